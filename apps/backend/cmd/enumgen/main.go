@@ -0,0 +1,237 @@
+// Command enumgen generates the methods of typed string enums, for use
+// with go generate.
+//
+// An enum is a string type with typed constants declared in its package:
+//
+//	//go:generate go run github.com/PrinceNarteh/go-boilerplate/cmd/enumgen -type=State
+//
+//	type State string
+//
+//	const (
+//		StatePending State = "pending"
+//		StateDead    State = "dead"
+//	)
+//
+// For each type, enumgen writes a file named after it, such as
+// state_enum.go, with:
+//
+//   - StateValues, returning the values in declaration order
+//   - IsValid, reporting whether a value is declared, which the "enum"
+//     validation tag checks, see package enums
+//   - ParseState, parsing and validating a string
+//   - MarshalText and UnmarshalText, so that JSON rejects undeclared values
+//   - Value and Scan, so that pgx rejects undeclared values read from or
+//     written to the database, NULL scanning into the zero value
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"unicode"
+)
+
+// enum is a type to generate and its constants, in declaration order
+type enum struct {
+	Package string
+	Type    string
+	Values  []string
+}
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("enumgen: ")
+
+	types := flag.String("type", "", "comma-separated names of the enum types")
+	dir := flag.String("dir", ".", "directory of the package declaring the types")
+	flag.Parse()
+	if *types == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	pkg, consts, err := parsePackage(*dir)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	for _, name := range strings.Split(*types, ",") {
+		e := enum{Package: pkg, Type: name, Values: consts[name]}
+		if len(e.Values) == 0 {
+			log.Fatalf("no constants of type %s in %s", name, *dir)
+		}
+
+		src, err := generate(e)
+		if err != nil {
+			log.Fatal(err)
+		}
+		path := filepath.Join(*dir, fileName(name))
+		if err := os.WriteFile(path, src, 0o644); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
+
+// parsePackage returns the name of the package in dir and the names of its
+// string constants, by type, in declaration order. Test and generated
+// files are skipped.
+func parsePackage(dir string) (string, map[string][]string, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.go"))
+	if err != nil {
+		return "", nil, err
+	}
+
+	fset := token.NewFileSet()
+	var pkg string
+	consts := make(map[string][]string)
+	for _, path := range paths {
+		if strings.HasSuffix(path, "_test.go") || strings.HasSuffix(path, "_enum.go") {
+			continue
+		}
+		file, err := parser.ParseFile(fset, path, nil, parser.SkipObjectResolution)
+		if err != nil {
+			return "", nil, err
+		}
+		pkg = file.Name.Name
+
+		for _, decl := range file.Decls {
+			gen, ok := decl.(*ast.GenDecl)
+			if !ok || gen.Tok != token.CONST {
+				continue
+			}
+			for _, spec := range gen.Specs {
+				vs := spec.(*ast.ValueSpec)
+				ident, ok := vs.Type.(*ast.Ident)
+				if !ok {
+					continue
+				}
+				for i, name := range vs.Names {
+					if i >= len(vs.Values) {
+						break
+					}
+					if lit, ok := vs.Values[i].(*ast.BasicLit); ok && lit.Kind == token.STRING {
+						consts[ident.Name] = append(consts[ident.Name], name.Name)
+					}
+				}
+			}
+		}
+	}
+	return pkg, consts, nil
+}
+
+// fileName returns the name of the generated file of typ, in snake case
+func fileName(typ string) string {
+	var b strings.Builder
+	for i, r := range typ {
+		if unicode.IsUpper(r) {
+			if i > 0 {
+				b.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String() + "_enum.go"
+}
+
+// generate returns the formatted source of the methods of e
+func generate(e enum) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := enumTemplate.Execute(&buf, e); err != nil {
+		return nil, err
+	}
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("failed to format %s: %w", e.Type, err)
+	}
+	return src, nil
+}
+
+// enumTemplate is the source of the generated file
+var enumTemplate = template.Must(template.New("enum").Funcs(template.FuncMap{
+	"lowerFirst": func(s string) string { return strings.ToLower(s[:1]) + s[1:] },
+	"join":       strings.Join,
+}).Parse(`// Code generated by enumgen -type={{.Type}}; DO NOT EDIT.
+
+package {{.Package}}
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"slices"
+)
+
+// {{lowerFirst .Type}}Values are the values of {{.Type}}, in declaration order
+var {{lowerFirst .Type}}Values = []{{.Type}}{ {{join .Values ", "}} }
+
+// {{.Type}}Values returns the values of {{.Type}}, in declaration order
+func {{.Type}}Values() []{{.Type}} {
+	return slices.Clone({{lowerFirst .Type}}Values)
+}
+
+// IsValid reports whether v is a declared value of {{.Type}}
+func (v {{.Type}}) IsValid() bool {
+	switch v {
+	case {{join .Values ", "}}:
+		return true
+	}
+	return false
+}
+
+// Parse{{.Type}} returns the {{.Type}} of s, or an error when s is not a declared value
+func Parse{{.Type}}(s string) ({{.Type}}, error) {
+	v := {{.Type}}(s)
+	if !v.IsValid() {
+		return "", fmt.Errorf("invalid {{.Type}} %q", s)
+	}
+	return v, nil
+}
+
+// MarshalText implements encoding.TextMarshaler
+func (v {{.Type}}) MarshalText() ([]byte, error) {
+	return []byte(v), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler, rejecting undeclared values
+func (v *{{.Type}}) UnmarshalText(text []byte) error {
+	parsed, err := Parse{{.Type}}(string(text))
+	if err != nil {
+		return err
+	}
+	*v = parsed
+	return nil
+}
+
+// Value implements driver.Valuer, rejecting undeclared values
+func (v {{.Type}}) Value() (driver.Value, error) {
+	if !v.IsValid() {
+		return nil, fmt.Errorf("invalid {{.Type}} %q", string(v))
+	}
+	return string(v), nil
+}
+
+// Scan implements sql.Scanner, rejecting undeclared values. NULL scans into
+// the zero value, which IsValid reports as undeclared.
+func (v *{{.Type}}) Scan(src any) error {
+	switch src := src.(type) {
+	case nil:
+		*v = ""
+		return nil
+	case string:
+		return v.UnmarshalText([]byte(src))
+	case []byte:
+		return v.UnmarshalText(src)
+	default:
+		return fmt.Errorf("cannot scan %T into {{.Type}}", src)
+	}
+}
+`))
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"os"
+	"runtime"
+
+	"github.com/rs/zerolog"
+
+	"github.com/PrinceNarteh/go-boilerplate/internal/config"
+	"github.com/PrinceNarteh/go-boilerplate/internal/version"
+)
+
+// subsystems records which optional subsystems were started
+type subsystems struct {
+	Database   bool
+	Redis      bool
+	NewRelic   bool
+	Metrics    bool
+	Prometheus bool
+	RateLimit  bool
+	GeoIP      bool
+	GRPC       bool
+	GraphQL    bool
+}
+
+// features returns the enabled state of each subsystem for usage statistics
+func (s subsystems) features() map[string]bool {
+	return map[string]bool{
+		"database":     s.Database,
+		"redis":        s.Redis,
+		"new_relic":    s.NewRelic,
+		"otel_metrics": s.Metrics,
+		"prometheus":   s.Prometheus,
+		"rate_limit":   s.RateLimit,
+		"geoip":        s.GeoIP,
+		"grpc":         s.GRPC,
+		"graphql":      s.GraphQL,
+	}
+}
+
+// logStartupBanner logs a single record describing the running build, the
+// runtime, the enabled subsystems and the listener, so the start of the logs
+// shows what is actually running.
+func logStartupBanner(logger *zerolog.Logger, cfg *config.Config, addr string, enabled subsystems) {
+	hostname, _ := os.Hostname()
+
+	logger.Info().
+		Str("version", version.Version).
+		Str("commit", version.Commit).
+		Str("build_time", version.BuildTime).
+		Str("go_version", runtime.Version()).
+		Str("os_arch", runtime.GOOS+"/"+runtime.GOARCH).
+		Int("gomaxprocs", runtime.GOMAXPROCS(0)).
+		Int("pid", os.Getpid()).
+		Str("hostname", hostname).
+		Str("env", cfg.Core.Env).
+		Str("log_level", cfg.Observability.GetLogLevel()).
+		Str("listen_addr", addr).
+		Bool("tls", cfg.Server.TLS.Enabled).
+		Dict("subsystems", zerolog.Dict().
+			Bool("database", enabled.Database).
+			Bool("redis", enabled.Redis).
+			Bool("new_relic", enabled.NewRelic).
+			Bool("otel_metrics", enabled.Metrics).
+			Bool("prometheus", enabled.Prometheus).
+			Bool("rate_limit", enabled.RateLimit).
+			Bool("geoip", enabled.GeoIP).
+			Bool("grpc", enabled.GRPC).
+			Bool("graphql", enabled.GraphQL),
+		).
+		Msg("Starting application")
+}
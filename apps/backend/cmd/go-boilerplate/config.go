@@ -0,0 +1,66 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/PrinceNarteh/go-boilerplate/internal/config"
+)
+
+// newConfigCommand creates the config command and its subcommands
+func newConfigCommand(a *app) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "config",
+		Short: "Inspect the configuration",
+	}
+
+	var format string
+	printCmd := &cobra.Command{
+		Use:   "print",
+		Short: "Print the configuration in effect, with defaults applied and secrets redacted",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			settings := config.Redacted(a.cfg)
+			switch format {
+			case "json":
+				enc := json.NewEncoder(os.Stdout)
+				enc.SetIndent("", "  ")
+				return enc.Encode(settings)
+			case "env":
+				printEnv(settings, "API")
+				return nil
+			default:
+				return fmt.Errorf("unknown format %q, expected env or json", format)
+			}
+		},
+	}
+	printCmd.Flags().StringVar(&format, "format", "env", "output format, env or json")
+
+	cmd.AddCommand(printCmd)
+	return cmd
+}
+
+// printEnv writes settings to stdout as the environment variables setting
+// them, sorted by name
+func printEnv(settings map[string]any, prefix string) {
+	for _, key := range slices.Sorted(maps.Keys(settings)) {
+		name := prefix + "_" + strings.ToUpper(key)
+		switch v := settings[key].(type) {
+		case map[string]any:
+			printEnv(v, name)
+		case []string:
+			// Space-separated values are loaded as lists
+			fmt.Fprintf(os.Stdout, "%s=%s\n", name, strings.Join(v, " "))
+		case nil:
+			fmt.Fprintf(os.Stdout, "%s=\n", name)
+		default:
+			fmt.Fprintf(os.Stdout, "%s=%v\n", name, v)
+		}
+	}
+}
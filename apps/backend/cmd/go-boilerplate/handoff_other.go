@@ -0,0 +1,8 @@
+//go:build !unix
+
+package main
+
+import "os"
+
+// notifyHandoff does nothing, as there is no handoff signal on this platform
+func notifyHandoff(chan<- os.Signal) {}
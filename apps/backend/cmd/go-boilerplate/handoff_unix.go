@@ -0,0 +1,15 @@
+//go:build unix
+
+package main
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// notifyHandoff relays SIGUSR2, which hands the listeners off to a new
+// process, to c
+func notifyHandoff(c chan<- os.Signal) {
+	signal.Notify(c, syscall.SIGUSR2)
+}
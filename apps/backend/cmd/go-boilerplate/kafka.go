@@ -0,0 +1,28 @@
+//go:build !nokafka
+
+package main
+
+import (
+	"github.com/rs/zerolog"
+
+	"github.com/PrinceNarteh/go-boilerplate/internal/config"
+	"github.com/PrinceNarteh/go-boilerplate/internal/messaging"
+	"github.com/PrinceNarteh/go-boilerplate/internal/messaging/kafka"
+)
+
+// newKafkaPublisher creates the Kafka producer publishing user events, and
+// the function flushing and closing it on shutdown.
+//
+// Consumers are started per topic, e.g.:
+//
+//	handleUserCreated := kafka.Typed(func(ctx context.Context, e events.UserCreated) error { return nil })
+//	consumer := kafka.NewConsumer(
+//	    cfg.Kafka, services.UserEventsTopic, handleUserCreated, messaging.JSONCodec{},
+//	    a.loggerService.GetTracer(), appLogger,
+//	)
+//	consumer.Start()
+//	lc.OnStop(lifecycle.PhaseWorkers, "kafka.users", lifecycle.Func(consumer.Stop))
+func newKafkaPublisher(cfg *config.Config, logger *zerolog.Logger) (messaging.Publisher, func(), error) {
+	producer := kafka.NewProducer(cfg.Kafka, messaging.JSONCodec{}, logger)
+	return producer, func() { producer.Close() }, nil
+}
@@ -0,0 +1,18 @@
+//go:build nokafka
+
+package main
+
+import (
+	"errors"
+
+	"github.com/rs/zerolog"
+
+	"github.com/PrinceNarteh/go-boilerplate/internal/config"
+	"github.com/PrinceNarteh/go-boilerplate/internal/messaging"
+)
+
+// newKafkaPublisher fails in builds with the nokafka tag, which leave the
+// Kafka client out of the binary
+func newKafkaPublisher(*config.Config, *zerolog.Logger) (messaging.Publisher, func(), error) {
+	return nil, nil, errors.New("built without Kafka support, rebuild without the nokafka tag")
+}
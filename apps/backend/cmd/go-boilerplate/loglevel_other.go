@@ -0,0 +1,9 @@
+//go:build !unix
+
+package main
+
+import "os"
+
+// notifyRestoreLogLevel does nothing, as there is no signal restoring the
+// log level on this platform
+func notifyRestoreLogLevel(chan<- os.Signal) {}
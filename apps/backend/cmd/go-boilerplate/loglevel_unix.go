@@ -0,0 +1,15 @@
+//go:build unix
+
+package main
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// notifyRestoreLogLevel relays SIGUSR1, which restores the configured log
+// level, to c
+func notifyRestoreLogLevel(c chan<- os.Signal) {
+	signal.Notify(c, syscall.SIGUSR1)
+}
@@ -2,85 +2,124 @@
 package main
 
 import (
-	"context"
-	"log"
+	"fmt"
 	"os"
-	"os/signal"
-	"syscall"
-	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/spf13/cobra"
 
 	"github.com/PrinceNarteh/go-boilerplate/internal/config"
+	"github.com/PrinceNarteh/go-boilerplate/internal/libs/async"
 	"github.com/PrinceNarteh/go-boilerplate/internal/logger"
-	"github.com/PrinceNarteh/go-boilerplate/internal/middlewares"
-	"github.com/PrinceNarteh/go-boilerplate/internal/routers"
-	"github.com/PrinceNarteh/go-boilerplate/internal/server"
+	"github.com/PrinceNarteh/go-boilerplate/internal/telemetry"
+	"github.com/PrinceNarteh/go-boilerplate/internal/version"
 )
 
-func main() {
-	// Load configuration
+// app holds the configuration and logger shared by every command
+type app struct {
+	cfg           *config.Config
+	loggerService *logger.LoggerService
+	logger        *zerolog.Logger
+	// flushSentry sends the errors buffered by Sentry
+	flushSentry func()
+}
+
+// load loads the configuration and initializes the logger. It runs before
+// any command, so commands only build what they need on top.
+func (a *app) load() error {
 	cfg, err := config.LoadConfig()
 	if err != nil {
-		log.Fatalf("Failed to load config: %v", err)
+		return fmt.Errorf("failed to load config: %w", err)
 	}
 
-	// Initialize logger service
 	loggerService := logger.NewLoggerService(cfg.Observability)
-	defer loggerService.Shutdown()
-
-	// Initialize logger
 	appLogger := logger.NewLoggerWithService(cfg.Observability, loggerService)
+	async.Configure(&appLogger, loggerService.GetTracer())
 
-	// Initialize database (uncomment when you have a database)
-	// db, err := database.New(cfg, &appLogger, loggerService)
-	// if err != nil {
-	//     appLogger.Fatal().Err(err).Msg("Failed to initialize database")
-	// }
-	// defer db.Close()
-
-	// Run migrations (uncomment when you have a database)
-	// ctx := context.Background()
-	// if err := database.Migrate(ctx, &appLogger, cfg); err != nil {
-	//     appLogger.Fatal().Err(err).Msg("Failed to run migrations")
-	// }
-
-	// Initialize router
-	router := routers.New(&appLogger)
-	router.SetupRoutes()
+	a.cfg = cfg
+	a.loggerService = loggerService
+	a.logger = &appLogger
 
-	// Setup middleware chain
-	middlewareChain := middlewares.Chain(
-		middlewares.Recovery(&appLogger),
-		middlewares.Logger(&appLogger),
-		middlewares.CORS(cfg.Server.CORSAllowedOrigins),
-	)
-
-	// Apply middleware to router
-	handler := middlewareChain(router)
-
-	// Initialize and start server
-	srv := server.New(cfg, handler, &appLogger)
-
-	// Start server in a goroutine
-	go func() {
-		if err := srv.Start(); err != nil {
-			appLogger.Fatal().Err(err).Msg("Failed to start server")
-		}
-	}()
+	// Report panics and server errors to Sentry alongside New Relic (optional)
+	flushSentry, err := telemetry.InitSentry(cfg.Observability)
+	if err != nil {
+		appLogger.Warn().Err(err).Msg("Sentry disabled")
+		flushSentry = func() {}
+	}
+	a.flushSentry = flushSentry
+	return nil
+}
 
-	// Wait for interrupt signal to gracefully shutdown
-	quit := make(chan os.Signal, 1)
-	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
-	<-quit
+// startErrorReporter batches the errors reported with telemetry.ReportError,
+// sent to New Relic, or to the logs without New Relic, when enabled. The
+// returned function sends the last batch.
+func (a *app) startErrorReporter() func() {
+	if !a.cfg.Observability.Errors.Enabled {
+		return func() {}
+	}
 
-	appLogger.Info().Msg("Shutting down server...")
+	var sink telemetry.ErrorSink = telemetry.NewLogErrorSink(a.logger)
+	if tracer := a.loggerService.GetTracer(); tracer != nil {
+		sink = telemetry.NewNewRelicErrorSink(tracer)
+	}
+	reporter := telemetry.NewErrorReporter(sink, a.cfg.Observability.Errors, a.logger)
+	reporter.Start()
+	telemetry.SetErrorReporter(reporter)
+	return reporter.Stop
+}
 
-	// Give server 30 seconds to shutdown gracefully
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancel()
+// close flushes the logs and the errors reported to Sentry
+func (a *app) close() {
+	if a.flushSentry != nil {
+		a.flushSentry()
+	}
+	if a.loggerService != nil {
+		a.loggerService.Shutdown()
+	}
+}
 
-	if err := srv.Stop(ctx); err != nil {
-		appLogger.Fatal().Err(err).Msg("Server forced to shutdown")
+func main() {
+	a := &app{}
+	err := newRootCommand(a).Execute()
+	if err != nil && a.logger != nil {
+		a.logger.Error().Err(err).Msg("Command failed")
+	} else if err != nil {
+		// The configuration was not loaded, e.g. on invalid arguments
+		fmt.Fprintln(os.Stderr, "Error:", err)
+	}
+	a.close()
+	if err != nil {
+		os.Exit(1)
 	}
+}
 
-	appLogger.Info().Msg("Server exited")
+// newRootCommand creates the command line of the binary. Without a
+// command, it serves the API, as before commands were introduced.
+func newRootCommand(a *app) *cobra.Command {
+	serve := newServeCommand(a)
+
+	root := &cobra.Command{
+		Use:     "go-boilerplate",
+		Short:   "Go boilerplate API server and operational commands",
+		Version: version.Version,
+		Args:    cobra.NoArgs,
+		RunE:    serve.RunE,
+		PersistentPreRunE: func(cmd *cobra.Command, _ []string) error {
+			// The arguments are valid, failures are not usage errors
+			cmd.SilenceUsage = true
+			return a.load()
+		},
+		// Errors are logged by main
+		SilenceErrors: true,
+	}
+	root.Flags().AddFlagSet(serve.Flags())
+	root.AddCommand(
+		serve,
+		newMigrateCommand(a),
+		newSeedCommand(a),
+		newWorkerCommand(a),
+		newRoutesCommand(a),
+		newConfigCommand(a),
+	)
+	return root
 }
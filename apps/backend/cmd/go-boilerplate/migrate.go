@@ -0,0 +1,175 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/rs/zerolog"
+	"github.com/spf13/cobra"
+
+	"github.com/PrinceNarteh/go-boilerplate/internal/config"
+	"github.com/PrinceNarteh/go-boilerplate/internal/database"
+	"github.com/PrinceNarteh/go-boilerplate/internal/repositories"
+	"github.com/PrinceNarteh/go-boilerplate/internal/services"
+)
+
+// newMigrateCommand creates the migrate command and its subcommands
+func newMigrateCommand(a *app) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "migrate",
+		Short: "Apply, roll back or inspect database migrations",
+	}
+	cmd.AddCommand(
+		&cobra.Command{
+			Use:   "up",
+			Short: "Apply all pending migrations",
+			Args:  cobra.NoArgs,
+			RunE: withMigrator(a, func(ctx context.Context, m *database.Migrator, _ []string) error {
+				return m.Up(ctx)
+			}),
+		},
+		&cobra.Command{
+			Use:   "down [n]",
+			Short: "Roll back the last n migrations (default 1)",
+			Args:  cobra.MatchAll(cobra.MaximumNArgs(1), stepsArg),
+			RunE: withMigrator(a, func(ctx context.Context, m *database.Migrator, args []string) error {
+				steps := 1
+				if len(args) > 0 {
+					steps, _ = strconv.Atoi(args[0])
+				}
+				return m.Down(ctx, steps)
+			}),
+		},
+		&cobra.Command{
+			Use:   "to <version>",
+			Short: "Migrate up or down to the given version",
+			Args:  cobra.MatchAll(cobra.ExactArgs(1), versionArg),
+			RunE: withMigrator(a, func(ctx context.Context, m *database.Migrator, args []string) error {
+				version, _ := strconv.ParseInt(args[0], 10, 32)
+				return m.To(ctx, int32(version))
+			}),
+		},
+		&cobra.Command{
+			Use:   "status",
+			Short: "Show the current version and pending migrations",
+			Args:  cobra.NoArgs,
+			RunE: withMigrator(a, func(ctx context.Context, m *database.Migrator, _ []string) error {
+				status, err := m.Status(ctx)
+				if err != nil {
+					return err
+				}
+				printMigrationStatus(status)
+				return nil
+			}),
+		},
+		&cobra.Command{
+			Use:   "tenants",
+			Short: "Apply all pending migrations to the schema of every tenant",
+			Args:  cobra.NoArgs,
+			RunE: func(cmd *cobra.Command, _ []string) error {
+				return migrateTenants(cmd.Context(), a.cfg, a.logger)
+			},
+		},
+		&cobra.Command{
+			Use:   "force <version>",
+			Short: "Set the version without running migrations and clear the dirty state",
+			Long: `Set the version without running migrations and clear the dirty state,
+after repairing a failed migration by hand.`,
+			Args: cobra.MatchAll(cobra.ExactArgs(1), versionArg),
+			RunE: withMigrator(a, func(ctx context.Context, m *database.Migrator, args []string) error {
+				version, _ := strconv.ParseInt(args[0], 10, 32)
+				return m.Force(ctx, int32(version))
+			}),
+		},
+	)
+	return cmd
+}
+
+// newSeedCommand creates the seed command
+func newSeedCommand(a *app) *cobra.Command {
+	var force bool
+	cmd := &cobra.Command{
+		Use:   "seed",
+		Short: "Fill the database with the development data of the embedded seeds",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			if a.cfg.Observability.IsProduction() && !force {
+				return errors.New("refusing to seed a production database without --force")
+			}
+			return database.Seed(cmd.Context(), a.logger, a.cfg)
+		},
+	}
+	cmd.Flags().BoolVar(&force, "force", false, "seed even in production")
+	return cmd
+}
+
+// withMigrator returns a command function running fn with a migrator
+// connected to the database
+func withMigrator(
+	a *app,
+	fn func(ctx context.Context, m *database.Migrator, args []string) error,
+) func(cmd *cobra.Command, args []string) error {
+	return func(cmd *cobra.Command, args []string) error {
+		ctx := cmd.Context()
+		m, err := database.NewMigrator(ctx, a.logger, a.cfg)
+		if err != nil {
+			return err
+		}
+		defer m.Close(ctx)
+
+		return fn(ctx, m, args)
+	}
+}
+
+// stepsArg validates the optional number of migrations argument
+func stepsArg(_ *cobra.Command, args []string) error {
+	if len(args) == 0 {
+		return nil
+	}
+	if steps, err := strconv.Atoi(args[0]); err != nil || steps < 1 {
+		return fmt.Errorf("invalid number of migrations: %s", args[0])
+	}
+	return nil
+}
+
+// versionArg validates the version argument
+func versionArg(_ *cobra.Command, args []string) error {
+	if _, err := strconv.ParseInt(args[0], 10, 32); err != nil {
+		return fmt.Errorf("invalid version: %s", args[0])
+	}
+	return nil
+}
+
+// migrateTenants applies all pending migrations to the schema of every
+// registered tenant
+func migrateTenants(ctx context.Context, cfg *config.Config, logger *zerolog.Logger) error {
+	db, err := database.New(cfg, logger, nil)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	tenants := services.NewTenantService(
+		repositories.NewTenantRepository(db),
+		func(ctx context.Context, schema string) error {
+			return database.MigrateSchema(ctx, logger, cfg, schema)
+		},
+		services.TenantServiceOptions{SchemaPrefix: cfg.Tenancy.SchemaPrefix, Logger: logger},
+	)
+	return tenants.MigrateAll(ctx)
+}
+
+// printMigrationStatus writes the migration status to stdout
+func printMigrationStatus(status database.MigrationStatus) {
+	fmt.Fprintf(os.Stdout, "version: %d/%d\n", status.Current, status.Latest)
+	if status.Dirty != nil {
+		fmt.Fprintf(os.Stdout, "dirty:   %d %s (%s, started %s)\n",
+			status.Dirty.Sequence, status.Dirty.Name, status.Dirty.Direction, status.Dirty.StartedAt.Format("2006-01-02 15:04:05"))
+	}
+	for _, name := range status.Pending {
+		fmt.Fprintf(os.Stdout, "pending: %s\n", name)
+	}
+}
@@ -0,0 +1,428 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/PrinceNarteh/go-boilerplate/internal/audit"
+	"github.com/PrinceNarteh/go-boilerplate/internal/auth"
+	"github.com/PrinceNarteh/go-boilerplate/internal/cache"
+	"github.com/PrinceNarteh/go-boilerplate/internal/caching"
+	"github.com/PrinceNarteh/go-boilerplate/internal/database"
+	"github.com/PrinceNarteh/go-boilerplate/internal/events"
+	"github.com/PrinceNarteh/go-boilerplate/internal/failover"
+	"github.com/PrinceNarteh/go-boilerplate/internal/graphql"
+	"github.com/PrinceNarteh/go-boilerplate/internal/grpcserver"
+	userv1 "github.com/PrinceNarteh/go-boilerplate/internal/grpcserver/gen/user/v1"
+	"github.com/PrinceNarteh/go-boilerplate/internal/handlers"
+	"github.com/PrinceNarteh/go-boilerplate/internal/healthcheck"
+	"github.com/PrinceNarteh/go-boilerplate/internal/jobs"
+	"github.com/PrinceNarteh/go-boilerplate/internal/lifecycle"
+	"github.com/PrinceNarteh/go-boilerplate/internal/mailer"
+	"github.com/PrinceNarteh/go-boilerplate/internal/matview"
+	prommetrics "github.com/PrinceNarteh/go-boilerplate/internal/metrics"
+	"github.com/PrinceNarteh/go-boilerplate/internal/middlewares"
+	"github.com/PrinceNarteh/go-boilerplate/internal/models"
+	"github.com/PrinceNarteh/go-boilerplate/internal/notifications"
+	"github.com/PrinceNarteh/go-boilerplate/internal/outbox"
+	"github.com/PrinceNarteh/go-boilerplate/internal/redis"
+	"github.com/PrinceNarteh/go-boilerplate/internal/repositories"
+	"github.com/PrinceNarteh/go-boilerplate/internal/routers"
+	"github.com/PrinceNarteh/go-boilerplate/internal/saga"
+	"github.com/PrinceNarteh/go-boilerplate/internal/services"
+	"github.com/PrinceNarteh/go-boilerplate/internal/tasks"
+	"github.com/PrinceNarteh/go-boilerplate/internal/telemetry"
+	"github.com/PrinceNarteh/go-boilerplate/internal/ws"
+)
+
+// openDatabase connects to the database when it is enabled, returning nil
+// otherwise. Queries fail fast following the failover policy of the
+// database while it is down, and its checks are registered with health
+// when set.
+func openDatabase(
+	a *app,
+	lc *lifecycle.Coordinator,
+	health *healthcheck.Service,
+	metrics *telemetry.Metrics,
+	promRegistry *prommetrics.Registry,
+) (*database.Database, error) {
+	cfg, appLogger := a.cfg, a.logger
+	if !cfg.Database.Enabled {
+		return nil, nil
+	}
+
+	db, err := database.New(cfg, appLogger, a.loggerService, telemetry.NewQueryTracer(metrics.DB))
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize database: %w", err)
+	}
+	lc.OnStop(lifecycle.PhaseClients, "database", lifecycle.Closer(db))
+	if promRegistry != nil {
+		if err := promRegistry.RegisterDBPool("primary", db.Pool); err != nil {
+			return nil, err
+		}
+	}
+
+	dbPolicy := failover.Policy(cfg.Failover.DatabasePolicy)
+	dbSupervisor := failover.New("database", healthcheck.DatabasePing(db.Pool), dbPolicy, cfg.Failover, appLogger)
+	dbSupervisor.Start()
+	lc.OnStop(lifecycle.PhaseWorkers, "failover.database", lifecycle.Func(dbSupervisor.Stop))
+	if health != nil {
+		health.Register(healthcheck.CheckDatabase, dbSupervisor.HealthCheck())
+		health.Register(healthcheck.CheckDatabasePool, healthcheck.PoolSaturation(db.Pool, 0.9))
+		health.Register(healthcheck.CheckReplicationLag, healthcheck.ReplicationLag(db.ReplicaPools(), 30*time.Second))
+	}
+	return db, nil
+}
+
+// sharedRedis is the Redis client of the database modules, created on
+// first use, so that Redis is only required by the modules configured to
+// use it
+type sharedRedis struct {
+	a            *app
+	lc           *lifecycle.Coordinator
+	promRegistry *prommetrics.Registry
+	client       *redis.Client
+}
+
+// get returns the client, creating it on the first call
+func (r *sharedRedis) get() (*redis.Client, error) {
+	if r.client != nil {
+		return r.client, nil
+	}
+
+	client, err := redis.New(r.a.cfg.Redis, r.a.logger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize Redis: %w", err)
+	}
+	r.lc.OnStop(lifecycle.PhaseClients, "redis.modules", lifecycle.Closer(client))
+	if r.promRegistry != nil {
+		if err := r.promRegistry.RegisterRedis("modules", client.Client); err != nil {
+			return nil, err
+		}
+	}
+	r.client = client
+	return client, nil
+}
+
+// jobWorkers run the jobs of the job queue, and of its spill store when
+// jobs overflow into PostgreSQL
+type jobWorkers []*jobs.Worker
+
+// Handle registers the handler of a job type with every worker
+func (w jobWorkers) Handle(jobType string, handler jobs.Handler) {
+	for _, worker := range w {
+		worker.Handle(jobType, handler)
+	}
+}
+
+// Start starts the workers, drained on shutdown
+func (w jobWorkers) Start(lc *lifecycle.Coordinator) {
+	for i, worker := range w {
+		worker.Start()
+		name := "jobs"
+		if i > 0 {
+			name = "jobs.spill"
+		}
+		lc.OnStop(lifecycle.PhaseWorkers, name, lifecycle.Func(worker.Stop))
+	}
+}
+
+// newJobs creates the job queue of the configured store and the workers
+// running its jobs. Jobs enqueued while a Redis queue is full spill into
+// PostgreSQL when API_JOBS_OVERFLOW is spill, with a worker of their own.
+func newJobs(
+	a *app,
+	db *database.Database,
+	rdb *sharedRedis,
+	metrics *telemetry.JobMetrics,
+) (*jobs.Queue, jobWorkers, error) {
+	cfg := a.cfg
+
+	var store jobs.Store = jobs.NewPostgresStore(db.Pool)
+	if cfg.Jobs.Store == "redis" {
+		client, err := rdb.get()
+		if err != nil {
+			return nil, nil, err
+		}
+		store = jobs.NewRedisStore(client, "")
+	}
+	workers := jobWorkers{jobs.NewWorker(store, cfg.Jobs, a.loggerService.GetTracer(), a.logger)}
+
+	opts := jobs.QueueOptions{Metrics: metrics}
+	if cfg.Jobs.Store == "redis" && cfg.Jobs.Overflow == "spill" {
+		spill := jobs.NewPostgresStore(db.Pool)
+		opts.Spill = spill
+		workers = append(workers, jobs.NewWorker(spill, cfg.Jobs, a.loggerService.GetTracer(), a.logger))
+	}
+	return jobs.NewQueue(store, cfg.Jobs, opts), workers, nil
+}
+
+// newSagas creates the saga coordinator, executing sagas on workers when
+// queue is set, which it registers its job handler with, and in-process
+// otherwise. With resume, it dispatches the sagas interrupted by a crash.
+// Sagas must be registered with the coordinator of every process running
+// the workers, before resuming.
+func newSagas(
+	a *app,
+	db *database.Database,
+	queue *jobs.Queue,
+	workers jobWorkers,
+	resume bool,
+) (*saga.Coordinator, error) {
+	var dispatcher saga.Dispatcher
+	if queue != nil {
+		dispatcher = saga.NewJobDispatcher(queue)
+	}
+	sagas := saga.NewCoordinator(saga.NewPostgresStore(db.Pool), dispatcher, a.logger)
+	workers.Handle(saga.ExecuteJob, sagas.JobHandler())
+	if a.cfg.Tenancy.Enabled {
+		sagas.Register(newTenantService(a, db).ProvisionSaga())
+	}
+
+	if resume {
+		if err := sagas.Resume(context.Background()); err != nil {
+			return nil, err
+		}
+	}
+	return sagas, nil
+}
+
+// newViews creates the scheduler refreshing the materialized views, which
+// must be started to refresh them on schedule
+func newViews(a *app, db *database.Database, metrics *telemetry.ViewMetrics) *matview.Scheduler {
+	views := matview.NewScheduler(db.Pool, metrics, a.logger)
+	views.Register(matview.View{Name: "user_signups_daily", Interval: time.Hour})
+	return views
+}
+
+// newTaskRunner creates the runner of the maintenance tasks, running them
+// on workers when queue is set, which it registers its job handler with,
+// and in-process otherwise
+func newTaskRunner(
+	a *app,
+	db *database.Database,
+	views *matview.Scheduler,
+	queue *jobs.Queue,
+	workers jobWorkers,
+) *tasks.Runner {
+	var dispatcher tasks.Dispatcher
+	if queue != nil {
+		dispatcher = tasks.NewJobDispatcher(queue)
+	}
+	runner := tasks.NewRunner(tasks.NewPostgresStore(db.Pool), dispatcher, a.logger)
+	runner.Register(tasks.RefreshView(views))
+	workers.Handle(tasks.ExecuteJob, runner.JobHandler())
+	return runner
+}
+
+// newOutbox creates the outbox recording events and the dispatcher
+// publishing them, at least once, to the broker of API_OUTBOX_BROKER
+func newOutbox(a *app, db *database.Database, rdb *sharedRedis) (*outbox.Store, *outbox.Dispatcher, error) {
+	cfg := a.cfg
+
+	var publisher outbox.Publisher = outbox.NewLogPublisher(a.logger)
+	if cfg.Outbox.Broker != "log" {
+		client, err := rdb.get()
+		if err != nil {
+			return nil, nil, err
+		}
+		publisher = outbox.NewRedisStreamPublisher(client, cfg.Outbox)
+	}
+
+	store := outbox.NewStore(db.Pool)
+	return store, outbox.NewDispatcher(store, publisher, cfg.Outbox, a.logger), nil
+}
+
+// newTenantService creates the service of tenants, whose queries run in
+// schemas of their own, migrated when tenants are created
+func newTenantService(a *app, db *database.Database) *services.TenantService {
+	cfg := a.cfg
+	return services.NewTenantService(
+		repositories.NewTenantRepository(db),
+		func(ctx context.Context, schema string) error {
+			return database.MigrateSchema(ctx, a.logger, cfg, schema)
+		},
+		services.TenantServiceOptions{SchemaPrefix: cfg.Tenancy.SchemaPrefix, Logger: a.logger},
+	)
+}
+
+// databaseModules are the components the feature modules stored in the
+// database are built on
+type databaseModules struct {
+	router         *routers.Router
+	apiMiddlewares []middlewares.Middleware
+	lc             *lifecycle.Coordinator
+	metrics        *telemetry.Metrics
+	promRegistry   *prommetrics.Registry
+	eventBus       *events.Bus
+	hub            *ws.Hub
+	tenants        *services.TenantService
+	enabled        *subsystems
+	// worker runs the background processing in this process
+	worker bool
+}
+
+// authentication authenticates the tokens of every transport
+type authentication struct {
+	// routes is the middleware authenticating the HTTP routes
+	routes middlewares.Middleware
+	// transports authenticates the other transports, such as gRPC and
+	// WebSocket, which serve no routes exempt from consents
+	transports *middlewares.Authenticator
+}
+
+// newAuthentication returns the authentication of tokens with opts
+func newAuthentication(a *app, opts middlewares.AuthenticateOptions) authentication {
+	tokens := auth.NewTokenManager(a.cfg.Auth.SecretKey, a.cfg.Observability.ServiceName)
+	return authentication{
+		routes:     middlewares.AuthenticateWith(tokens, opts),
+		transports: middlewares.NewAuthenticator(tokens, opts),
+	}
+}
+
+// registerDatabaseModules builds the feature modules stored in the
+// database and registers their routes, and starts their background
+// processing unless it runs in the worker command. It returns the
+// authentication of tokens against their user and session, and the user
+// service served over gRPC.
+func registerDatabaseModules(
+	a *app, db *database.Database, m databaseModules,
+) (authentication, *services.UserService, error) {
+	cfg, appLogger := a.cfg, a.logger
+	rdb := &sharedRedis{a: a, lc: m.lc, promRegistry: m.promRegistry}
+	lc := m.lc
+
+	// Refresh the materialized views
+	views := newViews(a, db, m.metrics.Views)
+	views.Start()
+	lc.OnStop(lifecycle.PhaseWorkers, "matview", lifecycle.Func(views.Stop))
+
+	// Run background jobs (optional), drained on shutdown
+	var jobQueue *jobs.Queue
+	var workers jobWorkers
+	if cfg.Jobs.Enabled {
+		var err error
+		if jobQueue, workers, err = newJobs(a, db, rdb, m.metrics.Jobs); err != nil {
+			return authentication{}, nil, err
+		}
+	}
+
+	// Run multi-step operations as sagas, and maintenance tasks on demand,
+	// on the job workers when enabled
+	if _, err := newSagas(a, db, jobQueue, workers, m.worker); err != nil {
+		return authentication{}, nil, err
+	}
+	taskRunner := newTaskRunner(a, db, views, jobQueue, workers)
+	if m.worker {
+		workers.Start(lc)
+	}
+
+	// Record user events in the outbox, published at least once
+	userOptions := services.UserServiceOptions{
+		Tx:     database.NewTxManager(db.Pool),
+		Events: events.UserEvents(m.eventBus),
+		Logger: appLogger,
+	}
+	if cfg.Outbox.Enabled {
+		eventOutbox, dispatcher, err := newOutbox(a, db, rdb)
+		if err != nil {
+			return authentication{}, nil, err
+		}
+		userOptions.Outbox = eventOutbox
+		if m.worker {
+			dispatcher.Start()
+			lc.OnStop(lifecycle.PhaseWorkers, "outbox", lifecycle.Func(dispatcher.Stop))
+		}
+	}
+
+	userCache := caching.Declare[models.User](cache.NewMemory(10000), handlers.UserCachePolicy, m.eventBus, m.metrics.Cache)
+	userRepo := repositories.NewCachedUserRepository(repositories.NewUserRepository(db), userCache.Values())
+	userService := services.NewUserService(userRepo, userOptions)
+	accountService := services.NewAccountService(userRepo, repositories.NewSessionRepository(db),
+		audit.NewPostgresLog(db.Pool), services.AccountServiceOptions{Tx: database.NewTxManager(db.Pool)})
+
+	// Look up the principal of tokens once per request, and across requests in Redis for a short TTL.
+	// The account service rejects the tokens of revoked sessions on every request, uncached.
+	authOptions := middlewares.AuthenticateOptions{Loader: userService, Checker: accountService}
+	// Run the queries of tokens issued in a tenant in the schema of that tenant
+	if cfg.Tenancy.Enabled {
+		authOptions.Tenants = m.tenants
+		authOptions.TenantHeader = cfg.Tenancy.Header
+	}
+	if cfg.Auth.PrincipalCacheTTL > 0 {
+		client, err := rdb.get()
+		if err != nil {
+			return authentication{}, nil, err
+		}
+		authOptions.Cache = cache.New[auth.Principal](cache.NewRedis(client), cache.Options{
+			Name: "principals", TTL: cfg.Auth.PrincipalCacheTTL, Metrics: m.metrics.Cache,
+		})
+	}
+
+	// Hold back the users who have not accepted the latest consent documents
+	// from the feature routes, leaving them their account and the consent routes
+	consents := repositories.NewConsentRepository(db)
+	authOptions.Consents = consents
+	authn := newAuthentication(a, authOptions)
+	authenticate := authn.routes
+	consented := middlewares.Chain(authenticate, middlewares.RequireConsent(consents))
+
+	// Skip the addresses the email provider reported as bouncing or complaining
+	suppressions := mailer.NewPostgresSuppressionList(db.Pool)
+	appMailer := mailer.Suppressing(mailer.NewLogMailer(appLogger), suppressions)
+	m.router.RegisterAPI(
+		handlers.NewUserHandler(userService, consented, userCache.Middleware()),
+		handlers.NewAccountHandler(userService, accountService, authenticate),
+		handlers.NewConsentHandler(consents, authenticate),
+		handlers.NewEmailChangeHandler(repositories.NewEmailChangeRepository(db), userRepo, appMailer, consented),
+		handlers.NewEmailSuppressionHandler(suppressions, cfg.Email.WebhookSecret, authenticate),
+		handlers.NewTenantHandler(m.tenants, consented),
+	)
+	events.SubscribeAsync(m.eventBus, "welcome-email", func(ctx context.Context, e events.UserCreated) error {
+		return appMailer.Send(ctx, mailer.Message{To: e.User.Email, Subject: "Welcome"})
+	})
+
+	m.router.RegisterAPI(handlers.NewTaskHandler(taskRunner, consented))
+
+	// Notify users on the channels of their preferences
+	notificationPrefs := repositories.NewNotificationPreferenceRepository(db)
+	notifier := notifications.NewNotifier(notificationPrefs, map[notifications.Channel]notifications.Sender{
+		notifications.ChannelEmail: notifications.EmailSender(appMailer,
+			func(ctx context.Context, userID int) (string, error) {
+				user, err := userRepo.GetByID(ctx, userID)
+				if err != nil {
+					return "", err
+				}
+				return user.Email, nil
+			}),
+		notifications.ChannelPush:  notifications.LogSender(notifications.ChannelPush, appLogger),
+		notifications.ChannelInApp: notifications.InAppSender(m.hub),
+	}, appLogger)
+	m.router.RegisterAPI(handlers.NewNotificationPreferenceHandler(notificationPrefs, consented))
+	events.SubscribeAsync(m.eventBus, "account-notification", func(ctx context.Context, e events.UserUpdated) error {
+		return notifier.Notify(ctx, notifications.Notification{
+			Kind:    services.AccountUpdatedNotification,
+			UserID:  e.User.ID,
+			Subject: "Your account was updated",
+			Text:    "The details of your account were changed.",
+		})
+	})
+
+	// Serve the user service as REST routes below /api/v1/rpc sharing the API middleware
+	gateway := grpcserver.NewGateway(consented)
+	if err := userv1.RegisterUserServiceHandlerServer(
+		context.Background(), gateway.Mux(), grpcserver.NewUserService(userService),
+	); err != nil {
+		return authentication{}, nil, fmt.Errorf("failed to register gRPC gateway: %w", err)
+	}
+	m.router.RegisterAPI(gateway)
+
+	// Serve the GraphQL endpoint (optional)
+	if cfg.GraphQL.Enabled {
+		m.router.Register(graphql.New(cfg.GraphQL, cfg.Observability.IsProduction(), userService, userRepo,
+			append(m.apiMiddlewares, consented)...))
+		m.enabled.GraphQL = true
+	}
+	return authn, userService, nil
+}
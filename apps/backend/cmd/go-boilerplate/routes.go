@@ -0,0 +1,60 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"github.com/PrinceNarteh/go-boilerplate/internal/events"
+	"github.com/PrinceNarteh/go-boilerplate/internal/healthcheck"
+	"github.com/PrinceNarteh/go-boilerplate/internal/metrics"
+	"github.com/PrinceNarteh/go-boilerplate/internal/middlewares"
+	"github.com/PrinceNarteh/go-boilerplate/internal/statuspage"
+	"github.com/PrinceNarteh/go-boilerplate/internal/ws"
+)
+
+// newRoutesCommand creates the routes command, listing the routes of the
+// router without serving them
+func newRoutesCommand(a *app) *cobra.Command {
+	var asJSON bool
+	cmd := &cobra.Command{
+		Use:   "routes",
+		Short: "List the routes registered on the router",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			// Nothing is started, the services only back the registered handlers
+			health := healthcheck.New(a.cfg.Observability.HealthChecks, a.logger)
+			var statusPage *statuspage.Page
+			if a.cfg.StatusPage.Enabled {
+				statusPage = statuspage.New(health, statuspage.NewMemoryIncidents(), a.cfg.StatusPage.UptimeWindow)
+			}
+			var promRegistry *metrics.Registry
+			if a.cfg.Observability.Prometheus.Enabled {
+				promRegistry = metrics.New(a.cfg.Observability.Prometheus)
+			}
+			eventBus, hub := events.NewBus(a.logger), ws.NewHub(a.cfg.WebSocket, a.logger)
+			router := newRouter(a, nil, health, eventBus, hub, statusPage, promRegistry)
+			registerWebSocket(a, router, hub, eventBus, newAuthentication(a, middlewares.AuthenticateOptions{}).transports)
+			routes := router.Routes()
+
+			if asJSON {
+				return json.NewEncoder(os.Stdout).Encode(routes)
+			}
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "METHOD\tPATTERN")
+			for _, route := range routes {
+				method := route.Method
+				if method == "" {
+					method = "ANY"
+				}
+				fmt.Fprintf(w, "%s\t%s\n", method, route.Pattern)
+			}
+			return w.Flush()
+		},
+	}
+	cmd.Flags().BoolVar(&asJSON, "json", false, "print the routes as JSON")
+	return cmd
+}
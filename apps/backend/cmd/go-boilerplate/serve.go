@@ -0,0 +1,814 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/spf13/cobra"
+	"go.opentelemetry.io/otel"
+
+	"github.com/PrinceNarteh/go-boilerplate/internal/auth"
+	"github.com/PrinceNarteh/go-boilerplate/internal/config"
+	"github.com/PrinceNarteh/go-boilerplate/internal/events"
+	"github.com/PrinceNarteh/go-boilerplate/internal/failover"
+	"github.com/PrinceNarteh/go-boilerplate/internal/flags"
+	"github.com/PrinceNarteh/go-boilerplate/internal/geoip"
+	"github.com/PrinceNarteh/go-boilerplate/internal/grpcserver"
+	userv1 "github.com/PrinceNarteh/go-boilerplate/internal/grpcserver/gen/user/v1"
+	"github.com/PrinceNarteh/go-boilerplate/internal/handlers"
+	"github.com/PrinceNarteh/go-boilerplate/internal/healthcheck"
+	"github.com/PrinceNarteh/go-boilerplate/internal/i18n"
+	"github.com/PrinceNarteh/go-boilerplate/internal/libs/async"
+	"github.com/PrinceNarteh/go-boilerplate/internal/lifecycle"
+	"github.com/PrinceNarteh/go-boilerplate/internal/lists"
+	"github.com/PrinceNarteh/go-boilerplate/internal/logger"
+	"github.com/PrinceNarteh/go-boilerplate/internal/mailer"
+	"github.com/PrinceNarteh/go-boilerplate/internal/messaging"
+	"github.com/PrinceNarteh/go-boilerplate/internal/messaging/nats"
+	prommetrics "github.com/PrinceNarteh/go-boilerplate/internal/metrics"
+	"github.com/PrinceNarteh/go-boilerplate/internal/middlewares"
+	"github.com/PrinceNarteh/go-boilerplate/internal/openapi"
+	"github.com/PrinceNarteh/go-boilerplate/internal/redis"
+	"github.com/PrinceNarteh/go-boilerplate/internal/routers"
+	"github.com/PrinceNarteh/go-boilerplate/internal/server"
+	"github.com/PrinceNarteh/go-boilerplate/internal/services"
+	"github.com/PrinceNarteh/go-boilerplate/internal/statuspage"
+	"github.com/PrinceNarteh/go-boilerplate/internal/telemetry"
+	"github.com/PrinceNarteh/go-boilerplate/internal/usagestats"
+	"github.com/PrinceNarteh/go-boilerplate/internal/ws"
+)
+
+// serveOptions are the flags of the serve command
+type serveOptions struct {
+	Port   string
+	Worker bool
+}
+
+// newServeCommand creates the serve command, which the binary runs by default
+func newServeCommand(a *app) *cobra.Command {
+	var opts serveOptions
+	cmd := &cobra.Command{
+		Use:   "serve",
+		Short: "Serve the API until SIGINT or SIGTERM",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			if opts.Port != "" {
+				a.cfg.Server.Port = opts.Port
+			}
+			return runServe(a, opts)
+		},
+	}
+	cmd.Flags().StringVar(&opts.Port, "port", "", "port to listen on, overriding API_SERVER_PORT")
+	cmd.Flags().BoolVar(&opts.Worker, "worker", true,
+		"run scheduled tasks and background jobs too, disable when they run in the worker command")
+	return cmd
+}
+
+// serverShutdownTimeout is how long the servers have to finish the requests
+// in flight on shutdown
+const serverShutdownTimeout = 30 * time.Second
+
+// runServe serves the API until SIGINT or SIGTERM
+func runServe(a *app, opts serveOptions) error {
+	cfg, appLogger := a.cfg, a.logger
+
+	// Stop the components in order on shutdown, or when startup fails
+	lc := lifecycle.New(appLogger)
+	defer lc.Shutdown()
+
+	// Registered first so it runs last: once every component has stopped,
+	// wait for the background goroutines they started
+	lc.OnStop(lifecycle.PhaseTelemetry, "goroutines", async.Shutdown)
+
+	// Initialize OpenTelemetry metrics (optional)
+	metrics := telemetry.NoopMetrics()
+	if cfg.Observability.Metrics.Enabled {
+		provider, err := telemetry.NewMeterProvider(context.Background(), cfg.Observability)
+		if err != nil {
+			return fmt.Errorf("failed to initialize metrics: %w", err)
+		}
+		lc.OnStop(lifecycle.PhaseTelemetry, "metrics", provider.Shutdown)
+		otel.SetMeterProvider(provider)
+
+		if metrics, err = telemetry.NewMetrics(provider); err != nil {
+			return fmt.Errorf("failed to create metric instruments: %w", err)
+		}
+	}
+
+	// Serve Prometheus metrics for scraping (optional). Database pools and
+	// Redis clients register their statistics with promRegistry.
+	var promRegistry *prommetrics.Registry
+	if cfg.Observability.Prometheus.Enabled {
+		promRegistry = prommetrics.New(cfg.Observability.Prometheus)
+	}
+
+	// Forward business events to New Relic custom events, or to the logs without New Relic
+	if cfg.Observability.Events.Enabled {
+		var sink telemetry.EventSink = telemetry.NewLogEventSink(appLogger)
+		if tracer := a.loggerService.GetTracer(); tracer != nil {
+			sink = telemetry.NewNewRelicEventSink(tracer)
+		}
+		recorder := telemetry.NewEventRecorder(sink, cfg.Observability.Events, appLogger)
+		recorder.Start()
+		lc.OnStop(lifecycle.PhaseTelemetry, "events", lifecycle.Func(recorder.Stop))
+		telemetry.SetEventRecorder(recorder)
+	}
+
+	// Merge repeated errors before they reach New Relic
+	lc.OnStop(lifecycle.PhaseTelemetry, "errors", lifecycle.Func(a.startErrorReporter()))
+
+	// Track optional subsystems for the startup banner
+	enabled := subsystems{
+		NewRelic:   a.loggerService.GetTracer() != nil,
+		Metrics:    cfg.Observability.Metrics.Enabled,
+		Prometheus: cfg.Observability.Prometheus.Enabled,
+		RateLimit:  cfg.RateLimit.Enabled,
+		Redis:      cfg.RateLimit.Enabled && cfg.RateLimit.Store == "redis",
+		GeoIP:      cfg.GeoIP.Enabled,
+	}
+
+	// Initialize readiness checks, polled in the background
+	health := healthcheck.New(cfg.Observability.HealthChecks, appLogger)
+
+	// Keep the history of the checks in Redis, so it survives restarts (optional)
+	if cfg.Observability.HealthChecks.HistoryStore == "redis" {
+		client, err := redis.New(cfg.Redis, appLogger)
+		if err != nil {
+			return fmt.Errorf("failed to initialize Redis: %w", err)
+		}
+		lc.OnStop(lifecycle.PhaseClients, "redis.health_history", lifecycle.Closer(client))
+		if promRegistry != nil {
+			if err := promRegistry.RegisterRedis("health_history", client.Client); err != nil {
+				return err
+			}
+		}
+		health.SetHistory(healthcheck.NewRedisHistory(client, "", cfg.Observability.HealthChecks.HistorySize))
+		enabled.Redis = true
+	}
+
+	// Connect to the database of the feature modules (optional)
+	db, err := openDatabase(a, lc, health, metrics, promRegistry)
+	if err != nil {
+		return err
+	}
+	enabled.Database = db != nil
+
+	// Run recurring tasks and the jobs declared in the scheduler file (optional), unless they run in a worker
+	sched, err := newScheduler(a, lc, db)
+	if err != nil {
+		return err
+	}
+	if opts.Worker {
+		sched.Start()
+		lc.OnStop(lifecycle.PhaseWorkers, "scheduler", lifecycle.Func(sched.Stop))
+	}
+
+	// Evaluate the feature flags declared in the flags file (optional), reloaded on SIGHUP.
+	// Routes are gated with middlewares.RequireFeature(featureFlags, flag, http.StatusNotFound).
+	featureFlags := flags.New(cfg.Flags.Declarations)
+	if cfg.Flags.Enabled {
+		goUntilStop(lc, "flags.reload", func(ctx context.Context) error {
+			return reloadFlags(ctx, cfg.Flags.File, featureFlags, appLogger)
+		})
+	}
+
+	// Replace the embedded third-party lists with their files and URLs (optional),
+	// reloaded every refresh interval and on SIGHUP. A list failing to load keeps its entries.
+	listLoader := lists.NewLoader(cfg.Lists.RefreshInterval, appLogger)
+	listLoader.Add(lists.DisposableDomains, lists.Source(cfg.Lists.DisposableDomains))
+	listLoader.Add(lists.BlockedIPs, lists.Source(cfg.Lists.BlockedIPs))
+	listLoader.Add(lists.ReservedUsernames, lists.Source(cfg.Lists.ReservedUsernames))
+	if err := listLoader.Load(context.Background()); err != nil {
+		appLogger.Error().Err(err).Msg("Failed to load lists, keeping the embedded ones")
+	}
+	listLoader.Start()
+	lc.OnStop(lifecycle.PhaseWorkers, "lists", lifecycle.Func(listLoader.Stop))
+	goUntilStop(lc, "lists.reload", func(ctx context.Context) error {
+		return reloadLists(ctx, listLoader, appLogger)
+	})
+
+	// Restore the configured log level, changed at runtime through the admin endpoint, on SIGUSR1
+	goUntilStop(lc, "loglevel.restore", func(ctx context.Context) error {
+		return restoreLogLevel(ctx, appLogger)
+	})
+
+	// Load the message catalog used to localize responses
+	catalog, err := i18n.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load message catalog: %w", err)
+	}
+	if err := catalog.Check(); err != nil {
+		return fmt.Errorf("message catalog is incomplete: %w", err)
+	}
+
+	// Attribute requests forwarded by trusted proxies to their client
+	if err := middlewares.SetTrustedProxies(cfg.Server.TrustedProxies); err != nil {
+		return err
+	}
+
+	// Setup middleware chain
+	chain := []middlewares.Middleware{
+		middlewares.RequestID(),
+		middlewares.Metrics(metrics.HTTP),
+	}
+	if promRegistry != nil {
+		chain = append(chain, promRegistry.Middleware())
+	}
+	chain = append(chain,
+		middlewares.Recovery(appLogger),
+		middlewares.LoggerWithOptions(appLogger, middlewares.AccessLogOptions{
+			ExcludePaths: cfg.AccessLog.ExcludePaths,
+			SampleRates: map[int]float64{
+				2: cfg.AccessLog.SuccessSampleRate,
+				3: cfg.AccessLog.SuccessSampleRate,
+				4: cfg.AccessLog.ClientErrorSampleRate,
+				5: cfg.AccessLog.ServerErrorSampleRate,
+			},
+			RouteSampleRates: cfg.AccessLog.RouteRates,
+			SlowThreshold:    cfg.AccessLog.SlowThreshold,
+			SlowOnly:         cfg.AccessLog.SlowOnly,
+		}),
+		middlewares.SecurityHeaders(cfg.SecurityHeaders),
+		middlewares.CORSPolicies(cfg.CORS.Policies),
+		middlewares.BodyLimit(cfg.Server.MaxRequestBodyBytes, cfg.Server.BodyLimits),
+		middlewares.Localization(catalog),
+	)
+
+	// Initialize IP geolocation (optional)
+	if cfg.GeoIP.Enabled {
+		resolver, err := geoip.OpenMaxMind(cfg.GeoIP.CountryDBPath, cfg.GeoIP.ASNDBPath)
+		if err != nil {
+			return fmt.Errorf("failed to open GeoIP databases: %w", err)
+		}
+		lc.OnStop(lifecycle.PhaseClients, "geoip", lifecycle.Closer(resolver))
+		chain = append(chain, middlewares.GeoIP(resolver, appLogger))
+		if len(cfg.GeoIP.CountryBlocks) > 0 {
+			chain = append(chain, middlewares.BlockCountries(cfg.GeoIP.CountryBlocks))
+		}
+	}
+
+	// Validate requests and responses against the OpenAPI spec (optional),
+	// to catch drift between the handlers and the spec before production
+	if cfg.OpenAPI.Validate && !cfg.Observability.IsProduction() {
+		validator, err := openapi.NewValidator(cfg.OpenAPI, appLogger)
+		if err != nil {
+			return err
+		}
+		chain = append(chain, validator.Middleware())
+	}
+	middlewareChain := middlewares.Chain(chain...)
+
+	// Setup route-level middleware applied to API routes only
+	apiMiddlewares := []middlewares.Middleware{middlewares.BlockIPs(lists.BlockedIPs)}
+	// Declared throttles share the store of the rate limiter
+	throttles := middlewares.NewThrottleRegistry(
+		middlewares.NewMemoryRateLimitStore(), middlewares.NewMemoryConcurrencyStore(), appLogger)
+	if cfg.RateLimit.Enabled {
+		var store middlewares.RateLimitStore = middlewares.NewMemoryRateLimitStore()
+		if cfg.RateLimit.Store == "redis" {
+			client, err := redis.New(cfg.Redis, appLogger)
+			if err != nil {
+				return fmt.Errorf("failed to initialize Redis: %w", err)
+			}
+			lc.OnStop(lifecycle.PhaseClients, "redis.rate_limit", lifecycle.Closer(client))
+			if promRegistry != nil {
+				if err := promRegistry.RegisterRedis("rate_limit", client.Client); err != nil {
+					return err
+				}
+			}
+
+			// Skip the store while Redis is down instead of waiting on connection timeouts
+			redisPolicy := failover.Policy(cfg.Failover.RedisPolicy)
+			redisSupervisor := failover.New("redis", client.HealthCheck(), redisPolicy, cfg.Failover, appLogger)
+			redisSupervisor.Start()
+			lc.OnStop(lifecycle.PhaseWorkers, "failover.redis", lifecycle.Func(redisSupervisor.Stop))
+			health.Register(healthcheck.CheckRedis, redisSupervisor.HealthCheck())
+
+			store = supervisedRateLimitStore{
+				RateLimitStore: middlewares.NewRedisRateLimitStore(client, "ratelimit:"),
+				supervisor:     redisSupervisor,
+			}
+			throttles = middlewares.NewThrottleRegistry(
+				supervisedRateLimitStore{
+					RateLimitStore: middlewares.NewRedisRateLimitStore(client, "throttle:"),
+					supervisor:     redisSupervisor,
+				},
+				supervisedConcurrencyStore{
+					ConcurrencyStore: middlewares.NewRedisConcurrencyStore(client, "throttle:"),
+					supervisor:       redisSupervisor,
+				},
+				appLogger,
+			)
+		}
+		apiMiddlewares = append(apiMiddlewares, newRateLimiter(cfg, store, appLogger))
+	}
+	middlewares.SetThrottleRegistry(throttles)
+
+	var tenantService *services.TenantService
+	if db != nil {
+		tenantService = newTenantService(a, db)
+	}
+
+	// Dispatch domain events to in-process subscribers, drained on shutdown
+	eventBus := events.NewBus(appLogger)
+	lc.OnStop(lifecycle.PhaseWorkers, "events.bus", eventBus.Shutdown)
+
+	// Push user events to the WebSocket connections of the user, closed on shutdown
+	hub := ws.NewHub(cfg.WebSocket, appLogger)
+	lc.OnStop(lifecycle.PhaseWorkers, "websocket", hub.Shutdown)
+
+	// Summarize the health checks on the public status page (optional), with
+	// the incident declared by administrators
+	var statusPage *statuspage.Page
+	if cfg.StatusPage.Enabled {
+		incidents, closeIncidents, err := newIncidentStore(cfg, appLogger)
+		if err != nil {
+			return err
+		}
+		lc.OnStop(lifecycle.PhaseClients, "incidents", func(context.Context) error { return closeIncidents() })
+		statusPage = statuspage.New(health, incidents, cfg.StatusPage.UptimeWindow)
+	}
+
+	// Initialize router
+	router := newRouter(a, apiMiddlewares, health, eventBus, hub, statusPage, promRegistry)
+	health.Start()
+	lc.OnStop(lifecycle.PhaseWorkers, "healthcheck", lifecycle.Func(health.Stop))
+
+	// Publish user events to the message broker of API_MESSAGING_DRIVER, flushed on shutdown
+	var publisher messaging.Publisher
+	switch cfg.Messaging.Driver {
+	case messaging.DriverKafka:
+		producer, closeProducer, err := newKafkaPublisher(cfg, appLogger)
+		if err != nil {
+			return fmt.Errorf("failed to initialize Kafka: %w", err)
+		}
+		lc.OnStop(lifecycle.PhaseClients, "kafka", lifecycle.Func(closeProducer))
+		publisher = producer
+	case messaging.DriverNATS:
+		client, err := nats.Connect(
+			context.Background(), cfg.Messaging.NATS, messaging.JSONCodec{}, a.loggerService.GetTracer(), appLogger,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to initialize NATS: %w", err)
+		}
+		lc.OnStop(lifecycle.PhaseClients, "nats", lifecycle.Closer(client))
+		health.Register(healthcheck.CheckNATS, client.HealthCheck())
+		publisher = client
+	}
+	if publisher != nil {
+		events.SubscribeAsync(eventBus, "messaging", func(ctx context.Context, e events.UserCreated) error {
+			return publisher.Publish(ctx, services.UserEventsTopic, strconv.Itoa(e.User.ID), e)
+		})
+	}
+
+	// Register the feature modules stored in the database, whose tokens are
+	// checked against their user and session, or only verified without it
+	authn := newAuthentication(a, middlewares.AuthenticateOptions{})
+	var userService *services.UserService
+	if db != nil {
+		authn, userService, err = registerDatabaseModules(a, db, databaseModules{
+			router:         router,
+			apiMiddlewares: apiMiddlewares,
+			lc:             lc,
+			metrics:        metrics,
+			promRegistry:   promRegistry,
+			eventBus:       eventBus,
+			hub:            hub,
+			tenants:        tenantService,
+			enabled:        &enabled,
+			worker:         opts.Worker,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	router.RegisterAPI(
+		handlers.NewSchedulerHandler(sched, authn.routes),
+		handlers.NewHealthHistoryHandler(health, authn.routes),
+		handlers.NewLogLevelHandler(authn.routes),
+	)
+	if statusPage != nil {
+		router.RegisterAPI(handlers.NewIncidentHandler(statusPage, authn.routes))
+	}
+	registerWebSocket(a, router, hub, eventBus, authn.transports)
+
+	// Serve gRPC services on their own port (optional)
+	var grpcServer *grpcserver.Server
+	if cfg.GRPC.Enabled {
+		grpcServer = grpcserver.New(cfg.GRPC, authn.transports, a.loggerService.GetTracer(), appLogger)
+		if userService != nil {
+			userv1.RegisterUserServiceServer(grpcServer, grpcserver.NewUserService(userService))
+		}
+		enabled.GRPC = true
+	}
+
+	// Apply middleware to router
+	handler := middlewareChain(router)
+
+	// Initialize and start server
+	srv, err := server.New(cfg, handler, appLogger)
+	if err != nil {
+		return err
+	}
+	logStartupBanner(appLogger, cfg, srv.Addr(), enabled)
+
+	// Serve the debug endpoints on their own port, to be kept internal (optional)
+	var debugServer *server.Server
+	if cfg.Server.EnablePprof && cfg.Server.PprofPort != "" && cfg.Server.AdminListen == "" {
+		debugRouter := routers.New(appLogger)
+		debugRouter.Register(routers.NewDebugModule())
+		debugServer = server.NewDebug(cfg.Server.PprofPort, debugRouter, appLogger)
+	}
+
+	// Serve the probes, metrics, debug endpoints and configuration on an
+	// internal address, with a middleware chain of their own (optional)
+	var adminServer *server.Server
+	if cfg.Server.AdminListen != "" {
+		adminServer, err = server.NewAdmin(cfg, newAdminHandler(a, health, promRegistry), appLogger)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Send anonymous usage statistics (opt-in, off by default)
+	usageStats := usagestats.NewReporter(cfg.UsageStats, enabled.features(), appLogger)
+	usageStats.Start()
+	lc.OnStop(lifecycle.PhaseWorkers, "usagestats", lifecycle.Func(usageStats.Stop))
+
+	// Initialization is complete, let startup probes succeed
+	health.MarkStarted()
+
+	// Start the servers in the background; a server failing to start stops
+	// the process like an interrupt would, after shutting down the others
+	serverErr := async.Go(context.Background(), "server", func(context.Context) error {
+		return srv.Start()
+	})
+	lc.Register(lifecycle.Hook{
+		Name: "server", Phase: lifecycle.PhaseServers, Timeout: serverShutdownTimeout, Stop: srv.Stop,
+	})
+	var grpcErr <-chan error
+	if grpcServer != nil {
+		grpcErr = async.Go(context.Background(), "grpcserver", func(context.Context) error {
+			return grpcServer.Start()
+		})
+		lc.Register(lifecycle.Hook{
+			Name: "grpcserver", Phase: lifecycle.PhaseServers, Timeout: serverShutdownTimeout, Stop: grpcServer.Stop,
+		})
+	}
+	var debugErr <-chan error
+	if debugServer != nil {
+		debugErr = async.Go(context.Background(), "debugserver", func(context.Context) error {
+			return debugServer.Start()
+		})
+		lc.OnStop(lifecycle.PhaseServers, "debugserver", debugServer.Stop)
+	}
+	var adminErr <-chan error
+	if adminServer != nil {
+		adminErr = async.Go(context.Background(), "adminserver", func(context.Context) error {
+			return adminServer.Start()
+		})
+		lc.OnStop(lifecycle.PhaseServers, "adminserver", adminServer.Stop)
+	}
+
+	// Wait for interrupt signal to gracefully shutdown, or for the handoff
+	// signal to pass the listeners to a new process before shutting down
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	handoff := make(chan os.Signal, 1)
+	notifyHandoff(handoff)
+	var startErr error
+	handedOff := false
+wait:
+	for {
+		select {
+		case <-quit:
+		case <-handoff:
+			// The gRPC and debug servers listen on ports of their own, which the
+			// new process would fail to listen on
+			if grpcServer != nil || debugServer != nil {
+				appLogger.Error().Msg("Cannot hand off listeners with the gRPC or debug server running, still serving")
+				continue
+			}
+			if handedOff = handOff(appLogger, srv, adminServer); !handedOff {
+				continue
+			}
+		case err := <-serverErr:
+			startErr = fmt.Errorf("failed to start server: %w", err)
+		case err := <-grpcErr:
+			startErr = fmt.Errorf("failed to start gRPC server: %w", err)
+		case err := <-debugErr:
+			startErr = fmt.Errorf("failed to start debug server: %w", err)
+		case err := <-adminErr:
+			startErr = fmt.Errorf("failed to start admin server: %w", err)
+		}
+		break wait
+	}
+
+	// Keep serving for the drain delay, without keep-alives, while readiness
+	// fails so that load balancers stop sending requests; a second signal
+	// skips the delay. After a handoff the new process serves on the same
+	// sockets, so readiness keeps succeeding.
+	if startErr == nil {
+		if !handedOff {
+			health.MarkDraining()
+		}
+		srv.Drain()
+		if cfg.Server.DrainDelay > 0 {
+			appLogger.Info().Dur("delay", cfg.Server.DrainDelay).Msg("Draining server...")
+			select {
+			case <-time.After(cfg.Server.DrainDelay):
+			case <-quit:
+			}
+		}
+	}
+
+	appLogger.Info().Msg("Shutting down server...")
+	shutdownErr := lc.Shutdown()
+
+	appLogger.Info().Msg("Server exited")
+	return errors.Join(startErr, shutdownErr)
+}
+
+// handOff passes the listeners of the API and admin servers to a new
+// process of the binary, reporting whether it was started
+func handOff(logger *zerolog.Logger, srv, adminServer *server.Server) bool {
+	servers := []*server.Server{srv}
+	if adminServer != nil {
+		servers = append(servers, adminServer)
+	}
+	process, err := server.Handoff(servers...)
+	if process == nil {
+		logger.Error().Err(err).Msg("Failed to hand off listeners, still serving")
+		return false
+	}
+	if err != nil {
+		logger.Warn().Err(err).Msg("Listeners handed off, but may delay the shutdown")
+	}
+
+	logger.Info().Int("pid", process.Pid).Msg("Handed off listeners to new process")
+	return true
+}
+
+// newRouter creates the router and registers the routes of the built-in
+// modules, so the routes command lists the routes served. Feature modules
+// are registered by runServe.
+func newRouter(
+	a *app,
+	apiMiddlewares []middlewares.Middleware,
+	health *healthcheck.Service,
+	eventBus *events.Bus,
+	hub *ws.Hub,
+	statusPage *statuspage.Page,
+	promRegistry *prommetrics.Registry,
+) *routers.Router {
+	cfg := a.cfg
+
+	router := routers.New(a.logger)
+	router.SetupRoutes(apiMiddlewares...)
+	router.Register(routers.NewWellKnownModule(cfg.WellKnown))
+	router.Register(routers.NewHealthModule(health))
+	if statusPage != nil {
+		router.Register(handlers.NewStatusPageHandler(statusPage, a.logger))
+	}
+	// Metrics are scraped from the admin server when there is one
+	if promRegistry != nil && cfg.Server.AdminListen == "" {
+		router.Register(promRegistry)
+	}
+
+	// Email template previews and test sends shorten the template iteration
+	// loop, but are unauthenticated and only served locally
+	if cfg.Core.Env == "local" {
+		router.RegisterAPI(handlers.NewEmailTemplateHandler(mailer.NewLogMailer(a.logger)))
+	}
+
+	// Profile running deployments, admins only unless served on a port of their own
+	if cfg.Server.EnablePprof && cfg.Server.PprofPort == "" && cfg.Server.AdminListen == "" {
+		tokens := auth.NewTokenManager(cfg.Auth.SecretKey, cfg.Observability.ServiceName)
+		router.Register(routers.NewDebugModule(middlewares.Authenticate(tokens), middlewares.RequireRole(auth.RoleAdmin)))
+	}
+
+	// Serve the legacy paths of renamed endpoints, registered in code with
+	// router.Alias or declared in the route aliases file
+	for _, alias := range cfg.RouteAliases.Aliases {
+		router.Alias(routers.AliasFromConfig(alias))
+	}
+
+	return router
+}
+
+// registerWebSocket serves the WebSocket connections of users (optional),
+// pushing them the updates of their user
+func registerWebSocket(
+	a *app, router *routers.Router, hub *ws.Hub, eventBus *events.Bus, authenticator *middlewares.Authenticator,
+) {
+	if !a.cfg.WebSocket.Enabled {
+		return
+	}
+	router.Register(ws.NewHandler(hub, authenticator, a.cfg.Server.CORSAllowedOrigins, nil))
+	events.SubscribeAsync(eventBus, "websocket", func(_ context.Context, e events.UserUpdated) error {
+		return hub.SendToUser(e.User.ID, ws.Message{Type: e.EventName(), Data: e.User.ToResponse()})
+	})
+}
+
+// newAdminHandler creates the handler of the internal admin server. Its
+// middleware chain is kept short, as it is not exposed: its requests are
+// neither authenticated, rate limited nor counted with the API requests.
+func newAdminHandler(a *app, health *healthcheck.Service, promRegistry *prommetrics.Registry) http.Handler {
+	cfg := a.cfg
+
+	router := routers.New(a.logger)
+	router.SetupAdminRoutes()
+	router.Register(routers.NewHealthModule(health), routers.NewConfigModule(cfg))
+	if promRegistry != nil {
+		router.Register(promRegistry)
+	}
+	if cfg.Server.EnablePprof {
+		router.Register(routers.NewDebugModule())
+	}
+
+	return middlewares.Chain(middlewares.RequestID(), middlewares.Recovery(a.logger))(router)
+}
+
+// newIncidentStore creates the store of the status page incident, shared in
+// Redis when configured. The returned function closes its connection.
+func newIncidentStore(cfg *config.Config, logger *zerolog.Logger) (statuspage.IncidentStore, func() error, error) {
+	if cfg.StatusPage.IncidentStore != "redis" {
+		return statuspage.NewMemoryIncidents(), func() error { return nil }, nil
+	}
+
+	client, err := redis.New(cfg.Redis, logger)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to initialize Redis: %w", err)
+	}
+	return statuspage.NewRedisIncidents(client, ""), client.Close, nil
+}
+
+// newRateLimiter builds the rate limit middleware from configuration
+func newRateLimiter(
+	cfg *config.Config,
+	store middlewares.RateLimitStore,
+	logger *zerolog.Logger,
+) middlewares.Middleware {
+	keyFunc := middlewares.KeyByIP()
+	switch cfg.RateLimit.KeyBy {
+	case "api_key":
+		keyFunc = middlewares.KeyByAPIKey("X-API-Key")
+	case "user_id":
+		keyFunc = middlewares.KeyByToken(auth.NewTokenManager(cfg.Auth.SecretKey, cfg.Observability.ServiceName))
+	}
+
+	return middlewares.RateLimit(middlewares.RateLimitOptions{
+		Store: store,
+		Rule: middlewares.RateLimitRule{
+			Algorithm: middlewares.RateLimitAlgorithm(cfg.RateLimit.Algorithm),
+			Limit:     cfg.RateLimit.Requests,
+			Window:    cfg.RateLimit.Window,
+		},
+		KeyFunc: keyFunc,
+		Logger:  logger,
+	})
+}
+
+// goUntilStop runs fn in a goroutine named name, whose context is canceled
+// when the workers stop
+func goUntilStop(lc *lifecycle.Coordinator, name string, fn func(ctx context.Context) error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := async.Go(ctx, name, fn)
+	lc.OnStop(lifecycle.PhaseWorkers, name, func(ctx context.Context) error {
+		cancel()
+		select {
+		case <-done:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+}
+
+// reloadFlags replaces the feature flags with those of the flags file on
+// every SIGHUP, until ctx is done. An invalid file is logged and the
+// current flags are kept.
+func reloadFlags(ctx context.Context, path string, featureFlags *flags.Service, logger *zerolog.Logger) error {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-hup:
+		}
+
+		declared, err := config.LoadFlags(path)
+		if err == nil {
+			err = featureFlags.Update(declared)
+		}
+		if err != nil {
+			logger.Error().Err(err).Msg("Failed to reload feature flags, keeping the current ones")
+			continue
+		}
+		logger.Info().Int("flags", len(declared.Flags)).Msg("Reloaded feature flags")
+	}
+}
+
+// reloadLists reloads the lists from their files and URLs on every SIGHUP,
+// until ctx is done. A list failing to load is logged and keeps its
+// current entries.
+func reloadLists(ctx context.Context, loader *lists.Loader, logger *zerolog.Logger) error {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-hup:
+		}
+
+		if err := loader.Load(ctx); err != nil {
+			logger.Error().Err(err).Msg("Failed to reload lists, keeping the current ones")
+		}
+	}
+}
+
+// restoreLogLevel restores the log level the application started with on
+// every SIGUSR1, until ctx is done, undoing changes made through the admin
+// endpoint. It has a signal of its own, so that reloading the
+// configuration files on SIGHUP keeps the level of an investigation.
+func restoreLogLevel(ctx context.Context, appLogger *zerolog.Logger) error {
+	configured := logger.Level()
+	usr1 := make(chan os.Signal, 1)
+	notifyRestoreLogLevel(usr1)
+	defer signal.Stop(usr1)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-usr1:
+		}
+
+		if previous := logger.Level(); previous != configured {
+			logger.SetLevel(configured)
+			appLogger.Info().Str("previous", previous.String()).Str("level", configured.String()).Msg("Restored log level")
+		}
+	}
+}
+
+// supervisedRateLimitStore fails fast while its connection is down, so the
+// rate limit middleware allows requests without waiting on timeouts
+type supervisedRateLimitStore struct {
+	middlewares.RateLimitStore
+	supervisor *failover.Supervisor
+}
+
+// Allow implements middlewares.RateLimitStore
+func (s supervisedRateLimitStore) Allow(
+	ctx context.Context,
+	key string,
+	rule middlewares.RateLimitRule,
+) (middlewares.RateLimitResult, error) {
+	var result middlewares.RateLimitResult
+	err := s.supervisor.Do(ctx, func(ctx context.Context) error {
+		var err error
+		result, err = s.RateLimitStore.Allow(ctx, key, rule)
+		return err
+	})
+	return result, err
+}
+
+// supervisedConcurrencyStore fails fast while its connection is down, so
+// throttles allow requests without waiting on timeouts
+type supervisedConcurrencyStore struct {
+	middlewares.ConcurrencyStore
+	supervisor *failover.Supervisor
+}
+
+// Acquire implements middlewares.ConcurrencyStore
+func (s supervisedConcurrencyStore) Acquire(
+	ctx context.Context,
+	key string,
+	limit int,
+	lease time.Duration,
+) (func(), bool, error) {
+	var (
+		release func()
+		ok      bool
+	)
+	err := s.supervisor.Do(ctx, func(ctx context.Context) error {
+		var err error
+		release, ok, err = s.ConcurrencyStore.Acquire(ctx, key, limit, lease)
+		return err
+	})
+	return release, ok, err
+}
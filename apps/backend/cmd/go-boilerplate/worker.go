@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/rs/zerolog"
+	"github.com/spf13/cobra"
+
+	"github.com/PrinceNarteh/go-boilerplate/internal/cache"
+	"github.com/PrinceNarteh/go-boilerplate/internal/config"
+	"github.com/PrinceNarteh/go-boilerplate/internal/database"
+	"github.com/PrinceNarteh/go-boilerplate/internal/libs/async"
+	"github.com/PrinceNarteh/go-boilerplate/internal/lifecycle"
+	"github.com/PrinceNarteh/go-boilerplate/internal/redis"
+	"github.com/PrinceNarteh/go-boilerplate/internal/scheduler"
+	"github.com/PrinceNarteh/go-boilerplate/internal/telemetry"
+)
+
+// newWorkerCommand creates the worker command, running the background
+// processing of serve in a process of its own
+func newWorkerCommand(a *app) *cobra.Command {
+	return &cobra.Command{
+		Use:   "worker",
+		Short: "Run scheduled tasks and background jobs without serving the API",
+		Long: `Run the scheduled tasks, the background jobs and the outbox dispatcher until
+SIGINT or SIGTERM, so they scale apart from the API. Serve the API with
+"serve --worker=false" alongside, or they run twice.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return runWorker(a)
+		},
+	}
+}
+
+// runWorker runs the background processing until SIGINT or SIGTERM
+func runWorker(a *app) error {
+	cfg, appLogger := a.cfg, a.logger
+
+	// Stop the components in order on shutdown, or when startup fails
+	lc := lifecycle.New(appLogger)
+	defer lc.Shutdown()
+	lc.OnStop(lifecycle.PhaseTelemetry, "goroutines", async.Shutdown)
+
+	// Merge repeated errors before they reach New Relic
+	lc.OnStop(lifecycle.PhaseTelemetry, "errors", lifecycle.Func(a.startErrorReporter()))
+
+	metrics := telemetry.NoopMetrics()
+	db, err := openDatabase(a, lc, nil, metrics, nil)
+	if err != nil {
+		return err
+	}
+
+	sched, err := newScheduler(a, lc, db)
+	if err != nil {
+		return err
+	}
+	sched.Start()
+	lc.OnStop(lifecycle.PhaseWorkers, "scheduler", lifecycle.Func(sched.Stop))
+
+	// Run background jobs, including the sagas and task runs dispatched to them,
+	// and publish the outbox, as in serve
+	if db != nil {
+		rdb := &sharedRedis{a: a, lc: lc}
+		if cfg.Jobs.Enabled {
+			jobQueue, workers, err := newJobs(a, db, rdb, metrics.Jobs)
+			if err != nil {
+				return err
+			}
+			if _, err := newSagas(a, db, jobQueue, workers, true); err != nil {
+				return err
+			}
+			newTaskRunner(a, db, newViews(a, db, metrics.Views), jobQueue, workers)
+			workers.Start(lc)
+		}
+		if cfg.Outbox.Enabled {
+			_, dispatcher, err := newOutbox(a, db, rdb)
+			if err != nil {
+				return err
+			}
+			dispatcher.Start()
+			lc.OnStop(lifecycle.PhaseWorkers, "outbox", lifecycle.Func(dispatcher.Stop))
+		}
+	}
+
+	appLogger.Info().Bool("scheduler", cfg.Scheduler.Enabled).Msg("Worker started")
+
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	<-quit
+
+	appLogger.Info().Msg("Shutting down worker...")
+	return lc.Shutdown()
+}
+
+// newScheduler creates the scheduler of recurring tasks, running the jobs
+// declared in the scheduler file when enabled, reloaded on SIGHUP, and the
+// retention job with a database. With API_SCHEDULER_LOCK, each activation
+// runs on one instance only. The scheduler must be started.
+func newScheduler(a *app, lc *lifecycle.Coordinator, db *database.Database) (*scheduler.Scheduler, error) {
+	cfg := a.cfg
+
+	sched := scheduler.New(a.loggerService.GetTracer(), a.logger)
+	if db != nil {
+		sched.Handle("retention", scheduler.Retention(db.Pool))
+	}
+	if cfg.Scheduler.Lock {
+		client, err := redis.New(cfg.Redis, a.logger)
+		if err != nil {
+			return nil, fmt.Errorf("failed to initialize Redis: %w", err)
+		}
+		lc.OnStop(lifecycle.PhaseClients, "redis.scheduler", lifecycle.Closer(client))
+		sched.UseLocker(cache.NewLocker(a.logger, client))
+	}
+	if cfg.Scheduler.Enabled {
+		if err := sched.Reconcile(cfg.Scheduler.Jobs); err != nil {
+			return nil, fmt.Errorf("failed to schedule jobs: %w", err)
+		}
+		goUntilStop(lc, "scheduler.reload", func(ctx context.Context) error {
+			return reloadJobs(ctx, cfg.Scheduler.File, sched, a.logger)
+		})
+	}
+	return sched, nil
+}
+
+// reloadJobs reconciles the scheduler with the scheduler file on every
+// SIGHUP, until ctx is done. An invalid file is logged and the current
+// jobs keep running.
+func reloadJobs(ctx context.Context, path string, sched *scheduler.Scheduler, logger *zerolog.Logger) error {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-hup:
+		}
+
+		declared, err := config.LoadJobs(path)
+		if err == nil {
+			err = sched.Reconcile(declared)
+		}
+		if err != nil {
+			logger.Error().Err(err).Msg("Failed to reload scheduled jobs, keeping the current ones")
+			continue
+		}
+		logger.Info().Int("jobs", len(declared)).Msg("Reloaded scheduled jobs")
+	}
+}
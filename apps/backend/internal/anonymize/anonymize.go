@@ -0,0 +1,172 @@
+// Package anonymize copies a database while masking personal data, to seed
+// staging with realistic data without exposing users.
+//
+// The personal columns of each table are declared next to its model with
+// Register, mapping each column to the Strategy masking it, or Skip for
+// tables whose rows are not copied at all. Tables without a declaration
+// are copied as is, so a migration adding a personal column must declare
+// it too.
+//
+// Strategies deriving values from the original, such as Email, do so with
+// a keyed hash: the same value is masked the same way in every table, so
+// joins and unique constraints still hold, while the original cannot be
+// recovered without the key.
+package anonymize
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Strategy masks the value of a column. key is the secret of the run, for
+// strategies deriving values from the original. NULL values are passed as
+// nil.
+type Strategy func(value any, key []byte) any
+
+// Columns maps the columns of a table to the strategy masking them
+type Columns map[string]Strategy
+
+// Table is the declaration of a table
+type Table struct {
+	Name    string
+	Columns Columns
+	// Skip leaves the table empty in the target
+	Skip bool
+}
+
+// tables holds the declared tables, by name
+var tables = struct {
+	mu     sync.Mutex
+	byName map[string]*Table
+}{byName: make(map[string]*Table)}
+
+// Register declares the columns of table masked by their strategy. It is
+// meant to be called from a package variable next to the model of the
+// table, and panics when the table is declared twice.
+func Register(table string, columns Columns) *Table {
+	return register(&Table{Name: table, Columns: columns})
+}
+
+// Skip declares a table whose rows are not copied, such as a queue whose
+// payloads hold personal data staging has no use for
+func Skip(table string) *Table {
+	return register(&Table{Name: table, Skip: true})
+}
+
+// register adds a table declaration
+func register(t *Table) *Table {
+	tables.mu.Lock()
+	defer tables.mu.Unlock()
+	if _, ok := tables.byName[t.Name]; ok {
+		panic(fmt.Sprintf("anonymize: table %s declared twice", t.Name))
+	}
+	tables.byName[t.Name] = t
+	return t
+}
+
+// Tables returns the declared tables, sorted by name
+func Tables() []*Table {
+	tables.mu.Lock()
+	defer tables.mu.Unlock()
+	list := make([]*Table, 0, len(tables.byName))
+	for _, t := range tables.byName {
+		list = append(list, t)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// lookup returns the declaration of a table
+func lookup(name string) (*Table, bool) {
+	tables.mu.Lock()
+	defer tables.mu.Unlock()
+	t, ok := tables.byName[name]
+	return t, ok
+}
+
+// Email replaces email addresses with distinct addresses of the reserved
+// example.com domain
+func Email(value any, key []byte) any {
+	return mapString(value, func(s string) string {
+		return "user-" + digest(key, strings.ToLower(s))[:16] + "@example.com"
+	})
+}
+
+var (
+	firstNames = []string{"Alex", "Sam", "Jordan", "Taylor", "Morgan", "Casey", "Riley", "Jamie", "Avery", "Quinn"}
+	lastNames  = []string{"Smith", "Mensah", "Garcia", "Okafor", "Chen", "Novak", "Silva", "Khan", "Müller", "Boateng"}
+)
+
+// Name replaces names with made-up names
+func Name(value any, key []byte) any {
+	return mapString(value, func(s string) string {
+		h := sum(key, s)
+		first := firstNames[binary.BigEndian.Uint32(h[:4])%uint32(len(firstNames))]
+		last := lastNames[binary.BigEndian.Uint32(h[4:8])%uint32(len(lastNames))]
+		return first + " " + last
+	})
+}
+
+// Token replaces secrets, such as token hashes, with distinct hexadecimal
+// strings of the same length
+func Token(value any, key []byte) any {
+	return mapString(value, func(s string) string {
+		d := digest(key, s)
+		for len(d) < len(s) {
+			d += digest(key, d)
+		}
+		return d[:len(s)]
+	})
+}
+
+// IP replaces IP addresses with addresses of the private 10.0.0.0/8 range
+func IP(value any, key []byte) any {
+	return mapString(value, func(s string) string {
+		if s == "" {
+			return s
+		}
+		d := sum(key, s)
+		return net.IPv4(10, d[0], d[1], d[2]).String()
+	})
+}
+
+// Null replaces values with NULL
+func Null(any, []byte) any {
+	return nil
+}
+
+// Fixed replaces values with v, such as an empty string for columns that
+// are not nullable
+func Fixed(v any) Strategy {
+	return func(any, []byte) any {
+		return v
+	}
+}
+
+// mapString applies fn to string values, leaving other values untouched
+func mapString(value any, fn func(s string) string) any {
+	s, ok := value.(string)
+	if !ok {
+		return value
+	}
+	return fn(s)
+}
+
+// sum returns the keyed hash of s
+func sum(key []byte, s string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(s))
+	return mac.Sum(nil)
+}
+
+// digest returns the keyed hash of s in hexadecimal
+func digest(key []byte, s string) string {
+	return hex.EncodeToString(sum(key, s))
+}
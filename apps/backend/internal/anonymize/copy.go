@@ -0,0 +1,284 @@
+package anonymize
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"slices"
+	"strings"
+
+	pgx "github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/rs/zerolog"
+)
+
+// migrationTables are the tables of the migrator, never copied
+var migrationTables = []string{"schema_version", "schema_version_dirty"}
+
+// Options configure Copy
+type Options struct {
+	// Key is the secret of the keyed hashes of the strategies. A random key
+	// is used when empty, so values are masked differently on each run.
+	Key    []byte
+	Logger *zerolog.Logger
+}
+
+// Copy replaces the rows of every table of the current schema of target
+// with the rows of source, masked as declared. Source is read from a
+// consistent snapshot, and target is written in a single transaction, so a
+// failed copy leaves it untouched. Both databases must be migrated to the
+// same version, and target user triggers do not fire during the copy.
+// Sequences are moved past the copied rows, and materialized views are
+// refreshed.
+func Copy(ctx context.Context, source, target *pgxpool.Pool, opts Options) error {
+	if len(opts.Key) == 0 {
+		opts.Key = make([]byte, 32)
+		// crypto/rand.Read never fails
+		_, _ = rand.Read(opts.Key)
+	}
+
+	src, err := source.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.RepeatableRead, AccessMode: pgx.ReadOnly})
+	if err != nil {
+		return fmt.Errorf("failed to begin source transaction: %w", err)
+	}
+	defer src.Rollback(ctx)
+
+	dst, err := target.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin target transaction: %w", err)
+	}
+	defer dst.Rollback(ctx)
+
+	if err := checkVersions(ctx, src, dst); err != nil {
+		return err
+	}
+	// The rows are copied as they are: triggers, such as those recording the
+	// history of users, and foreign key checks must not fire again
+	if _, err := dst.Exec(ctx, "SET LOCAL session_replication_role = replica"); err != nil {
+		return fmt.Errorf("failed to disable target triggers: %w", err)
+	}
+	names, err := listTables(ctx, src)
+	if err != nil {
+		return err
+	}
+	for _, t := range Tables() {
+		if !slices.Contains(names, t.Name) {
+			return fmt.Errorf("declared table %s does not exist", t.Name)
+		}
+	}
+
+	identifiers := make([]string, len(names))
+	for i, name := range names {
+		identifiers[i] = pgx.Identifier{name}.Sanitize()
+	}
+	if len(identifiers) > 0 {
+		if _, err := dst.Exec(ctx, "TRUNCATE "+strings.Join(identifiers, ", ")+" CASCADE"); err != nil {
+			return fmt.Errorf("failed to empty target tables: %w", err)
+		}
+	}
+
+	for _, name := range names {
+		t, declared := lookup(name)
+		log := opts.Logger.With().Str("table", name).Logger()
+		if declared && t.Skip {
+			log.Info().Msg("Skipped table")
+			continue
+		}
+		if !declared {
+			t = &Table{Name: name}
+		}
+
+		rows, err := copyTable(ctx, src, dst, t, opts.Key)
+		if err != nil {
+			return err
+		}
+		log.Info().Int64("rows", rows).Int("masked_columns", len(t.Columns)).Msg("Copied table")
+	}
+
+	if err := resetSequences(ctx, dst); err != nil {
+		return err
+	}
+	if err := refreshViews(ctx, dst); err != nil {
+		return err
+	}
+	if err := dst.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit target transaction: %w", err)
+	}
+	return nil
+}
+
+// checkVersions fails unless source and target are migrated to the same version
+func checkVersions(ctx context.Context, src, dst pgx.Tx) error {
+	var srcVersion, dstVersion int32
+	if err := src.QueryRow(ctx, "SELECT version FROM schema_version").Scan(&srcVersion); err != nil {
+		return fmt.Errorf("failed to get source schema version: %w", err)
+	}
+	if err := dst.QueryRow(ctx, "SELECT version FROM schema_version").Scan(&dstVersion); err != nil {
+		return fmt.Errorf("failed to get target schema version: %w", err)
+	}
+	if srcVersion != dstVersion {
+		return fmt.Errorf("target schema version %d does not match source version %d", dstVersion, srcVersion)
+	}
+	return nil
+}
+
+// listTables returns the tables of the current schema, referenced tables first
+func listTables(ctx context.Context, tx pgx.Tx) ([]string, error) {
+	query := `
+		SELECT table_name
+		FROM information_schema.tables
+		WHERE table_schema = current_schema() AND table_type = 'BASE TABLE'
+		ORDER BY table_name`
+
+	rows, err := tx.Query(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tables: %w", err)
+	}
+	names, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tables: %w", err)
+	}
+	names = slices.DeleteFunc(names, func(name string) bool {
+		return slices.Contains(migrationTables, name)
+	})
+
+	query = `
+		SELECT cl.relname, ref.relname
+		FROM pg_constraint c
+		JOIN pg_class cl ON cl.oid = c.conrelid
+		JOIN pg_class ref ON ref.oid = c.confrelid
+		WHERE c.contype = 'f' AND c.connamespace = (SELECT oid FROM pg_namespace WHERE nspname = current_schema())`
+
+	rows, err = tx.Query(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list foreign keys: %w", err)
+	}
+	references := make(map[string][]string)
+	var table, referenced string
+	_, err = pgx.ForEachRow(rows, []any{&table, &referenced}, func() error {
+		if table != referenced {
+			references[table] = append(references[table], referenced)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list foreign keys: %w", err)
+	}
+
+	// Depth-first, so each table comes after the tables it references
+	ordered := make([]string, 0, len(names))
+	visited := make(map[string]bool, len(names))
+	var visit func(name string)
+	visit = func(name string) {
+		if visited[name] {
+			return
+		}
+		visited[name] = true
+		for _, ref := range references[name] {
+			visit(ref)
+		}
+		if slices.Contains(names, name) {
+			ordered = append(ordered, name)
+		}
+	}
+	for _, name := range names {
+		visit(name)
+	}
+	return ordered, nil
+}
+
+// copyTable copies the rows of a table, masking the declared columns, and
+// returns the number of rows copied
+func copyTable(ctx context.Context, src, dst pgx.Tx, t *Table, key []byte) (int64, error) {
+	identifier := pgx.Identifier{t.Name}
+	rows, err := src.Query(ctx, "SELECT * FROM "+identifier.Sanitize())
+	if err != nil {
+		return 0, fmt.Errorf("failed to read %s: %w", t.Name, err)
+	}
+	defer rows.Close()
+
+	fields := rows.FieldDescriptions()
+	columns := make([]string, len(fields))
+	strategies := make([]Strategy, len(fields))
+	for i, field := range fields {
+		columns[i] = field.Name
+		strategies[i] = t.Columns[field.Name]
+	}
+	for column := range t.Columns {
+		if !slices.Contains(columns, column) {
+			return 0, fmt.Errorf("declared column %s.%s does not exist", t.Name, column)
+		}
+	}
+
+	n, err := dst.CopyFrom(ctx, identifier, columns, pgx.CopyFromFunc(func() ([]any, error) {
+		if !rows.Next() {
+			return nil, rows.Err()
+		}
+		values, err := rows.Values()
+		if err != nil {
+			return nil, err
+		}
+		for i, strategy := range strategies {
+			if strategy != nil {
+				values[i] = strategy(values[i], key)
+			}
+		}
+		return values, nil
+	}))
+	if err != nil {
+		return 0, fmt.Errorf("failed to copy %s: %w", t.Name, err)
+	}
+	return n, nil
+}
+
+// resetSequences moves the sequences of serial columns past the copied rows
+func resetSequences(ctx context.Context, tx pgx.Tx) error {
+	query := `
+		SELECT table_name, column_name
+		FROM information_schema.columns
+		WHERE table_schema = current_schema() AND column_default LIKE 'nextval(%'`
+
+	rows, err := tx.Query(ctx, query)
+	if err != nil {
+		return fmt.Errorf("failed to list sequences: %w", err)
+	}
+	type serial struct{ table, column string }
+	var serials []serial
+	var s serial
+	if _, err := pgx.ForEachRow(rows, []any{&s.table, &s.column}, func() error {
+		serials = append(serials, s)
+		return nil
+	}); err != nil {
+		return fmt.Errorf("failed to list sequences: %w", err)
+	}
+
+	for _, s := range serials {
+		query := fmt.Sprintf(
+			"SELECT setval(pg_get_serial_sequence($1, $2), COALESCE(MAX(%s), 0) + 1, false) FROM %s",
+			pgx.Identifier{s.column}.Sanitize(), pgx.Identifier{s.table}.Sanitize(),
+		)
+		if _, err := tx.Exec(ctx, query, pgx.Identifier{s.table}.Sanitize(), s.column); err != nil {
+			return fmt.Errorf("failed to reset sequence of %s.%s: %w", s.table, s.column, err)
+		}
+	}
+	return nil
+}
+
+// refreshViews refreshes the materialized views of the current schema
+func refreshViews(ctx context.Context, tx pgx.Tx) error {
+	rows, err := tx.Query(ctx, "SELECT matviewname FROM pg_matviews WHERE schemaname = current_schema()")
+	if err != nil {
+		return fmt.Errorf("failed to list materialized views: %w", err)
+	}
+	views, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return fmt.Errorf("failed to list materialized views: %w", err)
+	}
+
+	for _, view := range views {
+		if _, err := tx.Exec(ctx, "REFRESH MATERIALIZED VIEW "+pgx.Identifier{view}.Sanitize()); err != nil {
+			return fmt.Errorf("failed to refresh %s: %w", view, err)
+		}
+	}
+	return nil
+}
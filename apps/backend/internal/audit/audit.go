@@ -0,0 +1,78 @@
+// Package audit records the security events of user accounts, such as
+// password changes and revoked sessions, in a log users can read as the
+// security history of their account.
+//
+// Events are recorded with Log.Record, within the transaction of the change
+// they describe when there is one, so the log never misses a committed
+// change. They are never updated, and are removed with their user.
+package audit
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/PrinceNarteh/go-boilerplate/internal/auth"
+	"github.com/PrinceNarteh/go-boilerplate/internal/i18n"
+	"github.com/PrinceNarteh/go-boilerplate/internal/libs/id"
+	"github.com/PrinceNarteh/go-boilerplate/internal/middlewares"
+)
+
+// EventType identifies what happened to an account
+type EventType string
+
+// Event types
+const (
+	PasswordChanged EventType = "password.changed"
+	SessionRevoked  EventType = "session.revoked"
+	SessionsRevoked EventType = "sessions.revoked"
+)
+
+// EventTypeLabels translates event types
+var EventTypeLabels = i18n.RegisterEnum("security_event", PasswordChanged, SessionRevoked, SessionsRevoked)
+
+// Event is a security event of the account of a user
+type Event struct {
+	ID        int64     `json:"id"`
+	UserID    int       `json:"user_id"`
+	Type      EventType `json:"type"`
+	TypeLabel string    `json:"type_label,omitempty"`
+	// SessionID is the session the event was caused from, if any
+	SessionID id.ID  `json:"session_id"`
+	IP        string `json:"ip"`
+	UserAgent string `json:"user_agent"`
+	// Details describe the event, such as the number of sessions revoked
+	Details    map[string]string `json:"details,omitempty"`
+	OccurredAt time.Time         `json:"occurred_at"`
+}
+
+// Localize implements i18n.Localizable
+func (e *Event) Localize(ctx context.Context) {
+	e.TypeLabel = EventTypeLabels.Label(ctx, e.Type)
+}
+
+// Log stores the security events of users
+type Log interface {
+	// Record stores an event, in the transaction of ctx if any
+	Record(ctx context.Context, event *Event) error
+	// ListByUser returns a page of the events of a user, newest first.
+	// An empty cursor starts from the beginning. The returned cursor points
+	// after the last event of the page and is empty on the last page.
+	ListByUser(ctx context.Context, userID int, cursor string, limit int) ([]*Event, string, error)
+}
+
+// FromRequest returns an event of type t caused by the authenticated user
+// of r, from its session and client
+func FromRequest(r *http.Request, t EventType) *Event {
+	event := &Event{
+		Type:       t,
+		IP:         middlewares.ClientIP(r),
+		UserAgent:  r.UserAgent(),
+		OccurredAt: time.Now(),
+	}
+	if principal, ok := auth.FromContext(r.Context()); ok {
+		event.UserID = principal.UserID
+		event.SessionID, _ = id.Parse(principal.SessionID)
+	}
+	return event
+}
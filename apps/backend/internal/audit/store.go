@@ -0,0 +1,111 @@
+package audit
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/PrinceNarteh/go-boilerplate/internal/anonymize"
+	"github.com/PrinceNarteh/go-boilerplate/internal/database"
+	"github.com/PrinceNarteh/go-boilerplate/internal/libs"
+)
+
+// SecurityEventAnonymization masks from where users changed their account,
+// in staging snapshots
+var SecurityEventAnonymization = anonymize.Register("security_events", anonymize.Columns{
+	"ip":         anonymize.IP,
+	"user_agent": anonymize.Fixed(""),
+})
+
+// eventCursor is the keyset position of an event in the log ordering
+type eventCursor struct {
+	ID int64 `json:"i"`
+}
+
+// PostgresLog stores security events in the security_events table
+type PostgresLog struct {
+	db *pgxpool.Pool
+}
+
+// NewPostgresLog creates a new PostgreSQL security event log
+func NewPostgresLog(db *pgxpool.Pool) *PostgresLog {
+	return &PostgresLog{db: db}
+}
+
+// Record implements Log
+func (l *PostgresLog) Record(ctx context.Context, event *Event) error {
+	query := `
+		INSERT INTO security_events (user_id, type, session_id, ip, user_agent, details, occurred_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id`
+
+	details := event.Details
+	if details == nil {
+		details = map[string]string{}
+	}
+	err := database.Conn(ctx, l.db).QueryRow(ctx, query,
+		event.UserID,
+		event.Type,
+		event.SessionID,
+		event.IP,
+		event.UserAgent,
+		details,
+		event.OccurredAt,
+	).Scan(&event.ID)
+	if err != nil {
+		return fmt.Errorf("failed to record security event: %w", database.TranslateError(err))
+	}
+
+	return nil
+}
+
+// ListByUser implements Log
+func (l *PostgresLog) ListByUser(ctx context.Context, userID int, cursor string, limit int) ([]*Event, string, error) {
+	var after eventCursor
+	if cursor != "" {
+		if err := libs.DecodeCursor(cursor, &after); err != nil {
+			return nil, "", err
+		}
+	}
+
+	query := `
+		SELECT id, user_id, type, session_id, ip, user_agent, details, occurred_at
+		FROM security_events
+		WHERE user_id = $1 AND ($2::boolean OR id < $3)
+		ORDER BY id DESC
+		LIMIT $4`
+
+	// Fetch one extra row to know whether another page exists
+	rows, err := database.Conn(ctx, l.db).Query(ctx, query, userID, cursor == "", after.ID, limit+1)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to list security events: %w", database.TranslateError(err))
+	}
+	defer rows.Close()
+
+	var events []*Event
+	for rows.Next() {
+		var e Event
+		err := rows.Scan(&e.ID, &e.UserID, &e.Type, &e.SessionID, &e.IP, &e.UserAgent, &e.Details, &e.OccurredAt)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to scan security event: %w", err)
+		}
+		events = append(events, &e)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, "", fmt.Errorf("rows error: %w", err)
+	}
+
+	if len(events) <= limit {
+		return events, "", nil
+	}
+
+	events = events[:limit]
+	next, err := libs.EncodeCursor(eventCursor{ID: events[len(events)-1].ID})
+	if err != nil {
+		return nil, "", err
+	}
+
+	return events, next, nil
+}
@@ -0,0 +1,219 @@
+// Package auth provides the authenticated principal and JWT token handling
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/PrinceNarteh/go-boilerplate/internal/i18n"
+)
+
+//go:generate go run github.com/PrinceNarteh/go-boilerplate/cmd/enumgen -type=Role
+
+// Role grants a principal access to endpoints
+type Role string
+
+// RoleAdmin is the role granting access to administrative endpoints
+const RoleAdmin Role = "admin"
+
+// RoleLabels translates roles
+var RoleLabels = i18n.RegisterEnum("role", RoleValues()...)
+
+// ErrInvalidToken is returned when a token cannot be verified
+var ErrInvalidToken = errors.New("auth: invalid token")
+
+// Principal is the authenticated user making a request
+type Principal struct {
+	UserID int    `json:"user_id"`
+	Email  string `json:"email"`
+	Roles  []Role `json:"roles"`
+	// SessionID is the session the token belongs to, carried as its jti
+	// claim, empty for tokens issued without a session
+	SessionID string `json:"session_id,omitempty"`
+	// TenantID is the tenant the token was issued in, carried as its tenant
+	// claim, empty for tokens of the public schema
+	TenantID string `json:"tenant_id,omitempty"`
+	// IssuedAt is when the token was issued, from its iat claim
+	IssuedAt time.Time `json:"-"`
+	// RoleLabels are the labels of Roles, set when the client asks for labels
+	RoleLabels []string `json:"role_labels,omitempty"`
+}
+
+// Localize implements i18n.Localizable
+func (p *Principal) Localize(ctx context.Context) {
+	p.RoleLabels = RoleLabels.Labels(ctx, p.Roles)
+}
+
+// HasRole reports whether the principal has the given role
+func (p *Principal) HasRole(role Role) bool {
+	return slices.Contains(p.Roles, role)
+}
+
+// PrincipalLoader resolves the current state of the principal of a verified
+// token, such as its user and permissions, from the source of truth. It
+// returns an error wrapping ErrInvalidToken when the principal is no longer
+// valid, such as when its user was deleted.
+type PrincipalLoader interface {
+	LoadPrincipal(ctx context.Context, p *Principal) (*Principal, error)
+}
+
+// PrincipalChecker rejects the tokens revoked since they were issued, such
+// as those of signed out sessions. Unlike the principals of PrincipalLoader,
+// its results are never cached, so revocations apply on the next request.
+// It returns an error wrapping ErrInvalidToken when the token was revoked.
+type PrincipalChecker interface {
+	CheckPrincipal(ctx context.Context, p *Principal) error
+}
+
+// principalKey is the context key for the principal
+type principalKey struct{}
+
+// WithPrincipal returns a copy of ctx carrying the principal
+func WithPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// FromContext returns the principal stored in ctx
+func FromContext(ctx context.Context) (*Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(*Principal)
+	return p, ok
+}
+
+// UserIDFromContext returns the ID of the authenticated user as a string,
+// suitable for use as a rate limit or cache key
+func UserIDFromContext(ctx context.Context) (string, bool) {
+	p, ok := FromContext(ctx)
+	if !ok {
+		return "", false
+	}
+	return strconv.Itoa(p.UserID), true
+}
+
+// claims are the JWT claims carried by access tokens. Roles are plain
+// strings, so that tokens carrying roles unknown to this version, issued
+// during a rolling deployment, stay valid without them.
+type claims struct {
+	jwt.RegisteredClaims
+	Email  string   `json:"email"`
+	Roles  []string `json:"roles"`
+	Tenant string   `json:"tenant,omitempty"`
+}
+
+// TokenManager issues and verifies HS256-signed JWT access tokens
+type TokenManager struct {
+	secret []byte
+	issuer string
+}
+
+// NewTokenManager creates a new token manager using the given signing secret
+func NewTokenManager(secret, issuer string) *TokenManager {
+	return &TokenManager{
+		secret: []byte(secret),
+		issuer: issuer,
+	}
+}
+
+// Issue creates a signed access token for the principal valid for ttl
+func (m *TokenManager) Issue(p *Principal, ttl time.Duration) (string, error) {
+	now := time.Now()
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   strconv.Itoa(p.UserID),
+			Issuer:    m.issuer,
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+			ID:        p.SessionID,
+		},
+		Email:  p.Email,
+		Roles:  roleNames(p.Roles),
+		Tenant: p.TenantID,
+	})
+
+	signed, err := token.SignedString(m.secret)
+	if err != nil {
+		return "", fmt.Errorf("failed to sign token: %w", err)
+	}
+	return signed, nil
+}
+
+// Verify validates a signed access token and returns its principal
+func (m *TokenManager) Verify(tokenString string) (*Principal, error) {
+	var c claims
+	_, err := jwt.ParseWithClaims(tokenString, &c, func(*jwt.Token) (any, error) {
+		return m.secret, nil
+	},
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+		jwt.WithIssuer(m.issuer),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidToken, err)
+	}
+
+	userID, err := strconv.Atoi(c.Subject)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid subject", ErrInvalidToken)
+	}
+
+	var issuedAt time.Time
+	if c.IssuedAt != nil {
+		issuedAt = c.IssuedAt.Time
+	}
+
+	return &Principal{
+		UserID:    userID,
+		Email:     c.Email,
+		Roles:     knownRoles(c.Roles),
+		SessionID: c.ID,
+		TenantID:  c.Tenant,
+		IssuedAt:  issuedAt,
+	}, nil
+}
+
+// roleNames returns the names of roles
+func roleNames(roles []Role) []string {
+	names := make([]string, len(roles))
+	for i, role := range roles {
+		names[i] = string(role)
+	}
+	return names
+}
+
+// knownRoles returns the roles named by names, leaving out the unknown ones
+func knownRoles(names []string) []Role {
+	roles := make([]Role, 0, len(names))
+	for _, name := range names {
+		if role := Role(name); role.IsValid() {
+			roles = append(roles, role)
+		}
+	}
+	return roles
+}
+
+// NewOpaqueToken generates a random single-use token, such as an email
+// confirmation token, and returns it together with its hash. Only the hash
+// should be stored so that a database leak does not expose usable tokens.
+func NewOpaqueToken() (token, hash string, err error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", fmt.Errorf("failed to generate token: %w", err)
+	}
+	token = base64.RawURLEncoding.EncodeToString(b)
+	return token, HashToken(token), nil
+}
+
+// HashToken returns the hash under which an opaque token is stored
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,74 @@
+// Code generated by enumgen -type=Role; DO NOT EDIT.
+
+package auth
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"slices"
+)
+
+// roleValues are the values of Role, in declaration order
+var roleValues = []Role{RoleAdmin}
+
+// RoleValues returns the values of Role, in declaration order
+func RoleValues() []Role {
+	return slices.Clone(roleValues)
+}
+
+// IsValid reports whether v is a declared value of Role
+func (v Role) IsValid() bool {
+	switch v {
+	case RoleAdmin:
+		return true
+	}
+	return false
+}
+
+// ParseRole returns the Role of s, or an error when s is not a declared value
+func ParseRole(s string) (Role, error) {
+	v := Role(s)
+	if !v.IsValid() {
+		return "", fmt.Errorf("invalid Role %q", s)
+	}
+	return v, nil
+}
+
+// MarshalText implements encoding.TextMarshaler
+func (v Role) MarshalText() ([]byte, error) {
+	return []byte(v), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler, rejecting undeclared values
+func (v *Role) UnmarshalText(text []byte) error {
+	parsed, err := ParseRole(string(text))
+	if err != nil {
+		return err
+	}
+	*v = parsed
+	return nil
+}
+
+// Value implements driver.Valuer, rejecting undeclared values
+func (v Role) Value() (driver.Value, error) {
+	if !v.IsValid() {
+		return nil, fmt.Errorf("invalid Role %q", string(v))
+	}
+	return string(v), nil
+}
+
+// Scan implements sql.Scanner, rejecting undeclared values. NULL scans into
+// the zero value, which IsValid reports as undeclared.
+func (v *Role) Scan(src any) error {
+	switch src := src.(type) {
+	case nil:
+		*v = ""
+		return nil
+	case string:
+		return v.UnmarshalText([]byte(src))
+	case []byte:
+		return v.UnmarshalText(src)
+	default:
+		return fmt.Errorf("cannot scan %T into Role", src)
+	}
+}
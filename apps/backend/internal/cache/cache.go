@@ -0,0 +1,165 @@
+// Package cache provides cache-aside helpers over a pluggable byte store.
+//
+// A Store keeps raw values, in Redis or in process memory. Cache wraps a
+// store with a key namespace, JSON serialization of a value type and a
+// default TTL, and GetOrLoad loads missing values once even when many
+// callers miss the same key at the same time. Repositories are cached with
+// decorators that fill the cache on reads and invalidate it on writes.
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+
+	"github.com/PrinceNarteh/go-boilerplate/internal/telemetry"
+)
+
+// defaultTTL is the time to live of entries when Options.TTL is not set
+const defaultTTL = 5 * time.Minute
+
+// Store keeps raw cache values by key
+type Store interface {
+	// Get returns the value of key, reporting false when it is missing or expired
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+	// Set stores the value of key for ttl
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	// Delete removes keys, ignoring missing ones
+	Delete(ctx context.Context, keys ...string) error
+}
+
+// Options configures a Cache
+type Options struct {
+	// Name namespaces the keys of the cache and labels its metrics, e.g. "users"
+	Name string
+	// TTL is the default time to live of entries, 5 minutes when zero
+	TTL time.Duration
+	// Metrics records hits and misses when set
+	Metrics *telemetry.CacheMetrics
+}
+
+// Cache stores values of type T as JSON in a Store
+type Cache[T any] struct {
+	store   Store
+	name    string
+	ttl     time.Duration
+	metrics *telemetry.CacheMetrics
+	loads   singleflight.Group
+}
+
+// New creates a cache of values of type T
+func New[T any](store Store, opts Options) *Cache[T] {
+	if opts.TTL <= 0 {
+		opts.TTL = defaultTTL
+	}
+	return &Cache[T]{
+		store:   store,
+		name:    opts.Name,
+		ttl:     opts.TTL,
+		metrics: opts.Metrics,
+	}
+}
+
+// Get returns the cached value of key, reporting false on a miss
+func (c *Cache[T]) Get(ctx context.Context, key string) (*T, bool, error) {
+	data, ok, err := c.store.Get(ctx, c.key(key))
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to get %s from cache: %w", c.key(key), err)
+	}
+	if !ok {
+		c.recordMiss(ctx)
+		return nil, false, nil
+	}
+
+	var value T
+	if err := json.Unmarshal(data, &value); err != nil {
+		// Entries written by an older version of T are treated as missing
+		c.recordMiss(ctx)
+		return nil, false, nil
+	}
+
+	c.recordHit(ctx)
+	return &value, true, nil
+}
+
+// Set caches value under key for the default TTL
+func (c *Cache[T]) Set(ctx context.Context, key string, value *T) error {
+	return c.SetWithTTL(ctx, key, value, c.ttl)
+}
+
+// SetWithTTL caches value under key for ttl
+func (c *Cache[T]) SetWithTTL(ctx context.Context, key string, value *T, ttl time.Duration) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("failed to encode cache value: %w", err)
+	}
+	if err := c.store.Set(ctx, c.key(key), data, ttl); err != nil {
+		return fmt.Errorf("failed to set %s in cache: %w", c.key(key), err)
+	}
+	return nil
+}
+
+// Delete removes keys from the cache
+func (c *Cache[T]) Delete(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+
+	namespaced := make([]string, len(keys))
+	for i, key := range keys {
+		namespaced[i] = c.key(key)
+	}
+	if err := c.store.Delete(ctx, namespaced...); err != nil {
+		return fmt.Errorf("failed to delete from cache: %w", err)
+	}
+	return nil
+}
+
+// GetOrLoad returns the cached value of key, calling load and caching its
+// result on a miss. Concurrent misses of the same key share a single load.
+// Cache failures are not returned: the value is loaded and returned as if
+// there were no cache, so an unavailable store never breaks reads. Errors
+// from load are returned as is and nothing is cached.
+func (c *Cache[T]) GetOrLoad(ctx context.Context, key string, load func(ctx context.Context) (*T, error)) (*T, error) {
+	if value, ok, err := c.Get(ctx, key); err == nil && ok {
+		return value, nil
+	}
+
+	result, err, _ := c.loads.Do(key, func() (any, error) {
+		value, err := load(ctx)
+		if err != nil {
+			return nil, err
+		}
+		_ = c.Set(ctx, key, value)
+		return value, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result.(*T), nil
+}
+
+// key returns the namespaced store key of key
+func (c *Cache[T]) key(key string) string {
+	if c.name == "" {
+		return key
+	}
+	return c.name + ":" + key
+}
+
+// recordHit records a hit when metrics are enabled
+func (c *Cache[T]) recordHit(ctx context.Context) {
+	if c.metrics != nil {
+		c.metrics.Hit(ctx, c.name)
+	}
+}
+
+// recordMiss records a miss when metrics are enabled
+func (c *Cache[T]) recordMiss(ctx context.Context) {
+	if c.metrics != nil {
+		c.metrics.Miss(ctx, c.name)
+	}
+}
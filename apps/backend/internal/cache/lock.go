@@ -0,0 +1,285 @@
+package cache
+
+import (
+	"context"
+	cryptorand "crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/rs/zerolog"
+)
+
+const (
+	lockKeyPrefix  = "lock:"                // Namespace of lock keys
+	lockRetryDelay = 100 * time.Millisecond // Base delay between acquisition attempts of Lock
+	lockMinTTL     = 100 * time.Millisecond // Shortest accepted lock TTL
+)
+
+var (
+	// ErrLocked is returned by TryLock when another owner holds the lock
+	ErrLocked = errors.New("cache: lock held by another owner")
+	// ErrLockLost is returned by Release when the lock expired or was taken over before it was released
+	ErrLockLost = errors.New("cache: lock lost")
+)
+
+// renewScript extends the TTL of a lock if it is still held with the token
+var renewScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0`)
+
+// releaseScript deletes a lock if it is still held with the token
+var releaseScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0`)
+
+// Locker acquires distributed locks in Redis, so that work such as a
+// scheduled job runs on a single instance of the application at a time.
+//
+// With one client, a lock is a key set if absent with a random token and a
+// TTL. With several independent Redis servers, locks follow the Redlock
+// algorithm: a lock is held when a majority of the servers granted it
+// within its TTL. Held locks are renewed in the background until released,
+// and a lock that cannot be renewed is reported as lost through the
+// context of the Lock, so the work it protects can stop.
+type Locker struct {
+	clients []redis.Cmdable
+	logger  *zerolog.Logger
+}
+
+// NewLocker creates a locker over one Redis client, or over several
+// independent servers for Redlock
+func NewLocker(logger *zerolog.Logger, clients ...redis.Cmdable) *Locker {
+	return &Locker{clients: clients, logger: logger}
+}
+
+// Lock is a held distributed lock
+type Lock struct {
+	locker *Locker
+	key    string
+	token  string
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	mu       sync.Mutex
+	released bool
+}
+
+// Lock acquires the lock on key for ttl, waiting until it is free or ctx
+// is done. The lock is renewed every third of ttl until Release is called,
+// so ttl only bounds how long the lock outlives a crashed owner.
+func (l *Locker) Lock(ctx context.Context, key string, ttl time.Duration) (*Lock, error) {
+	for {
+		lock, err := l.TryLock(ctx, key, ttl)
+		if !errors.Is(err, ErrLocked) {
+			return lock, err
+		}
+
+		// Jitter keeps waiting instances from retrying in lockstep
+		delay := lockRetryDelay + rand.N(lockRetryDelay)
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("failed to acquire lock %s: %w", key, ctx.Err())
+		case <-time.After(delay):
+		}
+	}
+}
+
+// TryLock acquires the lock on key for ttl like Lock, but returns ErrLocked
+// immediately when another owner holds it
+func (l *Locker) TryLock(ctx context.Context, key string, ttl time.Duration) (*Lock, error) {
+	if len(l.clients) == 0 {
+		return nil, errors.New("cache: locker has no redis client")
+	}
+	if ttl < lockMinTTL {
+		return nil, fmt.Errorf("cache: lock ttl %s is shorter than %s", ttl, lockMinTTL)
+	}
+
+	token, err := newLockToken()
+	if err != nil {
+		return nil, err
+	}
+
+	key = lockKeyPrefix + key
+	start := time.Now()
+	granted, err := l.each(ctx, func(ctx context.Context, client redis.Cmdable) (bool, error) {
+		return client.SetNX(ctx, key, token, ttl).Result()
+	})
+	if !l.held(granted, time.Since(start), ttl) {
+		if granted > 0 {
+			// Undo partial acquisitions so the lock frees before its TTL
+			_ = l.release(context.WithoutCancel(ctx), key, token)
+		}
+		if err != nil && granted == 0 {
+			return nil, fmt.Errorf("failed to acquire lock %s: %w", key, err)
+		}
+		return nil, ErrLocked
+	}
+
+	lockCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	lock := &Lock{
+		locker: l,
+		key:    key,
+		token:  token,
+		ctx:    lockCtx,
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+	go lock.renew(ttl)
+	return lock, nil
+}
+
+// Context returns a context canceled when the lock is released or lost.
+// Work protected by the lock should use it to stop once another owner may
+// have taken over.
+func (l *Lock) Context() context.Context {
+	return l.ctx
+}
+
+// Release stops renewing the lock and frees it. It returns ErrLockLost
+// when the lock was lost before, and is a no-op when called again.
+func (l *Lock) Release(ctx context.Context) error {
+	stopped, lost := l.stop()
+	if !stopped {
+		return nil
+	}
+
+	if err := l.locker.release(ctx, l.key, l.token); err != nil {
+		return fmt.Errorf("failed to release lock %s: %w", l.key, err)
+	}
+	if lost {
+		return ErrLockLost
+	}
+	return nil
+}
+
+// Expire stops renewing the lock without freeing it, so it expires at the
+// end of its TTL. It suits work done once per period, such as a scheduled
+// run: an instance starting the same period a little late still finds the
+// lock held.
+func (l *Lock) Expire() {
+	l.stop()
+}
+
+// stop stops renewing the lock, reporting whether it was still renewed and
+// whether it was lost
+func (l *Lock) stop() (stopped, lost bool) {
+	l.mu.Lock()
+	if l.released {
+		l.mu.Unlock()
+		return false, false
+	}
+	l.released = true
+	l.mu.Unlock()
+
+	lost = l.ctx.Err() != nil
+	l.cancel()
+	<-l.done
+	return true, lost
+}
+
+// renew extends the lock every third of its TTL until it is released,
+// canceling its context when a renewal fails
+func (l *Lock) renew(ttl time.Duration) {
+	defer close(l.done)
+
+	ticker := time.NewTicker(ttl / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-l.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		ctx, cancel := context.WithTimeout(l.ctx, ttl/3)
+		start := time.Now()
+		renewed, err := l.locker.each(ctx, func(ctx context.Context, client redis.Cmdable) (bool, error) {
+			n, err := renewScript.Run(ctx, client, []string{l.key}, l.token, ttl.Milliseconds()).Int()
+			return n == 1, err
+		})
+		cancel()
+		if l.ctx.Err() != nil {
+			return
+		}
+		if !l.locker.held(renewed, time.Since(start), ttl) {
+			l.locker.logger.Warn().Err(err).Str("lock", l.key).Msg("Lost distributed lock")
+			l.cancel()
+			return
+		}
+	}
+}
+
+// release deletes the lock from every server still holding it with token
+func (l *Locker) release(ctx context.Context, key, token string) error {
+	_, err := l.each(ctx, func(ctx context.Context, client redis.Cmdable) (bool, error) {
+		return true, releaseScript.Run(ctx, client, []string{key}, token).Err()
+	})
+	return err
+}
+
+// lockOp is an operation on the lock of a single server, reporting whether it succeeded
+type lockOp func(ctx context.Context, client redis.Cmdable) (bool, error)
+
+// each runs fn on every server and returns how many reported success,
+// along with the errors of the servers that failed
+func (l *Locker) each(ctx context.Context, fn lockOp) (int, error) {
+	if len(l.clients) == 1 {
+		ok, err := fn(ctx, l.clients[0])
+		if ok && err == nil {
+			return 1, nil
+		}
+		return 0, err
+	}
+
+	var (
+		mu      sync.Mutex
+		wg      sync.WaitGroup
+		granted int
+		errList []error
+	)
+	for _, client := range l.clients {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ok, err := fn(ctx, client)
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case err != nil:
+				errList = append(errList, err)
+			case ok:
+				granted++
+			}
+		}()
+	}
+	wg.Wait()
+	return granted, errors.Join(errList...)
+}
+
+// held reports whether a lock granted by granted servers after elapsed is
+// still valid: granted by a majority, with time left before it expires
+// once the clock drift between servers is accounted for
+func (l *Locker) held(granted int, elapsed, ttl time.Duration) bool {
+	drift := ttl/100 + 2*time.Millisecond
+	return granted >= len(l.clients)/2+1 && elapsed+drift < ttl
+}
+
+// newLockToken returns a random token identifying the owner of a lock
+func newLockToken() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := cryptorand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate lock token: %w", err)
+	}
+	return hex.EncodeToString(buf), nil
+}
@@ -0,0 +1,89 @@
+package cache
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// memoryEntry is a value held by a Memory store
+type memoryEntry struct {
+	value     []byte
+	expiresAt time.Time
+}
+
+// Memory is an in-process Store, for single-instance deployments and
+// development. Each instance of the application has its own copy, so writes
+// on one instance do not invalidate the entries of the others.
+type Memory struct {
+	maxEntries int
+
+	mu      sync.Mutex
+	entries map[string]memoryEntry
+}
+
+// NewMemory creates an in-memory store holding at most maxEntries entries.
+// When it is full, expired entries are removed first, then arbitrary ones.
+func NewMemory(maxEntries int) *Memory {
+	return &Memory{
+		maxEntries: maxEntries,
+		entries:    make(map[string]memoryEntry),
+	}
+}
+
+// Get implements Store
+func (m *Memory) Get(_ context.Context, key string) ([]byte, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entry, ok := m.entries[key]
+	if !ok {
+		return nil, false, nil
+	}
+	if time.Now().After(entry.expiresAt) {
+		delete(m.entries, key)
+		return nil, false, nil
+	}
+	return entry.value, true, nil
+}
+
+// Set implements Store
+func (m *Memory) Set(_ context.Context, key string, value []byte, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, exists := m.entries[key]; !exists && len(m.entries) >= m.maxEntries {
+		m.evict()
+	}
+	m.entries[key] = memoryEntry{value: value, expiresAt: time.Now().Add(ttl)}
+	return nil
+}
+
+// Delete implements Store
+func (m *Memory) Delete(_ context.Context, keys ...string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, key := range keys {
+		delete(m.entries, key)
+	}
+	return nil
+}
+
+// evict makes room for one entry, removing every expired entry or, when
+// none has expired, an arbitrary one
+func (m *Memory) evict() {
+	now := time.Now()
+	for key, entry := range m.entries {
+		if now.After(entry.expiresAt) {
+			delete(m.entries, key)
+		}
+	}
+	if len(m.entries) < m.maxEntries {
+		return
+	}
+	for key := range m.entries {
+		delete(m.entries, key)
+		return
+	}
+}
@@ -0,0 +1,41 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Redis is a Store backed by Redis, shared by every instance of the application
+type Redis struct {
+	client redis.Cmdable
+}
+
+// NewRedis creates a Redis store
+func NewRedis(client redis.Cmdable) *Redis {
+	return &Redis{client: client}
+}
+
+// Get implements Store
+func (r *Redis) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	value, err := r.client.Get(ctx, key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return value, true, nil
+}
+
+// Set implements Store
+func (r *Redis) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	return r.client.Set(ctx, key, value, ttl).Err()
+}
+
+// Delete implements Store
+func (r *Redis) Delete(ctx context.Context, keys ...string) error {
+	return r.client.Del(ctx, keys...).Err()
+}
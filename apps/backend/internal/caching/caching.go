@@ -0,0 +1,176 @@
+// Package caching wires the caching of a resource from a single
+// declaration.
+//
+// A Policy declares how a resource is cached: for how long, what its
+// responses vary by, how requests map to the key of the resource, and the
+// domain events invalidating it. Declare turns a policy into a Resource,
+// which provides the value cache for repository decorators, such as
+// repositories.NewCachedUserRepository, and the middleware caching
+// responses, and subscribes to the invalidation events on the event bus.
+//
+// Responses are cached per resource key under a version that invalidation
+// replaces, so every cached variant of a resource, such as its responses in
+// each language, is invalidated at once without listing them.
+package caching
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/PrinceNarteh/go-boilerplate/internal/auth"
+	"github.com/PrinceNarteh/go-boilerplate/internal/cache"
+	"github.com/PrinceNarteh/go-boilerplate/internal/events"
+	"github.com/PrinceNarteh/go-boilerplate/internal/telemetry"
+)
+
+// defaultTTL is the time to live of entries when Policy.TTL is not set
+const defaultTTL = 5 * time.Minute
+
+// Policy declares the caching of a resource
+type Policy struct {
+	// Name namespaces the cache keys of the resource and labels its metrics, e.g. "users"
+	Name string
+	// TTL is the time to live of cached values and responses, 5 minutes when zero
+	TTL time.Duration
+	// Key returns the key of the resource a request reads, such as
+	// Param("id"). Responses of requests without a key are not cached.
+	Key func(r *http.Request) string
+	// Vary lists what responses vary by besides the URL, such as
+	// Header("Accept-Language")
+	Vary []Vary
+	// Invalidations list the events invalidating the resource, see On
+	Invalidations []Invalidation
+}
+
+// Vary is a request attribute cached responses vary by
+type Vary struct {
+	// header is the request header named in the Vary response header, if any
+	header string
+	value  func(r *http.Request) string
+}
+
+// Header varies responses by the value of a request header
+func Header(name string) Vary {
+	return Vary{
+		header: name,
+		value:  func(r *http.Request) string { return r.Header.Get(name) },
+	}
+}
+
+// Principal varies responses by the authenticated user, for responses
+// depending on who asks. The middleware must then run after Authenticate.
+var Principal = Vary{
+	value: func(r *http.Request) string {
+		principal, ok := auth.FromContext(r.Context())
+		if !ok {
+			return ""
+		}
+		return strconv.Itoa(principal.UserID)
+	},
+}
+
+// Param returns a Policy.Key reading the key from a path parameter
+func Param(name string) func(r *http.Request) string {
+	return func(r *http.Request) string {
+		return r.PathValue(name)
+	}
+}
+
+// Invalidation subscribes the invalidation of a resource to an event type
+type Invalidation struct {
+	subscribe func(bus *events.Bus, resource string, invalidate func(ctx context.Context, keys ...string) error)
+}
+
+// On declares that events of type E invalidate the resource keys returned by keys
+func On[E events.Event](keys func(event E) []string) Invalidation {
+	return Invalidation{
+		subscribe: func(bus *events.Bus, resource string, invalidate func(ctx context.Context, keys ...string) error) {
+			// Synchronous, so a client reading its own write never gets the cached value
+			events.Subscribe(bus, "cache:"+resource, func(ctx context.Context, event E) error {
+				return invalidate(ctx, keys(event)...)
+			})
+		},
+	}
+}
+
+// Resource is the cache of a resource, as declared by its policy
+type Resource[T any] struct {
+	policy    Policy
+	values    *cache.Cache[T]
+	responses *cache.Cache[cachedResponse]
+	versions  *cache.Cache[string]
+}
+
+// Declare creates the cache of a resource of type T in store from its
+// policy, and subscribes its invalidations on bus, which may be nil when
+// the policy has none. metrics records hits and misses and may be nil.
+func Declare[T any](store cache.Store, policy Policy, bus *events.Bus, metrics *telemetry.CacheMetrics) *Resource[T] {
+	if policy.TTL <= 0 {
+		policy.TTL = defaultTTL
+	}
+
+	r := &Resource[T]{
+		policy: policy,
+		values: cache.New[T](store, cache.Options{Name: policy.Name, TTL: policy.TTL, Metrics: metrics}),
+		responses: cache.New[cachedResponse](store, cache.Options{
+			Name:    policy.Name + ".responses",
+			TTL:     policy.TTL,
+			Metrics: metrics,
+		}),
+		// Versions outlive the responses cached under them, so an expired
+		// version never brings back responses cached before an invalidation
+		versions: cache.New[string](store, cache.Options{Name: policy.Name + ".versions", TTL: 2 * policy.TTL}),
+	}
+	for _, invalidation := range policy.Invalidations {
+		invalidation.subscribe(bus, policy.Name, r.Invalidate)
+	}
+	return r
+}
+
+// Values returns the cache of the values of the resource, for repository
+// decorators
+func (r *Resource[T]) Values() *cache.Cache[T] {
+	return r.values
+}
+
+// Invalidate removes the cached values of keys and the responses cached for
+// them. Entries are invalidated even when the context is canceled.
+func (r *Resource[T]) Invalidate(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+
+	ctx = context.WithoutCancel(ctx)
+	if err := r.values.Delete(ctx, keys...); err != nil {
+		return fmt.Errorf("failed to invalidate %s: %w", r.policy.Name, err)
+	}
+	for _, key := range keys {
+		version := newVersion()
+		if err := r.versions.Set(ctx, key, &version); err != nil {
+			return fmt.Errorf("failed to invalidate %s responses: %w", r.policy.Name, err)
+		}
+	}
+	return nil
+}
+
+// version returns the current version of the responses of key
+func (r *Resource[T]) version(ctx context.Context, key string) (string, error) {
+	version, ok, err := r.versions.Get(ctx, key)
+	if err != nil || !ok {
+		return "0", err
+	}
+	return *version, nil
+}
+
+// newVersion returns a random response version
+func newVersion() string {
+	var buf [8]byte
+	// crypto/rand.Read never fails
+	_, _ = rand.Read(buf[:])
+	return hex.EncodeToString(buf[:])
+}
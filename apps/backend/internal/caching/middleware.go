@@ -0,0 +1,137 @@
+package caching
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+
+	"github.com/PrinceNarteh/go-boilerplate/internal/database"
+	"github.com/PrinceNarteh/go-boilerplate/internal/middlewares"
+)
+
+// maxCachedBody is the size of the largest response body cached
+const maxCachedBody = 1 << 20
+
+// cachedHeaders are the response headers kept with cached responses
+var cachedHeaders = []string{"Content-Type", "Content-Language"}
+
+// cachedResponse is a successful response kept in the cache
+type cachedResponse struct {
+	Header map[string]string `json:"header"`
+	Body   []byte            `json:"body"`
+}
+
+// Middleware creates a middleware caching the 200 OK responses of GET
+// requests with a resource key, until they expire or the resource is
+// invalidated. Responses carry an X-Cache header set to HIT or MISS. It must
+// run after the middleware authorizing the request, as cached responses are
+// served without calling the handler. Responses of tenants are cached
+// apart, see database.WithSchema.
+func (r *Resource[T]) Middleware() middlewares.Middleware {
+	var varyHeaders []string
+	for _, vary := range r.policy.Vary {
+		if vary.header != "" {
+			varyHeaders = append(varyHeaders, vary.header)
+		}
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			key := r.policy.Key(req)
+			if req.Method != http.MethodGet || key == "" {
+				next.ServeHTTP(w, req)
+				return
+			}
+
+			// Without the version, the cache is unavailable and skipped
+			ctx := req.Context()
+			version, err := r.version(ctx, key)
+			if err != nil {
+				next.ServeHTTP(w, req)
+				return
+			}
+
+			for _, header := range varyHeaders {
+				w.Header().Add("Vary", header)
+			}
+			entryKey := key + ":" + version + ":" + r.variant(req)
+			if cached, ok, err := r.responses.Get(ctx, entryKey); err == nil && ok {
+				for name, value := range cached.Header {
+					w.Header().Set(name, value)
+				}
+				w.Header().Set("X-Cache", "HIT")
+				w.WriteHeader(http.StatusOK)
+				w.Write(cached.Body)
+				return
+			}
+
+			w.Header().Set("X-Cache", "MISS")
+			rec := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+			next.ServeHTTP(rec, req)
+			if rec.status != http.StatusOK || rec.overflow {
+				return
+			}
+
+			cached := cachedResponse{Header: make(map[string]string), Body: rec.body.Bytes()}
+			for _, name := range cachedHeaders {
+				if value := w.Header().Get(name); value != "" {
+					cached.Header[name] = value
+				}
+			}
+			// Failures are ignored: the response is served uncached
+			_ = r.responses.Set(ctx, entryKey, &cached)
+		})
+	}
+}
+
+// variant returns a digest of what the response to req varies by: the
+// tenant, the URL and the attributes of the policy
+func (r *Resource[T]) variant(req *http.Request) string {
+	schema, _ := database.SchemaFromContext(req.Context())
+	parts := []string{schema, req.URL.Path, req.URL.RawQuery}
+	for _, vary := range r.policy.Vary {
+		parts = append(parts, vary.value(req))
+	}
+
+	sum := sha256.Sum256([]byte(strings.Join(parts, "\x00")))
+	return hex.EncodeToString(sum[:16])
+}
+
+// responseRecorder passes a response through while keeping a copy of it
+type responseRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	body        bytes.Buffer
+	overflow    bool
+}
+
+// WriteHeader records the status code
+func (rec *responseRecorder) WriteHeader(code int) {
+	if !rec.wroteHeader {
+		rec.status = code
+		rec.wroteHeader = true
+	}
+	rec.ResponseWriter.WriteHeader(code)
+}
+
+// Write keeps a copy of the body, up to the largest body cached
+func (rec *responseRecorder) Write(b []byte) (int, error) {
+	rec.wroteHeader = true
+	if !rec.overflow {
+		if rec.body.Len()+len(b) > maxCachedBody {
+			rec.overflow = true
+			rec.body.Reset()
+		} else {
+			rec.body.Write(b)
+		}
+	}
+	return rec.ResponseWriter.Write(b)
+}
+
+// Unwrap returns the underlying ResponseWriter, for http.ResponseController
+func (rec *responseRecorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
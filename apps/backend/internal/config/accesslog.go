@@ -0,0 +1,56 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// AccessLogConfig holds the configuration for HTTP access logging.
+// Sample rates are the fraction of requests logged, from 0 to 1.
+// RouteSampleRates overrides them for the non-error requests of high-traffic
+// routes, as "pattern=rate" entries such as "/api/v1/events=0.01".
+type AccessLogConfig struct {
+	ExcludePaths          []string      `koanf:"exclude_paths"`
+	SuccessSampleRate     float64       `koanf:"success_sample_rate"      validate:"min=0,max=1"`
+	ClientErrorSampleRate float64       `koanf:"client_error_sample_rate" validate:"min=0,max=1"`
+	ServerErrorSampleRate float64       `koanf:"server_error_sample_rate" validate:"min=0,max=1"`
+	RouteSampleRates      []string      `koanf:"route_sample_rates"`
+	SlowThreshold         time.Duration `koanf:"slow_threshold"`
+	SlowOnly              bool          `koanf:"slow_only"`
+	// RouteRates are parsed from RouteSampleRates by LoadConfig
+	RouteRates map[string]float64 `koanf:"-"`
+}
+
+// DefaultAccessLogConfig returns a default access log configuration that
+// logs every request except health checks.
+func DefaultAccessLogConfig() *AccessLogConfig {
+	return &AccessLogConfig{
+		ExcludePaths:          []string{"/health", "/health/live", "/health/ready", "/health/startup"},
+		SuccessSampleRate:     1,
+		ClientErrorSampleRate: 1,
+		ServerErrorSampleRate: 1,
+	}
+}
+
+// ParseRouteSampleRates parses "pattern=rate" entries into the sample rate
+// of every route pattern
+func ParseRouteSampleRates(entries []string) (map[string]float64, error) {
+	rates := make(map[string]float64, len(entries))
+	for _, entry := range entries {
+		if entry == "" {
+			continue
+		}
+		pattern, value, ok := strings.Cut(entry, "=")
+		if !ok || pattern == "" {
+			return nil, fmt.Errorf("route sample rate %q must be pattern=rate", entry)
+		}
+		rate, err := strconv.ParseFloat(value, 64)
+		if err != nil || rate < 0 || rate > 1 {
+			return nil, fmt.Errorf("route sample rate of %s must be a number from 0 to 1", pattern)
+		}
+		rates[pattern] = rate
+	}
+	return rates, nil
+}
@@ -0,0 +1,109 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+// RouteAliasesConfig holds the legacy paths still served after an endpoint
+// was renamed. Aliases are declared in the JSON file at File, see
+// RouteAliasesFile; they can also be registered in code with routers.Alias.
+type RouteAliasesConfig struct {
+	File string `koanf:"file"`
+	// Aliases are loaded from File by LoadConfig
+	Aliases []RouteAliasConfig `koanf:"-"`
+}
+
+// RouteAliasesFile declares route aliases.
+//
+// Example:
+//
+//	{
+//	  "aliases": [
+//	    {"method": "GET", "from": "/api/v1/accounts/{id}", "to": "/api/v1/users/{id}",
+//	     "sunset": "2027-01-01T00:00:00Z"}
+//	  ]
+//	}
+type RouteAliasesFile struct {
+	Aliases []RouteAliasConfig `json:"aliases"`
+}
+
+// RouteAliasConfig maps the requests of the old path pattern From to the new
+// path To, whose parameters must be parameters of From. An empty Method
+// aliases every method. Sunset, when set, is the date the alias is removed.
+type RouteAliasConfig struct {
+	Method string    `json:"method,omitempty"`
+	From   string    `json:"from"`
+	To     string    `json:"to"`
+	Sunset time.Time `json:"sunset,omitzero"`
+}
+
+// pathParam matches the parameters of a path pattern, such as {id} or {path...}
+var pathParam = regexp.MustCompile(`\{([^{}.$]+)(?:\.\.\.)?\}`)
+
+// LoadRouteAliases reads and validates the aliases of a route aliases file
+func LoadRouteAliases(path string) ([]RouteAliasConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read route aliases file: %w", err)
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+
+	var file RouteAliasesFile
+	if err := decoder.Decode(&file); err != nil {
+		var syntaxErr *json.SyntaxError
+		if errors.As(err, &syntaxErr) {
+			line := bytes.Count(data[:syntaxErr.Offset], []byte("\n")) + 1
+			return nil, fmt.Errorf("route aliases file %s: line %d: %w", path, line, err)
+		}
+		return nil, fmt.Errorf("route aliases file %s: %w", path, err)
+	}
+
+	if err := ValidateRouteAliases(file.Aliases); err != nil {
+		return nil, fmt.Errorf("route aliases file %s: %w", path, err)
+	}
+	return file.Aliases, nil
+}
+
+// ValidateRouteAliases checks that aliases map absolute paths to other
+// paths, that the parameters of every new path are found in the old one,
+// and that no old path is aliased twice for a method
+func ValidateRouteAliases(aliases []RouteAliasConfig) error {
+	var errList []error
+	seen := make(map[string]bool)
+	for i, alias := range aliases {
+		if !strings.HasPrefix(alias.From, "/") || !strings.HasPrefix(alias.To, "/") {
+			errList = append(errList, fmt.Errorf("alias %d: from and to must start with /", i))
+			continue
+		}
+		if alias.From == alias.To {
+			errList = append(errList, fmt.Errorf("alias %d: %s is aliased to itself", i, alias.From))
+		}
+
+		var params []string
+		for _, match := range pathParam.FindAllStringSubmatch(alias.From, -1) {
+			params = append(params, match[1])
+		}
+		for _, match := range pathParam.FindAllStringSubmatch(alias.To, -1) {
+			if !slices.Contains(params, match[1]) {
+				errList = append(errList, fmt.Errorf("alias %d: parameter %s of %s is not in %s", i, match[1], alias.To, alias.From))
+			}
+		}
+
+		key := alias.Method + " " + alias.From
+		if seen[key] {
+			errList = append(errList, fmt.Errorf("alias %d: %s is aliased twice", i, strings.TrimSpace(key)))
+		}
+		seen[key] = true
+	}
+	return errors.Join(errList...)
+}
@@ -2,8 +2,11 @@
 package config
 
 import (
+	"fmt"
 	"os"
+	"strconv"
 	"strings"
+	"time"
 
 	_ "github.com/joho/godotenv/autoload" // Load .env file automatically
 	env "github.com/knadh/koanf/providers/env/v2"
@@ -13,14 +16,44 @@ import (
 	"github.com/PrinceNarteh/go-boilerplate/internal/libs"
 )
 
+const (
+	defaultReadHeaderTimeout   = 10      // Default seconds allowed for reading request headers
+	defaultMaxHeaderBytes      = 1 << 20 // Default maximum request header size (1 MiB)
+	defaultMaxRequestBodyBytes = 1 << 20 // Default maximum request body size (1 MiB)
+)
+
 // Config for the application
 type Config struct {
-	Auth          AuthConfig           `koanf:"auth"          validate:"required"`
-	Core          CoreConfig           `koanf:"core"          validate:"required"`
-	Database      DatabaseConfig       `koanf:"database"      validate:"required"`
-	Redis         RedisConfig          `koanf:"redis"         validate:"required"`
-	Server        ServerConfig         `koanf:"server"        validate:"required"`
-	Observability *ObservabilityConfig `koanf:"observability" validate:"required"`
+	Auth            AuthConfig             `koanf:"auth"          validate:"required"`
+	Core            CoreConfig             `koanf:"core"          validate:"required"`
+	Database        DatabaseConfig         `koanf:"database"      validate:"required"`
+	Redis           RedisConfig            `koanf:"redis"         validate:"required"`
+	Server          ServerConfig           `koanf:"server"        validate:"required"`
+	Observability   *ObservabilityConfig   `koanf:"observability" validate:"required"`
+	RateLimit       *RateLimitConfig       `koanf:"rate_limit"`
+	SecurityHeaders *SecurityHeadersConfig `koanf:"security_headers"`
+	WellKnown       *WellKnownConfig       `koanf:"well_known"`
+	GeoIP           GeoIPConfig            `koanf:"geoip"`
+	AccessLog       *AccessLogConfig       `koanf:"access_log"`
+	Scanner         ScannerConfig          `koanf:"scanner"`
+	UsageStats      UsageStatsConfig       `koanf:"usage_stats"`
+	Failover        FailoverConfig         `koanf:"failover"`
+	Scheduler       SchedulerConfig        `koanf:"scheduler"`
+	Jobs            JobsConfig             `koanf:"jobs"`
+	Flags           FlagsConfig            `koanf:"flags"`
+	Outbox          OutboxConfig           `koanf:"outbox"`
+	Tenancy         TenancyConfig          `koanf:"tenancy"`
+	Kafka           KafkaConfig            `koanf:"kafka"`
+	Messaging       MessagingConfig        `koanf:"messaging"`
+	WebSocket       WebSocketConfig        `koanf:"websocket"`
+	GRPC            GRPCConfig             `koanf:"grpc"`
+	GraphQL         GraphQLConfig          `koanf:"graphql"`
+	StatusPage      StatusPageConfig       `koanf:"status_page"`
+	CORS            CORSConfig             `koanf:"cors"`
+	RouteAliases    RouteAliasesConfig     `koanf:"route_aliases"`
+	OpenAPI         OpenAPIConfig          `koanf:"openapi"`
+	Email           EmailConfig            `koanf:"email"`
+	Lists           ListsConfig            `koanf:"lists"`
 }
 
 // CoreConfig contains core configuration for the application
@@ -30,20 +63,124 @@ type CoreConfig struct {
 
 // ServerConfig contains configuration for the server
 type ServerConfig struct {
-	Port               string   `koanf:"port"                 validate:"required"`
-	ReadTimeout        int      `koanf:"read_timeout"         validate:"required"`
-	WriteTimeout       int      `koanf:"write_timeout"        validate:"required"`
-	IdleTimeout        int      `koanf:"idle_timeout"         validate:"required"`
-	CORSAllowedOrigins []string `koanf:"cors_allowed_origins" validate:"required"`
+	Port string `koanf:"port" validate:"required"`
+	// Listen replaces Port with another listener when set: a TCP address
+	// such as "127.0.0.1:8080", a Unix socket as "unix:///run/api.sock", or
+	// a socket of systemd socket activation as "systemd://", for the first
+	// one, or "systemd://name" for the one with FileDescriptorName=name
+	Listen string `koanf:"listen"`
+	// SocketMode is the octal file mode of a Unix socket, such as "0660",
+	// which otherwise follows the umask
+	SocketMode          string `koanf:"socket_mode"`
+	ReadTimeout         int    `koanf:"read_timeout"           validate:"required"`
+	ReadHeaderTimeout   int    `koanf:"read_header_timeout"`
+	WriteTimeout        int    `koanf:"write_timeout"          validate:"required"`
+	IdleTimeout         int    `koanf:"idle_timeout"           validate:"required"`
+	MaxHeaderBytes      int    `koanf:"max_header_bytes"`
+	MaxRequestBodyBytes int64  `koanf:"max_request_body_bytes"`
+	// RouteBodyLimits overrides MaxRequestBodyBytes under path prefixes, as
+	// "prefix=bytes" entries such as "/api/v1/files=104857600", the longest
+	// prefix applying; 0 lifts the limit
+	RouteBodyLimits []string `koanf:"route_body_limits"`
+	// BodyLimits are parsed from RouteBodyLimits by LoadConfig
+	BodyLimits         map[string]int64 `koanf:"-"`
+	CORSAllowedOrigins []string         `koanf:"cors_allowed_origins"   validate:"required"`
+	// EnablePprof serves the runtime profiles and expvar variables under
+	// /debug, to admins only, or to anyone reaching the admin server or
+	// PprofPort when either is set
+	EnablePprof bool   `koanf:"enable_pprof"`
+	PprofPort   string `koanf:"pprof_port"`
+	// AdminListen runs an internal admin server on this address, in the
+	// forms of Listen, serving the health probes, the Prometheus metrics,
+	// the debug endpoints and the configuration in effect. The metrics and
+	// debug endpoints are then no longer served with the API.
+	AdminListen string `koanf:"admin_listen"`
+	// ReusePort sets SO_REUSEPORT on the TCP listeners, so that the process
+	// replacing this one on a rolling restart can listen on the same ports
+	// while this one drains
+	ReusePort bool `koanf:"reuse_port"`
+	// TrustedProxies are the addresses and CIDR ranges of the reverse
+	// proxies in front of the server, whose X-Forwarded-For header gives
+	// the client IP used by rate limits, IP and country blocking and logs.
+	// The proxies connecting on a Unix socket are always trusted.
+	TrustedProxies []string `koanf:"trusted_proxies" validate:"dive,cidr|ip"`
+	// DrainDelay is how long readiness fails on SIGINT or SIGTERM before the
+	// servers stop accepting connections, for load balancers to stop sending
+	// requests. In-flight requests are then given the shutdown timeout.
+	DrainDelay time.Duration   `koanf:"drain_delay" validate:"min=0"`
+	TLS        ServerTLSConfig `koanf:"tls"`
 }
 
-// RedisConfig contains configuration for Redis
+// ServerTLSConfig contains the TLS configuration of the HTTP server, which
+// then serves HTTPS and HTTP/2 on Port.
+// The certificate is read from CertFile and KeyFile, or obtained from
+// Let's Encrypt for Hosts when Autocert is set.
+type ServerTLSConfig struct {
+	Enabled  bool   `koanf:"enabled"`
+	CertFile string `koanf:"cert_file" validate:"required_if=Enabled true Autocert false"`
+	KeyFile  string `koanf:"key_file"  validate:"required_if=Enabled true Autocert false"`
+	Autocert bool   `koanf:"autocert"`
+	// Hosts are the only host names certificates are requested for
+	Hosts []string `koanf:"hosts"     validate:"required_if=Enabled true Autocert true"`
+	// CacheDir keeps the certificates across restarts, to stay within the
+	// rate limits of Let's Encrypt
+	CacheDir string `koanf:"cache_dir" validate:"required_if=Enabled true Autocert true"`
+	Email    string `koanf:"email"     validate:"omitempty,email"`
+	// RedirectPort serves a redirect of HTTP requests to HTTPS when set, and
+	// the HTTP-01 challenges of Let's Encrypt with Autocert
+	RedirectPort string `koanf:"redirect_port"`
+}
+
+// ParseRouteBodyLimits parses "prefix=bytes" entries into the body limit
+// of every path prefix
+func ParseRouteBodyLimits(entries []string) (map[string]int64, error) {
+	limits := make(map[string]int64, len(entries))
+	for _, entry := range entries {
+		if entry == "" {
+			continue
+		}
+		prefix, value, ok := strings.Cut(entry, "=")
+		if !ok || !strings.HasPrefix(prefix, "/") {
+			return nil, fmt.Errorf("route body limit %q must be prefix=bytes", entry)
+		}
+		limit, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || limit < 0 {
+			return nil, fmt.Errorf("body limit of %s must be a number of bytes", prefix)
+		}
+		limits[prefix] = limit
+	}
+	return limits, nil
+}
+
+// RedisConfig contains configuration for Redis.
+// Zero timeouts and pool size use the go-redis defaults.
 type RedisConfig struct {
-	Address string `koanf:"address" validate:"required"`
+	Address       string         `koanf:"address"        validate:"required"`
+	Username      string         `koanf:"username"`
+	Password      string         `koanf:"password"`
+	DB            int            `koanf:"db"             validate:"min=0"`
+	PoolSize      int            `koanf:"pool_size"      validate:"min=0"`
+	DialTimeout   time.Duration  `koanf:"dial_timeout"`
+	ReadTimeout   time.Duration  `koanf:"read_timeout"`
+	WriteTimeout  time.Duration  `koanf:"write_timeout"`
+	SlowThreshold time.Duration  `koanf:"slow_threshold"`
+	TLS           RedisTLSConfig `koanf:"tls"`
+}
+
+// RedisTLSConfig contains the TLS configuration of the Redis connection.
+// CAFile is a PEM bundle trusted in addition to the system roots.
+type RedisTLSConfig struct {
+	Enabled            bool   `koanf:"enabled"`
+	ServerName         string `koanf:"server_name"`
+	CAFile             string `koanf:"ca_file"`
+	InsecureSkipVerify bool   `koanf:"insecure_skip_verify"`
 }
 
 // DatabaseConfig contains configuration for database
 type DatabaseConfig struct {
+	// Enabled connects to the database and serves the feature modules
+	// stored in it, such as users and accounts
+	Enabled         bool   `koanf:"enabled"`
 	Host            string `koanf:"host"              validate:"required"`
 	Port            string `koanf:"port"              validate:"required"`
 	User            string `koanf:"user"              validate:"required"`
@@ -54,11 +191,20 @@ type DatabaseConfig struct {
 	MaxIdleConns    string `koanf:"max_idle_conns"    validate:"required"`
 	ConnMaxLifetime string `koanf:"conn_max_lifetime" validate:"required"`
 	ConnMaxIdletime string `koanf:"conn_max_idletime" validate:"required"`
+	// Replicas are the host:port addresses of read replicas, which share the
+	// credentials and database name of the primary
+	Replicas             []string      `koanf:"replicas"`
+	ReplicaCheckInterval time.Duration `koanf:"replica_check_interval"`
 }
 
 // AuthConfig contains configuration for authentication
 type AuthConfig struct {
 	SecretKey string `koanf:"secret_key" validate:"required"`
+	// PrincipalCacheTTL is how long the principals looked up for a token are
+	// cached, in Redis when configured, so changes to a user apply within it.
+	// Principals are looked up once per request when 0. Revoked sessions are
+	// checked on every request regardless.
+	PrincipalCacheTTL time.Duration `koanf:"principal_cache_ttl" validate:"min=0"`
 }
 
 // LoadConfig loads the configuration from a file or environment variables
@@ -101,10 +247,96 @@ func LoadConfig() (*Config, error) {
 		mainConfig.Observability = DefaultObservabilityConfig()
 	}
 
+	// Set default request limits if not provided
+	if mainConfig.Server.ReadHeaderTimeout <= 0 {
+		mainConfig.Server.ReadHeaderTimeout = defaultReadHeaderTimeout
+	}
+	if mainConfig.Server.MaxHeaderBytes <= 0 {
+		mainConfig.Server.MaxHeaderBytes = defaultMaxHeaderBytes
+	}
+	if mainConfig.Server.MaxRequestBodyBytes <= 0 {
+		mainConfig.Server.MaxRequestBodyBytes = defaultMaxRequestBodyBytes
+	}
+
+	// Set default rate limit config if not provided
+	if mainConfig.RateLimit == nil {
+		mainConfig.RateLimit = DefaultRateLimitConfig()
+	}
+
+	// Set default security headers config if not provided
+	if mainConfig.SecurityHeaders == nil {
+		mainConfig.SecurityHeaders = DefaultSecurityHeadersConfig()
+	}
+
+	// Set default well-known endpoints config if not provided
+	if mainConfig.WellKnown == nil {
+		mainConfig.WellKnown = DefaultWellKnownConfig()
+	}
+
+	// Set default access log config if not provided
+	if mainConfig.AccessLog == nil {
+		mainConfig.AccessLog = DefaultAccessLogConfig()
+	}
+	routeRates, err := ParseRouteSampleRates(mainConfig.AccessLog.RouteSampleRates)
+	if err != nil {
+		logger.Fatal().Err(err).Msg("invalid access log sample rates")
+	}
+	mainConfig.AccessLog.RouteRates = routeRates
+
+	bodyLimits, err := ParseRouteBodyLimits(mainConfig.Server.RouteBodyLimits)
+	if err != nil {
+		logger.Fatal().Err(err).Msg("invalid route body limits")
+	}
+	mainConfig.Server.BodyLimits = bodyLimits
+
+	countryBlocks, err := ParseCountryBlocks(mainConfig.GeoIP.BlockedCountries)
+	if err != nil {
+		logger.Fatal().Err(err).Msg("invalid blocked countries")
+	}
+	mainConfig.GeoIP.CountryBlocks = countryBlocks
+
 	// Override service name and environment from primary config
 	mainConfig.Observability.ServiceName = "api"
 	mainConfig.Observability.Environment = mainConfig.Core.Env
 
+	// Load the scheduled jobs
+	if mainConfig.Scheduler.Enabled {
+		jobs, err := LoadJobs(mainConfig.Scheduler.File)
+		if err != nil {
+			logger.Fatal().Err(err).Msg("invalid scheduled jobs")
+		}
+		mainConfig.Scheduler.Jobs = jobs
+	}
+
+	// Load the feature flags
+	if mainConfig.Flags.Enabled {
+		flags, err := LoadFlags(mainConfig.Flags.File)
+		if err != nil {
+			logger.Fatal().Err(err).Msg("invalid feature flags")
+		}
+		mainConfig.Flags.Declarations = flags
+	}
+
+	// Load the CORS policies
+	if mainConfig.CORS.File != "" {
+		policies, err := LoadCORS(mainConfig.CORS.File)
+		if err != nil {
+			logger.Fatal().Err(err).Msg("invalid CORS policies")
+		}
+		mainConfig.CORS.Policies = policies
+	} else {
+		mainConfig.CORS.Policies = DefaultCORSPolicies(mainConfig.Server.CORSAllowedOrigins, mainConfig.GraphQL)
+	}
+
+	// Load the route aliases
+	if mainConfig.RouteAliases.File != "" {
+		aliases, err := LoadRouteAliases(mainConfig.RouteAliases.File)
+		if err != nil {
+			logger.Fatal().Err(err).Msg("invalid route aliases")
+		}
+		mainConfig.RouteAliases.Aliases = aliases
+	}
+
 	// Validate observability config
 	if err := mainConfig.Observability.Validate(); err != nil {
 		logger.Fatal().Err(err).Msg("invalid observability config")
@@ -0,0 +1,128 @@
+package config
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+)
+
+// CORSConfig holds the configuration of CORS policies. Policies are
+// declared in the JSON file at File, each bound to the route groups under
+// its paths. Without a file, the "api" policy of DefaultCORSPolicies allows
+// Server.CORSAllowedOrigins on the API routes only.
+type CORSConfig struct {
+	File string `koanf:"file"`
+	// Policies are loaded from File by LoadConfig
+	Policies map[string]CORSPolicyConfig `koanf:"-"`
+}
+
+// CORSFile declares the CORS policies by name.
+//
+// Example:
+//
+//	{
+//	  "policies": {
+//	    "public": {"paths": ["/api/v1"], "allowed_origins": ["*"]},
+//	    "admin": {"paths": ["/api/v1/admin"], "allowed_origins": ["https://admin.example.com"],
+//	              "allow_credentials": true, "max_age": 600}
+//	  }
+//	}
+type CORSFile struct {
+	Policies map[string]CORSPolicyConfig `json:"policies"`
+}
+
+// CORSPolicyConfig declares a CORS policy. It applies to the requests whose
+// path is one of Paths or below one, the longest path of every policy
+// winning, so a policy can override another for a nested route group.
+// Requests under no policy, such as health probes, get no CORS headers.
+// AllowedMethods and AllowedHeaders default to the methods and headers of
+// the API, and MaxAge, in seconds, lets browsers cache preflight responses.
+type CORSPolicyConfig struct {
+	Paths            []string `json:"paths"`
+	AllowedOrigins   []string `json:"allowed_origins"`
+	AllowedMethods   []string `json:"allowed_methods,omitempty"`
+	AllowedHeaders   []string `json:"allowed_headers,omitempty"`
+	ExposedHeaders   []string `json:"exposed_headers,omitempty"`
+	AllowCredentials bool     `json:"allow_credentials,omitempty"`
+	MaxAge           int      `json:"max_age,omitempty"`
+}
+
+// DefaultCORSPolicies returns the policy applied without a CORS file,
+// allowing the given origins on the API routes and the GraphQL endpoint
+func DefaultCORSPolicies(allowedOrigins []string, graphQL GraphQLConfig) map[string]CORSPolicyConfig {
+	paths := []string{"/api"}
+	if graphQL.Enabled {
+		// The endpoint is served at /graphql unless configured otherwise
+		paths = append(paths, cmp.Or(graphQL.Path, "/graphql"))
+	}
+	return map[string]CORSPolicyConfig{
+		"api": {Paths: paths, AllowedOrigins: allowedOrigins},
+	}
+}
+
+// LoadCORS reads and validates the policies of a CORS file
+func LoadCORS(path string) (map[string]CORSPolicyConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CORS file: %w", err)
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+
+	var file CORSFile
+	if err := decoder.Decode(&file); err != nil {
+		var syntaxErr *json.SyntaxError
+		if errors.As(err, &syntaxErr) {
+			line := bytes.Count(data[:syntaxErr.Offset], []byte("\n")) + 1
+			return nil, fmt.Errorf("CORS file %s: line %d: %w", path, line, err)
+		}
+		return nil, fmt.Errorf("CORS file %s: %w", path, err)
+	}
+
+	if err := ValidateCORS(file.Policies); err != nil {
+		return nil, fmt.Errorf("CORS file %s: %w", path, err)
+	}
+	return file.Policies, nil
+}
+
+// ValidateCORS checks that every policy has origins and absolute paths, that
+// no path is bound to two policies, and that credentials are only allowed
+// for listed origins, as browsers reject them with a wildcard
+func ValidateCORS(policies map[string]CORSPolicyConfig) error {
+	var errList []error
+	bound := make(map[string]string)
+	for _, name := range slices.Sorted(maps.Keys(policies)) {
+		policy := policies[name]
+		if len(policy.Paths) == 0 {
+			errList = append(errList, fmt.Errorf("policy %q: paths are required", name))
+		}
+		for _, path := range policy.Paths {
+			if !strings.HasPrefix(path, "/") {
+				errList = append(errList, fmt.Errorf("policy %q: path %q must start with /", name, path))
+				continue
+			}
+			if other, ok := bound[path]; ok {
+				errList = append(errList, fmt.Errorf("policy %q: path %q is bound to policy %q", name, path, other))
+			}
+			bound[path] = name
+		}
+
+		if len(policy.AllowedOrigins) == 0 {
+			errList = append(errList, fmt.Errorf("policy %q: allowed_origins are required", name))
+		}
+		if policy.AllowCredentials && slices.Contains(policy.AllowedOrigins, "*") {
+			errList = append(errList, fmt.Errorf("policy %q: credentials cannot be allowed for every origin", name))
+		}
+		if policy.MaxAge < 0 {
+			errList = append(errList, fmt.Errorf("policy %q: max_age must not be negative", name))
+		}
+	}
+	return errors.Join(errList...)
+}
@@ -0,0 +1,9 @@
+package config
+
+// EmailConfig holds the configuration of outbound email
+type EmailConfig struct {
+	// WebhookSecret authenticates the bounce and complaint webhooks of the
+	// email provider, as the password of their HTTP basic auth. The
+	// webhooks are not served when it is empty.
+	WebhookSecret string `koanf:"webhook_secret"`
+}
@@ -0,0 +1,18 @@
+package config
+
+import "time"
+
+// FailoverConfig holds the configuration of the connection supervisors.
+// A supervisor probes its connection every CheckInterval and, during an
+// outage, every MinBackoff doubling up to MaxBackoff. The policies decide
+// what happens to writes while the connection is down: "fail" rejects them
+// with 503 Service Unavailable, "queue" keeps up to QueueSize of them in
+// memory and replays them on recovery, and "degrade" skips them.
+type FailoverConfig struct {
+	CheckInterval  time.Duration `koanf:"check_interval"`
+	MinBackoff     time.Duration `koanf:"min_backoff"`
+	MaxBackoff     time.Duration `koanf:"max_backoff"`
+	QueueSize      int           `koanf:"queue_size"`
+	DatabasePolicy string        `koanf:"database_policy" validate:"omitempty,oneof=fail queue degrade"`
+	RedisPolicy    string        `koanf:"redis_policy"    validate:"omitempty,oneof=fail queue degrade"`
+}
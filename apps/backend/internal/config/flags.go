@@ -0,0 +1,112 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+)
+
+// FlagsConfig holds the configuration of feature flags. Flags are declared
+// in the JSON file at File, so they can be changed and reloaded with SIGHUP
+// without a restart. Without a file, every flag is off.
+type FlagsConfig struct {
+	Enabled bool   `koanf:"enabled"`
+	File    string `koanf:"file"    validate:"required_if=Enabled true"`
+	// Declarations are loaded from File by LoadConfig
+	Declarations FlagsFile `koanf:"-"`
+}
+
+// FlagsFile declares feature flags and the cohorts they are rolled out to.
+//
+// Example:
+//
+//	{
+//	  "cohorts": {
+//	    "internal": {"roles": ["admin"], "email_domains": ["example.com"]},
+//	    "beta": {"user_ids": [42, 1337]}
+//	  },
+//	  "flags": {
+//	    "reports-v2": {"enabled": true, "cohorts": ["internal", "beta"], "percentage": 10}
+//	  }
+//	}
+type FlagsFile struct {
+	Cohorts map[string]CohortConfig `json:"cohorts,omitempty"`
+	Flags   map[string]FlagConfig   `json:"flags,omitempty"`
+}
+
+// CohortConfig declares a group of users. A user belongs to the cohort when
+// any of the criteria matches.
+type CohortConfig struct {
+	UserIDs []int    `json:"user_ids,omitempty"`
+	Roles   []string `json:"roles,omitempty"`
+	// EmailDomains match the domain of the email address, case-insensitively
+	EmailDomains []string `json:"email_domains,omitempty"`
+}
+
+// FlagConfig declares a feature flag. A disabled flag is off for everyone.
+// An enabled flag without cohorts or percentage is on for everyone;
+// otherwise it is on for the members of Cohorts and for Percentage percent
+// of the other authenticated users, always the same ones.
+type FlagConfig struct {
+	Enabled     bool     `json:"enabled"`
+	Description string   `json:"description,omitempty"`
+	Cohorts     []string `json:"cohorts,omitempty"`
+	Percentage  int      `json:"percentage,omitempty"`
+}
+
+// LoadFlags reads and validates the declarations of a flags file
+func LoadFlags(path string) (FlagsFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return FlagsFile{}, fmt.Errorf("failed to read flags file: %w", err)
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+
+	var file FlagsFile
+	if err := decoder.Decode(&file); err != nil {
+		var syntaxErr *json.SyntaxError
+		if errors.As(err, &syntaxErr) {
+			line := bytes.Count(data[:syntaxErr.Offset], []byte("\n")) + 1
+			return FlagsFile{}, fmt.Errorf("flags file %s: line %d: %w", path, line, err)
+		}
+		return FlagsFile{}, fmt.Errorf("flags file %s: %w", path, err)
+	}
+
+	if err := ValidateFlags(file); err != nil {
+		return FlagsFile{}, fmt.Errorf("flags file %s: %w", path, err)
+	}
+	return file, nil
+}
+
+// ValidateFlags checks that flags only reference declared cohorts and roll
+// out to a valid percentage
+func ValidateFlags(file FlagsFile) error {
+	var errList []error
+	for _, name := range slices.Sorted(maps.Keys(file.Cohorts)) {
+		for _, domain := range file.Cohorts[name].EmailDomains {
+			if domain == "" || strings.Contains(domain, "@") {
+				errList = append(errList, fmt.Errorf("cohort %q: invalid email domain %q", name, domain))
+			}
+		}
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(file.Flags)) {
+		flag := file.Flags[name]
+		for _, cohort := range flag.Cohorts {
+			if _, ok := file.Cohorts[cohort]; !ok {
+				errList = append(errList, fmt.Errorf("flag %q: unknown cohort %q", name, cohort))
+			}
+		}
+		if flag.Percentage < 0 || flag.Percentage > 100 {
+			errList = append(errList, fmt.Errorf("flag %q: percentage must be between 0 and 100", name))
+		}
+	}
+	return errors.Join(errList...)
+}
@@ -0,0 +1,41 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// GeoIPConfig holds the configuration for IP geolocation enrichment
+type GeoIPConfig struct {
+	Enabled       bool   `koanf:"enabled"`
+	CountryDBPath string `koanf:"country_db_path"`
+	ASNDBPath     string `koanf:"asn_db_path"`
+	// BlockedCountries are "path=codes" entries, such as "/api/v1=KP,IR",
+	// blocking comma-separated ISO 3166-1 alpha-2 codes on the requests to a
+	// route group path or below it
+	BlockedCountries []string `koanf:"blocked_countries"`
+	// CountryBlocks are parsed from BlockedCountries by LoadConfig
+	CountryBlocks map[string][]string `koanf:"-"`
+}
+
+// ParseCountryBlocks parses "path=codes" entries into the blocked country
+// codes of every route group path
+func ParseCountryBlocks(entries []string) (map[string][]string, error) {
+	blocks := make(map[string][]string, len(entries))
+	for _, entry := range entries {
+		if entry == "" {
+			continue
+		}
+		path, value, ok := strings.Cut(entry, "=")
+		if !ok || !strings.HasPrefix(path, "/") || value == "" {
+			return nil, fmt.Errorf("blocked countries %q must be path=codes", entry)
+		}
+		for code := range strings.SplitSeq(value, ",") {
+			if len(code) != 2 {
+				return nil, fmt.Errorf("blocked country %q of %s must be an ISO 3166-1 alpha-2 code", code, path)
+			}
+			blocks[path] = append(blocks[path], strings.ToUpper(code))
+		}
+	}
+	return blocks, nil
+}
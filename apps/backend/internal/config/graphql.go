@@ -0,0 +1,12 @@
+package config
+
+// GraphQLConfig holds the configuration of the GraphQL endpoint served at
+// Path. MaxDepth and MaxComplexity reject expensive queries before they run,
+// and the playground is served at Path/playground outside production.
+type GraphQLConfig struct {
+	Enabled       bool   `koanf:"enabled"`
+	Path          string `koanf:"path"`
+	MaxDepth      int    `koanf:"max_depth"`
+	MaxComplexity int    `koanf:"max_complexity"`
+	Playground    bool   `koanf:"playground"`
+}
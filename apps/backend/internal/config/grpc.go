@@ -0,0 +1,11 @@
+package config
+
+// GRPCConfig holds the configuration of the gRPC server, listening on Port
+// beside the HTTP server. Reflection lets tools such as grpcurl discover
+// the services, and MaxRecvMsgBytes limits the size of requests.
+type GRPCConfig struct {
+	Enabled         bool   `koanf:"enabled"`
+	Port            string `koanf:"port"`
+	Reflection      bool   `koanf:"reflection"`
+	MaxRecvMsgBytes int    `koanf:"max_recv_msg_bytes"`
+}
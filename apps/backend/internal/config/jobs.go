@@ -0,0 +1,30 @@
+package config
+
+import "time"
+
+// JobsConfig holds the configuration of the background job queue. Jobs are
+// kept in Redis or in PostgreSQL, as set by Store, and run by Concurrency
+// workers polling every PollInterval. A running job is claimed for Lease,
+// renewed while it runs, so the job of a crashed worker is picked up again
+// once its claim expires. Failed jobs are retried up to MaxAttempts times,
+// waiting MinBackoff doubling up to MaxBackoff, then moved to the
+// dead-letter queue. On shutdown, running jobs get ShutdownTimeout to finish.
+//
+// The queue is full once MaxPending jobs wait in the store, unbounded when
+// zero. Jobs enqueued while it is full follow Overflow: block waits up to
+// BlockTimeout for room, drop rejects them, and spill adds them to a
+// secondary PostgreSQL store with its own worker.
+type JobsConfig struct {
+	Enabled         bool          `koanf:"enabled"`
+	Store           string        `koanf:"store"            validate:"omitempty,oneof=redis postgres"`
+	Concurrency     int           `koanf:"concurrency"`
+	PollInterval    time.Duration `koanf:"poll_interval"`
+	Lease           time.Duration `koanf:"lease"`
+	MaxAttempts     int           `koanf:"max_attempts"`
+	MinBackoff      time.Duration `koanf:"min_backoff"`
+	MaxBackoff      time.Duration `koanf:"max_backoff"`
+	ShutdownTimeout time.Duration `koanf:"shutdown_timeout"`
+	MaxPending      int64         `koanf:"max_pending"`
+	Overflow        string        `koanf:"overflow"         validate:"omitempty,oneof=block drop spill"`
+	BlockTimeout    time.Duration `koanf:"block_timeout"`
+}
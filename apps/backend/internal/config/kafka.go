@@ -0,0 +1,25 @@
+package config
+
+import "time"
+
+// KafkaConfig holds the configuration of the Kafka producer and consumers,
+// used when MessagingConfig.Driver is "kafka".
+// Producers wait for RequiredAcks replicas and send batches every
+// BatchTimeout. Consumers join the consumer group GroupID, start from
+// StartOffset when the group has no committed offset, and commit the
+// offsets of handled messages every CommitInterval, or after each message
+// when zero. A message whose handler fails is retried MaxAttempts times,
+// waiting RetryBackoff doubling between attempts, then skipped. On shutdown,
+// consumers get ShutdownTimeout to finish the message in hand.
+type KafkaConfig struct {
+	Brokers         []string      `koanf:"brokers"`
+	ClientID        string        `koanf:"client_id"`
+	GroupID         string        `koanf:"group_id"`
+	RequiredAcks    string        `koanf:"required_acks"    validate:"omitempty,oneof=all one none"`
+	BatchTimeout    time.Duration `koanf:"batch_timeout"`
+	StartOffset     string        `koanf:"start_offset"     validate:"omitempty,oneof=earliest latest"`
+	CommitInterval  time.Duration `koanf:"commit_interval"`
+	MaxAttempts     int           `koanf:"max_attempts"`
+	RetryBackoff    time.Duration `koanf:"retry_backoff"`
+	ShutdownTimeout time.Duration `koanf:"shutdown_timeout"`
+}
@@ -0,0 +1,25 @@
+package config
+
+import "time"
+
+// ListsConfig holds the sources of the third-party allowlists and denylists
+// of package lists. Each list keeps its embedded entries unless a file or
+// URL is set.
+type ListsConfig struct {
+	// RefreshInterval is how often files and URLs are checked for changes,
+	// which they also are on SIGHUP. Zero only checks on SIGHUP.
+	RefreshInterval   time.Duration    `koanf:"refresh_interval"   validate:"min=0"`
+	DisposableDomains ListSourceConfig `koanf:"disposable_domains"`
+	BlockedIPs        ListSourceConfig `koanf:"blocked_ips"`
+	ReservedUsernames ListSourceConfig `koanf:"reserved_usernames"`
+}
+
+// ListSourceConfig is the source of a list: a file or a URL, revalidated
+// with its ETag. Entries are one per line, and text after "#" is ignored.
+type ListSourceConfig struct {
+	File string `koanf:"file"`
+	URL  string `koanf:"url"    validate:"omitempty,url,excluded_with=File"`
+	// SHA256 pins the hex digest the content must have; content with
+	// another digest is rejected
+	SHA256 string `koanf:"sha256" validate:"omitempty,hexadecimal,len=64"`
+}
@@ -0,0 +1,31 @@
+package config
+
+import "time"
+
+// MessagingConfig selects the message broker with Driver: "kafka", set up
+// with KafkaConfig, "nats", set up with NATS, or none when empty.
+type MessagingConfig struct {
+	Driver string     `koanf:"driver" validate:"omitempty,oneof=kafka nats"`
+	NATS   NATSConfig `koanf:"nats"`
+}
+
+// NATSConfig holds the configuration of the NATS connection and JetStream.
+// Messages published to Subjects are stored in the stream Stream, created
+// or updated on connection when set. Durable consumers, named after Durable,
+// redeliver a message whose handler fails, waiting RetryBackoff doubling
+// between deliveries, until it was delivered MaxDeliver times. Messages not
+// acknowledged within AckWait are redelivered. Requests wait RequestTimeout
+// for a reply, and consumers get ShutdownTimeout to finish the message in
+// hand on shutdown.
+type NATSConfig struct {
+	URL             string        `koanf:"url"`
+	Name            string        `koanf:"name"`
+	Stream          string        `koanf:"stream"`
+	Subjects        []string      `koanf:"subjects"`
+	Durable         string        `koanf:"durable"`
+	AckWait         time.Duration `koanf:"ack_wait"`
+	MaxDeliver      int           `koanf:"max_deliver"`
+	RetryBackoff    time.Duration `koanf:"retry_backoff"`
+	RequestTimeout  time.Duration `koanf:"request_timeout"`
+	ShutdownTimeout time.Duration `koanf:"shutdown_timeout"`
+}
@@ -3,13 +3,27 @@ package config
 import (
 	"errors"
 	"fmt"
+	"regexp"
+	"slices"
+	"strings"
 	"time"
 )
 
 const (
-	slowQueryThreshold  = 100 * time.Millisecond // Default threshold for slow queries
-	healthCheckInterval = 30 * time.Second       // Default interval for health checks
-	healthCheckTimeout  = 5 * time.Second        // Default timeout for health checks
+	slowQueryThreshold   = 100 * time.Millisecond // Default threshold for slow queries
+	metricsInterval      = 30 * time.Second       // Default interval for exporting metrics
+	healthCheckInterval  = 30 * time.Second       // Default interval for health checks
+	healthCheckTimeout   = 5 * time.Second        // Default timeout for health checks
+	healthCheckHistory   = 2880                   // Default number of results kept per check, a day of checks
+	healthCheckFlaps     = 20                     // Default number of recent results inspected for flapping
+	healthCheckChanges   = 5                      // Default number of status changes marking a check as flapping
+	eventsBuffer         = 1000                   // Default number of buffered application events
+	eventsRate           = 100                    // Default number of application events sent per second
+	errorsInterval       = time.Minute            // Default interval between batches of reported errors
+	errorsMaxPerInterval = 100                    // Default number of distinct errors reported per interval
+	logFileMaxSize       = 100                    // Default size of a log file, in megabytes, before it is rotated
+	logFileMaxAge        = 7 * 24 * time.Hour     // Default age of rotated log files before they are removed
+	logFileMaxBackups    = 10                     // Default number of rotated log files kept
 )
 
 // ObservabilityConfig holds the configuration for observability features
@@ -18,14 +32,98 @@ type ObservabilityConfig struct {
 	Environment  string             `koanf:"environment"   validate:"required"`
 	Logging      LoggingConfig      `koanf:"logging"       validate:"required"`
 	NewRelic     NewRelicConfig     `koanf:"new_relic"     validate:"required"`
+	Sentry       SentryConfig       `koanf:"sentry"`
 	HealthChecks HealthChecksConfig `koanf:"health_checks" validate:"required"`
+	Metrics      MetricsConfig      `koanf:"metrics"`
+	Prometheus   PrometheusConfig   `koanf:"prometheus"`
+	Events       EventsConfig       `koanf:"events"`
+	Errors       ErrorsConfig       `koanf:"errors"`
 }
 
 // LoggingConfig holds the configuration for logging
 type LoggingConfig struct {
-	Level              string        `koanf:"level"                validate:"required,oneof=debug info warn error fatal"`
-	Format             string        `koanf:"format"               validate:"required,oneof=json text"`
-	SlowQueryThreshold time.Duration `koanf:"slow_query_threshold" validate:"required,gt=0"`
+	Level              string                `koanf:"level"                validate:"required,oneof=debug info warn error fatal"`
+	Format             string                `koanf:"format"               validate:"required,oneof=json text"`
+	SlowQueryThreshold time.Duration         `koanf:"slow_query_threshold" validate:"required,gt=0"`
+	Sampling           LoggingSamplingConfig `koanf:"sampling"`
+	Redact             LoggingRedactConfig   `koanf:"redact"`
+	// Output lists the sinks every line is written to: stdout, stderr or
+	// file, stdout when empty
+	Output []string          `koanf:"output" validate:"dive,oneof=stdout stderr file"`
+	File   LoggingFileConfig `koanf:"file"`
+}
+
+// Log outputs
+const (
+	LogOutputStdout = "stdout"
+	LogOutputStderr = "stderr"
+	LogOutputFile   = "file"
+)
+
+// LoggingFileConfig holds the configuration for the file output, for
+// deployments without a log collector reading stdout. The file at Path is
+// rotated once it grows beyond MaxSize megabytes, and the rotated files are
+// removed once older than MaxAge or beyond the MaxBackups most recent ones,
+// a zero value keeping them all. Compress gzips the rotated files.
+type LoggingFileConfig struct {
+	Path       string        `koanf:"path"`
+	MaxSize    int           `koanf:"max_size"    validate:"min=0"`
+	MaxAge     time.Duration `koanf:"max_age"     validate:"min=0"`
+	MaxBackups int           `koanf:"max_backups" validate:"min=0"`
+	Compress   bool          `koanf:"compress"`
+}
+
+// LoggingRedactConfig holds the configuration for scrubbing sensitive values
+// from log events before they are written. Fields whose name contains one of
+// Fields, case-insensitively, are redacted, and so are the matches of
+// Patterns in every string value. Both add to DefaultRedactFields and
+// DefaultRedactPatterns.
+type LoggingRedactConfig struct {
+	Enabled  bool     `koanf:"enabled"`
+	Fields   []string `koanf:"fields"`
+	Patterns []string `koanf:"patterns"`
+}
+
+// DefaultRedactFields are the names, or parts of the names, of the log
+// fields always redacted
+var DefaultRedactFields = []string{
+	"password", "secret", "token", "authorization", "cookie", "api_key", "credit_card", "card_number", "cvv",
+}
+
+// DefaultRedactPatterns are the regular expressions of the values always
+// redacted from log strings: bearer credentials and JSON Web Tokens
+var DefaultRedactPatterns = []string{
+	`(?i)bearer\s+[a-z0-9._~+/-]+=*`,
+	`eyJ[a-zA-Z0-9_-]+\.[a-zA-Z0-9_-]+\.[a-zA-Z0-9_-]+`,
+}
+
+// CompilePatterns compiles the default and configured patterns
+func (c LoggingRedactConfig) CompilePatterns() ([]*regexp.Regexp, error) {
+	patterns := make([]*regexp.Regexp, 0, len(DefaultRedactPatterns)+len(c.Patterns))
+	for _, pattern := range slices.Concat(DefaultRedactPatterns, c.Patterns) {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redact pattern %q: %w", pattern, err)
+		}
+		patterns = append(patterns, re)
+	}
+	return patterns, nil
+}
+
+// LoggingSamplingConfig holds the configuration for log sampling, so a
+// misbehaving dependency cannot flood the logs and their ingest costs.
+// One in DebugEvery debug lines and one in InfoEvery info lines are logged.
+// Beyond Burst lines of a level per Period, warnings and errors included,
+// only one in BurstEvery lines of that level is logged, or none when
+// BurstEvery is 0. A zero DebugEvery, InfoEvery or Burst disables that
+// limit, and fatal lines are never sampled.
+type LoggingSamplingConfig struct {
+	Enabled    bool          `koanf:"enabled"`
+	DebugEvery uint32        `koanf:"debug_every"`
+	InfoEvery  uint32        `koanf:"info_every"`
+	Burst      uint32        `koanf:"burst"`
+	Period     time.Duration `koanf:"period"      validate:"min=0"`
+	BurstEvery uint32        `koanf:"burst_every"`
 }
 
 // NewRelicConfig holds the configuration for New Relic integration
@@ -36,12 +134,76 @@ type NewRelicConfig struct {
 	DebugLogging              bool   `koanf:"debug_logging"               validate:"required"`
 }
 
-// HealthChecksConfig holds the configuration for health checks
+// SentryConfig holds the configuration for Sentry error reporting, enabled
+// when DSN is set. Panics and 5xx errors of requests are reported with the
+// request, its user and a stack trace, alongside New Relic. SampleRate is the
+// fraction of errors reported.
+type SentryConfig struct {
+	DSN        string  `koanf:"dsn"`
+	SampleRate float64 `koanf:"sample_rate" validate:"min=0,max=1"`
+	Debug      bool    `koanf:"debug"`
+}
+
+// HealthChecksConfig holds the configuration for health checks.
+// HistorySize is the number of results kept per check, from which the
+// uptime of the status page is computed, in memory or in Redis, where it
+// survives restarts and is shared by every instance, per HistoryStore.
+// A check is flapping when its status changed FlapThreshold times within
+// its last FlapWindow results; flap detection is disabled when either is 0.
 type HealthChecksConfig struct {
-	Enabled  bool          `koanf:"enabled"`
-	Interval time.Duration `koanf:"interval" validate:"min=1s"`
-	Timeout  time.Duration `koanf:"timeout"  validate:"min=1s"`
-	Checks   []string      `koanf:"checks"`
+	Enabled       bool          `koanf:"enabled"`
+	Interval      time.Duration `koanf:"interval"       validate:"min=1s"`
+	Timeout       time.Duration `koanf:"timeout"        validate:"min=1s"`
+	Checks        []string      `koanf:"checks"`
+	HistorySize   int           `koanf:"history_size"   validate:"min=0"`
+	HistoryStore  string        `koanf:"history_store"  validate:"omitempty,oneof=memory redis"`
+	FlapWindow    int           `koanf:"flap_window"    validate:"min=0"`
+	FlapThreshold int           `koanf:"flap_threshold" validate:"min=0"`
+}
+
+// MetricsConfig holds the configuration for OpenTelemetry metrics.
+// Metrics are pushed over OTLP/HTTP to Endpoint, e.g. an OpenTelemetry
+// Collector or Grafana Mimir. DurationBuckets overrides the histogram
+// bucket boundaries, in seconds, of every duration instrument.
+type MetricsConfig struct {
+	Enabled         bool              `koanf:"enabled"`
+	Endpoint        string            `koanf:"endpoint"`
+	Insecure        bool              `koanf:"insecure"`
+	Headers         map[string]string `koanf:"headers"`
+	Interval        time.Duration     `koanf:"interval"`
+	DurationBuckets []float64         `koanf:"duration_buckets"`
+}
+
+// PrometheusConfig holds the configuration for the Prometheus metrics
+// endpoint, served at Path, /metrics by default, for scraping alongside the
+// OTLP export. DurationBuckets overrides the bucket boundaries, in seconds,
+// of the HTTP request duration histogram.
+type PrometheusConfig struct {
+	Enabled         bool      `koanf:"enabled"`
+	Path            string    `koanf:"path"`
+	DurationBuckets []float64 `koanf:"duration_buckets"`
+}
+
+// EventsConfig holds the configuration for business events recorded with
+// telemetry.RecordEvent. Up to Buffer events are kept in memory and sent at
+// most Rate per second, to New Relic custom events when New Relic is
+// configured and to the logs otherwise.
+type EventsConfig struct {
+	Enabled bool    `koanf:"enabled"`
+	Buffer  int     `koanf:"buffer"  validate:"min=1"`
+	Rate    float64 `koanf:"rate"    validate:"gt=0"`
+}
+
+// ErrorsConfig holds the configuration for errors reported with
+// telemetry.ReportError. Repeated errors, sharing a code and a location, are
+// merged and sent once per Interval with their count, to New Relic when it
+// is configured and to the logs otherwise. Beyond MaxPerInterval distinct
+// errors per interval, errors are only counted, so an error storm cannot
+// exhaust the APM quotas.
+type ErrorsConfig struct {
+	Enabled        bool          `koanf:"enabled"`
+	Interval       time.Duration `koanf:"interval"         validate:"min=0"`
+	MaxPerInterval int           `koanf:"max_per_interval" validate:"min=0"`
 }
 
 // DefaultObservabilityConfig returns a default configuration for observability features
@@ -54,6 +216,14 @@ func DefaultObservabilityConfig() *ObservabilityConfig {
 			Level:              "info",
 			Format:             "json",
 			SlowQueryThreshold: slowQueryThreshold,
+			Redact:             LoggingRedactConfig{Enabled: true},
+			Output:             []string{LogOutputStdout},
+			File: LoggingFileConfig{
+				MaxSize:    logFileMaxSize,
+				MaxAge:     logFileMaxAge,
+				MaxBackups: logFileMaxBackups,
+				Compress:   true,
+			},
 		},
 		NewRelic: NewRelicConfig{
 			LicenseKey:                "",
@@ -61,11 +231,37 @@ func DefaultObservabilityConfig() *ObservabilityConfig {
 			DistributedTracingEnabled: true,
 			DebugLogging:              false,
 		},
+		Sentry: SentryConfig{
+			SampleRate: 1,
+		},
 		HealthChecks: HealthChecksConfig{
-			Enabled:  true,
-			Interval: healthCheckInterval,
-			Timeout:  healthCheckTimeout,
-			Checks:   []string{"database", "redis"},
+			Enabled:       true,
+			Interval:      healthCheckInterval,
+			Timeout:       healthCheckTimeout,
+			Checks:        []string{"database", "database_pool", "replication_lag", "redis", "nats"},
+			HistorySize:   healthCheckHistory,
+			HistoryStore:  "memory",
+			FlapWindow:    healthCheckFlaps,
+			FlapThreshold: healthCheckChanges,
+		},
+		Metrics: MetricsConfig{
+			Enabled:  false,
+			Endpoint: "localhost:4318",
+			Interval: metricsInterval,
+		},
+		Prometheus: PrometheusConfig{
+			Enabled: false,
+			Path:    "/metrics",
+		},
+		Events: EventsConfig{
+			Enabled: true,
+			Buffer:  eventsBuffer,
+			Rate:    eventsRate,
+		},
+		Errors: ErrorsConfig{
+			Enabled:        true,
+			Interval:       errorsInterval,
+			MaxPerInterval: errorsMaxPerInterval,
 		},
 	}
 }
@@ -89,6 +285,26 @@ func (c *ObservabilityConfig) Validate() error {
 		return errors.New("logging slow_query_threshold must be non-negative")
 	}
 
+	// Validate redact patterns
+	if _, err := c.Logging.Redact.CompilePatterns(); err != nil {
+		return fmt.Errorf("logging %w", err)
+	}
+
+	// Validate log outputs
+	if slices.Contains(c.Logging.Output, LogOutputFile) && c.Logging.File.Path == "" {
+		return errors.New("logging file path is required with the file output")
+	}
+
+	// Validate metrics export
+	if c.Metrics.Enabled && c.Metrics.Endpoint == "" {
+		return errors.New("metrics endpoint is required when metrics are enabled")
+	}
+
+	// Validate Prometheus endpoint
+	if c.Prometheus.Path != "" && !strings.HasPrefix(c.Prometheus.Path, "/") {
+		return errors.New("prometheus path must start with /")
+	}
+
 	return nil
 }
 
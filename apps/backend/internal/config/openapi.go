@@ -0,0 +1,14 @@
+package config
+
+// OpenAPIConfig holds the configuration of the contract validation of
+// requests and responses against the OpenAPI spec, which catches drift
+// between the handlers and the spec during development and tests. Mismatches
+// are logged, or rejected when Mode is reject: requests with 400 Bad Request
+// and responses with 500 Internal Server Error. Responses are buffered to be
+// validated, so they are only validated when ValidateResponses is set.
+// Validation is never enabled in production.
+type OpenAPIConfig struct {
+	Validate          bool   `koanf:"validate"`
+	Mode              string `koanf:"mode"               validate:"omitempty,oneof=log reject"`
+	ValidateResponses bool   `koanf:"validate_responses"`
+}
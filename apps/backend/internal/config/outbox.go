@@ -0,0 +1,21 @@
+package config
+
+import "time"
+
+// OutboxConfig holds the configuration of the outbox dispatcher, which
+// publishes the events recorded in the outbox table to Broker. It claims up
+// to BatchSize events every PollInterval, for Lease. Events that fail to
+// publish are retried after MinBackoff, doubling up to MaxBackoff. With the
+// redis broker, events are added to the stream StreamPrefix+topic, trimmed
+// to about StreamMaxLen entries when set.
+type OutboxConfig struct {
+	Enabled      bool          `koanf:"enabled"`
+	Broker       string        `koanf:"broker"         validate:"omitempty,oneof=log redis"`
+	StreamPrefix string        `koanf:"stream_prefix"`
+	StreamMaxLen int64         `koanf:"stream_max_len"`
+	BatchSize    int           `koanf:"batch_size"`
+	PollInterval time.Duration `koanf:"poll_interval"`
+	Lease        time.Duration `koanf:"lease"`
+	MinBackoff   time.Duration `koanf:"min_backoff"`
+	MaxBackoff   time.Duration `koanf:"max_backoff"`
+}
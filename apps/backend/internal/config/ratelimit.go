@@ -0,0 +1,33 @@
+package config
+
+import "time"
+
+const (
+	defaultRateLimitRequests = 20          // Default number of requests allowed per window
+	defaultRateLimitWindow   = time.Second // Default rate limit window
+)
+
+// RateLimitConfig holds the configuration for request rate limiting.
+// KeyBy "user_id" limits each user of a valid bearer token, and anonymous
+// requests by IP.
+type RateLimitConfig struct {
+	Enabled   bool          `koanf:"enabled"`
+	Store     string        `koanf:"store"     validate:"required,oneof=memory redis"`
+	Algorithm string        `koanf:"algorithm" validate:"required,oneof=token_bucket sliding_window"`
+	KeyBy     string        `koanf:"key_by"    validate:"required,oneof=ip api_key user_id"`
+	Requests  int           `koanf:"requests"  validate:"required,gt=0"`
+	Window    time.Duration `koanf:"window"    validate:"required,gt=0"`
+}
+
+// DefaultRateLimitConfig returns a default rate limit configuration
+// allowing 20 requests per second per client IP using an in-memory store.
+func DefaultRateLimitConfig() *RateLimitConfig {
+	return &RateLimitConfig{
+		Enabled:   true,
+		Store:     "memory",
+		Algorithm: "token_bucket",
+		KeyBy:     "ip",
+		Requests:  defaultRateLimitRequests,
+		Window:    defaultRateLimitWindow,
+	}
+}
@@ -0,0 +1,17 @@
+package config
+
+import "time"
+
+// ScannerConfig holds the configuration for scanning uploaded files for malware.
+// Driver is "none" to accept files without scanning, or "clamav".
+type ScannerConfig struct {
+	Driver string       `koanf:"driver" validate:"omitempty,oneof=none clamav"`
+	ClamAV ClamAVConfig `koanf:"clamav"`
+}
+
+// ClamAVConfig holds the configuration of the clamd daemon.
+// Address is host:port for TCP or an absolute path for a Unix socket.
+type ClamAVConfig struct {
+	Address string        `koanf:"address"`
+	Timeout time.Duration `koanf:"timeout"`
+}
@@ -0,0 +1,127 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/PrinceNarteh/go-boilerplate/internal/libs/cron"
+)
+
+// SchedulerConfig holds the configuration of scheduled jobs. Jobs are
+// declared in the JSON file at File rather than in the environment, so
+// they can be changed and reloaded with SIGHUP without a restart.
+type SchedulerConfig struct {
+	Enabled bool   `koanf:"enabled"`
+	File    string `koanf:"file"    validate:"required_if=Enabled true"`
+	// Lock runs each activation of a job on one instance only, the first
+	// to take its lock in Redis
+	Lock bool `koanf:"lock"`
+	// Jobs are loaded from File by LoadConfig
+	Jobs []JobConfig `koanf:"-"`
+}
+
+// JobConfig declares a scheduled job. Job names the registered handler,
+// the declaration name when empty, so one handler can back several
+// declarations, such as a retention policy per table. Jobs are enabled
+// unless Enabled is explicitly false. Jitter, a Go duration such as "30s",
+// delays each run by a random time up to it, to spread the load of jobs
+// sharing a schedule.
+//
+// Example:
+//
+//	[
+//	  {"name": "purge-security-events", "job": "retention", "cron": "0 3 * * *",
+//	   "args": {"table": "security_events", "column": "occurred_at", "max_age": "2160h"}}
+//	]
+type JobConfig struct {
+	Name    string         `json:"name"`
+	Job     string         `json:"job,omitempty"`
+	Cron    string         `json:"cron"`
+	Jitter  string         `json:"jitter,omitempty"`
+	Enabled *bool          `json:"enabled,omitempty"`
+	Args    map[string]any `json:"args,omitempty"`
+}
+
+// Handler returns the name of the handler running the job
+func (j JobConfig) Handler() string {
+	if j.Job == "" {
+		return j.Name
+	}
+	return j.Job
+}
+
+// IsEnabled reports whether the job should run
+func (j JobConfig) IsEnabled() bool {
+	return j.Enabled == nil || *j.Enabled
+}
+
+// JitterDuration returns the maximum random delay of each run
+func (j JobConfig) JitterDuration() time.Duration {
+	// Validated by ValidateJobs
+	jitter, _ := time.ParseDuration(j.Jitter)
+	return jitter
+}
+
+// LoadJobs reads and validates the job declarations of a scheduler file.
+// Every invalid declaration is reported, each with its name and position.
+func LoadJobs(path string) ([]JobConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read jobs file: %w", err)
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+
+	var jobs []JobConfig
+	if err := decoder.Decode(&jobs); err != nil {
+		var syntaxErr *json.SyntaxError
+		if errors.As(err, &syntaxErr) {
+			line := bytes.Count(data[:syntaxErr.Offset], []byte("\n")) + 1
+			return nil, fmt.Errorf("jobs file %s: line %d: %w", path, line, err)
+		}
+		return nil, fmt.Errorf("jobs file %s: %w", path, err)
+	}
+
+	if err := ValidateJobs(jobs); err != nil {
+		return nil, fmt.Errorf("jobs file %s: %w", path, err)
+	}
+	return jobs, nil
+}
+
+// ValidateJobs checks that every job has a unique name, a cron expression
+// that parses and can match, and a valid jitter
+func ValidateJobs(jobs []JobConfig) error {
+	var errList []error
+	seen := make(map[string]bool, len(jobs))
+	for i, job := range jobs {
+		if job.Name == "" {
+			errList = append(errList, fmt.Errorf("job #%d: name is required", i+1))
+			continue
+		}
+		if seen[job.Name] {
+			errList = append(errList, fmt.Errorf("job %q: name is declared more than once", job.Name))
+		}
+		seen[job.Name] = true
+
+		if job.Jitter != "" {
+			if jitter, err := time.ParseDuration(job.Jitter); err != nil || jitter < 0 {
+				errList = append(errList, fmt.Errorf("job %q: invalid jitter %q", job.Name, job.Jitter))
+			}
+		}
+
+		schedule, err := cron.Parse(job.Cron)
+		if err != nil {
+			errList = append(errList, fmt.Errorf("job %q: %w", job.Name, err))
+			continue
+		}
+		if _, err := schedule.Next(time.Now()); err != nil {
+			errList = append(errList, fmt.Errorf("job %q: cron %q: %w", job.Name, job.Cron, err))
+		}
+	}
+	return errors.Join(errList...)
+}
@@ -0,0 +1,31 @@
+package config
+
+const defaultHSTSMaxAge = 63072000 // Two years, in seconds
+
+// SecurityHeadersConfig holds the configuration for HTTP security response headers.
+// Empty values disable the corresponding header.
+type SecurityHeadersConfig struct {
+	ContentSecurityPolicy string `koanf:"content_security_policy"`
+	HSTSMaxAge            int    `koanf:"hsts_max_age"            validate:"gte=0"`
+	HSTSIncludeSubdomains bool   `koanf:"hsts_include_subdomains"`
+	HSTSPreload           bool   `koanf:"hsts_preload"`
+	FrameOptions          string `koanf:"frame_options"           validate:"omitempty,oneof=DENY SAMEORIGIN"`
+	ReferrerPolicy        string `koanf:"referrer_policy"`
+	PermissionsPolicy     string `koanf:"permissions_policy"`
+	ContentTypeNosniff    bool   `koanf:"content_type_nosniff"`
+}
+
+// DefaultSecurityHeadersConfig returns a strict default configuration suitable
+// for a JSON API that never serves HTML to browsers.
+func DefaultSecurityHeadersConfig() *SecurityHeadersConfig {
+	return &SecurityHeadersConfig{
+		ContentSecurityPolicy: "default-src 'none'; frame-ancestors 'none'",
+		HSTSMaxAge:            defaultHSTSMaxAge,
+		HSTSIncludeSubdomains: true,
+		HSTSPreload:           false,
+		FrameOptions:          "DENY",
+		ReferrerPolicy:        "no-referrer",
+		PermissionsPolicy:     "camera=(), microphone=(), geolocation=(), payment=()",
+		ContentTypeNosniff:    true,
+	}
+}
@@ -0,0 +1,13 @@
+package config
+
+import "time"
+
+// StatusPageConfig holds the configuration of the public status page,
+// reporting the uptime of each component over UptimeWindow. The incident
+// declared by administrators is kept in memory or in Redis, as set by
+// IncidentStore; Redis shares it between the instances of the application.
+type StatusPageConfig struct {
+	Enabled       bool          `koanf:"enabled"`
+	UptimeWindow  time.Duration `koanf:"uptime_window"`
+	IncidentStore string        `koanf:"incident_store" validate:"omitempty,oneof=memory redis"`
+}
@@ -0,0 +1,13 @@
+package config
+
+// TenancyConfig holds the configuration of schema-per-tenant isolation.
+// When enabled, the authenticated requests of tokens issued in a tenant run
+// their queries in the PostgreSQL schema SchemaPrefix+ID of that tenant,
+// while other requests use the public schema. Clients may name their tenant
+// in Header, and requests naming another tenant than the one of their token
+// are forbidden.
+type TenancyConfig struct {
+	Enabled      bool   `koanf:"enabled"`
+	Header       string `koanf:"header"`
+	SchemaPrefix string `koanf:"schema_prefix"`
+}
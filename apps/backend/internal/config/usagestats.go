@@ -0,0 +1,11 @@
+package config
+
+import "time"
+
+// UsageStatsConfig holds the configuration for anonymous usage statistics.
+// Reporting is off unless Enabled is explicitly set to true.
+type UsageStatsConfig struct {
+	Enabled  bool          `koanf:"enabled"`
+	Endpoint string        `koanf:"endpoint" validate:"required_if=Enabled true,omitempty,url"`
+	Interval time.Duration `koanf:"interval"`
+}
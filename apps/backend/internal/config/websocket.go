@@ -0,0 +1,17 @@
+package config
+
+import "time"
+
+// WebSocketConfig holds the configuration of WebSocket connections.
+// Connections are pinged every nine tenths of PongTimeout and closed when
+// no pong arrives in time. Writes fail after WriteTimeout, messages from
+// clients are limited to MaxMessageBytes, and up to SendBuffer messages
+// wait for a slow client before it is disconnected.
+type WebSocketConfig struct {
+	Enabled         bool          `koanf:"enabled"`
+	Path            string        `koanf:"path"`
+	PongTimeout     time.Duration `koanf:"pong_timeout"`
+	WriteTimeout    time.Duration `koanf:"write_timeout"`
+	MaxMessageBytes int64         `koanf:"max_message_bytes"`
+	SendBuffer      int           `koanf:"send_buffer"`
+}
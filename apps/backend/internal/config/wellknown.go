@@ -0,0 +1,26 @@
+package config
+
+import "time"
+
+const defaultSecurityTxtExpiry = 365 * 24 * time.Hour // security.txt must be refreshed at least yearly
+
+// WellKnownConfig holds the configuration for robots.txt, favicon and
+// /.well-known endpoints. Endpoints whose settings are empty are not registered.
+type WellKnownConfig struct {
+	RobotsTxt          string        `koanf:"robots_txt"`
+	FaviconFile        string        `koanf:"favicon_file"`
+	SecurityContact    string        `koanf:"security_contact"`
+	SecurityPolicyURL  string        `koanf:"security_policy_url"  validate:"omitempty,url"`
+	SecurityTxtExpires time.Duration `koanf:"security_txt_expires"`
+	ChangePasswordURL  string        `koanf:"change_password_url"  validate:"omitempty,url"`
+}
+
+// DefaultWellKnownConfig returns a default configuration that disallows all
+// crawlers, answers favicon requests with an empty response and leaves the
+// security contact and change-password URL unset.
+func DefaultWellKnownConfig() *WellKnownConfig {
+	return &WellKnownConfig{
+		RobotsTxt:          "User-agent: *\nDisallow: /\n",
+		SecurityTxtExpires: defaultSecurityTxtExpiry,
+	}
+}
@@ -3,29 +3,37 @@ package database
 import (
 	"context"
 	"fmt"
-	"net"
-	"net/url"
+	"sync/atomic"
 	"time"
 
 	pgxzero "github.com/jackc/pgx-zerolog"
 	pgx "github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/jackc/pgx/v5/tracelog"
-	"github.com/newrelic/go-agent/v3/integrations/nrpgx5"
 	"github.com/rs/zerolog"
 
 	"github.com/PrinceNarteh/go-boilerplate/internal/config"
+	"github.com/PrinceNarteh/go-boilerplate/internal/libs/retry"
 	loggerConfig "github.com/PrinceNarteh/go-boilerplate/internal/logger"
 )
 
 // DatabasePingTimeout is the timeout duration for pinging the database
 const DatabasePingTimeout = 10
 
+// connectAttempts is the number of times the primary is pinged on startup,
+// so the application survives the database starting at the same time
+const connectAttempts = 5
+
 // Database represents a PostgreSQL database connection pool
 // It holds a connection pool and a logger for logging database operations.
+// Pool is the primary; read replicas, when configured, are reached through ReadPool.
 type Database struct {
 	Pool *pgxpool.Pool
 	log  *zerolog.Logger
+
+	replicas   []*replica
+	next       atomic.Uint64
+	stopChecks func()
 }
 
 // multiTracer allows chaining multiple tracers
@@ -62,33 +70,93 @@ func (mt *multiTracer) TraceQueryEnd(ctx context.Context, conn *pgx.Conn, data p
 
 // New creates a new Database instance with a connection pool
 // It initializes the connection pool with the provided configuration and logger.
-// It also sets up New Relic instrumentation if a logger service is provided.
-func New(cfg *config.Config, logger *zerolog.Logger, loggerService *loggerConfig.LoggerService) (*Database, error) {
-	hostPort := net.JoinHostPort(cfg.Database.Host, cfg.Database.Port)
-
-	// URL-encode the password
-	encodedPassword := url.QueryEscape(cfg.Database.Password)
-	dsn := fmt.Sprintf("postgres://%s:%s@%s/%s?sslmode=%s",
-		cfg.Database.User,
-		encodedPassword,
-		hostPort,
-		cfg.Database.Name,
-		cfg.Database.SSLMode,
-	)
+// It also sets up New Relic instrumentation if a logger service is provided,
+// and chains any additional tracers, such as query metrics, after it.
+// A pool is also opened for every configured read replica; replicas that
+// cannot be reached are marked unhealthy rather than failing startup.
+func New(
+	cfg *config.Config,
+	logger *zerolog.Logger,
+	loggerService *loggerConfig.LoggerService,
+	tracers ...pgx.QueryTracer,
+) (*Database, error) {
+	pool, err := newPool(cfg, connString(cfg), logger, loggerService, tracers)
+	if err != nil {
+		return nil, err
+	}
+
+	database := &Database{
+		Pool: pool,
+		log:  logger,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), DatabasePingTimeout*time.Second)
+	defer cancel()
+	err = retry.Do(ctx, retry.Policy{
+		MaxAttempts: connectAttempts,
+		OnRetry: func(attempt int, err error, delay time.Duration) {
+			logger.Warn().Err(err).Int("attempt", attempt).Dur("retry_in", delay).Msg("database not reachable, retrying")
+		},
+	}, pool.Ping)
+	if err != nil {
+		pool.Close()
+		return nil, fmt.Errorf("failed to ping database: %w", err)
+	}
+
+	logger.Info().Msg("connected to the database")
+
+	for _, addr := range cfg.Database.Replicas {
+		if addr == "" {
+			continue
+		}
+		addr = replicaAddr(addr, cfg.Database.Port)
+		replicaPool, err := newPool(cfg, replicaConnString(cfg, addr), logger, loggerService, tracers)
+		if err != nil {
+			database.Close()
+			return nil, fmt.Errorf("failed to create replica pool for %s: %w", addr, err)
+		}
+
+		r := &replica{addr: addr, pool: replicaPool}
+		r.healthy.Store(replicaPool.Ping(ctx) == nil)
+		if !r.healthy.Load() {
+			logger.Warn().Str("replica", addr).Msg("read replica unreachable, reads will use other pools")
+		}
+		database.replicas = append(database.replicas, r)
+	}
 
+	if len(database.replicas) > 0 {
+		interval := cfg.Database.ReplicaCheckInterval
+		if interval <= 0 {
+			interval = defaultReplicaCheckInterval
+		}
+		database.stopChecks = database.checkReplicas(interval)
+		logger.Info().Int("replicas", len(database.replicas)).Msg("connected to the read replicas")
+	}
+
+	return database, nil
+}
+
+// newPool creates a connection pool for dsn with the application's tracers
+func newPool(
+	cfg *config.Config,
+	dsn string,
+	logger *zerolog.Logger,
+	loggerService *loggerConfig.LoggerService,
+	tracers []pgx.QueryTracer,
+) (*pgxpool.Pool, error) {
 	pgxPoolConfig, err := pgxpool.ParseConfig(dsn)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse pgx pool config: %w", err)
 	}
 
 	// Add New Relic PostgreSQL instrumentation
-	if loggerService != nil && loggerService.GetApplication() != nil {
-		pgxPoolConfig.ConnConfig.Tracer = nrpgx5.NewTracer()
+	if loggerService != nil && loggerService.GetTracer() != nil {
+		pgxPoolConfig.ConnConfig.Tracer = newRelicTracer()
 	}
 
 	if cfg.Core.Env == "local" {
-		globalLevel := logger.GetLevel()
-		pgxLogger := loggerConfig.NewPgxLogger(globalLevel)
+		globalLevel := loggerConfig.Level()
+		pgxLogger := loggerConfig.NewPgxLogger(globalLevel, cfg.Observability.Logging.Redact)
 		// Chain tracers - New Relic first, then local logging
 		if pgxPoolConfig.ConnConfig.Tracer != nil {
 			// If New Relic tracer exists, create a multi-tracer
@@ -107,25 +175,29 @@ func New(cfg *config.Config, logger *zerolog.Logger, loggerService *loggerConfig
 		}
 	}
 
-	pool, err := pgxpool.NewWithConfig(context.Background(), pgxPoolConfig)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create pgx pool: %w", err)
+	// Run the queries of tenants in their schema
+	if cfg.Tenancy.Enabled {
+		enableTenancy(pgxPoolConfig)
 	}
 
-	database := &Database{
-		Pool: pool,
-		log:  logger,
+	// Chain additional tracers after the built-in ones
+	for _, tracer := range tracers {
+		switch current := pgxPoolConfig.ConnConfig.Tracer.(type) {
+		case nil:
+			pgxPoolConfig.ConnConfig.Tracer = tracer
+		case *multiTracer:
+			current.tracers = append(current.tracers, tracer)
+		default:
+			pgxPoolConfig.ConnConfig.Tracer = &multiTracer{tracers: []any{current, tracer}}
+		}
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), DatabasePingTimeout*time.Second)
-	defer cancel()
-	if err = pool.Ping(ctx); err != nil {
-		return nil, fmt.Errorf("failed to ping database: %w", err)
+	pool, err := pgxpool.NewWithConfig(context.Background(), pgxPoolConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create pgx pool: %w", err)
 	}
 
-	logger.Info().Msg("connected to the database")
-
-	return database, nil
+	return pool, nil
 }
 
 // Close closes the database connection pool
@@ -134,6 +206,13 @@ func New(cfg *config.Config, logger *zerolog.Logger, loggerService *loggerConfig
 // It is safe to call this method multiple times.
 func (db *Database) Close() error {
 	db.log.Info().Msg("closing database connection pool")
+	if db.stopChecks != nil {
+		db.stopChecks()
+		db.stopChecks = nil
+	}
+	for _, r := range db.replicas {
+		r.pool.Close()
+	}
 	db.Pool.Close()
 	return nil
 }
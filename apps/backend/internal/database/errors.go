@@ -0,0 +1,78 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+
+	pgx "github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"github.com/PrinceNarteh/go-boilerplate/internal/errs"
+)
+
+const (
+	// uniqueViolation is the PostgreSQL error code for unique constraint violations
+	uniqueViolation = "23505"
+	// connectionExceptionClass is the PostgreSQL error class of connection exceptions
+	connectionExceptionClass = "08"
+)
+
+// unavailableCodes are the PostgreSQL error codes sent when the server shuts
+// down or is not yet accepting connections
+var unavailableCodes = map[string]bool{
+	"57P01": true, // admin_shutdown
+	"57P02": true, // crash_shutdown
+	"57P03": true, // cannot_connect_now
+}
+
+// TranslateError maps database errors to domain errors: pgx.ErrNoRows to
+// errs.ErrNotFound, unique violations to errs.ErrConflict and connection
+// errors to errs.ErrUnavailable. The original error stays in the chain, so
+// errors.Is(err, pgx.ErrNoRows) and IsUniqueViolation still hold. Other
+// errors are returned unchanged.
+func TranslateError(err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, errs.ErrNotFound), errors.Is(err, errs.ErrConflict), errors.Is(err, errs.ErrUnavailable):
+		return err
+	case errors.Is(err, pgx.ErrNoRows):
+		return fmt.Errorf("%w: %w", errs.ErrNotFound, err)
+	case IsUniqueViolation(err):
+		return fmt.Errorf("%w: %w", errs.ErrConflict, err)
+	case IsConnectionError(err):
+		return fmt.Errorf("%w: %w", errs.ErrUnavailable, err)
+	default:
+		return err
+	}
+}
+
+// IsUniqueViolation reports whether err was caused by a unique constraint violation
+func IsUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == uniqueViolation
+}
+
+// IsConnectionError reports whether err was caused by a broken or refused
+// connection to the database rather than by the query itself
+func IsConnectionError(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return strings.HasPrefix(pgErr.Code, connectionExceptionClass) || unavailableCodes[pgErr.Code]
+	}
+
+	var connectErr *pgconn.ConnectError
+	var netErr net.Error
+	return errors.As(err, &connectErr) ||
+		errors.As(err, &netErr) ||
+		errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF)
+}
@@ -1,5 +1,4 @@
 -- Initial database schema
--- ---- +migrate Up
 -- This migration creates the initial tables for the application
 
 -- Example table (you can customize this according to your needs)
@@ -10,6 +9,7 @@ CREATE TABLE IF NOT EXISTS users (
     updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
 );
 
--- ---- +migrate Down
+---- create above / drop below ----
+
 -- This migration removes the initial tables
 DROP TABLE IF EXISTS users;
@@ -0,0 +1,19 @@
+-- Saga instances persisted by the saga coordinator so that multi-step
+-- operations can be resumed or compensated after a crash.
+CREATE TABLE IF NOT EXISTS sagas (
+    id UUID PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    status VARCHAR(32) NOT NULL,
+    step INTEGER NOT NULL DEFAULT 0,
+    data JSONB NOT NULL DEFAULT '{}',
+    error TEXT NOT NULL DEFAULT '',
+    locked_until TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_sagas_status ON sagas (status);
+
+---- create above / drop below ----
+
+DROP TABLE IF EXISTS sagas;
@@ -0,0 +1,25 @@
+-- Versioned legal documents (terms of service, privacy policy, ...) and the
+-- acceptances recorded for each user.
+CREATE TABLE IF NOT EXISTS consent_documents (
+    id SERIAL PRIMARY KEY,
+    kind VARCHAR(64) NOT NULL,
+    version VARCHAR(64) NOT NULL,
+    url TEXT NOT NULL,
+    published_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (kind, version)
+);
+
+CREATE TABLE IF NOT EXISTS user_consents (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    document_id INTEGER NOT NULL REFERENCES consent_documents (id),
+    ip VARCHAR(45) NOT NULL,
+    user_agent TEXT NOT NULL DEFAULT '',
+    accepted_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (user_id, document_id)
+);
+
+---- create above / drop below ----
+
+DROP TABLE IF EXISTS user_consents;
+DROP TABLE IF EXISTS consent_documents;
@@ -0,0 +1,6 @@
+-- Supports keyset pagination of users ordered by creation time
+CREATE INDEX IF NOT EXISTS idx_users_created_at_id ON users (created_at DESC, id DESC);
+
+---- create above / drop below ----
+
+DROP INDEX IF EXISTS idx_users_created_at_id;
@@ -0,0 +1,23 @@
+-- Pending and completed email address changes. A change is confirmed with a
+-- token sent to the new address and can be reverted from the old address
+-- until revert_until.
+CREATE TABLE IF NOT EXISTS email_changes (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    old_email VARCHAR(255) NOT NULL,
+    new_email VARCHAR(255) NOT NULL,
+    status VARCHAR(16) NOT NULL DEFAULT 'pending',
+    token_hash CHAR(64) NOT NULL UNIQUE,
+    revert_token_hash CHAR(64) UNIQUE,
+    expires_at TIMESTAMP NOT NULL,
+    confirmed_at TIMESTAMP,
+    revert_until TIMESTAMP,
+    reverted_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_email_changes_user_status ON email_changes (user_id, status);
+
+---- create above / drop below ----
+
+DROP TABLE IF EXISTS email_changes;
@@ -0,0 +1,13 @@
+-- Soft delete for users: deleted users keep their row with deleted_at set.
+-- Email uniqueness only applies to users that are not deleted, so that an
+-- address can be registered again after its user was deleted.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP;
+
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_email_key;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_email_active ON users (email) WHERE deleted_at IS NULL;
+
+---- create above / drop below ----
+
+DROP INDEX IF EXISTS idx_users_email_active;
+ALTER TABLE users ADD CONSTRAINT users_email_key UNIQUE (email);
+ALTER TABLE users DROP COLUMN IF EXISTS deleted_at;
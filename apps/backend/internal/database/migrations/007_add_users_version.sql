@@ -0,0 +1,7 @@
+-- Optimistic locking for users: version is incremented on every change and
+-- updates only apply when the version read by the client is still current
+ALTER TABLE users ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 1;
+
+---- create above / drop below ----
+
+ALTER TABLE users DROP COLUMN IF EXISTS version;
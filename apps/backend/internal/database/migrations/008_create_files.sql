@@ -0,0 +1,23 @@
+-- Uploaded files. Content lives in the storage backend under storage_key;
+-- only files with scan_status 'clean' may be served. Quarantined files are
+-- kept for inspection, and pending files are rescanned in the background.
+CREATE TABLE IF NOT EXISTS files (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    filename VARCHAR(255) NOT NULL,
+    content_type VARCHAR(255) NOT NULL,
+    size BIGINT NOT NULL,
+    storage_key VARCHAR(255) NOT NULL UNIQUE,
+    scan_status VARCHAR(16) NOT NULL DEFAULT 'pending',
+    scan_threat VARCHAR(255) NOT NULL DEFAULT '',
+    scanned_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_files_user_id ON files (user_id);
+CREATE INDEX IF NOT EXISTS idx_files_scan_pending ON files (created_at) WHERE scan_status = 'pending';
+
+---- create above / drop below ----
+
+DROP TABLE IF EXISTS files;
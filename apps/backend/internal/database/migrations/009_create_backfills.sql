@@ -0,0 +1,16 @@
+-- Progress of the backfills run during zero-downtime schema refactors.
+-- last_id is the highest ID already backfilled and max_id the highest ID
+-- present when the backfill started.
+CREATE TABLE IF NOT EXISTS backfills (
+    name VARCHAR(255) PRIMARY KEY,
+    last_id INTEGER NOT NULL DEFAULT 0,
+    max_id INTEGER NOT NULL DEFAULT 0,
+    rows BIGINT NOT NULL DEFAULT 0,
+    started_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    completed_at TIMESTAMP
+);
+
+---- create above / drop below ----
+
+DROP TABLE IF EXISTS backfills;
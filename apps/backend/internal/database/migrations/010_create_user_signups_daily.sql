@@ -0,0 +1,24 @@
+-- Materialized views are defined in migrations like tables, and refreshed at
+-- runtime by the matview scheduler, which records each successful refresh in
+-- materialized_view_refreshes so that staleness is shared across instances.
+CREATE TABLE IF NOT EXISTS materialized_view_refreshes (
+    name VARCHAR(255) PRIMARY KEY,
+    refreshed_at TIMESTAMP NOT NULL,
+    duration_ms INTEGER NOT NULL
+);
+
+-- Daily signups for reporting endpoints. The unique index allows the view
+-- to be refreshed concurrently, without blocking readers.
+CREATE MATERIALIZED VIEW IF NOT EXISTS user_signups_daily AS
+SELECT date_trunc('day', created_at)::date AS day, COUNT(*) AS signups
+FROM users
+WHERE deleted_at IS NULL
+GROUP BY 1
+WITH NO DATA;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_user_signups_daily_day ON user_signups_daily (day);
+
+---- create above / drop below ----
+
+DROP MATERIALIZED VIEW IF EXISTS user_signups_daily;
+DROP TABLE IF EXISTS materialized_view_refreshes;
@@ -0,0 +1,18 @@
+-- Runs of operational tasks started from the admin API
+CREATE TABLE IF NOT EXISTS task_runs (
+    id UUID PRIMARY KEY,
+    task VARCHAR(255) NOT NULL,
+    args JSONB,
+    status VARCHAR(32) NOT NULL,
+    error TEXT NOT NULL DEFAULT '',
+    requested_by INTEGER NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    started_at TIMESTAMP,
+    finished_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_task_runs_created_at ON task_runs (created_at DESC);
+
+---- create above / drop below ----
+
+DROP TABLE IF EXISTS task_runs;
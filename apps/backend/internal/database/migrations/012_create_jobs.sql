@@ -0,0 +1,22 @@
+-- Background jobs queued for the workers when the job queue store is postgres.
+-- Dead jobs used all their attempts and are kept for inspection.
+CREATE TABLE IF NOT EXISTS jobs (
+    id UUID PRIMARY KEY,
+    type VARCHAR(255) NOT NULL,
+    payload JSONB NOT NULL DEFAULT '{}',
+    status VARCHAR(32) NOT NULL DEFAULT 'pending',
+    attempt INTEGER NOT NULL DEFAULT 0,
+    max_attempts INTEGER NOT NULL,
+    last_error TEXT NOT NULL DEFAULT '',
+    run_at TIMESTAMP NOT NULL,
+    locked_until TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_jobs_pending_run_at ON jobs (run_at) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS idx_jobs_running_locked_until ON jobs (locked_until) WHERE status = 'running';
+
+---- create above / drop below ----
+
+DROP TABLE IF EXISTS jobs;
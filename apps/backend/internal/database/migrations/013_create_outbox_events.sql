@@ -0,0 +1,24 @@
+-- Domain events recorded in the transaction of the changes they describe,
+-- published to the message broker by the outbox dispatcher. Published events
+-- are kept for inspection until purged, e.g. by a retention job on published_at.
+CREATE TABLE IF NOT EXISTS outbox_events (
+    id UUID PRIMARY KEY,
+    topic VARCHAR(255) NOT NULL,
+    partition_key VARCHAR(255) NOT NULL DEFAULT '',
+    event_type VARCHAR(255) NOT NULL,
+    payload JSONB NOT NULL DEFAULT '{}',
+    attempts INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT NOT NULL DEFAULT '',
+    available_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    locked_until TIMESTAMP,
+    published_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_outbox_events_pending ON outbox_events (id) WHERE published_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_outbox_events_pending_key ON outbox_events (partition_key, id) WHERE published_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_outbox_events_published_at ON outbox_events (published_at) WHERE published_at IS NOT NULL;
+
+---- create above / drop below ----
+
+DROP TABLE IF EXISTS outbox_events;
@@ -0,0 +1,18 @@
+-- Registry of the tenants isolated in their own schema. It always lives in
+-- the public schema: tenant schemas run this migration too, as a no-op.
+CREATE TABLE IF NOT EXISTS public.tenants (
+    id VARCHAR(48) PRIMARY KEY,
+    schema_name VARCHAR(63) UNIQUE NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+---- create above / drop below ----
+
+-- Rolling back a tenant schema keeps the registry
+DO $$
+BEGIN
+    IF current_schema() = 'public' THEN
+        DROP TABLE IF EXISTS tenants;
+    END IF;
+END
+$$;
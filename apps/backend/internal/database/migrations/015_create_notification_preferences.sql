@@ -0,0 +1,15 @@
+-- The channels users chose for each kind of notification. Only the choices
+-- of users are stored, kinds and channels without a row use the defaults
+-- declared in code.
+CREATE TABLE IF NOT EXISTS notification_preferences (
+    user_id INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    kind VARCHAR(64) NOT NULL,
+    channel VARCHAR(16) NOT NULL,
+    enabled BOOLEAN NOT NULL,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, kind, channel)
+);
+
+---- create above / drop below ----
+
+DROP TABLE IF EXISTS notification_preferences;
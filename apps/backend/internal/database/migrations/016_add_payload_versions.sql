@@ -0,0 +1,9 @@
+-- Version of the payload schema of jobs and outbox events, 0 for those
+-- recorded before payloads were versioned, upgraded by the consumers
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS payload_version INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE outbox_events ADD COLUMN IF NOT EXISTS payload_version INTEGER NOT NULL DEFAULT 0;
+
+---- create above / drop below ----
+
+ALTER TABLE outbox_events DROP COLUMN IF EXISTS payload_version;
+ALTER TABLE jobs DROP COLUMN IF EXISTS payload_version;
@@ -0,0 +1,11 @@
+-- The settings of users, see models.UserPreferences. The GIN index serves
+-- containment queries on their content, such as
+-- preferences @> '{"newsletter": true}'
+ALTER TABLE users ADD COLUMN IF NOT EXISTS preferences JSONB NOT NULL DEFAULT '{}';
+
+CREATE INDEX IF NOT EXISTS idx_users_preferences ON users USING GIN (preferences jsonb_path_ops);
+
+---- create above / drop below ----
+
+DROP INDEX IF EXISTS idx_users_preferences;
+ALTER TABLE users DROP COLUMN IF EXISTS preferences;
@@ -0,0 +1,51 @@
+-- The history of users: every version of a user row, valid from the time of
+-- the transaction that wrote it until the time of the one that replaced it,
+-- so a user can be read as of any point in time, see
+-- UserRepository.GetByIDAsOf. The rows are written by a trigger, so every
+-- change is recorded, including those made outside the application.
+--
+-- now() is the start time of the transaction: the versions written by a
+-- transaction share a single time, and a row changed twice within one
+-- transaction leaves an empty version that no point in time selects.
+CREATE TABLE IF NOT EXISTS users_history (
+    history_id BIGSERIAL PRIMARY KEY,
+    id INTEGER NOT NULL,
+    email VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP,
+    updated_at TIMESTAMP,
+    deleted_at TIMESTAMP,
+    version INTEGER NOT NULL,
+    preferences JSONB NOT NULL,
+    valid_from TIMESTAMPTZ NOT NULL,
+    valid_to TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_users_history_id_valid_from ON users_history (id, valid_from DESC);
+
+CREATE OR REPLACE FUNCTION record_users_history() RETURNS trigger AS $$
+BEGIN
+    IF TG_OP IN ('UPDATE', 'DELETE') THEN
+        UPDATE users_history SET valid_to = now() WHERE id = OLD.id AND valid_to IS NULL;
+    END IF;
+    IF TG_OP IN ('INSERT', 'UPDATE') THEN
+        INSERT INTO users_history (id, email, created_at, updated_at, deleted_at, version, preferences, valid_from)
+        VALUES (NEW.id, NEW.email, NEW.created_at, NEW.updated_at, NEW.deleted_at, NEW.version, NEW.preferences, now());
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER users_history
+    AFTER INSERT OR UPDATE OR DELETE ON users
+    FOR EACH ROW EXECUTE FUNCTION record_users_history();
+
+-- Existing users start their history at their last change
+INSERT INTO users_history (id, email, created_at, updated_at, deleted_at, version, preferences, valid_from)
+SELECT id, email, created_at, updated_at, deleted_at, version, preferences, COALESCE(updated_at, created_at, now())
+FROM users;
+
+---- create above / drop below ----
+
+DROP TRIGGER IF EXISTS users_history ON users;
+DROP FUNCTION IF EXISTS record_users_history();
+DROP TABLE IF EXISTS users_history;
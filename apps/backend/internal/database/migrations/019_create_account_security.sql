@@ -0,0 +1,40 @@
+-- Self-serve account security: the password of users, the sessions their
+-- tokens belong to, and the security events of their account.
+--
+-- password_hash is a bcrypt hash, empty for users who never set a password.
+-- It is left out of users_history, so past hashes are not kept.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS password_hash TEXT NOT NULL DEFAULT '';
+
+-- A session is a sign-in of a user, carried by its tokens as their jti
+-- claim. Tokens of a revoked or expired session are rejected.
+CREATE TABLE IF NOT EXISTS sessions (
+    id UUID PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    ip VARCHAR(45) NOT NULL DEFAULT '',
+    user_agent TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMP NOT NULL,
+    revoked_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_sessions_user_active ON sessions (user_id, created_at DESC) WHERE revoked_at IS NULL;
+
+-- The security log of users, see package audit
+CREATE TABLE IF NOT EXISTS security_events (
+    id BIGSERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    type VARCHAR(64) NOT NULL,
+    session_id UUID,
+    ip VARCHAR(45) NOT NULL DEFAULT '',
+    user_agent TEXT NOT NULL DEFAULT '',
+    details JSONB NOT NULL DEFAULT '{}',
+    occurred_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_security_events_user_id ON security_events (user_id, id DESC);
+
+---- create above / drop below ----
+
+DROP TABLE IF EXISTS security_events;
+DROP TABLE IF EXISTS sessions;
+ALTER TABLE users DROP COLUMN IF EXISTS password_hash;
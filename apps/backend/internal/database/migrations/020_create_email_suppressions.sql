@@ -0,0 +1,16 @@
+-- Addresses no email is sent to anymore, after a hard bounce or a spam
+-- complaint reported by the email provider, see mailer.Suppressing.
+-- Addresses are stored lowercased.
+CREATE TABLE IF NOT EXISTS email_suppressions (
+    id BIGSERIAL PRIMARY KEY,
+    email VARCHAR(255) NOT NULL UNIQUE,
+    reason VARCHAR(32) NOT NULL,
+    provider VARCHAR(32) NOT NULL DEFAULT '',
+    details TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+---- create above / drop below ----
+
+DROP TABLE IF EXISTS email_suppressions;
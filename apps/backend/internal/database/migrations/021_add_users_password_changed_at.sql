@@ -0,0 +1,7 @@
+-- The time users last changed their password: tokens issued without a
+-- session before it are rejected, so a password change signs them out too
+ALTER TABLE users ADD COLUMN IF NOT EXISTS password_changed_at TIMESTAMP;
+
+---- create above / drop below ----
+
+ALTER TABLE users DROP COLUMN IF EXISTS password_changed_at;
@@ -9,6 +9,8 @@ import (
 	"io/fs"
 	"net"
 	"net/url"
+	"regexp"
+	"time"
 
 	pgx "github.com/jackc/pgx/v5"
 	tern "github.com/jackc/tern/v2/migrate"
@@ -17,54 +19,344 @@ import (
 	"github.com/PrinceNarteh/go-boilerplate/internal/config"
 )
 
+const (
+	versionTable = "schema_version"       // Table holding the current schema version
+	stateTable   = "schema_version_dirty" // Table recording the migration in progress
+)
+
 //go:embed migrations/*.sql
 var migrations embed.FS
 
-func Migrate(ctx context.Context, logger *zerolog.Logger, cfg *config.Config) error {
-	hostPort := net.JoinHostPort(cfg.Database.Host, cfg.Database.Port)
-	encodedPassword := url.QueryEscape(cfg.Database.Password)
-	dsn := fmt.Sprintf("postgres://%s:%s@%s/%s?sslmode=%s",
-		cfg.Database.User,
-		encodedPassword,
-		hostPort,
-		cfg.Database.Name,
-		cfg.Database.SSLMode,
-	)
+// disableTxPattern matches the marker of migrations that run outside a transaction
+var disableTxPattern = regexp.MustCompile(`(?m)^---- tern: disable-tx ----$`)
+
+// ErrDirty is returned when a previous migration was interrupted part way.
+// The schema must be repaired by hand and the version set with Force.
+var ErrDirty = errors.New("database schema is dirty")
+
+// DirtyMigration describes a migration that did not finish
+type DirtyMigration struct {
+	Sequence  int32
+	Name      string
+	Direction string
+	StartedAt time.Time
+}
+
+// MigrationStatus describes the state of the database schema
+type MigrationStatus struct {
+	Current int32
+	Latest  int32
+	Pending []string
+	Dirty   *DirtyMigration
+}
 
-	conn, err := pgx.Connect(ctx, dsn)
+// Migrator applies the embedded SQL migrations.
+//
+// Migrations are numbered files in the migrations directory, with the up and
+// down SQL separated by a "---- create above / drop below ----" line. The
+// current version is kept in the schema_version table. Before each migration
+// runs, it is recorded in schema_version_dirty and the record is removed once
+// the migration succeeds or is rolled back, so a migration that fails outside
+// a transaction or is interrupted leaves the schema marked dirty.
+//
+// With schema-per-tenant isolation, every tenant schema gets the same
+// migrations, with its own version tables, through NewSchemaMigrator.
+type Migrator struct {
+	conn         *pgx.Conn
+	tern         *tern.Migrator
+	logger       *zerolog.Logger
+	versionTable string
+	stateTable   string
+}
+
+// NewMigrator connects to the database and loads the embedded migrations.
+// The returned migrator must be closed with Close.
+func NewMigrator(ctx context.Context, logger *zerolog.Logger, cfg *config.Config) (*Migrator, error) {
+	conn, err := pgx.Connect(ctx, connString(cfg))
 	if err != nil {
-		return err
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
+	}
+
+	m, err := newMigrator(ctx, conn, logger, versionTable, stateTable)
+	if err != nil {
+		conn.Close(ctx)
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// NewSchemaMigrator connects to the database and loads the embedded
+// migrations like NewMigrator, to apply them to schema, such as the schema
+// of a tenant, which must exist and be a lowercase identifier. The
+// migrations create their tables in schema, which also holds the version
+// tables.
+func NewSchemaMigrator(
+	ctx context.Context,
+	logger *zerolog.Logger,
+	cfg *config.Config,
+	schema string,
+) (*Migrator, error) {
+	conn, err := pgx.Connect(ctx, connString(cfg))
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
+	}
+
+	if _, err := conn.Exec(ctx, "SET search_path TO "+pgx.Identifier{schema}.Sanitize()); err != nil {
+		conn.Close(ctx)
+		return nil, fmt.Errorf("failed to select schema %s: %w", schema, err)
+	}
+
+	schemaLogger := logger.With().Str("schema", schema).Logger()
+	m, err := newMigrator(ctx, conn, &schemaLogger, schema+"."+versionTable, schema+"."+stateTable)
+	if err != nil {
+		conn.Close(ctx)
+		return nil, err
 	}
-	defer conn.Close(ctx)
 
-	m, err := tern.NewMigrator(ctx, conn, "schema_version")
+	return m, nil
+}
+
+// newMigrator creates a migrator on an open connection, keeping the version
+// and migration state in the given tables
+func newMigrator(
+	ctx context.Context,
+	conn *pgx.Conn,
+	logger *zerolog.Logger,
+	versionTable, stateTable string,
+) (*Migrator, error) {
+	tm, err := tern.NewMigrator(ctx, conn, versionTable)
 	if err != nil {
-		return fmt.Errorf("constructing database migrator: %w", err)
+		return nil, fmt.Errorf("constructing database migrator: %w", err)
 	}
 
 	subtree, err := fs.Sub(migrations, "migrations")
 	if err != nil {
-		return fmt.Errorf("retrieving database migrations subtree: %w", err)
+		return nil, fmt.Errorf("retrieving database migrations subtree: %w", err)
 	}
 
-	if err = m.LoadMigrations(subtree); err != nil {
-		return fmt.Errorf("loading database migrations: %w", err)
+	if err = tm.LoadMigrations(subtree); err != nil {
+		return nil, fmt.Errorf("loading database migrations: %w", err)
 	}
 
-	from, err := m.GetCurrentVersion(ctx)
+	query := `
+		CREATE TABLE IF NOT EXISTS ` + stateTable + ` (
+			sequence INTEGER NOT NULL,
+			name TEXT NOT NULL,
+			direction TEXT NOT NULL,
+			started_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		)`
+	if _, err := conn.Exec(ctx, query); err != nil {
+		return nil, fmt.Errorf("creating migration state table: %w", err)
+	}
+
+	return &Migrator{
+		conn:         conn,
+		tern:         tm,
+		logger:       logger,
+		versionTable: versionTable,
+		stateTable:   stateTable,
+	}, nil
+}
+
+// Close closes the database connection of the migrator
+func (m *Migrator) Close(ctx context.Context) error {
+	return m.conn.Close(ctx)
+}
+
+// Status returns the current and latest versions, the pending migrations and
+// the interrupted migration, if any
+func (m *Migrator) Status(ctx context.Context) (MigrationStatus, error) {
+	current, err := m.tern.GetCurrentVersion(ctx)
 	if err != nil {
-		return errors.New("retrieving current database migration version")
+		return MigrationStatus{}, fmt.Errorf("retrieving current database migration version: %w", err)
 	}
 
-	if err = m.Migrate(ctx); err != nil {
+	dirty, err := m.dirty(ctx)
+	if err != nil {
+		return MigrationStatus{}, err
+	}
+
+	status := MigrationStatus{
+		Current: current,
+		Latest:  m.latest(),
+		Dirty:   dirty,
+	}
+	for _, migration := range m.tern.Migrations {
+		if migration.Sequence > current {
+			status.Pending = append(status.Pending, migration.Name)
+		}
+	}
+
+	return status, nil
+}
+
+// Up applies all pending migrations
+func (m *Migrator) Up(ctx context.Context) error {
+	return m.To(ctx, m.latest())
+}
+
+// Down rolls back the given number of migrations
+func (m *Migrator) Down(ctx context.Context, steps int) error {
+	current, err := m.tern.GetCurrentVersion(ctx)
+	if err != nil {
+		return fmt.Errorf("retrieving current database migration version: %w", err)
+	}
+	return m.To(ctx, max(0, current-int32(steps)))
+}
+
+// To migrates the schema up or down to the given version, one migration at a time.
+// It returns ErrDirty without changing anything if the schema is dirty.
+func (m *Migrator) To(ctx context.Context, target int32) error {
+	dirty, err := m.dirty(ctx)
+	if err != nil {
 		return err
 	}
+	if dirty != nil {
+		return fmt.Errorf("%w: migration %d %s (%s) did not finish", ErrDirty, dirty.Sequence, dirty.Name, dirty.Direction)
+	}
 
-	if from == int32(len(m.Migrations)) {
-		logger.Info().Msgf("database schema up to date, version %d", len(m.Migrations))
+	from, err := m.tern.GetCurrentVersion(ctx)
+	if err != nil {
+		return fmt.Errorf("retrieving current database migration version: %w", err)
+	}
+	if target < 0 || target > m.latest() {
+		return fmt.Errorf("version %d is outside the valid versions of 0 to %d", target, m.latest())
+	}
+
+	for current := from; current != target; {
+		next, migration := current+1, m.tern.Migrations[current]
+		direction, sql := "up", migration.UpSQL
+		if target < current {
+			next, migration = current-1, m.tern.Migrations[current-1]
+			direction, sql = "down", migration.DownSQL
+		}
+
+		if err := m.step(ctx, migration, next, direction, sql); err != nil {
+			return err
+		}
+		current = next
+	}
+
+	if from == target {
+		m.logger.Info().Msgf("database schema up to date, version %d", target)
 	} else {
-		logger.Info().Msgf("migrated database schema, from  %d to %d", from, len(m.Migrations))
+		m.logger.Info().Msgf("migrated database schema, from %d to %d", from, target)
 	}
 
 	return nil
 }
+
+// Force sets the schema version without running any migration and clears the
+// dirty state. It is used after repairing a schema left dirty by a failed migration.
+func (m *Migrator) Force(ctx context.Context, version int32) error {
+	if version < 0 || version > m.latest() {
+		return fmt.Errorf("version %d is outside the valid versions of 0 to %d", version, m.latest())
+	}
+
+	err := pgx.BeginFunc(ctx, m.conn, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, "UPDATE "+m.versionTable+" SET version = $1", version); err != nil {
+			return err
+		}
+		_, err := tx.Exec(ctx, "DELETE FROM "+m.stateTable)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("forcing database schema version: %w", err)
+	}
+
+	m.logger.Warn().Msgf("forced database schema version to %d", version)
+	return nil
+}
+
+// step runs a single migration, marking the schema dirty while it runs
+func (m *Migrator) step(ctx context.Context, migration *tern.Migration, target int32, direction, sql string) error {
+	query := `INSERT INTO ` + m.stateTable + ` (sequence, name, direction) VALUES ($1, $2, $3)`
+	if _, err := m.conn.Exec(ctx, query, migration.Sequence, migration.Name, direction); err != nil {
+		return fmt.Errorf("recording migration start: %w", err)
+	}
+
+	m.logger.Info().Int32("sequence", migration.Sequence).Str("name", migration.Name).Msgf("migrating %s", direction)
+
+	if err := m.tern.MigrateTo(ctx, target); err != nil {
+		// A migration run in a transaction was rolled back, so the schema is unchanged
+		if !disableTxPattern.MatchString(sql) {
+			if clearErr := m.clearDirty(ctx); clearErr != nil {
+				m.logger.Error().Err(clearErr).Msg("failed to clear migration state")
+			}
+		}
+		return fmt.Errorf("migration %s %s failed: %w", migration.Name, direction, err)
+	}
+
+	return m.clearDirty(ctx)
+}
+
+// dirty returns the migration left unfinished, or nil if the schema is clean
+func (m *Migrator) dirty(ctx context.Context) (*DirtyMigration, error) {
+	query := `SELECT sequence, name, direction, started_at FROM ` + m.stateTable + ` ORDER BY started_at DESC LIMIT 1`
+
+	var d DirtyMigration
+	err := m.conn.QueryRow(ctx, query).Scan(&d.Sequence, &d.Name, &d.Direction, &d.StartedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("retrieving migration state: %w", err)
+	}
+
+	return &d, nil
+}
+
+// clearDirty removes the record of the migration in progress
+func (m *Migrator) clearDirty(ctx context.Context) error {
+	if _, err := m.conn.Exec(ctx, "DELETE FROM "+m.stateTable); err != nil {
+		return fmt.Errorf("clearing migration state: %w", err)
+	}
+	return nil
+}
+
+// latest returns the version reached once every migration is applied
+func (m *Migrator) latest() int32 {
+	return int32(len(m.tern.Migrations))
+}
+
+// Migrate applies all pending migrations
+func Migrate(ctx context.Context, logger *zerolog.Logger, cfg *config.Config) error {
+	m, err := NewMigrator(ctx, logger, cfg)
+	if err != nil {
+		return err
+	}
+	defer m.Close(ctx)
+
+	return m.Up(ctx)
+}
+
+// MigrateSchema applies all pending migrations to schema, see NewSchemaMigrator
+func MigrateSchema(ctx context.Context, logger *zerolog.Logger, cfg *config.Config, schema string) error {
+	m, err := NewSchemaMigrator(ctx, logger, cfg, schema)
+	if err != nil {
+		return err
+	}
+	defer m.Close(ctx)
+
+	return m.Up(ctx)
+}
+
+// connString builds the PostgreSQL connection string from configuration
+func connString(cfg *config.Config) string {
+	return replicaConnString(cfg, net.JoinHostPort(cfg.Database.Host, cfg.Database.Port))
+}
+
+// replicaConnString builds the connection string of the server at hostPort,
+// using the credentials and database name of the primary
+func replicaConnString(cfg *config.Config, hostPort string) string {
+	// URL-encode the password
+	encodedPassword := url.QueryEscape(cfg.Database.Password)
+	return fmt.Sprintf("postgres://%s:%s@%s/%s?sslmode=%s",
+		cfg.Database.User,
+		encodedPassword,
+		hostPort,
+		cfg.Database.Name,
+		cfg.Database.SSLMode,
+	)
+}
@@ -0,0 +1,13 @@
+//go:build !nonewrelic
+
+package database
+
+import (
+	pgx "github.com/jackc/pgx/v5"
+	"github.com/newrelic/go-agent/v3/integrations/nrpgx5"
+)
+
+// newRelicTracer returns the tracer reporting queries to New Relic
+func newRelicTracer() pgx.QueryTracer {
+	return nrpgx5.NewTracer()
+}
@@ -0,0 +1,11 @@
+//go:build nonewrelic
+
+package database
+
+import pgx "github.com/jackc/pgx/v5"
+
+// newRelicTracer returns no tracer in builds with the nonewrelic tag, which
+// leave the New Relic pgx integration out of the binary
+func newRelicTracer() pgx.QueryTracer {
+	return nil
+}
@@ -0,0 +1,151 @@
+package database
+
+import (
+	"context"
+	"net"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/PrinceNarteh/go-boilerplate/internal/libs/async"
+)
+
+// defaultReplicaCheckInterval is the default interval between replica health checks
+const defaultReplicaCheckInterval = 10 * time.Second
+
+// replica is a read replica pool and its last known health
+type replica struct {
+	addr    string
+	pool    *pgxpool.Pool
+	healthy atomic.Bool
+}
+
+// primaryKey is the context key forcing reads to the primary
+type primaryKey struct{}
+
+// WithPrimary returns a context under which ReadPool returns the primary,
+// e.g. to read a row right after writing it, before replicas catch up
+func WithPrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryKey{}, true)
+}
+
+// Pools chooses the pool of every query, so that repositories send reads
+// to the replicas and writes to the primary. It is implemented by *Database.
+type Pools interface {
+	ReadPool(ctx context.Context) *pgxpool.Pool
+	WritePool(ctx context.Context) *pgxpool.Pool
+}
+
+// Read returns the querier for reads: the transaction carried by ctx, or
+// the ReadPool of db
+func Read(ctx context.Context, db Pools) Querier {
+	if tx, ok := TxFromContext(ctx); ok {
+		return tx
+	}
+	return db.ReadPool(ctx)
+}
+
+// Write returns the querier for writes, and for the reads that must see
+// the latest writes: the transaction carried by ctx, or the WritePool of db
+func Write(ctx context.Context, db Pools) Querier {
+	if tx, ok := TxFromContext(ctx); ok {
+		return tx
+	}
+	return db.WritePool(ctx)
+}
+
+// WritePool returns the pool for writes, which is always the primary
+func (db *Database) WritePool(_ context.Context) *pgxpool.Pool {
+	return db.Pool
+}
+
+// ReadPool returns the pool for reads. Healthy replicas are used in turn;
+// the primary is used when there are none, when ctx carries a transaction,
+// which always runs on the primary, or when ctx comes from WithPrimary.
+func (db *Database) ReadPool(ctx context.Context) *pgxpool.Pool {
+	if len(db.replicas) == 0 {
+		return db.Pool
+	}
+	if _, ok := TxFromContext(ctx); ok {
+		return db.Pool
+	}
+	if primary, _ := ctx.Value(primaryKey{}).(bool); primary {
+		return db.Pool
+	}
+
+	start := db.next.Add(1)
+	for i := range db.replicas {
+		r := db.replicas[(start+uint64(i))%uint64(len(db.replicas))]
+		if r.healthy.Load() {
+			return r.pool
+		}
+	}
+	return db.Pool
+}
+
+// ReplicaPools returns the pool of every read replica keyed by address,
+// whatever its health, e.g. to measure replication lag
+func (db *Database) ReplicaPools() map[string]*pgxpool.Pool {
+	pools := make(map[string]*pgxpool.Pool, len(db.replicas))
+	for _, r := range db.replicas {
+		pools[r.addr] = r.pool
+	}
+	return pools
+}
+
+// checkReplicas pings every replica at interval, taking unreachable replicas
+// out of rotation until they respond again. It returns a function stopping
+// the checks and waiting for them to finish.
+func (db *Database) checkReplicas(interval time.Duration) func() {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := async.Go(ctx, "database.replicas", func(ctx context.Context) error {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-ticker.C:
+				for _, r := range db.replicas {
+					db.checkReplica(ctx, r, interval)
+				}
+			}
+		}
+	})
+
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+// checkReplica pings a replica and records its health, logging changes
+func (db *Database) checkReplica(ctx context.Context, r *replica, timeout time.Duration) {
+	pingCtx, cancel := context.WithTimeout(ctx, timeout)
+	err := r.pool.Ping(pingCtx)
+	cancel()
+	if ctx.Err() != nil {
+		// The checks are stopping, the failure says nothing about the replica
+		return
+	}
+
+	healthy := err == nil
+	if r.healthy.Swap(healthy) == healthy {
+		return
+	}
+	if healthy {
+		db.log.Info().Str("replica", r.addr).Msg("read replica recovered, back in rotation")
+	} else {
+		db.log.Warn().Err(err).Str("replica", r.addr).Msg("read replica unhealthy, out of rotation")
+	}
+}
+
+// replicaAddr returns addr with the primary's port when it has none
+func replicaAddr(addr, defaultPort string) string {
+	if _, _, err := net.SplitHostPort(addr); err == nil {
+		return addr
+	}
+	return net.JoinHostPort(addr, defaultPort)
+}
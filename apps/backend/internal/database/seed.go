@@ -0,0 +1,56 @@
+package database
+
+import (
+	"context"
+	"embed"
+	"fmt"
+	"io/fs"
+
+	pgx "github.com/jackc/pgx/v5"
+	"github.com/rs/zerolog"
+
+	"github.com/PrinceNarteh/go-boilerplate/internal/config"
+)
+
+//go:embed seeds/*.sql
+var seeds embed.FS
+
+// Seed runs the embedded SQL files of the seeds directory in name order,
+// in a single transaction, to fill a migrated database with development
+// data. Seeds should be idempotent, e.g. with ON CONFLICT DO NOTHING, so
+// they can run again after new seeds are added.
+func Seed(ctx context.Context, logger *zerolog.Logger, cfg *config.Config) error {
+	// fs.Glob returns the names sorted
+	names, err := fs.Glob(seeds, "seeds/*.sql")
+	if err != nil {
+		return fmt.Errorf("failed to list seeds: %w", err)
+	}
+
+	conn, err := pgx.Connect(ctx, connString(cfg))
+	if err != nil {
+		return fmt.Errorf("failed to connect to database: %w", err)
+	}
+	defer conn.Close(ctx)
+
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	for _, name := range names {
+		sql, err := fs.ReadFile(seeds, name)
+		if err != nil {
+			return fmt.Errorf("failed to read seed %s: %w", name, err)
+		}
+		if _, err := tx.Exec(ctx, string(sql)); err != nil {
+			return fmt.Errorf("failed to run seed %s: %w", name, err)
+		}
+		logger.Info().Str("seed", name).Msg("Ran seed")
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit seeds: %w", err)
+	}
+	return nil
+}
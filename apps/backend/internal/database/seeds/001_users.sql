@@ -0,0 +1,5 @@
+-- Example users for development environments
+INSERT INTO users (email) VALUES
+    ('alice@example.com'),
+    ('bob@example.com')
+ON CONFLICT (email) WHERE deleted_at IS NULL DO NOTHING;
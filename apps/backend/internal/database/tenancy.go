@@ -0,0 +1,75 @@
+package database
+
+import (
+	"context"
+	"sync"
+
+	pgx "github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// schemaKey is the context key of the schema of the current tenant
+type schemaKey struct{}
+
+// WithSchema returns a context under which connections acquired from pools
+// with tenancy enabled query schema, the schema of a tenant. Only schema is
+// searched, so queries fail rather than fall back to the public tables
+// when it does not exist.
+func WithSchema(ctx context.Context, schema string) context.Context {
+	return context.WithValue(ctx, schemaKey{}, schema)
+}
+
+// SchemaFromContext returns the schema carried by ctx, if any
+func SchemaFromContext(ctx context.Context) (string, bool) {
+	schema, ok := ctx.Value(schemaKey{}).(string)
+	return schema, ok && schema != ""
+}
+
+// searchPaths sets the search_path of pooled connections to the schema of
+// the context acquiring them, and remembers the schema of each connection
+// so it is only changed when it differs
+type searchPaths struct {
+	mu      sync.Mutex
+	schemas map[*pgx.Conn]string
+}
+
+// enableTenancy installs the search_path hooks on a pool configuration
+func enableTenancy(poolConfig *pgxpool.Config) {
+	paths := &searchPaths{schemas: make(map[*pgx.Conn]string)}
+	poolConfig.BeforeAcquire = paths.beforeAcquire
+	poolConfig.BeforeClose = paths.beforeClose
+}
+
+// beforeAcquire switches conn to the schema of ctx, or back to the default
+// search_path. A connection whose search_path cannot be set is destroyed
+// and another one acquired.
+func (p *searchPaths) beforeAcquire(ctx context.Context, conn *pgx.Conn) bool {
+	schema, _ := SchemaFromContext(ctx)
+
+	p.mu.Lock()
+	current := p.schemas[conn]
+	p.mu.Unlock()
+	if schema == current {
+		return true
+	}
+
+	query := "RESET search_path"
+	if schema != "" {
+		query = "SET search_path TO " + pgx.Identifier{schema}.Sanitize()
+	}
+	if _, err := conn.Exec(ctx, query); err != nil {
+		return false
+	}
+
+	p.mu.Lock()
+	p.schemas[conn] = schema
+	p.mu.Unlock()
+	return true
+}
+
+// beforeClose forgets the schema of a closed connection
+func (p *searchPaths) beforeClose(conn *pgx.Conn) {
+	p.mu.Lock()
+	delete(p.schemas, conn)
+	p.mu.Unlock()
+}
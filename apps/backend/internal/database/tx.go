@@ -0,0 +1,74 @@
+package database
+
+import (
+	"context"
+	"fmt"
+
+	pgx "github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Querier is implemented by both *pgxpool.Pool and pgx.Tx, so repositories
+// can run the same queries inside and outside a transaction
+type Querier interface {
+	Begin(ctx context.Context) (pgx.Tx, error)
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+// txKey is the context key for the current transaction
+type txKey struct{}
+
+// TxManager runs functions within database transactions.
+//
+// The transaction is carried in the context passed to the function, and
+// repositories pick it up with Conn, Read or Write, so a service can compose
+// several repository calls atomically without the repositories knowing
+// about it.
+type TxManager struct {
+	pool *pgxpool.Pool
+}
+
+// NewTxManager creates a new transaction manager
+func NewTxManager(pool *pgxpool.Pool) *TxManager {
+	return &TxManager{pool: pool}
+}
+
+// WithinTx runs fn within a transaction that is committed when fn returns nil
+// and rolled back when it returns an error or panics. When ctx already
+// carries a transaction, fn runs within a savepoint of it instead, so only
+// the nested work is rolled back on error.
+func (m *TxManager) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	tx, err := Conn(ctx, m.pool).Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", TranslateError(err))
+	}
+	// Rollback is a no-op once the transaction is committed
+	defer tx.Rollback(context.WithoutCancel(ctx))
+
+	if err := fn(context.WithValue(ctx, txKey{}, tx)); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", TranslateError(err))
+	}
+
+	return nil
+}
+
+// TxFromContext returns the transaction carried by ctx, if any
+func TxFromContext(ctx context.Context) (pgx.Tx, bool) {
+	tx, ok := ctx.Value(txKey{}).(pgx.Tx)
+	return tx, ok
+}
+
+// Conn returns the transaction carried by ctx, or pool when there is none
+func Conn(ctx context.Context, pool *pgxpool.Pool) Querier {
+	if tx, ok := TxFromContext(ctx); ok {
+		return tx
+	}
+	return pool
+}
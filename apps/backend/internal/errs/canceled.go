@@ -0,0 +1,25 @@
+package errs
+
+import (
+	"context"
+	"errors"
+	"net/http"
+)
+
+// StatusClientClosedRequest is the non-standard status, introduced by nginx,
+// reported for requests the client canceled before the response was written
+const StatusClientClosedRequest = 499
+
+// ErrCodeClientClosed reports a request canceled by its client
+const ErrCodeClientClosed = "CLIENT_CLOSED_REQUEST"
+
+// ErrClientClosed is written for requests canceled by their client. The
+// client is gone, so it only shows in access logs and metrics.
+var ErrClientClosed = &AppError{Code: ErrCodeClientClosed, Message: "Client closed request", Status: StatusClientClosedRequest}
+
+// ClientCanceled reports whether the client of r canceled the request, by
+// disconnecting or resetting the stream. Requests running past a deadline,
+// such as the latency budget, are not client cancellations.
+func ClientCanceled(r *http.Request) bool {
+	return errors.Is(r.Context().Err(), context.Canceled)
+}
@@ -10,6 +10,7 @@ type AppError struct {
 	Code    string `json:"code"`
 	Message string `json:"message"`
 	Status  int    `json:"status"`
+	Details any    `json:"details,omitempty"`
 }
 
 // Error implements the error interface
@@ -17,6 +18,14 @@ func (e *AppError) Error() string {
 	return e.Message
 }
 
+// WithDetails returns a copy of the error carrying additional details,
+// such as per-field validation messages
+func (e *AppError) WithDetails(details any) *AppError {
+	clone := *e
+	clone.Details = details
+	return &clone
+}
+
 // Common error codes
 const (
 	ErrCodeValidation      = "VALIDATION_ERROR"
@@ -27,6 +36,12 @@ const (
 	ErrCodeBadRequest      = "BAD_REQUEST"
 	ErrCodeConflict        = "CONFLICT"
 	ErrCodeTooManyRequests = "TOO_MANY_REQUESTS"
+	ErrCodePayloadTooLarge = "PAYLOAD_TOO_LARGE"
+	ErrCodeUnavailable     = "SERVICE_UNAVAILABLE"
+	ErrCodeConsentRequired = "CONSENT_REQUIRED"
+	ErrCodeUnsupportedType = "UNSUPPORTED_MEDIA_TYPE"
+	// ErrCodeContractViolation reports a response not matching the OpenAPI spec
+	ErrCodeContractViolation = "CONTRACT_VIOLATION"
 )
 
 // Predefined errors
@@ -39,6 +54,10 @@ var (
 	ErrBadRequest      = &AppError{Code: ErrCodeBadRequest, Message: "Bad request", Status: http.StatusBadRequest}
 	ErrConflict        = &AppError{Code: ErrCodeConflict, Message: "Resource conflict", Status: http.StatusConflict}
 	ErrTooManyRequests = &AppError{Code: ErrCodeTooManyRequests, Message: "Too many requests", Status: http.StatusTooManyRequests}
+	ErrPayloadTooLarge = &AppError{Code: ErrCodePayloadTooLarge, Message: "Request body too large", Status: http.StatusRequestEntityTooLarge}
+	ErrUnavailable     = &AppError{Code: ErrCodeUnavailable, Message: "Service temporarily unavailable", Status: http.StatusServiceUnavailable}
+	ErrConsentRequired = &AppError{Code: ErrCodeConsentRequired, Message: "Updated terms must be accepted", Status: http.StatusForbidden}
+	ErrUnsupportedType = &AppError{Code: ErrCodeUnsupportedType, Message: "Unsupported media type", Status: http.StatusUnsupportedMediaType}
 )
 
 // New creates a new AppError
@@ -76,3 +95,21 @@ func NewInternal(message string) *AppError {
 		Status:  http.StatusInternalServerError,
 	}
 }
+
+// NewPayloadTooLarge creates a payload too large error for the given limit in bytes
+func NewPayloadTooLarge(limit int64) *AppError {
+	return &AppError{
+		Code:    ErrCodePayloadTooLarge,
+		Message: fmt.Sprintf("Request body must not exceed %d bytes", limit),
+		Status:  http.StatusRequestEntityTooLarge,
+	}
+}
+
+// NewUnsupportedMediaType creates an unsupported media type error with custom message
+func NewUnsupportedMediaType(message string) *AppError {
+	return &AppError{
+		Code:    ErrCodeUnsupportedType,
+		Message: message,
+		Status:  http.StatusUnsupportedMediaType,
+	}
+}
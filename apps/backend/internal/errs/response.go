@@ -0,0 +1,52 @@
+package errs
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+)
+
+// requestIDHeader is the response header set by the request ID middleware
+const requestIDHeader = "X-Request-ID"
+
+// ErrorBody is the body of an error response
+type ErrorBody struct {
+	Code      string `json:"code"`
+	Message   string `json:"message"`
+	Details   any    `json:"details,omitempty"`
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// ErrorResponse is the standard error envelope returned by the API
+type ErrorResponse struct {
+	Error ErrorBody `json:"error"`
+}
+
+// From returns err as an AppError.
+// Errors that do not wrap an AppError are reported as ErrInternal so that
+// internal details are never leaked to clients.
+func From(err error) *AppError {
+	var appErr *AppError
+	if errors.As(err, &appErr) {
+		return appErr
+	}
+	return ErrInternal
+}
+
+// WriteJSON writes err as a JSON error response using the standard envelope.
+// The request ID is taken from the X-Request-ID response header, which the
+// request ID middleware sets before handlers run.
+func WriteJSON(w http.ResponseWriter, err error) {
+	appErr := From(err)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(appErr.Status)
+	json.NewEncoder(w).Encode(ErrorResponse{
+		Error: ErrorBody{
+			Code:      appErr.Code,
+			Message:   appErr.Message,
+			Details:   appErr.Details,
+			RequestID: w.Header().Get(requestIDHeader),
+		},
+	})
+}
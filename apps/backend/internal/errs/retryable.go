@@ -0,0 +1,85 @@
+package errs
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"syscall"
+)
+
+// retryableError marks an error as transient
+type retryableError struct {
+	err error
+}
+
+func (e *retryableError) Error() string   { return e.err.Error() }
+func (e *retryableError) Unwrap() error   { return e.err }
+func (e *retryableError) Retryable() bool { return true }
+
+// Retryable marks err as transient, so IsRetryable reports true for it and
+// the errors wrapping it. It returns nil when err is nil.
+func Retryable(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &retryableError{err: err}
+}
+
+// IsRetryable reports whether err is transient, so the operation failing
+// with it may succeed if attempted again. Errors are transient when:
+//   - they or an error they wrap report it through a Retryable() bool method,
+//     as errors marked with Retryable do, or a SafeToRetry() bool method, as
+//     pgconn errors do for failures before the query was sent
+//   - they wrap an AppError with a retryable status, see IsRetryableStatus
+//   - they are network timeouts, refused or reset connections, or
+//     connections closed mid-response
+//
+// Context cancellation is never retryable: the caller gave up.
+func IsRetryable(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+
+	var retryable interface{ Retryable() bool }
+	if errors.As(err, &retryable) {
+		return retryable.Retryable()
+	}
+	var safeToRetry interface{ SafeToRetry() bool }
+	if errors.As(err, &safeToRetry) && safeToRetry.SafeToRetry() {
+		return true
+	}
+
+	var appErr *AppError
+	if errors.As(err, &appErr) {
+		return IsRetryableStatus(appErr.Status)
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return dnsErr.IsTemporary || dnsErr.IsTimeout
+	}
+	return errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.EPIPE) ||
+		errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// IsRetryableStatus reports whether an HTTP status reports a transient
+// failure: a timeout, rate limiting or an unavailable upstream
+func IsRetryableStatus(status int) bool {
+	switch status {
+	case http.StatusRequestTimeout,
+		http.StatusTooManyRequests,
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
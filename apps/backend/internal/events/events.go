@@ -0,0 +1,158 @@
+// Package events is an in-process domain event bus, decoupling the side
+// effects of a change, such as sending an email or invalidating a cache,
+// from the service making it.
+//
+// Events are plain structs implementing Event, such as UserCreated.
+// Subscribers register for an event type with Subscribe, running within
+// Publish, or SubscribeAsync, running in the background once Publish
+// returns. A subscriber that fails or panics is logged and never affects
+// the publisher or the other subscribers.
+//
+// Subscribers receive the context of the publisher, so the request ID,
+// logger and principal it carries are available. Asynchronous subscribers
+// outlive the request, so their context is never canceled by it and their
+// New Relic segments are recorded on a goroutine of its transaction.
+//
+// Events are not persisted: an asynchronous subscriber still running when
+// the process exits is lost. Use the outbox package for events that must be
+// delivered.
+package events
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+
+	"github.com/rs/zerolog"
+
+	"github.com/PrinceNarteh/go-boilerplate/internal/tracing"
+)
+
+// Event is implemented by event types
+type Event interface {
+	// EventName names the event in logs, such as "user.created"
+	EventName() string
+}
+
+// Handler handles events of type E
+type Handler[E Event] func(ctx context.Context, event E) error
+
+// subscriber is a registered handler
+type subscriber struct {
+	name   string
+	async  bool
+	handle func(ctx context.Context, event Event) error
+}
+
+// Bus dispatches events to their subscribers
+type Bus struct {
+	logger *zerolog.Logger
+
+	mu          sync.RWMutex
+	subscribers map[reflect.Type][]subscriber
+	running     sync.WaitGroup
+}
+
+// NewBus creates a new event bus
+func NewBus(logger *zerolog.Logger) *Bus {
+	return &Bus{
+		logger:      logger,
+		subscribers: make(map[reflect.Type][]subscriber),
+	}
+}
+
+// Subscribe registers a handler run within Publish for events of type E,
+// in the order of registration. name identifies the subscriber in logs.
+func Subscribe[E Event](b *Bus, name string, handler Handler[E]) {
+	register(b, name, false, handler)
+}
+
+// SubscribeAsync registers a handler run in the background for events of
+// type E, after Publish returns. name identifies the subscriber in logs.
+func SubscribeAsync[E Event](b *Bus, name string, handler Handler[E]) {
+	register(b, name, true, handler)
+}
+
+// register adds a subscriber for events of type E
+func register[E Event](b *Bus, name string, async bool, handler Handler[E]) {
+	eventType := reflect.TypeFor[E]()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.subscribers[eventType] = append(b.subscribers[eventType], subscriber{
+		name:  name,
+		async: async,
+		handle: func(ctx context.Context, event Event) error {
+			return handler(ctx, event.(E))
+		},
+	})
+}
+
+// Publish dispatches event to the subscribers of its type. It runs the
+// synchronous subscribers, starts the asynchronous ones and returns the
+// errors of the synchronous subscribers, already logged.
+func (b *Bus) Publish(ctx context.Context, event Event) error {
+	b.mu.RLock()
+	subscribers := b.subscribers[reflect.TypeOf(event)]
+	b.mu.RUnlock()
+
+	var errList []error
+	for _, sub := range subscribers {
+		if !sub.async {
+			if err := b.run(ctx, sub, event); err != nil {
+				errList = append(errList, err)
+			}
+			continue
+		}
+
+		asyncCtx := context.WithoutCancel(ctx)
+		if txn, ok := tracing.FromContext(ctx); ok {
+			asyncCtx = tracing.NewContext(asyncCtx, txn.NewGoroutine())
+		}
+		b.running.Add(1)
+		go func() {
+			defer b.running.Done()
+			_ = b.run(asyncCtx, sub, event)
+		}()
+	}
+	return errors.Join(errList...)
+}
+
+// Shutdown waits for the running asynchronous subscribers to return, until
+// ctx is done
+func (b *Bus) Shutdown(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		b.running.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("event subscribers still running: %w", ctx.Err())
+	}
+}
+
+// run runs a subscriber, recovering panics and logging failures
+func (b *Bus) run(ctx context.Context, sub subscriber, event Event) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("subscriber panicked: %v", p)
+		}
+		if err != nil {
+			err = fmt.Errorf("%s subscriber %s: %w", event.EventName(), sub.name, err)
+			b.logger.Error().
+				Err(err).
+				Str("event", event.EventName()).
+				Str("subscriber", sub.name).
+				Bool("async", sub.async).
+				Msg("Event subscriber failed")
+		}
+	}()
+
+	return sub.handle(ctx, event)
+}
@@ -0,0 +1,74 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/PrinceNarteh/go-boilerplate/internal/models"
+	"github.com/PrinceNarteh/go-boilerplate/internal/services"
+)
+
+// UserCreated is published after a user signed up
+type UserCreated struct {
+	User       *models.User `json:"user"`
+	OccurredAt time.Time    `json:"occurred_at"`
+}
+
+// EventName implements Event
+func (UserCreated) EventName() string { return string(services.UserCreated) }
+
+// UserUpdated is published after a user was changed
+type UserUpdated struct {
+	User       *models.User `json:"user"`
+	OccurredAt time.Time    `json:"occurred_at"`
+}
+
+// EventName implements Event
+func (UserUpdated) EventName() string { return string(services.UserUpdated) }
+
+// UserDeleted is published after a user was soft-deleted
+type UserDeleted struct {
+	User       *models.User `json:"user"`
+	OccurredAt time.Time    `json:"occurred_at"`
+}
+
+// EventName implements Event
+func (UserDeleted) EventName() string { return string(services.UserDeleted) }
+
+// UserRestored is published after a soft-deleted user was brought back
+type UserRestored struct {
+	User       *models.User `json:"user"`
+	OccurredAt time.Time    `json:"occurred_at"`
+}
+
+// EventName implements Event
+func (UserRestored) EventName() string { return string(services.UserRestored) }
+
+// UserEvents returns a services.UserEventPublisher publishing the user
+// events of a UserService on the bus as typed events, for
+// services.UserServiceOptions.Events
+func UserEvents(b *Bus) services.UserEventPublisher {
+	return userEvents{bus: b}
+}
+
+// userEvents publishes services.UserEvent values as typed events
+type userEvents struct {
+	bus *Bus
+}
+
+// PublishUserEvent implements services.UserEventPublisher
+func (p userEvents) PublishUserEvent(ctx context.Context, event services.UserEvent) error {
+	switch event.Type {
+	case services.UserCreated:
+		return p.bus.Publish(ctx, UserCreated{User: event.User, OccurredAt: event.OccurredAt})
+	case services.UserUpdated:
+		return p.bus.Publish(ctx, UserUpdated{User: event.User, OccurredAt: event.OccurredAt})
+	case services.UserDeleted:
+		return p.bus.Publish(ctx, UserDeleted{User: event.User, OccurredAt: event.OccurredAt})
+	case services.UserRestored:
+		return p.bus.Publish(ctx, UserRestored{User: event.User, OccurredAt: event.OccurredAt})
+	default:
+		return fmt.Errorf("unknown user event %q", event.Type)
+	}
+}
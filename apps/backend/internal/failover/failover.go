@@ -0,0 +1,374 @@
+// Package failover supervises connections to backing services, such as
+// PostgreSQL and Redis, so that an outage is handled in one place instead of
+// every caller seeing raw connection errors.
+//
+// A Supervisor probes its connection in the background, with exponential
+// backoff while it is down; pgxpool and go-redis open new connections on the
+// next use, so a successful probe means the connection is re-established.
+// While the connection is down, Do fails fast with errs.ErrUnavailable and
+// Write applies the policy of the subsystem: fail, queue the write until
+// recovery, or degrade by skipping it. The state is exposed as a health check
+// so readiness flips during outages.
+package failover
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/rs/zerolog"
+
+	"github.com/PrinceNarteh/go-boilerplate/internal/config"
+	"github.com/PrinceNarteh/go-boilerplate/internal/database"
+	"github.com/PrinceNarteh/go-boilerplate/internal/errs"
+	"github.com/PrinceNarteh/go-boilerplate/internal/healthcheck"
+	"github.com/PrinceNarteh/go-boilerplate/internal/libs/async"
+)
+
+const (
+	defaultCheckInterval = 5 * time.Second        // Default interval between probes while up
+	defaultMinBackoff    = 500 * time.Millisecond // Default first delay between probes while down
+	defaultMaxBackoff    = 30 * time.Second       // Default longest delay between probes while down
+	defaultQueueSize     = 1000                   // Default number of writes queued while down
+	probeTimeout         = 5 * time.Second        // Timeout of a single probe
+	replayTimeout        = 30 * time.Second       // Timeout of a single replayed write
+)
+
+// Policy decides what happens to writes while a connection is down
+type Policy string
+
+// Write policies
+const (
+	// PolicyFail rejects writes with an error wrapping errs.ErrUnavailable
+	PolicyFail Policy = "fail"
+	// PolicyQueue keeps writes in memory and replays them in order on recovery.
+	// Writes are rejected like PolicyFail once the queue is full.
+	PolicyQueue Policy = "queue"
+	// PolicyDegrade skips writes, for data that can be lost, such as caches
+	PolicyDegrade Policy = "degrade"
+)
+
+// State is the state of a supervised connection
+type State string
+
+// Connection states
+const (
+	StateUp   State = "up"
+	StateDown State = "down"
+)
+
+// Status describes a supervised connection, as reported by its health check
+type Status struct {
+	State  State     `json:"state"`
+	Since  time.Time `json:"since"`
+	Queued int       `json:"queued,omitempty"`
+	Error  string    `json:"error,omitempty"`
+}
+
+// queuedWrite is a write waiting for the connection to recover
+type queuedWrite struct {
+	ctx context.Context
+	fn  func(ctx context.Context) error
+}
+
+// Supervisor watches a connection and applies its write policy during outages
+type Supervisor struct {
+	name   string
+	probe  healthcheck.Check
+	policy Policy
+	cfg    config.FailoverConfig
+	logger *zerolog.Logger
+	wake   chan struct{}
+
+	mu     sync.Mutex
+	status Status
+	queue  []queuedWrite
+	stop   func()
+}
+
+// New creates a supervisor for the named connection. probe, such as
+// healthcheck.DatabasePing, checks the connection. Unset intervals and
+// sizes of cfg use the defaults, and an empty policy is PolicyFail.
+func New(
+	name string,
+	probe healthcheck.Check,
+	policy Policy,
+	cfg config.FailoverConfig,
+	logger *zerolog.Logger,
+) *Supervisor {
+	if policy == "" {
+		policy = PolicyFail
+	}
+	if cfg.CheckInterval <= 0 {
+		cfg.CheckInterval = defaultCheckInterval
+	}
+	if cfg.MinBackoff <= 0 {
+		cfg.MinBackoff = defaultMinBackoff
+	}
+	if cfg.MaxBackoff < cfg.MinBackoff {
+		cfg.MaxBackoff = max(defaultMaxBackoff, cfg.MinBackoff)
+	}
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = defaultQueueSize
+	}
+
+	return &Supervisor{
+		name:   name,
+		probe:  probe,
+		policy: policy,
+		cfg:    cfg,
+		logger: logger,
+		wake:   make(chan struct{}, 1),
+		status: Status{State: StateUp, Since: time.Now()},
+	}
+}
+
+// Start probes the connection in the background until Stop is called
+func (s *Supervisor) Start() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stop != nil {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := async.Go(ctx, "failover."+s.name, func(ctx context.Context) error {
+		s.watch(ctx)
+		return nil
+	})
+
+	s.stop = func() {
+		cancel()
+		<-done
+	}
+}
+
+// Stop stops probing the connection. Writes still queued are dropped.
+func (s *Supervisor) Stop() {
+	s.mu.Lock()
+	stop := s.stop
+	s.stop = nil
+	queued := len(s.queue)
+	s.mu.Unlock()
+
+	if stop != nil {
+		stop()
+	}
+	if queued > 0 {
+		s.logger.Warn().Str("connection", s.name).Int("queued", queued).Msg("Dropping queued writes on shutdown")
+	}
+}
+
+// Status returns the current status of the connection
+func (s *Supervisor) Status() Status {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	status := s.status
+	status.Queued = len(s.queue)
+	return status
+}
+
+// HealthCheck returns a check reporting the status of the connection,
+// failing while it is down
+func (s *Supervisor) HealthCheck() healthcheck.Check {
+	return func(_ context.Context) (any, error) {
+		status := s.Status()
+		if status.State == StateDown {
+			return status, fmt.Errorf("%s connection down since %s", s.name, status.Since.Format(time.RFC3339))
+		}
+		return status, nil
+	}
+}
+
+// Do runs fn, typically a read, while the connection is up. While it is
+// down, Do returns an error wrapping errs.ErrUnavailable without running
+// fn, so callers do not wait for connection timeouts. A connection error
+// returned by fn marks the connection down.
+func (s *Supervisor) Do(ctx context.Context, fn func(ctx context.Context) error) error {
+	if s.Status().State == StateDown {
+		return s.unavailable()
+	}
+
+	err := fn(ctx)
+	if IsConnectionError(err) {
+		s.markDown(err)
+	}
+	return err
+}
+
+// Write runs fn like Do, but applies the policy of the connection when it
+// is down or fn fails with a connection error. Queued writes are replayed
+// with the values of ctx but not its cancellation, so fn must not rely on a
+// transaction or other request-scoped resource.
+func (s *Supervisor) Write(ctx context.Context, fn func(ctx context.Context) error) error {
+	if s.Status().State == StateDown {
+		return s.degrade(ctx, fn)
+	}
+
+	err := fn(ctx)
+	if !IsConnectionError(err) {
+		return err
+	}
+	s.markDown(err)
+	return s.degrade(ctx, fn)
+}
+
+// degrade applies the policy to a write that cannot run now
+func (s *Supervisor) degrade(ctx context.Context, fn func(ctx context.Context) error) error {
+	switch s.policy {
+	case PolicyDegrade:
+		s.logger.Debug().Str("connection", s.name).Msg("Skipping write while connection is down")
+		return nil
+	case PolicyQueue:
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if len(s.queue) >= s.cfg.QueueSize {
+			return s.unavailable()
+		}
+		s.queue = append(s.queue, queuedWrite{ctx: context.WithoutCancel(ctx), fn: fn})
+		return nil
+	default:
+		return s.unavailable()
+	}
+}
+
+// watch probes the connection until ctx is done
+func (s *Supervisor) watch(ctx context.Context) {
+	backoff := s.cfg.MinBackoff
+	timer := time.NewTimer(s.cfg.CheckInterval)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		case <-s.wake:
+		}
+
+		wait := s.cfg.CheckInterval
+		if s.check(ctx) {
+			backoff = s.cfg.MinBackoff
+		} else {
+			// Jitter spreads the reconnection attempts of several instances
+			wait = backoff + rand.N(backoff/5+1)
+			backoff = min(2*backoff, s.cfg.MaxBackoff)
+		}
+
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+		timer.Reset(wait)
+	}
+}
+
+// check probes the connection, updates its state and replays queued writes
+// on recovery. It reports whether the connection is up.
+func (s *Supervisor) check(ctx context.Context) bool {
+	probeCtx, cancel := context.WithTimeout(ctx, probeTimeout)
+	_, err := s.probe(probeCtx)
+	cancel()
+	if ctx.Err() != nil {
+		return false
+	}
+	if err != nil {
+		s.markDown(err)
+		return false
+	}
+
+	s.mu.Lock()
+	wasDown := s.status.State == StateDown
+	if wasDown {
+		s.logger.Info().
+			Str("connection", s.name).
+			Dur("downtime", time.Since(s.status.Since)).
+			Msg("Connection recovered")
+		s.status = Status{State: StateUp, Since: time.Now()}
+	}
+	s.mu.Unlock()
+
+	return s.replay()
+}
+
+// replay runs the queued writes in order. It stops and marks the connection
+// down again at the first connection error, keeping the remaining writes.
+func (s *Supervisor) replay() bool {
+	replayed := 0
+	for {
+		s.mu.Lock()
+		if len(s.queue) == 0 {
+			s.mu.Unlock()
+			break
+		}
+		write := s.queue[0]
+		s.mu.Unlock()
+
+		ctx, cancel := context.WithTimeout(write.ctx, replayTimeout)
+		err := write.fn(ctx)
+		cancel()
+		if IsConnectionError(err) {
+			s.markDown(err)
+			return false
+		}
+		if err != nil {
+			s.logger.Error().Err(err).Str("connection", s.name).Msg("Queued write failed")
+		}
+
+		s.mu.Lock()
+		s.queue = s.queue[1:]
+		s.mu.Unlock()
+		replayed++
+	}
+
+	if replayed > 0 {
+		s.logger.Info().Str("connection", s.name).Int("writes", replayed).Msg("Replayed queued writes")
+	}
+	return true
+}
+
+// markDown records that the connection is down and wakes the watcher to
+// start reconnecting
+func (s *Supervisor) markDown(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.status.State == StateUp {
+		s.logger.Warn().Err(err).Str("connection", s.name).Str("policy", string(s.policy)).Msg("Connection down")
+		s.status = Status{State: StateDown, Since: time.Now()}
+		select {
+		case s.wake <- struct{}{}:
+		default:
+		}
+	}
+	s.status.Error = err.Error()
+}
+
+// unavailable returns the error reported while the connection is down
+func (s *Supervisor) unavailable() error {
+	return fmt.Errorf("%s connection down: %w", s.name, errs.ErrUnavailable)
+}
+
+// IsConnectionError reports whether err was caused by a broken or refused
+// connection to PostgreSQL or Redis, rather than by the operation itself
+func IsConnectionError(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	var netErr net.Error
+	return errors.Is(err, errs.ErrUnavailable) ||
+		database.IsConnectionError(err) ||
+		errors.Is(err, redis.ErrClosed) ||
+		errors.Is(err, redis.ErrPoolTimeout) ||
+		errors.As(err, &netErr) ||
+		errors.Is(err, io.EOF)
+}
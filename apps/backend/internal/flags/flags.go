@@ -0,0 +1,118 @@
+// Package flags evaluates feature flags for the user making a request.
+//
+// Flags and the cohorts they are rolled out to, such as internal users or
+// beta testers, are declared in config.FlagsFile and can be replaced at
+// runtime with Update. Unknown flags are off, so code and routes can ship
+// dark before their flag is declared. Routes are gated with
+// middlewares.RequireFeature.
+package flags
+
+import (
+	"context"
+	"hash/fnv"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/PrinceNarteh/go-boilerplate/internal/auth"
+	"github.com/PrinceNarteh/go-boilerplate/internal/config"
+)
+
+// Service evaluates feature flags
+type Service struct {
+	mu   sync.RWMutex
+	file config.FlagsFile
+}
+
+// New creates a service evaluating the flags of file, which must be valid
+func New(file config.FlagsFile) *Service {
+	return &Service{file: file}
+}
+
+// Update replaces the declared flags and cohorts. Invalid declarations are
+// rejected and the current ones kept.
+func (s *Service) Update(file config.FlagsFile) error {
+	if err := config.ValidateFlags(file); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.file = file
+	return nil
+}
+
+// Enabled reports whether a flag is on for the principal of ctx, see
+// auth.FromContext. Without a principal, only flags on for everyone are on.
+func (s *Service) Enabled(ctx context.Context, flag string) bool {
+	principal, _ := auth.FromContext(ctx)
+	return s.EnabledFor(principal, flag)
+}
+
+// EnabledFor reports whether a flag is on for principal, which may be nil
+func (s *Service) EnabledFor(principal *auth.Principal, flag string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	declared, ok := s.file.Flags[flag]
+	if !ok || !declared.Enabled {
+		return false
+	}
+	if len(declared.Cohorts) == 0 && declared.Percentage == 0 {
+		return true
+	}
+	if principal == nil {
+		return false
+	}
+
+	for _, cohort := range declared.Cohorts {
+		if member(s.file.Cohorts[cohort], principal) {
+			return true
+		}
+	}
+	return declared.Percentage > 0 && bucket(flag, principal.UserID) < declared.Percentage
+}
+
+// Cohorts returns the names of the declared cohorts principal belongs to, sorted
+func (s *Service) Cohorts(principal *auth.Principal) []string {
+	if principal == nil {
+		return nil
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var cohorts []string
+	for name, cohort := range s.file.Cohorts {
+		if member(cohort, principal) {
+			cohorts = append(cohorts, name)
+		}
+	}
+	slices.Sort(cohorts)
+	return cohorts
+}
+
+// member reports whether principal belongs to cohort
+func member(cohort config.CohortConfig, principal *auth.Principal) bool {
+	if slices.Contains(cohort.UserIDs, principal.UserID) {
+		return true
+	}
+	if slices.ContainsFunc(cohort.Roles, func(role string) bool { return principal.HasRole(auth.Role(role)) }) {
+		return true
+	}
+	if _, domain, ok := strings.Cut(principal.Email, "@"); ok {
+		return slices.ContainsFunc(cohort.EmailDomains, func(d string) bool {
+			return strings.EqualFold(d, domain)
+		})
+	}
+	return false
+}
+
+// bucket places a user in one of 100 buckets, differently for each flag so
+// that the same users are not always the first to get new features
+func bucket(flag string, userID int) int {
+	h := fnv.New32a()
+	h.Write([]byte(flag + ":" + strconv.Itoa(userID)))
+	return int(h.Sum32() % 100)
+}
@@ -0,0 +1,107 @@
+// Package geoip resolves client IP addresses to countries and autonomous
+// systems using MaxMind GeoIP2/GeoLite2 databases.
+package geoip
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+
+	"github.com/oschwald/geoip2-golang"
+)
+
+// Location is the geolocation of an IP address.
+// Fields are empty when the corresponding database is not configured
+// or has no record for the address.
+type Location struct {
+	CountryCode string `json:"country_code,omitempty"`
+	ASN         uint   `json:"asn,omitempty"`
+	ASOrg       string `json:"as_org,omitempty"`
+}
+
+// Resolver looks up the location of an IP address
+type Resolver interface {
+	Lookup(ip net.IP) (Location, error)
+}
+
+// locationKey is the context key for the request location
+type locationKey struct{}
+
+// WithLocation returns a copy of ctx carrying the location
+func WithLocation(ctx context.Context, loc Location) context.Context {
+	return context.WithValue(ctx, locationKey{}, loc)
+}
+
+// FromContext returns the location stored in ctx
+func FromContext(ctx context.Context) (Location, bool) {
+	loc, ok := ctx.Value(locationKey{}).(Location)
+	return loc, ok
+}
+
+// MaxMindResolver resolves locations from local MaxMind database files
+type MaxMindResolver struct {
+	country *geoip2.Reader
+	asn     *geoip2.Reader
+}
+
+// OpenMaxMind opens the MaxMind country and ASN databases.
+// Either path may be empty to skip that lookup.
+func OpenMaxMind(countryDBPath, asnDBPath string) (*MaxMindResolver, error) {
+	r := &MaxMindResolver{}
+
+	if countryDBPath != "" {
+		reader, err := geoip2.Open(countryDBPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open country database: %w", err)
+		}
+		r.country = reader
+	}
+
+	if asnDBPath != "" {
+		reader, err := geoip2.Open(asnDBPath)
+		if err != nil {
+			r.Close()
+			return nil, fmt.Errorf("failed to open ASN database: %w", err)
+		}
+		r.asn = reader
+	}
+
+	return r, nil
+}
+
+// Lookup implements Resolver
+func (r *MaxMindResolver) Lookup(ip net.IP) (Location, error) {
+	var loc Location
+
+	if r.country != nil {
+		record, err := r.country.Country(ip)
+		if err != nil {
+			return loc, fmt.Errorf("failed to look up country: %w", err)
+		}
+		loc.CountryCode = record.Country.IsoCode
+	}
+
+	if r.asn != nil {
+		record, err := r.asn.ASN(ip)
+		if err != nil {
+			return loc, fmt.Errorf("failed to look up ASN: %w", err)
+		}
+		loc.ASN = record.AutonomousSystemNumber
+		loc.ASOrg = record.AutonomousSystemOrganization
+	}
+
+	return loc, nil
+}
+
+// Close closes the underlying databases
+func (r *MaxMindResolver) Close() error {
+	var errs []error
+	if r.country != nil {
+		errs = append(errs, r.country.Close())
+	}
+	if r.asn != nil {
+		errs = append(errs, r.asn.Close())
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,160 @@
+// Package graphql serves a GraphQL endpoint beside the REST API.
+//
+// The schema is declared in schema.graphqls, and gqlgen generates its
+// executor into generated/ and the resolver stubs of schema.resolvers.go
+// with "task graphql", binding the types of the schema to the models of
+// internal/models. Resolvers load records through the dataloaders of the
+// request, batching the lookups of a query into one query per type.
+//
+// Operations deeper than the configured depth or more complex than the
+// configured complexity are rejected before they run, and errors are
+// reported with the code of their errs.AppError in their extensions.
+package graphql
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	gqlgen "github.com/99designs/gqlgen/graphql"
+	"github.com/99designs/gqlgen/graphql/handler"
+	"github.com/99designs/gqlgen/graphql/handler/extension"
+	"github.com/99designs/gqlgen/graphql/handler/lru"
+	"github.com/99designs/gqlgen/graphql/handler/transport"
+	"github.com/99designs/gqlgen/graphql/playground"
+	"github.com/rs/zerolog"
+	"github.com/vektah/gqlparser/v2/ast"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+
+	"github.com/PrinceNarteh/go-boilerplate/internal/config"
+	"github.com/PrinceNarteh/go-boilerplate/internal/errs"
+	"github.com/PrinceNarteh/go-boilerplate/internal/graphql/generated"
+	"github.com/PrinceNarteh/go-boilerplate/internal/libs"
+	"github.com/PrinceNarteh/go-boilerplate/internal/middlewares"
+	"github.com/PrinceNarteh/go-boilerplate/internal/repositories"
+	"github.com/PrinceNarteh/go-boilerplate/internal/routers"
+	"github.com/PrinceNarteh/go-boilerplate/internal/services"
+)
+
+const (
+	defaultPath          = "/graphql" // Default path of the endpoint
+	defaultMaxDepth      = 10         // Default limit of the nesting of operations
+	defaultMaxComplexity = 200        // Default limit of the complexity of operations
+	queryCacheSize       = 1000       // Number of parsed queries kept
+)
+
+// playgroundCSP lets the playground load its scripts and styles from the
+// CDN, in place of the strict policy of the API
+const playgroundCSP = "default-src 'self'; script-src 'self' 'unsafe-inline' https://cdn.jsdelivr.net; " +
+	"style-src 'self' 'unsafe-inline' https://cdn.jsdelivr.net; img-src 'self' data: https://cdn.jsdelivr.net; " +
+	"font-src https://cdn.jsdelivr.net; connect-src 'self'"
+
+// Module serves the GraphQL endpoint, and its playground outside production
+type Module struct {
+	handler    http.Handler
+	path       string
+	playground bool
+	mw         []middlewares.Middleware
+}
+
+// New creates the GraphQL module. Unset settings of cfg use the defaults.
+// mw wraps the endpoint but not the playground; it should authenticate
+// users, storing the principal the resolvers check. The playground and
+// introspection are only enabled outside production.
+func New(
+	cfg config.GraphQLConfig,
+	production bool,
+	users *services.UserService,
+	userRepo repositories.UserRepository,
+	mw ...middlewares.Middleware,
+) *Module {
+	if cfg.Path == "" {
+		cfg.Path = defaultPath
+	}
+	if cfg.MaxDepth <= 0 {
+		cfg.MaxDepth = defaultMaxDepth
+	}
+	if cfg.MaxComplexity <= 0 {
+		cfg.MaxComplexity = defaultMaxComplexity
+	}
+
+	gc := generated.Config{Resolvers: &Resolver{users: users}}
+	gc.Complexity.Query.Users = func(childComplexity int, ids []int) int {
+		return len(ids) * childComplexity
+	}
+
+	srv := handler.New(generated.NewExecutableSchema(gc))
+	srv.AddTransport(transport.GET{})
+	srv.AddTransport(transport.POST{})
+	srv.SetQueryCache(lru.New[*ast.QueryDocument](queryCacheSize))
+	srv.Use(depthLimit(cfg.MaxDepth))
+	srv.Use(extension.FixedComplexityLimit(cfg.MaxComplexity))
+	if !production {
+		srv.Use(extension.Introspection{})
+	}
+	srv.SetErrorPresenter(presentError)
+	srv.SetRecoverFunc(recoverPanic)
+
+	return &Module{
+		handler:    withLoaders(userRepo, srv),
+		path:       cfg.Path,
+		playground: cfg.Playground && !production,
+		mw:         mw,
+	}
+}
+
+// RegisterRoutes implements routers.Module
+func (m *Module) RegisterRoutes(g *routers.RouteGroup) {
+	g.Handle(http.MethodGet, m.path, m.handler, m.mw...)
+	g.Handle(http.MethodPost, m.path, m.handler, m.mw...)
+
+	if m.playground {
+		page := playground.Handler("GraphQL", g.Prefix()+m.path)
+		g.GET(m.path+"/playground", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Security-Policy", playgroundCSP)
+			page.ServeHTTP(w, r)
+		})
+	}
+}
+
+// presentError reports application errors with their message and code,
+// and hides the details of unexpected errors, as errs.WriteJSON does
+func presentError(ctx context.Context, err error) *gqlerror.Error {
+	gqlErr := gqlgen.DefaultErrorPresenter(ctx, err)
+
+	var appErr *errs.AppError
+	if errors.As(err, &appErr) {
+		gqlErr.Message = appErr.Message
+		gqlErr.Extensions = map[string]any{"code": appErr.Code}
+		if appErr.Details != nil {
+			gqlErr.Extensions["details"] = appErr.Details
+		}
+		return gqlErr
+	}
+
+	// Errors of parsing, validation and limits are already GraphQL errors
+	var parseErr *gqlerror.Error
+	if errors.As(err, &parseErr) {
+		return gqlErr
+	}
+
+	zerolog.Ctx(ctx).Error().Err(err).Msg("Unhandled error in GraphQL resolver")
+	gqlErr.Message = errs.ErrInternal.Message
+	gqlErr.Extensions = map[string]any{"code": errs.ErrInternal.Code}
+	return gqlErr
+}
+
+// recoverPanic logs the panic of a resolver, which is reported as an internal error
+func recoverPanic(ctx context.Context, p any) error {
+	zerolog.Ctx(ctx).Error().Interface("panic", p).Msg("Panic recovered in GraphQL resolver")
+	return errs.ErrInternal
+}
+
+// validate validates an input as the HTTP handlers validate request bodies
+func validate(input any) error {
+	messages := libs.FieldMessages(libs.Validate(input))
+	if messages == nil {
+		return nil
+	}
+	return errs.ErrValidation.WithDetails(messages)
+}
@@ -0,0 +1,64 @@
+package graphql
+
+import (
+	"context"
+	"strings"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/vektah/gqlparser/v2/ast"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+// depthLimit is a handler extension rejecting operations nested deeper than
+// its value. Introspection fields are not counted, since the introspection
+// query of tools is deeply nested by design.
+type depthLimit int
+
+var (
+	_ graphql.HandlerExtension        = depthLimit(0)
+	_ graphql.OperationContextMutator = depthLimit(0)
+)
+
+// ExtensionName implements graphql.HandlerExtension
+func (depthLimit) ExtensionName() string {
+	return "DepthLimit"
+}
+
+// Validate implements graphql.HandlerExtension
+func (depthLimit) Validate(graphql.ExecutableSchema) error {
+	return nil
+}
+
+// MutateOperationContext implements graphql.OperationContextMutator
+func (l depthLimit) MutateOperationContext(_ context.Context, oc *graphql.OperationContext) *gqlerror.Error {
+	if depth := selectionDepth(oc.Operation.SelectionSet); depth > int(l) {
+		err := gqlerror.Errorf("operation has depth %d, which exceeds the limit of %d", depth, l)
+		err.Extensions = map[string]any{"code": "DEPTH_LIMIT_EXCEEDED"}
+		return err
+	}
+	return nil
+}
+
+// selectionDepth returns the depth of the deepest field of set. Fragment
+// cycles are rejected by validation, which runs first.
+func selectionDepth(set ast.SelectionSet) int {
+	depth := 0
+	for _, selection := range set {
+		var d int
+		switch s := selection.(type) {
+		case *ast.Field:
+			if strings.HasPrefix(s.Name, "__") {
+				continue
+			}
+			d = 1 + selectionDepth(s.SelectionSet)
+		case *ast.InlineFragment:
+			d = selectionDepth(s.SelectionSet)
+		case *ast.FragmentSpread:
+			if s.Definition != nil {
+				d = selectionDepth(s.Definition.SelectionSet)
+			}
+		}
+		depth = max(depth, d)
+	}
+	return depth
+}
@@ -0,0 +1,77 @@
+package graphql
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/graph-gophers/dataloader/v7"
+
+	"github.com/PrinceNarteh/go-boilerplate/internal/errs"
+	"github.com/PrinceNarteh/go-boilerplate/internal/models"
+	"github.com/PrinceNarteh/go-boilerplate/internal/repositories"
+)
+
+// loaderWait is how long loaders collect keys before querying a batch
+const loaderWait = 2 * time.Millisecond
+
+// loadersKey is the context key of the loaders of a request
+type loadersKey struct{}
+
+// Loaders batch and cache the reads of a request, so resolving the same
+// type for many parents takes one query instead of one per parent
+type Loaders struct {
+	Users *dataloader.Loader[int, *models.User]
+}
+
+// newLoaders creates the loaders of a request
+func newLoaders(users repositories.UserRepository) *Loaders {
+	return &Loaders{
+		Users: dataloader.NewBatchedLoader(
+			batchUsers(users),
+			dataloader.WithWait[int, *models.User](loaderWait),
+		),
+	}
+}
+
+// withLoaders stores new loaders in the context of every request
+func withLoaders(users repositories.UserRepository, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := context.WithValue(r.Context(), loadersKey{}, newLoaders(users))
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// loadersFrom returns the loaders of the request
+func loadersFrom(ctx context.Context) *Loaders {
+	return ctx.Value(loadersKey{}).(*Loaders)
+}
+
+// batchUsers loads users by ID with one query, reporting IDs without a user
+// as errs.ErrNotFound
+func batchUsers(users repositories.UserRepository) dataloader.BatchFunc[int, *models.User] {
+	return func(ctx context.Context, ids []int) []*dataloader.Result[*models.User] {
+		results := make([]*dataloader.Result[*models.User], len(ids))
+
+		found, err := users.GetByIDs(ctx, ids)
+		if err != nil {
+			for i := range results {
+				results[i] = &dataloader.Result[*models.User]{Error: err}
+			}
+			return results
+		}
+
+		byID := make(map[int]*models.User, len(found))
+		for _, user := range found {
+			byID[user.ID] = user
+		}
+		for i, id := range ids {
+			if user, ok := byID[id]; ok {
+				results[i] = &dataloader.Result[*models.User]{Data: user}
+			} else {
+				results[i] = &dataloader.Result[*models.User]{Error: errs.ErrNotFound}
+			}
+		}
+		return results
+	}
+}
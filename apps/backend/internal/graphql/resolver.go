@@ -0,0 +1,28 @@
+package graphql
+
+import (
+	"context"
+
+	"github.com/PrinceNarteh/go-boilerplate/internal/auth"
+	"github.com/PrinceNarteh/go-boilerplate/internal/errs"
+	"github.com/PrinceNarteh/go-boilerplate/internal/services"
+)
+
+// Resolver is the root resolver, holding the dependencies of the resolvers
+// of schema.resolvers.go. Reads go through the loaders of the request, and
+// writes through the services so they publish their events.
+type Resolver struct {
+	users *services.UserService
+}
+
+// requireAdmin fails unless the caller is an administrator
+func requireAdmin(ctx context.Context) error {
+	principal, ok := auth.FromContext(ctx)
+	if !ok {
+		return errs.ErrUnauthorized
+	}
+	if !principal.HasRole(auth.RoleAdmin) {
+		return errs.ErrForbidden
+	}
+	return nil
+}
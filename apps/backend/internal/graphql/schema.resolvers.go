@@ -0,0 +1,97 @@
+package graphql
+
+// This file will be automatically regenerated based on the schema, any resolver implementations
+// will be copied through when generating and any unknown code will be moved to the end.
+// Code generated by github.com/99designs/gqlgen version v0.17.78
+
+import (
+	"context"
+	"errors"
+
+	"github.com/PrinceNarteh/go-boilerplate/internal/auth"
+	"github.com/PrinceNarteh/go-boilerplate/internal/errs"
+	"github.com/PrinceNarteh/go-boilerplate/internal/graphql/generated"
+	"github.com/PrinceNarteh/go-boilerplate/internal/models"
+)
+
+// CreateUser is the resolver for the createUser field.
+func (r *mutationResolver) CreateUser(ctx context.Context, input models.CreateUserRequest) (*models.User, error) {
+	if err := requireAdmin(ctx); err != nil {
+		return nil, err
+	}
+	if err := validate(input); err != nil {
+		return nil, err
+	}
+
+	user, err := r.users.Create(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+	loadersFrom(ctx).Users.Prime(ctx, user.ID, user)
+	return user, nil
+}
+
+// UpdateUser is the resolver for the updateUser field.
+func (r *mutationResolver) UpdateUser(ctx context.Context, id int, input models.UpdateUserRequest) (*models.User, error) {
+	if err := requireAdmin(ctx); err != nil {
+		return nil, err
+	}
+	if err := validate(input); err != nil {
+		return nil, err
+	}
+
+	user, err := r.users.Update(ctx, id, input)
+	if err != nil {
+		return nil, err
+	}
+	loadersFrom(ctx).Users.Clear(ctx, id).Prime(ctx, id, user)
+	return user, nil
+}
+
+// Me is the resolver for the me field.
+func (r *queryResolver) Me(ctx context.Context) (*models.User, error) {
+	principal, ok := auth.FromContext(ctx)
+	if !ok {
+		return nil, errs.ErrUnauthorized
+	}
+	return loadersFrom(ctx).Users.Load(ctx, principal.UserID)()
+}
+
+// User is the resolver for the user field.
+func (r *queryResolver) User(ctx context.Context, id int) (*models.User, error) {
+	if err := requireAdmin(ctx); err != nil {
+		return nil, err
+	}
+
+	user, err := loadersFrom(ctx).Users.Load(ctx, id)()
+	if errors.Is(err, errs.ErrNotFound) {
+		return nil, nil
+	}
+	return user, err
+}
+
+// Users is the resolver for the users field.
+func (r *queryResolver) Users(ctx context.Context, ids []int) ([]*models.User, error) {
+	if err := requireAdmin(ctx); err != nil {
+		return nil, err
+	}
+
+	users, loadErrs := loadersFrom(ctx).Users.LoadMany(ctx, ids)()
+	for i, err := range loadErrs {
+		switch {
+		case err == nil:
+		case errors.Is(err, errs.ErrNotFound):
+			users[i] = nil
+		default:
+			return nil, err
+		}
+	}
+	return users, nil
+}
+
+func (r *Resolver) Mutation() generated.MutationResolver { return &mutationResolver{r} }
+
+func (r *Resolver) Query() generated.QueryResolver { return &queryResolver{r} }
+
+type mutationResolver struct{ *Resolver }
+type queryResolver struct{ *Resolver }
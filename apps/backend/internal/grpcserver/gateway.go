@@ -0,0 +1,93 @@
+package grpcserver
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"github.com/rs/zerolog"
+	"google.golang.org/protobuf/encoding/protojson"
+
+	"github.com/PrinceNarteh/go-boilerplate/internal/errs"
+	"github.com/PrinceNarteh/go-boilerplate/internal/middlewares"
+	"github.com/PrinceNarteh/go-boilerplate/internal/routers"
+)
+
+// gatewayPrefix is where the transcoded routes are mounted, below the API prefix
+const gatewayPrefix = "/rpc"
+
+// Gateway serves the gRPC services as REST routes, transcoded by
+// grpc-gateway from the google.api.http rules of their proto files.
+//
+// Services are called in-process, so calls go through the middleware chain
+// of the API routes rather than the gRPC interceptors, and their status
+// errors are written as the errs JSON errors of the HTTP endpoints. Register
+// services with the RegisterXHandlerServer functions of their generated
+// code, passing Mux.
+type Gateway struct {
+	mux          *runtime.ServeMux
+	authenticate middlewares.Middleware
+}
+
+// NewGateway creates a gateway. authenticate is the middleware used to
+// authenticate users, storing the principal the services check.
+func NewGateway(authenticate middlewares.Middleware) *Gateway {
+	mux := runtime.NewServeMux(
+		runtime.WithMarshalerOption(runtime.MIMEWildcard, &runtime.JSONPb{
+			MarshalOptions:   protojson.MarshalOptions{UseProtoNames: true, EmitUnpopulated: true},
+			UnmarshalOptions: protojson.UnmarshalOptions{DiscardUnknown: true},
+		}),
+		runtime.WithErrorHandler(writeGatewayError),
+		runtime.WithRoutingErrorHandler(writeRoutingError),
+	)
+	return &Gateway{mux: mux, authenticate: authenticate}
+}
+
+// Mux returns the mux services are registered on
+func (g *Gateway) Mux() *runtime.ServeMux {
+	return g.mux
+}
+
+// RegisterRoutes implements routers.Module, mounting the transcoded routes
+// below /rpc. Register it with routers.Router.RegisterAPI so they share the
+// middleware chain of the API.
+func (g *Gateway) RegisterRoutes(rg *routers.RouteGroup) {
+	prefix := rg.Prefix() + gatewayPrefix
+	rg.Handle("", gatewayPrefix+"/", http.StripPrefix(prefix, g.mux), g.authenticate)
+}
+
+// writeGatewayError writes the error of a transcoded call as errs.WriteJSON does
+func writeGatewayError(
+	ctx context.Context,
+	_ *runtime.ServeMux,
+	_ runtime.Marshaler,
+	w http.ResponseWriter,
+	_ *http.Request,
+	err error,
+) {
+	appErr := fromStatus(err)
+	if appErr.Status >= http.StatusInternalServerError {
+		zerolog.Ctx(ctx).Error().Err(err).Msg("Transcoded gRPC call failed")
+	}
+	errs.WriteJSON(w, appErr)
+}
+
+// writeRoutingError writes requests matching no transcoded route as the
+// router does
+func writeRoutingError(
+	_ context.Context,
+	_ *runtime.ServeMux,
+	_ runtime.Marshaler,
+	w http.ResponseWriter,
+	_ *http.Request,
+	status int,
+) {
+	switch status {
+	case http.StatusMethodNotAllowed:
+		errs.WriteJSON(w, errs.New(errs.ErrCodeBadRequest, "Method not allowed", status))
+	case http.StatusBadRequest:
+		errs.WriteJSON(w, errs.ErrBadRequest)
+	default:
+		errs.WriteJSON(w, errs.ErrNotFound)
+	}
+}
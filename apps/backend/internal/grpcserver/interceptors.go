@@ -0,0 +1,225 @@
+package grpcserver
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	"github.com/PrinceNarteh/go-boilerplate/internal/middlewares"
+	"github.com/PrinceNarteh/go-boilerplate/internal/telemetry"
+	"github.com/PrinceNarteh/go-boilerplate/internal/tracing"
+)
+
+// publicServices are the services callable without a token, as probes and
+// tools call them
+var publicServices = []string{"/grpc.health.v1.Health/", "/grpc.reflection."}
+
+// unaryRecovery turns panics of handlers into Internal errors
+func unaryRecovery(logger *zerolog.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (
+		_ any, err error,
+	) {
+		defer recoverPanic(logger, info.FullMethod, &err)
+		return handler(ctx, req)
+	}
+}
+
+// streamRecovery turns panics of stream handlers into Internal errors
+func streamRecovery(logger *zerolog.Logger) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+		defer recoverPanic(logger, info.FullMethod, &err)
+		return handler(srv, ss)
+	}
+}
+
+// recoverPanic recovers a panic of the handler of method, replacing its error
+func recoverPanic(logger *zerolog.Logger, method string, err *error) {
+	if p := recover(); p != nil {
+		logger.Error().
+			Interface("panic", p).
+			Str("method", method).
+			Msg("Panic recovered")
+		*err = status.Error(codes.Internal, "Internal server error")
+	}
+}
+
+// unaryNewRelic records a New Relic transaction per call, named after the method
+func unaryNewRelic(tracer tracing.Tracer) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		txn := startTransaction(ctx, tracer, info.FullMethod)
+		defer txn.End()
+
+		ctx = tracing.NewContext(ctx, txn)
+		resp, err := handler(ctx, req)
+		noticeError(ctx, info.FullMethod, err)
+		return resp, err
+	}
+}
+
+// streamNewRelic records a New Relic transaction per stream, named after the method
+func streamNewRelic(tracer tracing.Tracer) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		txn := startTransaction(ss.Context(), tracer, info.FullMethod)
+		defer txn.End()
+
+		ctx := tracing.NewContext(ss.Context(), txn)
+		err := handler(srv, &serverStream{ServerStream: ss, ctx: ctx})
+		noticeError(ctx, info.FullMethod, err)
+		return err
+	}
+}
+
+// startTransaction starts the transaction of a call, accepting the
+// distributed trace headers of the caller. It returns a transaction doing
+// nothing when tracer is nil.
+func startTransaction(ctx context.Context, tracer tracing.Tracer, method string) tracing.Transaction {
+	txn := tracing.Start(tracer, method)
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		headers := make(map[string][]string, len(md))
+		for k, v := range md {
+			headers[k] = v
+		}
+		txn.AcceptDistributedTraceHeaders(tracing.TransportOther, headers)
+	}
+	return txn
+}
+
+// noticeError reports the error of a call of method, unless its code is a
+// client error
+func noticeError(ctx context.Context, method string, err error) {
+	if err == nil {
+		return
+	}
+	txn, _ := tracing.FromContext(ctx)
+	txn.AddAttribute("grpc.code", status.Code(err).String())
+	switch status.Code(err) {
+	case codes.Unknown, codes.Internal, codes.Unavailable, codes.DataLoss, codes.DeadlineExceeded:
+		telemetry.ReportError(ctx, "grpc"+method, err)
+	}
+}
+
+// unaryLogger logs every call and stores the logger of the call in its context
+func unaryLogger(logger *zerolog.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		ctx, callLogger := withLogger(ctx, logger)
+		start := time.Now()
+		resp, err := handler(ctx, req)
+		logCall(ctx, callLogger, info.FullMethod, start, err)
+		return resp, err
+	}
+}
+
+// streamLogger logs every stream and stores the logger of the stream in its context
+func streamLogger(logger *zerolog.Logger) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, callLogger := withLogger(ss.Context(), logger)
+		start := time.Now()
+		err := handler(srv, &serverStream{ServerStream: ss, ctx: ctx})
+		logCall(ctx, callLogger, info.FullMethod, start, err)
+		return err
+	}
+}
+
+// withLogger stores a logger for the call in ctx, with the request ID of
+// the x-request-id metadata when the caller set one
+func withLogger(ctx context.Context, logger *zerolog.Logger) (context.Context, *zerolog.Logger) {
+	callLogger := logger.With().Logger()
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if ids := md.Get(middlewares.RequestIDHeader); len(ids) > 0 {
+			callLogger = callLogger.With().Str("request_id", ids[0]).Logger()
+			ctx = middlewares.WithRequestID(ctx, ids[0])
+		}
+	}
+	return callLogger.WithContext(ctx), &callLogger
+}
+
+// logCall logs a finished call, as errors when the server failed
+func logCall(ctx context.Context, logger *zerolog.Logger, method string, start time.Time, err error) {
+	code := status.Code(err)
+	event := logger.Info()
+	switch code {
+	case codes.Unknown, codes.Internal, codes.Unavailable, codes.DataLoss:
+		event = logger.Error().Err(err)
+	}
+	if p, ok := peer.FromContext(ctx); ok {
+		event = event.Str("remote_addr", p.Addr.String())
+	}
+	event.
+		Str("method", method).
+		Str("code", code.String()).
+		Dur("duration", time.Since(start)).
+		Msg("gRPC request")
+}
+
+// unaryAuthenticate requires a valid bearer token in the authorization
+// metadata, storing the resolved principal in the context of the call
+func unaryAuthenticate(authenticator *middlewares.Authenticator) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		ctx, err := authenticate(ctx, authenticator, info.FullMethod)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// streamAuthenticate requires a valid bearer token in the authorization
+// metadata, storing the resolved principal in the context of the stream
+func streamAuthenticate(authenticator *middlewares.Authenticator) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := authenticate(ss.Context(), authenticator, info.FullMethod)
+		if err != nil {
+			return err
+		}
+		return handler(srv, &serverStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+// authenticate resolves the principal of the bearer token of a call to
+// method as the HTTP routes do, unless the method belongs to a public
+// service. Callers may name their tenant in the metadata of the tenant
+// header.
+func authenticate(ctx context.Context, authenticator *middlewares.Authenticator, method string) (context.Context, error) {
+	for _, prefix := range publicServices {
+		if strings.HasPrefix(method, prefix) {
+			return ctx, nil
+		}
+	}
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get("authorization")
+	if len(values) == 0 {
+		return nil, status.Error(codes.Unauthenticated, "Unauthorized")
+	}
+	token, ok := strings.CutPrefix(values[0], "Bearer ")
+	if !ok || token == "" {
+		return nil, status.Error(codes.Unauthenticated, "Unauthorized")
+	}
+	var tenant string
+	if values := md.Get(authenticator.TenantHeader()); len(values) > 0 {
+		tenant = values[0]
+	}
+	authenticated, err := authenticator.Authenticate(ctx, token, tenant)
+	if err != nil {
+		return nil, toStatus(middlewares.AuthenticationError(ctx, err))
+	}
+	return authenticated, nil
+}
+
+// serverStream replaces the context of a stream
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+// Context implements grpc.ServerStream
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
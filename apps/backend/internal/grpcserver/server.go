@@ -0,0 +1,141 @@
+// Package grpcserver serves gRPC services beside the HTTP server.
+//
+// The server registers the standard health service, reporting each
+// registered service as serving until shutdown, and the reflection service
+// when enabled. Every call goes through interceptors recovering panics,
+// recording a New Relic transaction, logging the call and authenticating
+// the bearer token of the authorization metadata. Services are registered
+// on the Server, which implements grpc.ServiceRegistrar, with the
+// Register functions of their generated code. The Gateway serves them as
+// REST routes of the HTTP API as well, from the google.api.http rules of
+// their methods.
+//
+// Services are declared in proto files under proto/, and their code is
+// generated into gen/ with "task proto". proto/google/api holds the
+// annotations of the HTTP rules, trimmed from googleapis.
+package grpcserver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+
+	"github.com/rs/zerolog"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
+
+	"github.com/PrinceNarteh/go-boilerplate/internal/config"
+	"github.com/PrinceNarteh/go-boilerplate/internal/middlewares"
+	"github.com/PrinceNarteh/go-boilerplate/internal/tracing"
+)
+
+const (
+	defaultPort            = "9090"  // Default port of the gRPC server
+	defaultMaxRecvMsgBytes = 4 << 20 // Default limit of request messages
+)
+
+// Server is the gRPC server
+type Server struct {
+	grpc   *grpc.Server
+	health *health.Server
+	addr   string
+	logger *zerolog.Logger
+}
+
+// New creates a gRPC server. Transactions are recorded on tracer, which
+// may be nil, and authenticator resolves the principals of the bearer tokens
+// of callers. Unset settings of cfg use the defaults.
+func New(
+	cfg config.GRPCConfig,
+	authenticator *middlewares.Authenticator,
+	tracer tracing.Tracer,
+	logger *zerolog.Logger,
+) *Server {
+	if cfg.Port == "" {
+		cfg.Port = defaultPort
+	}
+	if cfg.MaxRecvMsgBytes <= 0 {
+		cfg.MaxRecvMsgBytes = defaultMaxRecvMsgBytes
+	}
+
+	srv := grpc.NewServer(
+		grpc.MaxRecvMsgSize(cfg.MaxRecvMsgBytes),
+		grpc.ChainUnaryInterceptor(
+			unaryRecovery(logger),
+			unaryNewRelic(tracer),
+			unaryLogger(logger),
+			unaryAuthenticate(authenticator),
+		),
+		grpc.ChainStreamInterceptor(
+			streamRecovery(logger),
+			streamNewRelic(tracer),
+			streamLogger(logger),
+			streamAuthenticate(authenticator),
+		),
+	)
+
+	healthServer := health.NewServer()
+	healthpb.RegisterHealthServer(srv, healthServer)
+	if cfg.Reflection {
+		reflection.Register(srv)
+	}
+
+	return &Server{
+		grpc:   srv,
+		health: healthServer,
+		addr:   ":" + cfg.Port,
+		logger: logger,
+	}
+}
+
+// RegisterService implements grpc.ServiceRegistrar. The service is reported
+// as serving by the health service.
+func (s *Server) RegisterService(desc *grpc.ServiceDesc, impl any) {
+	s.grpc.RegisterService(desc, impl)
+	s.health.SetServingStatus(desc.ServiceName, healthpb.HealthCheckResponse_SERVING)
+}
+
+// Addr returns the address the server listens on
+func (s *Server) Addr() string {
+	return s.addr
+}
+
+// Start starts the gRPC server and blocks until it stops
+func (s *Server) Start() error {
+	s.logger.Info().Msgf("Starting gRPC server on port %s", s.addr)
+
+	lis, err := net.Listen("tcp", s.addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen for gRPC: %w", err)
+	}
+	if err := s.grpc.Serve(lis); err != nil && !errors.Is(err, grpc.ErrServerStopped) {
+		return fmt.Errorf("failed to start gRPC server: %w", err)
+	}
+	return nil
+}
+
+// Stop reports every service as not serving, so load balancers stop
+// routing calls, and waits for the pending calls to finish. Calls still
+// running when ctx is done are canceled.
+func (s *Server) Stop(ctx context.Context) error {
+	s.logger.Info().Msg("Shutting down gRPC server...")
+	s.health.Shutdown()
+
+	done := make(chan struct{})
+	go func() {
+		s.grpc.GracefulStop()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		s.logger.Info().Msg("gRPC server stopped")
+		return nil
+	case <-ctx.Done():
+		s.grpc.Stop()
+		return fmt.Errorf("failed to shutdown gRPC server: %w", ctx.Err())
+	}
+}
@@ -0,0 +1,67 @@
+package grpcserver
+
+import (
+	"errors"
+	"net/http"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/PrinceNarteh/go-boilerplate/internal/errs"
+)
+
+// statusCodes maps the HTTP statuses of application errors to gRPC codes
+var statusCodes = map[int]codes.Code{
+	http.StatusBadRequest:            codes.InvalidArgument,
+	http.StatusUnauthorized:          codes.Unauthenticated,
+	http.StatusForbidden:             codes.PermissionDenied,
+	http.StatusNotFound:              codes.NotFound,
+	http.StatusConflict:              codes.AlreadyExists,
+	http.StatusRequestEntityTooLarge: codes.ResourceExhausted,
+	http.StatusTooManyRequests:       codes.ResourceExhausted,
+	http.StatusServiceUnavailable:    codes.Unavailable,
+}
+
+// toStatus converts an error of the services to a gRPC status error. The
+// messages of application errors are kept, and other errors are reported
+// as Internal without details, as errs.WriteJSON does.
+func toStatus(err error) error {
+	var appErr *errs.AppError
+	if !errors.As(err, &appErr) {
+		return status.Error(codes.Internal, errs.ErrInternal.Message)
+	}
+	code, ok := statusCodes[appErr.Status]
+	if !ok {
+		code = codes.Internal
+	}
+	return status.Error(code, appErr.Message)
+}
+
+// statusErrors maps gRPC codes to the application errors of the HTTP API
+var statusErrors = map[codes.Code]*errs.AppError{
+	codes.InvalidArgument:    errs.ErrValidation,
+	codes.OutOfRange:         errs.ErrValidation,
+	codes.FailedPrecondition: errs.ErrBadRequest,
+	codes.Unauthenticated:    errs.ErrUnauthorized,
+	codes.PermissionDenied:   errs.ErrForbidden,
+	codes.NotFound:           errs.ErrNotFound,
+	codes.AlreadyExists:      errs.ErrConflict,
+	codes.Aborted:            errs.ErrConflict,
+	codes.ResourceExhausted:  errs.ErrTooManyRequests,
+	codes.Unavailable:        errs.ErrUnavailable,
+}
+
+// fromStatus converts a gRPC status error to an application error, so
+// transcoded calls fail as the HTTP endpoints do. The messages of client
+// errors are kept, and other codes are reported as Internal.
+func fromStatus(err error) *errs.AppError {
+	st := status.Convert(err)
+	appErr, ok := statusErrors[st.Code()]
+	if !ok {
+		return errs.ErrInternal
+	}
+	if st.Message() == "" {
+		return appErr
+	}
+	return errs.New(appErr.Code, st.Message(), appErr.Status)
+}
@@ -0,0 +1,130 @@
+package grpcserver
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/PrinceNarteh/go-boilerplate/internal/auth"
+	userv1 "github.com/PrinceNarteh/go-boilerplate/internal/grpcserver/gen/user/v1"
+	"github.com/PrinceNarteh/go-boilerplate/internal/libs"
+	"github.com/PrinceNarteh/go-boilerplate/internal/libs/pagination"
+	"github.com/PrinceNarteh/go-boilerplate/internal/models"
+	"github.com/PrinceNarteh/go-boilerplate/internal/services"
+)
+
+// UserService implements userv1.UserServiceServer with services.UserService.
+// As the /api/v1/users endpoints, it is restricted to administrators.
+type UserService struct {
+	userv1.UnimplementedUserServiceServer
+
+	users *services.UserService
+}
+
+// NewUserService creates the gRPC user service
+func NewUserService(users *services.UserService) *UserService {
+	return &UserService{users: users}
+}
+
+// GetUser implements userv1.UserServiceServer
+func (s *UserService) GetUser(ctx context.Context, req *userv1.GetUserRequest) (*userv1.User, error) {
+	if err := requireAdmin(ctx); err != nil {
+		return nil, err
+	}
+
+	user, err := s.users.Get(ctx, int(req.GetId()))
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return toUser(user), nil
+}
+
+// ListUsers implements userv1.UserServiceServer
+func (s *UserService) ListUsers(ctx context.Context, req *userv1.ListUsersRequest) (*userv1.ListUsersResponse, error) {
+	if err := requireAdmin(ctx); err != nil {
+		return nil, err
+	}
+
+	size := int(req.GetPageSize())
+	switch {
+	case size < 0:
+		return nil, status.Error(codes.InvalidArgument, "page_size must not be negative")
+	case size == 0:
+		size = pagination.DefaultPerPage
+	case size > pagination.MaxPerPage:
+		size = pagination.MaxPerPage
+	}
+
+	users, next, err := s.users.List(ctx, req.GetPageToken(), size)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	resp := &userv1.ListUsersResponse{
+		Users:         make([]*userv1.User, len(users)),
+		NextPageToken: next,
+	}
+	for i, user := range users {
+		resp.Users[i] = toUser(user)
+	}
+	return resp, nil
+}
+
+// CreateUser implements userv1.UserServiceServer
+func (s *UserService) CreateUser(ctx context.Context, req *userv1.CreateUserRequest) (*userv1.User, error) {
+	if err := requireAdmin(ctx); err != nil {
+		return nil, err
+	}
+
+	create := models.CreateUserRequest{Email: req.GetEmail()}
+	if err := validate(create); err != nil {
+		return nil, err
+	}
+
+	user, err := s.users.Create(ctx, create)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return toUser(user), nil
+}
+
+// requireAdmin fails unless the caller is an administrator
+func requireAdmin(ctx context.Context) error {
+	principal, ok := auth.FromContext(ctx)
+	if !ok || !principal.HasRole(auth.RoleAdmin) {
+		return status.Error(codes.PermissionDenied, "Forbidden")
+	}
+	return nil
+}
+
+// validate validates req as the HTTP handlers do, reporting the invalid
+// fields as an InvalidArgument error
+func validate(req any) error {
+	messages := libs.FieldMessages(libs.Validate(req))
+	if messages == nil {
+		return nil
+	}
+
+	fields := make([]string, 0, len(messages))
+	for field, message := range messages {
+		fields = append(fields, fmt.Sprintf("%s: %s", field, message))
+	}
+	sort.Strings(fields)
+	return status.Error(codes.InvalidArgument, strings.Join(fields, "; "))
+}
+
+// toUser converts a user to its message
+func toUser(user *models.User) *userv1.User {
+	return &userv1.User{
+		Id:        int64(user.ID),
+		Email:     user.Email,
+		CreatedAt: timestamppb.New(user.CreatedAt),
+		UpdatedAt: timestamppb.New(user.UpdatedAt),
+		Version:   int64(user.Version),
+	}
+}
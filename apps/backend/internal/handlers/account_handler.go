@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/PrinceNarteh/go-boilerplate/internal/audit"
+	"github.com/PrinceNarteh/go-boilerplate/internal/auth"
+	"github.com/PrinceNarteh/go-boilerplate/internal/errs"
+	"github.com/PrinceNarteh/go-boilerplate/internal/libs/id"
+	"github.com/PrinceNarteh/go-boilerplate/internal/libs/pagination"
+	"github.com/PrinceNarteh/go-boilerplate/internal/middlewares"
+	"github.com/PrinceNarteh/go-boilerplate/internal/models"
+	"github.com/PrinceNarteh/go-boilerplate/internal/routers"
+	"github.com/PrinceNarteh/go-boilerplate/internal/services"
+)
+
+// passwordChangeThrottle limits the current passwords a token can try
+var passwordChangeThrottle = middlewares.DeclareThrottle(middlewares.Throttle{
+	Name:        "account.password",
+	Limit:       5,
+	Window:      15 * time.Minute,
+	Concurrency: 1,
+})
+
+// AccountHandler serves the self-serve account endpoints of the
+// authenticated user: profile, password, sessions and security log
+type AccountHandler struct {
+	users        *services.UserService
+	accounts     *services.AccountService
+	authenticate middlewares.Middleware
+}
+
+// NewAccountHandler creates a new account handler.
+// authenticate is the middleware used to authenticate users.
+func NewAccountHandler(
+	users *services.UserService,
+	accounts *services.AccountService,
+	authenticate middlewares.Middleware,
+) *AccountHandler {
+	return &AccountHandler{
+		users:        users,
+		accounts:     accounts,
+		authenticate: authenticate,
+	}
+}
+
+// RegisterRoutes implements routers.Module
+func (h *AccountHandler) RegisterRoutes(g *routers.RouteGroup) {
+	me := g.Group("/me", h.authenticate)
+	me.GET("", routers.Handler(h.profile))
+	me.PATCH("", routers.Handler(h.updateProfile))
+	me.PUT("/password", routers.Handler(h.changePassword), passwordChangeThrottle.Middleware())
+	me.GET("/sessions", routers.Handler(h.listSessions))
+	me.DELETE("/sessions", routers.Handler(h.revokeOtherSessions))
+	me.DELETE("/sessions/{id}", routers.Handler(h.revokeSession))
+	me.GET("/security-events", routers.Handler(h.securityEvents))
+}
+
+// profile returns the user's account
+func (h *AccountHandler) profile(r *http.Request, _ struct{}) (*models.UserResponse, error) {
+	principal, _ := auth.FromContext(r.Context())
+
+	user, err := h.users.Get(r.Context(), principal.UserID)
+	if err != nil {
+		return nil, err
+	}
+	return user.ToResponse(), nil
+}
+
+// updateProfile merges the preferences of the user's account
+func (h *AccountHandler) updateProfile(r *http.Request, req models.UpdateProfileRequest) (*models.UserResponse, error) {
+	principal, _ := auth.FromContext(r.Context())
+
+	user, err := h.users.Update(r.Context(), principal.UserID, models.UpdateUserRequest{Preferences: req.Preferences})
+	if err != nil {
+		return nil, err
+	}
+	return user.ToResponse(), nil
+}
+
+// changePassword changes the user's password and signs out their other sessions
+func (h *AccountHandler) changePassword(r *http.Request, req models.ChangePasswordRequest) (routers.NoContent, error) {
+	return routers.NoContent{}, h.accounts.ChangePassword(r.Context(), audit.FromRequest(r, audit.PasswordChanged), req)
+}
+
+// listSessions returns the user's active sessions, with the current one marked
+func (h *AccountHandler) listSessions(r *http.Request, _ struct{}) ([]*models.Session, error) {
+	principal, _ := auth.FromContext(r.Context())
+
+	current, _ := id.Parse(principal.SessionID)
+	return h.accounts.ListSessions(r.Context(), principal.UserID, current)
+}
+
+// revokeSession signs out one of the user's sessions
+func (h *AccountHandler) revokeSession(r *http.Request, _ struct{}) (routers.NoContent, error) {
+	sessionID, err := id.Parse(routers.Param(r, "id"))
+	if err != nil {
+		middlewares.RecordBindingFailure(r.Context(), "path", "type", "id")
+		return routers.NoContent{}, errs.NewValidation("id must be a session ID")
+	}
+	return routers.NoContent{}, h.accounts.RevokeSession(r.Context(), audit.FromRequest(r, audit.SessionRevoked), sessionID)
+}
+
+// revokeOtherSessions signs out every session of the user but the current one
+func (h *AccountHandler) revokeOtherSessions(r *http.Request, _ struct{}) (*models.RevokeSessionsResponse, error) {
+	revoked, err := h.accounts.RevokeOtherSessions(r.Context(), audit.FromRequest(r, audit.SessionsRevoked))
+	if err != nil {
+		return nil, err
+	}
+	return &models.RevokeSessionsResponse{Revoked: revoked}, nil
+}
+
+// securityEvents returns a page of the user's security log, newest first
+func (h *AccountHandler) securityEvents(r *http.Request, _ struct{}) (pagination.CursorPage[*audit.Event], error) {
+	principal, _ := auth.FromContext(r.Context())
+
+	params, err := pagination.ParseCursor(r.URL.Query(), pagination.Options{})
+	if err != nil {
+		return pagination.CursorPage[*audit.Event]{}, err
+	}
+
+	events, next, err := h.accounts.SecurityEvents(r.Context(), principal.UserID, params.Cursor, params.Limit)
+	if err != nil {
+		return pagination.CursorPage[*audit.Event]{}, err
+	}
+	return pagination.NewCursorPage(events, next), nil
+}
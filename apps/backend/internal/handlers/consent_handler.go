@@ -0,0 +1,121 @@
+// Package handlers contains the HTTP handlers of the application's feature modules
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/PrinceNarteh/go-boilerplate/internal/auth"
+	"github.com/PrinceNarteh/go-boilerplate/internal/errs"
+	"github.com/PrinceNarteh/go-boilerplate/internal/middlewares"
+	"github.com/PrinceNarteh/go-boilerplate/internal/models"
+	"github.com/PrinceNarteh/go-boilerplate/internal/repositories"
+	"github.com/PrinceNarteh/go-boilerplate/internal/routers"
+)
+
+// ConsentHandler serves the terms-of-service and consent endpoints
+type ConsentHandler struct {
+	repo         repositories.ConsentRepository
+	authenticate middlewares.Middleware
+}
+
+// NewConsentHandler creates a new consent handler.
+// authenticate is the middleware used to authenticate users.
+func NewConsentHandler(repo repositories.ConsentRepository, authenticate middlewares.Middleware) *ConsentHandler {
+	return &ConsentHandler{
+		repo:         repo,
+		authenticate: authenticate,
+	}
+}
+
+// RegisterRoutes implements routers.Module
+func (h *ConsentHandler) RegisterRoutes(g *routers.RouteGroup) {
+	g.GET("/consents/documents", routers.Handler(h.listDocuments))
+
+	me := g.Group("/me/consents", h.authenticate)
+	me.GET("", routers.Handler(h.status))
+	me.POST("", routers.Handler(h.accept))
+
+	admin := g.Group("/admin/consents", h.authenticate, middlewares.RequireRole(auth.RoleAdmin))
+	admin.POST("/documents", routers.Handler(h.publish))
+}
+
+// listDocuments returns the current version of every consent document
+func (h *ConsentHandler) listDocuments(r *http.Request, _ struct{}) ([]*models.ConsentDocument, error) {
+	docs, err := h.repo.LatestDocuments(r.Context())
+	if err != nil {
+		return nil, err
+	}
+	if docs == nil {
+		docs = []*models.ConsentDocument{}
+	}
+	return docs, nil
+}
+
+// status returns the documents the user accepted and those still pending
+func (h *ConsentHandler) status(r *http.Request, _ struct{}) (*models.ConsentStatusResponse, error) {
+	principal, _ := auth.FromContext(r.Context())
+
+	accepted, err := h.repo.ListByUser(r.Context(), principal.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	pending, err := h.repo.PendingDocuments(r.Context(), principal.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &models.ConsentStatusResponse{Accepted: accepted, Pending: pending}
+	if resp.Accepted == nil {
+		resp.Accepted = []*models.UserConsent{}
+	}
+	if resp.Pending == nil {
+		resp.Pending = []*models.ConsentDocument{}
+	}
+	return resp, nil
+}
+
+// accept records the user's acceptance of a document version
+func (h *ConsentHandler) accept(
+	r *http.Request,
+	req models.AcceptConsentRequest,
+) (routers.Created[*models.UserConsent], error) {
+	principal, _ := auth.FromContext(r.Context())
+
+	if _, err := h.repo.GetDocument(r.Context(), req.DocumentID); err != nil {
+		if errors.Is(err, errs.ErrNotFound) {
+			return routers.Created[*models.UserConsent]{}, errs.NewNotFound("Consent document")
+		}
+		return routers.Created[*models.UserConsent]{}, err
+	}
+
+	consent, err := h.repo.Accept(r.Context(), &models.UserConsent{
+		UserID:     principal.UserID,
+		DocumentID: req.DocumentID,
+		IP:         middlewares.ClientIP(r),
+		UserAgent:  r.UserAgent(),
+	})
+	if err != nil {
+		return routers.Created[*models.UserConsent]{}, err
+	}
+
+	return routers.Created[*models.UserConsent]{Data: consent}, nil
+}
+
+// publish publishes a new document version, requiring every user to accept it again
+func (h *ConsentHandler) publish(
+	r *http.Request,
+	req models.PublishConsentDocumentRequest,
+) (routers.Created[*models.ConsentDocument], error) {
+	doc, err := h.repo.CreateDocument(r.Context(), &models.ConsentDocument{
+		Kind:    req.Kind,
+		Version: req.Version,
+		URL:     req.URL,
+	})
+	if err != nil {
+		return routers.Created[*models.ConsentDocument]{}, err
+	}
+
+	return routers.Created[*models.ConsentDocument]{Data: doc}, nil
+}
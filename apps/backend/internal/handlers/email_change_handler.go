@@ -0,0 +1,267 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/PrinceNarteh/go-boilerplate/internal/auth"
+	"github.com/PrinceNarteh/go-boilerplate/internal/errs"
+	"github.com/PrinceNarteh/go-boilerplate/internal/mailer"
+	"github.com/PrinceNarteh/go-boilerplate/internal/middlewares"
+	"github.com/PrinceNarteh/go-boilerplate/internal/models"
+	"github.com/PrinceNarteh/go-boilerplate/internal/repositories"
+	"github.com/PrinceNarteh/go-boilerplate/internal/routers"
+)
+
+const (
+	emailChangeTTL          = 24 * time.Hour     // How long the confirmation token sent to the new address is valid
+	emailChangeRevertWindow = 7 * 24 * time.Hour // How long the old address can revert a confirmed change
+)
+
+// errInvalidEmailChangeToken is returned for unknown, expired or already used tokens
+var errInvalidEmailChangeToken = errs.NewValidation("Invalid or expired token")
+
+// emailChangeEmail is the data of the email change templates
+type emailChangeEmail struct {
+	NewEmail string
+	Token    string
+	Until    string // Expiry of the token, formatted with formatEmailTime
+}
+
+// sampleEmailChange is the sample data of the email change templates
+var sampleEmailChange = emailChangeEmail{
+	NewEmail: "new.address@example.com",
+	Token:    "3q2-7wYs_sample-token",
+	Until:    "2025-01-02 15:04 UTC",
+}
+
+var (
+	emailChangeConfirmTemplate = mailer.Register(mailer.Template{
+		Name:        "email_change.confirm",
+		Description: "Sent to the new address to confirm an email change",
+		Subject:     "Confirm your new email address",
+		Text: "Use this token to confirm {{.NewEmail}} as the email address of your account:\n\n{{.Token}}\n\n" +
+			"The token expires at {{.Until}}. If you did not request this change, ignore this email.",
+		Sample: sampleEmailChange,
+	})
+	emailChangeRequestedTemplate = mailer.Register(mailer.Template{
+		Name:        "email_change.requested",
+		Description: "Sent to the old address when an email change is requested",
+		Subject:     "Email change requested",
+		Text: "A change of your account email address to {{.NewEmail}} was requested. It takes effect " +
+			"once confirmed from the new address.\n\n" +
+			"If this was not you, sign in and cancel the change, then change your password.",
+		Sample: sampleEmailChange,
+	})
+	emailChangeConfirmedTemplate = mailer.Register(mailer.Template{
+		Name:        "email_change.confirmed",
+		Description: "Sent to the old address with the revert token once an email change is confirmed",
+		Subject:     "Your email address was changed",
+		Text: "The email address of your account was changed to {{.NewEmail}}.\n\n" +
+			"If this was not you, use this token to restore this address until {{.Until}}:\n\n{{.Token}}",
+		Sample: sampleEmailChange,
+	})
+	emailChangeRevertedTemplate = mailer.Register(mailer.Template{
+		Name:        "email_change.reverted",
+		Description: "Sent to the restored address once an email change is reverted",
+		Subject:     "Your email address was restored",
+		Text: "The email address of your account was restored to this address. " +
+			"We recommend changing your password now.",
+		Sample: sampleEmailChange,
+	})
+)
+
+var (
+	// emailChangeRequestThrottle limits the emails a user can send to
+	// addresses they do not own yet
+	emailChangeRequestThrottle = middlewares.DeclareThrottle(middlewares.Throttle{
+		Name:        "email_change.request",
+		Limit:       5,
+		Window:      time.Hour,
+		Concurrency: 1,
+	})
+	// emailChangeTokenThrottle limits token guessing, per IP as these
+	// routes are anonymous
+	emailChangeTokenThrottle = middlewares.DeclareThrottle(middlewares.Throttle{
+		Name:   "email_change.token",
+		Limit:  10,
+		Window: 15 * time.Minute,
+	})
+)
+
+// EmailChangeHandler serves the email change flow.
+//
+// A change is requested by the signed-in user and confirmed with a token sent
+// to the new address, while the old address is notified. Once confirmed, the
+// old address receives a revert token that restores it during the revert window.
+type EmailChangeHandler struct {
+	changes      repositories.EmailChangeRepository
+	users        repositories.UserRepository
+	mailer       mailer.Mailer
+	authenticate middlewares.Middleware
+}
+
+// NewEmailChangeHandler creates a new email change handler.
+// authenticate is the middleware used to authenticate users.
+func NewEmailChangeHandler(
+	changes repositories.EmailChangeRepository,
+	users repositories.UserRepository,
+	m mailer.Mailer,
+	authenticate middlewares.Middleware,
+) *EmailChangeHandler {
+	return &EmailChangeHandler{
+		changes:      changes,
+		users:        users,
+		mailer:       m,
+		authenticate: authenticate,
+	}
+}
+
+// RegisterRoutes implements routers.Module
+func (h *EmailChangeHandler) RegisterRoutes(g *routers.RouteGroup) {
+	me := g.Group("/me/email-change", h.authenticate)
+	me.GET("", routers.Handler(h.pending))
+	me.POST("", routers.Handler(h.request), emailChangeRequestThrottle.Middleware())
+	me.DELETE("", routers.Handler(h.cancel))
+
+	// Confirm and revert are authorized by the token alone, as they are
+	// reached from links in emails that may be opened on another device
+	g.POST("/email-change/confirm", routers.Handler(h.confirm), emailChangeTokenThrottle.Middleware())
+	g.POST("/email-change/revert", routers.Handler(h.revert), emailChangeTokenThrottle.Middleware())
+}
+
+// pending returns the user's pending email change
+func (h *EmailChangeHandler) pending(r *http.Request, _ struct{}) (*models.EmailChange, error) {
+	principal, _ := auth.FromContext(r.Context())
+
+	change, err := h.changes.GetPending(r.Context(), principal.UserID)
+	if errors.Is(err, errs.ErrNotFound) {
+		return nil, errs.NewNotFound("Pending email change")
+	}
+	return change, err
+}
+
+// request starts an email change, replacing any pending one
+func (h *EmailChangeHandler) request(
+	r *http.Request,
+	req models.RequestEmailChangeRequest,
+) (routers.Created[*models.EmailChange], error) {
+	ctx := r.Context()
+	principal, _ := auth.FromContext(ctx)
+	newEmail := models.NormalizeEmail(req.Email)
+
+	user, err := h.users.GetByID(ctx, principal.UserID)
+	if err != nil {
+		return routers.Created[*models.EmailChange]{}, err
+	}
+	if strings.EqualFold(user.Email, newEmail) {
+		return routers.Created[*models.EmailChange]{}, errs.NewValidation("New email must differ from the current email")
+	}
+
+	if _, err := h.users.GetByEmail(ctx, newEmail); err == nil {
+		return routers.Created[*models.EmailChange]{}, errs.ErrConflict
+	} else if !errors.Is(err, errs.ErrNotFound) {
+		return routers.Created[*models.EmailChange]{}, err
+	}
+
+	token, hash, err := auth.NewOpaqueToken()
+	if err != nil {
+		return routers.Created[*models.EmailChange]{}, err
+	}
+
+	change, err := h.changes.Create(ctx, &models.EmailChange{
+		UserID:    user.ID,
+		OldEmail:  user.Email,
+		NewEmail:  newEmail,
+		ExpiresAt: time.Now().Add(emailChangeTTL),
+	}, hash)
+	if err != nil {
+		return routers.Created[*models.EmailChange]{}, err
+	}
+
+	h.send(ctx, emailChangeConfirmTemplate, change.NewEmail, emailChangeEmail{
+		NewEmail: change.NewEmail,
+		Token:    token,
+		Until:    formatEmailTime(change.ExpiresAt),
+	})
+	h.send(ctx, emailChangeRequestedTemplate, change.OldEmail, emailChangeEmail{NewEmail: change.NewEmail})
+
+	return routers.Created[*models.EmailChange]{Data: change}, nil
+}
+
+// cancel cancels the user's pending email change
+func (h *EmailChangeHandler) cancel(r *http.Request, _ struct{}) (routers.NoContent, error) {
+	principal, _ := auth.FromContext(r.Context())
+	return routers.NoContent{}, h.changes.CancelPending(r.Context(), principal.UserID)
+}
+
+// confirm applies a pending email change and sends the revert token to the old address
+func (h *EmailChangeHandler) confirm(r *http.Request, req models.EmailChangeTokenRequest) (*models.EmailChange, error) {
+	ctx := r.Context()
+
+	revertToken, revertHash, err := auth.NewOpaqueToken()
+	if err != nil {
+		return nil, err
+	}
+
+	change, err := h.changes.Confirm(ctx, auth.HashToken(req.Token), revertHash, emailChangeRevertWindow)
+	if err != nil {
+		return nil, emailChangeError(err)
+	}
+
+	h.send(ctx, emailChangeConfirmedTemplate, change.OldEmail, emailChangeEmail{
+		NewEmail: change.NewEmail,
+		Token:    revertToken,
+		Until:    formatEmailTime(*change.RevertUntil),
+	})
+
+	return change, nil
+}
+
+// revert restores the previous email address of a confirmed change
+func (h *EmailChangeHandler) revert(r *http.Request, req models.EmailChangeTokenRequest) (*models.EmailChange, error) {
+	ctx := r.Context()
+
+	change, err := h.changes.Revert(ctx, auth.HashToken(req.Token))
+	if err != nil {
+		return nil, emailChangeError(err)
+	}
+
+	h.send(ctx, emailChangeRevertedTemplate, change.OldEmail, emailChangeEmail{})
+
+	return change, nil
+}
+
+// send renders and sends a notification email. Failures are logged rather
+// than returned because the change itself has already been recorded.
+func (h *EmailChangeHandler) send(ctx context.Context, tmpl *mailer.Template, to string, data emailChangeEmail) {
+	msg, err := tmpl.Render(to, data)
+	if err == nil {
+		err = h.mailer.Send(ctx, msg)
+	}
+	if err != nil {
+		zerolog.Ctx(ctx).Error().Err(err).Str("template", tmpl.Name).Msg("Failed to send email change notification")
+	}
+}
+
+// emailChangeError maps repository errors of the confirm and revert steps to API errors
+func emailChangeError(err error) error {
+	switch {
+	case errors.Is(err, errs.ErrNotFound):
+		return errInvalidEmailChangeToken
+	case errors.Is(err, repositories.ErrEmailChanged), errors.Is(err, errs.ErrConflict):
+		return errs.ErrConflict
+	default:
+		return err
+	}
+}
+
+// formatEmailTime formats a time for display in emails
+func formatEmailTime(t time.Time) string {
+	return t.UTC().Format("2006-01-02 15:04 MST")
+}
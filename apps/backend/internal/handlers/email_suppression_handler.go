@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/rs/zerolog"
+
+	"github.com/PrinceNarteh/go-boilerplate/internal/auth"
+	"github.com/PrinceNarteh/go-boilerplate/internal/errs"
+	"github.com/PrinceNarteh/go-boilerplate/internal/libs/pagination"
+	"github.com/PrinceNarteh/go-boilerplate/internal/mailer"
+	"github.com/PrinceNarteh/go-boilerplate/internal/middlewares"
+	"github.com/PrinceNarteh/go-boilerplate/internal/routers"
+)
+
+// EmailSuppressionHandler serves the webhooks through which the email
+// provider reports bounces and complaints, suppressing their addresses, and
+// the admin endpoints reviewing and clearing suppressions
+type EmailSuppressionHandler struct {
+	suppressions  mailer.SuppressionList
+	webhookSecret string
+	authenticate  middlewares.Middleware
+}
+
+// NewEmailSuppressionHandler creates a new email suppression handler.
+// webhookSecret authenticates the webhooks, which are not served when it is
+// empty, and authenticate is the middleware used to authenticate users.
+func NewEmailSuppressionHandler(
+	suppressions mailer.SuppressionList,
+	webhookSecret string,
+	authenticate middlewares.Middleware,
+) *EmailSuppressionHandler {
+	return &EmailSuppressionHandler{
+		suppressions:  suppressions,
+		webhookSecret: webhookSecret,
+		authenticate:  authenticate,
+	}
+}
+
+// RegisterRoutes implements routers.Module
+func (h *EmailSuppressionHandler) RegisterRoutes(g *routers.RouteGroup) {
+	if h.webhookSecret != "" {
+		g.POST("/webhooks/email/{provider}", routers.Handler(h.feedback), h.verifyWebhook)
+	}
+
+	admin := g.Group("/admin/email-suppressions", h.authenticate, middlewares.RequireRole(auth.RoleAdmin))
+	admin.GET("", routers.Handler(h.list))
+	admin.DELETE("/{email}", routers.Handler(h.remove))
+}
+
+// verifyWebhook rejects the webhooks without the secret as the password of
+// their basic auth
+func (h *EmailSuppressionHandler) verifyWebhook(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, password, ok := r.BasicAuth()
+		if !ok || subtle.ConstantTimeCompare([]byte(password), []byte(h.webhookSecret)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Basic realm="email webhooks"`)
+			errs.WriteJSON(w, errs.ErrUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// feedback suppresses the addresses of the hard bounces and complaints
+// reported by a provider. Other events are acknowledged and dropped, so the
+// provider does not retry them.
+func (h *EmailSuppressionHandler) feedback(r *http.Request, body json.RawMessage) (routers.NoContent, error) {
+	provider := routers.Param(r, "provider")
+	parse, ok := mailer.LookupFeedbackParser(provider)
+	if !ok {
+		return routers.NoContent{}, errs.NewNotFound("Email provider")
+	}
+
+	suppressions, err := parse(body)
+	if err != nil {
+		return routers.NoContent{}, errs.NewValidation(err.Error())
+	}
+
+	logger := zerolog.Ctx(r.Context())
+	for i := range suppressions {
+		s := &suppressions[i]
+		if err := h.suppressions.Suppress(r.Context(), s); err != nil {
+			return routers.NoContent{}, err
+		}
+		logger.Info().
+			Str("provider", provider).
+			Str("reason", string(s.Reason)).
+			Int64("suppression_id", s.ID).
+			Msg("Email address suppressed")
+	}
+	return routers.NoContent{}, nil
+}
+
+// list returns a page of suppressions, newest first
+func (h *EmailSuppressionHandler) list(r *http.Request, _ struct{}) (pagination.CursorPage[*mailer.Suppression], error) {
+	params, err := pagination.ParseCursor(r.URL.Query(), pagination.Options{})
+	if err != nil {
+		return pagination.CursorPage[*mailer.Suppression]{}, err
+	}
+
+	suppressions, next, err := h.suppressions.List(r.Context(), params.Cursor, params.Limit)
+	if err != nil {
+		return pagination.CursorPage[*mailer.Suppression]{}, err
+	}
+	return pagination.NewCursorPage(suppressions, next), nil
+}
+
+// remove clears the suppression of an address, so emails are sent to it again
+func (h *EmailSuppressionHandler) remove(r *http.Request, _ struct{}) (routers.NoContent, error) {
+	err := h.suppressions.Remove(r.Context(), routers.Param(r, "email"))
+	if errors.Is(err, errs.ErrNotFound) {
+		return routers.NoContent{}, errs.NewNotFound("Email suppression")
+	}
+	return routers.NoContent{}, err
+}
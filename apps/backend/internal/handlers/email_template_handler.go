@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"html/template"
+	"net/http"
+
+	"github.com/rs/zerolog"
+
+	"github.com/PrinceNarteh/go-boilerplate/internal/errs"
+	"github.com/PrinceNarteh/go-boilerplate/internal/mailer"
+	"github.com/PrinceNarteh/go-boilerplate/internal/models"
+	"github.com/PrinceNarteh/go-boilerplate/internal/routers"
+)
+
+// emailPreviewPage shows a rendered email in the browser, the HTML part in
+// a sandboxed frame so its styles do not leak into the page
+var emailPreviewPage = template.Must(template.New("preview").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>{{.Name}}</title></head>
+<body style="font-family: sans-serif; margin: 2em">
+<p><a href="../emails">All templates</a></p>
+<h1>{{.Message.Subject}}</h1>
+<p>{{.Name}}: {{.Description}}</p>
+{{if .Message.HTML}}
+<h2>HTML</h2>
+<iframe sandbox srcdoc="{{.Message.HTML}}" style="width: 100%; height: 30em; border: 1px solid #ccc"></iframe>
+{{end}}
+<h2>Text</h2>
+<pre style="white-space: pre-wrap; border: 1px solid #ccc; padding: 1em">{{.Message.Text}}</pre>
+</body>
+</html>
+`))
+
+// EmailTemplateHandler serves development endpoints previewing the
+// registered email templates with their sample data and sending them to a
+// given address. It has no authentication and must only be registered in
+// development.
+type EmailTemplateHandler struct {
+	mailer mailer.Mailer
+}
+
+// NewEmailTemplateHandler creates a new email template handler sending test emails with m
+func NewEmailTemplateHandler(m mailer.Mailer) *EmailTemplateHandler {
+	return &EmailTemplateHandler{mailer: m}
+}
+
+// RegisterRoutes implements routers.Module
+func (h *EmailTemplateHandler) RegisterRoutes(g *routers.RouteGroup) {
+	dev := g.Group("/dev/emails")
+	dev.GET("", routers.Handler(h.list))
+	dev.GET("/{name}", h.preview)
+	dev.POST("/{name}/send", routers.Handler(h.send))
+}
+
+// list returns the registered templates
+func (h *EmailTemplateHandler) list(_ *http.Request, _ struct{}) ([]models.EmailTemplate, error) {
+	templates := mailer.Templates()
+	list := make([]models.EmailTemplate, len(templates))
+	for i, t := range templates {
+		list[i] = models.EmailTemplate{Name: t.Name, Description: t.Description, HasHTML: t.HTML != ""}
+	}
+	return list, nil
+}
+
+// preview renders a template with its sample data as an HTML page
+func (h *EmailTemplateHandler) preview(w http.ResponseWriter, r *http.Request) {
+	tmpl, err := lookupEmailTemplate(r)
+	if err != nil {
+		errs.WriteJSON(w, err)
+		return
+	}
+
+	msg, err := tmpl.RenderSample("")
+	if err != nil {
+		zerolog.Ctx(r.Context()).Error().Err(err).Str("template", tmpl.Name).Msg("Failed to render email template")
+		errs.WriteJSON(w, errs.NewInternal(err.Error()))
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	emailPreviewPage.Execute(w, struct {
+		*mailer.Template
+		Message mailer.Message
+	}{tmpl, msg})
+}
+
+// send sends a template with its sample data to the requested address
+func (h *EmailTemplateHandler) send(r *http.Request, req models.SendTestEmailRequest) (routers.NoContent, error) {
+	tmpl, err := lookupEmailTemplate(r)
+	if err != nil {
+		return routers.NoContent{}, err
+	}
+
+	msg, err := tmpl.RenderSample(req.To)
+	if err != nil {
+		return routers.NoContent{}, err
+	}
+	return routers.NoContent{}, h.mailer.Send(r.Context(), msg)
+}
+
+// lookupEmailTemplate returns the template named by the path
+func lookupEmailTemplate(r *http.Request) (*mailer.Template, error) {
+	tmpl, ok := mailer.Lookup(routers.Param(r, "name"))
+	if !ok {
+		return nil, errs.NewNotFound("Email template")
+	}
+	return tmpl, nil
+}
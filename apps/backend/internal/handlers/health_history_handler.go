@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/PrinceNarteh/go-boilerplate/internal/auth"
+	"github.com/PrinceNarteh/go-boilerplate/internal/errs"
+	"github.com/PrinceNarteh/go-boilerplate/internal/healthcheck"
+	"github.com/PrinceNarteh/go-boilerplate/internal/middlewares"
+	"github.com/PrinceNarteh/go-boilerplate/internal/routers"
+)
+
+// defaultHealthHistoryWindow is the period of the history returned when the
+// since query parameter is not set
+const defaultHealthHistoryWindow = 24 * time.Hour
+
+// HealthHistoryHandler serves the admin endpoints reporting the availability
+// and flapping of the health checks from their history
+type HealthHistoryHandler struct {
+	health       *healthcheck.Service
+	authenticate middlewares.Middleware
+}
+
+// NewHealthHistoryHandler creates a new health history handler.
+// authenticate is the middleware used to authenticate users.
+func NewHealthHistoryHandler(health *healthcheck.Service, authenticate middlewares.Middleware) *HealthHistoryHandler {
+	return &HealthHistoryHandler{
+		health:       health,
+		authenticate: authenticate,
+	}
+}
+
+// RegisterRoutes implements routers.Module
+func (h *HealthHistoryHandler) RegisterRoutes(g *routers.RouteGroup) {
+	admin := g.Group("/admin/health", h.authenticate, middlewares.RequireRole(auth.RoleAdmin))
+	admin.GET("/history", routers.Handler(h.list))
+	admin.GET("/history/{name}", routers.Handler(h.get))
+}
+
+// list returns the availability of every check, without their samples
+func (h *HealthHistoryHandler) list(r *http.Request, _ struct{}) ([]healthcheck.CheckHistory, error) {
+	since, err := historySince(r)
+	if err != nil {
+		return nil, err
+	}
+
+	checks := h.health.Checks()
+	histories := make([]healthcheck.CheckHistory, 0, len(checks))
+	for _, name := range checks {
+		history, err := h.health.CheckHistory(r.Context(), name, since)
+		if err != nil {
+			return nil, err
+		}
+		history.Samples = nil
+		histories = append(histories, history)
+	}
+	return histories, nil
+}
+
+// get returns the availability of a check with its samples
+func (h *HealthHistoryHandler) get(r *http.Request, _ struct{}) (healthcheck.CheckHistory, error) {
+	name := routers.Param(r, "name")
+	if !slices.Contains(h.health.Checks(), name) {
+		return healthcheck.CheckHistory{}, errs.NewNotFound("Health check")
+	}
+
+	since, err := historySince(r)
+	if err != nil {
+		return healthcheck.CheckHistory{}, err
+	}
+	return h.health.CheckHistory(r.Context(), name, since)
+}
+
+// historySince returns the start of the requested history, from the since
+// query parameter, a duration such as 1h, which defaults to 24h
+func historySince(r *http.Request) (time.Time, error) {
+	window := defaultHealthHistoryWindow
+	if value := r.URL.Query().Get("since"); value != "" {
+		parsed, err := time.ParseDuration(value)
+		if err != nil || parsed <= 0 {
+			middlewares.RecordBindingFailure(r.Context(), "query", "value", "since")
+			return time.Time{}, errs.NewValidation("since must be a positive duration, such as 1h")
+		}
+		window = parsed
+	}
+	return time.Now().Add(-window), nil
+}
@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/rs/zerolog"
+
+	"github.com/PrinceNarteh/go-boilerplate/internal/auth"
+	"github.com/PrinceNarteh/go-boilerplate/internal/logger"
+	"github.com/PrinceNarteh/go-boilerplate/internal/middlewares"
+	"github.com/PrinceNarteh/go-boilerplate/internal/routers"
+)
+
+// LogLevelHandler serves the admin endpoints reading and changing the log
+// level at runtime, without restarting the server
+type LogLevelHandler struct {
+	authenticate middlewares.Middleware
+}
+
+// NewLogLevelHandler creates a new log level handler.
+// authenticate is the middleware used to authenticate users.
+func NewLogLevelHandler(authenticate middlewares.Middleware) *LogLevelHandler {
+	return &LogLevelHandler{authenticate: authenticate}
+}
+
+// RegisterRoutes implements routers.Module
+func (h *LogLevelHandler) RegisterRoutes(g *routers.RouteGroup) {
+	admin := g.Group("/admin", h.authenticate, middlewares.RequireRole(auth.RoleAdmin))
+	admin.GET("/loglevel", routers.Handler(h.get))
+	admin.PUT("/loglevel", routers.Handler(h.set))
+}
+
+// LogLevel is the log level of the application
+type LogLevel struct {
+	Level string `json:"level" validate:"required,oneof=trace debug info warn error"`
+}
+
+// get returns the current log level
+func (h *LogLevelHandler) get(_ *http.Request, _ struct{}) (LogLevel, error) {
+	return LogLevel{Level: logger.Level().String()}, nil
+}
+
+// set changes the log level, logging who changed it. The entry is created
+// before the change, at a level the previous level records.
+func (h *LogLevelHandler) set(r *http.Request, req LogLevel) (LogLevel, error) {
+	level, err := zerolog.ParseLevel(req.Level)
+	if err != nil {
+		return LogLevel{}, err
+	}
+
+	previous := logger.Level()
+	userID, _ := auth.UserIDFromContext(r.Context())
+	log := zerolog.Ctx(r.Context()).WithLevel(max(previous, zerolog.InfoLevel))
+	logger.SetLevel(level)
+	log.Str("user_id", userID).
+		Str("previous", previous.String()).
+		Str("level", level.String()).
+		Msg("Log level changed")
+
+	return LogLevel{Level: level.String()}, nil
+}
@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/PrinceNarteh/go-boilerplate/internal/auth"
+	"github.com/PrinceNarteh/go-boilerplate/internal/errs"
+	"github.com/PrinceNarteh/go-boilerplate/internal/middlewares"
+	"github.com/PrinceNarteh/go-boilerplate/internal/models"
+	"github.com/PrinceNarteh/go-boilerplate/internal/notifications"
+	"github.com/PrinceNarteh/go-boilerplate/internal/repositories"
+	"github.com/PrinceNarteh/go-boilerplate/internal/routers"
+)
+
+// NotificationPreferenceHandler serves the notification preferences of the authenticated user
+type NotificationPreferenceHandler struct {
+	repo         repositories.NotificationPreferenceRepository
+	authenticate middlewares.Middleware
+}
+
+// NewNotificationPreferenceHandler creates a new notification preference handler.
+// authenticate is the middleware used to authenticate users.
+func NewNotificationPreferenceHandler(
+	repo repositories.NotificationPreferenceRepository,
+	authenticate middlewares.Middleware,
+) *NotificationPreferenceHandler {
+	return &NotificationPreferenceHandler{
+		repo:         repo,
+		authenticate: authenticate,
+	}
+}
+
+// RegisterRoutes implements routers.Module
+func (h *NotificationPreferenceHandler) RegisterRoutes(g *routers.RouteGroup) {
+	me := g.Group("/me/notification-preferences", h.authenticate)
+	me.GET("", routers.Handler(h.list))
+	me.PUT("", routers.Handler(h.update))
+}
+
+// list returns the channels of every kind of notification for the user
+func (h *NotificationPreferenceHandler) list(r *http.Request, _ struct{}) ([]models.NotificationKindSettings, error) {
+	principal, _ := auth.FromContext(r.Context())
+
+	prefs, err := h.repo.ListByUser(r.Context(), principal.UserID)
+	if err != nil {
+		return nil, err
+	}
+	return settings(prefs), nil
+}
+
+// update enables or disables kinds of notifications on channels, and
+// returns the resulting settings
+func (h *NotificationPreferenceHandler) update(
+	r *http.Request,
+	req models.UpdateNotificationPreferencesRequest,
+) ([]models.NotificationKindSettings, error) {
+	principal, _ := auth.FromContext(r.Context())
+
+	// The last update of a kind and channel wins
+	byKey := make(map[string]int, len(req.Preferences))
+	var prefs []*models.NotificationPreference
+	for i, update := range req.Preferences {
+		kind, ok := notifications.Lookup(update.Kind)
+		if !ok {
+			return nil, errs.NewValidation(fmt.Sprintf("preferences[%d]: unknown notification kind %q", i, update.Kind))
+		}
+		if kind.IsRequired(notifications.Channel(update.Channel)) && !*update.Enabled {
+			return nil, errs.NewValidation(
+				fmt.Sprintf("preferences[%d]: %s notifications cannot be disabled by %s", i, update.Kind, update.Channel),
+			)
+		}
+
+		pref := &models.NotificationPreference{Kind: update.Kind, Channel: update.Channel, Enabled: *update.Enabled}
+		key := update.Kind + "/" + update.Channel
+		if j, ok := byKey[key]; ok {
+			prefs[j] = pref
+			continue
+		}
+		byKey[key] = len(prefs)
+		prefs = append(prefs, pref)
+	}
+
+	if err := h.repo.Set(r.Context(), principal.UserID, prefs); err != nil {
+		return nil, err
+	}
+	return h.list(r, struct{}{})
+}
+
+// settings describes every declared kind for a user with the given preferences
+func settings(prefs []*models.NotificationPreference) []models.NotificationKindSettings {
+	kinds := notifications.Kinds()
+	list := make([]models.NotificationKindSettings, len(kinds))
+	for i, kind := range kinds {
+		list[i] = kind.Settings(prefs)
+	}
+	return list
+}
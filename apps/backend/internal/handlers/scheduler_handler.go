@@ -0,0 +1,36 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/PrinceNarteh/go-boilerplate/internal/auth"
+	"github.com/PrinceNarteh/go-boilerplate/internal/middlewares"
+	"github.com/PrinceNarteh/go-boilerplate/internal/routers"
+	"github.com/PrinceNarteh/go-boilerplate/internal/scheduler"
+)
+
+// SchedulerHandler serves the admin endpoint listing scheduled jobs
+type SchedulerHandler struct {
+	scheduler    *scheduler.Scheduler
+	authenticate middlewares.Middleware
+}
+
+// NewSchedulerHandler creates a new scheduler handler.
+// authenticate is the middleware used to authenticate users.
+func NewSchedulerHandler(scheduler *scheduler.Scheduler, authenticate middlewares.Middleware) *SchedulerHandler {
+	return &SchedulerHandler{
+		scheduler:    scheduler,
+		authenticate: authenticate,
+	}
+}
+
+// RegisterRoutes implements routers.Module
+func (h *SchedulerHandler) RegisterRoutes(g *routers.RouteGroup) {
+	admin := g.Group("/admin", h.authenticate, middlewares.RequireRole(auth.RoleAdmin))
+	admin.GET("/scheduler", routers.Handler(h.list))
+}
+
+// list returns the scheduled jobs with their next and last runs
+func (h *SchedulerHandler) list(_ *http.Request, _ struct{}) ([]scheduler.Status, error) {
+	return h.scheduler.Status(), nil
+}
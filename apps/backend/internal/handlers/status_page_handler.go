@@ -0,0 +1,174 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/PrinceNarteh/go-boilerplate/internal/auth"
+	"github.com/PrinceNarteh/go-boilerplate/internal/errs"
+	"github.com/PrinceNarteh/go-boilerplate/internal/middlewares"
+	"github.com/PrinceNarteh/go-boilerplate/internal/models"
+	"github.com/PrinceNarteh/go-boilerplate/internal/routers"
+	"github.com/PrinceNarteh/go-boilerplate/internal/statuspage"
+)
+
+const (
+	// statusPageCacheControl lets clients and proxies reuse the page for a
+	// short while, then revalidate it with its ETag
+	statusPageCacheControl = "public, max-age=15, must-revalidate"
+	// statusPageCSP allows the inline styles of the HTML page only
+	statusPageCSP = "default-src 'none'; style-src 'unsafe-inline'; frame-ancestors 'none'"
+	// statusPageBudget is the p99 latency of the status page beyond which
+	// the uptime of components is left out
+	statusPageBudget = 250 * time.Millisecond
+)
+
+// statusPageTemplate is the minimal HTML rendering of the status page
+var statusPageTemplate = template.Must(template.New("status").Funcs(template.FuncMap{
+	"percent": func(p *float64) string {
+		if p == nil {
+			return "-"
+		}
+		return fmt.Sprintf("%.2f%%", *p)
+	},
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Status</title>
+<style>
+body { font-family: sans-serif; max-width: 40em; margin: 2em auto; padding: 0 1em; color: #222 }
+.operational { color: #1a7f37 } .degraded { color: #9a6700 } .outage { color: #cf222e } .unknown { color: #666 }
+table { width: 100%; border-collapse: collapse }
+td, th { text-align: left; padding: .4em 0; border-bottom: 1px solid #ddd }
+.incident { border: 1px solid #cf222e; padding: 1em; margin: 1em 0 }
+</style>
+</head>
+<body>
+<h1 class="{{.Status}}">{{.Status}}</h1>
+{{with .Incident}}<div class="incident"><strong>{{.Severity}} incident</strong>
+since {{.StartedAt.Format "2006-01-02 15:04 MST"}}<p>{{.Message}}</p></div>{{end}}
+<table>
+<tr><th>Component</th><th>Status</th><th>Uptime ({{.UptimeWindow}})</th></tr>
+{{range .Components}}<tr><td>{{.Name}}</td><td class="{{.Status}}">{{.Status}}</td>
+<td>{{percent .UptimePercent}}</td></tr>
+{{end}}</table>
+<p><small>Updated {{.UpdatedAt.Format "2006-01-02 15:04:05 MST"}}</small></p>
+</body>
+</html>
+`))
+
+// StatusPageHandler serves the public status page at /status, as JSON or,
+// for browsers, as HTML. While the page is over its latency budget, as
+// when it is polled during an outage, the uptime of components is left out.
+type StatusPageHandler struct {
+	page   *statuspage.Page
+	budget middlewares.Middleware
+}
+
+// NewStatusPageHandler creates a new status page handler.
+// Register it on the root group, as the page is public.
+func NewStatusPageHandler(page *statuspage.Page, logger *zerolog.Logger) *StatusPageHandler {
+	return &StatusPageHandler{
+		page: page,
+		budget: middlewares.LatencyBudget(middlewares.LatencyBudgetOptions{
+			Name:   "status_page",
+			Budget: statusPageBudget,
+			Logger: logger,
+		}),
+	}
+}
+
+// RegisterRoutes implements routers.Module
+func (h *StatusPageHandler) RegisterRoutes(g *routers.RouteGroup) {
+	g.GET("/status", h.show, h.budget)
+}
+
+// show writes the status page, answering 304 when the client has it already
+func (h *StatusPageHandler) show(w http.ResponseWriter, r *http.Request) {
+	summary, err := h.page.Summary(r.Context(), statuspage.SummaryOptions{
+		SkipUptime: middlewares.IsDegraded(r.Context()),
+	})
+	if err != nil {
+		zerolog.Ctx(r.Context()).Error().Err(err).Msg("Failed to build status page")
+		errs.WriteJSON(w, err)
+		return
+	}
+
+	var body bytes.Buffer
+	contentType := "application/json"
+	if wantsHTML(r) {
+		contentType = "text/html; charset=utf-8"
+		err = statusPageTemplate.Execute(&body, summary)
+	} else {
+		err = json.NewEncoder(&body).Encode(summary)
+	}
+	if err != nil {
+		zerolog.Ctx(r.Context()).Error().Err(err).Msg("Failed to render status page")
+		errs.WriteJSON(w, err)
+		return
+	}
+
+	sum := sha256.Sum256(body.Bytes())
+	etag := `"` + hex.EncodeToString(sum[:8]) + `"`
+
+	w.Header().Set("Cache-Control", statusPageCacheControl)
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Vary", "Accept")
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	if contentType != "application/json" {
+		w.Header().Set("Content-Security-Policy", statusPageCSP)
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write(body.Bytes())
+}
+
+// wantsHTML reports whether the client prefers HTML, as browsers do
+func wantsHTML(r *http.Request) bool {
+	return strings.Contains(r.Header.Get("Accept"), "text/html")
+}
+
+// IncidentHandler serves the admin endpoints declaring and resolving the
+// incident shown on the status page
+type IncidentHandler struct {
+	page         *statuspage.Page
+	authenticate middlewares.Middleware
+}
+
+// NewIncidentHandler creates a new incident handler.
+// authenticate is the middleware used to authenticate users.
+func NewIncidentHandler(page *statuspage.Page, authenticate middlewares.Middleware) *IncidentHandler {
+	return &IncidentHandler{page: page, authenticate: authenticate}
+}
+
+// RegisterRoutes implements routers.Module
+func (h *IncidentHandler) RegisterRoutes(g *routers.RouteGroup) {
+	admin := g.Group("/admin/status", h.authenticate, middlewares.RequireRole(auth.RoleAdmin))
+	admin.PUT("/incident", routers.Handler(h.set))
+	admin.DELETE("/incident", routers.Handler(h.clear))
+}
+
+// set declares the incident, or updates the current one
+func (h *IncidentHandler) set(r *http.Request, req models.SetIncidentRequest) (*statuspage.Incident, error) {
+	return h.page.SetIncident(r.Context(), req.Message, statuspage.Severity(req.Severity))
+}
+
+// clear resolves the current incident
+func (h *IncidentHandler) clear(r *http.Request, _ struct{}) (routers.NoContent, error) {
+	return routers.NoContent{}, h.page.ClearIncident(r.Context())
+}
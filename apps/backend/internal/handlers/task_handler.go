@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/PrinceNarteh/go-boilerplate/internal/auth"
+	"github.com/PrinceNarteh/go-boilerplate/internal/errs"
+	"github.com/PrinceNarteh/go-boilerplate/internal/libs/id"
+	"github.com/PrinceNarteh/go-boilerplate/internal/middlewares"
+	"github.com/PrinceNarteh/go-boilerplate/internal/models"
+	"github.com/PrinceNarteh/go-boilerplate/internal/routers"
+	"github.com/PrinceNarteh/go-boilerplate/internal/tasks"
+)
+
+// recentTaskRunsLimit is the number of runs returned when listing task runs
+const recentTaskRunsLimit = 50
+
+// taskRunThrottle limits the tasks an admin can start, as tasks such as
+// backfills load the database
+var taskRunThrottle = middlewares.DeclareThrottle(middlewares.Throttle{
+	Name:   "tasks.start",
+	Limit:  10,
+	Window: time.Minute,
+})
+
+// TaskHandler serves the admin endpoints running operational tasks
+type TaskHandler struct {
+	runner       *tasks.Runner
+	authenticate middlewares.Middleware
+}
+
+// NewTaskHandler creates a new task handler.
+// authenticate is the middleware used to authenticate users.
+func NewTaskHandler(runner *tasks.Runner, authenticate middlewares.Middleware) *TaskHandler {
+	return &TaskHandler{
+		runner:       runner,
+		authenticate: authenticate,
+	}
+}
+
+// RegisterRoutes implements routers.Module
+func (h *TaskHandler) RegisterRoutes(g *routers.RouteGroup) {
+	admin := g.Group("/admin", h.authenticate, middlewares.RequireRole(auth.RoleAdmin))
+	admin.GET("/tasks", routers.Handler(h.listTasks))
+	admin.POST("/tasks/{name}/runs", routers.Handler(h.start), taskRunThrottle.Middleware())
+	admin.GET("/task-runs", routers.Handler(h.listRuns))
+	admin.GET("/task-runs/{id}", routers.Handler(h.getRun))
+}
+
+// listTasks returns the registered tasks
+func (h *TaskHandler) listTasks(_ *http.Request, _ struct{}) ([]models.TaskResponse, error) {
+	registered := h.runner.Tasks()
+
+	resp := make([]models.TaskResponse, len(registered))
+	for i, task := range registered {
+		resp[i] = models.TaskResponse{Name: task.Name, Description: task.Description}
+	}
+	return resp, nil
+}
+
+// start validates the arguments of a task and starts a run in the background
+func (h *TaskHandler) start(r *http.Request, req models.StartTaskRunRequest) (routers.Accepted[*tasks.Run], error) {
+	principal, _ := auth.FromContext(r.Context())
+
+	run, err := h.runner.Start(r.Context(), routers.Param(r, "name"), req.Args, principal.UserID)
+	if err != nil {
+		if errors.Is(err, tasks.ErrUnknownTask) {
+			return routers.Accepted[*tasks.Run]{}, errs.NewNotFound("Task")
+		}
+		return routers.Accepted[*tasks.Run]{}, err
+	}
+
+	return routers.Accepted[*tasks.Run]{Data: run}, nil
+}
+
+// listRuns returns the most recent task runs
+func (h *TaskHandler) listRuns(r *http.Request, _ struct{}) ([]*tasks.Run, error) {
+	runs, err := h.runner.List(r.Context(), recentTaskRunsLimit)
+	if err != nil {
+		return nil, err
+	}
+	if runs == nil {
+		runs = []*tasks.Run{}
+	}
+	return runs, nil
+}
+
+// getRun returns the status of a task run
+func (h *TaskHandler) getRun(r *http.Request, _ struct{}) (*tasks.Run, error) {
+	runID, err := id.Parse(routers.Param(r, "id"))
+	if err != nil {
+		return nil, errs.NewNotFound("Task run")
+	}
+
+	run, err := h.runner.Get(r.Context(), runID)
+	if err != nil {
+		if errors.Is(err, errs.ErrNotFound) {
+			return nil, errs.NewNotFound("Task run")
+		}
+		return nil, err
+	}
+	return run, nil
+}
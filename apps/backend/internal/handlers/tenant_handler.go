@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/PrinceNarteh/go-boilerplate/internal/auth"
+	"github.com/PrinceNarteh/go-boilerplate/internal/middlewares"
+	"github.com/PrinceNarteh/go-boilerplate/internal/models"
+	"github.com/PrinceNarteh/go-boilerplate/internal/routers"
+	"github.com/PrinceNarteh/go-boilerplate/internal/services"
+)
+
+// TenantHandler serves the admin endpoints provisioning tenants
+type TenantHandler struct {
+	tenants      *services.TenantService
+	authenticate middlewares.Middleware
+}
+
+// NewTenantHandler creates a new tenant handler.
+// authenticate is the middleware used to authenticate users.
+func NewTenantHandler(tenants *services.TenantService, authenticate middlewares.Middleware) *TenantHandler {
+	return &TenantHandler{
+		tenants:      tenants,
+		authenticate: authenticate,
+	}
+}
+
+// RegisterRoutes implements routers.Module
+func (h *TenantHandler) RegisterRoutes(g *routers.RouteGroup) {
+	admin := g.Group("/admin", h.authenticate, middlewares.RequireRole(auth.RoleAdmin))
+	admin.GET("/tenants", routers.Handler(h.list))
+	admin.POST("/tenants", routers.Handler(h.create))
+	admin.DELETE("/tenants/{id}", routers.Handler(h.delete))
+}
+
+// list returns every tenant
+func (h *TenantHandler) list(r *http.Request, _ struct{}) ([]*models.Tenant, error) {
+	return h.tenants.List(r.Context())
+}
+
+// create provisions a tenant with its schema
+func (h *TenantHandler) create(
+	r *http.Request,
+	req models.CreateTenantRequest,
+) (routers.Created[*models.Tenant], error) {
+	tenant, err := h.tenants.Provision(r.Context(), req.ID)
+	if err != nil {
+		return routers.Created[*models.Tenant]{}, err
+	}
+	return routers.Created[*models.Tenant]{Data: tenant}, nil
+}
+
+// delete deprovisions a tenant, dropping its schema and data
+func (h *TenantHandler) delete(r *http.Request, _ struct{}) (routers.NoContent, error) {
+	return routers.NoContent{}, h.tenants.Deprovision(r.Context(), routers.Param(r, "id"))
+}
@@ -0,0 +1,164 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/PrinceNarteh/go-boilerplate/internal/auth"
+	"github.com/PrinceNarteh/go-boilerplate/internal/caching"
+	"github.com/PrinceNarteh/go-boilerplate/internal/events"
+	"github.com/PrinceNarteh/go-boilerplate/internal/libs/pagination"
+	"github.com/PrinceNarteh/go-boilerplate/internal/middlewares"
+	"github.com/PrinceNarteh/go-boilerplate/internal/models"
+	"github.com/PrinceNarteh/go-boilerplate/internal/repositories"
+	"github.com/PrinceNarteh/go-boilerplate/internal/routers"
+	"github.com/PrinceNarteh/go-boilerplate/internal/services"
+)
+
+// UserCachePolicy declares the caching of users, keyed by user ID and
+// invalidated by the events changing a user
+var UserCachePolicy = caching.Policy{
+	Name: "users",
+	Key:  caching.Param("id"),
+	Vary: []caching.Vary{caching.Header("Accept-Language")},
+	Invalidations: []caching.Invalidation{
+		caching.On(func(e events.UserUpdated) []string { return []string{strconv.Itoa(e.User.ID)} }),
+		caching.On(func(e events.UserDeleted) []string { return []string{strconv.Itoa(e.User.ID)} }),
+		caching.On(func(e events.UserRestored) []string { return []string{strconv.Itoa(e.User.ID)} }),
+	},
+}
+
+// UserHandler serves the administrative user management endpoints
+type UserHandler struct {
+	users        *services.UserService
+	authenticate middlewares.Middleware
+	cached       []middlewares.Middleware
+}
+
+// NewUserHandler creates a new user handler.
+// authenticate is the middleware used to authenticate users.
+// cache is the middleware caching user responses, such as the one of a
+// resource declared with UserCachePolicy, and may be nil.
+func NewUserHandler(
+	users *services.UserService,
+	authenticate middlewares.Middleware,
+	cache middlewares.Middleware,
+) *UserHandler {
+	h := &UserHandler{
+		users:        users,
+		authenticate: authenticate,
+	}
+	if cache != nil {
+		h.cached = append(h.cached, cache)
+	}
+	return h
+}
+
+// RegisterRoutes implements routers.Module
+func (h *UserHandler) RegisterRoutes(g *routers.RouteGroup) {
+	users := g.Group("/users", h.authenticate, middlewares.RequireRole(auth.RoleAdmin))
+	users.GET("", routers.Handler(h.list))
+	users.POST("", routers.Handler(h.create))
+	users.GET("/{id}", routers.Handler(h.get), h.cached...)
+	users.PUT("/{id}", routers.Handler(h.update))
+	users.DELETE("/{id}", routers.Handler(h.delete))
+	users.POST("/{id}/restore", routers.Handler(h.restore))
+}
+
+// list returns a page of users, newest first.
+// Deleted users are included when the include_deleted query parameter is true.
+func (h *UserHandler) list(r *http.Request, _ struct{}) (pagination.CursorPage[*models.UserResponse], error) {
+	params, err := pagination.ParseCursor(r.URL.Query(), pagination.Options{})
+	if err != nil {
+		return pagination.CursorPage[*models.UserResponse]{}, err
+	}
+
+	ctx := r.Context()
+	if r.URL.Query().Get("include_deleted") == "true" {
+		ctx = repositories.IncludeDeleted(ctx)
+	}
+
+	users, next, err := h.users.List(ctx, params.Cursor, params.Limit)
+	if err != nil {
+		return pagination.CursorPage[*models.UserResponse]{}, err
+	}
+
+	resp := make([]*models.UserResponse, len(users))
+	for i, user := range users {
+		resp[i] = user.ToResponse()
+	}
+	return pagination.NewCursorPage(resp, next), nil
+}
+
+// create creates a user
+func (h *UserHandler) create(
+	r *http.Request,
+	req models.CreateUserRequest,
+) (routers.Created[*models.UserResponse], error) {
+	user, err := h.users.Create(r.Context(), req)
+	if err != nil {
+		return routers.Created[*models.UserResponse]{}, err
+	}
+	return routers.Created[*models.UserResponse]{Data: user.ToResponse()}, nil
+}
+
+// get returns a user by ID, or as it was at the time of the as_of query
+// parameter, an RFC 3339 time
+func (h *UserHandler) get(r *http.Request, _ struct{}) (*models.UserResponse, error) {
+	id, err := routers.ParamInt(r, "id")
+	if err != nil {
+		return nil, err
+	}
+	asOf, past, err := routers.QueryTime(r, "as_of")
+	if err != nil {
+		return nil, err
+	}
+
+	var user *models.User
+	if past {
+		user, err = h.users.GetAsOf(r.Context(), id, asOf)
+	} else {
+		user, err = h.users.Get(r.Context(), id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return user.ToResponse(), nil
+}
+
+// update updates a user
+func (h *UserHandler) update(r *http.Request, req models.UpdateUserRequest) (*models.UserResponse, error) {
+	id, err := routers.ParamInt(r, "id")
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := h.users.Update(r.Context(), id, req)
+	if err != nil {
+		return nil, err
+	}
+	return user.ToResponse(), nil
+}
+
+// delete soft-deletes a user
+func (h *UserHandler) delete(r *http.Request, _ struct{}) (routers.NoContent, error) {
+	id, err := routers.ParamInt(r, "id")
+	if err != nil {
+		return routers.NoContent{}, err
+	}
+	return routers.NoContent{}, h.users.Delete(r.Context(), id)
+}
+
+// restore restores a soft-deleted user
+func (h *UserHandler) restore(r *http.Request, _ struct{}) (*models.UserResponse, error) {
+	id, err := routers.ParamInt(r, "id")
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := h.users.Restore(r.Context(), id)
+	if err != nil {
+		return nil, err
+	}
+	return user.ToResponse(), nil
+}
@@ -0,0 +1,102 @@
+package healthcheck
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/redis/go-redis/v9"
+)
+
+// Names of the built-in checks, as listed in HealthChecksConfig.Checks
+const (
+	CheckDatabase       = "database"
+	CheckDatabasePool   = "database_pool"
+	CheckReplicationLag = "replication_lag"
+	CheckRedis          = "redis"
+	CheckNATS           = "nats"
+)
+
+// PoolStats are the statistics of a database connection pool
+type PoolStats struct {
+	MaxConns          int32   `json:"max_conns"`
+	TotalConns        int32   `json:"total_conns"`
+	AcquiredConns     int32   `json:"acquired_conns"`
+	IdleConns         int32   `json:"idle_conns"`
+	AcquireCount      int64   `json:"acquire_count"`
+	EmptyAcquireCount int64   `json:"empty_acquire_count"`
+	AcquireDurationMs float64 `json:"acquire_duration_ms"`
+	Saturation        float64 `json:"saturation"`
+}
+
+// DatabasePing checks that the database answers a ping
+func DatabasePing(pool *pgxpool.Pool) Check {
+	return func(ctx context.Context) (any, error) {
+		return nil, pool.Ping(ctx)
+	}
+}
+
+// PoolSaturation reports the statistics of a connection pool and fails when
+// the share of connections in use reaches maxSaturation, from 0 to 1
+func PoolSaturation(pool *pgxpool.Pool, maxSaturation float64) Check {
+	return func(_ context.Context) (any, error) {
+		stat := pool.Stat()
+		stats := PoolStats{
+			MaxConns:          stat.MaxConns(),
+			TotalConns:        stat.TotalConns(),
+			AcquiredConns:     stat.AcquiredConns(),
+			IdleConns:         stat.IdleConns(),
+			AcquireCount:      stat.AcquireCount(),
+			EmptyAcquireCount: stat.EmptyAcquireCount(),
+			AcquireDurationMs: float64(stat.AcquireDuration().Microseconds()) / 1000,
+		}
+		if stats.MaxConns > 0 {
+			stats.Saturation = float64(stats.AcquiredConns) / float64(stats.MaxConns)
+		}
+
+		if stats.Saturation >= maxSaturation {
+			return stats, fmt.Errorf("pool saturated: %d of %d connections in use", stats.AcquiredConns, stats.MaxConns)
+		}
+		return stats, nil
+	}
+}
+
+// ReplicationLag reports the replay lag of each read replica, keyed by
+// address, and fails when any replica lags more than maxLag behind the primary
+func ReplicationLag(replicas map[string]*pgxpool.Pool, maxLag time.Duration) Check {
+	return func(ctx context.Context) (any, error) {
+		// A replica that replayed everything it received is not lagging, even
+		// if the last replayed transaction is old because the primary is idle
+		query := `
+			SELECT CASE
+				WHEN pg_last_wal_receive_lsn() = pg_last_wal_replay_lsn() THEN 0
+				ELSE COALESCE(EXTRACT(EPOCH FROM NOW() - pg_last_xact_replay_timestamp()), 0)
+			END::float8`
+
+		lags := make(map[string]float64, len(replicas))
+		var errList []error
+		for addr, pool := range replicas {
+			var lag float64
+			if err := pool.QueryRow(ctx, query).Scan(&lag); err != nil {
+				errList = append(errList, fmt.Errorf("replica %s: %w", addr, err))
+				continue
+			}
+
+			lags[addr] = lag
+			if lag > maxLag.Seconds() {
+				errList = append(errList, fmt.Errorf("replica %s lags %.1fs behind", addr, lag))
+			}
+		}
+
+		return lags, errors.Join(errList...)
+	}
+}
+
+// RedisPing checks that Redis answers a ping
+func RedisPing(client *redis.Client) Check {
+	return func(ctx context.Context) (any, error) {
+		return nil, client.Ping(ctx).Err()
+	}
+}
@@ -0,0 +1,352 @@
+// Package healthcheck polls the application's dependencies and reports
+// whether the application is ready to serve traffic.
+//
+// The service backs the startup and readiness probes: startup succeeds once
+// initialization is complete, and readiness once it is and every check
+// passes, until the application starts draining on shutdown. Liveness does not depend on it, so that an orchestrator never
+// restarts a process only because a dependency is slow or down.
+//
+// Checks are registered by name and run in the background every
+// HealthChecksConfig.Interval, each within HealthChecksConfig.Timeout. The
+// readiness endpoint serves the last results, so probes never wait on a slow
+// dependency and cannot overload it.
+//
+// Every result is also recorded in a History of HealthChecksConfig.HistorySize
+// samples per check, from which the uptime of the status page is computed.
+// The history is kept in memory unless SetHistory replaces it, e.g. with a
+// RedisHistory that survives restarts. A check whose status keeps changing
+// within its recent samples is reported as flapping, and its failures and
+// recoveries are no longer logged until it settles.
+package healthcheck
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/PrinceNarteh/go-boilerplate/internal/config"
+	"github.com/PrinceNarteh/go-boilerplate/internal/libs/async"
+)
+
+// Status is the status of a check or of the whole application
+type Status string
+
+// Check statuses
+const (
+	StatusHealthy   Status = "healthy"
+	StatusUnhealthy Status = "unhealthy"
+	// StatusUnknown is reported for checks that have not run yet
+	StatusUnknown Status = "unknown"
+	// StatusStarting is reported until initialization is complete
+	StatusStarting Status = "starting"
+	// StatusDraining is reported once shutdown has begun
+	StatusDraining Status = "draining"
+)
+
+// Check checks a dependency. It returns an error when the dependency is
+// unhealthy, and optional details, such as pool statistics, in both cases.
+type Check func(ctx context.Context) (details any, err error)
+
+// Result is the outcome of the last run of a check
+type Result struct {
+	Status    Status    `json:"status"`
+	LatencyMs float64   `json:"latency_ms"`
+	Error     string    `json:"error,omitempty"`
+	Details   any       `json:"details,omitempty"`
+	Flapping  bool      `json:"flapping,omitempty"`
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+// CheckHistory is the availability of a check since a given time
+type CheckHistory struct {
+	Name     string    `json:"name"`
+	Status   Status    `json:"status"`
+	Flapping bool      `json:"flapping"`
+	Since    time.Time `json:"since"`
+	// UptimePercent is nil when no sample was recorded since Since
+	UptimePercent *float64 `json:"uptime_percent"`
+	Changes       int      `json:"changes"`
+	SampleCount   int      `json:"sample_count"`
+	Samples       []Sample `json:"samples,omitempty"`
+}
+
+// Report is the readiness of the application
+type Report struct {
+	Status Status            `json:"status"`
+	Checks map[string]Result `json:"checks"`
+}
+
+// namedCheck is a registered check
+type namedCheck struct {
+	name  string
+	check Check
+}
+
+// Service runs the registered checks, keeps their last results and
+// records every result in its history
+type Service struct {
+	cfg     config.HealthChecksConfig
+	logger  *zerolog.Logger
+	history History
+
+	started  atomic.Bool
+	draining atomic.Bool
+
+	mu      sync.RWMutex
+	checks  []namedCheck
+	results map[string]Result
+	stop    func()
+}
+
+// New creates a new health check service, keeping the history of the
+// checks in memory
+func New(cfg config.HealthChecksConfig, logger *zerolog.Logger) *Service {
+	return &Service{
+		cfg:     cfg,
+		logger:  logger,
+		history: NewMemoryHistory(cfg.HistorySize),
+		results: make(map[string]Result),
+	}
+}
+
+// SetHistory replaces the history of the checks. It must be called before Start.
+func (s *Service) SetHistory(history History) {
+	s.history = history
+}
+
+// Register adds a check. Checks whose name is not listed in
+// HealthChecksConfig.Checks are ignored, so deployments choose which
+// dependencies gate readiness; all checks are kept when the list is empty.
+func (s *Service) Register(name string, check Check) {
+	if len(s.cfg.Checks) > 0 && !slices.Contains(s.cfg.Checks, name) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.checks = append(s.checks, namedCheck{name: name, check: check})
+	s.results[name] = Result{Status: StatusUnknown}
+}
+
+// Start runs the checks immediately and then at every interval until Stop is called
+func (s *Service) Start() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stop != nil || !s.cfg.Enabled {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := async.Go(ctx, "healthcheck", func(ctx context.Context) error {
+		ticker := time.NewTicker(s.cfg.Interval)
+		defer ticker.Stop()
+
+		for {
+			s.Run(ctx)
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-ticker.C:
+			}
+		}
+	})
+
+	s.stop = func() {
+		cancel()
+		<-done
+	}
+}
+
+// Stop stops polling the checks
+func (s *Service) Stop() {
+	s.mu.Lock()
+	stop := s.stop
+	s.stop = nil
+	s.mu.Unlock()
+
+	if stop != nil {
+		stop()
+	}
+}
+
+// Run runs every check concurrently and records the results
+func (s *Service) Run(ctx context.Context) {
+	s.mu.RLock()
+	checks := slices.Clone(s.checks)
+	s.mu.RUnlock()
+
+	var wg sync.WaitGroup
+	for _, c := range checks {
+		wg.Add(1)
+		// A panicking check is recovered and reported, keeping its last result
+		async.Go(ctx, "healthcheck."+c.name, func(ctx context.Context) error {
+			defer wg.Done()
+			result := s.run(ctx, c)
+			if ctx.Err() != nil {
+				return nil
+			}
+
+			sample := Sample{Status: result.Status, LatencyMs: result.LatencyMs, CheckedAt: result.CheckedAt}
+			if err := s.history.Record(ctx, c.name, sample); err != nil {
+				s.logger.Warn().Err(err).Str("check", c.name).Msg("Failed to record health check history")
+			}
+			flapping, known := s.flapping(ctx, c.name)
+
+			s.mu.Lock()
+			previous := s.results[c.name]
+			result.Flapping = flapping
+			if !known {
+				result.Flapping = previous.Flapping
+			}
+			s.results[c.name] = result
+			s.mu.Unlock()
+
+			switch {
+			case result.Flapping && !previous.Flapping:
+				s.logger.Warn().Str("check", c.name).Msg("Health check flapping")
+			case previous.Flapping && !result.Flapping:
+				s.logger.Info().Str("check", c.name).Str("status", string(result.Status)).Msg("Health check stopped flapping")
+			case result.Flapping:
+			case previous.Status != result.Status && result.Status == StatusUnhealthy:
+				s.logger.Warn().Str("check", c.name).Str("error", result.Error).Msg("Health check failing")
+			case previous.Status == StatusUnhealthy && result.Status == StatusHealthy:
+				s.logger.Info().Str("check", c.name).Msg("Health check recovered")
+			}
+			return nil
+		})
+	}
+	wg.Wait()
+}
+
+// MarkStarted records that initialization is complete, e.g. once migrations
+// have run and every module is registered
+func (s *Service) MarkStarted() {
+	if s.started.CompareAndSwap(false, true) {
+		s.logger.Info().Msg("Application started")
+	}
+}
+
+// Started reports whether MarkStarted has been called
+func (s *Service) Started() bool {
+	return s.started.Load()
+}
+
+// MarkDraining records that shutdown has begun, failing readiness so that
+// load balancers stop sending requests before the servers stop accepting them
+func (s *Service) MarkDraining() {
+	if s.draining.CompareAndSwap(false, true) {
+		s.logger.Info().Msg("Application draining")
+	}
+}
+
+// Draining reports whether MarkDraining has been called
+func (s *Service) Draining() bool {
+	return s.draining.Load()
+}
+
+// Report returns the last result of every check. The application is
+// healthy when it has started, is not draining and every check is healthy;
+// checks that have not run yet count as unhealthy.
+func (s *Service) Report() Report {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	report := Report{Status: StatusHealthy, Checks: make(map[string]Result, len(s.results))}
+	for name, result := range s.results {
+		report.Checks[name] = result
+		if result.Status != StatusHealthy {
+			report.Status = StatusUnhealthy
+		}
+	}
+	if !s.Started() {
+		report.Status = StatusStarting
+	}
+	if s.Draining() {
+		report.Status = StatusDraining
+	}
+	return report
+}
+
+// Checks returns the names of the registered checks
+func (s *Service) Checks() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	names := make([]string, len(s.checks))
+	for i, c := range s.checks {
+		names[i] = c.name
+	}
+	return names
+}
+
+// History returns the samples of the named check recorded since the given time, oldest first
+func (s *Service) History(ctx context.Context, name string, since time.Time) ([]Sample, error) {
+	return s.history.Samples(ctx, name, since)
+}
+
+// CheckHistory returns the availability of the named check since the given
+// time, with its samples
+func (s *Service) CheckHistory(ctx context.Context, name string, since time.Time) (CheckHistory, error) {
+	s.mu.RLock()
+	result := s.results[name]
+	s.mu.RUnlock()
+
+	samples, err := s.history.Samples(ctx, name, since)
+	if err != nil {
+		return CheckHistory{}, err
+	}
+
+	history := CheckHistory{
+		Name:        name,
+		Status:      result.Status,
+		Flapping:    result.Flapping,
+		Since:       since,
+		Changes:     Changes(samples),
+		SampleCount: len(samples),
+		Samples:     samples,
+	}
+	if uptime := Uptime(samples); uptime >= 0 {
+		history.UptimePercent = &uptime
+	}
+	return history, nil
+}
+
+// flapping reports whether the status of the named check changed at least
+// HealthChecksConfig.FlapThreshold times within its last FlapWindow samples.
+// known is false when the history could not be loaded.
+func (s *Service) flapping(ctx context.Context, name string) (flapping, known bool) {
+	if s.cfg.FlapWindow <= 0 || s.cfg.FlapThreshold <= 0 {
+		return false, true
+	}
+
+	samples, err := s.history.Last(ctx, name, s.cfg.FlapWindow)
+	if err != nil {
+		s.logger.Warn().Err(err).Str("check", name).Msg("Failed to load health check history")
+		return false, false
+	}
+	return Changes(samples) >= s.cfg.FlapThreshold, true
+}
+
+// run runs a single check within the configured timeout
+func (s *Service) run(ctx context.Context, c namedCheck) Result {
+	ctx, cancel := context.WithTimeout(ctx, s.cfg.Timeout)
+	defer cancel()
+
+	start := time.Now()
+	details, err := c.check(ctx)
+	result := Result{
+		Status:    StatusHealthy,
+		LatencyMs: float64(time.Since(start).Microseconds()) / 1000,
+		Details:   details,
+		CheckedAt: start,
+	}
+	if err != nil {
+		result.Status = StatusUnhealthy
+		result.Error = err.Error()
+	}
+	return result
+}
@@ -0,0 +1,208 @@
+package healthcheck
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// defaultHistorySize is the number of samples kept per check when
+	// HealthChecksConfig.HistorySize is not set
+	defaultHistorySize = 2880
+	// defaultHistoryPrefix prefixes the Redis keys of the history of each check
+	defaultHistoryPrefix = "healthcheck:history:"
+)
+
+// Sample is a recorded run of a check
+type Sample struct {
+	Status    Status    `json:"status"`
+	LatencyMs float64   `json:"latency_ms"`
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+// History stores the past results of the checks, so their availability
+// can be reported over time rather than only their last state
+type History interface {
+	// Record appends a sample of the named check
+	Record(ctx context.Context, name string, sample Sample) error
+	// Samples returns the samples of the named check recorded since the
+	// given time, oldest first
+	Samples(ctx context.Context, name string, since time.Time) ([]Sample, error)
+	// Last returns the last n samples of the named check, oldest first
+	Last(ctx context.Context, name string, n int) ([]Sample, error)
+}
+
+// MemoryHistory keeps the last samples of each check in process memory.
+// Each instance of the application has its own history, lost on restart.
+type MemoryHistory struct {
+	size int
+
+	mu      sync.RWMutex
+	samples map[string][]Sample
+}
+
+// NewMemoryHistory creates a history keeping at most size samples per check
+func NewMemoryHistory(size int) *MemoryHistory {
+	if size <= 0 {
+		size = defaultHistorySize
+	}
+	return &MemoryHistory{size: size, samples: make(map[string][]Sample)}
+}
+
+// Record implements History
+func (h *MemoryHistory) Record(_ context.Context, name string, sample Sample) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	// Dropping the oldest samples by reslicing keeps memory bounded, since
+	// append only copies the retained samples when it grows the array
+	samples := append(h.samples[name], sample)
+	if len(samples) > h.size {
+		samples = samples[len(samples)-h.size:]
+	}
+	h.samples[name] = samples
+	return nil
+}
+
+// Samples implements History
+func (h *MemoryHistory) Samples(_ context.Context, name string, since time.Time) ([]Sample, error) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	samples := h.samples[name]
+	for i, sample := range samples {
+		if !sample.CheckedAt.Before(since) {
+			return append([]Sample(nil), samples[i:]...), nil
+		}
+	}
+	return nil, nil
+}
+
+// Last implements History
+func (h *MemoryHistory) Last(_ context.Context, name string, n int) ([]Sample, error) {
+	if n <= 0 {
+		return nil, nil
+	}
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	samples := h.samples[name]
+	if len(samples) > n {
+		samples = samples[len(samples)-n:]
+	}
+	return append([]Sample(nil), samples...), nil
+}
+
+// RedisHistory keeps the last samples of each check in a Redis list, so the
+// history survives restarts. Instances sharing the Redis server share the
+// history too, and each check then has the samples of every instance.
+type RedisHistory struct {
+	client redis.Cmdable
+	prefix string
+	size   int
+}
+
+// NewRedisHistory creates a history keeping at most size samples per check
+// in Redis. prefix defaults to "healthcheck:history:" when empty.
+func NewRedisHistory(client redis.Cmdable, prefix string, size int) *RedisHistory {
+	if prefix == "" {
+		prefix = defaultHistoryPrefix
+	}
+	if size <= 0 {
+		size = defaultHistorySize
+	}
+	return &RedisHistory{client: client, prefix: prefix, size: size}
+}
+
+// Record implements History
+func (h *RedisHistory) Record(ctx context.Context, name string, sample Sample) error {
+	data, err := json.Marshal(sample)
+	if err != nil {
+		return fmt.Errorf("failed to encode health check sample: %w", err)
+	}
+
+	// The list is trimmed in the same transaction, so it never grows past size
+	key := h.prefix + name
+	_, err = h.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.RPush(ctx, key, data)
+		pipe.LTrim(ctx, key, int64(-h.size), -1)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to record health check sample: %w", err)
+	}
+	return nil
+}
+
+// Samples implements History
+func (h *RedisHistory) Samples(ctx context.Context, name string, since time.Time) ([]Sample, error) {
+	samples, err := h.load(ctx, name, 0)
+	if err != nil {
+		return nil, err
+	}
+	for i, sample := range samples {
+		if !sample.CheckedAt.Before(since) {
+			return samples[i:], nil
+		}
+	}
+	return nil, nil
+}
+
+// Last implements History
+func (h *RedisHistory) Last(ctx context.Context, name string, n int) ([]Sample, error) {
+	if n <= 0 {
+		return nil, nil
+	}
+	return h.load(ctx, name, -int64(n))
+}
+
+// load decodes the samples of the named check from start to the end of its list
+func (h *RedisHistory) load(ctx context.Context, name string, start int64) ([]Sample, error) {
+	values, err := h.client.LRange(ctx, h.prefix+name, start, -1).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load health check history: %w", err)
+	}
+
+	samples := make([]Sample, 0, len(values))
+	for _, value := range values {
+		var sample Sample
+		if err := json.Unmarshal([]byte(value), &sample); err != nil {
+			return nil, fmt.Errorf("failed to decode health check sample: %w", err)
+		}
+		samples = append(samples, sample)
+	}
+	return samples, nil
+}
+
+// Uptime returns the percentage of healthy samples, or -1 when there are none
+func Uptime(samples []Sample) float64 {
+	if len(samples) == 0 {
+		return -1
+	}
+
+	healthy := 0
+	for _, sample := range samples {
+		if sample.Status == StatusHealthy {
+			healthy++
+		}
+	}
+	return float64(healthy) * 100 / float64(len(samples))
+}
+
+// Changes returns the number of times the status changed between
+// consecutive samples
+func Changes(samples []Sample) int {
+	changes := 0
+	for i := 1; i < len(samples); i++ {
+		if samples[i].Status != samples[i-1].Status {
+			changes++
+		}
+	}
+	return changes
+}
@@ -0,0 +1,79 @@
+package i18n
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"sync"
+)
+
+// enums holds the message keys of the registered enum values, by enum name
+var enums = struct {
+	mu   sync.Mutex
+	keys map[string][]string
+}{keys: make(map[string][]string)}
+
+// Enum translates the values of an enum-like field into display labels
+type Enum[T ~string] struct {
+	name string
+}
+
+// RegisterEnum registers the values of an enum named name, such as
+// "scan_status", and returns it for building labels. Catalog.Check reports
+// the values without a label. It is meant to be called from package-level
+// variable declarations.
+func RegisterEnum[T ~string](name string, values ...T) *Enum[T] {
+	e := &Enum[T]{name: name}
+
+	keys := make([]string, len(values))
+	for i, value := range values {
+		keys[i] = e.Key(value)
+	}
+
+	enums.mu.Lock()
+	defer enums.mu.Unlock()
+	enums.keys[name] = keys
+	return e
+}
+
+// Key returns the message key of the label of value
+func (e *Enum[T]) Key(value T) string {
+	return "enum." + e.name + "." + string(value)
+}
+
+// Label returns the label of value for the localizer of ctx, or an empty
+// string when the client did not ask for labels, so label fields tagged
+// omitempty are left out of responses
+func (e *Enum[T]) Label(ctx context.Context, value T) string {
+	l := FromContext(ctx)
+	if l == nil || !l.labels || value == "" {
+		return ""
+	}
+	return l.T(e.Key(value))
+}
+
+// Labels returns the labels of values like Label, in order
+func (e *Enum[T]) Labels(ctx context.Context, values []T) []string {
+	l := FromContext(ctx)
+	if l == nil || !l.labels {
+		return nil
+	}
+
+	labels := make([]string, len(values))
+	for i, value := range values {
+		labels[i] = l.T(e.Key(value))
+	}
+	return labels
+}
+
+// enumKeys returns the message keys of every registered enum value, sorted
+func enumKeys() []string {
+	enums.mu.Lock()
+	defer enums.mu.Unlock()
+
+	var keys []string
+	for _, name := range slices.Sorted(maps.Keys(enums.keys)) {
+		keys = append(keys, enums.keys[name]...)
+	}
+	return keys
+}
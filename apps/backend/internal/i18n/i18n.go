@@ -0,0 +1,215 @@
+// Package i18n provides the message catalog used to localize responses.
+//
+// The catalog holds flat message keys per locale, loaded from the JSON
+// files of the locales directory, one per locale such as "fr.json". Lookups
+// fall back to the default locale, then to the key itself, so a missing
+// translation never breaks a response.
+//
+// Enum-like model fields, such as statuses and roles, register their values
+// with RegisterEnum. Their labels are the messages "enum.<name>.<value>",
+// and responses implementing Localizable fill label fields alongside the raw
+// values when the client asks for labels, see middlewares.Localization.
+package i18n
+
+import (
+	"context"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"path"
+	"reflect"
+	"slices"
+	"strings"
+
+	"golang.org/x/text/language"
+)
+
+// DefaultLocale is the locale of the embedded catalog used when the client
+// accepts no available locale
+const DefaultLocale = "en"
+
+//go:embed locales/*.json
+var embedded embed.FS
+
+// Catalog holds the messages of every available locale
+type Catalog struct {
+	defaultLocale string
+	locales       []string
+	messages      map[string]map[string]string
+	matcher       language.Matcher
+}
+
+// Load returns the catalog of the embedded locales
+func Load() (*Catalog, error) {
+	fsys, err := fs.Sub(embedded, "locales")
+	if err != nil {
+		return nil, err
+	}
+	return NewCatalog(fsys, DefaultLocale)
+}
+
+// NewCatalog loads a catalog from the "<locale>.json" files at the root of
+// fsys. Each file is a JSON object of message keys to messages. The file
+// of defaultLocale is required.
+func NewCatalog(fsys fs.FS, defaultLocale string) (*Catalog, error) {
+	files, err := fs.Glob(fsys, "*.json")
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Catalog{
+		defaultLocale: defaultLocale,
+		messages:      make(map[string]map[string]string, len(files)),
+	}
+	for _, file := range files {
+		locale := strings.TrimSuffix(path.Base(file), ".json")
+		if _, err := language.Parse(locale); err != nil {
+			return nil, fmt.Errorf("i18n: %s is not named after a locale: %w", file, err)
+		}
+
+		data, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return nil, err
+		}
+		var messages map[string]string
+		if err := json.Unmarshal(data, &messages); err != nil {
+			return nil, fmt.Errorf("i18n: invalid %s: %w", file, err)
+		}
+		c.messages[locale] = messages
+	}
+	if _, ok := c.messages[defaultLocale]; !ok {
+		return nil, fmt.Errorf("i18n: missing %s.json for the default locale", defaultLocale)
+	}
+
+	// The matcher falls back to its first tag, the default locale
+	others := slices.DeleteFunc(slices.Sorted(maps.Keys(c.messages)), func(locale string) bool {
+		return locale == defaultLocale
+	})
+	c.locales = append([]string{defaultLocale}, others...)
+	tags := make([]language.Tag, len(c.locales))
+	for i, locale := range c.locales {
+		tags[i] = language.Make(locale)
+	}
+	c.matcher = language.NewMatcher(tags)
+
+	return c, nil
+}
+
+// Locales returns the available locales, the default one first
+func (c *Catalog) Locales() []string {
+	return slices.Clone(c.locales)
+}
+
+// Match returns the available locale best matching an Accept-Language
+// header value or a single locale, such as "fr-CA", or the default locale
+func (c *Catalog) Match(accept string) string {
+	_, index := language.MatchStrings(c.matcher, accept)
+	return c.locales[index]
+}
+
+// Translate returns the message of key in locale, falling back to the
+// default locale and then to key
+func (c *Catalog) Translate(locale, key string) string {
+	if message, ok := c.messages[locale][key]; ok {
+		return message
+	}
+	if message, ok := c.messages[c.defaultLocale][key]; ok {
+		return message
+	}
+	return key
+}
+
+// Check reports the registered enum values without a label in the default
+// locale, so missing translations are caught at startup
+func (c *Catalog) Check() error {
+	var errList []error
+	for _, key := range enumKeys() {
+		if _, ok := c.messages[c.defaultLocale][key]; !ok {
+			errList = append(errList, fmt.Errorf("i18n: missing %q in %s.json", key, c.defaultLocale))
+		}
+	}
+	return errors.Join(errList...)
+}
+
+// localizerKey is the context key of the Localizer
+type localizerKey struct{}
+
+// Localizer translates messages for the locale of a request
+type Localizer struct {
+	catalog *Catalog
+	locale  string
+	labels  bool
+}
+
+// NewLocalizer creates a localizer for locale. labels reports whether the
+// client asked for enum labels in responses.
+func NewLocalizer(catalog *Catalog, locale string, labels bool) *Localizer {
+	return &Localizer{catalog: catalog, locale: locale, labels: labels}
+}
+
+// WithLocalizer returns a copy of ctx carrying l
+func WithLocalizer(ctx context.Context, l *Localizer) context.Context {
+	return context.WithValue(ctx, localizerKey{}, l)
+}
+
+// FromContext returns the localizer of ctx, or nil
+func FromContext(ctx context.Context) *Localizer {
+	l, _ := ctx.Value(localizerKey{}).(*Localizer)
+	return l
+}
+
+// Locale returns the locale of the localizer
+func (l *Localizer) Locale() string {
+	return l.locale
+}
+
+// Labels reports whether the client asked for enum labels
+func (l *Localizer) Labels() bool {
+	return l.labels
+}
+
+// T returns the message of key in the locale of the localizer
+func (l *Localizer) T(key string) string {
+	return l.catalog.Translate(l.locale, key)
+}
+
+// Localizable is implemented by responses with localized fields, such as
+// the labels of their enum fields
+type Localizable interface {
+	// Localize fills the localized fields for the localizer of ctx
+	Localize(ctx context.Context)
+}
+
+// Localize calls Localize on v, or on each element of v when it is a slice,
+// when the client asked for labels. It is called on every response by
+// routers.Handler.
+func Localize(ctx context.Context, v any) {
+	if l := FromContext(ctx); l == nil || !l.labels {
+		return
+	}
+
+	if localizable, ok := v.(Localizable); ok {
+		localizable.Localize(ctx)
+		return
+	}
+
+	value := reflect.ValueOf(v)
+	if value.Kind() != reflect.Slice {
+		return
+	}
+	for i := range value.Len() {
+		elem := value.Index(i)
+		if elem.Kind() == reflect.Pointer && elem.IsNil() {
+			continue
+		}
+		if elem.Kind() != reflect.Pointer && elem.CanAddr() {
+			elem = elem.Addr()
+		}
+		if localizable, ok := elem.Interface().(Localizable); ok {
+			localizable.Localize(ctx)
+		}
+	}
+}
@@ -0,0 +1,284 @@
+// Package jobs runs background jobs on a queue shared by every instance of
+// the application.
+//
+// A Queue enqueues jobs, each a type and a JSON payload, into a Store kept
+// in Redis or PostgreSQL. A Worker claims due jobs with a pool of
+// goroutines and runs the handler registered for their type. A failed job
+// is retried with exponential backoff until it has run MaxAttempts times,
+// then moved to the dead-letter queue for inspection. Claims are leased and
+// renewed while a job runs, so the jobs of a crashed instance run again
+// once their lease expires: handlers must be idempotent.
+//
+// The queue applies backpressure when the store holds MaxPending waiting
+// jobs: further jobs wait for room, are dropped or spill over to a
+// secondary store, as set by the overflow policy, so bursts degrade
+// predictably rather than filling the memory of Redis.
+//
+// Payloads are versioned, see package versioned: a job records the version
+// of its payload, and handlers created with Upgrading upgrade the payloads
+// enqueued by older binaries during a rolling deployment.
+//
+// Each run is recorded as a New Relic background transaction named after
+// the job type.
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/PrinceNarteh/go-boilerplate/internal/config"
+	"github.com/PrinceNarteh/go-boilerplate/internal/libs/id"
+	"github.com/PrinceNarteh/go-boilerplate/internal/libs/versioned"
+	"github.com/PrinceNarteh/go-boilerplate/internal/telemetry"
+)
+
+// Overflow policies, applied to jobs enqueued while the queue is full
+const (
+	OverflowBlock = "block" // Wait for room, up to the block timeout
+	OverflowDrop  = "drop"  // Reject the job with ErrQueueFull
+	OverflowSpill = "spill" // Add the job to the spill store
+)
+
+const (
+	defaultMaxAttempts  = 5                     // Default number of runs of a job
+	defaultBlockTimeout = 5 * time.Second       // Default longest wait for room in a full queue
+	blockPollInterval   = 50 * time.Millisecond // Delay between checks for room in a full queue
+)
+
+//go:generate go run github.com/PrinceNarteh/go-boilerplate/cmd/enumgen -type=State
+
+// State is the state of a job kept in a store
+type State string
+
+// Job states
+const (
+	StatePending State = "pending" // Waiting to run at its RunAt
+	StateRunning State = "running" // Claimed by a worker until its lease ends
+	StateDead    State = "dead"    // Out of attempts, kept for inspection
+)
+
+// ErrNoHandler fails jobs whose type has no handler. They are retried like
+// other failures, so that during a rolling deployment, jobs enqueued by new
+// instances run once a worker knowing them claims them.
+var ErrNoHandler = errors.New("jobs: no handler for job type")
+
+// ErrQueueFull is returned when enqueuing a job the overflow policy rejects
+var ErrQueueFull = errors.New("jobs: queue is full")
+
+// Job is a unit of background work
+type Job struct {
+	ID      id.ID           `json:"id"`
+	Type    string          `json:"type"`
+	Payload json.RawMessage `json:"payload"`
+	// Version is the version of the payload schema, 0 for the jobs
+	// enqueued before payloads were versioned
+	Version int `json:"version,omitempty"`
+	// Attempt is the number of runs so far, including the current one
+	Attempt     int       `json:"attempt"`
+	MaxAttempts int       `json:"max_attempts"`
+	LastError   string    `json:"last_error,omitempty"`
+	RunAt       time.Time `json:"run_at"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// Store keeps the jobs of the queue
+type Store interface {
+	// Push adds a job, due at its RunAt
+	Push(ctx context.Context, job *Job) error
+	// Pop claims the next due job for lease, including jobs whose claim
+	// expired. It returns nil when no job is due.
+	Pop(ctx context.Context, lease time.Duration) (*Job, error)
+	// Extend renews the claim of a running job for lease
+	Extend(ctx context.Context, job *Job, lease time.Duration) error
+	// Complete removes a job that succeeded
+	Complete(ctx context.Context, job *Job) error
+	// Retry releases a failed job, saving its attempt and error, to run again at its RunAt
+	Retry(ctx context.Context, job *Job) error
+	// Bury moves a job that used all its attempts to the dead-letter queue
+	Bury(ctx context.Context, job *Job) error
+	// Pending returns the number of jobs waiting to run, due or not
+	Pending(ctx context.Context) (int64, error)
+}
+
+// Handler runs jobs of a type. A returned error fails the run, which is
+// retried until the job runs out of attempts.
+type Handler func(ctx context.Context, job *Job) error
+
+// Typed returns a handler decoding the payload of jobs into P before calling fn.
+// Payloads that do not decode fail the job.
+func Typed[P any](fn func(ctx context.Context, payload P) error) Handler {
+	return Upgrading(nil, fn)
+}
+
+// Upgrading returns a handler like Typed that first upgrades payloads of
+// older versions to the version of P with upgrades. Payloads of a newer
+// version fail with versioned.ErrNewerVersion and are retried like other
+// failures, so they run once a worker knowing their version claims them.
+func Upgrading[P any](upgrades versioned.Upgrades, fn func(ctx context.Context, payload P) error) Handler {
+	return func(ctx context.Context, job *Job) error {
+		payload, err := versioned.Decode[P](job.Payload, job.Version, upgrades)
+		if err != nil {
+			return fmt.Errorf("failed to decode %s payload: %w", job.Type, err)
+		}
+		return fn(ctx, payload)
+	}
+}
+
+// QueueOptions configures the optional dependencies of a Queue
+type QueueOptions struct {
+	// Spill receives the jobs enqueued while the queue is full with the
+	// spill policy, such as a PostgresStore run by a Worker of its own.
+	// Without it, those jobs are dropped.
+	Spill Store
+	// Metrics counts the jobs enqueued while the queue is full when set
+	Metrics *telemetry.JobMetrics
+}
+
+// Queue enqueues jobs
+type Queue struct {
+	store        Store
+	spill        Store
+	metrics      *telemetry.JobMetrics
+	maxAttempts  int
+	maxPending   int64
+	overflow     string
+	blockTimeout time.Duration
+}
+
+// NewQueue creates a queue adding jobs to store. Unset settings of cfg use
+// the defaults, and the overflow policy is block.
+func NewQueue(store Store, cfg config.JobsConfig, opts QueueOptions) *Queue {
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = defaultMaxAttempts
+	}
+	if cfg.Overflow == "" {
+		cfg.Overflow = OverflowBlock
+	}
+	if cfg.BlockTimeout <= 0 {
+		cfg.BlockTimeout = defaultBlockTimeout
+	}
+
+	return &Queue{
+		store:        store,
+		spill:        opts.Spill,
+		metrics:      opts.Metrics,
+		maxAttempts:  cfg.MaxAttempts,
+		maxPending:   cfg.MaxPending,
+		overflow:     cfg.Overflow,
+		blockTimeout: cfg.BlockTimeout,
+	}
+}
+
+// Enqueue adds a job of jobType running as soon as a worker is free.
+// payload is encoded as JSON.
+func (q *Queue) Enqueue(ctx context.Context, jobType string, payload any) error {
+	_, err := q.EnqueueAt(ctx, jobType, payload, time.Now())
+	return err
+}
+
+// EnqueueAt adds a job of jobType running at runAt and returns it, with the
+// version of payload, see versioned.Versioned. When the queue is full, the overflow policy applies: the call may wait for room,
+// or fail with ErrQueueFull.
+func (q *Queue) EnqueueAt(ctx context.Context, jobType string, payload any, runAt time.Time) (*Job, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode %s payload: %w", jobType, err)
+	}
+
+	now := time.Now()
+	job := &Job{
+		ID:          id.NewAt(now),
+		Type:        jobType,
+		Payload:     data,
+		Version:     versioned.Of(payload),
+		MaxAttempts: q.maxAttempts,
+		RunAt:       runAt,
+		CreatedAt:   now,
+	}
+	if err := q.push(ctx, job); err != nil {
+		return nil, fmt.Errorf("failed to enqueue %s job: %w", jobType, err)
+	}
+	return job, nil
+}
+
+// push adds a job to the store, applying the overflow policy while the
+// queue is full. Concurrent pushes may exceed the limit by a few jobs.
+func (q *Queue) push(ctx context.Context, job *Job) error {
+	if q.maxPending <= 0 {
+		return q.store.Push(ctx, job)
+	}
+
+	full, err := q.full(ctx)
+	if err != nil {
+		return err
+	}
+	if !full {
+		return q.store.Push(ctx, job)
+	}
+
+	switch {
+	case q.overflow == OverflowSpill && q.spill != nil:
+		if err := q.spill.Push(ctx, job); err != nil {
+			return fmt.Errorf("failed to spill job: %w", err)
+		}
+		q.recordOverflow(ctx, job, "spilled")
+		return nil
+	case q.overflow == OverflowBlock:
+		if err := q.wait(ctx); err != nil {
+			q.recordOverflow(ctx, job, "timed_out")
+			return err
+		}
+		q.recordOverflow(ctx, job, "blocked")
+		return q.store.Push(ctx, job)
+	default:
+		q.recordOverflow(ctx, job, "dropped")
+		return ErrQueueFull
+	}
+}
+
+// wait checks for room in the queue until there is some or the block
+// timeout elapses
+func (q *Queue) wait(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, q.blockTimeout)
+	defer cancel()
+
+	ticker := time.NewTicker(blockPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				return fmt.Errorf("%w after waiting %s", ErrQueueFull, q.blockTimeout)
+			}
+			return ctx.Err()
+		case <-ticker.C:
+		}
+
+		full, err := q.full(ctx)
+		if err != nil {
+			return err
+		}
+		if !full {
+			return nil
+		}
+	}
+}
+
+// full reports whether the store holds the maximum number of pending jobs
+func (q *Queue) full(ctx context.Context) (bool, error) {
+	pending, err := q.store.Pending(ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed to check queue capacity: %w", err)
+	}
+	return pending >= q.maxPending, nil
+}
+
+// recordOverflow counts a job enqueued while the queue was full
+func (q *Queue) recordOverflow(ctx context.Context, job *Job, outcome string) {
+	if q.metrics != nil {
+		q.metrics.RecordOverflow(ctx, job.Type, outcome)
+	}
+}
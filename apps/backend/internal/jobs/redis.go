@@ -0,0 +1,196 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// defaultRedisPrefix namespaces the keys of the queue
+const defaultRedisPrefix = "jobs:"
+
+// popScript requeues jobs whose claim expired, then claims the first due
+// job and counts the attempt, so a run that crashes its worker still uses
+// one. KEYS are the due, running, job data and attempts keys; ARGV the
+// current time and lease end in milliseconds. It returns the job data and
+// attempt.
+var popScript = redis.NewScript(`
+local expired = redis.call("ZRANGEBYSCORE", KEYS[2], "-inf", ARGV[1], "LIMIT", 0, 100)
+for _, id in ipairs(expired) do
+	redis.call("ZREM", KEYS[2], id)
+	redis.call("ZADD", KEYS[1], ARGV[1], id)
+end
+
+local ids = redis.call("ZRANGEBYSCORE", KEYS[1], "-inf", ARGV[1], "LIMIT", 0, 1)
+if #ids == 0 then
+	return false
+end
+redis.call("ZREM", KEYS[1], ids[1])
+redis.call("ZADD", KEYS[2], ARGV[2], ids[1])
+local attempt = redis.call("HINCRBY", KEYS[4], ids[1], 1)
+return {redis.call("HGET", KEYS[3], ids[1]), attempt}`)
+
+// extendScript renews the claim of a job if it is still running
+var extendScript = redis.NewScript(`
+if redis.call("ZSCORE", KEYS[1], ARGV[1]) then
+	return redis.call("ZADD", KEYS[1], "XX", "CH", ARGV[2], ARGV[1])
+end
+return -1`)
+
+// completeScript removes a job if it is still running. KEYS are the
+// running, job data and attempts keys; ARGV the job ID.
+var completeScript = redis.NewScript(`
+if not redis.call("ZSCORE", KEYS[1], ARGV[1]) then
+	return -1
+end
+redis.call("ZREM", KEYS[1], ARGV[1])
+redis.call("HDEL", KEYS[2], ARGV[1])
+redis.call("HDEL", KEYS[3], ARGV[1])
+return 1`)
+
+// moveScript saves a job and moves it from the running set to another
+// sorted set if it is still running. KEYS are the running, job data,
+// attempts and destination keys; ARGV the job ID, data, attempt and score.
+var moveScript = redis.NewScript(`
+if not redis.call("ZSCORE", KEYS[1], ARGV[1]) then
+	return -1
+end
+redis.call("HSET", KEYS[2], ARGV[1], ARGV[2])
+redis.call("HSET", KEYS[3], ARGV[1], ARGV[3])
+redis.call("ZREM", KEYS[1], ARGV[1])
+redis.call("ZADD", KEYS[4], ARGV[4], ARGV[1])
+return 1`)
+
+// RedisStore keeps jobs in Redis: their data in a hash, and their IDs in
+// sorted sets of due, running and dead jobs scored by due time, lease end
+// and burial time. Attempts are counted in a hash of their own, updated by
+// the scripts claiming jobs.
+type RedisStore struct {
+	client                      redis.Cmdable
+	dueKey, runningKey, deadKey string
+	dataKey, attemptsKey        string
+}
+
+// NewRedisStore creates a Redis job store. Keys are prefixed with prefix,
+// "jobs:" when empty.
+func NewRedisStore(client redis.Cmdable, prefix string) *RedisStore {
+	if prefix == "" {
+		prefix = defaultRedisPrefix
+	}
+	return &RedisStore{
+		client:      client,
+		dueKey:      prefix + "due",
+		runningKey:  prefix + "running",
+		deadKey:     prefix + "dead",
+		dataKey:     prefix + "data",
+		attemptsKey: prefix + "attempts",
+	}
+}
+
+// Push implements Store
+func (s *RedisStore) Push(ctx context.Context, job *Job) error {
+	data, err := json.Marshal(job)
+	if err != nil {
+		return fmt.Errorf("failed to encode job: %w", err)
+	}
+
+	_, err = s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, s.dataKey, job.ID.String(), data)
+		pipe.ZAdd(ctx, s.dueKey, redis.Z{Score: float64(job.RunAt.UnixMilli()), Member: job.ID.String()})
+		return nil
+	})
+	return err
+}
+
+// Pop implements Store. The attempt is counted on claim, see popScript.
+func (s *RedisStore) Pop(ctx context.Context, lease time.Duration) (*Job, error) {
+	now := time.Now()
+	res, err := popScript.Run(ctx, s.client,
+		[]string{s.dueKey, s.runningKey, s.dataKey, s.attemptsKey},
+		now.UnixMilli(), now.Add(lease).UnixMilli(),
+	).Slice()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	data, _ := res[0].(string)
+	attempt, _ := res[1].(int64)
+
+	var job Job
+	if err := json.Unmarshal([]byte(data), &job); err != nil {
+		return nil, fmt.Errorf("failed to decode job: %w", err)
+	}
+	job.Attempt = int(attempt)
+	return &job, nil
+}
+
+// Extend implements Store
+func (s *RedisStore) Extend(ctx context.Context, job *Job, lease time.Duration) error {
+	n, err := extendScript.Run(ctx, s.client,
+		[]string{s.runningKey},
+		job.ID.String(), time.Now().Add(lease).UnixMilli(),
+	).Int()
+	if err != nil {
+		return err
+	}
+	if n < 0 {
+		return fmt.Errorf("job %s is no longer running", job.ID)
+	}
+	return nil
+}
+
+// Complete implements Store
+func (s *RedisStore) Complete(ctx context.Context, job *Job) error {
+	n, err := completeScript.Run(ctx, s.client,
+		[]string{s.runningKey, s.dataKey, s.attemptsKey},
+		job.ID.String(),
+	).Int()
+	if err != nil {
+		return err
+	}
+	if n < 0 {
+		return fmt.Errorf("job %s is no longer running", job.ID)
+	}
+	return nil
+}
+
+// Pending implements Store
+func (s *RedisStore) Pending(ctx context.Context) (int64, error) {
+	return s.client.ZCard(ctx, s.dueKey).Result()
+}
+
+// Retry implements Store
+func (s *RedisStore) Retry(ctx context.Context, job *Job) error {
+	return s.move(ctx, job, s.dueKey, job.RunAt)
+}
+
+// Bury implements Store. Dead jobs are kept until removed by hand.
+func (s *RedisStore) Bury(ctx context.Context, job *Job) error {
+	return s.move(ctx, job, s.deadKey, time.Now())
+}
+
+// move saves a running job and moves it to the sorted set key with the score of at
+func (s *RedisStore) move(ctx context.Context, job *Job, key string, at time.Time) error {
+	data, err := json.Marshal(job)
+	if err != nil {
+		return fmt.Errorf("failed to encode job: %w", err)
+	}
+
+	n, err := moveScript.Run(ctx, s.client,
+		[]string{s.runningKey, s.dataKey, s.attemptsKey, key},
+		job.ID.String(), data, job.Attempt, at.UnixMilli(),
+	).Int()
+	if err != nil {
+		return err
+	}
+	if n < 0 {
+		return fmt.Errorf("job %s is no longer running", job.ID)
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+// Code generated by enumgen -type=State; DO NOT EDIT.
+
+package jobs
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"slices"
+)
+
+// stateValues are the values of State, in declaration order
+var stateValues = []State{StatePending, StateRunning, StateDead}
+
+// StateValues returns the values of State, in declaration order
+func StateValues() []State {
+	return slices.Clone(stateValues)
+}
+
+// IsValid reports whether v is a declared value of State
+func (v State) IsValid() bool {
+	switch v {
+	case StatePending, StateRunning, StateDead:
+		return true
+	}
+	return false
+}
+
+// ParseState returns the State of s, or an error when s is not a declared value
+func ParseState(s string) (State, error) {
+	v := State(s)
+	if !v.IsValid() {
+		return "", fmt.Errorf("invalid State %q", s)
+	}
+	return v, nil
+}
+
+// MarshalText implements encoding.TextMarshaler
+func (v State) MarshalText() ([]byte, error) {
+	return []byte(v), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler, rejecting undeclared values
+func (v *State) UnmarshalText(text []byte) error {
+	parsed, err := ParseState(string(text))
+	if err != nil {
+		return err
+	}
+	*v = parsed
+	return nil
+}
+
+// Value implements driver.Valuer, rejecting undeclared values
+func (v State) Value() (driver.Value, error) {
+	if !v.IsValid() {
+		return nil, fmt.Errorf("invalid State %q", string(v))
+	}
+	return string(v), nil
+}
+
+// Scan implements sql.Scanner, rejecting undeclared values. NULL scans into
+// the zero value, which IsValid reports as undeclared.
+func (v *State) Scan(src any) error {
+	switch src := src.(type) {
+	case nil:
+		*v = ""
+		return nil
+	case string:
+		return v.UnmarshalText([]byte(src))
+	case []byte:
+		return v.UnmarshalText(src)
+	default:
+		return fmt.Errorf("cannot scan %T into State", src)
+	}
+}
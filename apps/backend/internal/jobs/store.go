@@ -0,0 +1,153 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	pgx "github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/PrinceNarteh/go-boilerplate/internal/anonymize"
+	"github.com/PrinceNarteh/go-boilerplate/internal/database"
+)
+
+// JobAnonymization leaves jobs out of staging snapshots, as job payloads
+// may hold personal data
+var JobAnonymization = anonymize.Skip("jobs")
+
+// PostgresStore keeps jobs in the jobs table. Workers claim jobs with
+// FOR UPDATE SKIP LOCKED, so they never wait on each other.
+//
+// States are written as State parameters, but the queries selecting jobs
+// by state spell them out, so the planner matches the partial indexes on
+// status.
+type PostgresStore struct {
+	db *pgxpool.Pool
+}
+
+// NewPostgresStore creates a new PostgreSQL job store
+func NewPostgresStore(db *pgxpool.Pool) *PostgresStore {
+	return &PostgresStore{db: db}
+}
+
+// Push implements Store. Due times are stored relative to the database
+// clock, like the lease ends compared to it.
+func (s *PostgresStore) Push(ctx context.Context, job *Job) error {
+	query := `
+		INSERT INTO jobs (id, type, payload, payload_version, max_attempts, run_at, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, NOW() + $6::interval, NOW(), NOW())`
+
+	_, err := s.db.Exec(
+		ctx, query, job.ID, job.Type, []byte(job.Payload), job.Version, job.MaxAttempts, time.Until(job.RunAt),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create job: %w", database.TranslateError(err))
+	}
+
+	return nil
+}
+
+// Pop implements Store. The attempt is counted on claim, so a run that
+// crashes its worker still uses one.
+func (s *PostgresStore) Pop(ctx context.Context, lease time.Duration) (*Job, error) {
+	query := `
+		UPDATE jobs
+		SET status = $2, attempt = attempt + 1, locked_until = NOW() + $1::interval, updated_at = NOW()
+		WHERE id = (
+			SELECT id FROM jobs
+			WHERE (status = 'pending' AND run_at <= NOW())
+				OR (status = 'running' AND locked_until < NOW())
+			ORDER BY run_at
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, type, payload, payload_version, attempt, max_attempts, last_error, run_at, created_at`
+
+	var job Job
+	var payload []byte
+	err := s.db.QueryRow(ctx, query, lease, StateRunning).Scan(
+		&job.ID,
+		&job.Type,
+		&payload,
+		&job.Version,
+		&job.Attempt,
+		&job.MaxAttempts,
+		&job.LastError,
+		&job.RunAt,
+		&job.CreatedAt,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim job: %w", database.TranslateError(err))
+	}
+	job.Payload = payload
+
+	return &job, nil
+}
+
+// Extend implements Store
+func (s *PostgresStore) Extend(ctx context.Context, job *Job, lease time.Duration) error {
+	query := `UPDATE jobs SET locked_until = NOW() + $2::interval WHERE id = $1 AND status = $3`
+
+	tag, err := s.db.Exec(ctx, query, job.ID, lease, StateRunning)
+	if err != nil {
+		return fmt.Errorf("failed to extend job: %w", database.TranslateError(err))
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("job %s is no longer running", job.ID)
+	}
+
+	return nil
+}
+
+// Complete implements Store
+func (s *PostgresStore) Complete(ctx context.Context, job *Job) error {
+	if _, err := s.db.Exec(ctx, `DELETE FROM jobs WHERE id = $1`, job.ID); err != nil {
+		return fmt.Errorf("failed to complete job: %w", database.TranslateError(err))
+	}
+
+	return nil
+}
+
+// Pending implements Store
+func (s *PostgresStore) Pending(ctx context.Context) (int64, error) {
+	var pending int64
+	if err := s.db.QueryRow(ctx, `SELECT COUNT(*) FROM jobs WHERE status = 'pending'`).Scan(&pending); err != nil {
+		return 0, fmt.Errorf("failed to count pending jobs: %w", database.TranslateError(err))
+	}
+
+	return pending, nil
+}
+
+// Retry implements Store
+func (s *PostgresStore) Retry(ctx context.Context, job *Job) error {
+	query := `
+		UPDATE jobs
+		SET status = $5, attempt = $2, last_error = $3, run_at = NOW() + $4::interval,
+			locked_until = NULL, updated_at = NOW()
+		WHERE id = $1`
+
+	if _, err := s.db.Exec(ctx, query, job.ID, job.Attempt, job.LastError, time.Until(job.RunAt), StatePending); err != nil {
+		return fmt.Errorf("failed to retry job: %w", database.TranslateError(err))
+	}
+
+	return nil
+}
+
+// Bury implements Store. Dead jobs are kept until removed by hand.
+func (s *PostgresStore) Bury(ctx context.Context, job *Job) error {
+	query := `
+		UPDATE jobs
+		SET status = $4, attempt = $2, last_error = $3, locked_until = NULL, updated_at = NOW()
+		WHERE id = $1`
+
+	if _, err := s.db.Exec(ctx, query, job.ID, job.Attempt, job.LastError, StateDead); err != nil {
+		return fmt.Errorf("failed to bury job: %w", database.TranslateError(err))
+	}
+
+	return nil
+}
@@ -0,0 +1,293 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/PrinceNarteh/go-boilerplate/internal/config"
+	"github.com/PrinceNarteh/go-boilerplate/internal/libs/async"
+	"github.com/PrinceNarteh/go-boilerplate/internal/libs/retry"
+	"github.com/PrinceNarteh/go-boilerplate/internal/telemetry"
+	"github.com/PrinceNarteh/go-boilerplate/internal/tracing"
+)
+
+const (
+	defaultConcurrency     = 10               // Default number of jobs run at once
+	defaultPollInterval    = time.Second      // Default delay between polls of an empty queue
+	defaultLease           = time.Minute      // Default duration of the claim of a running job
+	defaultMinBackoff      = 10 * time.Second // Default delay before the first retry
+	defaultMaxBackoff      = time.Hour        // Default longest delay between retries
+	defaultShutdownTimeout = 30 * time.Second // Default time given to running jobs on shutdown
+	storeTimeout           = 5 * time.Second  // Timeout of store operations outside a poll
+)
+
+// Worker runs the jobs of a queue with a pool of goroutines
+type Worker struct {
+	store  Store
+	cfg    config.JobsConfig
+	tracer tracing.Tracer
+	logger *zerolog.Logger
+	retry  retry.Policy
+
+	mu       sync.Mutex
+	handlers map[string]Handler
+	stop     func()
+}
+
+// NewWorker creates a worker claiming jobs from store. tracer records each
+// run as a transaction and may be nil. Unset sizes and durations of cfg use the
+// defaults.
+func NewWorker(store Store, cfg config.JobsConfig, tracer tracing.Tracer, logger *zerolog.Logger) *Worker {
+	if cfg.Concurrency <= 0 {
+		cfg.Concurrency = defaultConcurrency
+	}
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = defaultPollInterval
+	}
+	if cfg.Lease <= 0 {
+		cfg.Lease = defaultLease
+	}
+	if cfg.MinBackoff <= 0 {
+		cfg.MinBackoff = defaultMinBackoff
+	}
+	if cfg.MaxBackoff < cfg.MinBackoff {
+		cfg.MaxBackoff = max(defaultMaxBackoff, cfg.MinBackoff)
+	}
+	if cfg.ShutdownTimeout <= 0 {
+		cfg.ShutdownTimeout = defaultShutdownTimeout
+	}
+
+	return &Worker{
+		store:    store,
+		cfg:      cfg,
+		tracer:   tracer,
+		logger:   logger,
+		retry:    retry.Policy{MinBackoff: cfg.MinBackoff, MaxBackoff: cfg.MaxBackoff},
+		handlers: make(map[string]Handler),
+	}
+}
+
+// Handle registers the handler of a job type. It must be called before Start.
+func (w *Worker) Handle(jobType string, handler Handler) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.handlers[jobType] = handler
+}
+
+// Start runs jobs in the background until Stop is called
+func (w *Worker) Start() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.stop != nil {
+		return
+	}
+
+	// Polling stops on Stop, while running jobs keep their context until
+	// the shutdown timeout
+	pollCtx, stopPolling := context.WithCancel(context.Background())
+	runCtx, cancelRuns := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	for range w.cfg.Concurrency {
+		wg.Add(1)
+		async.Go(pollCtx, "jobs.worker", func(pollCtx context.Context) error {
+			defer wg.Done()
+			w.poll(pollCtx, runCtx)
+			return nil
+		})
+	}
+
+	w.stop = func() {
+		stopPolling()
+
+		drained := make(chan struct{})
+		go func() {
+			wg.Wait()
+			close(drained)
+		}()
+		select {
+		case <-drained:
+		case <-time.After(w.cfg.ShutdownTimeout):
+			w.logger.Warn().Dur("timeout", w.cfg.ShutdownTimeout).Msg("Canceling running jobs on shutdown")
+			cancelRuns()
+			<-drained
+		}
+		cancelRuns()
+	}
+}
+
+// Stop stops claiming jobs and waits for running jobs to finish, up to
+// the shutdown timeout. Jobs still running then see their context canceled;
+// those that do not fail run again once their lease expires.
+func (w *Worker) Stop() {
+	w.mu.Lock()
+	stop := w.stop
+	w.stop = nil
+	w.mu.Unlock()
+
+	if stop != nil {
+		stop()
+	}
+}
+
+// poll claims and runs jobs one at a time until pollCtx is done
+func (w *Worker) poll(pollCtx, runCtx context.Context) {
+	for {
+		if pollCtx.Err() != nil {
+			return
+		}
+
+		job, err := w.store.Pop(pollCtx, w.cfg.Lease)
+		if err != nil && pollCtx.Err() == nil {
+			w.logger.Error().Err(err).Msg("Failed to claim job")
+		}
+		if job == nil {
+			// Jitter spreads the polls of idle workers
+			wait := w.cfg.PollInterval + rand.N(w.cfg.PollInterval/2+1)
+			select {
+			case <-pollCtx.Done():
+				return
+			case <-time.After(wait):
+			}
+			continue
+		}
+
+		w.run(runCtx, job)
+	}
+}
+
+// run runs a claimed job, renewing its claim meanwhile, and records the outcome
+func (w *Worker) run(ctx context.Context, job *Job) {
+	log := w.logger.With().Stringer("job_id", job.ID).Str("job_type", job.Type).Int("attempt", job.Attempt).Logger()
+
+	// The store counts attempts on claim, so a job past its last attempt
+	// crashed its worker on every run and is not run again
+	if job.Attempt > job.MaxAttempts {
+		log.Error().Msg("Job ran out of attempts without completing, moving it to the dead-letter queue")
+		job.Attempt = job.MaxAttempts
+		job.LastError = "worker stopped during every attempt"
+		storeCtx, cancelStore := context.WithTimeout(context.WithoutCancel(ctx), storeTimeout)
+		defer cancelStore()
+		if err := w.store.Bury(storeCtx, job); err != nil {
+			log.Error().Err(err).Msg("Failed to bury job")
+		}
+		return
+	}
+
+	txn := tracing.Start(w.tracer, "job/"+job.Type)
+	defer txn.End()
+	txn.AddAttribute("job.id", job.ID.String())
+	txn.AddAttribute("job.attempt", job.Attempt)
+
+	runCtx, cancel := context.WithCancel(tracing.NewContext(ctx, txn))
+	defer cancel()
+	stopRenewing := w.renew(runCtx, job, cancel, &log)
+
+	start := time.Now()
+	err := w.handle(runCtx, job)
+	if lost := stopRenewing(); lost {
+		// Another worker may have claimed the job, which is now its own
+		return
+	}
+
+	storeCtx, cancelStore := context.WithTimeout(context.WithoutCancel(ctx), storeTimeout)
+	defer cancelStore()
+
+	if err == nil {
+		log.Info().Dur("duration", time.Since(start)).Msg("Job completed")
+		if err := w.store.Complete(storeCtx, job); err != nil {
+			log.Error().Err(err).Msg("Failed to complete job")
+		}
+		return
+	}
+
+	if ctx.Err() != nil {
+		// Interrupted by shutdown rather than failed, so the attempt is given back
+		log.Warn().Err(err).Msg("Job interrupted by shutdown, requeuing it")
+		job.Attempt--
+		job.RunAt = time.Now()
+		if err := w.store.Retry(storeCtx, job); err != nil {
+			log.Error().Err(err).Msg("Failed to requeue job")
+		}
+		return
+	}
+
+	telemetry.ReportError(runCtx, "job/"+job.Type, err)
+	job.LastError = err.Error()
+	if job.Attempt >= job.MaxAttempts {
+		log.Error().Err(err).Dur("duration", time.Since(start)).Msg("Job failed, moving it to the dead-letter queue")
+		if err := w.store.Bury(storeCtx, job); err != nil {
+			log.Error().Err(err).Msg("Failed to bury job")
+		}
+		return
+	}
+
+	job.RunAt = time.Now().Add(w.retry.Backoff(job.Attempt))
+	log.Warn().Err(err).Dur("duration", time.Since(start)).Time("retry_at", job.RunAt).Msg("Job failed, retrying")
+	if err := w.store.Retry(storeCtx, job); err != nil {
+		log.Error().Err(err).Msg("Failed to retry job")
+	}
+}
+
+// handle calls the handler of a job, recovering panics
+func (w *Worker) handle(ctx context.Context, job *Job) (err error) {
+	w.mu.Lock()
+	handler, ok := w.handlers[job.Type]
+	w.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("%w %q", ErrNoHandler, job.Type)
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("job panicked: %v", p)
+		}
+	}()
+	return handler(ctx, job)
+}
+
+// renew extends the claim of a job every third of the lease until the
+// returned function is called, which reports whether the claim was lost.
+// When the claim cannot be extended, another worker may claim the job, so
+// cancel stops the run.
+func (w *Worker) renew(ctx context.Context, job *Job, cancel context.CancelFunc, log *zerolog.Logger) func() bool {
+	done := make(chan struct{})
+	var lost bool
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(w.cfg.Lease / 3)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			extendCtx, cancelExtend := context.WithTimeout(ctx, storeTimeout)
+			err := w.store.Extend(extendCtx, job, w.cfg.Lease)
+			cancelExtend()
+			if err != nil && ctx.Err() == nil {
+				log.Error().Err(err).Msg("Failed to extend job lease, canceling the run")
+				lost = true
+				cancel()
+				return
+			}
+		}
+	}()
+
+	return func() bool {
+		close(done)
+		wg.Wait()
+		return lost
+	}
+}
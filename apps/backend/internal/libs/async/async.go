@@ -0,0 +1,171 @@
+// Package async launches background goroutines that cannot crash the
+// process.
+//
+// A goroutine started with Go recovers its panics, logs its failures with
+// the logger of its context, or the one set by Configure, and reports them
+// to the New Relic transaction of its context, or to a background
+// transaction of the tracer set by Configure. Its context is canceled
+// by Shutdown, so background loops stop with the process, and Shutdown
+// waits for them to return.
+//
+// Use Go instead of a bare go statement for any goroutine outliving the
+// call that starts it. Goroutines that already recover their panics, such
+// as the asynchronous subscribers of the event bus, do not need it.
+package async
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"slices"
+	"sync"
+
+	"github.com/rs/zerolog"
+
+	"github.com/PrinceNarteh/go-boilerplate/internal/tracing"
+)
+
+// PanicError is the error of a goroutine that panicked
+type PanicError struct {
+	Name  string
+	Value any
+	Stack []byte
+}
+
+// Error implements the error interface
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("goroutine %s panicked: %v", e.Name, e.Value)
+}
+
+var (
+	mu      sync.Mutex
+	logger  = zerolog.Nop()
+	tracer  tracing.Tracer
+	running = make(map[string]int)
+	wg      sync.WaitGroup
+
+	// shutdownCtx is canceled by Shutdown, canceling the context of every goroutine
+	shutdownCtx, cancelShutdown = context.WithCancel(context.Background())
+)
+
+// Configure sets the logger and the tracer, which may be nil, reporting
+// the failures of goroutines whose context carries neither.
+// It is meant to be called once at startup.
+func Configure(l *zerolog.Logger, t tracing.Tracer) {
+	mu.Lock()
+	defer mu.Unlock()
+	logger = *l
+	tracer = t
+}
+
+// Go runs fn in a new goroutine named name, e.g. "outbox.dispatcher". The
+// context of fn is canceled when ctx is done or on Shutdown. A panic of fn
+// is recovered and returned as a *PanicError. Errors, except the
+// cancellation of the context, are logged and reported to New Relic.
+//
+// The returned channel receives the error of fn, nil on success, and is
+// then closed, so receiving from it waits for the goroutine to return.
+func Go(ctx context.Context, name string, fn func(ctx context.Context) error) <-chan error {
+	ctx, cancel := context.WithCancel(ctx)
+	stop := context.AfterFunc(shutdownCtx, cancel)
+	if txn, ok := tracing.FromContext(ctx); ok {
+		ctx = tracing.NewContext(ctx, txn.NewGoroutine())
+	}
+
+	mu.Lock()
+	running[name]++
+	wg.Add(1)
+	mu.Unlock()
+
+	done := make(chan error, 1)
+	go func() {
+		defer func() {
+			mu.Lock()
+			if running[name]--; running[name] == 0 {
+				delete(running, name)
+			}
+			mu.Unlock()
+			wg.Done()
+		}()
+		defer stop()
+		defer cancel()
+
+		err := run(ctx, name, fn)
+		if err != nil && (ctx.Err() == nil || !errors.Is(err, context.Canceled)) {
+			report(ctx, name, err)
+		}
+		done <- err
+		close(done)
+	}()
+	return done
+}
+
+// Shutdown cancels the context of every goroutine started with Go and
+// waits for them to return, until ctx is done. Goroutines started afterwards
+// see their context canceled.
+func Shutdown(ctx context.Context) error {
+	cancelShutdown()
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("goroutines still running %v: %w", Running(), ctx.Err())
+	}
+}
+
+// Running returns the names of the goroutines started with Go that have
+// not returned yet
+func Running() []string {
+	mu.Lock()
+	defer mu.Unlock()
+
+	names := make([]string, 0, len(running))
+	for name := range running {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// run calls fn, recovering its panic
+func run(ctx context.Context, name string, fn func(ctx context.Context) error) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = &PanicError{Name: name, Value: p, Stack: debug.Stack()}
+		}
+	}()
+	return fn(ctx)
+}
+
+// report logs the failure of a goroutine and notices it in New Relic
+func report(ctx context.Context, name string, err error) {
+	mu.Lock()
+	log, t := logger, tracer
+	mu.Unlock()
+	if l := zerolog.Ctx(ctx); l.GetLevel() != zerolog.Disabled {
+		log = *l
+	}
+
+	event := log.Error().Str("goroutine", name).Err(err)
+	var panicErr *PanicError
+	if errors.As(err, &panicErr) {
+		event = event.Bytes("stack", panicErr.Stack)
+	}
+	event.Msg("Goroutine failed")
+
+	if txn, ok := tracing.FromContext(ctx); ok {
+		txn.NoticeError(err)
+		return
+	}
+	txn := tracing.Start(t, "async/"+name)
+	txn.NoticeError(err)
+	txn.End()
+}
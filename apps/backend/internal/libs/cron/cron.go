@@ -0,0 +1,236 @@
+// Package cron parses standard five-field cron expressions and computes
+// their activation times.
+//
+// An expression has the fields minute (0-59), hour (0-23), day of month
+// (1-31), month (1-12 or jan-dec) and day of week (0-7 or sun-sat, where 0
+// and 7 are Sunday). Each field is "*", a value, a range "a-b" or a
+// comma-separated list of them, optionally followed by a step "/n". As in
+// Vixie cron, when both day fields are restricted a time matches if either
+// one does. The macros @yearly, @annually, @monthly, @weekly, @daily,
+// @midnight and @hourly are also accepted.
+package cron
+
+import (
+	"errors"
+	"fmt"
+	"math/bits"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxSearchYears bounds the search for the next activation, so expressions
+// that can never match, such as February 30th, do not loop forever
+const maxSearchYears = 5
+
+// ErrNoActivation is returned by Schedule.Next for expressions that never match
+var ErrNoActivation = errors.New("cron: expression never matches")
+
+// macros are the accepted shorthands and their expressions
+var macros = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// field describes the values accepted by a field of an expression
+type field struct {
+	name     string
+	min, max int
+	names    map[string]int
+}
+
+// fields are the fields of an expression, in order
+var fields = [5]field{
+	{name: "minute", min: 0, max: 59},
+	{name: "hour", min: 0, max: 23},
+	{name: "day of month", min: 1, max: 31},
+	{name: "month", min: 1, max: 12, names: map[string]int{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}},
+	{name: "day of week", min: 0, max: 7, names: map[string]int{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}},
+}
+
+// Schedule is a parsed cron expression
+type Schedule struct {
+	expr string
+	// Bit sets of the accepted values of each field
+	minute, hour, dom, month, dow uint64
+	// Whether the day fields are "*", for the Vixie cron day matching rule
+	domStar, dowStar bool
+}
+
+// Parse parses a cron expression. Errors name the offending field and value.
+func Parse(expr string) (*Schedule, error) {
+	spec := strings.TrimSpace(expr)
+	if strings.HasPrefix(spec, "@") {
+		macro, ok := macros[strings.ToLower(spec)]
+		if !ok {
+			return nil, fmt.Errorf("cron: unknown macro %q", spec)
+		}
+		spec = macro
+	}
+
+	parts := strings.Fields(spec)
+	if len(parts) != len(fields) {
+		return nil, fmt.Errorf(
+			"cron: expected 5 fields (minute hour day-of-month month day-of-week), got %d in %q", len(parts), expr,
+		)
+	}
+
+	sets := make([]uint64, len(fields))
+	for i, part := range parts {
+		set, err := parseField(part, fields[i])
+		if err != nil {
+			return nil, err
+		}
+		sets[i] = set
+	}
+
+	// Sunday can be written 0 or 7
+	dow := sets[4]
+	if dow&(1<<7) != 0 {
+		dow |= 1
+		dow &^= 1 << 7
+	}
+
+	return &Schedule{
+		expr:    expr,
+		minute:  sets[0],
+		hour:    sets[1],
+		dom:     sets[2],
+		month:   sets[3],
+		dow:     dow,
+		domStar: parts[2] == "*",
+		dowStar: parts[4] == "*",
+	}, nil
+}
+
+// String returns the expression the schedule was parsed from
+func (s *Schedule) String() string {
+	return s.expr
+}
+
+// Next returns the first activation strictly after t, in the location of t.
+// It returns ErrNoActivation when there is none within five years.
+func (s *Schedule) Next(t time.Time) (time.Time, error) {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(maxSearchYears, 0, 0)
+
+	for t.Before(limit) {
+		switch {
+		case s.month&(1<<uint(t.Month())) == 0:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+		case !s.dayMatches(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+		case s.hour&(1<<uint(t.Hour())) == 0:
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+		case s.minute&(1<<uint(t.Minute())) == 0:
+			t = t.Truncate(time.Minute).Add(time.Minute)
+		default:
+			return t, nil
+		}
+	}
+
+	return time.Time{}, ErrNoActivation
+}
+
+// dayMatches reports whether the day of t matches the day fields
+func (s *Schedule) dayMatches(t time.Time) bool {
+	domMatch := s.dom&(1<<uint(t.Day())) != 0
+	dowMatch := s.dow&(1<<uint(t.Weekday())) != 0
+	if s.domStar || s.dowStar {
+		return domMatch && dowMatch
+	}
+	return domMatch || dowMatch
+}
+
+// parseField parses a field into the bit set of its accepted values
+func parseField(part string, f field) (uint64, error) {
+	var set uint64
+	for item := range strings.SplitSeq(part, ",") {
+		itemSet, err := parseItem(item, f)
+		if err != nil {
+			return 0, err
+		}
+		set |= itemSet
+	}
+	return set, nil
+}
+
+// parseItem parses a single item of a field: "*", "a", "a-b", each with an optional "/step"
+func parseItem(item string, f field) (uint64, error) {
+	rangePart, stepPart, hasStep := strings.Cut(item, "/")
+
+	step := 1
+	if hasStep {
+		n, err := strconv.Atoi(stepPart)
+		if err != nil || n <= 0 {
+			return 0, fmt.Errorf("cron: %s: invalid step %q in %q", f.name, stepPart, item)
+		}
+		step = n
+	}
+
+	var low, high int
+	switch {
+	case rangePart == "*":
+		low, high = f.min, f.max
+	case strings.Contains(rangePart, "-"):
+		lowPart, highPart, _ := strings.Cut(rangePart, "-")
+		var err error
+		if low, err = parseValue(lowPart, f); err != nil {
+			return 0, err
+		}
+		if high, err = parseValue(highPart, f); err != nil {
+			return 0, err
+		}
+		if low > high {
+			return 0, fmt.Errorf("cron: %s: range %q starts after it ends", f.name, rangePart)
+		}
+	default:
+		value, err := parseValue(rangePart, f)
+		if err != nil {
+			return 0, err
+		}
+		low, high = value, value
+		if hasStep {
+			// "a/n" means every n starting at a
+			high = f.max
+		}
+	}
+
+	var set uint64
+	for v := low; v <= high; v += step {
+		set |= 1 << uint(v)
+	}
+	if bits.OnesCount64(set) == 0 {
+		return 0, fmt.Errorf("cron: %s: %q matches no value", f.name, item)
+	}
+	return set, nil
+}
+
+// parseValue parses a number or name within the bounds of a field
+func parseValue(s string, f field) (int, error) {
+	if value, ok := f.names[strings.ToLower(s)]; ok {
+		return value, nil
+	}
+
+	value, err := strconv.Atoi(s)
+	if err != nil {
+		if f.names != nil {
+			return 0, fmt.Errorf("cron: %s: %q is neither a number nor a known name", f.name, s)
+		}
+		return 0, fmt.Errorf("cron: %s: %q is not a number", f.name, s)
+	}
+	if value < f.min || value > f.max {
+		return 0, fmt.Errorf("cron: %s: %d is out of range %d-%d", f.name, value, f.min, f.max)
+	}
+	return value, nil
+}
@@ -0,0 +1,32 @@
+package libs
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+
+	"github.com/PrinceNarteh/go-boilerplate/internal/errs"
+)
+
+// EncodeCursor encodes the keyset position v into an opaque cursor token.
+// Clients must treat the token as opaque and pass it back unchanged.
+func EncodeCursor(v any) (string, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode cursor: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// DecodeCursor decodes a cursor token produced by EncodeCursor into v.
+// It returns a validation error when the token is malformed.
+func DecodeCursor(cursor string, v any) error {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return errs.NewValidation("invalid cursor")
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return errs.NewValidation("invalid cursor")
+	}
+	return nil
+}
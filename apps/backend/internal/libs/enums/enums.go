@@ -0,0 +1,53 @@
+// Package enums supports the typed string enums generated by cmd/enumgen:
+// the "enum" validation tag of request fields, and a check that a switch
+// handles every value of an enum.
+package enums
+
+import (
+	"fmt"
+	"strings"
+
+	validator "github.com/go-playground/validator/v10"
+)
+
+// Enum is a typed string enum generated by cmd/enumgen
+type Enum interface {
+	~string
+	IsValid() bool
+}
+
+// Tag is the validation tag of enum fields, as in validate:"required,enum"
+const Tag = "enum"
+
+// Validate implements the "enum" validation: a field passes when its type
+// declares its value, or when it is empty, so that the tag combines with
+// required. Slices of enums are checked with dive, as in
+// validate:"dive,enum". Fields without an IsValid method always pass.
+func Validate(fl validator.FieldLevel) bool {
+	field := fl.Field()
+	if field.IsZero() {
+		return true
+	}
+	v, ok := field.Interface().(interface{ IsValid() bool })
+	return !ok || v.IsValid()
+}
+
+// CheckExhaustive calls handled with every value and returns an error
+// naming the values it reports as not handled. Tests use it to assert that
+// a switch covers every value of an enum, including the ones added later:
+//
+//	err := enums.CheckExhaustive(jobs.StateValues(), func(s jobs.State) bool {
+//		return describe(s) != ""
+//	})
+func CheckExhaustive[T Enum](values []T, handled func(T) bool) error {
+	var missing []string
+	for _, v := range values {
+		if !handled(v) {
+			missing = append(missing, string(v))
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("values not handled: %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
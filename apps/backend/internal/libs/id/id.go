@@ -0,0 +1,318 @@
+// Package id generates the identifiers of the application: request IDs, job
+// IDs and the public identifiers of resources.
+//
+// IDs are ULIDs: 128 bits made of a millisecond timestamp followed by 80
+// random bits, written as 26 Crockford base32 characters such as
+// "01J9ZKXW3Q8N6V2T4R5Y7B0C1D". They sort by creation time, both as text
+// and as bytes, so they make good primary keys and cursors. IDs generated
+// by a process within the same millisecond increment the random part and
+// stay strictly increasing.
+//
+// An ID is stored in PostgreSQL UUID columns in binary form, and in text
+// columns and JSON as its ULID text. Parse also accepts the UUID form, so
+// IDs read back from UUID columns or created before ULIDs were used still
+// parse.
+package id
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql/driver"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const (
+	encodedLen      = 26                                 // Length of the text form of an ID
+	shortRandomLen  = 16                                 // Random characters of a short ID, 80 bits
+	maxUniqueTries  = 5                                  // Attempts of NewShortUnique before giving up
+	crockfordDigits = "0123456789ABCDEFGHJKMNPQRSTVWXYZ" // Crockford base32 alphabet
+)
+
+var (
+	// ErrInvalid is returned when parsing malformed IDs
+	ErrInvalid = errors.New("id: invalid id")
+	// ErrCollision is returned by NewShortUnique when every attempt was taken
+	ErrCollision = errors.New("id: could not generate a unique id")
+)
+
+// decoding maps the characters of the alphabet to their values, -1 elsewhere.
+// Lowercase letters and the easily confused I, L and O are accepted too.
+var decoding = func() [256]int8 {
+	var table [256]int8
+	for i := range table {
+		table[i] = -1
+	}
+	for i, c := range crockfordDigits {
+		table[c] = int8(i)
+		table[strings.ToLower(string(c))[0]] = int8(i)
+	}
+	for c, v := range map[byte]int8{'I': 1, 'i': 1, 'L': 1, 'l': 1, 'O': 0, 'o': 0} {
+		table[c] = v
+	}
+	return table
+}()
+
+// ID is a ULID. The zero ID is invalid and marshals as an empty string.
+type ID [16]byte
+
+// Nil is the zero ID
+var Nil ID
+
+// generator produces strictly increasing IDs
+var generator struct {
+	mu   sync.Mutex
+	last ID
+}
+
+// New returns a new ID for the current time
+func New() ID {
+	return NewAt(time.Now())
+}
+
+// NewString returns the text form of a new ID
+func NewString() string {
+	return New().String()
+}
+
+// NewAt returns a new ID for t. IDs generated within the same millisecond,
+// or with a clock going backwards, follow the last ID generated.
+func NewAt(t time.Time) ID {
+	var id ID
+	ms := uint64(t.UnixMilli())
+	id[0], id[1] = byte(ms>>40), byte(ms>>32)
+	binary.BigEndian.PutUint32(id[2:6], uint32(ms))
+
+	generator.mu.Lock()
+	defer generator.mu.Unlock()
+
+	if id.timestamp() <= generator.last.timestamp() {
+		// Keep the order by incrementing the previous ID; the 80 random bits
+		// make overflowing into the timestamp practically impossible
+		id = generator.last
+		for i := len(id) - 1; i >= 0; i-- {
+			id[i]++
+			if id[i] != 0 {
+				break
+			}
+		}
+	} else {
+		// crypto/rand.Read never fails
+		_, _ = rand.Read(id[6:])
+	}
+
+	generator.last = id
+	return id
+}
+
+// Parse parses the ULID or UUID text form of an ID
+func Parse(s string) (ID, error) {
+	switch len(s) {
+	case encodedLen:
+		return decode(s)
+	case 36, 32:
+		u, err := uuid.Parse(s)
+		if err != nil {
+			return Nil, fmt.Errorf("%w: %q", ErrInvalid, s)
+		}
+		return ID(u), nil
+	default:
+		return Nil, fmt.Errorf("%w: %q", ErrInvalid, s)
+	}
+}
+
+// MustParse parses an ID like Parse and panics on error, for constants
+func MustParse(s string) ID {
+	id, err := Parse(s)
+	if err != nil {
+		panic(err)
+	}
+	return id
+}
+
+// String returns the ULID text form of the ID
+func (id ID) String() string {
+	return string(id.encode())
+}
+
+// UUID returns the ID in UUID form, for systems expecting UUIDs
+func (id ID) UUID() string {
+	return uuid.UUID(id).String()
+}
+
+// Time returns the creation time of the ID, to the millisecond
+func (id ID) Time() time.Time {
+	return time.UnixMilli(int64(id.timestamp()))
+}
+
+// IsZero reports whether the ID is Nil
+func (id ID) IsZero() bool {
+	return id == Nil
+}
+
+// Compare returns -1, 0 or 1 when id sorts before, like or after other
+func (id ID) Compare(other ID) int {
+	for i := range id {
+		switch {
+		case id[i] < other[i]:
+			return -1
+		case id[i] > other[i]:
+			return 1
+		}
+	}
+	return 0
+}
+
+// MarshalText implements encoding.TextMarshaler, used by JSON
+func (id ID) MarshalText() ([]byte, error) {
+	if id.IsZero() {
+		return []byte{}, nil
+	}
+	return id.encode(), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler, used by JSON.
+// An empty text is the zero ID.
+func (id *ID) UnmarshalText(text []byte) error {
+	if len(text) == 0 {
+		*id = Nil
+		return nil
+	}
+	parsed, err := Parse(string(text))
+	if err != nil {
+		return err
+	}
+	*id = parsed
+	return nil
+}
+
+// ScanUUID implements pgtype.UUIDScanner, for UUID columns
+func (id *ID) ScanUUID(v pgtype.UUID) error {
+	if !v.Valid {
+		*id = Nil
+		return nil
+	}
+	*id = v.Bytes
+	return nil
+}
+
+// UUIDValue implements pgtype.UUIDValuer, for UUID columns
+func (id ID) UUIDValue() (pgtype.UUID, error) {
+	return pgtype.UUID{Bytes: id, Valid: !id.IsZero()}, nil
+}
+
+// Scan implements sql.Scanner, for text columns
+func (id *ID) Scan(src any) error {
+	switch v := src.(type) {
+	case nil:
+		*id = Nil
+		return nil
+	case string:
+		return id.UnmarshalText([]byte(v))
+	case []byte:
+		if len(v) == len(id) {
+			copy(id[:], v)
+			return nil
+		}
+		return id.UnmarshalText(v)
+	default:
+		return fmt.Errorf("id: cannot scan %T", src)
+	}
+}
+
+// Value implements driver.Valuer, for text columns. The zero ID is NULL.
+func (id ID) Value() (driver.Value, error) {
+	if id.IsZero() {
+		return nil, nil
+	}
+	return id.String(), nil
+}
+
+// NewShort returns a short random public identifier, such as
+// "inv_8ZK3QW5N2T7R4Y6B" for the prefix "inv". It has 80 random
+// bits and no timestamp, so it reveals neither creation time nor volume.
+// The prefix names the kind of resource and may be empty.
+func NewShort(prefix string) string {
+	var buf [10]byte
+	_, _ = rand.Read(buf[:])
+
+	// Each character holds 5 of the 80 bits
+	chars := make([]byte, shortRandomLen)
+	hi, lo := uint64(binary.BigEndian.Uint16(buf[:2])), binary.BigEndian.Uint64(buf[2:])
+	for i := shortRandomLen - 1; i >= 0; i-- {
+		chars[i] = crockfordDigits[lo&31]
+		lo = lo>>5 | (hi&31)<<59
+		hi >>= 5
+	}
+
+	if prefix == "" {
+		return string(chars)
+	}
+	return prefix + "_" + string(chars)
+}
+
+// NewShortUnique returns a short identifier like NewShort that exists
+// reports as unused, such as one checked against a unique column. The
+// chance of a collision is negligible, so retries guard against a broken
+// random source more than against bad luck.
+func NewShortUnique(
+	ctx context.Context,
+	prefix string,
+	exists func(ctx context.Context, id string) (bool, error),
+) (string, error) {
+	for range maxUniqueTries {
+		short := NewShort(prefix)
+		taken, err := exists(ctx, short)
+		if err != nil {
+			return "", err
+		}
+		if !taken {
+			return short, nil
+		}
+	}
+	return "", ErrCollision
+}
+
+// timestamp returns the millisecond timestamp of the ID
+func (id ID) timestamp() uint64 {
+	return uint64(id[0])<<40 | uint64(id[1])<<32 | uint64(binary.BigEndian.Uint32(id[2:6]))
+}
+
+// encode returns the ULID text form of the ID: the 128 bits as 26 base32
+// characters, the first holding only the 3 most significant bits
+func (id ID) encode() []byte {
+	out := make([]byte, encodedLen)
+	hi, lo := binary.BigEndian.Uint64(id[:8]), binary.BigEndian.Uint64(id[8:])
+	for i := encodedLen - 1; i >= 0; i-- {
+		out[i] = crockfordDigits[lo&31]
+		lo = lo>>5 | (hi&31)<<59
+		hi >>= 5
+	}
+	return out
+}
+
+// decode parses the ULID text form of an ID
+func decode(s string) (ID, error) {
+	var hi, lo uint64
+	for i := range encodedLen {
+		v := decoding[s[i]]
+		if v < 0 || (i == 0 && v > 7) {
+			// The first character holds 3 bits, larger values overflow 128 bits
+			return Nil, fmt.Errorf("%w: %q", ErrInvalid, s)
+		}
+		hi = hi<<5 | lo>>59
+		lo = lo<<5 | uint64(v)
+	}
+
+	var id ID
+	binary.BigEndian.PutUint64(id[:8], hi)
+	binary.BigEndian.PutUint64(id[8:], lo)
+	return id, nil
+}
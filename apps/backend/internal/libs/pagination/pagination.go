@@ -0,0 +1,279 @@
+// Package pagination parses pagination, sorting and filtering query
+// parameters for list endpoints and builds the matching SQL fragments.
+//
+// Query parameters follow the format
+//
+//	?page=2&per_page=50&sort=-created_at,email&filter[email]=jane@example.com
+//
+// where a leading "-" sorts in descending order. Only fields declared in
+// Options are accepted, and they are mapped to column names, so the SQL
+// fragments produced by Params are safe to interpolate into queries.
+package pagination
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/PrinceNarteh/go-boilerplate/internal/errs"
+	"github.com/PrinceNarteh/go-boilerplate/internal/i18n"
+)
+
+const (
+	// DefaultPerPage is the page size used when per_page is not set
+	DefaultPerPage = 20
+	// MaxPerPage is the largest page size accepted by default
+	MaxPerPage = 100
+	// MaxPage is the last page number accepted by default. Deep offsets scan
+	// every skipped row, and larger pages could overflow the OFFSET, so lists
+	// past it should use cursor pagination.
+	MaxPage = 10000
+)
+
+// SortField is a column to sort by
+type SortField struct {
+	Column string
+	Desc   bool
+}
+
+// Options declares the sortable and filterable fields of a list endpoint.
+// The maps go from the public field name used in the query string to the
+// SQL column name.
+type Options struct {
+	DefaultPerPage int
+	MaxPerPage     int
+	MaxPage        int
+	Sortable       map[string]string
+	Filterable     map[string]string
+	DefaultSort    []SortField
+}
+
+// Params holds the parsed pagination, sorting and filtering parameters
+type Params struct {
+	Page    int
+	PerPage int
+	Sort    []SortField
+	// Filters maps column names to the value they must equal
+	Filters map[string]string
+}
+
+// Parse parses the query parameters of a list request.
+// It returns a validation error describing every invalid parameter.
+func Parse(query url.Values, opts Options) (Params, error) {
+	if opts.DefaultPerPage <= 0 {
+		opts.DefaultPerPage = DefaultPerPage
+	}
+	if opts.MaxPerPage <= 0 {
+		opts.MaxPerPage = MaxPerPage
+	}
+	if opts.MaxPage <= 0 {
+		opts.MaxPage = MaxPage
+	}
+
+	params := Params{
+		Page:    1,
+		PerPage: opts.DefaultPerPage,
+		Sort:    opts.DefaultSort,
+		Filters: make(map[string]string),
+	}
+	details := make(map[string]string)
+
+	if v := query.Get("page"); v != "" {
+		page, err := strconv.Atoi(v)
+		if err != nil || page < 1 || page > opts.MaxPage {
+			details["page"] = fmt.Sprintf("page must be between 1 and %d", opts.MaxPage)
+		}
+		params.Page = page
+	}
+
+	if v := query.Get("per_page"); v != "" {
+		perPage, err := strconv.Atoi(v)
+		if err != nil || perPage < 1 || perPage > opts.MaxPerPage {
+			details["per_page"] = fmt.Sprintf("per_page must be between 1 and %d", opts.MaxPerPage)
+		}
+		params.PerPage = perPage
+	}
+
+	if v := query.Get("sort"); v != "" {
+		params.Sort = nil
+		for _, field := range strings.Split(v, ",") {
+			desc := strings.HasPrefix(field, "-")
+			column, ok := opts.Sortable[strings.TrimPrefix(field, "-")]
+			if !ok {
+				details["sort"] = fmt.Sprintf("cannot sort by %q", strings.TrimPrefix(field, "-"))
+				break
+			}
+			params.Sort = append(params.Sort, SortField{Column: column, Desc: desc})
+		}
+	}
+
+	for key, values := range query {
+		name, ok := strings.CutPrefix(key, "filter[")
+		if !ok || !strings.HasSuffix(name, "]") {
+			continue
+		}
+		name = strings.TrimSuffix(name, "]")
+		column, ok := opts.Filterable[name]
+		if !ok {
+			details[key] = fmt.Sprintf("cannot filter by %q", name)
+			continue
+		}
+		params.Filters[column] = values[0]
+	}
+
+	if len(details) > 0 {
+		return Params{}, errs.NewValidation("Invalid list parameters").WithDetails(details)
+	}
+
+	return params, nil
+}
+
+// Limit returns the number of rows to fetch
+func (p Params) Limit() int {
+	return p.PerPage
+}
+
+// Offset returns the number of rows to skip
+func (p Params) Offset() int {
+	return (p.Page - 1) * p.PerPage
+}
+
+// OrderBy returns the ORDER BY clause, or an empty string when unsorted
+func (p Params) OrderBy() string {
+	if len(p.Sort) == 0 {
+		return ""
+	}
+
+	parts := make([]string, len(p.Sort))
+	for i, s := range p.Sort {
+		direction := "ASC"
+		if s.Desc {
+			direction = "DESC"
+		}
+		parts[i] = s.Column + " " + direction
+	}
+	return "ORDER BY " + strings.Join(parts, ", ")
+}
+
+// LimitOffset returns the LIMIT and OFFSET clause
+func (p Params) LimitOffset() string {
+	return fmt.Sprintf("LIMIT %d OFFSET %d", p.Limit(), p.Offset())
+}
+
+// Where returns an AND-joined condition for the filters, with placeholders
+// numbered from firstArg, and the matching arguments. It returns "TRUE" when
+// there are no filters so it can always be used in a WHERE clause.
+func (p Params) Where(firstArg int) (string, []any) {
+	if len(p.Filters) == 0 {
+		return "TRUE", nil
+	}
+
+	columns := make([]string, 0, len(p.Filters))
+	for column := range p.Filters {
+		columns = append(columns, column)
+	}
+	// Sort columns so that the generated SQL is stable and can be prepared once
+	slices.Sort(columns)
+
+	conditions := make([]string, len(columns))
+	args := make([]any, len(columns))
+	for i, column := range columns {
+		conditions[i] = fmt.Sprintf("%s = $%d", column, firstArg+i)
+		args[i] = p.Filters[column]
+	}
+	return strings.Join(conditions, " AND "), args
+}
+
+// Meta describes the position of a page within the full result set
+type Meta struct {
+	Page       int `json:"page"`
+	PerPage    int `json:"per_page"`
+	Total      int `json:"total"`
+	TotalPages int `json:"total_pages"`
+}
+
+// Page is the response envelope of a paginated list
+type Page[T any] struct {
+	Data []T  `json:"data"`
+	Meta Meta `json:"meta"`
+}
+
+// NewPage creates a page of items out of total matching items
+func NewPage[T any](items []T, total int, p Params) Page[T] {
+	if items == nil {
+		items = []T{}
+	}
+
+	totalPages := 0
+	if p.PerPage > 0 {
+		totalPages = (total + p.PerPage - 1) / p.PerPage
+	}
+
+	return Page[T]{
+		Data: items,
+		Meta: Meta{
+			Page:       p.Page,
+			PerPage:    p.PerPage,
+			Total:      total,
+			TotalPages: totalPages,
+		},
+	}
+}
+
+// CursorParams holds the parsed parameters of a cursor-paginated list request
+type CursorParams struct {
+	Cursor string
+	Limit  int
+}
+
+// ParseCursor parses the cursor and per_page query parameters of a list
+// request that uses keyset pagination. The cursor is passed through
+// unchanged; decoding it is left to the repository that issued it.
+func ParseCursor(query url.Values, opts Options) (CursorParams, error) {
+	if opts.DefaultPerPage <= 0 {
+		opts.DefaultPerPage = DefaultPerPage
+	}
+	if opts.MaxPerPage <= 0 {
+		opts.MaxPerPage = MaxPerPage
+	}
+
+	params := CursorParams{
+		Cursor: query.Get("cursor"),
+		Limit:  opts.DefaultPerPage,
+	}
+
+	if v := query.Get("per_page"); v != "" {
+		perPage, err := strconv.Atoi(v)
+		if err != nil || perPage < 1 || perPage > opts.MaxPerPage {
+			return CursorParams{}, errs.NewValidation("Invalid list parameters").WithDetails(map[string]string{
+				"per_page": fmt.Sprintf("per_page must be between 1 and %d", opts.MaxPerPage),
+			})
+		}
+		params.Limit = perPage
+	}
+
+	return params, nil
+}
+
+// CursorPage is the response envelope of a cursor-paginated list.
+// NextCursor is omitted on the last page.
+type CursorPage[T any] struct {
+	Data       []T    `json:"data"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// NewCursorPage creates a page of items followed by the page at next
+func NewCursorPage[T any](items []T, next string) CursorPage[T] {
+	if items == nil {
+		items = []T{}
+	}
+	return CursorPage[T]{Data: items, NextCursor: next}
+}
+
+// Localize implements i18n.Localizable for the items of the page
+func (p CursorPage[T]) Localize(ctx context.Context) {
+	i18n.Localize(ctx, p.Data)
+}
@@ -0,0 +1,133 @@
+// Package retry runs operations again when they fail with a transient
+// error, waiting with exponential backoff and jitter between attempts.
+//
+// A Policy sets the number of attempts, the bounds of the backoff and which
+// errors are retried, errs.IsRetryable by default:
+//
+//	err := retry.Do(ctx, retry.Policy{MaxAttempts: 5}, func(ctx context.Context) error {
+//		return client.Ping(ctx)
+//	})
+//
+// Subsystems that retry later rather than in a loop, such as the job queue
+// scheduling the next run of a failed job, use Policy.Backoff for the delay.
+package retry
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"time"
+
+	"github.com/PrinceNarteh/go-boilerplate/internal/errs"
+)
+
+const (
+	defaultMaxAttempts = 3                      // Default number of attempts, including the first
+	defaultMinBackoff  = 100 * time.Millisecond // Default delay before the first retry
+	defaultMaxBackoff  = 10 * time.Second       // Default longest delay between attempts
+	defaultJitter      = 0.2                    // Default fraction of the delay added at random
+)
+
+// Policy configures the retries of an operation. Zero fields take their
+// default: 3 attempts, a backoff doubling from 100ms up to 10s, up to 20%
+// jitter, and retrying the errors for which errs.IsRetryable reports true.
+type Policy struct {
+	// MaxAttempts is the number of attempts, including the first
+	MaxAttempts int
+	// MinBackoff is the delay before the first retry, doubled for every
+	// further retry up to MaxBackoff
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+	// Jitter is the fraction of the delay added at random, so instances
+	// failing together do not retry together. A negative Jitter disables it.
+	Jitter float64
+	// RetryIf reports whether an error is worth retrying
+	RetryIf func(err error) bool
+	// OnRetry is called before waiting for the next attempt, e.g. to log
+	// the failure
+	OnRetry func(attempt int, err error, delay time.Duration)
+}
+
+// withDefaults returns the policy with its zero fields set to their default
+func (p Policy) withDefaults() Policy {
+	if p.MaxAttempts <= 0 {
+		p.MaxAttempts = defaultMaxAttempts
+	}
+	if p.MinBackoff <= 0 {
+		p.MinBackoff = defaultMinBackoff
+	}
+	if p.MaxBackoff < p.MinBackoff {
+		p.MaxBackoff = max(defaultMaxBackoff, p.MinBackoff)
+	}
+	if p.Jitter == 0 {
+		p.Jitter = defaultJitter
+	}
+	if p.RetryIf == nil {
+		p.RetryIf = errs.IsRetryable
+	}
+	return p
+}
+
+// Backoff returns the delay before the retry following attempt, counted
+// from 1, doubling from the minimum backoff up to the maximum, with jitter
+func (p Policy) Backoff(attempt int) time.Duration {
+	p = p.withDefaults()
+
+	delay := p.MinBackoff
+	for range attempt - 1 {
+		delay *= 2
+		if delay >= p.MaxBackoff {
+			delay = p.MaxBackoff
+			break
+		}
+	}
+	if p.Jitter > 0 {
+		delay += rand.N(time.Duration(float64(delay)*p.Jitter) + 1)
+	}
+	return delay
+}
+
+// Do calls fn until it succeeds, fails with an error the policy does not
+// retry, or runs out of attempts, and returns its last error. Waiting
+// between attempts stops when ctx is done.
+func Do(ctx context.Context, p Policy, fn func(ctx context.Context) error) error {
+	_, err := DoValue(ctx, p, func(ctx context.Context) (struct{}, error) {
+		return struct{}{}, fn(ctx)
+	})
+	return err
+}
+
+// DoValue is Do for operations returning a value, which is returned from
+// the successful attempt
+func DoValue[T any](ctx context.Context, p Policy, fn func(ctx context.Context) (T, error)) (T, error) {
+	p = p.withDefaults()
+
+	for attempt := 1; ; attempt++ {
+		value, err := fn(ctx)
+		if err == nil {
+			return value, nil
+		}
+		if !p.RetryIf(err) || ctx.Err() != nil {
+			return value, err
+		}
+		if attempt >= p.MaxAttempts {
+			if attempt > 1 {
+				err = fmt.Errorf("giving up after %d attempts: %w", attempt, err)
+			}
+			return value, err
+		}
+
+		delay := p.Backoff(attempt)
+		if p.OnRetry != nil {
+			p.OnRetry(attempt, err, delay)
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return value, err
+		case <-timer.C:
+		}
+	}
+}
@@ -0,0 +1,18 @@
+package libs
+
+import (
+	"context"
+
+	"github.com/PrinceNarteh/go-boilerplate/internal/libs/async"
+)
+
+// SafeGo runs fn in a new goroutine named name, recovering its panics.
+// It is async.Go for fire-and-forget work without an error to report: a
+// panic is logged with its stack and reported to New Relic, and the context
+// of fn is canceled on async.Shutdown, which waits for fn to return.
+func SafeGo(ctx context.Context, name string, fn func(ctx context.Context)) {
+	async.Go(ctx, name, func(ctx context.Context) error {
+		fn(ctx)
+		return nil
+	})
+}
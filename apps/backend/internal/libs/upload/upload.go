@@ -0,0 +1,300 @@
+// Package upload parses multipart/form-data requests with per-part limits.
+//
+// Unlike http.Request.ParseMultipartForm, Parse limits every part on its own,
+// checks the type of uploaded files by sniffing their content instead of
+// trusting the Content-Type sent by the client, and removes every temporary
+// file when parsing fails. Errors are *errs.AppError values: 413 for parts
+// that are too large, 415 for disallowed types and 400 for malformed bodies.
+package upload
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/PrinceNarteh/go-boilerplate/internal/errs"
+)
+
+const (
+	defaultMaxPartSize     = 10 << 20 // Default maximum size of an uploaded file (10 MiB)
+	defaultMaxFieldSize    = 64 << 10 // Default maximum size of a form value (64 KiB)
+	defaultMaxParts        = 100      // Default maximum number of parts
+	defaultMemoryThreshold = 1 << 20  // Default size above which files spill to disk (1 MiB)
+	sniffLen               = 512      // Bytes inspected by http.DetectContentType
+)
+
+// Options limits what Parse accepts. Zero values use the defaults.
+type Options struct {
+	// MaxPartSize is the maximum size of each uploaded file
+	MaxPartSize int64
+	// MaxFieldSize is the maximum size of each non-file form value
+	MaxFieldSize int64
+	// MaxParts is the maximum number of parts, files and values together
+	MaxParts int
+	// MemoryThreshold is the size above which a file is written to a
+	// temporary file instead of being kept in memory
+	MemoryThreshold int64
+	// AllowedTypes lists the accepted sniffed MIME types of files, such as
+	// "image/png" or "image/*". All types are accepted when empty.
+	AllowedTypes []string
+	// TempDir is where spilled files are written, os.TempDir() when empty
+	TempDir string
+}
+
+// File is an uploaded file held in memory or in a temporary file
+type File struct {
+	FieldName string
+	Filename  string
+	// ContentType is the type sniffed from the content, not the one sent by the client
+	ContentType string
+	Size        int64
+
+	data []byte
+	path string
+}
+
+// Open returns a reader over the content of the file
+func (f *File) Open() (io.ReadSeekCloser, error) {
+	if f.path == "" {
+		return nopCloser{bytes.NewReader(f.data)}, nil
+	}
+	return os.Open(f.path)
+}
+
+// Form is a parsed multipart form
+type Form struct {
+	Values url.Values
+	Files  map[string][]*File
+}
+
+// File returns the first file uploaded under name, if any
+func (f *Form) File(name string) (*File, bool) {
+	files := f.Files[name]
+	if len(files) == 0 {
+		return nil, false
+	}
+	return files[0], true
+}
+
+// Cleanup removes the temporary files of the form.
+// It should be deferred by handlers once Parse succeeds.
+func (f *Form) Cleanup() error {
+	var errList []error
+	for _, files := range f.Files {
+		for _, file := range files {
+			if file.path == "" {
+				continue
+			}
+			if err := os.Remove(file.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+				errList = append(errList, err)
+			}
+		}
+	}
+	return errors.Join(errList...)
+}
+
+// Parse reads a multipart/form-data request body.
+// On error, any temporary file already written is removed.
+func Parse(r *http.Request, opts Options) (*Form, error) {
+	opts = withDefaults(opts)
+
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/form-data" {
+		return nil, errs.NewUnsupportedMediaType("Request must be multipart/form-data")
+	}
+
+	reader, err := r.MultipartReader()
+	if err != nil {
+		return nil, errs.NewValidation("Malformed multipart body")
+	}
+
+	form := &Form{
+		Values: make(url.Values),
+		Files:  make(map[string][]*File),
+	}
+
+	if err := parseParts(reader, form, opts); err != nil {
+		_ = form.Cleanup()
+		return nil, err
+	}
+
+	return form, nil
+}
+
+// parseParts reads every part of the body into form
+func parseParts(reader *multipart.Reader, form *Form, opts Options) error {
+	for parts := 0; ; parts++ {
+		part, err := reader.NextPart()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return readError(err)
+		}
+
+		if parts >= opts.MaxParts {
+			part.Close()
+			return errs.NewValidation(fmt.Sprintf("Multipart body must not have more than %d parts", opts.MaxParts))
+		}
+
+		name := part.FormName()
+		if part.FileName() == "" {
+			err = readField(part, form, opts)
+		} else {
+			var file *File
+			if file, err = readFile(part, opts); err == nil {
+				form.Files[name] = append(form.Files[name], file)
+			}
+		}
+		part.Close()
+
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// readField reads a non-file form value
+func readField(part *multipart.Part, form *Form, opts Options) error {
+	value, err := io.ReadAll(io.LimitReader(part, opts.MaxFieldSize+1))
+	if err != nil {
+		return readError(err)
+	}
+	if int64(len(value)) > opts.MaxFieldSize {
+		return tooLarge(part.FormName(), opts.MaxFieldSize)
+	}
+
+	form.Values.Add(part.FormName(), string(value))
+	return nil
+}
+
+// readFile reads an uploaded file, sniffing its type from the first bytes
+// and spilling it to a temporary file once it exceeds the memory threshold
+func readFile(part *multipart.Part, opts Options) (*File, error) {
+	head := make([]byte, sniffLen)
+	n, err := io.ReadFull(part, head)
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return nil, readError(err)
+	}
+	head = head[:n]
+
+	file := &File{
+		FieldName:   part.FormName(),
+		Filename:    path.Base(part.FileName()),
+		ContentType: http.DetectContentType(head),
+	}
+	if !typeAllowed(file.ContentType, opts.AllowedTypes) {
+		return nil, errs.NewUnsupportedMediaType(fmt.Sprintf("File type %s is not allowed", file.ContentType)).
+			WithDetails(map[string]string{file.FieldName: "allowed types are " + strings.Join(opts.AllowedTypes, ", ")})
+	}
+
+	// Read one byte past the limit to detect oversized files
+	content := io.MultiReader(bytes.NewReader(head), io.LimitReader(part, opts.MaxPartSize+1-int64(n)))
+
+	var buf bytes.Buffer
+	size, err := io.CopyN(&buf, content, opts.MemoryThreshold+1)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, readError(err)
+	}
+	if size <= opts.MemoryThreshold {
+		file.data, file.Size = buf.Bytes(), size
+		return file, nil
+	}
+
+	tmp, err := os.CreateTemp(opts.TempDir, "upload-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	file.path = tmp.Name()
+
+	size, err = io.Copy(tmp, io.MultiReader(&buf, content))
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil && size > opts.MaxPartSize {
+		err = tooLarge(file.FieldName, opts.MaxPartSize)
+	}
+	if err != nil {
+		os.Remove(file.path)
+		return nil, readError(err)
+	}
+
+	file.Size = size
+	return file, nil
+}
+
+// typeAllowed reports whether a sniffed content type matches one of the allowed types
+func typeAllowed(contentType string, allowed []string) bool {
+	if len(allowed) == 0 {
+		return true
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	for _, a := range allowed {
+		if prefix, ok := strings.CutSuffix(a, "/*"); ok {
+			if strings.HasPrefix(mediaType, prefix+"/") {
+				return true
+			}
+		} else if mediaType == a {
+			return true
+		}
+	}
+	return false
+}
+
+// readError maps errors reading the body to application errors
+func readError(err error) error {
+	var appErr *errs.AppError
+	var maxBytesErr *http.MaxBytesError
+	switch {
+	case errors.As(err, &appErr):
+		return appErr
+	case errors.As(err, &maxBytesErr):
+		return errs.NewPayloadTooLarge(maxBytesErr.Limit)
+	default:
+		return errs.NewValidation("Malformed multipart body")
+	}
+}
+
+// tooLarge creates the error for a part exceeding its limit
+func tooLarge(field string, limit int64) error {
+	message := fmt.Sprintf("Part %q must not exceed %d bytes", field, limit)
+	return errs.New(errs.ErrCodePayloadTooLarge, message, http.StatusRequestEntityTooLarge).
+		WithDetails(map[string]string{field: fmt.Sprintf("must not exceed %d bytes", limit)})
+}
+
+// withDefaults fills in the zero values of opts
+func withDefaults(opts Options) Options {
+	if opts.MaxPartSize <= 0 {
+		opts.MaxPartSize = defaultMaxPartSize
+	}
+	if opts.MaxFieldSize <= 0 {
+		opts.MaxFieldSize = defaultMaxFieldSize
+	}
+	if opts.MaxParts <= 0 {
+		opts.MaxParts = defaultMaxParts
+	}
+	if opts.MemoryThreshold <= 0 {
+		opts.MemoryThreshold = defaultMemoryThreshold
+	}
+	opts.MemoryThreshold = min(opts.MemoryThreshold, opts.MaxPartSize)
+	return opts
+}
+
+// nopCloser adds a no-op Close to a bytes.Reader
+type nopCloser struct {
+	*bytes.Reader
+}
+
+// Close implements io.Closer
+func (nopCloser) Close() error {
+	return nil
+}
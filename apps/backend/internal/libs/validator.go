@@ -6,26 +6,62 @@ import (
 	"strings"
 
 	validator "github.com/go-playground/validator/v10"
+
+	"github.com/PrinceNarteh/go-boilerplate/internal/libs/enums"
+	"github.com/PrinceNarteh/go-boilerplate/internal/lists"
 )
 
-// ValidateStruct validates a struct and returns a map of field names to error messages.
-// The field names are in lowercase, and the error messages are user-friendly.
+// FieldError is a field of a struct failing a validation rule
+type FieldError struct {
+	// Field is the name of the field in lowercase
+	Field string
+	// Rule is the validation tag that failed, such as "required"
+	Rule string
+	// Message is a user-friendly error message
+	Message string
+}
+
+// Validate validates a struct and returns its invalid fields.
 // If the struct is valid, it returns nil.
-func ValidateStruct(data any) map[string]string {
+func Validate(data any) []FieldError {
 	validate := validator.New(validator.WithRequiredStructEnabled())
-	errors := make(map[string]string)
+	validate.RegisterValidation(enums.Tag, enums.Validate)
+	validate.RegisterValidation(lists.TagNotDisposable, lists.ValidateNotDisposable)
+	validate.RegisterValidation(lists.TagNotReserved, lists.ValidateNotReserved)
 
+	var fields []FieldError
 	err := validate.Struct(data)
 	if valErrs, ok := err.(validator.ValidationErrors); ok {
 		for _, v := range valErrs {
-			errors[strings.ToLower(v.Field())] = getErrorMessage(v)
+			fields = append(fields, FieldError{
+				Field:   strings.ToLower(v.Field()),
+				Rule:    v.Tag(),
+				Message: getErrorMessage(v),
+			})
 		}
 	}
+	return fields
+}
+
+// ValidateStruct validates a struct and returns a map of field names to error messages.
+// The field names are in lowercase, and the error messages are user-friendly.
+// If the struct is valid, it returns nil.
+func ValidateStruct(data any) map[string]string {
+	return FieldMessages(Validate(data))
+}
+
+// FieldMessages returns a map of the names of fields to their error
+// messages, or nil when there are no fields
+func FieldMessages(fields []FieldError) map[string]string {
+	if len(fields) == 0 {
+		return nil
+	}
 
-	if len(errors) > 0 {
-		return errors
+	messages := make(map[string]string, len(fields))
+	for _, field := range fields {
+		messages[field.Field] = field.Message
 	}
-	return nil
+	return messages
 }
 
 // getErrorMessage returns a user-friendly error message based on the validation error tag.
@@ -46,6 +82,12 @@ func getErrorMessage(err validator.FieldError) string {
 		return fmt.Sprintf("%s must be exactly %s characters", err.Field(), err.Param())
 	case "oneof":
 		return fmt.Sprintf("%s must be one of: %s", err.Field(), err.Param())
+	case enums.Tag:
+		return fmt.Sprintf("%s is not an allowed value", err.Field())
+	case lists.TagNotDisposable:
+		return fmt.Sprintf("%s must not use a disposable email provider", err.Field())
+	case lists.TagNotReserved:
+		return fmt.Sprintf("%s is reserved", err.Field())
 	case "url":
 		return fmt.Sprintf("%s is not a valid URL", err.Field())
 	case "uuid":
@@ -0,0 +1,144 @@
+// Package versioned versions the schema of job and event payloads, so that
+// during a rolling deployment, workers process the payloads encoded by older
+// binaries without crashing or silently misparsing them.
+//
+// A payload type declares its version with a PayloadVersion method, to be
+// incremented on every incompatible change, and the version is stored with
+// every payload. Consumers register an upgrade from every older version to
+// the next, applied to the encoded payload before decoding it:
+//
+//	func (RescanFilePayload) PayloadVersion() int { return 2 }
+//
+//	upgrades := versioned.Upgrades{
+//		// Version 2 renamed id to file_id
+//		1: versioned.RenameField("id", "file_id"),
+//	}
+//
+// Payloads of a version newer than the consumer knows fail with
+// ErrNewerVersion, for the message to be retried by an upgraded consumer.
+package versioned
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// Header is the message header holding the version of the payload
+const Header = "payload-version"
+
+// ErrNewerVersion is returned when decoding a payload encoded by a newer
+// version of the application than the running one
+var ErrNewerVersion = errors.New("versioned: payload version is newer than supported")
+
+// Versioned is implemented by payloads declaring the version of their
+// schema. Payloads that do not implement it are at version 1.
+type Versioned interface {
+	PayloadVersion() int
+}
+
+// Upgrade converts an encoded payload to the next version
+type Upgrade func(data []byte) ([]byte, error)
+
+// Upgrades maps every version to the upgrade to the next one
+type Upgrades map[int]Upgrade
+
+// Of returns the version of the payload v, or of the value v points to
+func Of(v any) int {
+	if versioned, ok := v.(Versioned); ok {
+		return max(versioned.PayloadVersion(), 1)
+	}
+	return 1
+}
+
+// Version returns the version of payloads of type T
+func Version[T any]() int {
+	var v T
+	if versioned, ok := any(v).(Versioned); ok {
+		return max(versioned.PayloadVersion(), 1)
+	}
+	return Of(&v)
+}
+
+// Apply upgrades data from version to target, a version of 0 standing for
+// a payload stored before it was versioned, at version 1
+func (u Upgrades) Apply(data []byte, version, target int) ([]byte, error) {
+	version = max(version, 1)
+	if version > target {
+		return nil, fmt.Errorf("%w: version %d, up to %d", ErrNewerVersion, version, target)
+	}
+
+	for ; version < target; version++ {
+		upgrade, ok := u[version]
+		if !ok {
+			return nil, fmt.Errorf("versioned: no upgrade from version %d", version)
+		}
+		upgraded, err := upgrade(data)
+		if err != nil {
+			return nil, fmt.Errorf("versioned: failed to upgrade from version %d: %w", version, err)
+		}
+		data = upgraded
+	}
+	return data, nil
+}
+
+// Decode upgrades the JSON payload data of version to the version of T,
+// then decodes it
+func Decode[T any](data []byte, version int, upgrades Upgrades) (T, error) {
+	var v T
+	data, err := upgrades.Apply(data, version, Version[T]())
+	if err != nil {
+		return v, err
+	}
+	return v, json.Unmarshal(data, &v)
+}
+
+// FormatVersion formats a version for the Header of a message
+func FormatVersion(version int) string {
+	return strconv.Itoa(version)
+}
+
+// ParseVersion parses the Header of a message, missing on the messages of
+// older versions of the application, which are at version 1
+func ParseVersion(value string) int {
+	version, err := strconv.Atoi(value)
+	if err != nil || version < 1 {
+		return 1
+	}
+	return version
+}
+
+// RenameField returns an upgrade renaming a field of JSON objects
+func RenameField(from, to string) Upgrade {
+	return func(data []byte) ([]byte, error) {
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal(data, &fields); err != nil {
+			return nil, err
+		}
+		if value, ok := fields[from]; ok {
+			fields[to] = value
+			delete(fields, from)
+		}
+		return json.Marshal(fields)
+	}
+}
+
+// SetDefault returns an upgrade setting a field added with a default value
+// on JSON objects that do not have it
+func SetDefault(field string, value any) Upgrade {
+	return func(data []byte) ([]byte, error) {
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal(data, &fields); err != nil {
+			return nil, err
+		}
+		if _, ok := fields[field]; !ok {
+			encoded, err := json.Marshal(value)
+			if err != nil {
+				return nil, err
+			}
+			fields[field] = encoded
+		}
+		return json.Marshal(fields)
+	}
+}
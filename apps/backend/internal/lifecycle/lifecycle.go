@@ -0,0 +1,181 @@
+// Package lifecycle stops the components of the application in order on
+// shutdown.
+//
+// Components register a shutdown hook with the Coordinator as they start,
+// in the phase they belong to: servers stop accepting requests first, then
+// the workers processing requests and messages are drained, then the
+// clients of databases and brokers they used are closed, and telemetry is
+// flushed last. Within a phase, hooks run in the reverse order of their
+// registration, as deferred calls do, so a component registered after the
+// one it depends on stops before it.
+//
+// Every hook runs with its own timeout, so a component that does not stop
+// in time is reported and skipped rather than blocking the ones after it.
+// Shutdown runs the hooks once, however many times it is called, so it can
+// both be deferred, to clean up when startup fails, and called on SIGINT or
+// SIGTERM.
+package lifecycle
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// DefaultTimeout is how long a hook may take to stop when Hook.Timeout is not set
+const DefaultTimeout = 10 * time.Second
+
+// Phase groups the hooks stopping at the same stage of the shutdown.
+// Phases stop in increasing order.
+type Phase int
+
+const (
+	// PhaseServers stops the servers accepting requests
+	PhaseServers Phase = iota
+	// PhaseWorkers drains the components processing requests, jobs and
+	// messages, such as job workers, schedulers, consumers and the WebSocket hub
+	PhaseWorkers
+	// PhaseClients closes the connections to databases, caches and brokers
+	PhaseClients
+	// PhaseTelemetry flushes metrics, events and errors
+	PhaseTelemetry
+)
+
+// String returns the name of the phase
+func (p Phase) String() string {
+	switch p {
+	case PhaseServers:
+		return "servers"
+	case PhaseWorkers:
+		return "workers"
+	case PhaseClients:
+		return "clients"
+	case PhaseTelemetry:
+		return "telemetry"
+	default:
+		return fmt.Sprintf("phase(%d)", int(p))
+	}
+}
+
+// StopFunc stops a component, returning once it stopped or ctx is done
+type StopFunc func(ctx context.Context) error
+
+// Func adapts a stop method without context nor error, such as
+// Scheduler.Stop. The hook is abandoned, not canceled, when it times out.
+func Func(stop func()) StopFunc {
+	return func(context.Context) error {
+		stop()
+		return nil
+	}
+}
+
+// Closer adapts a component closed with io.Closer, such as a Redis client
+func Closer(c io.Closer) StopFunc {
+	return func(context.Context) error {
+		return c.Close()
+	}
+}
+
+// Hook stops a component on shutdown
+type Hook struct {
+	// Name identifies the component in the logs, such as "redis"
+	Name  string
+	Phase Phase
+	// Timeout bounds how long the component may take to stop, DefaultTimeout when zero
+	Timeout time.Duration
+	Stop    StopFunc
+}
+
+// Coordinator runs the shutdown hooks of the application
+type Coordinator struct {
+	logger *zerolog.Logger
+
+	mu    sync.Mutex
+	hooks []Hook
+
+	once sync.Once
+	err  error
+}
+
+// New creates a new coordinator
+func New(logger *zerolog.Logger) *Coordinator {
+	return &Coordinator{logger: logger}
+}
+
+// Register adds a hook, run on Shutdown
+func (c *Coordinator) Register(h Hook) {
+	c.mu.Lock()
+	c.hooks = append(c.hooks, h)
+	c.mu.Unlock()
+}
+
+// OnStop registers stop as the hook of the component name in phase, with the
+// default timeout
+func (c *Coordinator) OnStop(phase Phase, name string, stop StopFunc) {
+	c.Register(Hook{Name: name, Phase: phase, Stop: stop})
+}
+
+// Shutdown runs the hooks, phase by phase, and returns their errors joined.
+// Failures are logged as they happen. Only the first call runs the hooks,
+// later calls return the result of the first.
+func (c *Coordinator) Shutdown() error {
+	c.once.Do(func() {
+		c.mu.Lock()
+		hooks := slices.Clone(c.hooks)
+		c.mu.Unlock()
+
+		// Stable sort of the reversed hooks keeps them last registered
+		// first within a phase
+		slices.Reverse(hooks)
+		slices.SortStableFunc(hooks, func(a, b Hook) int { return cmp.Compare(a.Phase, b.Phase) })
+
+		var errList []error
+		for _, h := range hooks {
+			if err := c.run(h); err != nil {
+				errList = append(errList, fmt.Errorf("failed to stop %s: %w", h.Name, err))
+			}
+		}
+		c.err = errors.Join(errList...)
+	})
+	return c.err
+}
+
+// run runs a hook with its timeout, recovering its panic
+func (c *Coordinator) run(h Hook) error {
+	timeout := cmp.Or(h.Timeout, DefaultTimeout)
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	log := c.logger.With().Str("component", h.Name).Str("phase", h.Phase.String()).Logger()
+	start := time.Now()
+
+	done := make(chan error, 1)
+	go func() {
+		defer func() {
+			if p := recover(); p != nil {
+				done <- fmt.Errorf("panic: %v", p)
+			}
+		}()
+		done <- h.Stop(ctx)
+	}()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = fmt.Errorf("did not stop within %s", timeout)
+	}
+	if err != nil {
+		log.Error().Err(err).Msg("Component failed to stop")
+		return err
+	}
+	log.Debug().Dur("duration", time.Since(start)).Msg("Component stopped")
+	return nil
+}
@@ -0,0 +1,237 @@
+// Package lists keeps allowlists and denylists maintained by third parties,
+// such as the domains of disposable email providers, in memory for fast
+// lookups by validators and middleware.
+//
+// Every list starts with the entries embedded in the binary, which a Loader
+// replaces with those of a file or URL, checked again periodically and on
+// SIGHUP. Lists are content-addressed: each version is identified by the
+// SHA-256 digest of its content, unchanged content is not parsed again, and
+// a source may be pinned to the digest it must have.
+package lists
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"fmt"
+	"net/netip"
+	"slices"
+	"strings"
+	"sync/atomic"
+)
+
+//go:embed data/*.txt
+var embedded embed.FS
+
+// Kind defines how the entries of a list are matched
+type Kind int
+
+const (
+	// KindNames matches whole values, case-insensitively
+	KindNames Kind = iota
+	// KindDomains matches domains and their subdomains, case-insensitively
+	KindDomains
+	// KindNetworks matches IP addresses within the listed addresses and
+	// CIDR ranges
+	KindNetworks
+)
+
+// The lists of the application, starting with their embedded entries
+var (
+	// DisposableDomains are the domains of disposable email providers
+	DisposableDomains = mustEmbedded("disposable_domains", KindDomains)
+	// BlockedIPs are the addresses and ranges whose requests are rejected
+	BlockedIPs = mustEmbedded("blocked_ips", KindNetworks)
+	// ReservedUsernames are the names users cannot take
+	ReservedUsernames = mustEmbedded("reserved_usernames", KindNames)
+)
+
+// List is a set of entries, replaced atomically so that lookups never wait
+// on a reload
+type List struct {
+	name    string
+	kind    Kind
+	current atomic.Pointer[snapshot]
+}
+
+// snapshot is the parsed content of a version of a list
+type snapshot struct {
+	digest  string
+	entries map[string]struct{}
+	// prefixes holds the networks by prefix length, so that an address is
+	// looked up once per length in use
+	prefixes map[int]map[netip.Prefix]struct{}
+	bits     []int
+}
+
+// New creates a list of kind from its content, one entry per line, where
+// blank lines and text after "#" are ignored
+func New(name string, kind Kind, data []byte) (*List, error) {
+	snap, err := parse(kind, data)
+	if err != nil {
+		return nil, fmt.Errorf("list %s: %w", name, err)
+	}
+
+	l := &List{name: name, kind: kind}
+	l.current.Store(snap)
+	return l, nil
+}
+
+// mustEmbedded creates a list from its embedded file
+func mustEmbedded(name string, kind Kind) *List {
+	data, err := embedded.ReadFile("data/" + name + ".txt")
+	if err != nil {
+		panic(err)
+	}
+	l, err := New(name, kind, data)
+	if err != nil {
+		panic(err)
+	}
+	return l
+}
+
+// Name returns the name of the list
+func (l *List) Name() string {
+	return l.name
+}
+
+// Digest returns the digest of the current content, as "sha256:<hex>"
+func (l *List) Digest() string {
+	return l.current.Load().digest
+}
+
+// Len returns the number of entries
+func (l *List) Len() int {
+	snap := l.current.Load()
+	n := len(snap.entries)
+	for _, prefixes := range snap.prefixes {
+		n += len(prefixes)
+	}
+	return n
+}
+
+// Replace parses data and makes it the content of the list. It reports
+// whether the content changed; invalid content is rejected and the current
+// content kept.
+func (l *List) Replace(data []byte) (bool, error) {
+	if digest(data) == l.Digest() {
+		return false, nil
+	}
+
+	snap, err := parse(l.kind, data)
+	if err != nil {
+		return false, fmt.Errorf("list %s: %w", l.name, err)
+	}
+	l.current.Store(snap)
+	return true, nil
+}
+
+// Contains reports whether value is listed: a name, a domain or an email
+// address for KindDomains, or an IP address for KindNetworks
+func (l *List) Contains(value string) bool {
+	switch l.kind {
+	case KindNetworks:
+		addr, err := netip.ParseAddr(value)
+		return err == nil && l.ContainsAddr(addr)
+	case KindDomains:
+		if _, domain, ok := strings.Cut(value, "@"); ok {
+			value = domain
+		}
+		snap := l.current.Load()
+		domain := strings.TrimSuffix(strings.ToLower(strings.TrimSpace(value)), ".")
+		for domain != "" {
+			if _, ok := snap.entries[domain]; ok {
+				return true
+			}
+			_, domain, _ = strings.Cut(domain, ".")
+		}
+		return false
+	default:
+		_, ok := l.current.Load().entries[strings.ToLower(strings.TrimSpace(value))]
+		return ok
+	}
+}
+
+// ContainsAddr reports whether addr is within a listed network
+func (l *List) ContainsAddr(addr netip.Addr) bool {
+	snap := l.current.Load()
+	addr = addr.Unmap()
+	for _, bits := range snap.bits {
+		prefix, err := addr.Prefix(bits)
+		if err != nil {
+			continue
+		}
+		if _, ok := snap.prefixes[bits][prefix]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// parse reads the entries of a list of kind
+func parse(kind Kind, data []byte) (*snapshot, error) {
+	snap := &snapshot{digest: digest(data), entries: make(map[string]struct{})}
+	if kind == KindNetworks {
+		snap.prefixes = make(map[int]map[netip.Prefix]struct{})
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for line := 1; scanner.Scan(); line++ {
+		entry, _, _ := strings.Cut(scanner.Text(), "#")
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		if entry == "" {
+			continue
+		}
+
+		if kind != KindNetworks {
+			snap.entries[strings.TrimSuffix(entry, ".")] = struct{}{}
+			continue
+		}
+
+		prefix, err := parsePrefix(entry)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		bits := prefix.Bits()
+		if snap.prefixes[bits] == nil {
+			snap.prefixes[bits] = make(map[netip.Prefix]struct{})
+			snap.bits = append(snap.bits, bits)
+		}
+		snap.prefixes[bits][prefix] = struct{}{}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	slices.Sort(snap.bits)
+	return snap, nil
+}
+
+// parsePrefix parses a CIDR range, or an address as the range of itself
+func parsePrefix(entry string) (netip.Prefix, error) {
+	if !strings.Contains(entry, "/") {
+		addr, err := netip.ParseAddr(entry)
+		if err != nil {
+			return netip.Prefix{}, err
+		}
+		addr = addr.Unmap()
+		return netip.PrefixFrom(addr, addr.BitLen()), nil
+	}
+
+	prefix, err := netip.ParsePrefix(entry)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	if prefix.Addr().Is4In6() && prefix.Bits() >= 96 {
+		prefix = netip.PrefixFrom(prefix.Addr().Unmap(), prefix.Bits()-96)
+	}
+	return prefix.Masked(), nil
+}
+
+// digest returns the content address of data
+func digest(data []byte) string {
+	sum := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
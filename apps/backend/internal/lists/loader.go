@@ -0,0 +1,201 @@
+package lists
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/PrinceNarteh/go-boilerplate/internal/libs/async"
+)
+
+const (
+	fetchTimeout = 30 * time.Second // Timeout for downloading a list
+	maxListBytes = 32 << 20         // Maximum size of a list (32 MiB)
+)
+
+// Source is where the content of a list comes from: a file or a URL,
+// revalidated with its ETag. It converts from config.ListSourceConfig.
+type Source struct {
+	File string
+	URL  string
+	// SHA256 pins the hex digest the content must have
+	SHA256 string
+}
+
+// source is a list and its source
+type source struct {
+	list *List
+	cfg  Source
+	// etag is the entity tag of the content last read from the URL
+	etag string
+}
+
+// Loader replaces the content of lists with that of their configured files
+// and URLs
+type Loader struct {
+	mu       sync.Mutex
+	sources  []*source
+	interval time.Duration
+	client   *http.Client
+	logger   *zerolog.Logger
+	stop     func()
+}
+
+// NewLoader creates a loader reloading its lists every interval, or only
+// when Load is called when interval is zero
+func NewLoader(interval time.Duration, logger *zerolog.Logger) *Loader {
+	return &Loader{
+		interval: interval,
+		client:   &http.Client{Timeout: fetchTimeout},
+		logger:   logger,
+	}
+}
+
+// Add loads list from cfg, unless it has neither a file nor a URL, in which
+// case the list keeps its content
+func (l *Loader) Add(list *List, cfg Source) {
+	if cfg.File == "" && cfg.URL == "" {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.sources = append(l.sources, &source{list: list, cfg: cfg})
+}
+
+// Load reads every source and replaces the lists whose content changed.
+// A list failing to load keeps its current content, and the errors of all
+// the lists are returned together.
+func (l *Loader) Load(ctx context.Context) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var errList []error
+	for _, src := range l.sources {
+		changed, err := l.load(ctx, src)
+		if err != nil {
+			errList = append(errList, err)
+			continue
+		}
+		if changed {
+			l.logger.Info().
+				Str("list", src.list.Name()).
+				Str("digest", src.list.Digest()).
+				Int("entries", src.list.Len()).
+				Msg("Loaded list")
+		}
+	}
+	return errors.Join(errList...)
+}
+
+// Start reloads the lists every refresh interval in the background until
+// Stop is called. It does nothing without an interval or sources.
+func (l *Loader) Start() {
+	if l.interval <= 0 || len(l.sources) == 0 || l.stop != nil {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := async.Go(ctx, "lists.loader", func(ctx context.Context) error {
+		ticker := time.NewTicker(l.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-ticker.C:
+			}
+
+			if err := l.Load(ctx); err != nil && ctx.Err() == nil {
+				l.logger.Error().Err(err).Msg("Failed to refresh lists, keeping the current ones")
+			}
+		}
+	})
+
+	l.stop = func() {
+		cancel()
+		<-done
+	}
+}
+
+// Stop stops reloading the lists
+func (l *Loader) Stop() {
+	if l.stop != nil {
+		l.stop()
+		l.stop = nil
+	}
+}
+
+// load reads the content of src and replaces its list when it changed
+func (l *Loader) load(ctx context.Context, src *source) (bool, error) {
+	var data []byte
+	var etag string
+	var err error
+	if src.cfg.File != "" {
+		data, err = os.ReadFile(src.cfg.File)
+	} else {
+		data, etag, err = l.fetch(ctx, src)
+	}
+	if err != nil {
+		return false, fmt.Errorf("list %s: %w", src.list.Name(), err)
+	}
+	if data == nil {
+		return false, nil
+	}
+
+	if src.cfg.SHA256 != "" && digest(data) != "sha256:"+strings.ToLower(src.cfg.SHA256) {
+		return false, fmt.Errorf("list %s: content has digest %s, expected sha256:%s",
+			src.list.Name(), digest(data), strings.ToLower(src.cfg.SHA256))
+	}
+	changed, err := src.list.Replace(data)
+	if err != nil {
+		return false, err
+	}
+
+	src.etag = etag
+	return changed, nil
+}
+
+// fetch downloads the content of the URL of src, returning nil when it is
+// unchanged since the ETag of the last download
+func (l *Loader) fetch(ctx context.Context, src *source) ([]byte, string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, src.cfg.URL, nil)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to create request: %w", err)
+	}
+	if src.etag != "" {
+		req.Header.Set("If-None-Match", src.etag)
+	}
+
+	resp, err := l.client.Do(req)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to download: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusNotModified:
+		return nil, "", nil
+	case http.StatusOK:
+	default:
+		return nil, "", fmt.Errorf("failed to download: unexpected status %d", resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxListBytes+1))
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to download: %w", err)
+	}
+	if len(data) > maxListBytes {
+		return nil, "", fmt.Errorf("list exceeds %d bytes", maxListBytes)
+	}
+	return data, resp.Header.Get("ETag"), nil
+}
@@ -0,0 +1,23 @@
+package lists
+
+import validator "github.com/go-playground/validator/v10"
+
+// Validation tags of the fields checked against the lists, as in
+// validate:"required,email,not_disposable"
+const (
+	// TagNotDisposable rejects email addresses and domains of disposable
+	// email providers
+	TagNotDisposable = "not_disposable"
+	// TagNotReserved rejects reserved usernames
+	TagNotReserved = "not_reserved"
+)
+
+// ValidateNotDisposable implements the "not_disposable" validation
+func ValidateNotDisposable(fl validator.FieldLevel) bool {
+	return !DisposableDomains.Contains(fl.Field().String())
+}
+
+// ValidateNotReserved implements the "not_reserved" validation
+func ValidateNotReserved(fl validator.FieldLevel) bool {
+	return !ReservedUsernames.Contains(fl.Field().String())
+}
@@ -0,0 +1,16 @@
+package logger
+
+import "github.com/rs/zerolog"
+
+// Level returns the level of the application's loggers
+func Level() zerolog.Level {
+	return zerolog.GlobalLevel()
+}
+
+// SetLevel atomically changes the level of the application's loggers, such
+// as to debug a production incident without restarting the server. The
+// level is global, so it applies at once to every logger, including the
+// request-scoped ones derived from the application logger.
+func SetLevel(level zerolog.Level) {
+	zerolog.SetGlobalLevel(level)
+}
@@ -8,20 +8,23 @@ import (
 	"io"
 	"log"
 	"os"
+	"slices"
 	"time"
 
 	// "github.com/newrelic/go-agent/v3/integrations/logcontext-v2/zerologWriter"
-	"github.com/newrelic/go-agent/v3/newrelic"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/pkgerrors"
 
 	"github.com/PrinceNarteh/go-boilerplate/internal/config"
+	"github.com/PrinceNarteh/go-boilerplate/internal/tracing"
 )
 
 // LoggerService provides logging capabilities using New newrelic
 // and zerolog for structured logging.
 type LoggerService struct {
-	nrApp *newrelic.Application
+	tracer tracing.Tracer
+	// closers are the log files to close on shutdown
+	closers []io.Closer
 }
 
 // NewLoggerService creates a new instance of LoggerService.
@@ -32,7 +35,8 @@ type LoggerService struct {
 // distributed tracing enabled, and a debug logger using zerolog.
 // The zerolog logger is configured to output to the console in a human-readable format.
 // This service can be used to log application events, errors, and performance metrics.
-// It is recommended to use this service for all logging needs in the application
+// It is recommended to use this service for all logging needs in the application.
+// Binaries built with the nonewrelic tag never start the agent, see tracing.NewNewRelic.
 func NewLoggerService(cfg *config.ObservabilityConfig) *LoggerService {
 	svc := &LoggerService{}
 
@@ -41,39 +45,38 @@ func NewLoggerService(cfg *config.ObservabilityConfig) *LoggerService {
 		return svc
 	}
 
-	var configOpts []newrelic.ConfigOption
-	configOpts = append(configOpts,
-		newrelic.ConfigAppName(cfg.ServiceName),
-		newrelic.ConfigLicense(cfg.NewRelic.LicenseKey),
-		newrelic.ConfigAppLogForwardingEnabled(cfg.NewRelic.AppLogForwardingEnabled),
-		newrelic.ConfigDistributedTracerEnabled(cfg.NewRelic.DistributedTracingEnabled),
-	)
-
-	if cfg.NewRelic.DebugLogging {
-		configOpts = append(configOpts, newrelic.ConfigDebugLogger(os.Stdout))
-	}
-
-	app, err := newrelic.NewApplication(configOpts...)
+	tracer, err := tracing.NewNewRelic(tracing.NewRelicOptions{
+		AppName:            cfg.ServiceName,
+		LicenseKey:         cfg.NewRelic.LicenseKey,
+		AppLogForwarding:   cfg.NewRelic.AppLogForwardingEnabled,
+		DistributedTracing: cfg.NewRelic.DistributedTracingEnabled,
+		DebugLogging:       cfg.NewRelic.DebugLogging,
+	})
 	if err != nil {
 		log.Printf("Failed to initialized New Relic:  %v\n", err)
+		return svc
 	}
 
-	svc.nrApp = app
+	svc.tracer = tracer
 	log.Printf("New Relic initialized for app: %s\n", cfg.ServiceName)
 
 	return svc
 }
 
-// Shutdown shuts down New Relic
+// Shutdown shuts down New Relic and closes the log files
 func (ls *LoggerService) Shutdown() {
-	if ls.nrApp != nil {
-		ls.nrApp.Shutdown(10 * time.Second)
+	if ls.tracer != nil {
+		ls.tracer.Shutdown(10 * time.Second)
+	}
+	for _, closer := range ls.closers {
+		closer.Close()
 	}
 }
 
-// GetApplication returns the New Relic application instance
-func (ls *LoggerService) GetApplication() *newrelic.Application {
-	return ls.nrApp
+// GetTracer returns the tracer of the New Relic application, nil when
+// New Relic is not running
+func (ls *LoggerService) GetTracer() tracing.Tracer {
+	return ls.tracer
 }
 
 // NewLoggerWithService creates a logger with full config and logger service
@@ -94,36 +97,28 @@ func NewLoggerWithService(cfg *config.ObservabilityConfig, loggerService *Logger
 		logLevel = zerolog.InfoLevel
 	}
 
-	// Don't set global level - let each logger have its own level
+	// The level is set globally, so SetLevel changes it at runtime for every
+	// logger derived from this one
+	SetLevel(logLevel)
 	zerolog.TimeFieldFormat = "2006-01-02 15:04:05"
 	zerolog.ErrorStackMarshaler = pkgerrors.MarshalStack
 
-	var writer io.Writer
+	writer := newOutputWriter(cfg, loggerService)
 
-	// Setup base writer
-	var baseWriter io.Writer
-	if cfg.IsProduction() && cfg.Logging.Format == "json" {
-		// In production, write to stdout
-		baseWriter = os.Stdout
+	// Note: New Relic log forwarding is now handled automatically by zerologWriter integration
 
-		// Wrap with New Relic zerologWriter for log forwarding in production
-		// TODO: Re-enable zerologWriter integration when dependency issue is resolved
-		// if loggerService != nil && loggerService.nrApp != nil {
-		// 	nrWriter := zerologWriter.New(baseWriter, loggerService.nrApp)
-		// 	writer = nrWriter
-		// } else {
-			writer = baseWriter
-		// }
-	} else {
-		// Development mode - use console writer
-		consoleWriter := zerolog.ConsoleWriter{Out: os.Stdout, TimeFormat: "2006-01-02 15:04:05"}
-		writer = consoleWriter
+	// Scrub sensitive fields before events reach stdout or New Relic. The
+	// patterns are validated with the configuration.
+	if cfg.Logging.Redact.Enabled {
+		redactWriter, err := newRedactWriter(writer, cfg.Logging.Redact)
+		if err != nil {
+			log.Printf("Failed to initialize log redaction: %v\n", err)
+		} else {
+			writer = redactWriter
+		}
 	}
 
-	// Note: New Relic log forwarding is now handled automatically by zerologWriter integration
-
 	logger := zerolog.New(writer).
-		Level(logLevel).
 		With().
 		Timestamp().
 		Str("service", cfg.ServiceName).
@@ -135,27 +130,121 @@ func NewLoggerWithService(cfg *config.ObservabilityConfig, loggerService *Logger
 		logger = logger.With().Stack().Logger()
 	}
 
+	// Sample the logs, shared by every logger derived from this one
+	if cfg.Logging.Sampling.Enabled {
+		logger = logger.Sample(newSampler(cfg.Logging.Sampling))
+	}
+
 	return logger
 }
 
+// newOutputWriter creates the writer multiplexing log lines to the
+// configured outputs. In development, lines are formatted for the console on
+// stdout and stderr, while files always get JSON lines. The log files are
+// closed by loggerService on shutdown, or at exit without it.
+func newOutputWriter(cfg *config.ObservabilityConfig, loggerService *LoggerService) io.Writer {
+	console := !cfg.IsProduction() || cfg.Logging.Format != "json"
+	stream := func(out io.Writer) io.Writer {
+		if console {
+			return zerolog.ConsoleWriter{Out: out, TimeFormat: "2006-01-02 15:04:05"}
+		}
+		// TODO: Wrap with New Relic zerologWriter for log forwarding in
+		// production when the dependency issue is resolved:
+		// zerologWriter.New(out, app), with the New Relic application of loggerService
+		return out
+	}
+
+	var writers []io.Writer
+	for _, output := range slices.Compact(slices.Sorted(slices.Values(cfg.Logging.Output))) {
+		switch output {
+		case config.LogOutputStdout:
+			writers = append(writers, stream(os.Stdout))
+		case config.LogOutputStderr:
+			writers = append(writers, stream(os.Stderr))
+		case config.LogOutputFile:
+			file, err := openRotatingFile(cfg.Logging.File)
+			if err != nil {
+				log.Printf("Failed to open log file: %v\n", err)
+				continue
+			}
+			if loggerService != nil {
+				loggerService.closers = append(loggerService.closers, file)
+			}
+			writers = append(writers, file)
+		}
+	}
+
+	switch len(writers) {
+	case 0:
+		return stream(os.Stdout)
+	case 1:
+		return writers[0]
+	default:
+		return zerolog.MultiLevelWriter(writers...)
+	}
+}
+
+// newSampler creates the sampler of every level but fatal and panic: one
+// in every N lines of the level, then at most a burst of them per period
+func newSampler(cfg config.LoggingSamplingConfig) zerolog.Sampler {
+	level := func(every uint32) zerolog.Sampler {
+		var chain samplerChain
+		if every > 1 {
+			chain = append(chain, &zerolog.BasicSampler{N: every})
+		}
+		if cfg.Burst > 0 && cfg.Period > 0 {
+			// Lines beyond the burst go to NextSampler, dropped when it is nil
+			burst := &zerolog.BurstSampler{Burst: cfg.Burst, Period: cfg.Period}
+			if cfg.BurstEvery > 0 {
+				burst.NextSampler = &zerolog.BasicSampler{N: cfg.BurstEvery}
+			}
+			chain = append(chain, burst)
+		}
+		return chain
+	}
+
+	return zerolog.LevelSampler{
+		TraceSampler: level(cfg.DebugEvery),
+		DebugSampler: level(cfg.DebugEvery),
+		InfoSampler:  level(cfg.InfoEvery),
+		WarnSampler:  level(0),
+		ErrorSampler: level(0),
+	}
+}
+
+// samplerChain samples the lines sampled by each of its samplers in turn,
+// so a line dropped by one does not count against the next
+type samplerChain []zerolog.Sampler
+
+// Sample implements zerolog.Sampler
+func (c samplerChain) Sample(lvl zerolog.Level) bool {
+	for _, sampler := range c {
+		if !sampler.Sample(lvl) {
+			return false
+		}
+	}
+	return true
+}
+
 // WithTraceContext adds New Relic transaction context to logger
-func WithTraceContext(logger zerolog.Logger, txn *newrelic.Transaction) zerolog.Logger {
+func WithTraceContext(logger zerolog.Logger, txn tracing.Transaction) zerolog.Logger {
 	if txn == nil {
 		return logger
 	}
 
 	// Get trace metadata from transaction
-	metadata := txn.GetTraceMetadata()
+	traceID, spanID := txn.TraceIDs()
 
 	return logger.With().
-		Str("trace.id", metadata.TraceID).
-		Str("span.id", metadata.SpanID).
+		Str("trace.id", traceID).
+		Str("span.id", spanID).
 		Logger()
 }
 
-// NewPgxLogger creates a database logger
-func NewPgxLogger(level zerolog.Level) zerolog.Logger {
-	writer := zerolog.ConsoleWriter{
+// NewPgxLogger creates a database logger. Query arguments are scrubbed like
+// the application logs when redaction is enabled.
+func NewPgxLogger(level zerolog.Level, redact config.LoggingRedactConfig) zerolog.Logger {
+	var writer io.Writer = zerolog.ConsoleWriter{
 		Out:        os.Stdout,
 		TimeFormat: "2006-01-02 15:04:05",
 		FormatFieldValue: func(i any) string {
@@ -179,6 +268,14 @@ func NewPgxLogger(level zerolog.Level) zerolog.Logger {
 			}
 		},
 	}
+	if redact.Enabled {
+		redactWriter, err := newRedactWriter(writer, redact)
+		if err != nil {
+			log.Printf("Failed to initialize database log redaction: %v\n", err)
+		} else {
+			writer = redactWriter
+		}
+	}
 
 	return zerolog.New(writer).
 		Level(level).
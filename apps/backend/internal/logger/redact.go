@@ -0,0 +1,123 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/PrinceNarteh/go-boilerplate/internal/config"
+)
+
+// redacted replaces sensitive values in log events
+const redacted = "[REDACTED]"
+
+// redactWriter scrubs sensitive fields and values from the JSON log events
+// written through it, before they reach the underlying writer. zerolog
+// writes every event in a single call, so each write is decoded as a whole;
+// events with nothing to scrub are written unchanged.
+type redactWriter struct {
+	out      io.Writer
+	fields   []string
+	patterns []*regexp.Regexp
+}
+
+// newRedactWriter wraps out with the default and configured redaction
+func newRedactWriter(out io.Writer, cfg config.LoggingRedactConfig) (io.Writer, error) {
+	patterns, err := cfg.CompilePatterns()
+	if err != nil {
+		return nil, err
+	}
+
+	fields := slices.Concat(config.DefaultRedactFields, cfg.Fields)
+	for i, field := range fields {
+		fields[i] = strings.ToLower(field)
+	}
+
+	return &redactWriter{out: out, fields: fields, patterns: patterns}, nil
+}
+
+// Write implements io.Writer
+func (w *redactWriter) Write(p []byte) (int, error) {
+	decoder := json.NewDecoder(bytes.NewReader(p))
+	decoder.UseNumber()
+	var event map[string]any
+	if err := decoder.Decode(&event); err != nil {
+		// Not an event, such as the output of a standard library logger
+		return w.out.Write(p)
+	}
+
+	if !w.redactMap(event) {
+		return w.out.Write(p)
+	}
+
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(event); err != nil {
+		return 0, err
+	}
+	if _, err := w.out.Write(buf.Bytes()); err != nil {
+		return 0, err
+	}
+	// Report the original length, as callers check it against what they wrote
+	return len(p), nil
+}
+
+// redactMap redacts the sensitive fields of m in place and reports whether
+// anything was redacted
+func (w *redactWriter) redactMap(m map[string]any) bool {
+	changed := false
+	for key, value := range m {
+		if w.sensitive(key) {
+			if value != nil && value != redacted {
+				m[key] = redacted
+				changed = true
+			}
+			continue
+		}
+		if value, ok := w.redactValue(value); ok {
+			m[key] = value
+			changed = true
+		}
+	}
+	return changed
+}
+
+// redactValue returns v with its sensitive fields and values redacted, and
+// whether anything was redacted
+func (w *redactWriter) redactValue(v any) (any, bool) {
+	switch v := v.(type) {
+	case string:
+		redactedValue := v
+		for _, pattern := range w.patterns {
+			redactedValue = pattern.ReplaceAllLiteralString(redactedValue, redacted)
+		}
+		return redactedValue, redactedValue != v
+	case map[string]any:
+		return v, w.redactMap(v)
+	case []any:
+		changed := false
+		for i, item := range v {
+			if item, ok := w.redactValue(item); ok {
+				v[i] = item
+				changed = true
+			}
+		}
+		return v, changed
+	}
+	return v, false
+}
+
+// sensitive reports whether a field name contains a sensitive field name
+func (w *redactWriter) sensitive(key string) bool {
+	key = strings.ToLower(key)
+	for _, field := range w.fields {
+		if strings.Contains(key, field) {
+			return true
+		}
+	}
+	return false
+}
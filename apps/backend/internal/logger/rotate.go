@@ -0,0 +1,228 @@
+package logger
+
+import (
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/PrinceNarteh/go-boilerplate/internal/config"
+	"github.com/PrinceNarteh/go-boilerplate/internal/libs"
+)
+
+// backupTimeFormat is the format of the time a log file was rotated, in the
+// name of the rotated file, such as api-2026-01-02T15-04-05.000.log
+const backupTimeFormat = "2006-01-02T15-04-05.000"
+
+// rotatingFile writes to a log file, rotated once it grows beyond the
+// configured size. Rotated files are compressed and removed in the
+// background, so writes never wait for them.
+type rotatingFile struct {
+	cfg config.LoggingFileConfig
+
+	mu   sync.Mutex
+	file *os.File
+	size int64
+
+	// cleaning serializes the compression and removal of rotated files
+	cleaning sync.Mutex
+}
+
+// openRotatingFile opens the log file of cfg, creating it and its directory
+// when they do not exist, and cleans up the rotated files left over
+func openRotatingFile(cfg config.LoggingFileConfig) (*rotatingFile, error) {
+	f := &rotatingFile{cfg: cfg}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	libs.SafeGo(context.Background(), "logger.cleanup", func(context.Context) { f.cleanup() })
+	return f, nil
+}
+
+// Write implements io.Writer, rotating the file first when p would grow it
+// beyond the maximum size
+func (f *rotatingFile) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.file == nil {
+		return 0, os.ErrClosed
+	}
+	maxSize := int64(f.cfg.MaxSize) * 1024 * 1024
+	if maxSize > 0 && f.size > 0 && f.size+int64(len(p)) > maxSize {
+		if err := f.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+// Close closes the log file
+func (f *rotatingFile) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.file == nil {
+		return nil
+	}
+	err := f.file.Close()
+	f.file = nil
+	return err
+}
+
+// open opens the log file for appending
+func (f *rotatingFile) open() error {
+	if err := os.MkdirAll(filepath.Dir(f.cfg.Path), 0o755); err != nil {
+		return fmt.Errorf("failed to create log directory: %w", err)
+	}
+	file, err := os.OpenFile(f.cfg.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open log file: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("failed to stat log file: %w", err)
+	}
+	f.file, f.size = file, info.Size()
+	return nil
+}
+
+// rotate renames the log file after the current time and opens a new one
+func (f *rotatingFile) rotate() error {
+	if err := f.file.Close(); err != nil {
+		return fmt.Errorf("failed to close log file: %w", err)
+	}
+	f.file = nil
+
+	dir, prefix, ext := f.nameParts()
+	backup := filepath.Join(dir, prefix+time.Now().UTC().Format(backupTimeFormat)+ext)
+	if err := os.Rename(f.cfg.Path, backup); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to rotate log file: %w", err)
+	}
+	if err := f.open(); err != nil {
+		return err
+	}
+
+	libs.SafeGo(context.Background(), "logger.cleanup", func(context.Context) { f.cleanup() })
+	return nil
+}
+
+// nameParts returns the directory of the log file, and the prefix and
+// extension of its rotated files
+func (f *rotatingFile) nameParts() (dir, prefix, ext string) {
+	dir, name := filepath.Split(f.cfg.Path)
+	ext = filepath.Ext(name)
+	return filepath.Clean(dir), strings.TrimSuffix(name, ext) + "-", ext
+}
+
+// backup is a rotated log file
+type backup struct {
+	path       string
+	rotatedAt  time.Time
+	compressed bool
+}
+
+// backups lists the rotated log files, the most recent first
+func (f *rotatingFile) backups() ([]backup, error) {
+	dir, prefix, ext := f.nameParts()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var backups []backup
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		stamp, compressed := strings.TrimSuffix(strings.TrimPrefix(name, prefix), ".gz"), strings.HasSuffix(name, ".gz")
+		stamp, ok := strings.CutSuffix(stamp, ext)
+		if !ok {
+			continue
+		}
+		rotatedAt, err := time.Parse(backupTimeFormat, stamp)
+		if err != nil {
+			continue
+		}
+		backups = append(backups, backup{path: filepath.Join(dir, name), rotatedAt: rotatedAt, compressed: compressed})
+	}
+
+	slices.SortFunc(backups, func(a, b backup) int { return b.rotatedAt.Compare(a.rotatedAt) })
+	return backups, nil
+}
+
+// cleanup removes the rotated files beyond the maximum count or age, and
+// compresses the others. Failures are reported on stderr, as the logs may
+// be what fails.
+func (f *rotatingFile) cleanup() {
+	f.cleaning.Lock()
+	defer f.cleaning.Unlock()
+
+	backups, err := f.backups()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "logger: failed to list rotated log files: %v\n", err)
+		return
+	}
+
+	for i, b := range backups {
+		expired := f.cfg.MaxAge > 0 && time.Since(b.rotatedAt) > f.cfg.MaxAge
+		if expired || (f.cfg.MaxBackups > 0 && i >= f.cfg.MaxBackups) {
+			if err := os.Remove(b.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+				fmt.Fprintf(os.Stderr, "logger: failed to remove rotated log file: %v\n", err)
+			}
+			continue
+		}
+		if f.cfg.Compress && !b.compressed {
+			if err := compressFile(b.path); err != nil {
+				fmt.Fprintf(os.Stderr, "logger: failed to compress rotated log file: %v\n", err)
+			}
+		}
+	}
+}
+
+// compressFile gzips the file at path to path.gz, then removes it
+func compressFile(path string) (err error) {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.OpenFile(path+".gz", os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			os.Remove(dst.Name())
+		}
+	}()
+
+	gz := gzip.NewWriter(dst)
+	if _, err := io.Copy(gz, src); err != nil {
+		dst.Close()
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		dst.Close()
+		return err
+	}
+	if err := dst.Close(); err != nil {
+		return err
+	}
+
+	src.Close()
+	return os.Remove(path)
+}
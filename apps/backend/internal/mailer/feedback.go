@@ -0,0 +1,90 @@
+package mailer
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+)
+
+// FeedbackParser parses the body of a webhook of an email provider into
+// the suppressions it reports. Soft bounces, deliveries and other events
+// are left out, as they do not call for suppressing the address.
+type FeedbackParser func(body []byte) ([]Suppression, error)
+
+// feedbackParsers are the parsers of the supported providers, by name
+var feedbackParsers = map[string]FeedbackParser{
+	"postmark": ParsePostmarkFeedback,
+	"sendgrid": ParseSendGridFeedback,
+}
+
+// LookupFeedbackParser returns the parser of the webhooks of provider
+func LookupFeedbackParser(provider string) (FeedbackParser, bool) {
+	p, ok := feedbackParsers[provider]
+	return p, ok
+}
+
+// sendGridEvent is an event of the SendGrid event webhook
+type sendGridEvent struct {
+	Email  string `json:"email"`
+	Event  string `json:"event"`
+	Type   string `json:"type"`
+	Reason string `json:"reason"`
+}
+
+// ParseSendGridFeedback parses a batch of the SendGrid event webhook.
+// Bounces are hard unless their type is "blocked".
+func ParseSendGridFeedback(body []byte) ([]Suppression, error) {
+	var events []sendGridEvent
+	if err := json.Unmarshal(body, &events); err != nil {
+		return nil, fmt.Errorf("invalid SendGrid events: %w", err)
+	}
+
+	var suppressions []Suppression
+	for _, e := range events {
+		switch {
+		case e.Email == "":
+			continue
+		case e.Event == "bounce" && e.Type != "blocked":
+			suppressions = append(suppressions, Suppression{Email: e.Email, Reason: ReasonBounce, Provider: "sendgrid", Details: e.Reason})
+		case e.Event == "spamreport":
+			suppressions = append(suppressions, Suppression{Email: e.Email, Reason: ReasonComplaint, Provider: "sendgrid"})
+		}
+	}
+	return suppressions, nil
+}
+
+// postmarkEvent is an event of the Postmark bounce and spam complaint webhooks
+type postmarkEvent struct {
+	RecordType  string `json:"RecordType"`
+	Type        string `json:"Type"`
+	Email       string `json:"Email"`
+	Description string `json:"Description"`
+}
+
+// postmarkHardBounces are the Postmark bounce types suppressing the address
+var postmarkHardBounces = map[string]bool{
+	"HardBounce":      true,
+	"BadEmailAddress": true,
+}
+
+// ParsePostmarkFeedback parses an event of the Postmark bounce or spam
+// complaint webhook
+func ParsePostmarkFeedback(body []byte) ([]Suppression, error) {
+	var e postmarkEvent
+	if err := json.Unmarshal(body, &e); err != nil {
+		return nil, fmt.Errorf("invalid Postmark event: %w", err)
+	}
+	if e.Email == "" {
+		return nil, nil
+	}
+
+	switch {
+	case e.RecordType == "SpamComplaint" || e.Type == "SpamComplaint":
+		return []Suppression{{Email: e.Email, Reason: ReasonComplaint, Provider: "postmark"}}, nil
+	case e.RecordType == "Bounce" && postmarkHardBounces[e.Type]:
+		details := cmp.Or(e.Description, e.Type)
+		return []Suppression{{Email: e.Email, Reason: ReasonBounce, Provider: "postmark", Details: details}}, nil
+	default:
+		return nil, nil
+	}
+}
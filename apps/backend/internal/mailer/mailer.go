@@ -0,0 +1,42 @@
+// Package mailer sends transactional emails
+package mailer
+
+import (
+	"context"
+
+	"github.com/rs/zerolog"
+)
+
+// Message is an email to be sent
+type Message struct {
+	To      string
+	Subject string
+	Text    string
+	HTML    string
+}
+
+// Mailer sends email messages
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// LogMailer logs messages instead of sending them.
+// It is intended for local development and tests.
+type LogMailer struct {
+	logger *zerolog.Logger
+}
+
+// NewLogMailer creates a new mailer that writes messages to the logger
+func NewLogMailer(logger *zerolog.Logger) *LogMailer {
+	return &LogMailer{logger: logger}
+}
+
+// Send implements Mailer
+func (m *LogMailer) Send(_ context.Context, msg Message) error {
+	m.logger.Info().
+		Str("to", msg.To).
+		Str("subject", msg.Subject).
+		Str("text", msg.Text).
+		Msg("email sent")
+	return nil
+}
@@ -0,0 +1,80 @@
+package mailer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ErrSuppressed is returned when sending to a suppressed address
+var ErrSuppressed = errors.New("address is suppressed")
+
+// SuppressionReason is why an address is suppressed
+type SuppressionReason string
+
+// Suppression reasons
+const (
+	// ReasonBounce is a hard bounce: the address does not exist or rejects mail
+	ReasonBounce SuppressionReason = "bounce"
+	// ReasonComplaint is a recipient marking an email as spam
+	ReasonComplaint SuppressionReason = "complaint"
+)
+
+// Suppression is an address no email is sent to anymore
+type Suppression struct {
+	ID     int64             `json:"id"`
+	Email  string            `json:"email"`
+	Reason SuppressionReason `json:"reason"`
+	// Provider is the email provider that reported the address
+	Provider  string    `json:"provider"`
+	Details   string    `json:"details,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// SuppressionList stores the suppressed addresses. Addresses are compared
+// once normalized with NormalizeAddress.
+type SuppressionList interface {
+	IsSuppressed(ctx context.Context, email string) (bool, error)
+	// Suppress adds an address, or updates the reason of a suppressed one
+	Suppress(ctx context.Context, s *Suppression) error
+	// List returns a page of suppressions, newest first, and the cursor of
+	// the next page, which is empty on the last page
+	List(ctx context.Context, cursor string, limit int) ([]*Suppression, string, error)
+	// Remove clears the suppression of an address. It returns
+	// errs.ErrNotFound when the address is not suppressed.
+	Remove(ctx context.Context, email string) error
+}
+
+// NormalizeAddress trims and lowercases an email address
+func NormalizeAddress(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// suppressingMailer is a Mailer skipping suppressed addresses
+type suppressingMailer struct {
+	next Mailer
+	list SuppressionList
+}
+
+// Suppressing returns a mailer sending with next unless the recipient is
+// in list, in which case Send returns ErrSuppressed. A failure to consult
+// the list is returned too, rather than risking the reputation of the
+// sender.
+func Suppressing(next Mailer, list SuppressionList) Mailer {
+	return &suppressingMailer{next: next, list: list}
+}
+
+// Send implements Mailer
+func (m *suppressingMailer) Send(ctx context.Context, msg Message) error {
+	suppressed, err := m.list.IsSuppressed(ctx, NormalizeAddress(msg.To))
+	if err != nil {
+		return fmt.Errorf("failed to check email suppressions: %w", err)
+	}
+	if suppressed {
+		return fmt.Errorf("failed to send email to %s: %w", msg.To, ErrSuppressed)
+	}
+	return m.next.Send(ctx, msg)
+}
@@ -0,0 +1,132 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/PrinceNarteh/go-boilerplate/internal/anonymize"
+	"github.com/PrinceNarteh/go-boilerplate/internal/database"
+	"github.com/PrinceNarteh/go-boilerplate/internal/libs"
+)
+
+// SuppressionAnonymization masks the suppressed addresses in staging
+// snapshots, the same way as the addresses of users
+var SuppressionAnonymization = anonymize.Register("email_suppressions", anonymize.Columns{
+	"email":   anonymize.Email,
+	"details": anonymize.Fixed(""),
+})
+
+// suppressionCursor is the keyset position of a suppression in the list ordering
+type suppressionCursor struct {
+	ID int64 `json:"i"`
+}
+
+// PostgresSuppressionList stores the suppressed addresses in the
+// email_suppressions table
+type PostgresSuppressionList struct {
+	db *pgxpool.Pool
+}
+
+// NewPostgresSuppressionList creates a new PostgreSQL suppression list
+func NewPostgresSuppressionList(db *pgxpool.Pool) *PostgresSuppressionList {
+	return &PostgresSuppressionList{db: db}
+}
+
+// IsSuppressed implements SuppressionList
+func (l *PostgresSuppressionList) IsSuppressed(ctx context.Context, email string) (bool, error) {
+	query := `SELECT EXISTS (SELECT 1 FROM email_suppressions WHERE email = $1)`
+
+	var suppressed bool
+	if err := database.Conn(ctx, l.db).QueryRow(ctx, query, email).Scan(&suppressed); err != nil {
+		return false, fmt.Errorf("failed to check email suppression: %w", database.TranslateError(err))
+	}
+
+	return suppressed, nil
+}
+
+// Suppress implements SuppressionList
+func (l *PostgresSuppressionList) Suppress(ctx context.Context, s *Suppression) error {
+	query := `
+		INSERT INTO email_suppressions (email, reason, provider, details)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (email) DO UPDATE
+		SET reason = EXCLUDED.reason, provider = EXCLUDED.provider, details = EXCLUDED.details, updated_at = NOW()
+		RETURNING id, created_at, updated_at`
+
+	err := database.Conn(ctx, l.db).QueryRow(ctx, query,
+		NormalizeAddress(s.Email), s.Reason, s.Provider, s.Details,
+	).Scan(&s.ID, &s.CreatedAt, &s.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to suppress email: %w", database.TranslateError(err))
+	}
+
+	return nil
+}
+
+// List implements SuppressionList
+func (l *PostgresSuppressionList) List(ctx context.Context, cursor string, limit int) ([]*Suppression, string, error) {
+	var after suppressionCursor
+	if cursor != "" {
+		if err := libs.DecodeCursor(cursor, &after); err != nil {
+			return nil, "", err
+		}
+	}
+
+	query := `
+		SELECT id, email, reason, provider, details, created_at, updated_at
+		FROM email_suppressions
+		WHERE $1::boolean OR id < $2
+		ORDER BY id DESC
+		LIMIT $3`
+
+	// Fetch one extra row to know whether another page exists
+	rows, err := database.Conn(ctx, l.db).Query(ctx, query, cursor == "", after.ID, limit+1)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to list email suppressions: %w", database.TranslateError(err))
+	}
+	defer rows.Close()
+
+	var suppressions []*Suppression
+	for rows.Next() {
+		var s Suppression
+		err := rows.Scan(&s.ID, &s.Email, &s.Reason, &s.Provider, &s.Details, &s.CreatedAt, &s.UpdatedAt)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to scan email suppression: %w", err)
+		}
+		suppressions = append(suppressions, &s)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, "", fmt.Errorf("rows error: %w", err)
+	}
+
+	if len(suppressions) <= limit {
+		return suppressions, "", nil
+	}
+
+	suppressions = suppressions[:limit]
+	next, err := libs.EncodeCursor(suppressionCursor{ID: suppressions[len(suppressions)-1].ID})
+	if err != nil {
+		return nil, "", err
+	}
+
+	return suppressions, next, nil
+}
+
+// Remove implements SuppressionList
+func (l *PostgresSuppressionList) Remove(ctx context.Context, email string) error {
+	query := `DELETE FROM email_suppressions WHERE email = $1`
+
+	tag, err := database.Conn(ctx, l.db).Exec(ctx, query, NormalizeAddress(email))
+	if err != nil {
+		return fmt.Errorf("failed to remove email suppression: %w", database.TranslateError(err))
+	}
+	if tag.RowsAffected() == 0 {
+		return database.TranslateError(pgx.ErrNoRows)
+	}
+
+	return nil
+}
@@ -0,0 +1,111 @@
+package mailer
+
+import (
+	"bytes"
+	"fmt"
+	htmltemplate "html/template"
+	"sort"
+	"sync"
+	"text/template"
+)
+
+// Template is an email template. Subject and Text are text/template
+// sources and HTML, which is optional, an html/template source; all are
+// executed with the same data. Sample is the data of previews and test
+// sends, and should exercise every field the template uses.
+type Template struct {
+	Name        string
+	Description string
+	Subject     string
+	Text        string
+	HTML        string
+	Sample      any
+
+	subject *template.Template
+	text    *template.Template
+	html    *htmltemplate.Template
+}
+
+var (
+	templatesMu sync.RWMutex
+	templates   = map[string]*Template{}
+)
+
+// Register parses and registers an email template and returns it.
+// It is meant to be called from package-level variable declarations, and
+// panics when the name is empty or already registered, or a source does
+// not parse.
+func Register(t Template) *Template {
+	if t.Name == "" {
+		panic("mailer: template name must not be empty")
+	}
+
+	t.subject = template.Must(template.New(t.Name + ".subject").Parse(t.Subject))
+	t.text = template.Must(template.New(t.Name + ".text").Parse(t.Text))
+	if t.HTML != "" {
+		t.html = htmltemplate.Must(htmltemplate.New(t.Name + ".html").Parse(t.HTML))
+	}
+
+	templatesMu.Lock()
+	defer templatesMu.Unlock()
+
+	if _, dup := templates[t.Name]; dup {
+		panic("mailer: template " + t.Name + " registered twice")
+	}
+	templates[t.Name] = &t
+	return &t
+}
+
+// Templates returns the registered templates sorted by name
+func Templates() []*Template {
+	templatesMu.RLock()
+	defer templatesMu.RUnlock()
+
+	list := make([]*Template, 0, len(templates))
+	for _, t := range templates {
+		list = append(list, t)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// Lookup returns the registered template with the given name
+func Lookup(name string) (*Template, bool) {
+	templatesMu.RLock()
+	defer templatesMu.RUnlock()
+
+	t, ok := templates[name]
+	return t, ok
+}
+
+// Render executes the template with data into a message to the given address
+func (t *Template) Render(to string, data any) (Message, error) {
+	msg := Message{To: to}
+
+	var buf bytes.Buffer
+	if err := t.subject.Execute(&buf, data); err != nil {
+		return Message{}, fmt.Errorf("failed to render subject of email template %s: %w", t.Name, err)
+	}
+	msg.Subject = buf.String()
+
+	buf.Reset()
+	if err := t.text.Execute(&buf, data); err != nil {
+		return Message{}, fmt.Errorf("failed to render text of email template %s: %w", t.Name, err)
+	}
+	msg.Text = buf.String()
+
+	if t.html != nil {
+		buf.Reset()
+		if err := t.html.Execute(&buf, data); err != nil {
+			return Message{}, fmt.Errorf("failed to render HTML of email template %s: %w", t.Name, err)
+		}
+		msg.HTML = buf.String()
+	}
+
+	return msg, nil
+}
+
+// RenderSample renders the template with its sample data
+func (t *Template) RenderSample(to string) (Message, error) {
+	return t.Render(to, t.Sample)
+}
@@ -0,0 +1,329 @@
+// Package matview refreshes PostgreSQL materialized views on a schedule.
+//
+// Views are created by migrations and registered with a Scheduler at
+// startup. The scheduler refreshes each view when its interval has elapsed
+// since the last refresh, concurrently when the view has a unique index so
+// that readers are never blocked. Refresh times are stored in the
+// materialized_view_refreshes table, so several instances share the schedule
+// and only one of them refreshes a view at a time. Staleness is exported as
+// a metric and reported by Status and Check for health endpoints.
+package matview
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	pgx "github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/rs/zerolog"
+
+	"github.com/PrinceNarteh/go-boilerplate/internal/database"
+	"github.com/PrinceNarteh/go-boilerplate/internal/libs/async"
+	"github.com/PrinceNarteh/go-boilerplate/internal/telemetry"
+)
+
+// maxCheckInterval is the longest time between two checks of the schedule
+const maxCheckInterval = 30 * time.Second
+
+// ErrStale is returned by Check when a view has not been refreshed within its MaxStaleness
+var ErrStale = errors.New("matview: stale materialized views")
+
+// View is a materialized view refreshed by the scheduler
+type View struct {
+	// Name is the name of the materialized view
+	Name string
+	// Interval is the time between two refreshes
+	Interval time.Duration
+	// MaxStaleness is the age above which the view is reported unhealthy,
+	// twice Interval when zero
+	MaxStaleness time.Duration
+}
+
+// Status is the refresh status of a view
+type Status struct {
+	Name        string     `json:"name"`
+	RefreshedAt *time.Time `json:"refreshed_at,omitempty"`
+	// Staleness is the number of seconds since the last refresh
+	Staleness float64 `json:"staleness_seconds"`
+	Healthy   bool    `json:"healthy"`
+	LastError string  `json:"last_error,omitempty"`
+}
+
+// Scheduler refreshes registered materialized views
+type Scheduler struct {
+	db      *pgxpool.Pool
+	tx      *database.TxManager
+	metrics *telemetry.ViewMetrics
+	logger  *zerolog.Logger
+
+	mu      sync.Mutex
+	views   []View
+	lastErr map[string]string
+	stop    func()
+}
+
+// NewScheduler creates a new materialized view scheduler
+func NewScheduler(db *pgxpool.Pool, metrics *telemetry.ViewMetrics, logger *zerolog.Logger) *Scheduler {
+	return &Scheduler{
+		db:      db,
+		tx:      database.NewTxManager(db),
+		metrics: metrics,
+		logger:  logger,
+		lastErr: make(map[string]string),
+	}
+}
+
+// Register adds a view to the schedule. It must be called before Start.
+func (s *Scheduler) Register(view View) {
+	if view.MaxStaleness <= 0 {
+		view.MaxStaleness = 2 * view.Interval
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.views = append(s.views, view)
+}
+
+// Registered reports whether a view with the given name is registered
+func (s *Scheduler) Registered(name string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, view := range s.views {
+		if view.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Start refreshes the views that are due, then keeps checking the schedule
+// in the background until Stop is called
+func (s *Scheduler) Start() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stop != nil || len(s.views) == 0 {
+		return
+	}
+
+	interval := maxCheckInterval
+	for _, view := range s.views {
+		interval = min(interval, view.Interval)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := async.Go(ctx, "matview.scheduler", func(ctx context.Context) error {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			s.refreshDue(ctx)
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-ticker.C:
+			}
+		}
+	})
+
+	s.stop = func() {
+		cancel()
+		<-done
+	}
+}
+
+// Stop stops the background refreshes and waits for a running one to finish
+func (s *Scheduler) Stop() {
+	s.mu.Lock()
+	stop := s.stop
+	s.stop = nil
+	s.mu.Unlock()
+
+	if stop != nil {
+		stop()
+	}
+}
+
+// Refresh refreshes the named view now. It does nothing when another
+// instance is already refreshing it.
+func (s *Scheduler) Refresh(ctx context.Context, name string) error {
+	start := time.Now()
+	err := s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		conn := database.Conn(ctx, s.db)
+
+		var locked bool
+		lockQuery := `SELECT pg_try_advisory_xact_lock(hashtext('matview:' || $1))`
+		if err := conn.QueryRow(ctx, lockQuery, name).Scan(&locked); err != nil {
+			return fmt.Errorf("failed to lock view: %w", err)
+		}
+		if !locked {
+			return nil
+		}
+
+		concurrent, err := canRefreshConcurrently(ctx, conn, name)
+		if err != nil {
+			return err
+		}
+
+		refresh := "REFRESH MATERIALIZED VIEW "
+		if concurrent {
+			refresh += "CONCURRENTLY "
+		}
+		if _, err := conn.Exec(ctx, refresh+pgx.Identifier{name}.Sanitize()); err != nil {
+			return fmt.Errorf("failed to refresh view: %w", err)
+		}
+
+		record := `
+			INSERT INTO materialized_view_refreshes (name, refreshed_at, duration_ms)
+			VALUES ($1, NOW(), $2)
+			ON CONFLICT (name) DO UPDATE SET refreshed_at = EXCLUDED.refreshed_at, duration_ms = EXCLUDED.duration_ms`
+		if _, err := conn.Exec(ctx, record, name, time.Since(start).Milliseconds()); err != nil {
+			return fmt.Errorf("failed to record view refresh: %w", err)
+		}
+		return nil
+	})
+
+	s.metrics.RecordRefresh(ctx, name, time.Since(start), err)
+
+	s.mu.Lock()
+	if err != nil {
+		s.lastErr[name] = err.Error()
+	} else {
+		delete(s.lastErr, name)
+	}
+	s.mu.Unlock()
+
+	if err != nil {
+		return fmt.Errorf("matview %s: %w", name, err)
+	}
+	return nil
+}
+
+// Status returns the refresh status of every registered view
+func (s *Scheduler) Status(ctx context.Context) ([]Status, error) {
+	refreshed, err := s.refreshTimes(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	statuses := make([]Status, len(s.views))
+	for i, view := range s.views {
+		status := Status{Name: view.Name, LastError: s.lastErr[view.Name]}
+		if at, ok := refreshed[view.Name]; ok {
+			staleness := time.Since(at)
+			status.RefreshedAt = &at
+			status.Staleness = staleness.Seconds()
+			status.Healthy = staleness <= view.MaxStaleness
+		}
+		statuses[i] = status
+	}
+
+	return statuses, nil
+}
+
+// Check returns an error wrapping ErrStale listing the views that were not
+// refreshed within their MaxStaleness, for use by health checks
+func (s *Scheduler) Check(ctx context.Context) error {
+	statuses, err := s.Status(ctx)
+	if err != nil {
+		return err
+	}
+
+	var stale []string
+	for _, status := range statuses {
+		if !status.Healthy {
+			stale = append(stale, status.Name)
+		}
+	}
+	if len(stale) > 0 {
+		return fmt.Errorf("%w: %s", ErrStale, strings.Join(stale, ", "))
+	}
+	return nil
+}
+
+// refreshDue refreshes the views whose interval has elapsed and records
+// the staleness of every view
+func (s *Scheduler) refreshDue(ctx context.Context) {
+	refreshed, err := s.refreshTimes(ctx)
+	if err != nil {
+		s.logger.Error().Err(err).Msg("Failed to read materialized view refresh times")
+		return
+	}
+
+	s.mu.Lock()
+	views := append([]View(nil), s.views...)
+	s.mu.Unlock()
+
+	for _, view := range views {
+		if ctx.Err() != nil {
+			return
+		}
+
+		at, ok := refreshed[view.Name]
+		if ok {
+			s.metrics.RecordStaleness(ctx, view.Name, time.Since(at))
+			if time.Since(at) < view.Interval {
+				continue
+			}
+		}
+
+		if err := s.Refresh(ctx, view.Name); err != nil && ctx.Err() == nil {
+			s.logger.Error().Err(err).Str("view", view.Name).Msg("Failed to refresh materialized view")
+		}
+	}
+}
+
+// refreshTimes returns the time of the last refresh of every view that was refreshed
+func (s *Scheduler) refreshTimes(ctx context.Context) (map[string]time.Time, error) {
+	rows, err := database.Conn(ctx, s.db).Query(ctx, `SELECT name, refreshed_at FROM materialized_view_refreshes`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list view refreshes: %w", err)
+	}
+	defer rows.Close()
+
+	refreshed := make(map[string]time.Time)
+	for rows.Next() {
+		var name string
+		var at time.Time
+		if err := rows.Scan(&name, &at); err != nil {
+			return nil, fmt.Errorf("failed to scan view refresh: %w", err)
+		}
+		refreshed[name] = at
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+
+	return refreshed, nil
+}
+
+// canRefreshConcurrently reports whether a view can be refreshed without
+// blocking readers, which requires it to be populated and to have a unique
+// index without a WHERE clause
+func canRefreshConcurrently(ctx context.Context, conn database.Querier, name string) (bool, error) {
+	query := `
+		SELECT m.ispopulated AND EXISTS (
+			SELECT 1
+			FROM pg_index i
+			JOIN pg_class c ON c.oid = i.indrelid
+			WHERE c.relname = m.matviewname AND i.indisunique AND i.indpred IS NULL
+		)
+		FROM pg_matviews m
+		WHERE m.matviewname = $1`
+
+	var concurrent bool
+	if err := conn.QueryRow(ctx, query, name).Scan(&concurrent); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return false, fmt.Errorf("materialized view %s does not exist", name)
+		}
+		return false, fmt.Errorf("failed to inspect view: %w", err)
+	}
+	return concurrent, nil
+}
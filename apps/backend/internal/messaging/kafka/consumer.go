@@ -0,0 +1,240 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+	kafkago "github.com/segmentio/kafka-go"
+
+	"github.com/PrinceNarteh/go-boilerplate/internal/config"
+	"github.com/PrinceNarteh/go-boilerplate/internal/libs/async"
+	"github.com/PrinceNarteh/go-boilerplate/internal/messaging"
+	"github.com/PrinceNarteh/go-boilerplate/internal/telemetry"
+	"github.com/PrinceNarteh/go-boilerplate/internal/tracing"
+)
+
+const (
+	defaultMaxAttempts     = 3                // Default number of times a message is handled
+	defaultRetryBackoff    = time.Second      // Default delay before the first retry of a message
+	maxRetryBackoff        = time.Minute      // Longest delay between retries of a message
+	defaultShutdownTimeout = 30 * time.Second // Default time given to the message in hand on shutdown
+	commitTimeout          = 5 * time.Second  // Timeout of offset commits
+)
+
+// Consumer reads a topic as a member of a consumer group
+type Consumer struct {
+	reader  *kafkago.Reader
+	topic   string
+	handler Handler
+	codec   messaging.Codec
+	cfg     config.KafkaConfig
+	tracer  tracing.Tracer
+	logger  *zerolog.Logger
+
+	mu   sync.Mutex
+	stop func()
+}
+
+// NewConsumer creates a consumer of topic in the consumer group of cfg,
+// calling handler for each message. Values are decoded with codec,
+// messaging.JSONCodec when nil. tracer records each message as a
+// transaction and may be nil. Unset settings of cfg use the defaults.
+func NewConsumer(
+	cfg config.KafkaConfig,
+	topic string,
+	handler Handler,
+	codec messaging.Codec,
+	tracer tracing.Tracer,
+	logger *zerolog.Logger,
+) *Consumer {
+	if codec == nil {
+		codec = messaging.JSONCodec{}
+	}
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = defaultMaxAttempts
+	}
+	if cfg.RetryBackoff <= 0 {
+		cfg.RetryBackoff = defaultRetryBackoff
+	}
+	if cfg.ShutdownTimeout <= 0 {
+		cfg.ShutdownTimeout = defaultShutdownTimeout
+	}
+	startOffset := kafkago.FirstOffset
+	if cfg.StartOffset == "latest" {
+		startOffset = kafkago.LastOffset
+	}
+
+	log := logger.With().Str("topic", topic).Str("group_id", cfg.GroupID).Logger()
+	return &Consumer{
+		reader: kafkago.NewReader(kafkago.ReaderConfig{
+			Brokers:        cfg.Brokers,
+			GroupID:        cfg.GroupID,
+			Topic:          topic,
+			Dialer:         &kafkago.Dialer{ClientID: cfg.ClientID, Timeout: 10 * time.Second, DualStack: true},
+			StartOffset:    startOffset,
+			CommitInterval: cfg.CommitInterval,
+			ErrorLogger: zerologFunc(func(msg string, args ...any) {
+				log.Error().Msgf("kafka consumer: "+msg, args...)
+			}),
+		}),
+		topic:   topic,
+		handler: handler,
+		codec:   codec,
+		cfg:     cfg,
+		tracer:  tracer,
+		logger:  &log,
+	}
+}
+
+// Start consumes messages in the background until Stop is called
+func (c *Consumer) Start() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.stop != nil {
+		return
+	}
+
+	// Fetching stops on Stop, while the message in hand keeps its context
+	// until the shutdown timeout
+	fetchCtx, stopFetching := context.WithCancel(context.Background())
+	runCtx, cancelRun := context.WithCancel(context.Background())
+	done := async.Go(fetchCtx, "kafka.consumer."+c.topic, func(fetchCtx context.Context) error {
+		c.consume(fetchCtx, runCtx)
+		return nil
+	})
+
+	c.stop = func() {
+		stopFetching()
+		select {
+		case <-done:
+		case <-time.After(c.cfg.ShutdownTimeout):
+			c.logger.Warn().Dur("timeout", c.cfg.ShutdownTimeout).Msg("Canceling message handler on shutdown")
+			cancelRun()
+			<-done
+		}
+		cancelRun()
+
+		// Closing commits the offsets pending with a commit interval and
+		// leaves the consumer group
+		if err := c.reader.Close(); err != nil {
+			c.logger.Error().Err(err).Msg("Failed to close Kafka consumer")
+		}
+	}
+}
+
+// Stop stops fetching messages, waits for the message in hand up to the
+// shutdown timeout and leaves the consumer group. Messages not committed
+// yet are delivered again to the group.
+func (c *Consumer) Stop() {
+	c.mu.Lock()
+	stop := c.stop
+	c.stop = nil
+	c.mu.Unlock()
+
+	if stop != nil {
+		stop()
+	}
+}
+
+// consume fetches and handles messages one at a time until fetchCtx is done
+func (c *Consumer) consume(fetchCtx, runCtx context.Context) {
+	for {
+		m, err := c.reader.FetchMessage(fetchCtx)
+		if err != nil {
+			if fetchCtx.Err() != nil || errors.Is(err, context.Canceled) {
+				return
+			}
+			c.logger.Error().Err(err).Msg("Failed to fetch Kafka message")
+			select {
+			case <-fetchCtx.Done():
+				return
+			case <-time.After(c.cfg.RetryBackoff):
+			}
+			continue
+		}
+
+		if !c.handle(fetchCtx, runCtx, m) {
+			// Interrupted by shutdown, the message is delivered again
+			return
+		}
+
+		commitCtx, cancel := context.WithTimeout(context.WithoutCancel(runCtx), commitTimeout)
+		if err := c.reader.CommitMessages(commitCtx, m); err != nil {
+			c.logger.Error().Err(err).
+				Int("partition", m.Partition).
+				Int64("offset", m.Offset).
+				Msg("Failed to commit Kafka offset")
+		}
+		cancel()
+	}
+}
+
+// handle calls the handler for a message until it succeeds or runs out of
+// attempts, waiting with exponential backoff between attempts. It reports
+// false when shutdown interrupted the retries.
+func (c *Consumer) handle(fetchCtx, runCtx context.Context, m kafkago.Message) bool {
+	msg := &Message{
+		Topic:     m.Topic,
+		Partition: m.Partition,
+		Offset:    m.Offset,
+		Key:       string(m.Key),
+		Value:     m.Value,
+		Headers:   make(map[string]string, len(m.Headers)),
+		Time:      m.Time,
+		codec:     c.codec,
+	}
+	for _, header := range m.Headers {
+		msg.Headers[header.Key] = string(header.Value)
+	}
+	log := c.logger.With().Int("partition", m.Partition).Int64("offset", m.Offset).Logger()
+
+	backoff := c.cfg.RetryBackoff
+	for {
+		msg.Attempt++
+		err := c.run(runCtx, msg, m, &log)
+		if err == nil {
+			return true
+		}
+		if runCtx.Err() != nil {
+			return false
+		}
+		if msg.Attempt >= c.cfg.MaxAttempts {
+			log.Error().Err(err).Int("attempt", msg.Attempt).Msg("Kafka message failed, skipping it")
+			return true
+		}
+
+		log.Warn().Err(err).Int("attempt", msg.Attempt).Dur("retry_in", backoff).Msg("Kafka message failed, retrying")
+		select {
+		case <-fetchCtx.Done():
+			return false
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, maxRetryBackoff)
+	}
+}
+
+// run handles one attempt at a message as a New Relic transaction
+// continuing the trace of the producer, recovering panics. The handler
+// finds the logger of the message in its context.
+func (c *Consumer) run(ctx context.Context, msg *Message, m kafkago.Message, log *zerolog.Logger) (err error) {
+	// newrelic methods are no-ops on a nil application and transaction
+	txn := tracing.Start(c.tracer, "kafka/"+c.topic)
+	defer txn.End()
+	txn.AcceptDistributedTraceHeaders(tracing.TransportKafka, fromHeaders(m.Headers))
+	txn.AddAttribute("kafka.partition", m.Partition)
+	txn.AddAttribute("kafka.offset", m.Offset)
+	txn.AddAttribute("kafka.attempt", msg.Attempt)
+
+	ctx = tracing.NewContext(ctx, txn)
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("kafka handler panicked: %v", p)
+		}
+		telemetry.ReportError(ctx, "kafka/"+c.topic, err)
+	}()
+	return c.handler(log.WithContext(ctx), msg)
+}
@@ -0,0 +1,104 @@
+// Package kafka produces and consumes Kafka messages.
+//
+// A Producer encodes values with a messaging.Codec and writes them to topics,
+// partitioned by key so the messages of a key keep their order. A Consumer
+// reads a topic as a member of a consumer group and calls its Handler for
+// each message, committing the offset of a message once it is handled, so
+// messages are delivered at least once: handlers must be idempotent.
+//
+// Values are encoded as JSON by default. Other encodings, such as Avro with
+// a schema registry, implement messaging.Codec. The content type of the codec
+// travels in the content-type header of each message.
+//
+// The producer adds the New Relic distributed trace headers of the
+// transaction in its context to each message, and the consumer records each
+// message as a New Relic background transaction continuing that trace.
+package kafka
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	kafkago "github.com/segmentio/kafka-go"
+
+	"github.com/PrinceNarteh/go-boilerplate/internal/libs/versioned"
+	"github.com/PrinceNarteh/go-boilerplate/internal/messaging"
+)
+
+// Message is a message read from a topic
+type Message struct {
+	Topic     string
+	Partition int
+	Offset    int64
+	Key       string
+	Value     []byte
+	Headers   map[string]string
+	Time      time.Time
+	// Attempt is the number of times the message was handled by this
+	// consumer so far, including the current one
+	Attempt int
+
+	codec messaging.Codec
+}
+
+// Decode decodes the value of the message into v with the codec of the consumer
+func (m *Message) Decode(v any) error {
+	return m.codec.Unmarshal(m.Value, v)
+}
+
+// Handler handles the messages of a topic. A returned error fails the
+// message, which is retried until it runs out of attempts.
+type Handler func(ctx context.Context, msg *Message) error
+
+// Typed returns a handler decoding the value of messages into V before
+// calling fn. Values that do not decode fail the message.
+func Typed[V any](fn func(ctx context.Context, value V) error) Handler {
+	return Upgrading(nil, fn)
+}
+
+// Upgrading returns a handler like Typed that first upgrades messages of
+// older versions, as set by their versioned.Header, to the version of V
+// with upgrades. Messages of a newer version fail with
+// versioned.ErrNewerVersion.
+func Upgrading[V any](upgrades versioned.Upgrades, fn func(ctx context.Context, value V) error) Handler {
+	return func(ctx context.Context, msg *Message) error {
+		version := versioned.ParseVersion(msg.Headers[versioned.Header])
+		data, err := upgrades.Apply(msg.Value, version, versioned.Version[V]())
+		if err != nil {
+			return fmt.Errorf("failed to decode %s message: %w", msg.Topic, err)
+		}
+
+		var value V
+		if err := msg.codec.Unmarshal(data, &value); err != nil {
+			return fmt.Errorf("failed to decode %s message: %w", msg.Topic, err)
+		}
+		return fn(ctx, value)
+	}
+}
+
+// toHeaders converts HTTP-style headers, such as distributed trace
+// headers, to message headers
+func toHeaders(h http.Header) []kafkago.Header {
+	headers := make([]kafkago.Header, 0, len(h))
+	for key := range h {
+		headers = append(headers, kafkago.Header{Key: key, Value: []byte(h.Get(key))})
+	}
+	return headers
+}
+
+// fromHeaders converts message headers to HTTP-style headers
+func fromHeaders(headers []kafkago.Header) http.Header {
+	h := make(http.Header, len(headers))
+	for _, header := range headers {
+		h.Set(header.Key, string(header.Value))
+	}
+	return h
+}
+
+// zerologFunc adapts a logging function to the logger of kafka-go
+type zerologFunc func(msg string, args ...any)
+
+// Printf implements kafkago.Logger
+func (f zerologFunc) Printf(msg string, args ...any) { f(msg, args...) }
@@ -0,0 +1,102 @@
+package kafka
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/rs/zerolog"
+	kafkago "github.com/segmentio/kafka-go"
+
+	"github.com/PrinceNarteh/go-boilerplate/internal/config"
+	"github.com/PrinceNarteh/go-boilerplate/internal/libs/versioned"
+	"github.com/PrinceNarteh/go-boilerplate/internal/messaging"
+	"github.com/PrinceNarteh/go-boilerplate/internal/tracing"
+)
+
+const (
+	defaultRequiredAcks = kafkago.RequireAll    // Default acknowledgements of a write
+	defaultBatchTimeout = 10 * time.Millisecond // Default delay before sending an incomplete batch
+)
+
+// Producer writes messages to Kafka topics
+type Producer struct {
+	writer *kafkago.Writer
+	codec  messaging.Codec
+}
+
+// NewProducer creates a producer writing to the brokers of cfg. Values are
+// encoded with codec, messaging.JSONCodec when nil. The producer must be
+// closed with Close.
+func NewProducer(cfg config.KafkaConfig, codec messaging.Codec, logger *zerolog.Logger) *Producer {
+	if codec == nil {
+		codec = messaging.JSONCodec{}
+	}
+	if cfg.BatchTimeout <= 0 {
+		cfg.BatchTimeout = defaultBatchTimeout
+	}
+
+	return &Producer{
+		writer: &kafkago.Writer{
+			Addr: kafkago.TCP(cfg.Brokers...),
+			// Messages of a key go to the same partition as with the Java client
+			Balancer:     &kafkago.Murmur2Balancer{},
+			RequiredAcks: requiredAcks(cfg.RequiredAcks),
+			BatchTimeout: cfg.BatchTimeout,
+			Transport:    &kafkago.Transport{ClientID: cfg.ClientID},
+			ErrorLogger: zerologFunc(func(msg string, args ...any) {
+				logger.Error().Msgf("kafka producer: "+msg, args...)
+			}),
+		},
+		codec: codec,
+	}
+}
+
+// Publish encodes value and writes it to topic under key, waiting for the
+// acknowledgement of the brokers. The distributed trace headers of the
+// New Relic transaction of ctx are added to the message.
+func (p *Producer) Publish(ctx context.Context, topic, key string, value any) error {
+	data, err := p.codec.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s message: %w", topic, err)
+	}
+
+	headers := http.Header{}
+	txn, ok := tracing.FromContext(ctx)
+	if ok {
+		txn.InsertDistributedTraceHeaders(headers)
+	}
+	headers.Set(messaging.ContentTypeHeader, p.codec.ContentType())
+	headers.Set(versioned.Header, versioned.FormatVersion(versioned.Of(value)))
+
+	defer txn.StartProducerSegment("Kafka", topic).End()
+
+	err = p.writer.WriteMessages(ctx, kafkago.Message{
+		Topic:   topic,
+		Key:     []byte(key),
+		Value:   data,
+		Headers: toHeaders(headers),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to publish %s message: %w", topic, err)
+	}
+	return nil
+}
+
+// Close flushes pending messages and closes the connections of the producer
+func (p *Producer) Close() error {
+	return p.writer.Close()
+}
+
+// requiredAcks maps the configured acknowledgements to kafka-go
+func requiredAcks(acks string) kafkago.RequiredAcks {
+	switch acks {
+	case "none":
+		return kafkago.RequireNone
+	case "one":
+		return kafkago.RequireOne
+	default:
+		return defaultRequiredAcks
+	}
+}
@@ -0,0 +1,48 @@
+// Package messaging holds what the message broker drivers share.
+//
+// The broker is selected with MessagingConfig.Driver: Kafka, see package
+// kafka, or NATS JetStream, see package nats, for deployments not running
+// Kafka. Both encode values with a Codec and publish them through the
+// Publisher interface, so producers do not depend on the driver.
+package messaging
+
+import (
+	"context"
+	"encoding/json"
+)
+
+// Drivers of MessagingConfig.Driver
+const (
+	DriverKafka = "kafka"
+	DriverNATS  = "nats"
+)
+
+// ContentTypeHeader is the message header naming the codec of the value
+const ContentTypeHeader = "content-type"
+
+// Publisher publishes messages to a broker
+type Publisher interface {
+	// Publish encodes value and publishes it to topic under key. Messages of
+	// a key keep their order where the broker partitions topics.
+	Publish(ctx context.Context, topic, key string, value any) error
+}
+
+// Codec encodes and decodes message values
+type Codec interface {
+	// ContentType names the encoding, such as "application/json"
+	ContentType() string
+	Marshal(v any) ([]byte, error)
+	Unmarshal(data []byte, v any) error
+}
+
+// JSONCodec encodes values as JSON
+type JSONCodec struct{}
+
+// ContentType implements Codec
+func (JSONCodec) ContentType() string { return "application/json" }
+
+// Marshal implements Codec
+func (JSONCodec) Marshal(v any) ([]byte, error) { return json.Marshal(v) }
+
+// Unmarshal implements Codec
+func (JSONCodec) Unmarshal(data []byte, v any) error { return json.Unmarshal(data, v) }
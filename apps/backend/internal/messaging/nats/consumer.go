@@ -0,0 +1,163 @@
+package nats
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/rs/zerolog"
+
+	"github.com/PrinceNarteh/go-boilerplate/internal/libs/async"
+)
+
+// Consumer reads a subject of the stream through a durable JetStream consumer
+type Consumer struct {
+	client  *Client
+	name    string
+	subject string
+	handler Handler
+	logger  *zerolog.Logger
+
+	mu   sync.Mutex
+	stop func()
+}
+
+// NewConsumer creates a consumer of subject on the stream of client,
+// calling handler for each message. name is the durable name the server
+// keeps the position of the consumer under, shared by the instances of the
+// service so they split the messages.
+func NewConsumer(client *Client, name, subject string, handler Handler) *Consumer {
+	log := client.logger.With().Str("subject", subject).Str("consumer", name).Logger()
+	return &Consumer{
+		client:  client,
+		name:    name,
+		subject: subject,
+		handler: handler,
+		logger:  &log,
+	}
+}
+
+// Start creates or updates the durable consumer and consumes messages in
+// the background until Stop is called
+func (c *Consumer) Start(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.stop != nil {
+		return nil
+	}
+
+	cfg := c.client.cfg
+	consumer, err := c.client.js.CreateOrUpdateConsumer(ctx, cfg.Stream, jetstream.ConsumerConfig{
+		Durable:       c.name,
+		FilterSubject: c.subject,
+		AckPolicy:     jetstream.AckExplicitPolicy,
+		AckWait:       cfg.AckWait,
+		MaxDeliver:    cfg.MaxDeliver,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create consumer %s: %w", c.name, err)
+	}
+
+	// Messages are pulled one at a time, so the ack wait of a message does
+	// not run out while it waits behind others
+	messages, err := consumer.Messages(jetstream.PullMaxMessages(1))
+	if err != nil {
+		return fmt.Errorf("failed to consume %s: %w", c.subject, err)
+	}
+
+	// Pulling stops on Stop, while the message in hand keeps its context
+	// until the shutdown timeout
+	runCtx, cancelRun := context.WithCancel(context.Background())
+	done := async.Go(runCtx, "nats.consumer."+c.name, func(ctx context.Context) error {
+		c.consume(ctx, messages)
+		return nil
+	})
+
+	c.stop = func() {
+		messages.Stop()
+		select {
+		case <-done:
+		case <-time.After(cfg.ShutdownTimeout):
+			c.logger.Warn().Dur("timeout", cfg.ShutdownTimeout).Msg("Canceling message handler on shutdown")
+			cancelRun()
+			<-done
+		}
+		cancelRun()
+	}
+	return nil
+}
+
+// Stop stops pulling messages and waits for the message in hand up to the
+// shutdown timeout. Messages not acknowledged yet are delivered again.
+func (c *Consumer) Stop() {
+	c.mu.Lock()
+	stop := c.stop
+	c.stop = nil
+	c.mu.Unlock()
+
+	if stop != nil {
+		stop()
+	}
+}
+
+// consume handles messages one at a time until the iterator is stopped
+func (c *Consumer) consume(ctx context.Context, messages jetstream.MessagesContext) {
+	for {
+		m, err := messages.Next()
+		if err != nil {
+			if errors.Is(err, jetstream.ErrMsgIteratorClosed) {
+				return
+			}
+			c.logger.Warn().Err(err).Msg("Failed to pull NATS message")
+			continue
+		}
+		c.handle(ctx, m)
+	}
+}
+
+// handle calls the handler for a message and acknowledges it. A failed
+// message is delivered again after a backoff doubling with each delivery,
+// and terminated once it runs out of deliveries.
+func (c *Consumer) handle(ctx context.Context, m jetstream.Msg) {
+	attempt := 1
+	if meta, err := m.Metadata(); err == nil {
+		attempt = int(meta.NumDelivered)
+	}
+	msg := &Message{
+		Subject: m.Subject(),
+		Key:     m.Headers().Get(keyHeader),
+		Data:    m.Data(),
+		Headers: make(map[string]string, len(m.Headers())),
+		Attempt: attempt,
+		codec:   c.client.codec,
+	}
+	for key := range m.Headers() {
+		msg.Headers[key] = m.Headers().Get(key)
+	}
+	log := c.logger.With().Int("attempt", attempt).Logger()
+
+	err := c.client.run(ctx, msg, c.handler)
+	switch {
+	case err == nil:
+		err = m.Ack()
+	case ctx.Err() != nil:
+		// Interrupted by shutdown, the message is delivered again
+		err = m.Nak()
+	case attempt >= c.client.cfg.MaxDeliver:
+		log.Error().Err(err).Msg("NATS message failed, terminating it")
+		err = m.Term()
+	default:
+		backoff := c.client.cfg.RetryBackoff << (attempt - 1)
+		if backoff <= 0 || backoff > maxRetryBackoff {
+			backoff = maxRetryBackoff
+		}
+		log.Warn().Err(err).Dur("retry_in", backoff).Msg("NATS message failed, retrying")
+		err = m.NakWithDelay(backoff)
+	}
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to acknowledge NATS message")
+	}
+}
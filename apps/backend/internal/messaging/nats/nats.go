@@ -0,0 +1,405 @@
+// Package nats publishes and consumes messages with NATS JetStream, a
+// lighter alternative to Kafka.
+//
+// A Client connects to NATS and creates the JetStream stream of the
+// configuration, storing the messages of its subjects. Publish stores a
+// message in the stream and waits for the acknowledgement of the server. A
+// Consumer reads a subject through a durable consumer, so it resumes where
+// it stopped, and acknowledges each message once handled: messages are
+// delivered at least once, and handlers must be idempotent.
+//
+// The client also serves core NATS, which does not store messages:
+// Subscribe for fire-and-forget notifications, and Request and Reply for
+// request-reply between services.
+//
+// Durable consumers are started per subject and stopped with the workers,
+// and responders are registered per request subject, such as:
+//
+//	consumer := nats.NewConsumer(client, durable+"-users", services.UserEventsTopic,
+//		nats.Typed(func(ctx context.Context, e events.UserCreated) error { ... }))
+//	if err := consumer.Start(ctx); err != nil {
+//		return err
+//	}
+//	lc.OnStop(lifecycle.PhaseWorkers, "nats.users", lifecycle.Func(consumer.Stop))
+//
+//	client.Reply("users.get", durable, func(ctx context.Context, msg *nats.Message) (any, error) { ... })
+//
+// Values are encoded with a messaging.Codec, JSON by default, named in the
+// content-type header. Messages carry the New Relic distributed trace
+// headers of the publisher, and handlers run in New Relic background
+// transactions continuing that trace.
+package nats
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	natsgo "github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/rs/zerolog"
+
+	"github.com/PrinceNarteh/go-boilerplate/internal/config"
+	"github.com/PrinceNarteh/go-boilerplate/internal/healthcheck"
+	"github.com/PrinceNarteh/go-boilerplate/internal/libs/versioned"
+	"github.com/PrinceNarteh/go-boilerplate/internal/messaging"
+	"github.com/PrinceNarteh/go-boilerplate/internal/telemetry"
+	"github.com/PrinceNarteh/go-boilerplate/internal/tracing"
+)
+
+const (
+	defaultMaxDeliver      = 3                // Default number of deliveries of a stored message
+	defaultAckWait         = 30 * time.Second // Default time given to handle a stored message
+	defaultRetryBackoff    = time.Second      // Default delay before the first redelivery of a message
+	maxRetryBackoff        = time.Minute      // Longest delay between deliveries of a message
+	defaultRequestTimeout  = 5 * time.Second  // Default time waited for a reply
+	defaultShutdownTimeout = 30 * time.Second // Default time given to messages in hand on shutdown
+)
+
+const (
+	keyHeader   = "key"   // Message header holding the key of a published message
+	errorHeader = "error" // Reply header holding the error of a failed request
+)
+
+// ErrRequestFailed is returned by Request when the responder failed
+var ErrRequestFailed = errors.New("request failed")
+
+// Client is a connection to NATS and its JetStream context
+type Client struct {
+	conn   *natsgo.Conn
+	js     jetstream.JetStream
+	codec  messaging.Codec
+	cfg    config.NATSConfig
+	tracer tracing.Tracer
+	logger *zerolog.Logger
+	closed chan struct{}
+}
+
+// Connect connects to the server of cfg and creates or updates its stream
+// when cfg.Stream is set. Values are encoded with codec,
+// messaging.JSONCodec when nil. tracer records handled messages as
+// transactions and may be nil. Unset settings of cfg use the defaults. The
+// client reconnects on its own, and must be closed with Close.
+func Connect(
+	ctx context.Context,
+	cfg config.NATSConfig,
+	codec messaging.Codec,
+	tracer tracing.Tracer,
+	logger *zerolog.Logger,
+) (*Client, error) {
+	if codec == nil {
+		codec = messaging.JSONCodec{}
+	}
+	if cfg.URL == "" {
+		cfg.URL = natsgo.DefaultURL
+	}
+	if cfg.MaxDeliver <= 0 {
+		cfg.MaxDeliver = defaultMaxDeliver
+	}
+	if cfg.AckWait <= 0 {
+		cfg.AckWait = defaultAckWait
+	}
+	if cfg.RetryBackoff <= 0 {
+		cfg.RetryBackoff = defaultRetryBackoff
+	}
+	if cfg.RequestTimeout <= 0 {
+		cfg.RequestTimeout = defaultRequestTimeout
+	}
+	if cfg.ShutdownTimeout <= 0 {
+		cfg.ShutdownTimeout = defaultShutdownTimeout
+	}
+
+	c := &Client{
+		codec:  codec,
+		cfg:    cfg,
+		tracer: tracer,
+		logger: logger,
+		closed: make(chan struct{}),
+	}
+	conn, err := natsgo.Connect(cfg.URL,
+		natsgo.Name(cfg.Name),
+		natsgo.MaxReconnects(-1),
+		natsgo.DisconnectErrHandler(func(_ *natsgo.Conn, err error) {
+			if err != nil {
+				logger.Warn().Err(err).Msg("Disconnected from NATS")
+			}
+		}),
+		natsgo.ReconnectHandler(func(conn *natsgo.Conn) {
+			logger.Info().Str("url", conn.ConnectedUrl()).Msg("Reconnected to NATS")
+		}),
+		natsgo.ErrorHandler(func(_ *natsgo.Conn, sub *natsgo.Subscription, err error) {
+			event := logger.Error().Err(err)
+			if sub != nil {
+				event = event.Str("subject", sub.Subject)
+			}
+			event.Msg("NATS error")
+		}),
+		natsgo.ClosedHandler(func(*natsgo.Conn) { close(c.closed) }),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to NATS: %w", err)
+	}
+	c.conn = conn
+
+	if c.js, err = jetstream.New(conn); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to create JetStream context: %w", err)
+	}
+	if cfg.Stream != "" {
+		_, err := c.js.CreateOrUpdateStream(ctx, jetstream.StreamConfig{
+			Name:     cfg.Stream,
+			Subjects: cfg.Subjects,
+		})
+		if err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("failed to create stream %s: %w", cfg.Stream, err)
+		}
+	}
+
+	return c, nil
+}
+
+// Publish encodes value and stores it in the stream of subject under key,
+// waiting for the acknowledgement of the server. It implements
+// messaging.Publisher.
+func (c *Client) Publish(ctx context.Context, subject, key string, value any) error {
+	msg, err := c.newMsg(ctx, subject, value)
+	if err != nil {
+		return err
+	}
+	if key != "" {
+		msg.Header.Set(keyHeader, key)
+	}
+
+	txn, _ := tracing.FromContext(ctx)
+	defer txn.StartProducerSegment("NATS", subject).End()
+
+	if _, err := c.js.PublishMsg(ctx, msg); err != nil {
+		return fmt.Errorf("failed to publish %s message: %w", subject, err)
+	}
+	return nil
+}
+
+// Subscribe calls handler for the messages published to subject with core
+// NATS while the subscription lasts. Messages are not stored: those
+// published while no subscriber listens are lost.
+func (c *Client) Subscribe(subject string, handler Handler) (*natsgo.Subscription, error) {
+	sub, err := c.conn.Subscribe(subject, func(m *natsgo.Msg) {
+		msg := c.newMessage(m, 1)
+		if err := c.run(context.Background(), msg, handler); err != nil {
+			c.logger.Error().Err(err).Str("subject", m.Subject).Msg("NATS message failed")
+		}
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to subscribe to %s: %w", subject, err)
+	}
+	return sub, nil
+}
+
+// Request sends req to the responder of subject and decodes its reply into
+// resp, waiting up to the request timeout. A failed responder returns an
+// error wrapping ErrRequestFailed.
+func (c *Client) Request(ctx context.Context, subject string, req, resp any) error {
+	msg, err := c.newMsg(ctx, subject, req)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.cfg.RequestTimeout)
+	defer cancel()
+	reply, err := c.conn.RequestMsgWithContext(ctx, msg)
+	if err != nil {
+		return fmt.Errorf("failed to request %s: %w", subject, err)
+	}
+	if text := reply.Header.Get(errorHeader); text != "" {
+		return fmt.Errorf("%w: %s: %s", ErrRequestFailed, subject, text)
+	}
+	if resp == nil {
+		return nil
+	}
+	if err := c.codec.Unmarshal(reply.Data, resp); err != nil {
+		return fmt.Errorf("failed to decode %s reply: %w", subject, err)
+	}
+	return nil
+}
+
+// Responder answers a request with a value sent back to the requester, or
+// an error failing the request
+type Responder func(ctx context.Context, msg *Message) (any, error)
+
+// Reply answers the requests sent to subject with responder while the
+// subscription lasts. Responders subscribed with the same queue share the
+// requests, so each request is answered by one instance of the service.
+func (c *Client) Reply(subject, queue string, responder Responder) (*natsgo.Subscription, error) {
+	sub, err := c.conn.QueueSubscribe(subject, queue, func(m *natsgo.Msg) {
+		var value any
+		err := c.run(context.Background(), c.newMessage(m, 1), func(ctx context.Context, msg *Message) error {
+			var err error
+			value, err = responder(ctx, msg)
+			return err
+		})
+
+		reply := natsgo.NewMsg(m.Reply)
+		if err == nil {
+			reply.Data, err = c.codec.Marshal(value)
+			reply.Header.Set(messaging.ContentTypeHeader, c.codec.ContentType())
+		}
+		if err != nil {
+			reply.Header.Set(errorHeader, err.Error())
+		}
+		if err := m.RespondMsg(reply); err != nil {
+			c.logger.Error().Err(err).Str("subject", m.Subject).Msg("Failed to reply to NATS request")
+		}
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to reply to %s: %w", subject, err)
+	}
+	return sub, nil
+}
+
+// HealthCheck returns a check failing while the connection is down or
+// JetStream does not answer
+func (c *Client) HealthCheck() healthcheck.Check {
+	return func(ctx context.Context) (any, error) {
+		details := map[string]any{"status": c.conn.Status().String()}
+		if !c.conn.IsConnected() {
+			return details, errors.New("not connected to NATS")
+		}
+
+		info, err := c.js.AccountInfo(ctx)
+		if err != nil {
+			return details, fmt.Errorf("JetStream unavailable: %w", err)
+		}
+		details["streams"] = info.Streams
+		details["consumers"] = info.Consumers
+		return details, nil
+	}
+}
+
+// Close drains the subscriptions, letting their handlers finish the
+// messages in hand up to the shutdown timeout, and closes the connection
+func (c *Client) Close() error {
+	if err := c.conn.Drain(); err != nil {
+		c.conn.Close()
+		return fmt.Errorf("failed to drain NATS connection: %w", err)
+	}
+
+	select {
+	case <-c.closed:
+	case <-time.After(c.cfg.ShutdownTimeout):
+		c.conn.Close()
+	}
+	return nil
+}
+
+// newMsg creates a message of value to subject, carrying the distributed
+// trace headers of the New Relic transaction of ctx
+func (c *Client) newMsg(ctx context.Context, subject string, value any) (*natsgo.Msg, error) {
+	data, err := c.codec.Marshal(value)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode %s message: %w", subject, err)
+	}
+
+	headers := http.Header{}
+	if txn, ok := tracing.FromContext(ctx); ok {
+		txn.InsertDistributedTraceHeaders(headers)
+	}
+	headers.Set(messaging.ContentTypeHeader, c.codec.ContentType())
+	headers.Set(versioned.Header, versioned.FormatVersion(versioned.Of(value)))
+
+	msg := natsgo.NewMsg(subject)
+	msg.Data = data
+	msg.Header = natsgo.Header(headers)
+	return msg, nil
+}
+
+// newMessage converts a received message for handlers
+func (c *Client) newMessage(m *natsgo.Msg, attempt int) *Message {
+	msg := &Message{
+		Subject: m.Subject,
+		Key:     m.Header.Get(keyHeader),
+		Data:    m.Data,
+		Headers: make(map[string]string, len(m.Header)),
+		Attempt: attempt,
+		codec:   c.codec,
+	}
+	for key := range m.Header {
+		msg.Headers[key] = m.Header.Get(key)
+	}
+	return msg
+}
+
+// run handles a message as a New Relic transaction continuing the trace of
+// the publisher, recovering panics. The handler finds the logger of the
+// client in its context.
+func (c *Client) run(ctx context.Context, msg *Message, handler Handler) (err error) {
+	txn := tracing.Start(c.tracer, "nats/"+msg.Subject)
+	defer txn.End()
+	headers := make(http.Header, len(msg.Headers))
+	for key, value := range msg.Headers {
+		headers.Set(key, value)
+	}
+	txn.AcceptDistributedTraceHeaders(tracing.TransportQueue, headers)
+	txn.AddAttribute("nats.attempt", msg.Attempt)
+
+	ctx = tracing.NewContext(ctx, txn)
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("nats handler panicked: %v", p)
+		}
+		telemetry.ReportError(ctx, "nats/"+msg.Subject, err)
+	}()
+	log := c.logger.With().Str("subject", msg.Subject).Logger()
+	return handler(log.WithContext(ctx), msg)
+}
+
+// Message is a message received from NATS
+type Message struct {
+	Subject string
+	// Key is the key the message was published under, if any
+	Key     string
+	Data    []byte
+	Headers map[string]string
+	// Attempt is the number of times the message was delivered so far,
+	// including the current one. It is always 1 with core NATS.
+	Attempt int
+
+	codec messaging.Codec
+}
+
+// Decode decodes the data of the message into v with the codec of the client
+func (m *Message) Decode(v any) error {
+	return m.codec.Unmarshal(m.Data, v)
+}
+
+// Handler handles messages. A returned error fails the message, which a
+// durable consumer delivers again until it runs out of deliveries.
+type Handler func(ctx context.Context, msg *Message) error
+
+// Typed returns a handler decoding messages into V before calling fn.
+// Messages that do not decode fail.
+func Typed[V any](fn func(ctx context.Context, value V) error) Handler {
+	return Upgrading(nil, fn)
+}
+
+// Upgrading returns a handler like Typed that first upgrades messages of
+// older versions, as set by their versioned.Header, to the version of V
+// with upgrades. Messages of a newer version fail with
+// versioned.ErrNewerVersion.
+func Upgrading[V any](upgrades versioned.Upgrades, fn func(ctx context.Context, value V) error) Handler {
+	return func(ctx context.Context, msg *Message) error {
+		version := versioned.ParseVersion(msg.Headers[versioned.Header])
+		data, err := upgrades.Apply(msg.Data, version, versioned.Version[V]())
+		if err != nil {
+			return fmt.Errorf("failed to decode %s message: %w", msg.Subject, err)
+		}
+
+		var value V
+		if err := msg.codec.Unmarshal(data, &value); err != nil {
+			return fmt.Errorf("failed to decode %s message: %w", msg.Subject, err)
+		}
+		return fn(ctx, value)
+	}
+}
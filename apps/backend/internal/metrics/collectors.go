@@ -0,0 +1,133 @@
+package metrics
+
+import (
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+	goredis "github.com/redis/go-redis/v9"
+)
+
+// RegisterDBPool registers the statistics of a database connection pool,
+// labelled with name, e.g. "primary" or the address of a replica
+func (r *Registry) RegisterDBPool(name string, pool *pgxpool.Pool) error {
+	return r.Register(newDBPoolCollector(name, pool))
+}
+
+// RegisterRedis registers the connection pool statistics of a Redis client,
+// labelled with name, e.g. "cache"
+func (r *Registry) RegisterRedis(name string, client *goredis.Client) error {
+	return r.Register(newRedisCollector(name, client))
+}
+
+// dbPoolCollector collects the statistics of a pgx pool when scraped
+type dbPoolCollector struct {
+	pool *pgxpool.Pool
+
+	acquiredConns     *prometheus.Desc
+	idleConns         *prometheus.Desc
+	totalConns        *prometheus.Desc
+	maxConns          *prometheus.Desc
+	acquires          *prometheus.Desc
+	acquireSeconds    *prometheus.Desc
+	emptyAcquires     *prometheus.Desc
+	canceledAcquires  *prometheus.Desc
+	newConns          *prometheus.Desc
+	lifetimeDestroyed *prometheus.Desc
+	idleDestroyed     *prometheus.Desc
+}
+
+// newDBPoolCollector creates the collector of a database pool
+func newDBPoolCollector(name string, pool *pgxpool.Pool) *dbPoolCollector {
+	labels := prometheus.Labels{"pool": name}
+	desc := func(metric, help string) *prometheus.Desc {
+		return prometheus.NewDesc("db_pool_"+metric, help, nil, labels)
+	}
+
+	return &dbPoolCollector{
+		pool:              pool,
+		acquiredConns:     desc("acquired_connections", "Number of connections in use."),
+		idleConns:         desc("idle_connections", "Number of idle connections."),
+		totalConns:        desc("connections", "Number of open connections."),
+		maxConns:          desc("max_connections", "Maximum number of open connections."),
+		acquires:          desc("acquires_total", "Number of connections acquired."),
+		acquireSeconds:    desc("acquire_seconds_total", "Time spent acquiring connections."),
+		emptyAcquires:     desc("empty_acquires_total", "Number of acquires that waited for a connection."),
+		canceledAcquires:  desc("canceled_acquires_total", "Number of acquires canceled by their context."),
+		newConns:          desc("new_connections_total", "Number of connections opened."),
+		lifetimeDestroyed: desc("max_lifetime_destroyed_total", "Number of connections closed for their age."),
+		idleDestroyed:     desc("max_idle_destroyed_total", "Number of connections closed for being idle."),
+	}
+}
+
+// Describe implements prometheus.Collector
+func (c *dbPoolCollector) Describe(ch chan<- *prometheus.Desc) {
+	prometheus.DescribeByCollect(c, ch)
+}
+
+// Collect implements prometheus.Collector
+func (c *dbPoolCollector) Collect(ch chan<- prometheus.Metric) {
+	stat := c.pool.Stat()
+	gauge := func(desc *prometheus.Desc, v float64) {
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, v)
+	}
+	counter := func(desc *prometheus.Desc, v float64) {
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.CounterValue, v)
+	}
+
+	gauge(c.acquiredConns, float64(stat.AcquiredConns()))
+	gauge(c.idleConns, float64(stat.IdleConns()))
+	gauge(c.totalConns, float64(stat.TotalConns()))
+	gauge(c.maxConns, float64(stat.MaxConns()))
+	counter(c.acquires, float64(stat.AcquireCount()))
+	counter(c.acquireSeconds, stat.AcquireDuration().Seconds())
+	counter(c.emptyAcquires, float64(stat.EmptyAcquireCount()))
+	counter(c.canceledAcquires, float64(stat.CanceledAcquireCount()))
+	counter(c.newConns, float64(stat.NewConnsCount()))
+	counter(c.lifetimeDestroyed, float64(stat.MaxLifetimeDestroyCount()))
+	counter(c.idleDestroyed, float64(stat.MaxIdleDestroyCount()))
+}
+
+// redisCollector collects the pool statistics of a Redis client when scraped
+type redisCollector struct {
+	client *goredis.Client
+
+	hits       *prometheus.Desc
+	misses     *prometheus.Desc
+	timeouts   *prometheus.Desc
+	totalConns *prometheus.Desc
+	idleConns  *prometheus.Desc
+	staleConns *prometheus.Desc
+}
+
+// newRedisCollector creates the collector of a Redis client
+func newRedisCollector(name string, client *goredis.Client) *redisCollector {
+	labels := prometheus.Labels{"client": name}
+	desc := func(metric, help string) *prometheus.Desc {
+		return prometheus.NewDesc("redis_pool_"+metric, help, nil, labels)
+	}
+
+	return &redisCollector{
+		client:     client,
+		hits:       desc("hits_total", "Number of times an idle connection was found in the pool."),
+		misses:     desc("misses_total", "Number of times no idle connection was found in the pool."),
+		timeouts:   desc("timeouts_total", "Number of times waiting for a connection timed out."),
+		totalConns: desc("connections", "Number of open connections."),
+		idleConns:  desc("idle_connections", "Number of idle connections."),
+		staleConns: desc("stale_connections_total", "Number of stale connections removed from the pool."),
+	}
+}
+
+// Describe implements prometheus.Collector
+func (c *redisCollector) Describe(ch chan<- *prometheus.Desc) {
+	prometheus.DescribeByCollect(c, ch)
+}
+
+// Collect implements prometheus.Collector
+func (c *redisCollector) Collect(ch chan<- prometheus.Metric) {
+	stats := c.client.PoolStats()
+	ch <- prometheus.MustNewConstMetric(c.hits, prometheus.CounterValue, float64(stats.Hits))
+	ch <- prometheus.MustNewConstMetric(c.misses, prometheus.CounterValue, float64(stats.Misses))
+	ch <- prometheus.MustNewConstMetric(c.timeouts, prometheus.CounterValue, float64(stats.Timeouts))
+	ch <- prometheus.MustNewConstMetric(c.totalConns, prometheus.GaugeValue, float64(stats.TotalConns))
+	ch <- prometheus.MustNewConstMetric(c.idleConns, prometheus.GaugeValue, float64(stats.IdleConns))
+	ch <- prometheus.MustNewConstMetric(c.staleConns, prometheus.CounterValue, float64(stats.StaleConns))
+}
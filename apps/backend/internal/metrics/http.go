@@ -0,0 +1,147 @@
+package metrics
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/PrinceNarteh/go-boilerplate/internal/middlewares"
+)
+
+// sizeBuckets are the bucket boundaries, in bytes, of the request and
+// response size histograms, from 100B to 10MB
+var sizeBuckets = prometheus.ExponentialBuckets(100, 10, 6)
+
+// httpMetrics records the rate, errors and duration of HTTP server requests
+type httpMetrics struct {
+	requests     *prometheus.CounterVec
+	duration     *prometheus.HistogramVec
+	requestSize  *prometheus.HistogramVec
+	responseSize *prometheus.HistogramVec
+	inFlight     prometheus.Gauge
+	canceled     *prometheus.CounterVec
+}
+
+// newHTTPMetrics creates the HTTP server collectors. The duration histogram
+// uses the default Prometheus buckets unless buckets is set.
+func newHTTPMetrics(buckets []float64) *httpMetrics {
+	if len(buckets) == 0 {
+		buckets = prometheus.DefBuckets
+	}
+	labels := []string{"method", "route", "status"}
+
+	return &httpMetrics{
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "http_requests_total",
+			Help: "Number of HTTP requests, by method, route and status.",
+		}, labels),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "http_request_duration_seconds",
+			Help:    "Duration of HTTP requests, by method, route and status.",
+			Buckets: buckets,
+		}, labels),
+		requestSize: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "http_request_size_bytes",
+			Help:    "Size of HTTP request bodies, by method, route and status.",
+			Buckets: sizeBuckets,
+		}, labels),
+		responseSize: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "http_response_size_bytes",
+			Help:    "Size of HTTP response bodies, by method, route and status.",
+			Buckets: sizeBuckets,
+		}, labels),
+		inFlight: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "http_requests_in_flight",
+			Help: "Number of HTTP requests being served.",
+		}),
+		canceled: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "http_requests_canceled_total",
+			Help: "Number of HTTP requests canceled by their client before the response, by method and route.",
+		}, []string{"method", "route"}),
+	}
+}
+
+// collectors returns the collectors to register
+func (m *httpMetrics) collectors() []prometheus.Collector {
+	return []prometheus.Collector{m.requests, m.duration, m.requestSize, m.responseSize, m.inFlight, m.canceled}
+}
+
+// Middleware records the HTTP request metrics. Requests are labelled with
+// the route pattern matched by the router, so that paths with IDs do not
+// create a time series each. Requests canceled by their client are counted
+// apart and never recorded as server errors.
+func (r *Registry) Middleware() middlewares.Middleware {
+	m := r.http
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			start := time.Now()
+			m.inFlight.Inc()
+			defer m.inFlight.Dec()
+
+			req, route := middlewares.TrackRoute(req)
+			rw := &responseWriter{ResponseWriter: w, statusCode: http.StatusOK}
+
+			next.ServeHTTP(rw, req)
+
+			method := middlewares.MetricMethod(req.Method)
+			status, canceled := middlewares.CanceledStatus(req, rw.statusCode)
+			if canceled {
+				m.canceled.WithLabelValues(method, route()).Inc()
+			}
+			labels := prometheus.Labels{
+				"method": method,
+				"route":  route(),
+				"status": strconv.Itoa(status),
+			}
+			m.requests.With(labels).Inc()
+			m.duration.With(labels).Observe(time.Since(start).Seconds())
+			// The length of chunked bodies is unknown
+			if req.ContentLength >= 0 {
+				m.requestSize.With(labels).Observe(float64(req.ContentLength))
+			}
+			m.responseSize.With(labels).Observe(float64(rw.written))
+		})
+	}
+}
+
+// responseWriter captures the status code and the size of a response
+type responseWriter struct {
+	http.ResponseWriter
+	statusCode  int
+	written     int64
+	wroteHeader bool
+}
+
+func (rw *responseWriter) WriteHeader(code int) {
+	if !rw.wroteHeader {
+		rw.statusCode = code
+		rw.wroteHeader = true
+	}
+	rw.ResponseWriter.WriteHeader(code)
+}
+
+func (rw *responseWriter) Write(b []byte) (int, error) {
+	rw.wroteHeader = true
+	n, err := rw.ResponseWriter.Write(b)
+	rw.written += int64(n)
+	return n, err
+}
+
+// Hijack implements http.Hijacker, so connections can be upgraded to
+// WebSocket through the middleware
+func (rw *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, buf, err := http.NewResponseController(rw.ResponseWriter).Hijack()
+	if err == nil {
+		rw.statusCode = http.StatusSwitchingProtocols
+	}
+	return conn, buf, err
+}
+
+// Unwrap returns the underlying ResponseWriter, for http.ResponseController
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
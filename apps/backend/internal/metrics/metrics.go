@@ -0,0 +1,91 @@
+// Package metrics exposes the application's metrics in the Prometheus
+// format, for deployments that scrape rather than receive OTLP pushes.
+//
+// A Registry collects the RED metrics of the HTTP server, labelled by route
+// pattern and status, the Go runtime and process metrics, and the statistics
+// of the database pools and Redis clients registered with it. Feature
+// modules register their own collectors with Register:
+//
+//	ordersPlaced := prometheus.NewCounter(prometheus.CounterOpts{
+//		Name: "orders_placed_total",
+//		Help: "Number of orders placed.",
+//	})
+//	if err := registry.Register(ordersPlaced); err != nil {
+//		return err
+//	}
+//
+// The Registry is a routers.Module serving the metrics at the configured path.
+package metrics
+
+import (
+	"cmp"
+	"fmt"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"github.com/PrinceNarteh/go-boilerplate/internal/config"
+	"github.com/PrinceNarteh/go-boilerplate/internal/routers"
+)
+
+// defaultPath is the path of the metrics endpoint when none is configured
+const defaultPath = "/metrics"
+
+// Registry holds the collectors exposed on the metrics endpoint
+type Registry struct {
+	registry *prometheus.Registry
+	path     string
+	http     *httpMetrics
+}
+
+// New creates a registry with the HTTP server, Go runtime and process
+// collectors registered
+func New(cfg config.PrometheusConfig) *Registry {
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		collectors.NewBuildInfoCollector(),
+	)
+
+	httpMetrics := newHTTPMetrics(cfg.DurationBuckets)
+	registry.MustRegister(httpMetrics.collectors()...)
+
+	return &Registry{
+		registry: registry,
+		path:     cmp.Or(cfg.Path, defaultPath),
+		http:     httpMetrics,
+	}
+}
+
+// Register registers collectors, failing if one collects metrics already
+// collected by another, e.g. when registered twice
+func (r *Registry) Register(cs ...prometheus.Collector) error {
+	for _, c := range cs {
+		if err := r.registry.Register(c); err != nil {
+			return fmt.Errorf("failed to register metrics collector: %w", err)
+		}
+	}
+	return nil
+}
+
+// Registerer returns the underlying registerer, for libraries that register
+// their own collectors
+func (r *Registry) Registerer() prometheus.Registerer {
+	return r.registry
+}
+
+// Handler returns the handler serving the metrics in the Prometheus text format
+func (r *Registry) Handler() http.Handler {
+	return promhttp.HandlerFor(r.registry, promhttp.HandlerOpts{
+		Registry:          r.registry,
+		EnableOpenMetrics: true,
+	})
+}
+
+// RegisterRoutes implements routers.Module
+func (r *Registry) RegisterRoutes(g *routers.RouteGroup) {
+	g.Handle(http.MethodGet, r.path, r.Handler())
+}
@@ -0,0 +1,193 @@
+package middlewares
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/rs/zerolog"
+
+	"github.com/PrinceNarteh/go-boilerplate/internal/auth"
+	"github.com/PrinceNarteh/go-boilerplate/internal/cache"
+	"github.com/PrinceNarteh/go-boilerplate/internal/errs"
+)
+
+// AuthenticateOptions configures the lookup of the principals of verified
+// tokens by AuthenticateWith and Authenticator
+type AuthenticateOptions struct {
+	// Loader resolves the current state of the principal of every verified
+	// token when set, such as its user and permissions
+	Loader auth.PrincipalLoader
+	// Cache caches the principals resolved by Loader by token hash when set,
+	// such as in Redis with a short TTL, so that requests do not look them up
+	Cache *cache.Cache[auth.Principal]
+	// Checker rejects the revoked tokens when set, on every request and
+	// without caching, such as those of revoked sessions
+	Checker auth.PrincipalChecker
+	// Consents rejects the principals who have not accepted the latest
+	// consent documents when set. It only applies to Authenticator: routes
+	// chain RequireConsent after AuthenticateWith instead.
+	Consents ConsentChecker
+	// Tenants resolves the schema of the tenant of tokens when tenancy is
+	// enabled. Tokens issued in a tenant are rejected without it.
+	Tenants TenantResolver
+	// TenantHeader is the request header in which clients may name their
+	// tenant, "X-Tenant-ID" when empty. Requests naming another tenant than
+	// the one of their token are forbidden.
+	TenantHeader string
+}
+
+// Authenticate creates a middleware that requires a valid bearer token.
+// The verified principal is stored in the request context (see auth.FromContext)
+// and its user ID is added to the request logger.
+func Authenticate(tokens *auth.TokenManager) Middleware {
+	return AuthenticateWith(tokens, AuthenticateOptions{})
+}
+
+// resolvedKey is the context key for the hash of the token whose principal
+// the context carries
+type resolvedKey struct{}
+
+// AuthenticateWith creates a middleware like Authenticate that resolves the
+// principal of the token with opts.Loader. The principal is resolved once per
+// request, even when several groups of the route authenticate, and cached
+// across requests with opts.Cache. Tokens whose principal is no longer valid,
+// or that opts.Checker rejects, are rejected as unauthorized. The queries of
+// tokens issued in a tenant, including those of the loader, run in the
+// schema of that tenant.
+func AuthenticateWith(tokens *auth.TokenManager, opts AuthenticateOptions) Middleware {
+	// Routes require consents with RequireConsent, so that the routes exempt
+	// from it share the principal resolved here
+	opts.Consents = nil
+	authenticator := NewAuthenticator(tokens, opts)
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if !ok || token == "" {
+				errs.WriteJSON(w, errs.ErrUnauthorized)
+				return
+			}
+
+			hash := auth.HashToken(token)
+			if resolved, _ := r.Context().Value(resolvedKey{}).(string); resolved == hash {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			ctx, err := authenticator.Authenticate(r.Context(), token, r.Header.Get(authenticator.opts.TenantHeader))
+			if err != nil {
+				errs.WriteJSON(w, AuthenticationError(r.Context(), err))
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(context.WithValue(ctx, resolvedKey{}, hash)))
+		})
+	}
+}
+
+// Authenticator resolves the principals of bearer tokens as AuthenticateWith
+// does, for the transports other than the HTTP routes, such as gRPC and
+// WebSocket
+type Authenticator struct {
+	tokens *auth.TokenManager
+	opts   AuthenticateOptions
+}
+
+// NewAuthenticator creates an authenticator resolving the principals of the
+// tokens verified by tokens with opts
+func NewAuthenticator(tokens *auth.TokenManager, opts AuthenticateOptions) *Authenticator {
+	if opts.TenantHeader == "" {
+		opts.TenantHeader = defaultTenantHeader
+	}
+	return &Authenticator{tokens: tokens, opts: opts}
+}
+
+// TenantHeader returns the request header in which clients may name their
+// tenant
+func (a *Authenticator) TenantHeader() string {
+	return a.opts.TenantHeader
+}
+
+// Authenticate verifies token and returns a copy of ctx carrying its
+// principal (see auth.FromContext), running its queries in the schema of
+// its tenant. tenant is the tenant named by the client, if any. It returns
+// an error wrapping auth.ErrInvalidToken when the token is invalid or
+// revoked, and the errs.AppError of principals that are not allowed, such
+// as errs.ErrConsentRequired. The user ID is added to the logger of ctx.
+func (a *Authenticator) Authenticate(ctx context.Context, token, tenant string) (context.Context, error) {
+	principal, err := a.tokens.Verify(token)
+	if err != nil {
+		return nil, err
+	}
+	ctx, err = tenantContext(ctx, a.opts.Tenants, principal, tenant)
+	if err != nil {
+		return nil, err
+	}
+	if a.opts.Checker != nil {
+		if err := a.opts.Checker.CheckPrincipal(ctx, principal); err != nil {
+			return nil, err
+		}
+	}
+
+	if a.opts.Loader != nil {
+		verified := principal
+		load := func(ctx context.Context) (*auth.Principal, error) {
+			return a.opts.Loader.LoadPrincipal(ctx, verified)
+		}
+		if a.opts.Cache == nil {
+			principal, err = load(ctx)
+		} else {
+			principal, err = a.opts.Cache.GetOrLoad(ctx, auth.HashToken(token), load)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if a.opts.Consents != nil {
+		if err := checkConsents(ctx, a.opts.Consents, principal); err != nil {
+			return nil, err
+		}
+	}
+
+	zerolog.Ctx(ctx).UpdateContext(func(c zerolog.Context) zerolog.Context {
+		return c.Int("user_id", principal.UserID)
+	})
+	return auth.WithPrincipal(ctx, principal), nil
+}
+
+// AuthenticationError returns the application error of a failed
+// authentication with Authenticator, logging the unexpected ones
+func AuthenticationError(ctx context.Context, err error) error {
+	if errors.Is(err, auth.ErrInvalidToken) {
+		return errs.ErrUnauthorized
+	}
+	var appErr *errs.AppError
+	if !errors.As(err, &appErr) {
+		zerolog.Ctx(ctx).Error().Err(err).Msg("Failed to resolve principal")
+		return errs.ErrInternal
+	}
+	return appErr
+}
+
+// RequireRole creates a middleware that only allows principals with the given role.
+// It must run after Authenticate.
+func RequireRole(role auth.Role) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			principal, ok := auth.FromContext(r.Context())
+			if !ok {
+				errs.WriteJSON(w, errs.ErrUnauthorized)
+				return
+			}
+			if !principal.HasRole(role) {
+				errs.WriteJSON(w, errs.ErrForbidden)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,25 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/netip"
+
+	"github.com/PrinceNarteh/go-boilerplate/internal/errs"
+	"github.com/PrinceNarteh/go-boilerplate/internal/lists"
+)
+
+// BlockIPs creates a middleware that rejects requests from the addresses
+// and ranges of list with errs.ErrForbidden. The list is consulted on every
+// request, so reloads apply immediately.
+func BlockIPs(list *lists.List) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if addr, err := netip.ParseAddr(ClientIP(r)); err == nil && list.ContainsAddr(addr) {
+				errs.WriteJSON(w, errs.ErrForbidden)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package middlewares
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/PrinceNarteh/go-boilerplate/internal/errs"
+)
+
+// BodyLimit creates a middleware that limits the size of request bodies.
+// The limit applied is the one whose path prefix in overrides is the longest
+// match for the request path, or defaultLimit when none match. Requests that
+// declare a larger Content-Length are rejected with a 413 before the body is
+// read; bodies without a declared length are wrapped in http.MaxBytesReader so
+// that reads fail once the limit is exceeded. A limit of zero or less disables
+// the check for matching routes.
+func BodyLimit(defaultLimit int64, overrides map[string]int64) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			limit := bodyLimitFor(r.URL.Path, defaultLimit, overrides)
+			if limit <= 0 || r.Body == nil || r.Body == http.NoBody {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if r.ContentLength > limit {
+				// Closing the connection stops the client from streaming the rest of the body
+				w.Header().Set("Connection", "close")
+				errs.WriteJSON(w, errs.NewPayloadTooLarge(limit))
+				return
+			}
+
+			r.Body = http.MaxBytesReader(w, r.Body, limit)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// bodyLimitFor returns the body limit for a path using the longest matching
+// prefix. Prefixes match whole path segments, so "/api/v1/files" matches
+// "/api/v1/files/1" but not "/api/v1/filesystem".
+func bodyLimitFor(path string, defaultLimit int64, overrides map[string]int64) int64 {
+	limit, matched := defaultLimit, -1
+	for prefix, override := range overrides {
+		segment := strings.TrimSuffix(prefix, "/")
+		if len(segment) > matched && (path == segment || strings.HasPrefix(path, segment+"/")) {
+			limit, matched = override, len(segment)
+		}
+	}
+	return limit
+}
@@ -0,0 +1,88 @@
+package middlewares
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+	"sync/atomic"
+)
+
+// trustedProxies are the networks of the proxies whose X-Forwarded-For
+// header ClientIP trusts, none until SetTrustedProxies is called
+var trustedProxies atomic.Pointer[[]netip.Prefix]
+
+// SetTrustedProxies sets the networks of the reverse proxies in front of
+// the server, in CIDR notation such as "10.0.0.0/8" or as single addresses.
+// Requests they forward are attributed to the client they forwarded for.
+func SetTrustedProxies(cidrs []string) error {
+	prefixes := make([]netip.Prefix, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		prefix, err := netip.ParsePrefix(cidr)
+		if err != nil {
+			addr, addrErr := netip.ParseAddr(cidr)
+			if addrErr != nil {
+				return fmt.Errorf("invalid trusted proxy %q: %w", cidr, err)
+			}
+			prefix = netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen())
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	trustedProxies.Store(&prefixes)
+	return nil
+}
+
+// ClientIP returns the IP address of the client that sent the request.
+// Behind proxies set with SetTrustedProxies, it is the last address of
+// X-Forwarded-For that is not one of them, as the addresses before it may
+// be forged by the client. The peers of Unix sockets are trusted proxies,
+// as only the local processes the socket file is shared with reach them.
+func ClientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	if !isUnixSocket(r) && !isTrustedProxy(host) {
+		return host
+	}
+
+	hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	client := host
+	for i := len(hops) - 1; i >= 0; i-- {
+		addr, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+		if err != nil {
+			break
+		}
+		client = addr.Unmap().String()
+		if !isTrustedProxy(client) {
+			break
+		}
+	}
+	return client
+}
+
+// isUnixSocket reports whether the request was received on a Unix socket
+func isUnixSocket(r *http.Request) bool {
+	addr, ok := r.Context().Value(http.LocalAddrContextKey).(net.Addr)
+	return ok && addr.Network() == "unix"
+}
+
+// isTrustedProxy reports whether ip belongs to a trusted proxy
+func isTrustedProxy(ip string) bool {
+	prefixes := trustedProxies.Load()
+	if prefixes == nil {
+		return false
+	}
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, prefix := range *prefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,58 @@
+package middlewares
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/rs/zerolog"
+
+	"github.com/PrinceNarteh/go-boilerplate/internal/auth"
+	"github.com/PrinceNarteh/go-boilerplate/internal/errs"
+)
+
+// ConsentChecker returns the kinds of consent documents a user still has to accept
+type ConsentChecker interface {
+	PendingConsents(ctx context.Context, userID int) ([]string, error)
+}
+
+// RequireConsent creates a middleware that rejects authenticated users who
+// have not accepted the latest version of every consent document with
+// errs.ErrConsentRequired, listing the pending document kinds in the details.
+// It must run after Authenticate; anonymous requests are passed through.
+func RequireConsent(checker ConsentChecker) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			principal, ok := auth.FromContext(r.Context())
+			if !ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if err := checkConsents(r.Context(), checker, principal); err != nil {
+				var appErr *errs.AppError
+				if !errors.As(err, &appErr) {
+					zerolog.Ctx(r.Context()).Error().Err(err).Msg("Failed to check pending consents")
+				}
+				errs.WriteJSON(w, err)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// checkConsents returns errs.ErrConsentRequired, listing the pending
+// document kinds in the details, when principal has not accepted the latest
+// version of every consent document
+func checkConsents(ctx context.Context, checker ConsentChecker, principal *auth.Principal) error {
+	pending, err := checker.PendingConsents(ctx, principal.UserID)
+	if err != nil {
+		return err
+	}
+	if len(pending) > 0 {
+		return errs.ErrConsentRequired.WithDetails(map[string][]string{"pending": pending})
+	}
+	return nil
+}
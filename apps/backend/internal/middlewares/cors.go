@@ -0,0 +1,127 @@
+package middlewares
+
+import (
+	"cmp"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/PrinceNarteh/go-boilerplate/internal/config"
+)
+
+var (
+	// corsDefaultMethods are the methods allowed by policies that do not list theirs
+	corsDefaultMethods = []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"}
+	// corsDefaultHeaders are the request headers allowed by policies that do not list theirs
+	corsDefaultHeaders = []string{"Content-Type", "Authorization", RequestIDHeader}
+)
+
+// corsPolicy is a policy with its headers built once
+type corsPolicy struct {
+	origins     []string
+	anyOrigin   bool
+	credentials bool
+	methods     string
+	headers     string
+	exposed     string
+	maxAge      string
+}
+
+// corsBinding binds a policy to a path and the paths below it
+type corsBinding struct {
+	path   string
+	policy *corsPolicy
+}
+
+// CORS creates a CORS middleware allowing the given origins on every route
+func CORS(allowedOrigins []string) Middleware {
+	return CORSPolicies(map[string]config.CORSPolicyConfig{
+		"default": {Paths: []string{"/"}, AllowedOrigins: allowedOrigins},
+	})
+}
+
+// CORSPolicies creates a CORS middleware applying to each request the policy
+// bound to the longest path its path is or is below, see
+// config.CORSPolicyConfig. It runs before routing, so preflight requests are
+// answered for every route of the policy, which the router would reject
+// as their method is OPTIONS. Requests under no policy pass through
+// without CORS headers.
+func CORSPolicies(policies map[string]config.CORSPolicyConfig) Middleware {
+	var bindings []corsBinding
+	for _, cfg := range policies {
+		methods, headers := cfg.AllowedMethods, cfg.AllowedHeaders
+		if len(methods) == 0 {
+			methods = corsDefaultMethods
+		}
+		if len(headers) == 0 {
+			headers = corsDefaultHeaders
+		}
+		exposed := slices.Concat([]string{RequestIDHeader}, RateLimitHeaders, cfg.ExposedHeaders)
+
+		policy := &corsPolicy{
+			origins:     cfg.AllowedOrigins,
+			anyOrigin:   slices.Contains(cfg.AllowedOrigins, "*"),
+			credentials: cfg.AllowCredentials,
+			methods:     strings.Join(methods, ", "),
+			headers:     strings.Join(headers, ", "),
+			exposed:     strings.Join(exposed, ", "),
+		}
+		if cfg.MaxAge > 0 {
+			policy.maxAge = strconv.Itoa(cfg.MaxAge)
+		}
+		for _, path := range cfg.Paths {
+			bindings = append(bindings, corsBinding{path: strings.TrimSuffix(path, "/"), policy: policy})
+		}
+	}
+	// The longest path is matched first
+	slices.SortFunc(bindings, func(a, b corsBinding) int {
+		return cmp.Compare(len(b.path), len(a.path))
+	})
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			policy := matchCORSPolicy(bindings, r.URL.Path)
+			if policy == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			origin := r.Header.Get("Origin")
+			w.Header().Add("Vary", "Origin")
+			allowed := origin != "" && (policy.anyOrigin || slices.Contains(policy.origins, origin))
+			if allowed {
+				w.Header().Set("Access-Control-Allow-Origin", origin)
+				w.Header().Set("Access-Control-Expose-Headers", policy.exposed)
+				if policy.credentials {
+					w.Header().Set("Access-Control-Allow-Credentials", "true")
+				}
+			}
+
+			if r.Method == http.MethodOptions {
+				if allowed {
+					w.Header().Set("Access-Control-Allow-Methods", policy.methods)
+					w.Header().Set("Access-Control-Allow-Headers", policy.headers)
+					if policy.maxAge != "" {
+						w.Header().Set("Access-Control-Max-Age", policy.maxAge)
+					}
+				}
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// matchCORSPolicy returns the policy bound to the longest path matching
+// the request path, or nil
+func matchCORSPolicy(bindings []corsBinding, path string) *corsPolicy {
+	for _, b := range bindings {
+		if b.path == "" || path == b.path || strings.HasPrefix(path, b.path+"/") {
+			return b.policy
+		}
+	}
+	return nil
+}
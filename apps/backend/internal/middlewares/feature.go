@@ -0,0 +1,43 @@
+package middlewares
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/PrinceNarteh/go-boilerplate/internal/errs"
+)
+
+// FeatureChecker reports whether a feature flag is on for a request,
+// implemented by *flags.Service
+type FeatureChecker interface {
+	Enabled(ctx context.Context, flag string) bool
+}
+
+// RequireFeature creates a middleware that only serves requests for which
+// flag is on, to launch routes to cohorts such as internal users before
+// everyone else. Other requests get status: http.StatusNotFound answers
+// like an unknown route, hiding the route in a dark launch, and
+// http.StatusForbidden reports that access is denied. Flags rolled out to
+// cohorts need the principal, so the middleware must run after Authenticate.
+//
+// Example:
+//
+//	gate := middlewares.RequireFeature(featureFlags, "reports-v2", http.StatusNotFound)
+//	reports := g.Group("/reports", authenticate, gate)
+func RequireFeature(checker FeatureChecker, flag string, status int) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if checker.Enabled(r.Context(), flag) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if status == http.StatusForbidden {
+				errs.WriteJSON(w, errs.ErrForbidden)
+				return
+			}
+			// The response of the mux to unknown routes
+			http.NotFound(w, r)
+		})
+	}
+}
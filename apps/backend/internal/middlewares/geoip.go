@@ -0,0 +1,93 @@
+package middlewares
+
+import (
+	"cmp"
+	"net"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/rs/zerolog"
+
+	"github.com/PrinceNarteh/go-boilerplate/internal/errs"
+	"github.com/PrinceNarteh/go-boilerplate/internal/geoip"
+)
+
+// GeoIP creates a middleware that resolves the client IP to a location,
+// stores it in the request context (see geoip.FromContext) and adds the
+// country and ASN to the request logger. Lookup failures are logged at debug
+// level and the request continues without a location.
+func GeoIP(resolver geoip.Resolver, logger *zerolog.Logger) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ip := net.ParseIP(ClientIP(r))
+			if ip == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			loc, err := resolver.Lookup(ip)
+			if err != nil {
+				logger.Debug().Err(err).Str("ip", ip.String()).Msg("GeoIP lookup failed")
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			zerolog.Ctx(r.Context()).UpdateContext(func(c zerolog.Context) zerolog.Context {
+				return c.Str("country", loc.CountryCode).Uint("asn", loc.ASN)
+			})
+
+			next.ServeHTTP(w, r.WithContext(geoip.WithLocation(r.Context(), loc)))
+		})
+	}
+}
+
+// BlockCountries creates a middleware that rejects requests from the ISO
+// 3166-1 alpha-2 country codes blocked on their route group with
+// errs.ErrForbidden. Blocks are keyed by route group path and apply to the
+// path and below it, the longest path winning, so a nested group can relax
+// or tighten the blocks of its parent. It relies on GeoIP running earlier in
+// the chain; requests without a known country are allowed.
+func BlockCountries(blocks map[string][]string) Middleware {
+	var bindings []countryBlock
+	for path, countries := range blocks {
+		codes := make([]string, len(countries))
+		for i, c := range countries {
+			codes[i] = strings.ToUpper(c)
+		}
+		bindings = append(bindings, countryBlock{path: strings.TrimSuffix(path, "/"), countries: codes})
+	}
+	// The longest path is matched first
+	slices.SortFunc(bindings, func(a, b countryBlock) int {
+		return cmp.Compare(len(b.path), len(a.path))
+	})
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			blocked := matchCountryBlock(bindings, r.URL.Path)
+			if loc, ok := geoip.FromContext(r.Context()); ok && slices.Contains(blocked, loc.CountryCode) {
+				errs.WriteJSON(w, errs.ErrForbidden)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// countryBlock binds the blocked countries to a route group path
+type countryBlock struct {
+	path      string
+	countries []string
+}
+
+// matchCountryBlock returns the countries blocked on the longest path
+// matching the request path
+func matchCountryBlock(bindings []countryBlock, path string) []string {
+	for _, b := range bindings {
+		if b.path == "" || path == b.path || strings.HasPrefix(path, b.path+"/") {
+			return b.countries
+		}
+	}
+	return nil
+}
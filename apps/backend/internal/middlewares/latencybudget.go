@@ -0,0 +1,220 @@
+package middlewares
+
+import (
+	"context"
+	"expvar"
+	"math/rand/v2"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/PrinceNarteh/go-boilerplate/internal/errs"
+)
+
+const (
+	defaultBudgetWindow     = 30 * time.Second // Default evaluation window for latency budgets
+	defaultBudgetMinSamples = 50               // Default samples needed before a window is evaluated
+	maxBudgetSamples        = 4096             // Samples kept per window; beyond this reservoir sampling is used
+)
+
+// latencyBudgetVars exposes the state of every latency budget via expvar
+var latencyBudgetVars = expvar.NewMap("latency_budgets")
+
+// Priority classifies how important a request is when shedding load
+type Priority int
+
+// Request priorities
+const (
+	PriorityLow Priority = iota
+	PriorityNormal
+	PriorityHigh
+)
+
+// PriorityHeader lets clients mark their requests as low or high priority
+const PriorityHeader = "X-Request-Priority"
+
+// degradedKey is the context key marking requests served while over budget
+type degradedKey struct{}
+
+// LatencyBudgetOptions configures a latency budget for a route
+type LatencyBudgetOptions struct {
+	// Name identifies the budget in logs and metrics
+	Name string
+	// Budget is the p99 latency the route should stay under
+	Budget time.Duration
+	// Window is how often the p99 is recomputed
+	Window time.Duration
+	// MinSamples is the number of requests a window needs before it is evaluated
+	MinSamples int
+	// ShedLowPriority rejects low priority requests with a 503 while over budget
+	ShedLowPriority bool
+	// Priority classifies requests; defaults to reading PriorityHeader
+	Priority func(r *http.Request) Priority
+	// Logger logs the changes of state, discarded when nil
+	Logger *zerolog.Logger
+}
+
+// latencyBudget tracks request latencies for a single route
+type latencyBudget struct {
+	opts LatencyBudgetOptions
+	vars *expvar.Map
+	shed *expvar.Int
+
+	mu          sync.Mutex
+	windowStart time.Time
+	samples     []time.Duration
+	seen        int
+	overBudget  bool
+}
+
+// LatencyBudget creates a middleware that tracks the p99 latency of a route.
+// When the p99 of the previous window exceeds the budget, requests are marked
+// as degraded so handlers can skip optional work (see IsDegraded), and low
+// priority requests are shed if ShedLowPriority is set. The current state is
+// published under the "latency_budgets" expvar.
+func LatencyBudget(opts LatencyBudgetOptions) Middleware {
+	if opts.Window <= 0 {
+		opts.Window = defaultBudgetWindow
+	}
+	if opts.MinSamples <= 0 {
+		opts.MinSamples = defaultBudgetMinSamples
+	}
+	if opts.Priority == nil {
+		opts.Priority = priorityFromHeader
+	}
+	if opts.Logger == nil {
+		nop := zerolog.Nop()
+		opts.Logger = &nop
+	}
+
+	lb := &latencyBudget{
+		opts:        opts,
+		vars:        new(expvar.Map).Init(),
+		shed:        new(expvar.Int),
+		windowStart: time.Now(),
+		samples:     make([]time.Duration, 0, maxBudgetSamples),
+	}
+	lb.vars.Set("shed_total", lb.shed)
+	lb.vars.Set("budget_ms", expvarInt(opts.Budget.Milliseconds()))
+	latencyBudgetVars.Set(opts.Name, lb.vars)
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if lb.isOverBudget(time.Now()) {
+				if lb.opts.ShedLowPriority && lb.opts.Priority(r) == PriorityLow {
+					lb.shed.Add(1)
+					w.Header().Set("Retry-After", strconv.Itoa(ceilSeconds(lb.opts.Window)))
+					errs.WriteJSON(w, errs.ErrUnavailable)
+					return
+				}
+				r = r.WithContext(context.WithValue(r.Context(), degradedKey{}, true))
+			}
+
+			start := time.Now()
+			next.ServeHTTP(w, r)
+			lb.observe(time.Now(), time.Since(start))
+		})
+	}
+}
+
+// IsDegraded reports whether the request is being served while its route is
+// over its latency budget, in which case expensive optional work should be skipped
+func IsDegraded(ctx context.Context) bool {
+	degraded, _ := ctx.Value(degradedKey{}).(bool)
+	return degraded
+}
+
+// isOverBudget reports whether the last evaluated window exceeded the budget,
+// evaluating the window first once it has elapsed, so that a route whose
+// requests are all shed recovers
+func (lb *latencyBudget) isOverBudget(now time.Time) bool {
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+	lb.evaluate(now)
+	return lb.overBudget
+}
+
+// observe records a request latency and evaluates the window once it has elapsed
+func (lb *latencyBudget) observe(now time.Time, latency time.Duration) {
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+
+	lb.seen++
+	if len(lb.samples) < maxBudgetSamples {
+		lb.samples = append(lb.samples, latency)
+	} else if i := rand.IntN(lb.seen); i < maxBudgetSamples {
+		lb.samples[i] = latency
+	}
+	lb.evaluate(now)
+}
+
+// evaluate computes the p99 of the window once it has elapsed and starts the
+// next one. A window with fewer than MinSamples requests is not over budget,
+// as there is too little load to shed. lb.mu must be held.
+func (lb *latencyBudget) evaluate(now time.Time) {
+	if now.Sub(lb.windowStart) < lb.opts.Window {
+		return
+	}
+
+	var p99 time.Duration
+	if lb.seen >= lb.opts.MinSamples {
+		slices.Sort(lb.samples)
+		p99 = lb.samples[(len(lb.samples)*99-1)/100]
+	}
+	overBudget := p99 > lb.opts.Budget
+
+	if overBudget != lb.overBudget {
+		event := lb.opts.Logger.Info()
+		if overBudget {
+			event = lb.opts.Logger.Warn()
+		}
+		event.
+			Str("budget", lb.opts.Name).
+			Dur("p99", p99).
+			Dur("limit", lb.opts.Budget).
+			Int("samples", lb.seen).
+			Bool("over_budget", overBudget).
+			Msg("Latency budget state changed")
+	}
+
+	lb.overBudget = overBudget
+	if lb.seen >= lb.opts.MinSamples {
+		lb.vars.Set("p99_ms", expvarInt(p99.Milliseconds()))
+	}
+	lb.vars.Set("over_budget", expvarInt(boolToInt(overBudget)))
+
+	lb.windowStart = now
+	lb.samples = lb.samples[:0]
+	lb.seen = 0
+}
+
+// priorityFromHeader reads the request priority from PriorityHeader
+func priorityFromHeader(r *http.Request) Priority {
+	switch r.Header.Get(PriorityHeader) {
+	case "low":
+		return PriorityLow
+	case "high":
+		return PriorityHigh
+	default:
+		return PriorityNormal
+	}
+}
+
+// expvarInt creates an expvar.Int holding v
+func expvarInt(v int64) *expvar.Int {
+	i := new(expvar.Int)
+	i.Set(v)
+	return i
+}
+
+// boolToInt converts a bool to 0 or 1
+func boolToInt(b bool) int64 {
+	if b {
+		return 1
+	}
+	return 0
+}
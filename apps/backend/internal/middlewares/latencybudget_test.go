@@ -0,0 +1,47 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestLatencyBudgetRecoversWhileShedding(t *testing.T) {
+	const window = 20 * time.Millisecond
+	handler := LatencyBudget(LatencyBudgetOptions{
+		Name:            "test_recovery",
+		Budget:          time.Millisecond,
+		Window:          window,
+		MinSamples:      2,
+		ShedLowPriority: true,
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(5 * time.Millisecond)
+	}))
+
+	serve := func(priority string) int {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set(PriorityHeader, priority)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	// A window of slow requests puts the route over budget
+	serve("normal")
+	serve("normal")
+	time.Sleep(window)
+	if code := serve("low"); code != http.StatusServiceUnavailable {
+		t.Fatalf("low priority request over budget: got %d, want %d", code, http.StatusServiceUnavailable)
+	}
+
+	// Only shed requests arrive in the next window, which has too few
+	// samples to be over budget
+	for range 3 {
+		serve("low")
+	}
+	time.Sleep(window)
+	if code := serve("low"); code != http.StatusOK {
+		t.Fatalf("low priority request after recovery: got %d, want %d", code, http.StatusOK)
+	}
+}
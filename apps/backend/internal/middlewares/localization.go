@@ -0,0 +1,32 @@
+package middlewares
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/PrinceNarteh/go-boilerplate/internal/i18n"
+)
+
+// Localization creates a middleware that stores the localizer of each
+// request in its context. The locale is taken from the "lang" query
+// parameter, then the Accept-Language header, matched against the locales
+// of catalog, and returned in the Content-Language response header. Enum
+// labels are added to responses when the "labels" query parameter is true.
+func Localization(catalog *i18n.Catalog) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			query := r.URL.Query()
+			accept := query.Get("lang")
+			if accept == "" {
+				accept = r.Header.Get("Accept-Language")
+			}
+			locale := catalog.Match(accept)
+			labels, _ := strconv.ParseBool(query.Get("labels"))
+
+			w.Header().Set("Content-Language", locale)
+			w.Header().Add("Vary", "Accept-Language")
+			ctx := i18n.WithLocalizer(r.Context(), i18n.NewLocalizer(catalog, locale, labels))
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
@@ -0,0 +1,125 @@
+package middlewares
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/PrinceNarteh/go-boilerplate/internal/errs"
+	"github.com/PrinceNarteh/go-boilerplate/internal/telemetry"
+)
+
+// unmatchedRoute is the route reported for requests that matched no route
+const unmatchedRoute = "unmatched"
+
+// routeKey is the context key for the matched route pattern of a request
+type routeKey struct{}
+
+// routeHolder is filled in by the router once the request is matched
+type routeHolder struct {
+	pattern string
+	metrics *telemetry.HTTPMetrics
+}
+
+// Metrics creates a middleware recording HTTP request metrics.
+// Requests are labelled with the matched route pattern, as reported by the
+// router through SetRoutePattern, so that paths with IDs do not create a
+// time series each. Requests canceled by their client are counted apart and
+// never recorded as server errors, see CanceledStatus.
+func Metrics(m *telemetry.HTTPMetrics) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			r, route := trackRoute(r)
+			route.metrics = m
+
+			method := MetricMethod(r.Method)
+			done := m.Start(r.Context(), method)
+			rw := &responseWriter{ResponseWriter: w, statusCode: http.StatusOK}
+
+			next.ServeHTTP(rw, r)
+
+			status, canceled := CanceledStatus(r, rw.statusCode)
+			if canceled {
+				m.RecordCanceled(r.Context(), method, route.pattern)
+			}
+			done(route.pattern, status)
+		})
+	}
+}
+
+// MetricMethod returns the method label of a request of method: the
+// method when it is a standard one, and "OTHER" otherwise, so that clients
+// cannot create a time series per made-up method
+func MetricMethod(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+		http.MethodDelete, http.MethodConnect, http.MethodOptions, http.MethodTrace:
+		return method
+	default:
+		return "OTHER"
+	}
+}
+
+// CanceledStatus returns the status to report for a request served with
+// status, and whether its client canceled it. Server errors of canceled
+// requests are caused by the cancellation, e.g. aborted queries, and are
+// reported as errs.StatusClientClosedRequest.
+func CanceledStatus(r *http.Request, status int) (int, bool) {
+	if !errs.ClientCanceled(r) {
+		return status, false
+	}
+	if status >= http.StatusInternalServerError {
+		status = errs.StatusClientClosedRequest
+	}
+	return status, true
+}
+
+// TrackRoute returns the request with a context in which the router records
+// the route pattern it matches, and a function returning that pattern once
+// the request is served, for middlewares labelling requests by route
+func TrackRoute(r *http.Request) (*http.Request, func() string) {
+	r, route := trackRoute(r)
+	return r, func() string { return route.pattern }
+}
+
+// trackRoute returns the request with the route holder of its context,
+// adding one if no middleware did already
+func trackRoute(r *http.Request) (*http.Request, *routeHolder) {
+	if route, ok := r.Context().Value(routeKey{}).(*routeHolder); ok {
+		return r, route
+	}
+	route := &routeHolder{pattern: unmatchedRoute}
+	return r.WithContext(context.WithValue(r.Context(), routeKey{}, route)), route
+}
+
+// SetRoutePattern records the route pattern that matched the request
+func SetRoutePattern(ctx context.Context, pattern string) {
+	if route, ok := ctx.Value(routeKey{}).(*routeHolder); ok {
+		route.pattern = pattern
+	}
+}
+
+// RecordBindingFailure records a request whose input could not be decoded
+// against its route, see telemetry.HTTPMetrics.RecordBindingFailure. It
+// does nothing without the Metrics middleware.
+func RecordBindingFailure(ctx context.Context, source, reason, field string) {
+	if route, ok := ctx.Value(routeKey{}).(*routeHolder); ok && route.metrics != nil {
+		route.metrics.RecordBindingFailure(ctx, route.pattern, source, reason, field)
+	}
+}
+
+// RecordValidationFailure records a request field failing a validation rule
+// against its route. It does nothing without the Metrics middleware.
+func RecordValidationFailure(ctx context.Context, field, rule string) {
+	if route, ok := ctx.Value(routeKey{}).(*routeHolder); ok && route.metrics != nil {
+		route.metrics.RecordValidationFailure(ctx, route.pattern, field, rule)
+	}
+}
+
+// RecordDeprecatedRoute records a request to a deprecated route alias,
+// served by the route of successor. It does nothing without the Metrics
+// middleware.
+func RecordDeprecatedRoute(ctx context.Context, successor string) {
+	if route, ok := ctx.Value(routeKey{}).(*routeHolder); ok && route.metrics != nil {
+		route.metrics.RecordDeprecatedRoute(ctx, route.pattern, successor)
+	}
+}
@@ -1,92 +1,172 @@
 package middlewares
 
 import (
+	"bufio"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net"
 	"net/http"
+	"runtime/debug"
 	"time"
 
 	"github.com/rs/zerolog"
+
+	"github.com/PrinceNarteh/go-boilerplate/internal/errs"
+	"github.com/PrinceNarteh/go-boilerplate/internal/telemetry"
 )
 
 // Middleware represents a middleware function
 type Middleware func(http.Handler) http.Handler
 
-// Logger creates a logging middleware
+// AccessLogOptions controls which requests the Logger middleware logs
+type AccessLogOptions struct {
+	// ExcludePaths are paths, such as health checks, whose successful
+	// requests are never logged. Server errors are still logged.
+	ExcludePaths []string
+	// SampleRates maps a status class (2 for 2xx, 5 for 5xx, ...) to the
+	// fraction of requests logged. Classes not listed are always logged.
+	SampleRates map[int]float64
+	// RouteSampleRates maps a route pattern, such as "/api/v1/users/{id}",
+	// to the fraction of its requests logged, overriding SampleRates for
+	// high-traffic routes. Server errors are sampled by SampleRates only.
+	RouteSampleRates map[string]float64
+	// SlowThreshold marks requests taking at least this long as slow.
+	// Slow requests are always logged, at warn level. Zero disables it.
+	SlowThreshold time.Duration
+	// SlowOnly logs only slow requests and server errors
+	SlowOnly bool
+}
+
+// Logger creates a logging middleware that logs every request with its
+// method, path, matched route pattern, status, response size, duration,
+// client IP, user agent and request ID.
+// It attaches a request-scoped logger carrying the request ID to the request
+// context, so handlers can log with zerolog.Ctx(r.Context()) and downstream
+// middleware can enrich the access log with UpdateContext.
 func Logger(logger *zerolog.Logger) Middleware {
+	return LoggerWithOptions(logger, AccessLogOptions{})
+}
+
+// LoggerWithOptions creates a logging middleware like Logger that only logs
+// the requests selected by opts. The request-scoped logger is attached to
+// every request, including those that are not logged.
+func LoggerWithOptions(logger *zerolog.Logger, opts AccessLogOptions) Middleware {
+	excluded := make(map[string]bool, len(opts.ExcludePaths))
+	for _, path := range opts.ExcludePaths {
+		excluded[path] = true
+	}
+
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			start := time.Now()
-			
-			// Create a response writer that captures status code
+
+			reqLogger := logger.With().Logger()
+			if id := GetRequestID(r.Context()); id != "" {
+				reqLogger = reqLogger.With().Str("request_id", id).Logger()
+			}
+			r, route := trackRoute(r)
+			ctx := reqLogger.WithContext(r.Context())
+
+			// Create a response writer that captures status code and size
 			rw := &responseWriter{ResponseWriter: w, statusCode: http.StatusOK}
-			
-			next.ServeHTTP(rw, r)
-			
-			logger.Info().
+
+			next.ServeHTTP(rw, r.WithContext(ctx))
+
+			duration := time.Since(start)
+			status, canceled := CanceledStatus(r, rw.statusCode)
+			slow := opts.SlowThreshold > 0 && duration >= opts.SlowThreshold
+			if !slow && !opts.shouldLog(status, route.pattern, excluded[r.URL.Path]) {
+				return
+			}
+
+			// Log through the context logger so fields added downstream are included
+			event := zerolog.Ctx(ctx).Info()
+			if slow {
+				event = zerolog.Ctx(ctx).Warn().Bool("slow", true)
+			}
+			if canceled {
+				event = event.Bool("canceled", true)
+			}
+			event.
 				Str("method", r.Method).
 				Str("path", r.URL.Path).
-				Str("remote_addr", r.RemoteAddr).
-				Int("status", rw.statusCode).
-				Dur("duration", time.Since(start)).
+				Str("route", route.pattern).
+				Int("status", status).
+				Int64("bytes", rw.written).
+				Dur("duration", duration).
+				Str("remote_ip", ClientIP(r)).
+				Str("user_agent", r.UserAgent()).
 				Msg("HTTP request")
 		})
 	}
 }
 
-// CORS creates a CORS middleware
-func CORS(allowedOrigins []string) Middleware {
-	return func(next http.Handler) http.Handler {
-		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			origin := r.Header.Get("Origin")
-			
-			// Check if origin is allowed (simplified version)
-			allowed := false
-			for _, allowedOrigin := range allowedOrigins {
-				if allowedOrigin == "*" || allowedOrigin == origin {
-					allowed = true
-					break
-				}
-			}
-			
-			if allowed {
-				w.Header().Set("Access-Control-Allow-Origin", origin)
-			}
-			
-			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
-			
-			if r.Method == "OPTIONS" {
-				w.WriteHeader(http.StatusNoContent)
-				return
-			}
-			
-			next.ServeHTTP(w, r)
-		})
+// shouldLog decides whether a request that is not slow is logged
+func (o AccessLogOptions) shouldLog(status int, route string, excluded bool) bool {
+	if status < http.StatusInternalServerError && (excluded || o.SlowOnly) {
+		return false
+	}
+
+	rate, ok := o.SampleRates[status/100]
+	if routeRate, found := o.RouteSampleRates[route]; found && status < http.StatusInternalServerError {
+		rate, ok = routeRate, true
+	}
+	if !ok || rate >= 1 {
+		return true
 	}
+	return rand.Float64() < rate
 }
 
-// Recovery creates a panic recovery middleware
+// Recovery creates a panic recovery middleware. The panic is logged with its
+// stack, reported to New Relic, and to Sentry when it is initialized, and the
+// client receives errs.ErrInternal in the standard envelope with the request
+// ID, never the panic itself. Nothing is written when the handler already
+// started its response. Panics with http.ErrAbortHandler, which abort a
+// response on purpose, are passed on to the server.
 func Recovery(logger *zerolog.Logger) Middleware {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			rw := &responseWriter{ResponseWriter: w, statusCode: http.StatusOK}
 			defer func() {
-				if err := recover(); err != nil {
-					logger.Error().
-						Interface("panic", err).
-						Str("method", r.Method).
-						Str("path", r.URL.Path).
-						Msg("Panic recovered")
-					
-					w.Header().Set("Content-Type", "application/json")
-					w.WriteHeader(http.StatusInternalServerError)
-					w.Write([]byte(`{"error":"Internal server error"}`))
+				p := recover()
+				if p == nil {
+					return
+				}
+				if err, ok := p.(error); ok && errors.Is(err, http.ErrAbortHandler) {
+					panic(p)
+				}
+
+				logger.Error().
+					Interface("panic", p).
+					Bytes("stack", debug.Stack()).
+					Str("request_id", GetRequestID(r.Context())).
+					Str("method", r.Method).
+					Str("path", r.URL.Path).
+					Str("route", routePattern(r)).
+					Msg("Panic recovered")
+				telemetry.ReportError(r.Context(), "http/"+MetricMethod(r.Method)+" "+routePattern(r), fmt.Errorf("panic: %v", p))
+				capturePanic(r, p)
+
+				if !rw.wroteHeader {
+					errs.WriteJSON(w, errs.ErrInternal)
 				}
 			}()
-			
-			next.ServeHTTP(w, r)
+
+			next.ServeHTTP(rw, r)
 		})
 	}
 }
 
+// routePattern returns the route matched for r, or unmatchedRoute when the
+// router did not match it yet
+func routePattern(r *http.Request) string {
+	if route, ok := r.Context().Value(routeKey{}).(*routeHolder); ok {
+		return route.pattern
+	}
+	return unmatchedRoute
+}
+
 // Chain chains multiple middlewares together
 func Chain(middlewares ...Middleware) Middleware {
 	return func(final http.Handler) http.Handler {
@@ -97,13 +177,40 @@ func Chain(middlewares ...Middleware) Middleware {
 	}
 }
 
-// responseWriter wraps http.ResponseWriter to capture status code
+// responseWriter wraps http.ResponseWriter to capture the status code and
+// the number of bytes written
 type responseWriter struct {
 	http.ResponseWriter
-	statusCode int
+	statusCode  int
+	written     int64
+	wroteHeader bool
 }
 
 func (rw *responseWriter) WriteHeader(code int) {
 	rw.statusCode = code
+	rw.wroteHeader = true
 	rw.ResponseWriter.WriteHeader(code)
 }
+
+func (rw *responseWriter) Write(b []byte) (int, error) {
+	rw.wroteHeader = true
+	n, err := rw.ResponseWriter.Write(b)
+	rw.written += int64(n)
+	return n, err
+}
+
+// Hijack implements http.Hijacker, so connections can be upgraded to
+// WebSocket through the middleware
+func (rw *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, buf, err := http.NewResponseController(rw.ResponseWriter).Hijack()
+	if err == nil {
+		rw.statusCode = http.StatusSwitchingProtocols
+		rw.wroteHeader = true
+	}
+	return conn, buf, err
+}
+
+// Unwrap returns the underlying ResponseWriter, for http.ResponseController
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
@@ -0,0 +1,195 @@
+package middlewares
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/PrinceNarteh/go-boilerplate/internal/auth"
+	"github.com/PrinceNarteh/go-boilerplate/internal/errs"
+)
+
+// RateLimitAlgorithm selects how requests are counted against a limit
+type RateLimitAlgorithm string
+
+// Supported rate limit algorithms
+const (
+	// TokenBucket allows bursts up to Limit and refills Limit tokens per Window
+	TokenBucket RateLimitAlgorithm = "token_bucket"
+	// SlidingWindow allows at most Limit requests in any rolling Window
+	SlidingWindow RateLimitAlgorithm = "sliding_window"
+)
+
+// RateLimitRule describes the limit applied to a single key
+type RateLimitRule struct {
+	Algorithm RateLimitAlgorithm
+	Limit     int
+	Window    time.Duration
+}
+
+// RateLimitResult is the outcome of a single rate limit check
+type RateLimitResult struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	ResetAfter time.Duration
+	RetryAfter time.Duration
+}
+
+// RateLimitStore records request counts for rate limit keys.
+// Implementations must be safe for concurrent use.
+type RateLimitStore interface {
+	Allow(ctx context.Context, key string, rule RateLimitRule) (RateLimitResult, error)
+}
+
+// KeyFunc extracts the rate limit key from a request.
+// Returning an empty key skips rate limiting for the request.
+type KeyFunc func(r *http.Request) string
+
+// RateLimitOptions configures the RateLimit middleware
+type RateLimitOptions struct {
+	Store   RateLimitStore
+	Rule    RateLimitRule
+	KeyFunc KeyFunc
+	// Logger logs the failures of Store, discarded when nil
+	Logger *zerolog.Logger
+}
+
+// rateLimitKey is the context key for the rate limit state of a request
+type rateLimitKey struct{}
+
+// RateLimitState is the rate limit applied to a request and its outcome
+type RateLimitState struct {
+	Rule   RateLimitRule
+	Result RateLimitResult
+}
+
+// RateLimitHeaders lists the response headers set by RateLimit,
+// which CORS exposes to browser clients
+var RateLimitHeaders = []string{"X-RateLimit-Limit", "X-RateLimit-Remaining", "X-RateLimit-Reset", "Retry-After"}
+
+// RateLimit creates a rate limiting middleware.
+// Every limited response carries the X-RateLimit-* headers so that clients
+// can pace themselves, and the state is available to handlers through
+// RateLimitFromContext. Requests over the limit are rejected with
+// errs.ErrTooManyRequests and a Retry-After header. Store failures are logged
+// and the request is allowed through so that a broken store does not take
+// down the API.
+func RateLimit(opts RateLimitOptions) Middleware {
+	keyFunc := opts.KeyFunc
+	if keyFunc == nil {
+		keyFunc = KeyByIP()
+	}
+	if opts.Logger == nil {
+		nop := zerolog.Nop()
+		opts.Logger = &nop
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := keyFunc(r)
+			if key == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			result, err := opts.Store.Allow(r.Context(), key, opts.Rule)
+			if err != nil {
+				opts.Logger.Warn().
+					Err(err).
+					Str("key", key).
+					Msg("Rate limit store unavailable, allowing request")
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			setRateLimitHeaders(w, result)
+			if !result.Allowed {
+				w.Header().Set("Retry-After", strconv.Itoa(ceilSeconds(result.RetryAfter)))
+				errs.WriteJSON(w, errs.ErrTooManyRequests)
+				return
+			}
+
+			ctx := context.WithValue(r.Context(), rateLimitKey{}, RateLimitState{Rule: opts.Rule, Result: result})
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// RateLimitFromContext returns the rate limit state of the request,
+// or false when the request was not rate limited
+func RateLimitFromContext(ctx context.Context) (RateLimitState, bool) {
+	state, ok := ctx.Value(rateLimitKey{}).(RateLimitState)
+	return state, ok
+}
+
+// KeyByIP keys requests by the client IP address
+func KeyByIP() KeyFunc {
+	return func(r *http.Request) string {
+		return "ip:" + ClientIP(r)
+	}
+}
+
+// KeyByAPIKey keys requests by the value of the given API key header,
+// falling back to the client IP when the header is absent.
+func KeyByAPIKey(header string) KeyFunc {
+	return func(r *http.Request) string {
+		if key := r.Header.Get(header); key != "" {
+			return "key:" + key
+		}
+		return "ip:" + ClientIP(r)
+	}
+}
+
+// KeyByUserID keys requests by the authenticated user ID returned by lookup,
+// falling back to the client IP for anonymous requests.
+func KeyByUserID(lookup func(ctx context.Context) (string, bool)) KeyFunc {
+	return func(r *http.Request) string {
+		if id, ok := lookup(r.Context()); ok && id != "" {
+			return "user:" + id
+		}
+		return "ip:" + ClientIP(r)
+	}
+}
+
+// KeyByToken keys requests like KeyByUserID by the user of their bearer
+// token, verified with tokens, for limiters running before the routes
+// authenticate requests. The principal of the token is not loaded.
+func KeyByToken(tokens *auth.TokenManager) KeyFunc {
+	lookup := func(r *http.Request) (string, bool) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || token == "" {
+			return "", false
+		}
+		principal, err := tokens.Verify(token)
+		if err != nil {
+			return "", false
+		}
+		return strconv.Itoa(principal.UserID), true
+	}
+
+	return func(r *http.Request) string {
+		if id, ok := lookup(r); ok {
+			return "user:" + id
+		}
+		return "ip:" + ClientIP(r)
+	}
+}
+
+// setRateLimitHeaders writes the X-RateLimit-* headers for a result.
+// X-RateLimit-Reset is the Unix time at which the limit fully resets.
+func setRateLimitHeaders(w http.ResponseWriter, result RateLimitResult) {
+	w.Header().Set("X-RateLimit-Limit", strconv.Itoa(result.Limit))
+	w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(result.Remaining))
+	w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(time.Now().Add(result.ResetAfter).Unix(), 10))
+}
+
+// ceilSeconds rounds a duration up to whole seconds, with a minimum of one
+func ceilSeconds(d time.Duration) int {
+	return max(1, int(math.Ceil(d.Seconds())))
+}
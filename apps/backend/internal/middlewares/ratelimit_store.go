@@ -0,0 +1,346 @@
+package middlewares
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/PrinceNarteh/go-boilerplate/internal/libs/id"
+)
+
+// memorySweepInterval is how often the memory store evicts idle keys
+const memorySweepInterval = time.Minute
+
+// MemoryRateLimitStore keeps rate limit state in process memory.
+// It is intended for development and single-instance deployments.
+type MemoryRateLimitStore struct {
+	mu        sync.Mutex
+	buckets   map[string]*memoryBucket
+	lastSweep time.Time
+}
+
+// memoryBucket holds the state of a single key for either algorithm
+type memoryBucket struct {
+	tokens    float64
+	updatedAt time.Time
+	window    int64
+	prev      int64
+	cur       int64
+	expiresAt time.Time
+}
+
+// NewMemoryRateLimitStore creates a new in-memory rate limit store
+func NewMemoryRateLimitStore() *MemoryRateLimitStore {
+	return &MemoryRateLimitStore{
+		buckets:   make(map[string]*memoryBucket),
+		lastSweep: time.Now(),
+	}
+}
+
+// Allow implements RateLimitStore
+func (s *MemoryRateLimitStore) Allow(_ context.Context, key string, rule RateLimitRule) (RateLimitResult, error) {
+	now := time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sweep(now)
+
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &memoryBucket{tokens: float64(rule.Limit), updatedAt: now}
+		s.buckets[key] = b
+	}
+	b.expiresAt = now.Add(2 * rule.Window)
+
+	switch rule.Algorithm {
+	case SlidingWindow:
+		index, elapsed := windowPosition(now, rule.Window)
+		switch index - b.window {
+		case 0:
+		case 1:
+			b.prev, b.cur = b.cur, 0
+		default:
+			b.prev, b.cur = 0, 0
+		}
+		b.window = index
+
+		allowed := slidingWindowAllows(rule, b.prev, b.cur, elapsed)
+		if allowed {
+			b.cur++
+		}
+		return slidingWindowResult(rule, allowed, b.prev, b.cur, elapsed), nil
+	default:
+		b.tokens = math.Min(float64(rule.Limit), b.tokens+now.Sub(b.updatedAt).Seconds()*tokenRate(rule))
+		b.updatedAt = now
+
+		allowed := b.tokens >= 1
+		if allowed {
+			b.tokens--
+		}
+		return tokenBucketResult(rule, allowed, b.tokens), nil
+	}
+}
+
+// sweep evicts keys that have been idle for longer than their window
+func (s *MemoryRateLimitStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < memorySweepInterval {
+		return
+	}
+	for key, b := range s.buckets {
+		if now.After(b.expiresAt) {
+			delete(s.buckets, key)
+		}
+	}
+	s.lastSweep = now
+}
+
+// tokenBucketScript refills and takes a token atomically.
+// It returns whether the request is allowed and the tokens left as a string,
+// since Lua numbers are truncated to integers when returned to the client.
+var tokenBucketScript = redis.NewScript(`
+local capacity = tonumber(ARGV[1])
+local rate = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(state[1]) or capacity
+local ts = tonumber(state[2]) or now
+tokens = math.min(capacity, tokens + math.max(0, now - ts) / 1000 * rate)
+local allowed = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+end
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'ts', now)
+redis.call('PEXPIRE', KEYS[1], ARGV[4])
+return {allowed, tostring(tokens)}
+`)
+
+// slidingWindowScript counts a request against the current window
+// unless the weighted count of the previous and current windows is exhausted.
+var slidingWindowScript = redis.NewScript(`
+local prev = tonumber(redis.call('GET', KEYS[1]) or '0')
+local cur = tonumber(redis.call('GET', KEYS[2]) or '0')
+if prev * tonumber(ARGV[2]) + cur + 1 > tonumber(ARGV[1]) then
+	return {0, prev, cur}
+end
+cur = redis.call('INCR', KEYS[2])
+redis.call('PEXPIRE', KEYS[2], ARGV[3])
+return {1, prev, cur}
+`)
+
+// RedisRateLimitStore keeps rate limit state in Redis so that limits
+// are shared across all instances of the application.
+type RedisRateLimitStore struct {
+	client redis.Scripter
+	prefix string
+}
+
+// NewRedisRateLimitStore creates a new Redis-backed rate limit store.
+// All keys are namespaced under prefix.
+func NewRedisRateLimitStore(client redis.Scripter, prefix string) *RedisRateLimitStore {
+	return &RedisRateLimitStore{
+		client: client,
+		prefix: prefix,
+	}
+}
+
+// Allow implements RateLimitStore
+func (s *RedisRateLimitStore) Allow(ctx context.Context, key string, rule RateLimitRule) (RateLimitResult, error) {
+	now := time.Now()
+	ttl := (2 * rule.Window).Milliseconds()
+
+	switch rule.Algorithm {
+	case SlidingWindow:
+		index, elapsed := windowPosition(now, rule.Window)
+		keys := []string{
+			fmt.Sprintf("%s{%s}:%d", s.prefix, key, index-1),
+			fmt.Sprintf("%s{%s}:%d", s.prefix, key, index),
+		}
+		weight := strconv.FormatFloat(previousWindowWeight(rule, elapsed), 'f', -1, 64)
+
+		values, err := slidingWindowScript.Run(ctx, s.client, keys, rule.Limit, weight, ttl).Int64Slice()
+		if err != nil {
+			return RateLimitResult{}, fmt.Errorf("failed to run sliding window script: %w", err)
+		}
+		return slidingWindowResult(rule, values[0] == 1, values[1], values[2], elapsed), nil
+	default:
+		values, err := tokenBucketScript.Run(ctx, s.client, []string{s.prefix + key},
+			rule.Limit, tokenRate(rule), now.UnixMilli(), ttl).Slice()
+		if err != nil {
+			return RateLimitResult{}, fmt.Errorf("failed to run token bucket script: %w", err)
+		}
+		allowed, _ := values[0].(int64)
+		tokens, err := strconv.ParseFloat(fmt.Sprint(values[1]), 64)
+		if err != nil {
+			return RateLimitResult{}, fmt.Errorf("failed to parse token bucket state: %w", err)
+		}
+		return tokenBucketResult(rule, allowed == 1, tokens), nil
+	}
+}
+
+// tokenRate returns the token refill rate per second
+func tokenRate(rule RateLimitRule) float64 {
+	return float64(rule.Limit) / rule.Window.Seconds()
+}
+
+// tokenBucketResult builds the result of a token bucket check
+func tokenBucketResult(rule RateLimitRule, allowed bool, tokens float64) RateLimitResult {
+	rate := tokenRate(rule)
+	result := RateLimitResult{
+		Allowed:    allowed,
+		Limit:      rule.Limit,
+		Remaining:  int(math.Floor(tokens)),
+		ResetAfter: secondsToDuration((float64(rule.Limit) - tokens) / rate),
+	}
+	if !allowed {
+		result.RetryAfter = secondsToDuration((1 - tokens) / rate)
+	}
+	return result
+}
+
+// windowPosition returns the index of the fixed window containing now
+// and how far into that window now is
+func windowPosition(now time.Time, window time.Duration) (int64, time.Duration) {
+	nanos := now.UnixNano()
+	return nanos / int64(window), time.Duration(nanos % int64(window))
+}
+
+// previousWindowWeight returns how much of the previous window still
+// overlaps the rolling window ending now
+func previousWindowWeight(rule RateLimitRule, elapsed time.Duration) float64 {
+	return float64(rule.Window-elapsed) / float64(rule.Window)
+}
+
+// slidingWindowAllows reports whether one more request fits in the rolling window
+func slidingWindowAllows(rule RateLimitRule, prev, cur int64, elapsed time.Duration) bool {
+	return float64(prev)*previousWindowWeight(rule, elapsed)+float64(cur)+1 <= float64(rule.Limit)
+}
+
+// slidingWindowResult builds the result of a sliding window check
+func slidingWindowResult(rule RateLimitRule, allowed bool, prev, cur int64, elapsed time.Duration) RateLimitResult {
+	estimated := float64(prev)*previousWindowWeight(rule, elapsed) + float64(cur)
+	result := RateLimitResult{
+		Allowed:    allowed,
+		Limit:      rule.Limit,
+		Remaining:  max(0, int(math.Floor(float64(rule.Limit)-estimated))),
+		ResetAfter: 2*rule.Window - elapsed,
+	}
+	if allowed {
+		return result
+	}
+
+	// Wait for the next window if the current one alone is exhausted,
+	// otherwise until enough of the previous window has slid out.
+	if prev == 0 || cur+1 > int64(rule.Limit) {
+		result.RetryAfter = rule.Window - elapsed
+		return result
+	}
+	weight := float64(int64(rule.Limit)-1-cur) / float64(prev)
+	result.RetryAfter = max(0, time.Duration((1-weight)*float64(rule.Window))-elapsed)
+	return result
+}
+
+// secondsToDuration converts fractional seconds to a duration
+func secondsToDuration(seconds float64) time.Duration {
+	return time.Duration(seconds * float64(time.Second))
+}
+
+// MemoryConcurrencyStore counts requests in flight in process memory.
+// It is intended for development and single-instance deployments.
+type MemoryConcurrencyStore struct {
+	mu       sync.Mutex
+	inFlight map[string]int
+}
+
+// NewMemoryConcurrencyStore creates a new in-memory concurrency store
+func NewMemoryConcurrencyStore() *MemoryConcurrencyStore {
+	return &MemoryConcurrencyStore{inFlight: make(map[string]int)}
+}
+
+// Acquire implements ConcurrencyStore. Slots are held until released, the
+// lease only matters for the slots of other instances.
+func (s *MemoryConcurrencyStore) Acquire(_ context.Context, key string, limit int, _ time.Duration) (func(), bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.inFlight[key] >= limit {
+		return nil, false, nil
+	}
+	s.inFlight[key]++
+
+	var once sync.Once
+	release := func() {
+		once.Do(func() {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			if s.inFlight[key]--; s.inFlight[key] <= 0 {
+				delete(s.inFlight, key)
+			}
+		})
+	}
+	return release, true, nil
+}
+
+// acquireSlotScript takes a slot of a sorted set of leases scored by their
+// expiry, after dropping the expired leases of crashed requests.
+// ARGV: limit, now (ms), lease (ms), token
+var acquireSlotScript = redis.NewScript(`
+redis.call('ZREMRANGEBYSCORE', KEYS[1], '-inf', ARGV[2])
+if redis.call('ZCARD', KEYS[1]) >= tonumber(ARGV[1]) then
+  return 0
+end
+redis.call('ZADD', KEYS[1], tonumber(ARGV[2]) + tonumber(ARGV[3]), ARGV[4])
+redis.call('PEXPIRE', KEYS[1], ARGV[3])
+return 1
+`)
+
+// releaseSlotScript gives a slot back. ARGV: token
+var releaseSlotScript = redis.NewScript(`
+return redis.call('ZREM', KEYS[1], ARGV[1])
+`)
+
+// RedisConcurrencyStore counts requests in flight in Redis so that caps
+// are shared across all instances of the application.
+type RedisConcurrencyStore struct {
+	client redis.Scripter
+	prefix string
+}
+
+// NewRedisConcurrencyStore creates a new Redis-backed concurrency store.
+// All keys are namespaced under prefix.
+func NewRedisConcurrencyStore(client redis.Scripter, prefix string) *RedisConcurrencyStore {
+	return &RedisConcurrencyStore{
+		client: client,
+		prefix: prefix,
+	}
+}
+
+// Acquire implements ConcurrencyStore
+func (s *RedisConcurrencyStore) Acquire(ctx context.Context, key string, limit int, lease time.Duration) (func(), bool, error) {
+	key = s.prefix + key
+	token := id.NewString()
+
+	acquired, err := acquireSlotScript.Run(ctx, s.client, []string{key},
+		limit, time.Now().UnixMilli(), lease.Milliseconds(), token).Int()
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to run acquire slot script: %w", err)
+	}
+	if acquired == 0 {
+		return nil, false, nil
+	}
+
+	release := func() {
+		// the request may have been canceled, the slot must be given back
+		// regardless, or it stays taken until its lease expires
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), time.Second)
+		defer cancel()
+		_ = releaseSlotScript.Run(ctx, s.client, []string{key}, token).Err()
+	}
+	return release, true, nil
+}
@@ -0,0 +1,62 @@
+package middlewares
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/PrinceNarteh/go-boilerplate/internal/libs/id"
+)
+
+// RequestIDHeader is the header used to receive and return request IDs
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength is the longest incoming request ID that is honored
+const maxRequestIDLength = 128
+
+// requestIDKey is the context key for the request ID
+type requestIDKey struct{}
+
+// RequestID creates a middleware that assigns every request an ID.
+// A well-formed incoming X-Request-ID header is reused so IDs can be
+// correlated across services; otherwise a new ULID is generated. The ID is
+// stored in the request context and returned in the X-Request-ID response header.
+func RequestID() Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requestID := r.Header.Get(RequestIDHeader)
+			if !validRequestID(requestID) {
+				requestID = id.NewString()
+			}
+
+			w.Header().Set(RequestIDHeader, requestID)
+			next.ServeHTTP(w, r.WithContext(WithRequestID(r.Context(), requestID)))
+		})
+	}
+}
+
+// WithRequestID returns a copy of ctx carrying the request ID
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// GetRequestID returns the request ID stored in ctx, or an empty string
+func GetRequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// validRequestID reports whether an incoming request ID is safe to reuse
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case c == '-', c == '_', c == '.', c == ':':
+		default:
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,56 @@
+package middlewares
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/PrinceNarteh/go-boilerplate/internal/config"
+)
+
+// SecurityHeaders creates a middleware that sets HTTP security headers
+// such as Content-Security-Policy and Strict-Transport-Security.
+// If cfg is nil, config.DefaultSecurityHeadersConfig is used.
+func SecurityHeaders(cfg *config.SecurityHeadersConfig) Middleware {
+	if cfg == nil {
+		cfg = config.DefaultSecurityHeadersConfig()
+	}
+
+	// Build the header set once instead of on every request
+	headers := make(map[string]string)
+	if cfg.ContentSecurityPolicy != "" {
+		headers["Content-Security-Policy"] = cfg.ContentSecurityPolicy
+	}
+	if cfg.HSTSMaxAge > 0 {
+		hsts := []string{"max-age=" + strconv.Itoa(cfg.HSTSMaxAge)}
+		if cfg.HSTSIncludeSubdomains {
+			hsts = append(hsts, "includeSubDomains")
+		}
+		if cfg.HSTSPreload {
+			hsts = append(hsts, "preload")
+		}
+		headers["Strict-Transport-Security"] = strings.Join(hsts, "; ")
+	}
+	if cfg.FrameOptions != "" {
+		headers["X-Frame-Options"] = cfg.FrameOptions
+	}
+	if cfg.ReferrerPolicy != "" {
+		headers["Referrer-Policy"] = cfg.ReferrerPolicy
+	}
+	if cfg.PermissionsPolicy != "" {
+		headers["Permissions-Policy"] = cfg.PermissionsPolicy
+	}
+	if cfg.ContentTypeNosniff {
+		headers["X-Content-Type-Options"] = "nosniff"
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			for name, value := range headers {
+				w.Header().Set(name, value)
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,48 @@
+package middlewares
+
+import (
+	"net/http"
+
+	"github.com/getsentry/sentry-go"
+
+	"github.com/PrinceNarteh/go-boilerplate/internal/auth"
+)
+
+// CaptureError reports err, the 5xx error of a request, to Sentry with the
+// request, its ID, its route and its user. It does nothing when Sentry is
+// not initialized, see telemetry.InitSentry.
+func CaptureError(r *http.Request, err error) {
+	if hub := requestHub(r); hub != nil {
+		hub.CaptureException(err)
+	}
+}
+
+// capturePanic reports a panic recovered while serving a request to Sentry
+func capturePanic(r *http.Request, p any) {
+	if hub := requestHub(r); hub != nil {
+		hub.RecoverWithContext(r.Context(), p)
+	}
+}
+
+// requestHub returns a Sentry hub scoped to a request, or nil when Sentry
+// is not initialized. Authorization and cookie headers are left out of the
+// request reported.
+func requestHub(r *http.Request) *sentry.Hub {
+	if sentry.CurrentHub().Client() == nil {
+		return nil
+	}
+
+	hub := sentry.CurrentHub().Clone()
+	scope := hub.Scope()
+	scope.SetRequest(r)
+	if requestID := GetRequestID(r.Context()); requestID != "" {
+		scope.SetTag("request_id", requestID)
+	}
+	if route, ok := r.Context().Value(routeKey{}).(*routeHolder); ok {
+		scope.SetTag("route", route.pattern)
+	}
+	if userID, ok := auth.UserIDFromContext(r.Context()); ok {
+		scope.SetUser(sentry.User{ID: userID})
+	}
+	return hub
+}
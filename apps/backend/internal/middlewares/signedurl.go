@@ -0,0 +1,35 @@
+package middlewares
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/PrinceNarteh/go-boilerplate/internal/errs"
+	"github.com/PrinceNarteh/go-boilerplate/internal/libs/signedurl"
+)
+
+// errLinkExpired is returned for signed URLs past their expiry
+var errLinkExpired = errs.New(errs.ErrCodeForbidden, "Link has expired", http.StatusForbidden)
+
+// errLinkInvalid is returned for unsigned or altered URLs
+var errLinkInvalid = errs.New(errs.ErrCodeForbidden, "Link is invalid", http.StatusForbidden)
+
+// RequireSignedURL only lets through requests whose URL was signed by signer
+// and has not expired. It replaces authentication on routes reached from
+// links, such as downloads or unsubscribe links.
+func RequireSignedURL(signer *signedurl.Signer) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if err := signer.Verify(r.URL); err != nil {
+				if errors.Is(err, signedurl.ErrExpired) {
+					errs.WriteJSON(w, errLinkExpired)
+				} else {
+					errs.WriteJSON(w, errLinkInvalid)
+				}
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,48 @@
+package middlewares
+
+import (
+	"context"
+
+	"github.com/rs/zerolog"
+
+	"github.com/PrinceNarteh/go-boilerplate/internal/auth"
+	"github.com/PrinceNarteh/go-boilerplate/internal/database"
+	"github.com/PrinceNarteh/go-boilerplate/internal/errs"
+)
+
+// defaultTenantHeader is the request header carrying the tenant ID
+const defaultTenantHeader = "X-Tenant-ID"
+
+// TenantResolver returns the database schema of a tenant, implemented by
+// *services.TenantService
+type TenantResolver interface {
+	TenantSchema(ctx context.Context, id string) (string, error)
+}
+
+// tenantContext returns ctx running the queries of principal in the schema
+// of its tenant (see database.WithSchema), the tenant its token was issued
+// in. requested is the tenant named by the client, if any, which must be
+// the tenant of the token: tokens are only accepted in their own tenant.
+// The tenant is added to the request logger.
+func tenantContext(ctx context.Context, resolver TenantResolver, principal *auth.Principal, requested string) (context.Context, error) {
+	if requested != "" && requested != principal.TenantID {
+		return nil, errs.ErrForbidden
+	}
+	if principal.TenantID == "" {
+		return ctx, nil
+	}
+	if resolver == nil {
+		// Tenancy is disabled, so the tenant of the token cannot be honored
+		return nil, errs.ErrForbidden
+	}
+
+	schema, err := resolver.TenantSchema(ctx, principal.TenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	zerolog.Ctx(ctx).UpdateContext(func(c zerolog.Context) zerolog.Context {
+		return c.Str("tenant", principal.TenantID)
+	})
+	return database.WithSchema(ctx, schema), nil
+}
@@ -0,0 +1,156 @@
+package middlewares
+
+import (
+	"cmp"
+	"context"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/PrinceNarteh/go-boilerplate/internal/auth"
+	"github.com/PrinceNarteh/go-boilerplate/internal/errs"
+)
+
+// defaultThrottleLease is how long a concurrency slot is held at most when
+// Throttle.Lease is not set, so slots of crashed instances are reclaimed
+const defaultThrottleLease = 5 * time.Minute
+
+// Throttle declares a limit stricter than the global rate limit for an
+// expensive operation, such as an export or a password reset, applied to
+// every user, or client IP for anonymous requests, separately. At most Limit
+// requests are allowed per Window, and at most Concurrency are served at
+// once. A zero Limit or Concurrency disables that limit.
+type Throttle struct {
+	// Name identifies the throttle in its keys and logs, such as "users.export"
+	Name        string
+	Limit       int
+	Window      time.Duration
+	Concurrency int
+	// Lease bounds how long a request holds its concurrency slot, 5 minutes
+	// when zero
+	Lease time.Duration
+}
+
+var (
+	throttlesMu sync.RWMutex
+	throttles   = make(map[string]*Throttle)
+)
+
+// DeclareThrottle declares a throttle, typically in a package-level
+// variable of the handler it applies to, which registers its routes with
+// Throttle.Middleware. It panics when the name is empty or declared twice.
+func DeclareThrottle(t Throttle) *Throttle {
+	if t.Name == "" {
+		panic("middlewares: throttle name must not be empty")
+	}
+
+	throttlesMu.Lock()
+	defer throttlesMu.Unlock()
+
+	if _, dup := throttles[t.Name]; dup {
+		panic("middlewares: throttle " + t.Name + " declared twice")
+	}
+	throttles[t.Name] = &t
+	return &t
+}
+
+// Throttles returns the declared throttles sorted by name
+func Throttles() []*Throttle {
+	throttlesMu.RLock()
+	defer throttlesMu.RUnlock()
+
+	list := make([]*Throttle, 0, len(throttles))
+	for _, t := range throttles {
+		list = append(list, t)
+	}
+	slices.SortFunc(list, func(a, b *Throttle) int { return cmp.Compare(a.Name, b.Name) })
+	return list
+}
+
+// ConcurrencyStore counts the requests in flight per key.
+// Implementations must be safe for concurrent use.
+type ConcurrencyStore interface {
+	// Acquire takes one of the limit slots of key, held until release is
+	// called or lease expires. It reports false when every slot is taken.
+	Acquire(ctx context.Context, key string, limit int, lease time.Duration) (release func(), ok bool, err error)
+}
+
+// ThrottleRegistry holds the counters shared by every throttle, in Redis
+// when the application runs several instances
+type ThrottleRegistry struct {
+	limits RateLimitStore
+	slots  ConcurrencyStore
+	logger *zerolog.Logger
+}
+
+// NewThrottleRegistry creates a registry counting requests in limits and
+// requests in flight in slots
+func NewThrottleRegistry(limits RateLimitStore, slots ConcurrencyStore, logger *zerolog.Logger) *ThrottleRegistry {
+	return &ThrottleRegistry{limits: limits, slots: slots, logger: logger}
+}
+
+// throttleRegistry is the registry enforcing throttles, in memory until
+// SetThrottleRegistry is called
+var throttleRegistry atomic.Pointer[ThrottleRegistry]
+
+func init() {
+	nop := zerolog.Nop()
+	throttleRegistry.Store(NewThrottleRegistry(NewMemoryRateLimitStore(), NewMemoryConcurrencyStore(), &nop))
+}
+
+// SetThrottleRegistry sets the registry enforcing every throttle
+func SetThrottleRegistry(r *ThrottleRegistry) {
+	throttleRegistry.Store(r)
+}
+
+// Middleware creates the middleware enforcing the throttle through the
+// registry set with SetThrottleRegistry. It must run after Authenticate to
+// throttle users rather than IPs. Requests over a limit are rejected with
+// errs.ErrTooManyRequests and a Retry-After header. Store failures are logged
+// and the request is allowed through, as with RateLimit.
+func (t *Throttle) Middleware() Middleware {
+	keyFunc := KeyByUserID(auth.UserIDFromContext)
+	lease := cmp.Or(t.Lease, defaultThrottleLease)
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			registry := throttleRegistry.Load()
+			key := t.Name + ":" + keyFunc(r)
+			log := registry.logger.With().Str("throttle", t.Name).Str("key", key).Logger()
+
+			if t.Limit > 0 {
+				rule := RateLimitRule{Algorithm: SlidingWindow, Limit: t.Limit, Window: t.Window}
+				result, err := registry.limits.Allow(r.Context(), key, rule)
+				switch {
+				case err != nil:
+					log.Warn().Err(err).Msg("Throttle store unavailable, allowing request")
+				case !result.Allowed:
+					w.Header().Set("Retry-After", strconv.Itoa(ceilSeconds(result.RetryAfter)))
+					errs.WriteJSON(w, errs.ErrTooManyRequests)
+					return
+				}
+			}
+
+			if t.Concurrency > 0 {
+				release, ok, err := registry.slots.Acquire(r.Context(), key, t.Concurrency, lease)
+				switch {
+				case err != nil:
+					log.Warn().Err(err).Msg("Throttle store unavailable, allowing request")
+				case !ok:
+					w.Header().Set("Retry-After", "1")
+					errs.WriteJSON(w, errs.ErrTooManyRequests)
+					return
+				default:
+					defer release()
+				}
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
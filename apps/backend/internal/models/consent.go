@@ -0,0 +1,53 @@
+package models
+
+import (
+	"time"
+
+	"github.com/PrinceNarteh/go-boilerplate/internal/anonymize"
+)
+
+// ConsentDocument is a published version of a legal document users must accept
+type ConsentDocument struct {
+	ID          int       `json:"id" db:"id"`
+	Kind        string    `json:"kind" db:"kind"`
+	Version     string    `json:"version" db:"version"`
+	URL         string    `json:"url" db:"url"`
+	PublishedAt time.Time `json:"published_at" db:"published_at"`
+}
+
+// UserConsentAnonymization masks where consents were given from in staging
+// snapshots
+var UserConsentAnonymization = anonymize.Register("user_consents", anonymize.Columns{
+	"ip":         anonymize.IP,
+	"user_agent": anonymize.Fixed(""),
+})
+
+// UserConsent records a user's acceptance of a consent document
+type UserConsent struct {
+	ID         int       `json:"id" db:"id"`
+	UserID     int       `json:"user_id" db:"user_id"`
+	DocumentID int       `json:"document_id" db:"document_id"`
+	Kind       string    `json:"kind" db:"kind"`
+	Version    string    `json:"version" db:"version"`
+	IP         string    `json:"ip" db:"ip"`
+	UserAgent  string    `json:"user_agent" db:"user_agent"`
+	AcceptedAt time.Time `json:"accepted_at" db:"accepted_at"`
+}
+
+// PublishConsentDocumentRequest represents the request payload for publishing a document version
+type PublishConsentDocumentRequest struct {
+	Kind    string `json:"kind" validate:"required,max=64"`
+	Version string `json:"version" validate:"required,max=64"`
+	URL     string `json:"url" validate:"required,url"`
+}
+
+// AcceptConsentRequest represents the request payload for accepting a document
+type AcceptConsentRequest struct {
+	DocumentID int `json:"document_id" validate:"required,gt=0"`
+}
+
+// ConsentStatusResponse represents a user's accepted and pending documents
+type ConsentStatusResponse struct {
+	Accepted []*UserConsent     `json:"accepted"`
+	Pending  []*ConsentDocument `json:"pending"`
+}
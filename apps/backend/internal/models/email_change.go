@@ -0,0 +1,64 @@
+package models
+
+import (
+	"context"
+	"time"
+
+	"github.com/PrinceNarteh/go-boilerplate/internal/anonymize"
+	"github.com/PrinceNarteh/go-boilerplate/internal/i18n"
+)
+
+// EmailChangeStatus represents the lifecycle status of an email change
+type EmailChangeStatus string
+
+// Email change statuses
+const (
+	EmailChangePending   EmailChangeStatus = "pending"
+	EmailChangeConfirmed EmailChangeStatus = "confirmed"
+	EmailChangeCancelled EmailChangeStatus = "cancelled"
+	EmailChangeReverted  EmailChangeStatus = "reverted"
+)
+
+// EmailChangeStatusLabels translates email change statuses
+var EmailChangeStatusLabels = i18n.RegisterEnum("email_change_status",
+	EmailChangePending, EmailChangeConfirmed, EmailChangeCancelled, EmailChangeReverted,
+)
+
+// EmailChangeAnonymization masks the addresses and tokens of email changes
+// in staging snapshots
+var EmailChangeAnonymization = anonymize.Register("email_changes", anonymize.Columns{
+	"old_email":         anonymize.Email,
+	"new_email":         anonymize.Email,
+	"token_hash":        anonymize.Token,
+	"revert_token_hash": anonymize.Token,
+})
+
+// EmailChange is a request to change a user's email address
+type EmailChange struct {
+	ID          int               `json:"id" db:"id"`
+	UserID      int               `json:"user_id" db:"user_id"`
+	OldEmail    string            `json:"old_email" db:"old_email"`
+	NewEmail    string            `json:"new_email" db:"new_email"`
+	Status      EmailChangeStatus `json:"status" db:"status"`
+	StatusLabel string            `json:"status_label,omitempty" db:"-"`
+	ExpiresAt   time.Time         `json:"expires_at" db:"expires_at"`
+	ConfirmedAt *time.Time        `json:"confirmed_at,omitempty" db:"confirmed_at"`
+	RevertUntil *time.Time        `json:"revert_until,omitempty" db:"revert_until"`
+	RevertedAt  *time.Time        `json:"reverted_at,omitempty" db:"reverted_at"`
+	CreatedAt   time.Time         `json:"created_at" db:"created_at"`
+}
+
+// Localize implements i18n.Localizable
+func (c *EmailChange) Localize(ctx context.Context) {
+	c.StatusLabel = EmailChangeStatusLabels.Label(ctx, c.Status)
+}
+
+// RequestEmailChangeRequest represents the request payload for starting an email change
+type RequestEmailChangeRequest struct {
+	Email string `json:"email" validate:"required,email,max=255,not_disposable"`
+}
+
+// EmailChangeTokenRequest represents the request payload for confirming or reverting an email change
+type EmailChangeTokenRequest struct {
+	Token string `json:"token" validate:"required"`
+}
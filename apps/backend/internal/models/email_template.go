@@ -0,0 +1,14 @@
+package models
+
+// EmailTemplate describes a registered email template
+type EmailTemplate struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	HasHTML     bool   `json:"has_html"`
+}
+
+// SendTestEmailRequest represents the request payload for sending an email
+// template with its sample data
+type SendTestEmailRequest struct {
+	To string `json:"to" validate:"required,email,max=255"`
+}
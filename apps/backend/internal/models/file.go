@@ -0,0 +1,57 @@
+package models
+
+import (
+	"context"
+	"time"
+
+	"github.com/PrinceNarteh/go-boilerplate/internal/anonymize"
+	"github.com/PrinceNarteh/go-boilerplate/internal/i18n"
+)
+
+// ScanStatus is the malware scan status of an uploaded file
+type ScanStatus string
+
+// Scan statuses
+const (
+	// ScanPending files have not been scanned successfully yet
+	ScanPending ScanStatus = "pending"
+	// ScanClean files were scanned and no threat was found
+	ScanClean ScanStatus = "clean"
+	// ScanQuarantined files contain a threat and must never be served
+	ScanQuarantined ScanStatus = "quarantined"
+)
+
+// ScanStatusLabels translates scan statuses
+var ScanStatusLabels = i18n.RegisterEnum("scan_status", ScanPending, ScanClean, ScanQuarantined)
+
+// FileAnonymization masks the names of uploaded files in staging snapshots,
+// which may name their owner
+var FileAnonymization = anonymize.Register("files", anonymize.Columns{
+	"filename": anonymize.Fixed("file"),
+})
+
+// File is an uploaded file stored by the storage backend
+type File struct {
+	ID          int        `json:"id" db:"id"`
+	UserID      int        `json:"user_id" db:"user_id"`
+	Filename    string     `json:"filename" db:"filename"`
+	ContentType string     `json:"content_type" db:"content_type"`
+	Size        int64      `json:"size" db:"size"`
+	StorageKey  string     `json:"-" db:"storage_key"`
+	ScanStatus  ScanStatus `json:"scan_status" db:"scan_status"`
+	ScanLabel   string     `json:"scan_status_label,omitempty" db:"-"`
+	ScanThreat  string     `json:"scan_threat,omitempty" db:"scan_threat"`
+	ScannedAt   *time.Time `json:"scanned_at,omitempty" db:"scanned_at"`
+	CreatedAt   time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at" db:"updated_at"`
+}
+
+// Localize implements i18n.Localizable
+func (f *File) Localize(ctx context.Context) {
+	f.ScanLabel = ScanStatusLabels.Label(ctx, f.ScanStatus)
+}
+
+// Available reports whether the file may be served to clients
+func (f *File) Available() bool {
+	return f.ScanStatus == ScanClean
+}
@@ -0,0 +1,8 @@
+package models
+
+// SetIncidentRequest represents the request payload for declaring or
+// updating the incident shown on the status page
+type SetIncidentRequest struct {
+	Message  string `json:"message" validate:"required,max=500"`
+	Severity string `json:"severity" validate:"required,oneof=minor major"`
+}
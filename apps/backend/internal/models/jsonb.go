@@ -0,0 +1,127 @@
+package models
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+
+	"github.com/PrinceNarteh/go-boilerplate/internal/errs"
+	"github.com/PrinceNarteh/go-boilerplate/internal/libs"
+)
+
+// JSONB is a struct field stored in a JSONB column. It encodes to JSON as
+// Data itself, is read and written by pgx as JSON, and the validate tags of
+// T are checked with those of its model, as for any nested struct.
+//
+// Columns holding JSONB fields are created with a GIN index when they are
+// queried by their content, see migration 017_add_users_preferences.sql.
+type JSONB[T any] struct {
+	Data T
+}
+
+// MarshalJSON encodes Data
+func (j JSONB[T]) MarshalJSON() ([]byte, error) {
+	return json.Marshal(j.Data)
+}
+
+// UnmarshalJSON decodes Data
+func (j *JSONB[T]) UnmarshalJSON(data []byte) error {
+	return json.Unmarshal(data, &j.Data)
+}
+
+// Value implements driver.Valuer, encoding Data for the column
+func (j JSONB[T]) Value() (driver.Value, error) {
+	data, err := json.Marshal(j.Data)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+// Scan implements sql.Scanner, decoding the column into Data. NULL leaves
+// Data at its zero value.
+func (j *JSONB[T]) Scan(src any) error {
+	var zero T
+	j.Data = zero
+
+	switch src := src.(type) {
+	case nil:
+		return nil
+	case []byte:
+		return json.Unmarshal(src, &j.Data)
+	case string:
+		return json.Unmarshal([]byte(src), &j.Data)
+	default:
+		return fmt.Errorf("cannot scan %T into a JSONB field", src)
+	}
+}
+
+// Merge applies patch, a JSON merge patch (RFC 7396), to Data: the fields of
+// patch replace those of Data, objects are merged recursively and null
+// fields are reset. Patches with unknown fields, or leaving Data invalid,
+// fail with errs.ErrValidation and leave Data unchanged.
+func (j *JSONB[T]) Merge(patch json.RawMessage) error {
+	current, err := json.Marshal(j.Data)
+	if err != nil {
+		return err
+	}
+	merged, err := MergeJSON(current, patch)
+	if err != nil {
+		return errs.ErrValidation.WithDetails(map[string]string{"patch": "must be a valid JSON object"})
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(merged))
+	decoder.DisallowUnknownFields()
+	var data T
+	if err := decoder.Decode(&data); err != nil {
+		return errs.ErrValidation.WithDetails(map[string]string{"patch": err.Error()})
+	}
+	if fields := libs.Validate(data); fields != nil {
+		return errs.ErrValidation.WithDetails(libs.FieldMessages(fields))
+	}
+
+	j.Data = data
+	return nil
+}
+
+// MergeJSON applies the JSON merge patch (RFC 7396) patch to the JSON
+// document doc
+func MergeJSON(doc, patch []byte) ([]byte, error) {
+	var target, changes any
+	if err := decodeJSON(doc, &target); err != nil {
+		return nil, fmt.Errorf("invalid document: %w", err)
+	}
+	if err := decodeJSON(patch, &changes); err != nil {
+		return nil, fmt.Errorf("invalid patch: %w", err)
+	}
+	return json.Marshal(mergePatch(target, changes))
+}
+
+// decodeJSON decodes data into v, keeping numbers as json.Number so that
+// large integers survive the merge
+func decodeJSON(data []byte, v any) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	return decoder.Decode(v)
+}
+
+// mergePatch merges the decoded patch into the decoded target
+func mergePatch(target, patch any) any {
+	changes, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+	fields, ok := target.(map[string]any)
+	if !ok {
+		fields = make(map[string]any, len(changes))
+	}
+	for name, value := range changes {
+		if value == nil {
+			delete(fields, name)
+			continue
+		}
+		fields[name] = mergePatch(fields[name], value)
+	}
+	return fields
+}
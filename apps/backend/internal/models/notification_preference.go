@@ -0,0 +1,44 @@
+package models
+
+import "time"
+
+// NotificationPreference is the choice of a user to receive a kind of
+// notification on a channel or not
+type NotificationPreference struct {
+	UserID    int       `json:"-" db:"user_id"`
+	Kind      string    `json:"kind" db:"kind"`
+	Channel   string    `json:"channel" db:"channel"`
+	Enabled   bool      `json:"enabled" db:"enabled"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// NotificationChannelSetting is whether a kind of notification is sent on a channel
+type NotificationChannelSetting struct {
+	Channel string `json:"channel"`
+	Enabled bool   `json:"enabled"`
+	// Default is the setting of users without a preference
+	Default bool `json:"default"`
+	// Required channels cannot be disabled, e.g. email for security alerts
+	Required bool `json:"required"`
+}
+
+// NotificationKindSettings are the channels of a kind of notification
+type NotificationKindSettings struct {
+	Kind        string                       `json:"kind"`
+	Description string                       `json:"description"`
+	Channels    []NotificationChannelSetting `json:"channels"`
+}
+
+// UpdateNotificationPreferencesRequest represents the request payload for
+// updating notification preferences. Kinds and channels not listed are
+// left unchanged.
+type UpdateNotificationPreferencesRequest struct {
+	Preferences []NotificationPreferenceUpdate `json:"preferences" validate:"required,min=1,max=100,dive"`
+}
+
+// NotificationPreferenceUpdate enables or disables a kind of notification on a channel
+type NotificationPreferenceUpdate struct {
+	Kind    string `json:"kind" validate:"required,max=64"`
+	Channel string `json:"channel" validate:"required,oneof=email push in_app"`
+	Enabled *bool  `json:"enabled" validate:"required"`
+}
@@ -0,0 +1,39 @@
+package models
+
+import (
+	"time"
+
+	"github.com/PrinceNarteh/go-boilerplate/internal/anonymize"
+	"github.com/PrinceNarteh/go-boilerplate/internal/libs/id"
+)
+
+// SessionAnonymization masks from where users signed in, in staging snapshots
+var SessionAnonymization = anonymize.Register("sessions", anonymize.Columns{
+	"ip":         anonymize.IP,
+	"user_agent": anonymize.Fixed(""),
+})
+
+// Session is a sign-in of a user. The tokens issued for it carry its ID,
+// see auth.Principal.SessionID, and are rejected once it is revoked.
+type Session struct {
+	ID        id.ID      `json:"id" db:"id"`
+	UserID    int        `json:"user_id" db:"user_id"`
+	IP        string     `json:"ip" db:"ip"`
+	UserAgent string     `json:"user_agent" db:"user_agent"`
+	CreatedAt time.Time  `json:"created_at" db:"created_at"`
+	ExpiresAt time.Time  `json:"expires_at" db:"expires_at"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty" db:"revoked_at"`
+	// Current is set on the session of the request listing sessions
+	Current bool `json:"current" db:"-"`
+}
+
+// Active reports whether tokens of the session are accepted at now
+func (s *Session) Active(now time.Time) bool {
+	return s.RevokedAt == nil && now.Before(s.ExpiresAt)
+}
+
+// RevokeSessionsResponse represents the response payload for signing out
+// the other sessions of a user
+type RevokeSessionsResponse struct {
+	Revoked int64 `json:"revoked"`
+}
@@ -0,0 +1,16 @@
+package models
+
+import (
+	"encoding/json"
+)
+
+// TaskResponse describes an operational task that can be run from the admin API
+type TaskResponse struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// StartTaskRunRequest represents the request payload for running an operational task
+type StartTaskRunRequest struct {
+	Args json.RawMessage `json:"args"`
+}
@@ -0,0 +1,17 @@
+package models
+
+import (
+	"time"
+)
+
+// Tenant is a customer whose data is isolated in its own database schema
+type Tenant struct {
+	ID        string    `json:"id" db:"id"`
+	Schema    string    `json:"schema" db:"schema_name"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+// CreateTenantRequest represents the request payload for provisioning a tenant
+type CreateTenantRequest struct {
+	ID string `json:"id" validate:"required,max=48"`
+}
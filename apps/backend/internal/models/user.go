@@ -1,41 +1,106 @@
 package models
 
 import (
+	"encoding/json"
+	"strings"
 	"time"
+
+	"github.com/PrinceNarteh/go-boilerplate/internal/anonymize"
 )
 
+// UserAnonymization masks the personal data of users in staging snapshots
+var UserAnonymization = anonymize.Register("users", anonymize.Columns{
+	"email": anonymize.Email,
+})
+
+// UserHistoryAnonymization masks the versions of users recorded by the
+// history trigger, with the same keyed strategy so they match their user
+var UserHistoryAnonymization = anonymize.Register("users_history", anonymize.Columns{
+	"email": anonymize.Email,
+})
+
 // User represents a user in the system
 type User struct {
 	ID        int       `json:"id" db:"id"`
 	Email     string    `json:"email" db:"email" validate:"required,email"`
 	CreatedAt time.Time `json:"created_at" db:"created_at"`
 	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+	// DeletedAt is set when the user is soft-deleted
+	DeletedAt *time.Time `json:"deleted_at,omitempty" db:"deleted_at"`
+	// Version is incremented on every change, for optimistic locking
+	Version int `json:"version" db:"version"`
+	// Preferences are stored in a JSONB column
+	Preferences JSONB[UserPreferences] `json:"preferences" db:"preferences"`
+}
+
+// UserPreferences are the settings of a user, stored with the user
+type UserPreferences struct {
+	Theme      string `json:"theme,omitempty"    validate:"omitempty,oneof=light dark system"`
+	Language   string `json:"language,omitempty" validate:"omitempty,bcp47_language_tag"`
+	Timezone   string `json:"timezone,omitempty" validate:"omitempty,timezone"`
+	Newsletter bool   `json:"newsletter"`
 }
 
 // CreateUserRequest represents the request payload for creating a user
 type CreateUserRequest struct {
-	Email string `json:"email" validate:"required,email"`
+	Email string `json:"email" validate:"required,email,not_disposable"`
 }
 
-// UpdateUserRequest represents the request payload for updating a user
+// UpdateUserRequest represents the request payload for updating a user.
+// When Version is set, the update fails with a conflict if the user has
+// changed since that version was read.
 type UpdateUserRequest struct {
-	Email string `json:"email" validate:"omitempty,email"`
+	Email   string `json:"email" validate:"omitempty,email,not_disposable"`
+	Version int    `json:"version" validate:"omitempty,min=1"`
+	// Preferences is a JSON merge patch of the preferences of the user,
+	// see JSONB.Merge
+	Preferences json.RawMessage `json:"preferences,omitempty"`
+}
+
+// UpdateProfileRequest represents the request payload for users updating
+// their own account. The email address is changed through the email change
+// flow, which confirms the new address.
+type UpdateProfileRequest struct {
+	// Preferences is a JSON merge patch of the preferences of the user,
+	// see JSONB.Merge
+	Preferences json.RawMessage `json:"preferences" validate:"required"`
+}
+
+// ChangePasswordRequest represents the request payload for changing the
+// password of the signed-in user. CurrentPassword may only be empty when
+// the user has no password yet.
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password" validate:"max=72"`
+	NewPassword     string `json:"new_password" validate:"required,min=8,max=72"`
 }
 
 // UserResponse represents the response payload for user data
 type UserResponse struct {
-	ID        int       `json:"id"`
-	Email     string    `json:"email"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID        int        `json:"id"`
+	Email     string     `json:"email"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+	Version   int        `json:"version"`
+	// Preferences are the settings of the user
+	Preferences UserPreferences `json:"preferences"`
+}
+
+// NormalizeEmail trims and lowercases an email address so that
+// uniqueness does not depend on how users type their address
+func NormalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
 }
 
 // ToResponse converts a User model to UserResponse
 func (u *User) ToResponse() *UserResponse {
 	return &UserResponse{
-		ID:        u.ID,
-		Email:     u.Email,
-		CreatedAt: u.CreatedAt,
-		UpdatedAt: u.UpdatedAt,
+		ID:          u.ID,
+		Email:       u.Email,
+		CreatedAt:   u.CreatedAt,
+		UpdatedAt:   u.UpdatedAt,
+		DeletedAt:   u.DeletedAt,
+		Version:     u.Version,
+		Preferences: u.Preferences.Data,
 	}
 }
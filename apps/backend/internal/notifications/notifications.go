@@ -0,0 +1,144 @@
+// Package notifications sends notifications to users on the channels they
+// chose.
+//
+// Each kind of notification is declared with Declare, next to the code
+// sending it, with the channels it is sent on by default. Users override
+// the defaults per kind and channel, and the Notifier consults their
+// preferences before fanning a notification out to the Sender of each
+// enabled channel. The required channels of a kind, such as email for
+// security alerts, are used whatever the preferences.
+package notifications
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"sync"
+
+	"github.com/PrinceNarteh/go-boilerplate/internal/models"
+)
+
+// Channel is a way of reaching users
+type Channel string
+
+// Notification channels
+const (
+	ChannelEmail Channel = "email"
+	ChannelPush  Channel = "push"
+	ChannelInApp Channel = "in_app"
+)
+
+// Channels are the notification channels, in the order they are listed
+var Channels = []Channel{ChannelEmail, ChannelPush, ChannelInApp}
+
+// Kind is the declaration of a kind of notification
+type Kind struct {
+	// Name identifies the kind in preferences, such as "security.new_sign_in"
+	Name        string
+	Description string
+	// Defaults are the channels used for users without a preference
+	Defaults []Channel
+	// Required are the channels used whatever the preferences, implicitly
+	// part of the defaults
+	Required []Channel
+}
+
+// kinds holds the declared kinds, by name
+var kinds = struct {
+	mu     sync.Mutex
+	byName map[string]*Kind
+}{byName: make(map[string]*Kind)}
+
+// Declare declares a kind of notification. It is meant to be called from a
+// package variable next to the code sending the notifications, and panics
+// when the kind is declared twice or uses an unknown channel.
+func Declare(kind Kind) *Kind {
+	for _, channel := range slices.Concat(kind.Defaults, kind.Required) {
+		if !slices.Contains(Channels, channel) {
+			panic(fmt.Sprintf("notifications: kind %s uses unknown channel %s", kind.Name, channel))
+		}
+	}
+
+	kinds.mu.Lock()
+	defer kinds.mu.Unlock()
+	if _, ok := kinds.byName[kind.Name]; ok {
+		panic(fmt.Sprintf("notifications: kind %s declared twice", kind.Name))
+	}
+	kinds.byName[kind.Name] = &kind
+	return &kind
+}
+
+// Kinds returns the declared kinds, sorted by name
+func Kinds() []*Kind {
+	kinds.mu.Lock()
+	defer kinds.mu.Unlock()
+	list := make([]*Kind, 0, len(kinds.byName))
+	for _, k := range kinds.byName {
+		list = append(list, k)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// Lookup returns the declared kind with the given name
+func Lookup(name string) (*Kind, bool) {
+	kinds.mu.Lock()
+	defer kinds.mu.Unlock()
+	k, ok := kinds.byName[name]
+	return k, ok
+}
+
+// IsDefault reports whether the kind is sent on channel to users without a preference
+func (k *Kind) IsDefault(channel Channel) bool {
+	return k.IsRequired(channel) || slices.Contains(k.Defaults, channel)
+}
+
+// IsRequired reports whether the kind is always sent on channel
+func (k *Kind) IsRequired(channel Channel) bool {
+	return slices.Contains(k.Required, channel)
+}
+
+// Enabled returns the channels the kind is sent on to a user with the
+// given preferences, which may be those of any kind
+func (k *Kind) Enabled(prefs []*models.NotificationPreference) []Channel {
+	var enabled []Channel
+	for _, channel := range Channels {
+		if k.enabled(channel, prefs) {
+			enabled = append(enabled, channel)
+		}
+	}
+	return enabled
+}
+
+// Settings describes the channels of the kind for a user with the given
+// preferences
+func (k *Kind) Settings(prefs []*models.NotificationPreference) models.NotificationKindSettings {
+	settings := models.NotificationKindSettings{
+		Kind:        k.Name,
+		Description: k.Description,
+		Channels:    make([]models.NotificationChannelSetting, len(Channels)),
+	}
+	for i, channel := range Channels {
+		settings.Channels[i] = models.NotificationChannelSetting{
+			Channel:  string(channel),
+			Enabled:  k.enabled(channel, prefs),
+			Default:  k.IsDefault(channel),
+			Required: k.IsRequired(channel),
+		}
+	}
+	return settings
+}
+
+// enabled reports whether the kind is sent on channel to a user with the
+// given preferences
+func (k *Kind) enabled(channel Channel, prefs []*models.NotificationPreference) bool {
+	if k.IsRequired(channel) {
+		return true
+	}
+	for _, pref := range prefs {
+		if pref.Kind == k.Name && pref.Channel == string(channel) {
+			return pref.Enabled
+		}
+	}
+	return k.IsDefault(channel)
+}
@@ -0,0 +1,141 @@
+package notifications
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/rs/zerolog"
+
+	"github.com/PrinceNarteh/go-boilerplate/internal/mailer"
+	"github.com/PrinceNarteh/go-boilerplate/internal/models"
+	"github.com/PrinceNarteh/go-boilerplate/internal/ws"
+)
+
+// Notification is a message to a user
+type Notification struct {
+	Kind    *Kind
+	UserID  int
+	Subject string
+	Text    string
+	// Data is the payload of in-app and push notifications, such as the ID
+	// of the resource to open
+	Data any
+}
+
+// Sender sends notifications on a channel
+type Sender interface {
+	Send(ctx context.Context, n Notification) error
+}
+
+// SenderFunc adapts a function to Sender
+type SenderFunc func(ctx context.Context, n Notification) error
+
+// Send implements Sender
+func (f SenderFunc) Send(ctx context.Context, n Notification) error {
+	return f(ctx, n)
+}
+
+// PreferenceStore returns the notification preferences of users, as
+// repositories.NotificationPreferenceRepository does
+type PreferenceStore interface {
+	ListByUser(ctx context.Context, userID int) ([]*models.NotificationPreference, error)
+}
+
+// Notifier sends notifications on the channels enabled by the preferences
+// of their user
+type Notifier struct {
+	prefs   PreferenceStore
+	senders map[Channel]Sender
+	logger  *zerolog.Logger
+}
+
+// NewNotifier creates a notifier sending on the channels of senders.
+// Enabled channels without a sender are skipped, so a deployment without
+// push notifications does not need one.
+func NewNotifier(prefs PreferenceStore, senders map[Channel]Sender, logger *zerolog.Logger) *Notifier {
+	return &Notifier{
+		prefs:   prefs,
+		senders: senders,
+		logger:  logger,
+	}
+}
+
+// Notify sends n on every channel its kind is enabled on for its user. A
+// channel failing does not stop the others, and the errors of all of them
+// are returned.
+func (n *Notifier) Notify(ctx context.Context, notification Notification) error {
+	prefs, err := n.prefs.ListByUser(ctx, notification.UserID)
+	if err != nil {
+		return fmt.Errorf("failed to get notification preferences: %w", err)
+	}
+
+	var errs []error
+	for _, channel := range notification.Kind.Enabled(prefs) {
+		sender, ok := n.senders[channel]
+		if !ok {
+			continue
+		}
+		if err := sender.Send(ctx, notification); err != nil {
+			errs = append(errs, fmt.Errorf("failed to send %s notification by %s: %w",
+				notification.Kind.Name, channel, err))
+			continue
+		}
+		n.logger.Debug().
+			Str("kind", notification.Kind.Name).
+			Str("channel", string(channel)).
+			Int("user_id", notification.UserID).
+			Msg("Notification sent")
+	}
+	return errors.Join(errs...)
+}
+
+// EmailLookup returns the email address of a user
+type EmailLookup func(ctx context.Context, userID int) (string, error)
+
+// EmailSender returns a sender emailing notifications with m to the
+// address returned by lookup
+func EmailSender(m mailer.Mailer, lookup EmailLookup) Sender {
+	return SenderFunc(func(ctx context.Context, n Notification) error {
+		email, err := lookup(ctx, n.UserID)
+		if err != nil {
+			return fmt.Errorf("failed to look up user email: %w", err)
+		}
+		return m.Send(ctx, mailer.Message{To: email, Subject: n.Subject, Text: n.Text})
+	})
+}
+
+// inAppMessage is the data of the WebSocket messages of in-app notifications
+type inAppMessage struct {
+	Kind    string `json:"kind"`
+	Subject string `json:"subject"`
+	Text    string `json:"text"`
+	Data    any    `json:"data,omitempty"`
+}
+
+// InAppSender returns a sender pushing notifications to the open WebSocket
+// connections of their user, as "notification" messages. Users without
+// connections do not get them.
+func InAppSender(hub *ws.Hub) Sender {
+	return SenderFunc(func(_ context.Context, n Notification) error {
+		return hub.SendToUser(n.UserID, ws.Message{
+			Type: "notification",
+			Data: inAppMessage{Kind: n.Kind.Name, Subject: n.Subject, Text: n.Text, Data: n.Data},
+		})
+	})
+}
+
+// LogSender returns a sender logging the notifications of channel instead
+// of sending them. It is intended for local development, or for channels
+// not integrated yet.
+func LogSender(channel Channel, logger *zerolog.Logger) Sender {
+	return SenderFunc(func(_ context.Context, n Notification) error {
+		logger.Info().
+			Str("channel", string(channel)).
+			Str("kind", n.Kind.Name).
+			Int("user_id", n.UserID).
+			Str("subject", n.Subject).
+			Msg("notification sent")
+		return nil
+	})
+}
@@ -0,0 +1,44 @@
+// Package openapi embeds the OpenAPI spec of the HTTP API and validates
+// requests and responses against it.
+//
+// The spec, openapi.yaml, is the contract of the API: handlers changing a
+// route, a parameter or a payload must update it. The Validator middleware
+// reports the requests and responses of documented routes that do not match
+// it, so drift between the handlers and the spec is caught during
+// development and tests rather than by API clients.
+package openapi
+
+import (
+	"context"
+	_ "embed"
+	"fmt"
+
+	"github.com/getkin/kin-openapi/openapi3"
+)
+
+//go:embed openapi.yaml
+var spec []byte
+
+func init() {
+	// Report which value does not match rather than dumping its schema, so
+	// mismatches fit on a log line and in an error response
+	openapi3.SchemaErrorDetailsDisabled = true
+}
+
+// Spec returns the OpenAPI spec of the API as YAML
+func Spec() []byte {
+	return spec
+}
+
+// Load parses and validates the OpenAPI spec of the API
+func Load() (*openapi3.T, error) {
+	loader := openapi3.NewLoader()
+	doc, err := loader.LoadFromData(spec)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse OpenAPI spec: %w", err)
+	}
+	if err := doc.Validate(context.Background()); err != nil {
+		return nil, fmt.Errorf("invalid OpenAPI spec: %w", err)
+	}
+	return doc, nil
+}
@@ -0,0 +1,152 @@
+package openapi
+
+import (
+	"bytes"
+	"fmt"
+	"maps"
+	"net/http"
+
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/getkin/kin-openapi/routers/gorillamux"
+	"github.com/rs/zerolog"
+
+	"github.com/PrinceNarteh/go-boilerplate/internal/config"
+	"github.com/PrinceNarteh/go-boilerplate/internal/errs"
+	"github.com/PrinceNarteh/go-boilerplate/internal/middlewares"
+)
+
+// Validator validates requests and, optionally, responses against the
+// OpenAPI spec. Routes the spec does not document are not validated.
+type Validator struct {
+	router    routers.Router
+	reject    bool
+	responses bool
+	logger    *zerolog.Logger
+	options   *openapi3filter.Options
+}
+
+// NewValidator creates a validator of the routes of the OpenAPI spec
+func NewValidator(cfg config.OpenAPIConfig, logger *zerolog.Logger) (*Validator, error) {
+	doc, err := Load()
+	if err != nil {
+		return nil, err
+	}
+
+	router, err := gorillamux.NewRouter(doc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to route OpenAPI spec: %w", err)
+	}
+
+	return &Validator{
+		router:    router,
+		reject:    cfg.Mode == "reject",
+		responses: cfg.ValidateResponses,
+		logger:    logger,
+		options: &openapi3filter.Options{
+			MultiError: true,
+			// Requests are authenticated by the middleware of their route
+			AuthenticationFunc: openapi3filter.NoopAuthenticationFunc,
+			// Validation must not change the request seen by the handler
+			SkipSettingDefaults: true,
+		},
+	}, nil
+}
+
+// Middleware returns the middleware validating the requests and responses
+// of the documented routes. It must run before routing, as the spec routes
+// the requests itself.
+func (v *Validator) Middleware() middlewares.Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			route, pathParams, err := v.router.FindRoute(r)
+			if err != nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			input := &openapi3filter.RequestValidationInput{
+				Request:    r,
+				PathParams: pathParams,
+				Route:      route,
+				Options:    v.options,
+			}
+			if err := openapi3filter.ValidateRequest(r.Context(), input); err != nil {
+				v.report(r, "request", err)
+				if v.reject {
+					errs.WriteJSON(w, errs.NewValidation("Request does not match the API contract").WithDetails(err.Error()))
+					return
+				}
+			}
+
+			if !v.responses {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			rec := &responseRecorder{header: w.Header().Clone(), status: http.StatusOK}
+			next.ServeHTTP(rec, r)
+
+			output := &openapi3filter.ResponseValidationInput{
+				RequestValidationInput: input,
+				Status:                 rec.status,
+				Header:                 rec.header,
+				Options:                v.options,
+			}
+			output.SetBodyBytes(rec.body.Bytes())
+			if err := openapi3filter.ValidateResponse(r.Context(), output); err != nil {
+				v.report(r, "response", err)
+				if v.reject {
+					errs.WriteJSON(w, errs.New(
+						errs.ErrCodeContractViolation,
+						"Response does not match the API contract",
+						http.StatusInternalServerError,
+					).WithDetails(err.Error()))
+					return
+				}
+			}
+
+			maps.Copy(w.Header(), rec.header)
+			w.WriteHeader(rec.status)
+			w.Write(rec.body.Bytes())
+		})
+	}
+}
+
+// report logs a mismatch between the spec and a request or response
+func (v *Validator) report(r *http.Request, kind string, err error) {
+	v.logger.Warn().
+		Str("request_id", middlewares.GetRequestID(r.Context())).
+		Str("method", r.Method).
+		Str("path", r.URL.Path).
+		Str("kind", kind).
+		Err(err).
+		Msg("OpenAPI contract mismatch")
+}
+
+// responseRecorder buffers a response until it is validated
+type responseRecorder struct {
+	header      http.Header
+	status      int
+	body        bytes.Buffer
+	wroteHeader bool
+}
+
+// Header implements http.ResponseWriter
+func (rec *responseRecorder) Header() http.Header {
+	return rec.header
+}
+
+// WriteHeader implements http.ResponseWriter
+func (rec *responseRecorder) WriteHeader(status int) {
+	if !rec.wroteHeader {
+		rec.status = status
+		rec.wroteHeader = true
+	}
+}
+
+// Write implements http.ResponseWriter
+func (rec *responseRecorder) Write(b []byte) (int, error) {
+	rec.wroteHeader = true
+	return rec.body.Write(b)
+}
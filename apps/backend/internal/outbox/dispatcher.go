@@ -0,0 +1,174 @@
+package outbox
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/PrinceNarteh/go-boilerplate/internal/config"
+	"github.com/PrinceNarteh/go-boilerplate/internal/libs/async"
+	"github.com/PrinceNarteh/go-boilerplate/internal/libs/id"
+	"github.com/PrinceNarteh/go-boilerplate/internal/libs/retry"
+)
+
+const (
+	defaultBatchSize    = 100              // Default number of events claimed at once
+	defaultPollInterval = time.Second      // Default delay between polls of an empty outbox
+	defaultLease        = 30 * time.Second // Default duration of the claim of a batch
+	defaultMinBackoff   = time.Second      // Default delay before the first retry of an event
+	defaultMaxBackoff   = 5 * time.Minute  // Default longest delay between retries
+	storeTimeout        = 5 * time.Second  // Timeout of store updates after publishing
+)
+
+// Dispatcher publishes the events of the outbox in the background. Several
+// instances can run at once: each claims its own events.
+type Dispatcher struct {
+	store     *Store
+	publisher Publisher
+	cfg       config.OutboxConfig
+	logger    *zerolog.Logger
+	retry     retry.Policy
+
+	mu   sync.Mutex
+	stop func()
+}
+
+// NewDispatcher creates a dispatcher publishing the events of store with
+// publisher. Unset sizes and durations of cfg use the defaults.
+func NewDispatcher(store *Store, publisher Publisher, cfg config.OutboxConfig, logger *zerolog.Logger) *Dispatcher {
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = defaultBatchSize
+	}
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = defaultPollInterval
+	}
+	if cfg.Lease <= 0 {
+		cfg.Lease = defaultLease
+	}
+	if cfg.MinBackoff <= 0 {
+		cfg.MinBackoff = defaultMinBackoff
+	}
+	if cfg.MaxBackoff < cfg.MinBackoff {
+		cfg.MaxBackoff = max(defaultMaxBackoff, cfg.MinBackoff)
+	}
+
+	return &Dispatcher{
+		store:     store,
+		publisher: publisher,
+		cfg:       cfg,
+		logger:    logger,
+		retry:     retry.Policy{MinBackoff: cfg.MinBackoff, MaxBackoff: cfg.MaxBackoff},
+	}
+}
+
+// Start publishes events in the background until Stop is called
+func (d *Dispatcher) Start() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.stop != nil {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := async.Go(ctx, "outbox.dispatcher", func(ctx context.Context) error {
+		d.loop(ctx)
+		return nil
+	})
+
+	d.stop = func() {
+		cancel()
+		<-done
+	}
+}
+
+// Stop stops publishing and waits for the current batch to be recorded.
+// Events claimed but not published yet are claimed again once their lease expires.
+func (d *Dispatcher) Stop() {
+	d.mu.Lock()
+	stop := d.stop
+	d.stop = nil
+	d.mu.Unlock()
+
+	if stop != nil {
+		stop()
+	}
+}
+
+// loop publishes batches of events, polling when the outbox is drained
+func (d *Dispatcher) loop(ctx context.Context) {
+	for {
+		n, err := d.dispatch(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			d.logger.Error().Err(err).Msg("Failed to dispatch outbox events")
+		}
+		if n == d.cfg.BatchSize {
+			// More events are probably waiting
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(d.cfg.PollInterval):
+		}
+	}
+}
+
+// dispatch claims a batch of events and publishes them in order, returning
+// the number of events claimed. When an event fails, the following events
+// of its key are released to keep them in order.
+func (d *Dispatcher) dispatch(ctx context.Context) (int, error) {
+	events, err := d.store.Claim(ctx, d.cfg.BatchSize, d.cfg.Lease)
+	if err != nil || len(events) == 0 {
+		return 0, err
+	}
+	slices.SortFunc(events, func(a, b *Event) int {
+		return a.ID.Compare(b.ID)
+	})
+
+	// Updates are recorded even when stopping, so published events are not sent again
+	storeCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), storeTimeout)
+	defer cancel()
+
+	var published, released []id.ID
+	failedKeys := make(map[string]bool)
+	for _, event := range events {
+		if ctx.Err() != nil || (event.Key != "" && failedKeys[event.Key]) {
+			released = append(released, event.ID)
+			continue
+		}
+
+		log := d.logger.With().Stringer("event_id", event.ID).Str("event_type", event.Type).Logger()
+		if err := d.publisher.Publish(ctx, event); err != nil {
+			if event.Key != "" {
+				failedKeys[event.Key] = true
+			}
+			delay := d.retry.Backoff(event.Attempts + 1)
+			log.Warn().Err(err).Int("attempt", event.Attempts+1).Dur("retry_in", delay).Msg("Failed to publish event")
+			if err := d.store.Retry(storeCtx, event, err, delay); err != nil {
+				log.Error().Err(err).Msg("Failed to release event")
+			}
+			continue
+		}
+		published = append(published, event.ID)
+	}
+
+	if len(published) > 0 {
+		if err := d.store.MarkPublished(storeCtx, published); err != nil {
+			return len(events), err
+		}
+		d.logger.Debug().Int("events", len(published)).Msg("Published outbox events")
+	}
+	if len(released) > 0 {
+		if err := d.store.Release(storeCtx, released); err != nil {
+			return len(events), err
+		}
+	}
+	return len(events), nil
+}
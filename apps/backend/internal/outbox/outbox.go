@@ -0,0 +1,59 @@
+// Package outbox publishes domain events reliably with the transactional
+// outbox pattern.
+//
+// Services record events with Store.Add in the transaction of the changes
+// they describe, so an event exists if and only if its change committed:
+// there is no window where the change is saved but the event lost, or the
+// event published for a change that rolled back. A Dispatcher then reads
+// the recorded events in the background and publishes them to a message
+// broker through a Publisher, retrying with backoff until the broker
+// accepts them.
+//
+// Delivery is at least once: an event may be published again when the
+// dispatcher stops between publishing it and marking it published, so
+// consumers must be idempotent, e.g. by tracking event IDs. Events sharing
+// a key are published in the order they were recorded, and an event is only
+// published once the previous events of its key are.
+//
+// Publishers are provided for Redis streams and for the logs. Other
+// brokers, such as Kafka or NATS, implement Publisher. Publishers pass the
+// version of the payload on, for consumers to upgrade the payloads recorded
+// by older versions of the application.
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/PrinceNarteh/go-boilerplate/internal/libs/id"
+)
+
+// ErrNoTransaction is returned when recording an event outside a
+// transaction, which would publish it even if the change rolled back
+var ErrNoTransaction = errors.New("outbox: events must be recorded within a transaction")
+
+// Event is a domain event recorded in the outbox
+type Event struct {
+	ID id.ID `json:"id"`
+	// Topic is where the event is published, such as "users"
+	Topic string `json:"topic"`
+	// Key orders the events of an entity, such as a user ID, optional
+	Key string `json:"key,omitempty"`
+	// Type is the kind of event, such as "user.created"
+	Type    string          `json:"type"`
+	Payload json.RawMessage `json:"payload"`
+	// Version is the version of the payload schema, see package versioned,
+	// 0 for the events recorded before payloads were versioned
+	Version  int `json:"version,omitempty"`
+	Attempts int `json:"attempts"`
+	// CreatedAt is the time the event was recorded
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Publisher publishes events to a message broker
+type Publisher interface {
+	// Publish publishes an event, returning once the broker accepted it
+	Publish(ctx context.Context, event *Event) error
+}
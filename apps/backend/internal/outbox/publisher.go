@@ -0,0 +1,76 @@
+package outbox
+
+import (
+	"context"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/rs/zerolog"
+
+	"github.com/PrinceNarteh/go-boilerplate/internal/config"
+	"github.com/PrinceNarteh/go-boilerplate/internal/libs/versioned"
+)
+
+// defaultStreamPrefix namespaces the streams of the Redis publisher
+const defaultStreamPrefix = "events:"
+
+// RedisStreamPublisher publishes events to Redis streams, one per topic.
+// Each entry has the fields id, type, key, payload, version and created_at, and
+// consumer groups read them with XREADGROUP.
+type RedisStreamPublisher struct {
+	client redis.Cmdable
+	prefix string
+	maxLen int64
+}
+
+// NewRedisStreamPublisher creates a publisher adding events to the streams
+// cfg.StreamPrefix+topic, "events:" by default, trimmed to about
+// cfg.StreamMaxLen entries when set
+func NewRedisStreamPublisher(client redis.Cmdable, cfg config.OutboxConfig) *RedisStreamPublisher {
+	prefix := cfg.StreamPrefix
+	if prefix == "" {
+		prefix = defaultStreamPrefix
+	}
+	return &RedisStreamPublisher{client: client, prefix: prefix, maxLen: cfg.StreamMaxLen}
+}
+
+// Publish implements Publisher
+func (p *RedisStreamPublisher) Publish(ctx context.Context, event *Event) error {
+	return p.client.XAdd(ctx, &redis.XAddArgs{
+		Stream: p.prefix + event.Topic,
+		MaxLen: p.maxLen,
+		Approx: p.maxLen > 0,
+		Values: []any{
+			"id", event.ID.String(),
+			"type", event.Type,
+			"key", event.Key,
+			"payload", string(event.Payload),
+			"version", versioned.FormatVersion(max(event.Version, 1)),
+			"created_at", event.CreatedAt.UTC().Format(time.RFC3339Nano),
+		},
+	}).Err()
+}
+
+// LogPublisher writes events to the logs instead of a broker, for
+// development and for trying the outbox out
+type LogPublisher struct {
+	logger *zerolog.Logger
+}
+
+// NewLogPublisher creates a publisher logging events
+func NewLogPublisher(logger *zerolog.Logger) *LogPublisher {
+	return &LogPublisher{logger: logger}
+}
+
+// Publish implements Publisher
+func (p *LogPublisher) Publish(_ context.Context, event *Event) error {
+	p.logger.Info().
+		Stringer("event_id", event.ID).
+		Str("topic", event.Topic).
+		Str("key", event.Key).
+		Str("event_type", event.Type).
+		RawJSON("payload", event.Payload).
+		Int("payload_version", event.Version).
+		Msg("Event published")
+	return nil
+}
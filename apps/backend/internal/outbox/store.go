@@ -0,0 +1,151 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	pgx "github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/PrinceNarteh/go-boilerplate/internal/anonymize"
+	"github.com/PrinceNarteh/go-boilerplate/internal/database"
+	"github.com/PrinceNarteh/go-boilerplate/internal/libs/id"
+	"github.com/PrinceNarteh/go-boilerplate/internal/libs/versioned"
+)
+
+// OutboxAnonymization leaves the outbox out of staging snapshots, as event
+// payloads hold personal data
+var OutboxAnonymization = anonymize.Skip("outbox_events")
+
+// Store keeps the events of the outbox in the outbox_events table
+type Store struct {
+	db *pgxpool.Pool
+}
+
+// NewStore creates a new outbox store
+func NewStore(db *pgxpool.Pool) *Store {
+	return &Store{db: db}
+}
+
+// Add records an event of eventType on topic, ordered with the other events
+// of key, which may be empty. payload is encoded as JSON, with its version,
+// see versioned.Versioned. ctx must carry the
+// transaction of the change the event describes, see database.TxManager;
+// ErrNoTransaction is returned otherwise.
+func (s *Store) Add(ctx context.Context, topic, key, eventType string, payload any) error {
+	tx, ok := database.TxFromContext(ctx)
+	if !ok {
+		return ErrNoTransaction
+	}
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s event: %w", eventType, err)
+	}
+
+	query := `
+		INSERT INTO outbox_events (id, topic, partition_key, event_type, payload, payload_version, available_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, NOW(), NOW())`
+
+	version := versioned.Of(payload)
+	if _, err := tx.Exec(ctx, query, id.New(), topic, key, eventType, data, version); err != nil {
+		return fmt.Errorf("failed to record %s event: %w", eventType, database.TranslateError(err))
+	}
+
+	return nil
+}
+
+// Claim claims up to limit events due for publishing for lease, in the order
+// they were recorded. Events whose key has an earlier unpublished event are
+// left for later, so that the events of a key are published in order.
+func (s *Store) Claim(ctx context.Context, limit int, lease time.Duration) ([]*Event, error) {
+	query := `
+		UPDATE outbox_events
+		SET locked_until = NOW() + $2::interval
+		WHERE id IN (
+			SELECT id FROM outbox_events e
+			WHERE published_at IS NULL
+				AND available_at <= NOW()
+				AND (locked_until IS NULL OR locked_until < NOW())
+				AND NOT EXISTS (
+					SELECT 1 FROM outbox_events prev
+					WHERE e.partition_key <> ''
+						AND prev.partition_key = e.partition_key
+						AND prev.id < e.id
+						AND prev.published_at IS NULL
+				)
+			ORDER BY id
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, topic, partition_key, event_type, payload, payload_version, attempts, created_at`
+
+	rows, err := s.db.Query(ctx, query, limit, lease)
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim events: %w", database.TranslateError(err))
+	}
+
+	events, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (*Event, error) {
+		var event Event
+		var payload []byte
+		err := row.Scan(
+			&event.ID,
+			&event.Topic,
+			&event.Key,
+			&event.Type,
+			&payload,
+			&event.Version,
+			&event.Attempts,
+			&event.CreatedAt,
+		)
+		event.Payload = payload
+		return &event, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim events: %w", database.TranslateError(err))
+	}
+
+	return events, nil
+}
+
+// MarkPublished marks events as published
+func (s *Store) MarkPublished(ctx context.Context, ids []id.ID) error {
+	query := `
+		UPDATE outbox_events
+		SET published_at = NOW(), locked_until = NULL, attempts = attempts + 1
+		WHERE id = ANY($1)`
+
+	if _, err := s.db.Exec(ctx, query, ids); err != nil {
+		return fmt.Errorf("failed to mark events published: %w", database.TranslateError(err))
+	}
+
+	return nil
+}
+
+// Retry releases an event that failed to publish, saving the error, to be
+// claimed again after delay
+func (s *Store) Retry(ctx context.Context, event *Event, cause error, delay time.Duration) error {
+	query := `
+		UPDATE outbox_events
+		SET attempts = attempts + 1, last_error = $2, available_at = NOW() + $3::interval, locked_until = NULL
+		WHERE id = $1`
+
+	if _, err := s.db.Exec(ctx, query, event.ID, cause.Error(), delay); err != nil {
+		return fmt.Errorf("failed to release event: %w", database.TranslateError(err))
+	}
+
+	return nil
+}
+
+// Release releases claimed events without publishing them, to be claimed again
+func (s *Store) Release(ctx context.Context, ids []id.ID) error {
+	query := `UPDATE outbox_events SET locked_until = NULL WHERE id = ANY($1)`
+
+	if _, err := s.db.Exec(ctx, query, ids); err != nil {
+		return fmt.Errorf("failed to release events: %w", database.TranslateError(err))
+	}
+
+	return nil
+}
@@ -0,0 +1,172 @@
+// Package redis provides the application's managed Redis client.
+//
+// The client is built from RedisConfig, with authentication, database index,
+// pool and timeout settings and optional TLS. A hook logs failed dials,
+// command errors and slow commands, and HealthCheck returns a ping check
+// for the health check service and connection supervisor.
+package redis
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"time"
+
+	goredis "github.com/redis/go-redis/v9"
+	"github.com/rs/zerolog"
+
+	"github.com/PrinceNarteh/go-boilerplate/internal/config"
+	"github.com/PrinceNarteh/go-boilerplate/internal/healthcheck"
+)
+
+// connectTimeout bounds the ping sent when the client is created
+const connectTimeout = 5 * time.Second
+
+// Client is a go-redis client managed by the application
+type Client struct {
+	*goredis.Client
+	log *zerolog.Logger
+}
+
+// New creates a Redis client and checks that Redis answers a ping. An
+// unreachable server is logged rather than returned, since go-redis
+// connects on first use; errors are only returned for invalid TLS settings.
+func New(cfg config.RedisConfig, logger *zerolog.Logger) (*Client, error) {
+	opts := &goredis.Options{
+		Addr:         cfg.Address,
+		Username:     cfg.Username,
+		Password:     cfg.Password,
+		DB:           cfg.DB,
+		PoolSize:     cfg.PoolSize,
+		DialTimeout:  cfg.DialTimeout,
+		ReadTimeout:  cfg.ReadTimeout,
+		WriteTimeout: cfg.WriteTimeout,
+	}
+
+	if cfg.TLS.Enabled {
+		tlsConfig, err := newTLSConfig(cfg.TLS)
+		if err != nil {
+			return nil, err
+		}
+		opts.TLSConfig = tlsConfig
+	}
+
+	client := &Client{
+		Client: goredis.NewClient(opts),
+		log:    logger,
+	}
+	client.AddHook(&logHook{logger: logger, slowThreshold: cfg.SlowThreshold})
+
+	ctx, cancel := context.WithTimeout(context.Background(), connectTimeout)
+	defer cancel()
+	if err := client.Ping(ctx).Err(); err != nil {
+		logger.Warn().Err(err).Str("address", cfg.Address).Msg("redis unreachable, commands will fail until it is up")
+	} else {
+		logger.Info().Str("address", cfg.Address).Int("db", cfg.DB).Msg("connected to redis")
+	}
+
+	return client, nil
+}
+
+// HealthCheck returns a check pinging Redis
+func (c *Client) HealthCheck() healthcheck.Check {
+	return healthcheck.RedisPing(c.Client)
+}
+
+// Close closes the connection pool. It should be called once the server
+// has stopped serving requests.
+func (c *Client) Close() error {
+	c.log.Info().Msg("closing redis connection pool")
+	if err := c.Client.Close(); err != nil && !errors.Is(err, goredis.ErrClosed) {
+		return fmt.Errorf("failed to close redis client: %w", err)
+	}
+	return nil
+}
+
+// newTLSConfig builds the TLS configuration of the connection
+func newTLSConfig(cfg config.RedisTLSConfig) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		ServerName:         cfg.ServerName,
+		InsecureSkipVerify: cfg.InsecureSkipVerify,
+	}
+
+	if cfg.CAFile != "" {
+		pem, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read redis CA file: %w", err)
+		}
+
+		roots, err := x509.SystemCertPool()
+		if err != nil {
+			roots = x509.NewCertPool()
+		}
+		if !roots.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("redis CA file %s contains no certificates", cfg.CAFile)
+		}
+		tlsConfig.RootCAs = roots
+	}
+
+	return tlsConfig, nil
+}
+
+// logHook logs failed dials, command errors and slow commands
+type logHook struct {
+	logger        *zerolog.Logger
+	slowThreshold time.Duration
+}
+
+// DialHook implements goredis.Hook
+func (h *logHook) DialHook(next goredis.DialHook) goredis.DialHook {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := next(ctx, network, addr)
+		if err != nil {
+			h.logger.Warn().Err(err).Str("address", addr).Msg("failed to connect to redis")
+		}
+		return conn, err
+	}
+}
+
+// ProcessHook implements goredis.Hook
+func (h *logHook) ProcessHook(next goredis.ProcessHook) goredis.ProcessHook {
+	return func(ctx context.Context, cmd goredis.Cmder) error {
+		start := time.Now()
+		err := next(ctx, cmd)
+		h.log(cmd.Name(), 1, time.Since(start), err)
+		return err
+	}
+}
+
+// ProcessPipelineHook implements goredis.Hook
+func (h *logHook) ProcessPipelineHook(next goredis.ProcessPipelineHook) goredis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []goredis.Cmder) error {
+		start := time.Now()
+		err := next(ctx, cmds)
+		h.log("pipeline", len(cmds), time.Since(start), err)
+		return err
+	}
+}
+
+// log logs a command when it failed or exceeded the slow threshold.
+// goredis.Nil only means a missing key and is never logged.
+func (h *logHook) log(command string, count int, duration time.Duration, err error) {
+	switch {
+	case err != nil && !errors.Is(err, goredis.Nil):
+		h.logger.Error().
+			Err(err).
+			Str("redis_command", command).
+			Int("commands", count).
+			Dur("duration", duration).
+			Msg("redis command failed")
+	case h.slowThreshold > 0 && duration >= h.slowThreshold:
+		h.logger.Warn().
+			Str("redis_command", command).
+			Int("commands", count).
+			Dur("duration", duration).
+			Msg("slow redis command")
+	}
+}
@@ -0,0 +1,205 @@
+package refactor
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	pgx "github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/rs/zerolog"
+
+	"github.com/PrinceNarteh/go-boilerplate/internal/database"
+)
+
+const (
+	defaultChunkSize = 1000 // Default number of IDs processed per chunk
+)
+
+// Backfill copies existing rows of a table to the new schema of a refactor.
+// Rows are processed in chunks of consecutive IDs, each chunk within its own
+// transaction together with the saved progress, so a backfill can be stopped
+// at any time and resumed where it left off.
+type Backfill struct {
+	// Name identifies the backfill in the progress table
+	Name string
+	// Table is the table whose rows are backfilled
+	Table string
+	// IDColumn is the integer primary key column of Table, "id" when empty
+	IDColumn string
+	// ChunkSize is the number of IDs per chunk, 1000 when zero
+	ChunkSize int
+	// Pause is waited between chunks to limit the load on the database
+	Pause time.Duration
+	// Chunk backfills the rows with IDs from fromID to toID inclusive and
+	// returns the number of rows written. It must use database.Conn with the
+	// given context to run within the chunk transaction, and be idempotent
+	// since rows written by the double write may be backfilled again.
+	Chunk func(ctx context.Context, fromID, toID int) (int64, error)
+}
+
+// BackfillProgress is the saved progress of a backfill
+type BackfillProgress struct {
+	Name        string     `json:"name"`
+	LastID      int        `json:"last_id"`
+	MaxID       int        `json:"max_id"`
+	Rows        int64      `json:"rows"`
+	StartedAt   time.Time  `json:"started_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+}
+
+// Percent returns how much of the backfill is done, from 0 to 100
+func (p *BackfillProgress) Percent() float64 {
+	if p.CompletedAt != nil || p.MaxID == 0 {
+		return 100
+	}
+	return float64(p.LastID) / float64(p.MaxID) * 100
+}
+
+// BackfillRunner runs backfills and tracks their progress in the backfills table
+type BackfillRunner struct {
+	db     *pgxpool.Pool
+	tx     *database.TxManager
+	logger *zerolog.Logger
+}
+
+// NewBackfillRunner creates a new backfill runner
+func NewBackfillRunner(db *pgxpool.Pool, logger *zerolog.Logger) *BackfillRunner {
+	return &BackfillRunner{
+		db:     db,
+		tx:     database.NewTxManager(db),
+		logger: logger,
+	}
+}
+
+// Run runs a backfill to completion, resuming from its saved progress.
+// Rows are backfilled up to the highest ID present when the backfill first
+// starts; later rows are expected to be written by the double write.
+// Running the same backfill from several processes is safe, as each chunk
+// locks the progress row.
+func (r *BackfillRunner) Run(ctx context.Context, b Backfill) (*BackfillProgress, error) {
+	if b.IDColumn == "" {
+		b.IDColumn = "id"
+	}
+	if b.ChunkSize <= 0 {
+		b.ChunkSize = defaultChunkSize
+	}
+
+	progress, err := r.start(ctx, b)
+	if err != nil {
+		return nil, err
+	}
+
+	logger := r.logger.With().Str("backfill", b.Name).Logger()
+	logger.Info().Int("last_id", progress.LastID).Int("max_id", progress.MaxID).Msg("Backfill started")
+
+	for progress.CompletedAt == nil {
+		if progress, err = r.runChunk(ctx, b); err != nil {
+			return progress, err
+		}
+
+		logger.Info().
+			Int("last_id", progress.LastID).
+			Int64("rows", progress.Rows).
+			Str("percent", fmt.Sprintf("%.1f", progress.Percent())).
+			Msg("Backfill progress")
+
+		if progress.CompletedAt == nil && b.Pause > 0 {
+			select {
+			case <-ctx.Done():
+				return progress, ctx.Err()
+			case <-time.After(b.Pause):
+			}
+		}
+	}
+
+	logger.Info().Int64("rows", progress.Rows).Msg("Backfill completed")
+	return progress, nil
+}
+
+// Progress returns the saved progress of the named backfill.
+// It returns an error wrapping errs.ErrNotFound when it never started.
+func (r *BackfillRunner) Progress(ctx context.Context, name string) (*BackfillProgress, error) {
+	query := `SELECT ` + progressColumns + ` FROM backfills WHERE name = $1`
+
+	progress, err := scanProgress(database.Conn(ctx, r.db).QueryRow(ctx, query, name))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get backfill progress: %w", database.TranslateError(err))
+	}
+
+	return progress, nil
+}
+
+// start records the backfill with the current highest ID of its table,
+// unless it already started, and returns its progress
+func (r *BackfillRunner) start(ctx context.Context, b Backfill) (*BackfillProgress, error) {
+	query := fmt.Sprintf(`
+		INSERT INTO backfills (name, max_id)
+		SELECT $1, COALESCE(MAX(%s), 0) FROM %s
+		ON CONFLICT (name) DO NOTHING`, b.IDColumn, b.Table)
+
+	if _, err := database.Conn(ctx, r.db).Exec(ctx, query, b.Name); err != nil {
+		return nil, fmt.Errorf("failed to start backfill: %w", err)
+	}
+
+	return r.Progress(ctx, b.Name)
+}
+
+// runChunk backfills the chunk after the saved progress and saves the new progress
+func (r *BackfillRunner) runChunk(ctx context.Context, b Backfill) (*BackfillProgress, error) {
+	var progress *BackfillProgress
+	err := r.tx.WithinTx(ctx, func(ctx context.Context) error {
+		conn := database.Conn(ctx, r.db)
+
+		// Lock the progress so that concurrent runners take turns
+		query := `SELECT ` + progressColumns + ` FROM backfills WHERE name = $1 FOR UPDATE`
+		current, err := scanProgress(conn.QueryRow(ctx, query, b.Name))
+		if err != nil {
+			return fmt.Errorf("failed to lock backfill progress: %w", err)
+		}
+		if current.CompletedAt != nil {
+			progress = current
+			return nil
+		}
+
+		fromID := current.LastID + 1
+		toID := min(current.LastID+b.ChunkSize, current.MaxID)
+
+		var rows int64
+		if fromID <= toID {
+			if rows, err = b.Chunk(ctx, fromID, toID); err != nil {
+				return fmt.Errorf("failed to backfill ids %d to %d: %w", fromID, toID, err)
+			}
+		}
+
+		update := `
+			UPDATE backfills
+			SET last_id = $2,
+				rows = rows + $3,
+				updated_at = NOW(),
+				completed_at = CASE WHEN $2 >= max_id THEN NOW() END
+			WHERE name = $1
+			RETURNING ` + progressColumns
+		progress, err = scanProgress(conn.QueryRow(ctx, update, b.Name, max(toID, current.LastID), rows))
+		if err != nil {
+			return fmt.Errorf("failed to save backfill progress: %w", err)
+		}
+		return nil
+	})
+
+	return progress, err
+}
+
+// progressColumns are the columns read by scanProgress
+const progressColumns = `name, last_id, max_id, rows, started_at, updated_at, completed_at`
+
+// scanProgress scans a row of progressColumns
+func scanProgress(row pgx.Row) (*BackfillProgress, error) {
+	var p BackfillProgress
+	err := row.Scan(&p.Name, &p.LastID, &p.MaxID, &p.Rows, &p.StartedAt, &p.UpdatedAt, &p.CompletedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
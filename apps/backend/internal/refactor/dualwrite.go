@@ -0,0 +1,162 @@
+// Package refactor supports zero-downtime schema refactors, such as moving a
+// column to a new table or changing its type, without a maintenance window.
+//
+// A refactor goes through the phases of a DualWrite: the service first
+// writes to both the old and the new schema while still reading the old one,
+// a Backfill then copies the existing rows chunk by chunk, reads switch to
+// the new schema once the comparison logs stay clean, and finally the old
+// schema stops being written and can be dropped by a migration.
+package refactor
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"reflect"
+	"sync/atomic"
+
+	"github.com/rs/zerolog"
+)
+
+// Phase is the step of a schema refactor
+type Phase string
+
+// Refactor phases, in order
+const (
+	// PhaseOld writes and reads the old schema only
+	PhaseOld Phase = "old"
+	// PhaseDoubleWrite writes both schemas and reads the old one
+	PhaseDoubleWrite Phase = "double_write"
+	// PhaseReadNew writes both schemas and reads the new one
+	PhaseReadNew Phase = "read_new"
+	// PhaseNew writes and reads the new schema only
+	PhaseNew Phase = "new"
+)
+
+// DualWrite routes the reads and writes of one refactor according to its
+// phase, which can be changed at runtime, e.g. from a feature flag.
+//
+// In the double-write phases, writes go to both schemas and return the
+// first error. Callers should run them within a transaction so that the
+// schemas cannot diverge. Reads can be compared against the schema that is
+// not read, and mismatches are logged for investigation.
+type DualWrite struct {
+	name        string
+	phase       atomic.Value
+	compareRate float64
+	logger      *zerolog.Logger
+}
+
+// DualWriteOptions configures a DualWrite
+type DualWriteOptions struct {
+	// Phase is the initial phase, PhaseOld when empty
+	Phase Phase
+	// CompareRate is the fraction of reads, from 0 to 1, also read from the
+	// other schema and compared in the double-write phases
+	CompareRate float64
+	Logger      *zerolog.Logger
+}
+
+// NewDualWrite creates the dual write of the refactor called name
+func NewDualWrite(name string, opts DualWriteOptions) *DualWrite {
+	logger := opts.Logger
+	if logger == nil {
+		nop := zerolog.Nop()
+		logger = &nop
+	}
+	phase := opts.Phase
+	if phase == "" {
+		phase = PhaseOld
+	}
+
+	d := &DualWrite{name: name, compareRate: opts.CompareRate, logger: logger}
+	d.phase.Store(phase)
+	return d
+}
+
+// Phase returns the current phase
+func (d *DualWrite) Phase() Phase {
+	return d.phase.Load().(Phase)
+}
+
+// SetPhase changes the phase, taking effect for the next reads and writes
+func (d *DualWrite) SetPhase(phase Phase) {
+	d.phase.Store(phase)
+	d.logger.Info().Str("refactor", d.name).Str("phase", string(phase)).Msg("Refactor phase changed")
+}
+
+// Write runs the writes the current phase requires. In the double-write
+// phases, the schema that is read is written first.
+func (d *DualWrite) Write(ctx context.Context, writeOld, writeNew func(ctx context.Context) error) error {
+	phase := d.Phase()
+	switch phase {
+	case PhaseOld:
+		return writeOld(ctx)
+	case PhaseNew:
+		return writeNew(ctx)
+	}
+
+	primary, secondary := writeOld, writeNew
+	if phase == PhaseReadNew {
+		primary, secondary = writeNew, writeOld
+	}
+
+	if err := primary(ctx); err != nil {
+		return err
+	}
+	if err := secondary(ctx); err != nil {
+		return fmt.Errorf("refactor %s: failed to double write: %w", d.name, err)
+	}
+	return nil
+}
+
+// Read returns the result of the read the current phase requires. In the
+// double-write phases, a fraction of reads also runs the other read and logs
+// any difference; its result and errors never affect the returned value.
+// Results are compared with reflect.DeepEqual.
+func Read[T any](ctx context.Context, d *DualWrite, readOld, readNew func(ctx context.Context) (T, error)) (T, error) {
+	return ReadFunc(ctx, d, readOld, readNew, func(a, b T) bool {
+		return reflect.DeepEqual(a, b)
+	})
+}
+
+// ReadFunc is like Read but compares results with equal, e.g. to ignore
+// fields that only exist in one schema
+func ReadFunc[T any](
+	ctx context.Context,
+	d *DualWrite,
+	readOld, readNew func(ctx context.Context) (T, error),
+	equal func(a, b T) bool,
+) (T, error) {
+	phase := d.Phase()
+	switch phase {
+	case PhaseOld:
+		return readOld(ctx)
+	case PhaseNew:
+		return readNew(ctx)
+	}
+
+	primary, shadow := readOld, readNew
+	if phase == PhaseReadNew {
+		primary, shadow = readNew, readOld
+	}
+
+	result, err := primary(ctx)
+	if err != nil || d.compareRate <= 0 || rand.Float64() >= d.compareRate {
+		return result, err
+	}
+
+	other, shadowErr := shadow(ctx)
+	switch {
+	case shadowErr != nil:
+		d.logger.Warn().Err(shadowErr).Str("refactor", d.name).Str("phase", string(phase)).
+			Msg("Refactor shadow read failed")
+	case !equal(result, other):
+		d.logger.Warn().Str("refactor", d.name).Str("phase", string(phase)).
+			Interface("read", result).
+			Interface("shadow", other).
+			Msg("Refactor read mismatch")
+	}
+
+	return result, nil
+}
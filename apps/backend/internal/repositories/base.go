@@ -0,0 +1,281 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	pgx "github.com/jackc/pgx/v5"
+
+	"github.com/PrinceNarteh/go-boilerplate/internal/database"
+	"github.com/PrinceNarteh/go-boilerplate/internal/errs"
+	"github.com/PrinceNarteh/go-boilerplate/internal/libs/pagination"
+)
+
+// Table describes how a model of type T is stored, for use with Base
+type Table[T any] struct {
+	// Name is the table name
+	Name string
+	// Entity names a single row in error messages, e.g. "user"
+	Entity string
+	// IDColumn is the integer primary key column, "id" when empty
+	IDColumn string
+	// Columns are returned by every query, in the order read by Scan
+	Columns []string
+	// Writable are the columns set by Create and Update, in the order returned by Values
+	Writable []string
+	// Timestamps sets created_at on Create and updated_at on Create and Update
+	Timestamps bool
+	// SoftDelete marks tables with a nullable deleted_at column. Rows with a
+	// deleted_at are hidden from GetByID, Update and List unless the context
+	// comes from IncludeDeleted.
+	SoftDelete bool
+	// Scan reads a row made of Columns
+	Scan func(row pgx.Row) (*T, error)
+	// Values returns the values of the Writable columns of item
+	Values func(item *T) []any
+	// ID returns the primary key of item
+	ID func(item *T) int
+	// Version returns the version of item for optimistic locking. When set,
+	// the table has an integer version column incremented on every change,
+	// and Update only succeeds if item still has the stored version.
+	Version func(item *T) int
+}
+
+// Base implements the common CRUD operations of a repository from table
+// metadata, so that a new resource only declares its columns and how to
+// scan them. Resource repositories embed it and add their own queries.
+type Base[T any] struct {
+	db    database.Pools
+	table Table[T]
+}
+
+// NewBase creates a new repository base for the given table
+func NewBase[T any](db database.Pools, table Table[T]) *Base[T] {
+	if table.IDColumn == "" {
+		table.IDColumn = "id"
+	}
+	return &Base[T]{db: db, table: table}
+}
+
+// Create inserts item and returns the stored row
+func (b *Base[T]) Create(ctx context.Context, item *T) (*T, error) {
+	columns := b.table.Writable
+	values := b.table.Values(item)
+
+	placeholders := make([]string, len(columns))
+	for i := range columns {
+		placeholders[i] = fmt.Sprintf("$%d", i+1)
+	}
+	if b.table.Timestamps {
+		columns = append(columns[:len(columns):len(columns)], "created_at", "updated_at")
+		placeholders = append(placeholders, "NOW()", "NOW()")
+	}
+
+	query := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s) RETURNING %s",
+		b.table.Name, strings.Join(columns, ", "), strings.Join(placeholders, ", "), b.columns())
+
+	created, err := b.table.Scan(database.Write(ctx, b.db).QueryRow(ctx, query, values...))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s: %w", b.table.Entity, database.TranslateError(err))
+	}
+
+	return created, nil
+}
+
+// GetByID retrieves a row by primary key.
+// It returns an error wrapping errs.ErrNotFound when there is none.
+func (b *Base[T]) GetByID(ctx context.Context, id int) (*T, error) {
+	query := fmt.Sprintf("SELECT %s FROM %s WHERE %s = $1%s",
+		b.columns(), b.table.Name, b.table.IDColumn, b.notDeleted(ctx))
+
+	item, err := b.table.Scan(database.Read(ctx, b.db).QueryRow(ctx, query, id))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get %s by id: %w", b.table.Entity, database.TranslateError(err))
+	}
+
+	return item, nil
+}
+
+// GetByIDs retrieves the rows with the given primary keys, in no particular
+// order. IDs without a row are left out rather than reported as errors.
+func (b *Base[T]) GetByIDs(ctx context.Context, ids []int) ([]*T, error) {
+	query := fmt.Sprintf("SELECT %s FROM %s WHERE %s = ANY($1)%s",
+		b.columns(), b.table.Name, b.table.IDColumn, b.notDeleted(ctx))
+
+	rows, err := database.Read(ctx, b.db).Query(ctx, query, ids)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get %s by ids: %w", b.table.Entity, database.TranslateError(err))
+	}
+	defer rows.Close()
+
+	items := make([]*T, 0, len(ids))
+	for rows.Next() {
+		item, err := b.table.Scan(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan %s: %w", b.table.Entity, err)
+		}
+		items = append(items, item)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+
+	return items, nil
+}
+
+// Update writes the Writable columns of item and returns the stored row.
+// It returns an error wrapping errs.ErrNotFound when the row does not exist.
+// On versioned tables, it returns an error wrapping errs.ErrConflict when the
+// row was modified since item was read.
+func (b *Base[T]) Update(ctx context.Context, item *T) (*T, error) {
+	assignments := make([]string, len(b.table.Writable))
+	for i, column := range b.table.Writable {
+		assignments[i] = fmt.Sprintf("%s = $%d", column, i+2)
+	}
+	assignments = append(assignments, b.touch()...)
+
+	args := append([]any{b.table.ID(item)}, b.table.Values(item)...)
+	where := fmt.Sprintf("%s = $1%s", b.table.IDColumn, b.notDeleted(ctx))
+	if b.table.Version != nil {
+		args = append(args, b.table.Version(item))
+		where += fmt.Sprintf(" AND version = $%d", len(args))
+	}
+
+	query := fmt.Sprintf("UPDATE %s SET %s WHERE %s RETURNING %s",
+		b.table.Name, strings.Join(assignments, ", "), where, b.columns())
+
+	conn := database.Write(ctx, b.db)
+	updated, err := b.table.Scan(conn.QueryRow(ctx, query, args...))
+	if errors.Is(err, pgx.ErrNoRows) && b.table.Version != nil {
+		// Tell a stale version apart from a missing row
+		var exists bool
+		existsQuery := fmt.Sprintf("SELECT EXISTS (SELECT 1 FROM %s WHERE %s = $1%s)",
+			b.table.Name, b.table.IDColumn, b.notDeleted(ctx))
+		if existsErr := conn.QueryRow(ctx, existsQuery, b.table.ID(item)).Scan(&exists); existsErr != nil {
+			err = existsErr
+		} else if exists {
+			err = errs.ErrConflict
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to update %s: %w", b.table.Entity, database.TranslateError(err))
+	}
+
+	return updated, nil
+}
+
+// Delete permanently deletes a row by primary key, including soft-deleted rows.
+// It returns an error wrapping errs.ErrNotFound when the row does not exist.
+func (b *Base[T]) Delete(ctx context.Context, id int) error {
+	query := fmt.Sprintf("DELETE FROM %s WHERE %s = $1", b.table.Name, b.table.IDColumn)
+
+	tag, err := database.Write(ctx, b.db).Exec(ctx, query, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete %s: %w", b.table.Entity, database.TranslateError(err))
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("failed to delete %s: %w", b.table.Entity, database.TranslateError(pgx.ErrNoRows))
+	}
+
+	return nil
+}
+
+// SoftDelete sets deleted_at on a row, hiding it from subsequent queries.
+// It returns an error wrapping errs.ErrNotFound when the row does not exist or
+// is already deleted.
+func (b *Base[T]) SoftDelete(ctx context.Context, id int) error {
+	set := strings.Join(append([]string{"deleted_at = NOW()"}, b.touch()...), ", ")
+	query := fmt.Sprintf("UPDATE %s SET %s WHERE %s = $1 AND deleted_at IS NULL", b.table.Name, set, b.table.IDColumn)
+
+	tag, err := database.Write(ctx, b.db).Exec(ctx, query, id)
+	if err != nil {
+		return fmt.Errorf("failed to soft delete %s: %w", b.table.Entity, database.TranslateError(err))
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("failed to soft delete %s: %w", b.table.Entity, database.TranslateError(pgx.ErrNoRows))
+	}
+
+	return nil
+}
+
+// Restore clears deleted_at on a soft-deleted row and returns the stored row.
+// It returns an error wrapping errs.ErrNotFound when there is no such deleted row.
+func (b *Base[T]) Restore(ctx context.Context, id int) (*T, error) {
+	set := strings.Join(append([]string{"deleted_at = NULL"}, b.touch()...), ", ")
+	query := fmt.Sprintf("UPDATE %s SET %s WHERE %s = $1 AND deleted_at IS NOT NULL RETURNING %s",
+		b.table.Name, set, b.table.IDColumn, b.columns())
+
+	restored, err := b.table.Scan(database.Write(ctx, b.db).QueryRow(ctx, query, id))
+	if err != nil {
+		return nil, fmt.Errorf("failed to restore %s: %w", b.table.Entity, database.TranslateError(err))
+	}
+
+	return restored, nil
+}
+
+// List retrieves a page of rows matching the filters of params, in its sort
+// order, together with the total number of matching rows
+func (b *Base[T]) List(ctx context.Context, params pagination.Params) ([]*T, int, error) {
+	where, args := params.Where(1)
+	where += b.notDeleted(ctx)
+	conn := database.Read(ctx, b.db)
+
+	var total int
+	countQuery := fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE %s", b.table.Name, where)
+	if err := conn.QueryRow(ctx, countQuery, args...).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to count %s: %w", b.table.Name, database.TranslateError(err))
+	}
+
+	query := fmt.Sprintf("SELECT %s FROM %s WHERE %s %s %s",
+		b.columns(), b.table.Name, where, params.OrderBy(), params.LimitOffset())
+
+	rows, err := conn.Query(ctx, query, args...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list %s: %w", b.table.Name, database.TranslateError(err))
+	}
+	defer rows.Close()
+
+	var items []*T
+	for rows.Next() {
+		item, err := b.table.Scan(rows)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to scan %s: %w", b.table.Entity, err)
+		}
+		items = append(items, item)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("rows error: %w", err)
+	}
+
+	return items, total, nil
+}
+
+// touch returns the assignments recording a change of a row:
+// a new updated_at and the next version, where the table has them
+func (b *Base[T]) touch() []string {
+	var assignments []string
+	if b.table.Timestamps {
+		assignments = append(assignments, "updated_at = NOW()")
+	}
+	if b.table.Version != nil {
+		assignments = append(assignments, "version = version + 1")
+	}
+	return assignments
+}
+
+// notDeleted returns the condition excluding soft-deleted rows, if the table has any
+func (b *Base[T]) notDeleted(ctx context.Context) string {
+	if !b.table.SoftDelete {
+		return ""
+	}
+	return notDeleted(ctx)
+}
+
+// columns returns the comma-separated list of returned columns
+func (b *Base[T]) columns() string {
+	return strings.Join(b.table.Columns, ", ")
+}
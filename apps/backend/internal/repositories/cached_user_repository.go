@@ -0,0 +1,81 @@
+package repositories
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/PrinceNarteh/go-boilerplate/internal/cache"
+	"github.com/PrinceNarteh/go-boilerplate/internal/database"
+	"github.com/PrinceNarteh/go-boilerplate/internal/models"
+)
+
+// cachedUserRepository decorates a UserRepository with a cache of users by ID
+type cachedUserRepository struct {
+	UserRepository
+	cache *cache.Cache[models.User]
+}
+
+// NewCachedUserRepository caches the users read by GetByID in front of repo
+// and invalidates them on every write. Reads within a transaction or
+// including soft-deleted users bypass the cache, so uncommitted or deleted
+// rows are never cached.
+func NewCachedUserRepository(repo UserRepository, users *cache.Cache[models.User]) UserRepository {
+	return &cachedUserRepository{
+		UserRepository: repo,
+		cache:          users,
+	}
+}
+
+// GetByID retrieves a user by ID from the cache, or from the repository on a miss
+func (r *cachedUserRepository) GetByID(ctx context.Context, id int) (*models.User, error) {
+	if _, inTx := database.TxFromContext(ctx); inTx || includesDeleted(ctx) {
+		return r.UserRepository.GetByID(ctx, id)
+	}
+
+	return r.cache.GetOrLoad(ctx, userCacheKey(id), func(ctx context.Context) (*models.User, error) {
+		return r.UserRepository.GetByID(ctx, id)
+	})
+}
+
+// Update updates a user and invalidates its cache entry
+func (r *cachedUserRepository) Update(ctx context.Context, user *models.User) (*models.User, error) {
+	defer r.invalidate(ctx, user.ID)
+	return r.UserRepository.Update(ctx, user)
+}
+
+// Delete deletes a user and invalidates its cache entry
+func (r *cachedUserRepository) Delete(ctx context.Context, id int) error {
+	defer r.invalidate(ctx, id)
+	return r.UserRepository.Delete(ctx, id)
+}
+
+// SoftDelete soft-deletes a user and invalidates its cache entry
+func (r *cachedUserRepository) SoftDelete(ctx context.Context, id int) error {
+	defer r.invalidate(ctx, id)
+	return r.UserRepository.SoftDelete(ctx, id)
+}
+
+// Restore restores a soft-deleted user and invalidates its cache entry
+func (r *cachedUserRepository) Restore(ctx context.Context, id int) (*models.User, error) {
+	defer r.invalidate(ctx, id)
+	return r.UserRepository.Restore(ctx, id)
+}
+
+// SetPasswordHash sets the password hash of a user and invalidates its cache
+// entry, whose update time changes
+func (r *cachedUserRepository) SetPasswordHash(ctx context.Context, id int, hash string) error {
+	defer r.invalidate(ctx, id)
+	return r.UserRepository.SetPasswordHash(ctx, id, hash)
+}
+
+// invalidate removes a user from the cache. Entries are removed even when
+// the write fails, as the outcome of a failed write is not always known.
+// Failures are ignored: entries still expire after their TTL.
+func (r *cachedUserRepository) invalidate(ctx context.Context, id int) {
+	_ = r.cache.Delete(context.WithoutCancel(ctx), userCacheKey(id))
+}
+
+// userCacheKey returns the cache key of a user
+func userCacheKey(id int) string {
+	return strconv.Itoa(id)
+}
@@ -0,0 +1,216 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/PrinceNarteh/go-boilerplate/internal/database"
+	"github.com/PrinceNarteh/go-boilerplate/internal/models"
+)
+
+// ConsentRepository defines the interface for consent data access
+type ConsentRepository interface {
+	CreateDocument(ctx context.Context, doc *models.ConsentDocument) (*models.ConsentDocument, error)
+	GetDocument(ctx context.Context, id int) (*models.ConsentDocument, error)
+	LatestDocuments(ctx context.Context) ([]*models.ConsentDocument, error)
+	Accept(ctx context.Context, consent *models.UserConsent) (*models.UserConsent, error)
+	ListByUser(ctx context.Context, userID int) ([]*models.UserConsent, error)
+	PendingDocuments(ctx context.Context, userID int) ([]*models.ConsentDocument, error)
+	PendingConsents(ctx context.Context, userID int) ([]string, error)
+}
+
+// consentRepository implements ConsentRepository
+type consentRepository struct {
+	db database.Pools
+}
+
+// NewConsentRepository creates a new consent repository
+func NewConsentRepository(db database.Pools) ConsentRepository {
+	return &consentRepository{db: db}
+}
+
+// latestDocumentsQuery selects the most recently published version of each document kind
+const latestDocumentsQuery = `
+	SELECT DISTINCT ON (kind) id, kind, version, url, published_at
+	FROM consent_documents
+	ORDER BY kind, published_at DESC, id DESC`
+
+// CreateDocument publishes a new document version
+func (r *consentRepository) CreateDocument(ctx context.Context, doc *models.ConsentDocument) (*models.ConsentDocument, error) {
+	query := `
+		INSERT INTO consent_documents (kind, version, url, published_at)
+		VALUES ($1, $2, $3, NOW())
+		RETURNING id, kind, version, url, published_at`
+
+	var created models.ConsentDocument
+	err := database.Write(ctx, r.db).QueryRow(ctx, query, doc.Kind, doc.Version, doc.URL).Scan(
+		&created.ID,
+		&created.Kind,
+		&created.Version,
+		&created.URL,
+		&created.PublishedAt,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create consent document: %w", database.TranslateError(err))
+	}
+
+	return &created, nil
+}
+
+// GetDocument retrieves a document version by ID
+func (r *consentRepository) GetDocument(ctx context.Context, id int) (*models.ConsentDocument, error) {
+	query := `SELECT id, kind, version, url, published_at FROM consent_documents WHERE id = $1`
+
+	var doc models.ConsentDocument
+	err := database.Read(ctx, r.db).QueryRow(ctx, query, id).Scan(
+		&doc.ID,
+		&doc.Kind,
+		&doc.Version,
+		&doc.URL,
+		&doc.PublishedAt,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get consent document: %w", database.TranslateError(err))
+	}
+
+	return &doc, nil
+}
+
+// LatestDocuments retrieves the current version of every document kind
+func (r *consentRepository) LatestDocuments(ctx context.Context) ([]*models.ConsentDocument, error) {
+	return r.queryDocuments(ctx, latestDocumentsQuery)
+}
+
+// Accept records that a user accepted a document version.
+// Accepting the same version twice keeps the original acceptance.
+func (r *consentRepository) Accept(ctx context.Context, consent *models.UserConsent) (*models.UserConsent, error) {
+	query := `
+		WITH inserted AS (
+			INSERT INTO user_consents (user_id, document_id, ip, user_agent, accepted_at)
+			VALUES ($1, $2, $3, $4, NOW())
+			ON CONFLICT (user_id, document_id) DO UPDATE SET user_id = EXCLUDED.user_id
+			RETURNING id, user_id, document_id, ip, user_agent, accepted_at
+		)
+		SELECT i.id, i.user_id, i.document_id, d.kind, d.version, i.ip, i.user_agent, i.accepted_at
+		FROM inserted i
+		JOIN consent_documents d ON d.id = i.document_id`
+
+	var accepted models.UserConsent
+	err := database.Write(ctx, r.db).QueryRow(ctx, query,
+		consent.UserID, consent.DocumentID, consent.IP, consent.UserAgent,
+	).Scan(
+		&accepted.ID,
+		&accepted.UserID,
+		&accepted.DocumentID,
+		&accepted.Kind,
+		&accepted.Version,
+		&accepted.IP,
+		&accepted.UserAgent,
+		&accepted.AcceptedAt,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to accept consent: %w", database.TranslateError(err))
+	}
+
+	return &accepted, nil
+}
+
+// ListByUser retrieves all acceptances of a user, newest first
+func (r *consentRepository) ListByUser(ctx context.Context, userID int) ([]*models.UserConsent, error) {
+	query := `
+		SELECT c.id, c.user_id, c.document_id, d.kind, d.version, c.ip, c.user_agent, c.accepted_at
+		FROM user_consents c
+		JOIN consent_documents d ON d.id = c.document_id
+		WHERE c.user_id = $1
+		ORDER BY c.accepted_at DESC`
+
+	rows, err := database.Read(ctx, r.db).Query(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list user consents: %w", database.TranslateError(err))
+	}
+	defer rows.Close()
+
+	var consents []*models.UserConsent
+	for rows.Next() {
+		var consent models.UserConsent
+		err := rows.Scan(
+			&consent.ID,
+			&consent.UserID,
+			&consent.DocumentID,
+			&consent.Kind,
+			&consent.Version,
+			&consent.IP,
+			&consent.UserAgent,
+			&consent.AcceptedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan user consent: %w", err)
+		}
+		consents = append(consents, &consent)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+
+	return consents, nil
+}
+
+// PendingDocuments retrieves the current document versions the user has not accepted
+func (r *consentRepository) PendingDocuments(ctx context.Context, userID int) ([]*models.ConsentDocument, error) {
+	query := `
+		SELECT l.id, l.kind, l.version, l.url, l.published_at
+		FROM (` + latestDocumentsQuery + `) l
+		WHERE NOT EXISTS (
+			SELECT 1 FROM user_consents c WHERE c.document_id = l.id AND c.user_id = $1
+		)
+		ORDER BY l.kind`
+
+	// Read from the primary so that an acceptance applies on the next request
+	return r.queryDocuments(database.WithPrimary(ctx), query, userID)
+}
+
+// PendingConsents returns the kinds of documents the user must (re-)accept
+func (r *consentRepository) PendingConsents(ctx context.Context, userID int) ([]string, error) {
+	docs, err := r.PendingDocuments(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	kinds := make([]string, len(docs))
+	for i, doc := range docs {
+		kinds[i] = doc.Kind
+	}
+	return kinds, nil
+}
+
+// queryDocuments runs a query returning consent documents
+func (r *consentRepository) queryDocuments(ctx context.Context, query string, args ...any) ([]*models.ConsentDocument, error) {
+	rows, err := database.Read(ctx, r.db).Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list consent documents: %w", database.TranslateError(err))
+	}
+	defer rows.Close()
+
+	var docs []*models.ConsentDocument
+	for rows.Next() {
+		var doc models.ConsentDocument
+		err := rows.Scan(
+			&doc.ID,
+			&doc.Kind,
+			&doc.Version,
+			&doc.URL,
+			&doc.PublishedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan consent document: %w", err)
+		}
+		docs = append(docs, &doc)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+
+	return docs, nil
+}
@@ -0,0 +1,200 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	pgx "github.com/jackc/pgx/v5"
+
+	"github.com/PrinceNarteh/go-boilerplate/internal/database"
+	"github.com/PrinceNarteh/go-boilerplate/internal/models"
+)
+
+// ErrEmailChanged is returned when confirming or reverting an email change
+// whose starting address is no longer the user's current address
+var ErrEmailChanged = errors.New("user email changed since the request")
+
+// EmailChangeRepository defines the interface for email change data access
+type EmailChangeRepository interface {
+	Create(ctx context.Context, change *models.EmailChange, tokenHash string) (*models.EmailChange, error)
+	GetPending(ctx context.Context, userID int) (*models.EmailChange, error)
+	CancelPending(ctx context.Context, userID int) error
+	Confirm(ctx context.Context, tokenHash, revertTokenHash string, revertWindow time.Duration) (*models.EmailChange, error)
+	Revert(ctx context.Context, revertTokenHash string) (*models.EmailChange, error)
+}
+
+// emailChangeRepository implements EmailChangeRepository
+type emailChangeRepository struct {
+	db database.Pools
+}
+
+// NewEmailChangeRepository creates a new email change repository
+func NewEmailChangeRepository(db database.Pools) EmailChangeRepository {
+	return &emailChangeRepository{db: db}
+}
+
+// emailChangeColumns are the columns scanned by scanEmailChange
+const emailChangeColumns = `
+	id, user_id, old_email, new_email, status, expires_at,
+	confirmed_at, revert_until, reverted_at, created_at`
+
+// Create stores a new pending email change, cancelling any previous pending change of the user
+func (r *emailChangeRepository) Create(
+	ctx context.Context,
+	change *models.EmailChange,
+	tokenHash string,
+) (*models.EmailChange, error) {
+	var created *models.EmailChange
+	err := pgx.BeginFunc(ctx, database.Write(ctx, r.db), func(tx pgx.Tx) error {
+		if err := cancelPending(ctx, tx, change.UserID); err != nil {
+			return err
+		}
+
+		query := `
+			INSERT INTO email_changes (user_id, old_email, new_email, status, token_hash, expires_at, created_at)
+			VALUES ($1, $2, $3, 'pending', $4, $5, NOW())
+			RETURNING` + emailChangeColumns
+
+		var err error
+		created, err = scanEmailChange(tx.QueryRow(ctx, query,
+			change.UserID, change.OldEmail, change.NewEmail, tokenHash, change.ExpiresAt,
+		))
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create email change: %w", database.TranslateError(err))
+	}
+
+	return created, nil
+}
+
+// GetPending retrieves the unexpired pending email change of a user
+func (r *emailChangeRepository) GetPending(ctx context.Context, userID int) (*models.EmailChange, error) {
+	query := `
+		SELECT` + emailChangeColumns + `
+		FROM email_changes
+		WHERE user_id = $1 AND status = 'pending' AND expires_at > NOW()
+		ORDER BY created_at DESC
+		LIMIT 1`
+
+	change, err := scanEmailChange(database.Read(ctx, r.db).QueryRow(ctx, query, userID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pending email change: %w", database.TranslateError(err))
+	}
+
+	return change, nil
+}
+
+// CancelPending cancels the pending email changes of a user
+func (r *emailChangeRepository) CancelPending(ctx context.Context, userID int) error {
+	if err := cancelPending(ctx, database.Write(ctx, r.db), userID); err != nil {
+		return fmt.Errorf("failed to cancel email change: %w", database.TranslateError(err))
+	}
+	return nil
+}
+
+// Confirm applies the pending email change matching tokenHash to the user and
+// opens a window during which it can be reverted with revertTokenHash.
+// It returns an error wrapping errs.ErrNotFound when no unexpired pending change matches.
+func (r *emailChangeRepository) Confirm(
+	ctx context.Context,
+	tokenHash, revertTokenHash string,
+	revertWindow time.Duration,
+) (*models.EmailChange, error) {
+	query := `
+		UPDATE email_changes
+		SET status = 'confirmed', confirmed_at = NOW(),
+			revert_token_hash = $2, revert_until = NOW() + $3::interval
+		WHERE token_hash = $1 AND status = 'pending' AND expires_at > NOW()
+		RETURNING` + emailChangeColumns
+
+	var change *models.EmailChange
+	err := pgx.BeginFunc(ctx, database.Write(ctx, r.db), func(tx pgx.Tx) error {
+		var err error
+		change, err = scanEmailChange(tx.QueryRow(ctx, query, tokenHash, revertTokenHash, revertWindow))
+		if err != nil {
+			return err
+		}
+		return setUserEmail(ctx, tx, change.UserID, change.OldEmail, change.NewEmail)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to confirm email change: %w", database.TranslateError(err))
+	}
+
+	return change, nil
+}
+
+// Revert restores the previous email address of a confirmed change while
+// its revert window is open. It returns an error wrapping errs.ErrNotFound
+// when no revertible change matches revertTokenHash.
+func (r *emailChangeRepository) Revert(ctx context.Context, revertTokenHash string) (*models.EmailChange, error) {
+	query := `
+		UPDATE email_changes
+		SET status = 'reverted', reverted_at = NOW()
+		WHERE revert_token_hash = $1 AND status = 'confirmed' AND revert_until > NOW()
+		RETURNING` + emailChangeColumns
+
+	var change *models.EmailChange
+	err := pgx.BeginFunc(ctx, database.Write(ctx, r.db), func(tx pgx.Tx) error {
+		var err error
+		change, err = scanEmailChange(tx.QueryRow(ctx, query, revertTokenHash))
+		if err != nil {
+			return err
+		}
+		return setUserEmail(ctx, tx, change.UserID, change.NewEmail, change.OldEmail)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to revert email change: %w", database.TranslateError(err))
+	}
+
+	return change, nil
+}
+
+// cancelPending cancels the pending email changes of a user
+func cancelPending(ctx context.Context, q database.Querier, userID int) error {
+	query := `UPDATE email_changes SET status = 'cancelled' WHERE user_id = $1 AND status = 'pending'`
+
+	_, err := q.Exec(ctx, query, userID)
+	return err
+}
+
+// setUserEmail changes a user's email from one address to another, failing
+// with ErrEmailChanged when the user's current address is not from
+func setUserEmail(ctx context.Context, tx pgx.Tx, userID int, from, to string) error {
+	query := `
+		UPDATE users
+		SET email = $3, updated_at = NOW(), version = version + 1
+		WHERE id = $1 AND email = $2 AND deleted_at IS NULL`
+
+	tag, err := tx.Exec(ctx, query, userID, from, to)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrEmailChanged
+	}
+	return nil
+}
+
+// scanEmailChange scans a row selected with emailChangeColumns
+func scanEmailChange(row pgx.Row) (*models.EmailChange, error) {
+	var change models.EmailChange
+	err := row.Scan(
+		&change.ID,
+		&change.UserID,
+		&change.OldEmail,
+		&change.NewEmail,
+		&change.Status,
+		&change.ExpiresAt,
+		&change.ConfirmedAt,
+		&change.RevertUntil,
+		&change.RevertedAt,
+		&change.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &change, nil
+}
@@ -0,0 +1,130 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+
+	pgx "github.com/jackc/pgx/v5"
+
+	"github.com/PrinceNarteh/go-boilerplate/internal/database"
+	"github.com/PrinceNarteh/go-boilerplate/internal/models"
+)
+
+// FileRepository defines the interface for uploaded file data access
+type FileRepository interface {
+	Create(ctx context.Context, file *models.File) (*models.File, error)
+	GetByID(ctx context.Context, id int) (*models.File, error)
+	SetScanResult(ctx context.Context, id int, status models.ScanStatus, threat string) (*models.File, error)
+	ListPendingScan(ctx context.Context, limit int) ([]*models.File, error)
+}
+
+// fileTable describes the files table for the repository base
+var fileTable = Table[models.File]{
+	Name:   "files",
+	Entity: "file",
+	Columns: []string{
+		"id", "user_id", "filename", "content_type", "size", "storage_key",
+		"scan_status", "scan_threat", "scanned_at", "created_at", "updated_at",
+	},
+	Writable:   []string{"user_id", "filename", "content_type", "size", "storage_key", "scan_status", "scan_threat"},
+	Timestamps: true,
+	Scan:       scanFile,
+	Values: func(file *models.File) []any {
+		return []any{
+			file.UserID, file.Filename, file.ContentType, file.Size,
+			file.StorageKey, file.ScanStatus, file.ScanThreat,
+		}
+	},
+	ID: func(file *models.File) int {
+		return file.ID
+	},
+}
+
+// fileRepository implements FileRepository.
+// Create and GetByID are provided by the embedded Base.
+type fileRepository struct {
+	*Base[models.File]
+	db database.Pools
+}
+
+// NewFileRepository creates a new file repository
+func NewFileRepository(db database.Pools) FileRepository {
+	return &fileRepository{
+		Base: NewBase(db, fileTable),
+		db:   db,
+	}
+}
+
+// SetScanResult records the outcome of a malware scan of a file
+func (r *fileRepository) SetScanResult(
+	ctx context.Context,
+	id int,
+	status models.ScanStatus,
+	threat string,
+) (*models.File, error) {
+	query := fmt.Sprintf(`
+		UPDATE files
+		SET scan_status = $2, scan_threat = $3, scanned_at = NOW(), updated_at = NOW()
+		WHERE id = $1
+		RETURNING %s`, r.columns())
+
+	file, err := scanFile(database.Write(ctx, r.db).QueryRow(ctx, query, id, status, threat))
+	if err != nil {
+		return nil, fmt.Errorf("failed to set file scan result: %w", database.TranslateError(err))
+	}
+
+	return file, nil
+}
+
+// ListPendingScan retrieves the oldest files that still have to be scanned
+func (r *fileRepository) ListPendingScan(ctx context.Context, limit int) ([]*models.File, error) {
+	query := fmt.Sprintf(`
+		SELECT %s
+		FROM files
+		WHERE scan_status = $1
+		ORDER BY created_at
+		LIMIT $2`, r.columns())
+
+	rows, err := database.Read(ctx, r.db).Query(ctx, query, models.ScanPending, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list files pending scan: %w", database.TranslateError(err))
+	}
+	defer rows.Close()
+
+	var files []*models.File
+	for rows.Next() {
+		file, err := scanFile(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan file: %w", err)
+		}
+		files = append(files, file)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+
+	return files, nil
+}
+
+// scanFile scans a row of the files table columns
+func scanFile(row pgx.Row) (*models.File, error) {
+	var file models.File
+	err := row.Scan(
+		&file.ID,
+		&file.UserID,
+		&file.Filename,
+		&file.ContentType,
+		&file.Size,
+		&file.StorageKey,
+		&file.ScanStatus,
+		&file.ScanThreat,
+		&file.ScannedAt,
+		&file.CreatedAt,
+		&file.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &file, nil
+}
@@ -0,0 +1,85 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/PrinceNarteh/go-boilerplate/internal/database"
+	"github.com/PrinceNarteh/go-boilerplate/internal/models"
+)
+
+// NotificationPreferenceRepository defines the interface for notification preference data access
+type NotificationPreferenceRepository interface {
+	ListByUser(ctx context.Context, userID int) ([]*models.NotificationPreference, error)
+	Set(ctx context.Context, userID int, prefs []*models.NotificationPreference) error
+}
+
+// notificationPreferenceRepository implements NotificationPreferenceRepository
+type notificationPreferenceRepository struct {
+	db database.Pools
+}
+
+// NewNotificationPreferenceRepository creates a new notification preference repository
+func NewNotificationPreferenceRepository(db database.Pools) NotificationPreferenceRepository {
+	return &notificationPreferenceRepository{db: db}
+}
+
+// ListByUser retrieves the preferences a user set
+func (r *notificationPreferenceRepository) ListByUser(
+	ctx context.Context,
+	userID int,
+) ([]*models.NotificationPreference, error) {
+	query := `
+		SELECT user_id, kind, channel, enabled, updated_at
+		FROM notification_preferences
+		WHERE user_id = $1
+		ORDER BY kind, channel`
+
+	rows, err := database.Read(ctx, r.db).Query(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list notification preferences: %w", database.TranslateError(err))
+	}
+	defer rows.Close()
+
+	var prefs []*models.NotificationPreference
+	for rows.Next() {
+		var pref models.NotificationPreference
+		if err := rows.Scan(&pref.UserID, &pref.Kind, &pref.Channel, &pref.Enabled, &pref.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan notification preference: %w", err)
+		}
+		prefs = append(prefs, &pref)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+
+	return prefs, nil
+}
+
+// Set records the preferences of a user at once, replacing those already
+// set for the same kinds and channels
+func (r *notificationPreferenceRepository) Set(
+	ctx context.Context,
+	userID int,
+	prefs []*models.NotificationPreference,
+) error {
+	kinds := make([]string, len(prefs))
+	channels := make([]string, len(prefs))
+	enabled := make([]bool, len(prefs))
+	for i, pref := range prefs {
+		kinds[i], channels[i], enabled[i] = pref.Kind, pref.Channel, pref.Enabled
+	}
+
+	query := `
+		INSERT INTO notification_preferences (user_id, kind, channel, enabled, updated_at)
+		SELECT $1, p.kind, p.channel, p.enabled, NOW()
+		FROM unnest($2::text[], $3::text[], $4::boolean[]) AS p (kind, channel, enabled)
+		ON CONFLICT (user_id, kind, channel)
+		DO UPDATE SET enabled = EXCLUDED.enabled, updated_at = EXCLUDED.updated_at`
+
+	if _, err := database.Write(ctx, r.db).Exec(ctx, query, userID, kinds, channels, enabled); err != nil {
+		return fmt.Errorf("failed to set notification preferences: %w", database.TranslateError(err))
+	}
+	return nil
+}
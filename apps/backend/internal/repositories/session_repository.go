@@ -0,0 +1,151 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+
+	pgx "github.com/jackc/pgx/v5"
+
+	"github.com/PrinceNarteh/go-boilerplate/internal/database"
+	"github.com/PrinceNarteh/go-boilerplate/internal/libs/id"
+	"github.com/PrinceNarteh/go-boilerplate/internal/models"
+)
+
+// SessionRepository defines the interface for session data access
+type SessionRepository interface {
+	Create(ctx context.Context, session *models.Session) (*models.Session, error)
+	Get(ctx context.Context, id id.ID) (*models.Session, error)
+	ListActive(ctx context.Context, userID int) ([]*models.Session, error)
+	Revoke(ctx context.Context, userID int, id id.ID) error
+	RevokeOthers(ctx context.Context, userID int, keep id.ID) (int64, error)
+}
+
+// sessionColumns are the columns selected for a session, in scan order
+const sessionColumns = ` id, user_id, ip, user_agent, created_at, expires_at, revoked_at`
+
+// sessionRepository implements SessionRepository
+type sessionRepository struct {
+	db database.Pools
+}
+
+// NewSessionRepository creates a new session repository
+func NewSessionRepository(db database.Pools) SessionRepository {
+	return &sessionRepository{db: db}
+}
+
+// Create stores a new session, with a new ID unless it has one
+func (r *sessionRepository) Create(ctx context.Context, session *models.Session) (*models.Session, error) {
+	query := `
+		INSERT INTO sessions (id, user_id, ip, user_agent, expires_at)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING` + sessionColumns
+
+	sessionID := session.ID
+	if sessionID.IsZero() {
+		sessionID = id.New()
+	}
+	created, err := scanSession(database.Write(ctx, r.db).QueryRow(ctx, query,
+		sessionID, session.UserID, session.IP, session.UserAgent, session.ExpiresAt,
+	))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create session: %w", database.TranslateError(err))
+	}
+
+	return created, nil
+}
+
+// Get retrieves a session by ID, revoked or not.
+// It returns an error wrapping errs.ErrNotFound when there is none.
+func (r *sessionRepository) Get(ctx context.Context, sessionID id.ID) (*models.Session, error) {
+	query := `SELECT` + sessionColumns + ` FROM sessions WHERE id = $1`
+
+	// Read from the primary so that a revocation applies on the next request
+	session, err := scanSession(database.Write(ctx, r.db).QueryRow(ctx, query, sessionID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get session: %w", database.TranslateError(err))
+	}
+
+	return session, nil
+}
+
+// ListActive retrieves the sessions of a user that are neither revoked nor
+// expired, newest first
+func (r *sessionRepository) ListActive(ctx context.Context, userID int) ([]*models.Session, error) {
+	query := `
+		SELECT` + sessionColumns + `
+		FROM sessions
+		WHERE user_id = $1 AND revoked_at IS NULL AND expires_at > NOW()
+		ORDER BY created_at DESC`
+
+	rows, err := database.Read(ctx, r.db).Query(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sessions: %w", database.TranslateError(err))
+	}
+	defer rows.Close()
+
+	var sessions []*models.Session
+	for rows.Next() {
+		session, err := scanSession(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan session: %w", err)
+		}
+		sessions = append(sessions, session)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+
+	return sessions, nil
+}
+
+// Revoke revokes an active session of a user. It returns an error wrapping
+// errs.ErrNotFound when the user has no such active session.
+func (r *sessionRepository) Revoke(ctx context.Context, userID int, sessionID id.ID) error {
+	query := `
+		UPDATE sessions SET revoked_at = NOW()
+		WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL AND expires_at > NOW()`
+
+	tag, err := database.Write(ctx, r.db).Exec(ctx, query, sessionID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to revoke session: %w", database.TranslateError(err))
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("failed to revoke session: %w", database.TranslateError(pgx.ErrNoRows))
+	}
+
+	return nil
+}
+
+// RevokeOthers revokes the active sessions of a user except keep, every
+// one of them when keep is the zero ID, and returns how many it revoked
+func (r *sessionRepository) RevokeOthers(ctx context.Context, userID int, keep id.ID) (int64, error) {
+	query := `
+		UPDATE sessions SET revoked_at = NOW()
+		WHERE user_id = $1 AND revoked_at IS NULL AND expires_at > NOW() AND id IS DISTINCT FROM $2`
+
+	tag, err := database.Write(ctx, r.db).Exec(ctx, query, userID, keep)
+	if err != nil {
+		return 0, fmt.Errorf("failed to revoke sessions: %w", database.TranslateError(err))
+	}
+
+	return tag.RowsAffected(), nil
+}
+
+// scanSession scans a row of the session columns
+func scanSession(row pgx.Row) (*models.Session, error) {
+	var session models.Session
+	err := row.Scan(
+		&session.ID,
+		&session.UserID,
+		&session.IP,
+		&session.UserAgent,
+		&session.CreatedAt,
+		&session.ExpiresAt,
+		&session.RevokedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
@@ -0,0 +1,28 @@
+package repositories
+
+import "context"
+
+// includeDeletedKey is the context key of the IncludeDeleted flag
+type includeDeletedKey struct{}
+
+// IncludeDeleted returns a context under which repositories also return
+// soft-deleted rows. It is meant for admin tooling such as listing or
+// restoring deleted records; regular requests never see them.
+func IncludeDeleted(ctx context.Context) context.Context {
+	return context.WithValue(ctx, includeDeletedKey{}, true)
+}
+
+// includesDeleted reports whether ctx was created by IncludeDeleted
+func includesDeleted(ctx context.Context) bool {
+	include, _ := ctx.Value(includeDeletedKey{}).(bool)
+	return include
+}
+
+// notDeleted returns the condition excluding soft-deleted rows under ctx,
+// to be appended to a WHERE clause
+func notDeleted(ctx context.Context) string {
+	if includesDeleted(ctx) {
+		return ""
+	}
+	return " AND deleted_at IS NULL"
+}
@@ -0,0 +1,129 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+
+	pgx "github.com/jackc/pgx/v5"
+
+	"github.com/PrinceNarteh/go-boilerplate/internal/database"
+	"github.com/PrinceNarteh/go-boilerplate/internal/models"
+)
+
+// TenantRepository defines the interface for the tenant registry and the
+// schemas of tenants
+type TenantRepository interface {
+	Create(ctx context.Context, tenant *models.Tenant) (*models.Tenant, error)
+	Get(ctx context.Context, id string) (*models.Tenant, error)
+	List(ctx context.Context) ([]*models.Tenant, error)
+	Delete(ctx context.Context, id string) error
+	CreateSchema(ctx context.Context, schema string) error
+	DropSchema(ctx context.Context, schema string) error
+}
+
+// tenantRepository implements TenantRepository. The registry is always
+// read from the public schema, whatever the schema of the request.
+type tenantRepository struct {
+	db database.Pools
+}
+
+// NewTenantRepository creates a new tenant repository
+func NewTenantRepository(db database.Pools) TenantRepository {
+	return &tenantRepository{db: db}
+}
+
+// Create registers a tenant
+func (r *tenantRepository) Create(ctx context.Context, tenant *models.Tenant) (*models.Tenant, error) {
+	query := `
+		INSERT INTO public.tenants (id, schema_name, created_at)
+		VALUES ($1, $2, NOW())
+		RETURNING id, schema_name, created_at`
+
+	var created models.Tenant
+	err := database.Write(ctx, r.db).QueryRow(ctx, query, tenant.ID, tenant.Schema).Scan(
+		&created.ID,
+		&created.Schema,
+		&created.CreatedAt,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create tenant: %w", database.TranslateError(err))
+	}
+
+	return &created, nil
+}
+
+// Get retrieves a tenant by ID
+func (r *tenantRepository) Get(ctx context.Context, id string) (*models.Tenant, error) {
+	query := `SELECT id, schema_name, created_at FROM public.tenants WHERE id = $1`
+
+	var tenant models.Tenant
+	err := database.Read(ctx, r.db).QueryRow(ctx, query, id).Scan(
+		&tenant.ID,
+		&tenant.Schema,
+		&tenant.CreatedAt,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tenant: %w", database.TranslateError(err))
+	}
+
+	return &tenant, nil
+}
+
+// List retrieves every tenant, ordered by ID
+func (r *tenantRepository) List(ctx context.Context) ([]*models.Tenant, error) {
+	query := `SELECT id, schema_name, created_at FROM public.tenants ORDER BY id`
+
+	rows, err := database.Read(ctx, r.db).Query(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tenants: %w", database.TranslateError(err))
+	}
+	defer rows.Close()
+
+	var tenants []*models.Tenant
+	for rows.Next() {
+		var tenant models.Tenant
+		if err := rows.Scan(&tenant.ID, &tenant.Schema, &tenant.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan tenant: %w", err)
+		}
+		tenants = append(tenants, &tenant)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+
+	return tenants, nil
+}
+
+// Delete removes a tenant from the registry
+func (r *tenantRepository) Delete(ctx context.Context, id string) error {
+	tag, err := database.Write(ctx, r.db).Exec(ctx, `DELETE FROM public.tenants WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete tenant: %w", database.TranslateError(err))
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("failed to delete tenant: %w", database.TranslateError(pgx.ErrNoRows))
+	}
+
+	return nil
+}
+
+// CreateSchema creates the schema of a tenant, if it does not exist yet
+func (r *tenantRepository) CreateSchema(ctx context.Context, schema string) error {
+	query := `CREATE SCHEMA IF NOT EXISTS ` + pgx.Identifier{schema}.Sanitize()
+	if _, err := database.Write(ctx, r.db).Exec(ctx, query); err != nil {
+		return fmt.Errorf("failed to create schema: %w", database.TranslateError(err))
+	}
+
+	return nil
+}
+
+// DropSchema drops the schema of a tenant with all its tables
+func (r *tenantRepository) DropSchema(ctx context.Context, schema string) error {
+	query := `DROP SCHEMA IF EXISTS ` + pgx.Identifier{schema}.Sanitize() + ` CASCADE`
+	if _, err := database.Write(ctx, r.db).Exec(ctx, query); err != nil {
+		return fmt.Errorf("failed to drop schema: %w", database.TranslateError(err))
+	}
+
+	return nil
+}
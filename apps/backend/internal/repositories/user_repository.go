@@ -3,149 +3,229 @@ package repositories
 import (
 	"context"
 	"fmt"
+	"time"
 
+	"github.com/PrinceNarteh/go-boilerplate/internal/database"
+	"github.com/PrinceNarteh/go-boilerplate/internal/libs"
 	"github.com/PrinceNarteh/go-boilerplate/internal/models"
-	"github.com/jackc/pgx/v5/pgxpool"
+	pgx "github.com/jackc/pgx/v5"
 )
 
 // UserRepository defines the interface for user data access
 type UserRepository interface {
 	Create(ctx context.Context, user *models.User) (*models.User, error)
 	GetByID(ctx context.Context, id int) (*models.User, error)
+	GetByIDs(ctx context.Context, ids []int) ([]*models.User, error)
 	GetByEmail(ctx context.Context, email string) (*models.User, error)
+	GetByIDAsOf(ctx context.Context, id int, asOf time.Time) (*models.User, error)
+	GetPasswordHash(ctx context.Context, id int) (string, error)
+	SetPasswordHash(ctx context.Context, id int, hash string) error
+	GetPasswordChangedAt(ctx context.Context, id int) (*time.Time, error)
 	Update(ctx context.Context, user *models.User) (*models.User, error)
 	Delete(ctx context.Context, id int) error
-	List(ctx context.Context, limit, offset int) ([]*models.User, error)
+	SoftDelete(ctx context.Context, id int) error
+	Restore(ctx context.Context, id int) (*models.User, error)
+	List(ctx context.Context, cursor string, limit int) ([]*models.User, string, error)
+	ListWithOffset(ctx context.Context, limit, offset int) ([]*models.User, error)
 }
 
-// userRepository implements UserRepository
+// userCursor is the keyset position of a user in the list ordering
+type userCursor struct {
+	CreatedAt time.Time `json:"c"`
+	ID        int       `json:"i"`
+}
+
+// userTable describes the users table for the repository base
+var userTable = Table[models.User]{
+	Name:       "users",
+	Entity:     "user",
+	Columns:    []string{"id", "email", "created_at", "updated_at", "deleted_at", "version", "preferences"},
+	Writable:   []string{"email", "preferences"},
+	Timestamps: true,
+	SoftDelete: true,
+	Scan:       scanUser,
+	Values: func(user *models.User) []any {
+		return []any{user.Email, user.Preferences}
+	},
+	ID: func(user *models.User) int {
+		return user.ID
+	},
+	Version: func(user *models.User) int {
+		return user.Version
+	},
+}
+
+// userRepository implements UserRepository.
+// Create, GetByID, GetByIDs, Update, Delete, SoftDelete and Restore are provided by the embedded Base.
 type userRepository struct {
-	db *pgxpool.Pool
+	*Base[models.User]
+	db database.Pools
 }
 
 // NewUserRepository creates a new user repository
-func NewUserRepository(db *pgxpool.Pool) UserRepository {
-	return &userRepository{db: db}
+func NewUserRepository(db database.Pools) UserRepository {
+	return &userRepository{
+		Base: NewBase(db, userTable),
+		db:   db,
+	}
 }
 
-// Create creates a new user
-func (r *userRepository) Create(ctx context.Context, user *models.User) (*models.User, error) {
-	query := `
-		INSERT INTO users (email, created_at, updated_at)
-		VALUES ($1, NOW(), NOW())
-		RETURNING id, email, created_at, updated_at`
-
-	var createdUser models.User
-	err := r.db.QueryRow(ctx, query, user.Email).Scan(
-		&createdUser.ID,
-		&createdUser.Email,
-		&createdUser.CreatedAt,
-		&createdUser.UpdatedAt,
-	)
+// GetByEmail retrieves a user by email
+func (r *userRepository) GetByEmail(ctx context.Context, email string) (*models.User, error) {
+	query := `SELECT id, email, created_at, updated_at, deleted_at, version, preferences FROM users WHERE email = $1` + notDeleted(ctx)
+
+	user, err := scanUser(database.Read(ctx, r.db).QueryRow(ctx, query, email))
 	if err != nil {
-		return nil, fmt.Errorf("failed to create user: %w", err)
+		return nil, fmt.Errorf("failed to get user by email: %w", database.TranslateError(err))
 	}
 
-	return &createdUser, nil
+	return user, nil
 }
 
-// GetByID retrieves a user by ID
-func (r *userRepository) GetByID(ctx context.Context, id int) (*models.User, error) {
-	query := `SELECT id, email, created_at, updated_at FROM users WHERE id = $1`
+// GetByIDAsOf retrieves a user as it was at asOf from the users_history
+// table, including a user deleted or hard-deleted since. It returns an error
+// wrapping errs.ErrNotFound when the user did not exist at asOf.
+func (r *userRepository) GetByIDAsOf(ctx context.Context, id int, asOf time.Time) (*models.User, error) {
+	query := `
+		SELECT id, email, created_at, updated_at, deleted_at, version, preferences
+		FROM users_history
+		WHERE id = $1 AND valid_from <= $2 AND (valid_to IS NULL OR valid_to > $2)
+		ORDER BY valid_from DESC
+		LIMIT 1`
 
-	var user models.User
-	err := r.db.QueryRow(ctx, query, id).Scan(
-		&user.ID,
-		&user.Email,
-		&user.CreatedAt,
-		&user.UpdatedAt,
-	)
+	user, err := scanUser(database.Read(ctx, r.db).QueryRow(ctx, query, id, asOf))
 	if err != nil {
-		return nil, fmt.Errorf("failed to get user by id: %w", err)
+		return nil, fmt.Errorf("failed to get user as of %s: %w", asOf.Format(time.RFC3339), database.TranslateError(err))
 	}
 
-	return &user, nil
+	return user, nil
 }
 
-// GetByEmail retrieves a user by email
-func (r *userRepository) GetByEmail(ctx context.Context, email string) (*models.User, error) {
-	query := `SELECT id, email, created_at, updated_at FROM users WHERE email = $1`
+// GetPasswordHash retrieves the password hash of a user, empty when the user
+// has no password. It returns an error wrapping errs.ErrNotFound when there
+// is no such user.
+func (r *userRepository) GetPasswordHash(ctx context.Context, id int) (string, error) {
+	query := `SELECT password_hash FROM users WHERE id = $1` + notDeleted(ctx)
 
-	var user models.User
-	err := r.db.QueryRow(ctx, query, email).Scan(
-		&user.ID,
-		&user.Email,
-		&user.CreatedAt,
-		&user.UpdatedAt,
-	)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get user by email: %w", err)
+	// Read from the primary so that a new password applies right away
+	var hash string
+	if err := database.Write(ctx, r.db).QueryRow(ctx, query, id).Scan(&hash); err != nil {
+		return "", fmt.Errorf("failed to get password hash: %w", database.TranslateError(err))
 	}
 
-	return &user, nil
+	return hash, nil
 }
 
-// Update updates a user
-func (r *userRepository) Update(ctx context.Context, user *models.User) (*models.User, error) {
+// SetPasswordHash replaces the password hash of a user and records the time
+// of the change. The hash is kept out of the User model, so it is never
+// cached nor serialized.
+// It returns an error wrapping errs.ErrNotFound when there is no such user.
+func (r *userRepository) SetPasswordHash(ctx context.Context, id int, hash string) error {
 	query := `
-		UPDATE users 
-		SET email = $2, updated_at = NOW()
-		WHERE id = $1
-		RETURNING id, email, created_at, updated_at`
-
-	var updatedUser models.User
-	err := r.db.QueryRow(ctx, query, user.ID, user.Email).Scan(
-		&updatedUser.ID,
-		&updatedUser.Email,
-		&updatedUser.CreatedAt,
-		&updatedUser.UpdatedAt,
-	)
+		UPDATE users SET password_hash = $2, password_changed_at = NOW(), updated_at = NOW()
+		WHERE id = $1` + notDeleted(ctx)
+
+	tag, err := database.Write(ctx, r.db).Exec(ctx, query, id, hash)
 	if err != nil {
-		return nil, fmt.Errorf("failed to update user: %w", err)
+		return fmt.Errorf("failed to set password hash: %w", database.TranslateError(err))
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("failed to set password hash: %w", database.TranslateError(pgx.ErrNoRows))
 	}
 
-	return &updatedUser, nil
+	return nil
 }
 
-// Delete deletes a user by ID
-func (r *userRepository) Delete(ctx context.Context, id int) error {
-	query := `DELETE FROM users WHERE id = $1`
+// GetPasswordChangedAt retrieves the time a user last changed their
+// password, nil when they never did. It returns an error wrapping
+// errs.ErrNotFound when there is no such user.
+func (r *userRepository) GetPasswordChangedAt(ctx context.Context, id int) (*time.Time, error) {
+	query := `SELECT password_changed_at FROM users WHERE id = $1` + notDeleted(ctx)
+
+	// Read from the primary so that a password change revokes tokens right away
+	var changedAt *time.Time
+	if err := database.Write(ctx, r.db).QueryRow(ctx, query, id).Scan(&changedAt); err != nil {
+		return nil, fmt.Errorf("failed to get password change time: %w", database.TranslateError(err))
+	}
 
-	_, err := r.db.Exec(ctx, query, id)
+	return changedAt, nil
+}
+
+// List retrieves a page of users using keyset pagination, newest first.
+// An empty cursor starts from the beginning. The returned cursor points after
+// the last user of the page and is empty when there are no more users.
+func (r *userRepository) List(ctx context.Context, cursor string, limit int) ([]*models.User, string, error) {
+	var after userCursor
+	if cursor != "" {
+		if err := libs.DecodeCursor(cursor, &after); err != nil {
+			return nil, "", err
+		}
+	}
+
+	query := `
+		SELECT id, email, created_at, updated_at, deleted_at, version, preferences
+		FROM users
+		WHERE ($1::boolean OR (created_at, id) < ($2, $3))` + notDeleted(ctx) + `
+		ORDER BY created_at DESC, id DESC
+		LIMIT $4`
+
+	// Fetch one extra row to know whether another page exists
+	rows, err := database.Read(ctx, r.db).Query(ctx, query, cursor == "", after.CreatedAt, after.ID, limit+1)
 	if err != nil {
-		return fmt.Errorf("failed to delete user: %w", err)
+		return nil, "", fmt.Errorf("failed to list users: %w", database.TranslateError(err))
 	}
+	defer rows.Close()
 
-	return nil
+	var users []*models.User
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to scan user: %w", err)
+		}
+		users = append(users, user)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, "", fmt.Errorf("rows error: %w", err)
+	}
+
+	if len(users) <= limit {
+		return users, "", nil
+	}
+
+	users = users[:limit]
+	last := users[len(users)-1]
+	next, err := libs.EncodeCursor(userCursor{CreatedAt: last.CreatedAt, ID: last.ID})
+	if err != nil {
+		return nil, "", err
+	}
+
+	return users, next, nil
 }
 
-// List retrieves a list of users with pagination
-func (r *userRepository) List(ctx context.Context, limit, offset int) ([]*models.User, error) {
+// ListWithOffset retrieves a list of users with LIMIT/OFFSET pagination.
+// Prefer List for large tables, as OFFSET scans every skipped row.
+func (r *userRepository) ListWithOffset(ctx context.Context, limit, offset int) ([]*models.User, error) {
 	query := `
-		SELECT id, email, created_at, updated_at 
-		FROM users 
-		ORDER BY created_at DESC 
+		SELECT id, email, created_at, updated_at, deleted_at, version, preferences
+		FROM users
+		WHERE TRUE` + notDeleted(ctx) + `
+		ORDER BY created_at DESC
 		LIMIT $1 OFFSET $2`
 
-	rows, err := r.db.Query(ctx, query, limit, offset)
+	rows, err := database.Read(ctx, r.db).Query(ctx, query, limit, offset)
 	if err != nil {
-		return nil, fmt.Errorf("failed to list users: %w", err)
+		return nil, fmt.Errorf("failed to list users: %w", database.TranslateError(err))
 	}
 	defer rows.Close()
 
 	var users []*models.User
 	for rows.Next() {
-		var user models.User
-		err := rows.Scan(
-			&user.ID,
-			&user.Email,
-			&user.CreatedAt,
-			&user.UpdatedAt,
-		)
+		user, err := scanUser(rows)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan user: %w", err)
 		}
-		users = append(users, &user)
+		users = append(users, user)
 	}
 
 	if err := rows.Err(); err != nil {
@@ -154,3 +234,21 @@ func (r *userRepository) List(ctx context.Context, limit, offset int) ([]*models
 
 	return users, nil
 }
+
+// scanUser scans a row of the users table columns
+func scanUser(row pgx.Row) (*models.User, error) {
+	var user models.User
+	err := row.Scan(
+		&user.ID,
+		&user.Email,
+		&user.CreatedAt,
+		&user.UpdatedAt,
+		&user.DeletedAt,
+		&user.Version,
+		&user.Preferences,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
@@ -0,0 +1,85 @@
+package routers
+
+import (
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/PrinceNarteh/go-boilerplate/internal/config"
+	"github.com/PrinceNarteh/go-boilerplate/internal/middlewares"
+)
+
+// Alias maps the requests of a legacy path pattern to the route that
+// replaced it, so renaming an endpoint does not break existing clients
+type Alias struct {
+	// Method is the aliased method, every method when empty
+	Method string
+	// From is the legacy path pattern, such as "/api/v1/accounts/{id}"
+	From string
+	// To is the new path, whose parameters are filled in from From, such as
+	// "/api/v1/users/{id}"
+	To string
+	// Sunset, when set, is the date the alias is removed
+	Sunset time.Time
+}
+
+// AliasFromConfig returns the alias declared in a route aliases file
+func AliasFromConfig(cfg config.RouteAliasConfig) Alias {
+	return Alias{Method: cfg.Method, From: cfg.From, To: cfg.To, Sunset: cfg.Sunset}
+}
+
+// pathParam matches the parameters of a path pattern, such as {id} or {path...}
+var pathParam = regexp.MustCompile(`\{([^{}.$]+)(\.\.\.)?\}`)
+
+// Alias registers aliases, to be called once the routes they point to are
+// registered. Requests to an alias are rewritten to its new path and served
+// by the route matching it, with its group middleware. Responses carry a
+// Deprecation header, a Link to the new path and, when set, the Sunset date,
+// and every request is recorded, see middlewares.RecordDeprecatedRoute.
+func (r *Router) Alias(aliases ...Alias) {
+	for _, alias := range aliases {
+		r.handle(alias.Method, alias.From, r.aliasHandler(alias))
+	}
+}
+
+// aliasHandler rewrites requests to the new path of alias and serves them
+func (r *Router) aliasHandler(alias Alias) http.Handler {
+	to := strings.TrimSuffix(alias.To, "{$}")
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		// The path is built both unescaped and escaped, for parameters
+		// holding escaped slashes to stay in their segment
+		fill := func(escape bool) string {
+			return pathParam.ReplaceAllStringFunc(to, func(param string) string {
+				match := pathParam.FindStringSubmatch(param)
+				value := req.PathValue(match[1])
+				// Wildcards span segments, whose slashes are kept
+				if !escape || match[2] != "" {
+					return value
+				}
+				return url.PathEscape(value)
+			})
+		}
+		path, rawPath := fill(false), fill(true)
+
+		header := w.Header()
+		header.Set("Deprecation", "true")
+		header.Add("Link", "<"+rawPath+`>; rel="successor-version"`)
+		if !alias.Sunset.IsZero() {
+			header.Set("Sunset", alias.Sunset.UTC().Format(http.TimeFormat))
+		}
+		middlewares.RecordDeprecatedRoute(req.Context(), alias.To)
+		zerolog.Ctx(req.Context()).Debug().
+			Str("alias", alias.From).
+			Str("path", path).
+			Msg("Serving deprecated route alias")
+
+		rewritten := req.Clone(req.Context())
+		rewritten.URL.Path = path
+		rewritten.URL.RawPath = rawPath
+		r.mux.ServeHTTP(w, rewritten)
+	})
+}
@@ -0,0 +1,29 @@
+package routers
+
+import (
+	"net/http"
+
+	"github.com/PrinceNarteh/go-boilerplate/internal/config"
+)
+
+// ConfigModule serves the configuration in effect at /config, with
+// defaults applied and secrets redacted as by the config print command.
+// It is meant for the internal admin server only.
+type ConfigModule struct {
+	cfg *config.Config
+}
+
+// NewConfigModule creates a new module serving cfg
+func NewConfigModule(cfg *config.Config) *ConfigModule {
+	return &ConfigModule{cfg: cfg}
+}
+
+// RegisterRoutes implements Module
+func (m *ConfigModule) RegisterRoutes(g *RouteGroup) {
+	g.GET("/config", m.configHandler)
+}
+
+// configHandler writes the redacted settings
+func (m *ConfigModule) configHandler(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, config.Redacted(m.cfg))
+}
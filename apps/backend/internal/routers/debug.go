@@ -0,0 +1,37 @@
+package routers
+
+import (
+	"expvar"
+	"net/http"
+	"net/http/pprof"
+
+	"github.com/PrinceNarteh/go-boilerplate/internal/middlewares"
+)
+
+// DebugModule serves the runtime profiles of net/http/pprof under
+// /debug/pprof, such as /debug/pprof/heap or /debug/pprof/profile for a CPU
+// profile, and the expvar variables at /debug/vars, for operators to
+// profile running deployments with go tool pprof
+type DebugModule struct {
+	middlewares []middlewares.Middleware
+}
+
+// NewDebugModule creates a new debug module whose routes are wrapped by mw,
+// which must restrict them to operators, e.g. by authenticating admins,
+// unless they are served on a port of their own
+func NewDebugModule(mw ...middlewares.Middleware) *DebugModule {
+	return &DebugModule{middlewares: mw}
+}
+
+// RegisterRoutes implements Module
+func (m *DebugModule) RegisterRoutes(g *RouteGroup) {
+	debug := g.Group("/debug", m.middlewares...)
+	// Index also serves the named profiles, such as heap or goroutine
+	debug.GET("/pprof/", pprof.Index)
+	debug.GET("/pprof/cmdline", pprof.Cmdline)
+	debug.GET("/pprof/profile", pprof.Profile)
+	debug.GET("/pprof/symbol", pprof.Symbol)
+	debug.POST("/pprof/symbol", pprof.Symbol)
+	debug.GET("/pprof/trace", pprof.Trace)
+	debug.Handle(http.MethodGet, "/vars", expvar.Handler())
+}
@@ -0,0 +1,96 @@
+package routers
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/PrinceNarteh/go-boilerplate/internal/middlewares"
+)
+
+// RouteGroup is a set of routes sharing a path prefix and middleware
+type RouteGroup struct {
+	router      *Router
+	prefix      string
+	middlewares []middlewares.Middleware
+}
+
+// Group creates a sub-group whose routes are prefixed with prefix and
+// wrapped by the parent group's middleware followed by mw
+func (g *RouteGroup) Group(prefix string, mw ...middlewares.Middleware) *RouteGroup {
+	return &RouteGroup{
+		router:      g.router,
+		prefix:      joinPath(g.prefix, prefix),
+		middlewares: append(slices.Clone(g.middlewares), mw...),
+	}
+}
+
+// With returns a group with the same prefix whose routes are additionally
+// wrapped by mw, for attaching middleware to a handful of routes inline
+func (g *RouteGroup) With(mw ...middlewares.Middleware) *RouteGroup {
+	return g.Group("", mw...)
+}
+
+// Use appends middleware to the group.
+// It only applies to routes registered on the group after the call.
+func (g *RouteGroup) Use(mw ...middlewares.Middleware) {
+	g.middlewares = append(g.middlewares, mw...)
+}
+
+// Prefix returns the path prefix of the group
+func (g *RouteGroup) Prefix() string {
+	return g.prefix
+}
+
+// Handle registers a handler for the given method and pattern.
+// The pattern may contain path parameters such as {id}; an empty method
+// matches every method. The handler is wrapped by the group middleware
+// followed by the route-level middleware mw.
+func (g *RouteGroup) Handle(method, pattern string, handler http.Handler, mw ...middlewares.Middleware) {
+	handler = middlewares.Chain(mw...)(handler)
+	handler = middlewares.Chain(g.middlewares...)(handler)
+	g.router.handle(method, joinPath(g.prefix, pattern), handler)
+}
+
+// HandleFunc registers a handler function for the given method and pattern
+func (g *RouteGroup) HandleFunc(method, pattern string, handler http.HandlerFunc, mw ...middlewares.Middleware) {
+	g.Handle(method, pattern, handler, mw...)
+}
+
+// GET registers a handler for GET requests
+func (g *RouteGroup) GET(pattern string, handler http.HandlerFunc, mw ...middlewares.Middleware) {
+	g.HandleFunc(http.MethodGet, pattern, handler, mw...)
+}
+
+// POST registers a handler for POST requests
+func (g *RouteGroup) POST(pattern string, handler http.HandlerFunc, mw ...middlewares.Middleware) {
+	g.HandleFunc(http.MethodPost, pattern, handler, mw...)
+}
+
+// PUT registers a handler for PUT requests
+func (g *RouteGroup) PUT(pattern string, handler http.HandlerFunc, mw ...middlewares.Middleware) {
+	g.HandleFunc(http.MethodPut, pattern, handler, mw...)
+}
+
+// PATCH registers a handler for PATCH requests
+func (g *RouteGroup) PATCH(pattern string, handler http.HandlerFunc, mw ...middlewares.Middleware) {
+	g.HandleFunc(http.MethodPatch, pattern, handler, mw...)
+}
+
+// DELETE registers a handler for DELETE requests
+func (g *RouteGroup) DELETE(pattern string, handler http.HandlerFunc, mw ...middlewares.Middleware) {
+	g.HandleFunc(http.MethodDelete, pattern, handler, mw...)
+}
+
+// joinPath joins a group prefix and a route pattern, preserving a trailing
+// slash on the pattern since it is significant to http.ServeMux
+func joinPath(prefix, pattern string) string {
+	prefix = strings.TrimSuffix(prefix, "/")
+	if pattern == "" {
+		if prefix == "" {
+			return "/"
+		}
+		return prefix
+	}
+	return prefix + "/" + strings.TrimPrefix(pattern, "/")
+}
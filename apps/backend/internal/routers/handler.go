@@ -0,0 +1,182 @@
+package routers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/rs/zerolog"
+
+	"github.com/PrinceNarteh/go-boilerplate/internal/errs"
+	"github.com/PrinceNarteh/go-boilerplate/internal/i18n"
+	"github.com/PrinceNarteh/go-boilerplate/internal/libs"
+	"github.com/PrinceNarteh/go-boilerplate/internal/middlewares"
+)
+
+// NoContent can be returned by typed handlers to respond with 204 No Content
+type NoContent struct{}
+
+// StatusCoder can be implemented by response types to override the
+// default 200 OK status, e.g. to return 201 Created
+type StatusCoder interface {
+	StatusCode() int
+}
+
+// Created wraps a response to be sent with 201 Created
+type Created[T any] struct {
+	Data T
+}
+
+// StatusCode implements StatusCoder
+func (c Created[T]) StatusCode() int {
+	return http.StatusCreated
+}
+
+// Localize implements i18n.Localizable for the wrapped response
+func (c Created[T]) Localize(ctx context.Context) {
+	i18n.Localize(ctx, c.Data)
+}
+
+// MarshalJSON encodes the wrapped response
+func (c Created[T]) MarshalJSON() ([]byte, error) {
+	return json.Marshal(c.Data)
+}
+
+// Accepted wraps a response to be sent with 202 Accepted, for requests
+// whose processing continues in the background
+type Accepted[T any] struct {
+	Data T
+}
+
+// StatusCode implements StatusCoder
+func (a Accepted[T]) StatusCode() int {
+	return http.StatusAccepted
+}
+
+// Localize implements i18n.Localizable for the wrapped response
+func (a Accepted[T]) Localize(ctx context.Context) {
+	i18n.Localize(ctx, a.Data)
+}
+
+// MarshalJSON encodes the wrapped response
+func (a Accepted[T]) MarshalJSON() ([]byte, error) {
+	return json.Marshal(a.Data)
+}
+
+// Handler adapts a typed handler function to an http.HandlerFunc.
+// It decodes the JSON request body into Req (skipped when the body is empty),
+// validates it with libs.ValidateStruct, calls fn and encodes the returned
+// response as JSON, once i18n.Localize filled its localized fields. Errors
+// are written with errs.WriteJSON using the status of the *errs.AppError,
+// and any other error is logged and reported as a 500. Requests canceled by
+// their client are not logged as errors, see writeError.
+func Handler[Req, Resp any](fn func(r *http.Request, req Req) (Resp, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req Req
+		if err := decodeBody(r, &req); err != nil {
+			writeError(w, r, err)
+			return
+		}
+
+		if fields := libs.Validate(req); fields != nil {
+			for _, field := range fields {
+				middlewares.RecordValidationFailure(r.Context(), field.Field, field.Rule)
+			}
+			writeError(w, r, errs.ErrValidation.WithDetails(libs.FieldMessages(fields)))
+			return
+		}
+
+		// Skip the handler, and the queries it would run, for clients gone
+		// while the body was read
+		if errs.ClientCanceled(r) {
+			writeError(w, r, r.Context().Err())
+			return
+		}
+
+		resp, err := fn(r, req)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+
+		if _, ok := any(resp).(NoContent); ok {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		status := http.StatusOK
+		if sc, ok := any(resp).(StatusCoder); ok {
+			status = sc.StatusCode()
+		}
+		i18n.Localize(r.Context(), resp)
+		writeJSON(w, status, resp)
+	}
+}
+
+// decodeBody decodes a JSON request body into v, leaving v untouched when the body is empty.
+// Bodies that do not decode are recorded as binding failures.
+func decodeBody(r *http.Request, v any) error {
+	if r.Body == nil || r.Body == http.NoBody {
+		return nil
+	}
+
+	err := json.NewDecoder(r.Body).Decode(v)
+	if err == nil || errors.Is(err, io.EOF) {
+		return nil
+	}
+	// A body cut short by the client leaving is not malformed
+	if errs.ClientCanceled(r) {
+		return r.Context().Err()
+	}
+
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		middlewares.RecordBindingFailure(r.Context(), "body", "too_large", "")
+		return errs.NewPayloadTooLarge(maxBytesErr.Limit)
+	}
+
+	// Errors of custom decoders, such as malformed dates, are invalid values
+	reason, field := "value", ""
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &syntaxErr), errors.Is(err, io.ErrUnexpectedEOF):
+		reason = "syntax"
+	case errors.As(err, &typeErr):
+		reason, field = "type", typeErr.Field
+	}
+	middlewares.RecordBindingFailure(r.Context(), "body", reason, field)
+
+	return errs.New(errs.ErrCodeBadRequest, "Request body must be valid JSON", http.StatusBadRequest)
+}
+
+// writeError writes err as a JSON error response, logging errors that are
+// not AppErrors since their details are hidden from the client. Errors of
+// requests canceled by their client, typically context.Canceled returned by
+// the queries they aborted, are reported as errs.ErrClientClosed instead.
+// Server errors are reported to Sentry, see middlewares.CaptureError.
+func writeError(w http.ResponseWriter, r *http.Request, err error) {
+	if errs.ClientCanceled(r) {
+		zerolog.Ctx(r.Context()).Debug().Err(err).Msg("Request canceled by client")
+		errs.WriteJSON(w, errs.ErrClientClosed)
+		return
+	}
+
+	var appErr *errs.AppError
+	if !errors.As(err, &appErr) {
+		zerolog.Ctx(r.Context()).Error().Err(err).Msg("Unhandled error in handler")
+	}
+	if errs.From(err).Status >= http.StatusInternalServerError {
+		middlewares.CaptureError(r, err)
+	}
+	errs.WriteJSON(w, err)
+}
+
+// writeJSON writes v as a JSON response with the given status
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,59 @@
+package routers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/PrinceNarteh/go-boilerplate/internal/healthcheck"
+)
+
+// HealthModule serves the startup and readiness probes from the health check
+// service. The liveness probe, /health/live, is served by the router itself
+// as it only reports that the process is up.
+type HealthModule struct {
+	health *healthcheck.Service
+}
+
+// NewHealthModule creates a new startup and readiness probe module
+func NewHealthModule(health *healthcheck.Service) *HealthModule {
+	return &HealthModule{health: health}
+}
+
+// RegisterRoutes implements Module
+func (m *HealthModule) RegisterRoutes(g *RouteGroup) {
+	g.GET("/health/startup", m.startupHandler)
+	g.GET("/health/ready", m.readyHandler)
+}
+
+// startupHandler reports whether initialization is complete, with
+// 503 Service Unavailable until it is
+func (m *HealthModule) startupHandler(w http.ResponseWriter, _ *http.Request) {
+	status, body := http.StatusOK, healthcheck.StatusHealthy
+	if !m.health.Started() {
+		status, body = http.StatusServiceUnavailable, healthcheck.StatusStarting
+	}
+
+	writeProbe(w, status, map[string]healthcheck.Status{"status": body})
+}
+
+// readyHandler reports the status and latency of every check, with
+// 503 Service Unavailable while starting or draining, or when any check is
+// failing
+func (m *HealthModule) readyHandler(w http.ResponseWriter, _ *http.Request) {
+	report := m.health.Report()
+
+	status := http.StatusOK
+	if report.Status != healthcheck.StatusHealthy {
+		status = http.StatusServiceUnavailable
+	}
+
+	writeProbe(w, status, report)
+}
+
+// writeProbe writes the uncached JSON response of a probe
+func writeProbe(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,43 @@
+package routers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/PrinceNarteh/go-boilerplate/internal/errs"
+	"github.com/PrinceNarteh/go-boilerplate/internal/middlewares"
+)
+
+// Param returns the value of a path parameter, such as id in /users/{id}
+func Param(r *http.Request, name string) string {
+	return r.PathValue(name)
+}
+
+// ParamInt returns a path parameter parsed as an integer.
+// It returns a validation error if the parameter is missing or not a number,
+// recorded as a binding failure.
+func ParamInt(r *http.Request, name string) (int, error) {
+	value, err := strconv.Atoi(r.PathValue(name))
+	if err != nil {
+		middlewares.RecordBindingFailure(r.Context(), "path", "type", name)
+		return 0, errs.NewValidation(name + " must be an integer")
+	}
+	return value, nil
+}
+
+// QueryTime returns a query parameter parsed as an RFC 3339 time, such as
+// 2025-01-02T15:04:05Z, and whether it is set. It returns a validation error
+// if the parameter is not a valid time, recorded as a binding failure.
+func QueryTime(r *http.Request, name string) (time.Time, bool, error) {
+	raw := r.URL.Query().Get(name)
+	if raw == "" {
+		return time.Time{}, false, nil
+	}
+	value, err := time.Parse(time.RFC3339, raw)
+	if err != nil {
+		middlewares.RecordBindingFailure(r.Context(), "query", "type", name)
+		return time.Time{}, false, errs.NewValidation(name + " must be an RFC 3339 time")
+	}
+	return value, true, nil
+}
@@ -1,32 +1,100 @@
+// Package routers contains the HTTP router and route registration helpers
 package routers
 
 import (
+	"cmp"
 	"net/http"
+	"slices"
+	"sync"
+	"time"
 
 	"github.com/rs/zerolog"
+
+	"github.com/PrinceNarteh/go-boilerplate/internal/middlewares"
+	"github.com/PrinceNarteh/go-boilerplate/internal/version"
 )
 
+// Module is implemented by feature modules that register their own routes.
+// Modules receive the root group and typically create a sub-group for their
+// resources, so adding a feature does not require editing SetupRoutes.
+type Module interface {
+	RegisterRoutes(g *RouteGroup)
+}
+
+// Route describes a registered route
+type Route struct {
+	Method  string
+	Pattern string
+}
+
 // Router represents the HTTP router
 type Router struct {
+	*RouteGroup
+
+	api    *RouteGroup
 	mux    *http.ServeMux
 	logger *zerolog.Logger
+
+	mu     sync.Mutex
+	routes []Route
 }
 
 // New creates a new router instance
 func New(logger *zerolog.Logger) *Router {
-	return &Router{
+	r := &Router{
 		mux:    http.NewServeMux(),
 		logger: logger,
 	}
+	r.RouteGroup = &RouteGroup{router: r}
+	return r
 }
 
-// SetupRoutes sets up all the routes for the application
-func (r *Router) SetupRoutes() {
-	// Health check endpoint
-	r.mux.HandleFunc("GET /health", r.healthCheckHandler)
-	
+// SetupRoutes sets up all the routes for the application.
+// apiMiddlewares are applied to the /api/v1 routes only, so that
+// infrastructure endpoints such as /health are never rate limited or authenticated.
+func (r *Router) SetupRoutes(apiMiddlewares ...middlewares.Middleware) {
+	// Liveness probe, /health is kept for existing deployments
+	r.GET("/health", r.healthCheckHandler)
+	r.GET("/health/live", r.healthCheckHandler)
+
 	// API routes can be added here
-	r.mux.HandleFunc("GET /api/v1/status", r.statusHandler)
+	r.api = r.Group("/api/v1", apiMiddlewares...)
+	r.api.GET("/status", Handler(r.statusHandler))
+	r.api.GET("/rate-limit", Handler(r.rateLimitHandler))
+}
+
+// SetupAdminRoutes sets up the routes of the internal admin server, which
+// serves the liveness probes but not the API
+func (r *Router) SetupAdminRoutes() {
+	r.GET("/health", r.healthCheckHandler)
+	r.GET("/health/live", r.healthCheckHandler)
+}
+
+// Register registers the routes of the given feature modules
+func (r *Router) Register(modules ...Module) {
+	for _, m := range modules {
+		m.RegisterRoutes(r.RouteGroup)
+	}
+}
+
+// RegisterAPI registers the routes of the given feature modules under the
+// /api/v1 group, so they share the API middleware. SetupRoutes must be called first.
+func (r *Router) RegisterAPI(modules ...Module) {
+	for _, m := range modules {
+		m.RegisterRoutes(r.api)
+	}
+}
+
+// Routes returns all registered routes sorted by pattern
+func (r *Router) Routes() []Route {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	routes := slices.Clone(r.routes)
+	slices.SortFunc(routes, func(a, b Route) int {
+		return cmp.Or(cmp.Compare(a.Pattern, b.Pattern), cmp.Compare(a.Method, b.Method))
+	})
+	return routes
 }
 
 // ServeHTTP implements the http.Handler interface
@@ -34,16 +102,71 @@ func (r *Router) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 	r.mux.ServeHTTP(w, req)
 }
 
-// healthCheckHandler handles health check requests
+// handle registers a handler on the underlying mux
+func (r *Router) handle(method, pattern string, handler http.Handler) {
+	r.mu.Lock()
+	r.routes = append(r.routes, Route{Method: method, Pattern: pattern})
+	r.mu.Unlock()
+
+	// Report the matched pattern for metrics before any route middleware runs
+	next := handler
+	handler = http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		middlewares.SetRoutePattern(req.Context(), pattern)
+		next.ServeHTTP(w, req)
+	})
+
+	if method == "" {
+		r.mux.Handle(pattern, handler)
+		return
+	}
+	r.mux.Handle(method+" "+pattern, handler)
+}
+
+// healthCheckHandler handles liveness probes. It only reports that the
+// process is up and serving requests, never the state of dependencies,
+// so that a slow database does not get the process restarted.
 func (r *Router) healthCheckHandler(w http.ResponseWriter, req *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	w.Write([]byte(`{"status":"healthy","service":"go-boilerplate"}`))
 }
 
+// statusResponse is the response of the status endpoint
+type statusResponse struct {
+	Status  string `json:"status"`
+	Version string `json:"version"`
+}
+
 // statusHandler handles status requests
-func (r *Router) statusHandler(w http.ResponseWriter, req *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	w.Write([]byte(`{"status":"running","version":"1.0.0"}`))
+func (r *Router) statusHandler(_ *http.Request, _ struct{}) (statusResponse, error) {
+	return statusResponse{Status: "running", Version: version.Version}, nil
+}
+
+// rateLimitResponse is the response of the rate limit endpoint
+type rateLimitResponse struct {
+	Enabled   bool   `json:"enabled"`
+	Algorithm string `json:"algorithm,omitempty"`
+	Limit     int    `json:"limit,omitempty"`
+	Window    string `json:"window,omitempty"`
+	Remaining int    `json:"remaining"`
+	Reset     int64  `json:"reset,omitempty"`
+}
+
+// rateLimitHandler reports the caller's rate limit and remaining quota.
+// The query itself counts against the limit, so the figures match the
+// X-RateLimit-* headers of the response.
+func (r *Router) rateLimitHandler(req *http.Request, _ struct{}) (rateLimitResponse, error) {
+	state, ok := middlewares.RateLimitFromContext(req.Context())
+	if !ok {
+		return rateLimitResponse{Enabled: false}, nil
+	}
+
+	return rateLimitResponse{
+		Enabled:   true,
+		Algorithm: string(state.Rule.Algorithm),
+		Limit:     state.Result.Limit,
+		Window:    state.Rule.Window.String(),
+		Remaining: state.Result.Remaining,
+		Reset:     time.Now().Add(state.Result.ResetAfter).Unix(),
+	}, nil
 }
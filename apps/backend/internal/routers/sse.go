@@ -0,0 +1,159 @@
+package routers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// sseHeartbeatInterval is the interval of the comments keeping idle streams
+// open through proxies
+const sseHeartbeatInterval = 15 * time.Second
+
+// Event is a Server-Sent Event
+type Event struct {
+	// ID is sent back by reconnecting clients in the Last-Event-ID header.
+	// It must not contain line breaks nor NUL characters.
+	ID string
+	// Event names the event, "message" for clients when empty. It must not
+	// contain line breaks.
+	Event string
+	// Data is sent as is when it is a string, and as JSON otherwise
+	Data any
+	// Retry tells clients how long to wait before reconnecting
+	Retry time.Duration
+}
+
+// SSE starts a Server-Sent Events stream in response to r. Events sent on
+// the returned channel are written and flushed to the client, and a
+// heartbeat comment is sent when the stream is idle. The handler must call
+// done, typically deferred, to end the stream once it returns: done closes
+// the channel and waits for the events sent to be written.
+//
+// Once the client disconnects, events are discarded and the context of r is
+// canceled, which handlers producing events watch to stop early:
+//
+//	events, done, err := routers.SSE(w, r)
+//	if err != nil {
+//	    errs.WriteJSON(w, err)
+//	    return
+//	}
+//	defer done()
+//	for progress := range updates {
+//	    select {
+//	    case events <- routers.Event{Event: "progress", Data: progress}:
+//	    case <-r.Context().Done():
+//	        return
+//	    }
+//	}
+//
+// SSE fails without writing anything when the response cannot be flushed.
+func SSE(w http.ResponseWriter, r *http.Request) (events chan<- Event, done func(), err error) {
+	rc := http.NewResponseController(w)
+
+	h := w.Header()
+	h.Set("Content-Type", "text/event-stream")
+	h.Set("Cache-Control", "no-cache")
+	h.Set("Connection", "keep-alive")
+	// Disable response buffering in nginx
+	h.Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	if err := rc.Flush(); err != nil {
+		return nil, nil, fmt.Errorf("failed to start event stream: %w", err)
+	}
+	// Streams outlive the write timeout of the server
+	_ = rc.SetWriteDeadline(time.Time{})
+
+	ch := make(chan Event)
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		streamEvents(r, w, rc, ch)
+	}()
+
+	return ch, func() {
+		close(ch)
+		<-finished
+	}, nil
+}
+
+// streamEvents writes events until the channel is closed, discarding them
+// once the client is gone
+func streamEvents(r *http.Request, w http.ResponseWriter, rc *http.ResponseController, ch <-chan Event) {
+	ctx := r.Context()
+	ticker := time.NewTicker(sseHeartbeatInterval)
+	defer ticker.Stop()
+
+	var buf strings.Builder
+	for {
+		select {
+		case event, ok := <-ch:
+			if !ok {
+				return
+			}
+			buf.Reset()
+			if err := writeEvent(&buf, event); err != nil {
+				zerolog.Ctx(ctx).Error().Err(err).Str("event", event.Event).Msg("Failed to encode event")
+				continue
+			}
+			if _, err := w.Write([]byte(buf.String())); err == nil {
+				_ = rc.Flush()
+			}
+			ticker.Reset(sseHeartbeatInterval)
+		case <-ticker.C:
+			if _, err := w.Write([]byte(": heartbeat\n\n")); err == nil {
+				_ = rc.Flush()
+			}
+		case <-ctx.Done():
+			for range ch {
+			}
+			return
+		}
+	}
+}
+
+// writeEvent formats an event in the event stream format. Line breaks in
+// the ID or name of the event, which would start new fields, are rejected.
+func writeEvent(buf *strings.Builder, event Event) error {
+	if strings.ContainsAny(event.ID, "\r\n\x00") {
+		return fmt.Errorf("invalid event id %q", event.ID)
+	}
+	if strings.ContainsAny(event.Event, "\r\n") {
+		return fmt.Errorf("invalid event name %q", event.Event)
+	}
+
+	var data string
+	switch v := event.Data.(type) {
+	case string:
+		data = v
+	case nil:
+	default:
+		b, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		data = string(b)
+	}
+
+	if event.ID != "" {
+		buf.WriteString("id: " + event.ID + "\n")
+	}
+	if event.Event != "" {
+		buf.WriteString("event: " + event.Event + "\n")
+	}
+	if event.Retry > 0 {
+		buf.WriteString("retry: " + strconv.FormatInt(event.Retry.Milliseconds(), 10) + "\n")
+	}
+	// Clients end lines at CR too, so every line break starts a data line
+	data = strings.NewReplacer("\r\n", "\n", "\r", "\n").Replace(data)
+	for _, line := range strings.Split(data, "\n") {
+		buf.WriteString("data: " + line + "\n")
+	}
+	buf.WriteString("\n")
+	return nil
+}
@@ -0,0 +1,92 @@
+package routers
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/PrinceNarteh/go-boilerplate/internal/config"
+)
+
+// wellKnownCacheControl lets clients and proxies cache static well-known responses
+const wellKnownCacheControl = "public, max-age=86400"
+
+// WellKnownModule serves /robots.txt, /favicon.ico and /.well-known endpoints
+// from configuration, so scanners and browsers probing an API deployment get
+// a proper answer instead of filling the logs with 404s.
+type WellKnownModule struct {
+	cfg *config.WellKnownConfig
+}
+
+// NewWellKnownModule creates a new well-known endpoints module
+func NewWellKnownModule(cfg *config.WellKnownConfig) *WellKnownModule {
+	if cfg == nil {
+		cfg = config.DefaultWellKnownConfig()
+	}
+	return &WellKnownModule{cfg: cfg}
+}
+
+// RegisterRoutes implements Module
+func (m *WellKnownModule) RegisterRoutes(g *RouteGroup) {
+	if m.cfg.RobotsTxt != "" {
+		g.GET("/robots.txt", m.textHandler(m.cfg.RobotsTxt))
+	}
+
+	g.GET("/favicon.ico", m.faviconHandler)
+
+	if m.cfg.SecurityContact != "" {
+		g.GET("/.well-known/security.txt", m.textHandler(m.securityTxt()))
+	}
+
+	if m.cfg.ChangePasswordURL != "" {
+		g.GET("/.well-known/change-password", func(w http.ResponseWriter, r *http.Request) {
+			http.Redirect(w, r, m.cfg.ChangePasswordURL, http.StatusFound)
+		})
+	}
+}
+
+// textHandler serves a static plain text body
+func (m *WellKnownModule) textHandler(body string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Header().Set("Cache-Control", wellKnownCacheControl)
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(body))
+	}
+}
+
+// faviconHandler serves the configured favicon file, or an empty response
+func (m *WellKnownModule) faviconHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", wellKnownCacheControl)
+	if m.cfg.FaviconFile == "" {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/x-icon")
+	http.ServeFile(w, r, m.cfg.FaviconFile)
+}
+
+// securityTxt renders an RFC 9116 security.txt document
+func (m *WellKnownModule) securityTxt() string {
+	var b strings.Builder
+
+	contact := m.cfg.SecurityContact
+	if !strings.Contains(contact, ":") {
+		contact = "mailto:" + contact
+	}
+	fmt.Fprintf(&b, "Contact: %s\n", contact)
+
+	expires := m.cfg.SecurityTxtExpires
+	if expires <= 0 {
+		expires = config.DefaultWellKnownConfig().SecurityTxtExpires
+	}
+	fmt.Fprintf(&b, "Expires: %s\n", time.Now().Add(expires).UTC().Format(time.RFC3339))
+
+	if m.cfg.SecurityPolicyURL != "" {
+		fmt.Fprintf(&b, "Policy: %s\n", m.cfg.SecurityPolicyURL)
+	}
+
+	return b.String()
+}
@@ -0,0 +1,41 @@
+package saga
+
+import (
+	"context"
+
+	"github.com/PrinceNarteh/go-boilerplate/internal/jobs"
+	"github.com/PrinceNarteh/go-boilerplate/internal/libs/id"
+)
+
+// ExecuteJob is the type of the jobs executing saga instances
+const ExecuteJob = "saga.execute"
+
+// ExecutePayload is the payload of an ExecuteJob
+type ExecutePayload struct {
+	SagaID id.ID `json:"saga_id"`
+}
+
+// JobDispatcher executes sagas on the workers of the job queue, so an
+// instance interrupted by a crash or a failed compensation is retried with
+// backoff. The workers must handle ExecuteJob with Coordinator.JobHandler.
+type JobDispatcher struct {
+	queue *jobs.Queue
+}
+
+// NewJobDispatcher creates a dispatcher enqueuing sagas on queue
+func NewJobDispatcher(queue *jobs.Queue) *JobDispatcher {
+	return &JobDispatcher{queue: queue}
+}
+
+// Dispatch implements Dispatcher
+func (d *JobDispatcher) Dispatch(ctx context.Context, sagaID id.ID) error {
+	return d.queue.Enqueue(ctx, ExecuteJob, ExecutePayload{SagaID: sagaID})
+}
+
+// JobHandler returns the handler of ExecuteJob, executing the instance of
+// the payload
+func (c *Coordinator) JobHandler() jobs.Handler {
+	return jobs.Typed(func(ctx context.Context, p ExecutePayload) error {
+		return c.Execute(ctx, p.SagaID)
+	})
+}
@@ -0,0 +1,362 @@
+// Package saga provides a lightweight saga coordinator for multi-step
+// operations that must either complete fully or be compensated.
+//
+// Each saga is a sequence of steps with optional compensations. The state of
+// every saga instance is persisted after each step so that a crashed process
+// can resume it, running the remaining steps or undoing the completed ones.
+// Because a step may be re-run after a crash, actions and compensations must
+// be idempotent.
+package saga
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/PrinceNarteh/go-boilerplate/internal/libs/async"
+	"github.com/PrinceNarteh/go-boilerplate/internal/libs/id"
+)
+
+const (
+	// defaultLease is how long an instance stays claimed by a single
+	// executor without renewal, so the instance of a crashed executor is
+	// claimed again once it ends
+	defaultLease = time.Minute
+	// extendTimeout bounds the renewal of a claim
+	extendTimeout = 10 * time.Second
+)
+
+// Status represents the lifecycle status of a saga instance
+type Status string
+
+// Saga statuses
+const (
+	StatusRunning      Status = "running"
+	StatusCompensating Status = "compensating"
+	StatusCompleted    Status = "completed"
+	StatusCompensated  Status = "compensated"
+)
+
+// ErrUnknownSaga is returned when starting or resuming a saga that is not registered
+var ErrUnknownSaga = errors.New("saga: unknown saga")
+
+// ErrClaimed is returned when executing a saga instance claimed by another
+// executor, so that dispatchers retry it once the claim is released or expires
+var ErrClaimed = errors.New("saga: claimed by another executor")
+
+// StepFunc is an action or compensation run against a saga instance.
+// It may read and modify the instance data, which is persisted afterwards.
+type StepFunc func(ctx context.Context, state *State) error
+
+// Step is a single unit of work in a saga
+type Step struct {
+	Name       string
+	Action     StepFunc
+	Compensate StepFunc
+}
+
+// Definition describes a saga as an ordered list of steps
+type Definition struct {
+	Name  string
+	Steps []Step
+}
+
+// State is the persisted state of a saga instance.
+// Step is the index of the next step to run while running, and the index of
+// the last completed step while compensating.
+type State struct {
+	ID        id.ID
+	Name      string
+	Status    Status
+	Step      int
+	Data      map[string]any
+	Error     string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// Store persists saga instances
+type Store interface {
+	Create(ctx context.Context, state *State) error
+	Save(ctx context.Context, state *State) error
+	// Claim locks an unfinished instance for lease and returns it, or
+	// returns false when it is finished. It returns ErrClaimed when the
+	// instance is claimed by someone else.
+	Claim(ctx context.Context, sagaID id.ID, lease time.Duration) (*State, bool, error)
+	// Extend renews the claim of an instance for lease, failing once the
+	// claim expired
+	Extend(ctx context.Context, sagaID id.ID, lease time.Duration) error
+	Release(ctx context.Context, sagaID id.ID) error
+	// ListUnfinished returns the IDs of instances that are still running or compensating
+	ListUnfinished(ctx context.Context) ([]id.ID, error)
+}
+
+// Dispatcher schedules the execution of a saga instance.
+// JobDispatcher runs sagas on the workers of the job queue with retries;
+// GoroutineDispatcher runs them in-process.
+type Dispatcher interface {
+	Dispatch(ctx context.Context, sagaID id.ID) error
+}
+
+// Coordinator registers saga definitions and drives their execution
+type Coordinator struct {
+	store      Store
+	dispatcher Dispatcher
+	logger     *zerolog.Logger
+	lease      time.Duration
+
+	mu          sync.RWMutex
+	definitions map[string]Definition
+}
+
+// NewCoordinator creates a new saga coordinator.
+// If dispatcher is nil, sagas are executed in a background goroutine.
+func NewCoordinator(store Store, dispatcher Dispatcher, logger *zerolog.Logger) *Coordinator {
+	c := &Coordinator{
+		store:       store,
+		dispatcher:  dispatcher,
+		logger:      logger,
+		lease:       defaultLease,
+		definitions: make(map[string]Definition),
+	}
+	if c.dispatcher == nil {
+		c.dispatcher = &GoroutineDispatcher{coordinator: c}
+	}
+	return c
+}
+
+// Register adds a saga definition to the coordinator
+func (c *Coordinator) Register(def Definition) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.definitions[def.Name] = def
+}
+
+// Start persists a new instance of the named saga and dispatches it for execution
+func (c *Coordinator) Start(ctx context.Context, name string, data map[string]any) (id.ID, error) {
+	if _, ok := c.definition(name); !ok {
+		return id.Nil, fmt.Errorf("%w: %s", ErrUnknownSaga, name)
+	}
+	if data == nil {
+		data = make(map[string]any)
+	}
+
+	state := &State{
+		ID:     id.New(),
+		Name:   name,
+		Status: StatusRunning,
+		Data:   data,
+	}
+	if err := c.store.Create(ctx, state); err != nil {
+		return id.Nil, fmt.Errorf("failed to create saga: %w", err)
+	}
+
+	if err := c.dispatcher.Dispatch(ctx, state.ID); err != nil {
+		return id.Nil, fmt.Errorf("failed to dispatch saga: %w", err)
+	}
+
+	return state.ID, nil
+}
+
+// Resume dispatches every unfinished saga instance.
+// It should be called on startup to recover sagas interrupted by a crash.
+func (c *Coordinator) Resume(ctx context.Context) error {
+	ids, err := c.store.ListUnfinished(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list unfinished sagas: %w", err)
+	}
+
+	for _, sagaID := range ids {
+		if err := c.dispatcher.Dispatch(ctx, sagaID); err != nil {
+			return fmt.Errorf("failed to dispatch saga %s: %w", sagaID, err)
+		}
+	}
+
+	if len(ids) > 0 {
+		c.logger.Info().Int("count", len(ids)).Msg("resumed unfinished sagas")
+	}
+	return nil
+}
+
+// Execute claims a saga instance and runs it until it completes, is fully
+// compensated, or a compensation fails. The claim is renewed while the
+// instance runs; when it cannot be, the run is canceled, as another
+// executor may claim the instance. It is called by dispatchers and returns
+// an error when the instance should be retried later, such as ErrClaimed
+// when another executor runs it.
+func (c *Coordinator) Execute(ctx context.Context, sagaID id.ID) (err error) {
+	state, ok, err := c.store.Claim(ctx, sagaID, c.lease)
+	if errors.Is(err, ErrClaimed) {
+		return fmt.Errorf("%w: %s", ErrClaimed, sagaID)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to claim saga: %w", err)
+	}
+	if !ok {
+		return nil
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stopRenewing := c.renew(ctx, sagaID, cancel)
+	defer func() {
+		if lost := stopRenewing(); lost {
+			// The instance may be another executor's now, whose claim is kept
+			if err == nil {
+				err = fmt.Errorf("lost the claim of saga %s", sagaID)
+			}
+			return
+		}
+		if err := c.store.Release(context.WithoutCancel(ctx), sagaID); err != nil {
+			c.logger.Error().Err(err).Stringer("saga_id", sagaID).Msg("failed to release saga")
+		}
+	}()
+
+	def, ok := c.definition(state.Name)
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrUnknownSaga, state.Name)
+	}
+
+	log := c.logger.With().Str("saga", state.Name).Stringer("saga_id", state.ID).Logger()
+
+	if state.Status == StatusRunning {
+		if err := c.runForward(ctx, def, state, &log); err != nil {
+			return err
+		}
+	}
+
+	if state.Status == StatusCompensating {
+		return c.runCompensations(ctx, def, state, &log)
+	}
+
+	return nil
+}
+
+// runForward runs the remaining steps, switching to compensation on the first failure
+func (c *Coordinator) runForward(ctx context.Context, def Definition, state *State, log *zerolog.Logger) error {
+	for state.Step < len(def.Steps) {
+		step := def.Steps[state.Step]
+
+		if err := step.Action(ctx, state); err != nil {
+			log.Warn().Err(err).Str("step", step.Name).Msg("saga step failed, compensating")
+			state.Status = StatusCompensating
+			state.Error = fmt.Sprintf("%s: %v", step.Name, err)
+			state.Step--
+			return c.save(ctx, state)
+		}
+
+		state.Step++
+		if err := c.save(ctx, state); err != nil {
+			return err
+		}
+	}
+
+	state.Status = StatusCompleted
+	log.Info().Msg("saga completed")
+	return c.save(ctx, state)
+}
+
+// runCompensations undoes completed steps in reverse order
+func (c *Coordinator) runCompensations(ctx context.Context, def Definition, state *State, log *zerolog.Logger) error {
+	for state.Step >= 0 {
+		step := def.Steps[state.Step]
+
+		if step.Compensate != nil {
+			if err := step.Compensate(ctx, state); err != nil {
+				log.Error().Err(err).Str("step", step.Name).Msg("saga compensation failed")
+				return fmt.Errorf("failed to compensate step %s: %w", step.Name, err)
+			}
+		}
+
+		state.Step--
+		if err := c.save(ctx, state); err != nil {
+			return err
+		}
+	}
+
+	state.Status = StatusCompensated
+	log.Info().Str("error", state.Error).Msg("saga compensated")
+	return c.save(ctx, state)
+}
+
+// renew extends the claim of an instance every third of the lease until the
+// returned function is called, which reports whether the claim was lost.
+// When the claim cannot be extended, cancel stops the run.
+func (c *Coordinator) renew(ctx context.Context, sagaID id.ID, cancel context.CancelFunc) func() bool {
+	done := make(chan struct{})
+	var lost bool
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(c.lease / 3)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			extendCtx, cancelExtend := context.WithTimeout(ctx, extendTimeout)
+			err := c.store.Extend(extendCtx, sagaID, c.lease)
+			cancelExtend()
+			if err != nil && ctx.Err() == nil {
+				c.logger.Error().Err(err).Stringer("saga_id", sagaID).Msg("failed to extend saga claim, canceling the run")
+				lost = true
+				cancel()
+				return
+			}
+		}
+	}()
+
+	return func() bool {
+		close(done)
+		wg.Wait()
+		return lost
+	}
+}
+
+// save persists the saga state
+func (c *Coordinator) save(ctx context.Context, state *State) error {
+	if err := c.store.Save(ctx, state); err != nil {
+		return fmt.Errorf("failed to save saga state: %w", err)
+	}
+	return nil
+}
+
+// definition returns a registered saga definition by name
+func (c *Coordinator) definition(name string) (Definition, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	def, ok := c.definitions[name]
+	return def, ok
+}
+
+// GoroutineDispatcher executes sagas in background goroutines of the current process
+type GoroutineDispatcher struct {
+	coordinator *Coordinator
+}
+
+// Dispatch implements Dispatcher
+func (d *GoroutineDispatcher) Dispatch(ctx context.Context, sagaID id.ID) error {
+	async.Go(context.WithoutCancel(ctx), "saga", func(ctx context.Context) error {
+		err := d.coordinator.Execute(ctx, sagaID)
+		switch {
+		case errors.Is(err, ErrClaimed):
+			// The executor holding the claim runs the instance
+			d.coordinator.logger.Debug().Err(err).Stringer("saga_id", sagaID).Msg("saga already running")
+		case err != nil:
+			d.coordinator.logger.Error().Err(err).Stringer("saga_id", sagaID).Msg("saga execution failed")
+		}
+		return nil
+	})
+	return nil
+}
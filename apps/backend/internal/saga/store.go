@@ -0,0 +1,160 @@
+package saga
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	pgx "github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/PrinceNarteh/go-boilerplate/internal/anonymize"
+	"github.com/PrinceNarteh/go-boilerplate/internal/libs/id"
+)
+
+// SagaAnonymization leaves sagas out of staging snapshots, as saga data may
+// hold personal data
+var SagaAnonymization = anonymize.Skip("sagas")
+
+// PostgresStore persists saga instances in the sagas table
+type PostgresStore struct {
+	db *pgxpool.Pool
+}
+
+// NewPostgresStore creates a new PostgreSQL saga store
+func NewPostgresStore(db *pgxpool.Pool) *PostgresStore {
+	return &PostgresStore{db: db}
+}
+
+// Create inserts a new saga instance
+func (s *PostgresStore) Create(ctx context.Context, state *State) error {
+	query := `
+		INSERT INTO sagas (id, name, status, step, data, error, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, NOW(), NOW())
+		RETURNING created_at, updated_at`
+
+	err := s.db.QueryRow(ctx, query,
+		state.ID, state.Name, state.Status, state.Step, state.Data, state.Error,
+	).Scan(&state.CreatedAt, &state.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create saga: %w", err)
+	}
+
+	return nil
+}
+
+// Save updates the progress of a saga instance
+func (s *PostgresStore) Save(ctx context.Context, state *State) error {
+	query := `
+		UPDATE sagas
+		SET status = $2, step = $3, data = $4, error = $5, updated_at = NOW()
+		WHERE id = $1
+		RETURNING updated_at`
+
+	err := s.db.QueryRow(ctx, query,
+		state.ID, state.Status, state.Step, state.Data, state.Error,
+	).Scan(&state.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to save saga: %w", err)
+	}
+
+	return nil
+}
+
+// Claim locks an unfinished saga instance for the given lease, returning
+// ErrClaimed when it is locked by another executor
+func (s *PostgresStore) Claim(ctx context.Context, sagaID id.ID, lease time.Duration) (*State, bool, error) {
+	query := `
+		UPDATE sagas
+		SET locked_until = NOW() + $2::interval
+		WHERE id = $1
+			AND status IN ('running', 'compensating')
+			AND (locked_until IS NULL OR locked_until < NOW())
+		RETURNING id, name, status, step, data, error, created_at, updated_at`
+
+	var state State
+	err := s.db.QueryRow(ctx, query, sagaID, lease).Scan(
+		&state.ID,
+		&state.Name,
+		&state.Status,
+		&state.Step,
+		&state.Data,
+		&state.Error,
+		&state.CreatedAt,
+		&state.UpdatedAt,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, false, s.claimed(ctx, sagaID)
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to claim saga: %w", err)
+	}
+
+	return &state, true, nil
+}
+
+// claimed returns ErrClaimed when an instance that could not be claimed is
+// unfinished, and thus locked by another executor
+func (s *PostgresStore) claimed(ctx context.Context, sagaID id.ID) error {
+	query := `SELECT EXISTS (SELECT 1 FROM sagas WHERE id = $1 AND status IN ('running', 'compensating'))`
+
+	var unfinished bool
+	if err := s.db.QueryRow(ctx, query, sagaID).Scan(&unfinished); err != nil {
+		return fmt.Errorf("failed to check saga claim: %w", err)
+	}
+	if unfinished {
+		return ErrClaimed
+	}
+	return nil
+}
+
+// Extend renews the lock on a claimed saga instance for the given lease. It
+// fails once the lock expired, as another executor may then claim it.
+func (s *PostgresStore) Extend(ctx context.Context, sagaID id.ID, lease time.Duration) error {
+	query := `
+		UPDATE sagas
+		SET locked_until = NOW() + $2::interval
+		WHERE id = $1
+			AND status IN ('running', 'compensating')
+			AND locked_until >= NOW()`
+
+	tag, err := s.db.Exec(ctx, query, sagaID, lease)
+	if err != nil {
+		return fmt.Errorf("failed to extend saga: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("saga %s is no longer claimed", sagaID)
+	}
+
+	return nil
+}
+
+// Release removes the lock on a saga instance
+func (s *PostgresStore) Release(ctx context.Context, sagaID id.ID) error {
+	query := `UPDATE sagas SET locked_until = NULL WHERE id = $1`
+
+	if _, err := s.db.Exec(ctx, query, sagaID); err != nil {
+		return fmt.Errorf("failed to release saga: %w", err)
+	}
+
+	return nil
+}
+
+// ListUnfinished returns the IDs of sagas that are running or compensating
+func (s *PostgresStore) ListUnfinished(ctx context.Context) ([]id.ID, error) {
+	query := `SELECT id FROM sagas WHERE status IN ('running', 'compensating') ORDER BY created_at`
+
+	rows, err := s.db.Query(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sagas: %w", err)
+	}
+	defer rows.Close()
+
+	ids, err := pgx.CollectRows(rows, pgx.RowTo[id.ID])
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan sagas: %w", err)
+	}
+
+	return ids, nil
+}
@@ -0,0 +1,130 @@
+package scanner
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+)
+
+const (
+	clamAVChunkSize      = 64 << 10         // Size of the chunks streamed to clamd
+	defaultClamAVTimeout = 30 * time.Second // Default time allowed for a whole scan
+)
+
+// ClamAV scans files with a clamd daemon using the INSTREAM command
+type ClamAV struct {
+	network string
+	address string
+	timeout time.Duration
+}
+
+// NewClamAV creates a ClamAV scanner for the clamd daemon at address, either
+// host:port for TCP or an absolute path for a Unix socket. Each scan must
+// complete within timeout, 30 seconds when zero.
+func NewClamAV(address string, timeout time.Duration) *ClamAV {
+	network := "tcp"
+	if strings.HasPrefix(address, "/") {
+		network = "unix"
+	}
+	if timeout <= 0 {
+		timeout = defaultClamAVTimeout
+	}
+	return &ClamAV{network: network, address: address, timeout: timeout}
+}
+
+// Scan implements Scanner.
+// Content larger than clamd's StreamMaxLength is reported as an error.
+func (c *ClamAV) Scan(ctx context.Context, r io.Reader) (Result, error) {
+	reply, err := c.command(ctx, "zINSTREAM\x00", func(conn net.Conn) error {
+		return stream(conn, r)
+	})
+	if err != nil {
+		return Result{}, err
+	}
+
+	// Replies are "stream: OK", "stream: <signature> FOUND" or "<message> ERROR"
+	reply = strings.TrimPrefix(reply, "stream: ")
+	switch {
+	case reply == "OK":
+		return Result{Verdict: VerdictClean, Engine: "clamav"}, nil
+	case strings.HasSuffix(reply, " FOUND"):
+		return Result{Verdict: VerdictInfected, Threat: strings.TrimSuffix(reply, " FOUND"), Engine: "clamav"}, nil
+	default:
+		return Result{}, fmt.Errorf("clamav: scan failed: %s", reply)
+	}
+}
+
+// Ping checks that clamd is reachable, e.g. for health checks
+func (c *ClamAV) Ping(ctx context.Context) error {
+	reply, err := c.command(ctx, "zPING\x00", nil)
+	if err != nil {
+		return err
+	}
+	if reply != "PONG" {
+		return fmt.Errorf("clamav: unexpected ping reply: %s", reply)
+	}
+	return nil
+}
+
+// command sends a null-terminated command to clamd, lets send write any
+// payload, and returns the reply without its terminating null byte
+func (c *ClamAV) command(ctx context.Context, cmd string, send func(conn net.Conn) error) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, c.network, c.address)
+	if err != nil {
+		return "", fmt.Errorf("clamav: failed to connect: %w", err)
+	}
+	defer conn.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+
+	if _, err := io.WriteString(conn, cmd); err != nil {
+		return "", fmt.Errorf("clamav: failed to send command: %w", err)
+	}
+	if send != nil {
+		if err := send(conn); err != nil {
+			return "", fmt.Errorf("clamav: failed to send content: %w", err)
+		}
+	}
+
+	reply, err := bufio.NewReader(conn).ReadString(0)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", fmt.Errorf("clamav: failed to read reply: %w", err)
+	}
+	return strings.TrimSpace(strings.TrimSuffix(reply, "\x00")), nil
+}
+
+// stream writes r as INSTREAM chunks, each prefixed by its length as a
+// 4-byte big-endian integer, followed by a zero-length chunk
+func stream(w io.Writer, r io.Reader) error {
+	buf := make([]byte, 4+clamAVChunkSize)
+	for {
+		n, err := r.Read(buf[4:])
+		if n > 0 {
+			binary.BigEndian.PutUint32(buf[:4], uint32(n))
+			if _, werr := w.Write(buf[:4+n]); werr != nil {
+				return werr
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err := w.Write([]byte{0, 0, 0, 0})
+	return err
+}
@@ -0,0 +1,62 @@
+// Package scanner checks uploaded files for viruses and malware.
+//
+// A Scanner reads the content of a file and returns a verdict. ClamAV scans
+// through a clamd daemon; Noop accepts everything and is used when scanning
+// is disabled. Other engines, such as ICAP gateways, plug in by implementing
+// Scanner.
+package scanner
+
+import (
+	"context"
+	"io"
+
+	"github.com/PrinceNarteh/go-boilerplate/internal/config"
+)
+
+// Verdict is the outcome of a scan
+type Verdict string
+
+// Scan verdicts
+const (
+	VerdictClean    Verdict = "clean"
+	VerdictInfected Verdict = "infected"
+)
+
+// Result is the result of scanning a file
+type Result struct {
+	Verdict Verdict
+	// Threat names the detected signature when the file is infected
+	Threat string
+	// Engine names the scanner that produced the result
+	Engine string
+}
+
+// Infected reports whether a threat was found
+func (r Result) Infected() bool {
+	return r.Verdict == VerdictInfected
+}
+
+// Scanner scans file content.
+// An error means the content could not be scanned, not that it is infected;
+// callers should keep such files out of reach until a later scan succeeds.
+type Scanner interface {
+	Scan(ctx context.Context, r io.Reader) (Result, error)
+}
+
+// Noop is a Scanner that reports every file as clean
+type Noop struct{}
+
+// Scan implements Scanner
+func (Noop) Scan(_ context.Context, r io.Reader) (Result, error) {
+	return Result{Verdict: VerdictClean, Engine: "noop"}, nil
+}
+
+// New creates the scanner selected by cfg.Driver, Noop when none is set
+func New(cfg config.ScannerConfig) Scanner {
+	switch cfg.Driver {
+	case "clamav":
+		return NewClamAV(cfg.ClamAV.Address, cfg.ClamAV.Timeout)
+	default:
+		return Noop{}
+	}
+}
@@ -0,0 +1,124 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	pgx "github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// defaultRetentionBatch is the number of rows deleted per statement by Retention
+const defaultRetentionBatch = 1000
+
+// retentionArgs are the arguments of a retention job
+type retentionArgs struct {
+	table     string
+	column    string
+	maxAge    time.Duration
+	batchSize int
+}
+
+// Retention returns a handler deleting the rows of a table older than a
+// maximum age, in batches so that locks stay short. Its arguments are:
+//
+//   - table: the table to purge, required
+//   - column: the timestamp column compared to the maximum age, "created_at" by default
+//   - max_age: the maximum age as a Go duration, such as "720h", required
+//   - batch_size: the number of rows deleted per statement, 1000 by default
+//
+// Only one instance purges a table at a time.
+func Retention(db *pgxpool.Pool) Handler {
+	return Handler{
+		Validate: func(args map[string]any) error {
+			_, err := parseRetentionArgs(args)
+			return err
+		},
+		Run: func(ctx context.Context, args map[string]any) error {
+			parsed, err := parseRetentionArgs(args)
+			if err != nil {
+				return err
+			}
+			return purge(ctx, db, parsed)
+		},
+	}
+}
+
+// purge deletes expired rows batch by batch while holding a session lock on the table
+func purge(ctx context.Context, db *pgxpool.Pool, args retentionArgs) error {
+	conn, err := db.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to acquire connection: %w", err)
+	}
+	defer conn.Release()
+
+	lockKey := "retention:" + args.table
+	var locked bool
+	if err := conn.QueryRow(ctx, `SELECT pg_try_advisory_lock(hashtext($1))`, lockKey).Scan(&locked); err != nil {
+		return fmt.Errorf("failed to lock table: %w", err)
+	}
+	if !locked {
+		return nil
+	}
+	defer conn.Exec(context.WithoutCancel(ctx), `SELECT pg_advisory_unlock(hashtext($1))`, lockKey)
+
+	table := pgx.Identifier{args.table}.Sanitize()
+	column := pgx.Identifier{args.column}.Sanitize()
+	query := fmt.Sprintf(`
+		DELETE FROM %[1]s
+		WHERE ctid IN (
+			SELECT ctid FROM %[1]s
+			WHERE %[2]s < NOW() - $1::interval
+			LIMIT $2
+		)`, table, column)
+
+	for {
+		tag, err := conn.Exec(ctx, query, args.maxAge, args.batchSize)
+		if err != nil {
+			return fmt.Errorf("failed to purge %s: %w", args.table, err)
+		}
+		if tag.RowsAffected() < int64(args.batchSize) {
+			return nil
+		}
+	}
+}
+
+// parseRetentionArgs reads and checks the arguments of a retention job
+func parseRetentionArgs(args map[string]any) (retentionArgs, error) {
+	parsed := retentionArgs{column: "created_at", batchSize: defaultRetentionBatch}
+
+	table, ok := args["table"].(string)
+	if !ok || table == "" {
+		return parsed, errors.New("table is required")
+	}
+	parsed.table = table
+
+	if column, ok := args["column"]; ok {
+		if parsed.column, ok = column.(string); !ok || parsed.column == "" {
+			return parsed, errors.New("column must be a column name")
+		}
+	}
+
+	maxAge, ok := args["max_age"].(string)
+	if !ok {
+		return parsed, errors.New("max_age is required, e.g. \"720h\"")
+	}
+	duration, err := time.ParseDuration(maxAge)
+	if err != nil || duration <= 0 {
+		return parsed, fmt.Errorf("max_age %q must be a positive duration, e.g. \"720h\"", maxAge)
+	}
+	parsed.maxAge = duration
+
+	if batchSize, ok := args["batch_size"]; ok {
+		// JSON numbers are decoded as float64
+		size, ok := batchSize.(float64)
+		if !ok || size < 1 || size != float64(int(size)) {
+			return parsed, errors.New("batch_size must be a positive integer")
+		}
+		parsed.batchSize = int(size)
+	}
+
+	return parsed, nil
+}
@@ -0,0 +1,536 @@
+// Package scheduler runs recurring jobs on their cron schedules.
+//
+// Modules register recurring tasks in code with Register. Handlers are
+// registered in code by name, and declarations from config.SchedulerConfig
+// bind them to a schedule and arguments. Reconcile applies a new set of
+// declarations, at startup and on every config reload, adding, updating and
+// removing jobs without restarting the scheduler. Runs can be delayed by a
+// random jitter, so jobs sharing a schedule do not all start at once.
+//
+// A job never overlaps with its own previous run. Every instance of the
+// application runs the schedule; with a locker set by UseLocker, each
+// activation of a job runs on a single instance and is skipped while the
+// previous run goes on elsewhere. Otherwise handlers that must run once per
+// deployment take a lock, as Retention does.
+//
+// Each run has an ID, logged with the job name by the logger that zerolog.Ctx
+// returns from the context of the run, and is recorded as a New Relic
+// background transaction. Status reports the jobs and their last run.
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"math/rand/v2"
+	"reflect"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/PrinceNarteh/go-boilerplate/internal/cache"
+	"github.com/PrinceNarteh/go-boilerplate/internal/config"
+	"github.com/PrinceNarteh/go-boilerplate/internal/libs/async"
+	"github.com/PrinceNarteh/go-boilerplate/internal/libs/cron"
+	"github.com/PrinceNarteh/go-boilerplate/internal/libs/id"
+	"github.com/PrinceNarteh/go-boilerplate/internal/telemetry"
+	"github.com/PrinceNarteh/go-boilerplate/internal/tracing"
+)
+
+const (
+	// lockTTL is the TTL of the locks taken on each activation of a job. The
+	// locks are renewed while the job runs; the lock of the activation
+	// expires this long after it returns.
+	lockTTL = time.Minute
+	// unlockTimeout is the time given to releasing the lock of a job after a run
+	unlockTimeout = 5 * time.Second
+)
+
+// Sources of jobs
+const (
+	SourceCode   = "code"   // Registered with Register
+	SourceConfig = "config" // Declared in the scheduler file
+)
+
+// RunStatus is the status of a run of a job
+type RunStatus string
+
+// Run statuses
+const (
+	RunRunning   RunStatus = "running"
+	RunSucceeded RunStatus = "succeeded"
+	RunFailed    RunStatus = "failed"
+)
+
+// Handler runs a kind of job
+type Handler struct {
+	// Run runs the job with the arguments of its declaration
+	Run func(ctx context.Context, args map[string]any) error
+	// Validate checks the arguments of a declaration, optional
+	Validate func(args map[string]any) error
+}
+
+// Task is a recurring task registered in code
+type Task struct {
+	// Name identifies the task among all jobs, in logs and in Status
+	Name string
+	// Cron is the schedule of the task
+	Cron string
+	// Jitter is the maximum random delay of each run, optional
+	Jitter time.Duration
+	// Run runs the task
+	Run func(ctx context.Context) error
+}
+
+// Run is a run of a job
+type Run struct {
+	ID         id.ID      `json:"id"`
+	Status     RunStatus  `json:"status"`
+	StartedAt  time.Time  `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	Error      string     `json:"error,omitempty"`
+}
+
+// Status is the state of a scheduled job. LastRun is the last run on this
+// instance: with a locker, other instances may have run the job since.
+type Status struct {
+	Name   string `json:"name"`
+	Source string `json:"source"`
+	// Job is the handler of a job declared in the scheduler file
+	Job     string    `json:"job,omitempty"`
+	Cron    string    `json:"cron"`
+	Jitter  string    `json:"jitter,omitempty"`
+	NextRun time.Time `json:"next_run"`
+	LastRun *Run      `json:"last_run,omitempty"`
+}
+
+// job is a scheduled job and its state
+type job struct {
+	cfg      config.JobConfig
+	handler  Handler
+	schedule *cron.Schedule
+	jitter   time.Duration
+	// registered is set for tasks registered in code, kept by Reconcile
+	registered bool
+	next       time.Time
+	running    bool
+	last       *Run
+}
+
+// Scheduler runs declared jobs on their schedules
+type Scheduler struct {
+	tracer tracing.Tracer
+	logger *zerolog.Logger
+	wake   chan struct{}
+
+	mu       sync.Mutex
+	locker   *cache.Locker
+	handlers map[string]Handler
+	jobs     map[string]*job
+	runs     sync.WaitGroup
+	stop     func()
+}
+
+// New creates a new scheduler. tracer records each run as a transaction and may be nil.
+func New(tracer tracing.Tracer, logger *zerolog.Logger) *Scheduler {
+	return &Scheduler{
+		tracer:   tracer,
+		logger:   logger,
+		wake:     make(chan struct{}, 1),
+		handlers: make(map[string]Handler),
+		jobs:     make(map[string]*job),
+	}
+}
+
+// Handle registers the handler of a kind of job. It must be called before Reconcile.
+func (s *Scheduler) Handle(name string, handler Handler) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.handlers[name] = handler
+}
+
+// Register schedules a recurring task. Its name must be unique among all
+// jobs, including those declared in the scheduler file.
+func (s *Scheduler) Register(task Task) error {
+	if task.Name == "" || task.Run == nil {
+		return errors.New("task name and run function are required")
+	}
+	if task.Jitter < 0 {
+		return fmt.Errorf("task %q: negative jitter", task.Name)
+	}
+	schedule, err := cron.Parse(task.Cron)
+	if err != nil {
+		return fmt.Errorf("task %q: %w", task.Name, err)
+	}
+	next, err := schedule.Next(time.Now())
+	if err != nil {
+		return fmt.Errorf("task %q: cron %q: %w", task.Name, task.Cron, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, exists := s.jobs[task.Name]; exists {
+		return fmt.Errorf("task %q: name is already scheduled", task.Name)
+	}
+
+	s.jobs[task.Name] = &job{
+		cfg: config.JobConfig{Name: task.Name, Cron: task.Cron},
+		handler: Handler{
+			Run: func(ctx context.Context, _ map[string]any) error {
+				return task.Run(ctx)
+			},
+		},
+		schedule:   schedule,
+		jitter:     task.Jitter,
+		registered: true,
+		next:       next,
+	}
+	s.logger.Info().Str("job", task.Name).Str("cron", task.Cron).Time("next_run", next).Msg("Scheduled task registered")
+	s.wakeUp()
+	return nil
+}
+
+// UseLocker makes each activation of a job run on a single instance of the
+// application, the first to take its lock, and only once the previous run
+// of the job returned. It must be called before Start.
+func (s *Scheduler) UseLocker(locker *cache.Locker) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.locker = locker
+}
+
+// Reconcile replaces the declared jobs with jobs. The declarations are
+// validated first, including that their handler is registered and accepts
+// their arguments and that their name is not taken by a registered task;
+// on error none of them is applied and the current jobs keep running.
+// Running jobs that are removed or changed finish their run.
+func (s *Scheduler) Reconcile(jobs []config.JobConfig) error {
+	if err := config.ValidateJobs(jobs); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	next := make(map[string]*job, len(jobs))
+	var errList []error
+	for _, cfg := range jobs {
+		if !cfg.IsEnabled() {
+			continue
+		}
+		if current, ok := s.jobs[cfg.Name]; ok && current.registered {
+			errList = append(errList, fmt.Errorf("job %q: name is taken by a registered task", cfg.Name))
+			continue
+		}
+
+		handler, ok := s.handlers[cfg.Handler()]
+		if !ok {
+			errList = append(errList, fmt.Errorf("job %q: unknown job %q, registered jobs are %v",
+				cfg.Name, cfg.Handler(), slices.Sorted(maps.Keys(s.handlers))))
+			continue
+		}
+		if handler.Validate != nil {
+			if err := handler.Validate(cfg.Args); err != nil {
+				errList = append(errList, fmt.Errorf("job %q: invalid args: %w", cfg.Name, err))
+				continue
+			}
+		}
+
+		// Validated above, so neither call can fail
+		schedule, _ := cron.Parse(cfg.Cron)
+		nextRun, _ := schedule.Next(now)
+		next[cfg.Name] = &job{
+			cfg:      cfg,
+			handler:  handler,
+			schedule: schedule,
+			jitter:   cfg.JitterDuration(),
+			next:     nextRun,
+		}
+	}
+	if err := errors.Join(errList...); err != nil {
+		return err
+	}
+
+	for name, j := range s.jobs {
+		if j.registered {
+			next[name] = j
+		}
+	}
+
+	for name, j := range next {
+		current, exists := s.jobs[name]
+		switch {
+		case current == j:
+		case !exists:
+			s.logger.Info().Str("job", name).Str("cron", j.cfg.Cron).Time("next_run", j.next).Msg("Scheduled job added")
+		case current.cfg.Cron == j.cfg.Cron && current.cfg.Jitter == j.cfg.Jitter &&
+			current.cfg.Handler() == j.cfg.Handler() && reflect.DeepEqual(current.cfg.Args, j.cfg.Args):
+			// Unchanged jobs keep their state
+			next[name] = current
+		default:
+			j.running, j.last = current.running, current.last
+			s.logger.Info().Str("job", name).Str("cron", j.cfg.Cron).Time("next_run", j.next).Msg("Scheduled job updated")
+		}
+	}
+	for name := range s.jobs {
+		if _, kept := next[name]; !kept {
+			s.logger.Info().Str("job", name).Msg("Scheduled job removed")
+		}
+	}
+	s.jobs = next
+	s.wakeUp()
+	return nil
+}
+
+// Status returns the state of every job, sorted by name
+func (s *Scheduler) Status() []Status {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	statuses := make([]Status, 0, len(s.jobs))
+	for _, name := range slices.Sorted(maps.Keys(s.jobs)) {
+		j := s.jobs[name]
+		status := Status{Name: name, Source: SourceConfig, Cron: j.cfg.Cron, NextRun: j.next}
+		if j.registered {
+			status.Source = SourceCode
+		} else {
+			status.Job = j.cfg.Handler()
+		}
+		if j.jitter > 0 {
+			status.Jitter = j.jitter.String()
+		}
+		if j.last != nil {
+			last := *j.last
+			status.LastRun = &last
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
+
+// wakeUp makes the loop recompute the next activation. s.mu must be held.
+func (s *Scheduler) wakeUp() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Start runs the jobs on their schedules in the background until Stop is called
+func (s *Scheduler) Start() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stop != nil {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := async.Go(ctx, "scheduler", func(ctx context.Context) error {
+		s.loop(ctx)
+		return nil
+	})
+
+	s.stop = func() {
+		cancel()
+		<-done
+		s.runs.Wait()
+	}
+}
+
+// Stop stops scheduling jobs and waits for running jobs to return.
+// Running jobs see their context canceled.
+func (s *Scheduler) Stop() {
+	s.mu.Lock()
+	stop := s.stop
+	s.stop = nil
+	s.mu.Unlock()
+
+	if stop != nil {
+		stop()
+	}
+}
+
+// loop starts due jobs and sleeps until the next activation or reconciliation
+func (s *Scheduler) loop(ctx context.Context) {
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		case <-s.wake:
+		}
+
+		wait := s.runDue(ctx, time.Now())
+
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+		timer.Reset(wait)
+	}
+}
+
+// runDue starts the jobs due at now and returns the time until the next activation
+func (s *Scheduler) runDue(ctx context.Context, now time.Time) time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	wait := time.Hour
+	for name, j := range s.jobs {
+		if !j.next.After(now) {
+			if j.running {
+				s.logger.Warn().Str("job", name).Msg("Skipping scheduled job, previous run still running")
+			} else {
+				j.running = true
+				s.runs.Add(1)
+				go s.run(ctx, name, j, j.next)
+			}
+
+			next, err := j.schedule.Next(now)
+			if err != nil {
+				// Validated when declared, only reachable for schedules ending within five years
+				s.logger.Error().Err(err).Str("job", name).Msg("Scheduled job has no next run")
+				delete(s.jobs, name)
+				continue
+			}
+			j.next = next
+		}
+		wait = min(wait, j.next.Sub(now))
+	}
+	return wait
+}
+
+// run runs the activation of a job due at activation, after its jitter,
+// recovering panics
+func (s *Scheduler) run(ctx context.Context, name string, j *job, activation time.Time) {
+	defer s.runs.Done()
+	defer func() {
+		s.mu.Lock()
+		j.running = false
+		// An updated declaration inherits the running state of the job it replaced
+		if current, ok := s.jobs[name]; ok {
+			current.running = false
+		}
+		s.mu.Unlock()
+	}()
+
+	if j.jitter > 0 {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(rand.N(j.jitter)):
+		}
+	}
+
+	if s.locker != nil {
+		var unlock func()
+		var ok bool
+		ctx, unlock, ok = s.lock(ctx, name, activation)
+		if !ok {
+			return
+		}
+		defer unlock()
+	}
+
+	runID := id.New()
+	log := s.logger.With().Str("job", name).Stringer("run_id", runID).Logger()
+
+	txn := tracing.Start(s.tracer, "scheduler/"+name)
+	defer txn.End()
+	txn.AddAttribute("job.run_id", runID.String())
+	ctx = tracing.NewContext(log.WithContext(ctx), txn)
+
+	run := Run{ID: runID, Status: RunRunning, StartedAt: time.Now()}
+	s.record(name, j, run)
+
+	err := func() (err error) {
+		defer func() {
+			if p := recover(); p != nil {
+				err = fmt.Errorf("job panicked: %v", p)
+			}
+		}()
+		return j.handler.Run(ctx, j.cfg.Args)
+	}()
+
+	finishedAt := time.Now()
+	run.FinishedAt = &finishedAt
+	duration := finishedAt.Sub(run.StartedAt)
+	if err != nil {
+		telemetry.ReportError(ctx, "scheduler/"+name, err)
+		run.Status, run.Error = RunFailed, err.Error()
+		s.record(name, j, run)
+		log.Error().Err(err).Dur("duration", duration).Msg("Scheduled job failed")
+		return
+	}
+	run.Status = RunSucceeded
+	s.record(name, j, run)
+	log.Info().Dur("duration", duration).Msg("Scheduled job completed")
+}
+
+// record saves run as the last run of a job
+func (s *Scheduler) record(name string, j *job, run Run) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	j.last = &run
+	// An updated declaration inherits the last run of the job it replaced
+	if current, ok := s.jobs[name]; ok {
+		current.last = &run
+	}
+}
+
+// lock takes the locks of an activation of a job. The lock of the activation
+// is expired rather than released after the run, so instances reaching the
+// activation late skip it, and the lock of the job is held for the run, so
+// the next activation skips a run still going on another instance. lock
+// returns a context canceled when a lock is lost and the function releasing
+// the locks, or false when the activation must be skipped.
+func (s *Scheduler) lock(ctx context.Context, name string, activation time.Time) (context.Context, func(), bool) {
+	log := s.logger.With().Str("job", name).Logger()
+
+	activationLock, err := s.locker.TryLock(ctx, fmt.Sprintf("scheduler:%s:%d", name, activation.Unix()), lockTTL)
+	if errors.Is(err, cache.ErrLocked) {
+		log.Debug().Msg("Scheduled job running on another instance")
+		return nil, nil, false
+	}
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to lock scheduled job")
+		return nil, nil, false
+	}
+
+	jobLock, err := s.locker.TryLock(ctx, "scheduler:"+name, lockTTL)
+	if err != nil {
+		activationLock.Expire()
+		if errors.Is(err, cache.ErrLocked) {
+			log.Warn().Msg("Skipping scheduled job, previous run still running on another instance")
+		} else {
+			log.Error().Err(err).Msg("Failed to lock scheduled job")
+		}
+		return nil, nil, false
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	stopOnActivationLoss := context.AfterFunc(activationLock.Context(), cancel)
+	stopOnJobLoss := context.AfterFunc(jobLock.Context(), cancel)
+
+	return ctx, func() {
+		stopOnActivationLoss()
+		stopOnJobLoss()
+		cancel()
+		activationLock.Expire()
+
+		unlockCtx, cancelUnlock := context.WithTimeout(context.Background(), unlockTimeout)
+		defer cancelUnlock()
+		if err := jobLock.Release(unlockCtx); err != nil && !errors.Is(err, cache.ErrLockLost) {
+			log.Error().Err(err).Msg("Failed to unlock scheduled job")
+		}
+	}, true
+}
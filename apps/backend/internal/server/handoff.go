@@ -0,0 +1,125 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+)
+
+// handoffEnv lists the addresses of the listeners a process hands off to the
+// process replacing it, in the order of their file descriptors, from 3
+const handoffEnv = "SERVER_HANDOFF_LISTENERS"
+
+var (
+	handoffOnce sync.Once
+	handoffMu   sync.Mutex
+	// handoffFDs are the file descriptors of the listeners handed off to
+	// this process, by address, until they are used
+	handoffFDs map[string]int
+)
+
+// inherited returns the listener of addr handed off by the process this one
+// replaces, and whether there is one
+func inherited(addr string) (net.Listener, bool, error) {
+	handoffOnce.Do(func() {
+		handoffFDs = make(map[string]int)
+		for i, a := range strings.Fields(os.Getenv(handoffEnv)) {
+			handoffFDs[a] = listenFDsStart + i
+		}
+		os.Unsetenv(handoffEnv)
+	})
+
+	handoffMu.Lock()
+	fd, ok := handoffFDs[addr]
+	delete(handoffFDs, addr)
+	handoffMu.Unlock()
+	if !ok {
+		return nil, false, nil
+	}
+
+	f := os.NewFile(uintptr(fd), "handoff:"+addr)
+	ln, err := net.FileListener(f)
+	// FileListener duplicates the descriptor, the inherited one is closed
+	f.Close()
+	if err != nil {
+		return nil, true, fmt.Errorf("failed to use socket %d handed off for %s: %w", fd, addr, err)
+	}
+	return ln, true, nil
+}
+
+// listenerFile is implemented by the TCP and Unix listeners
+type listenerFile interface {
+	File() (*os.File, error)
+}
+
+// Handoff starts a new process of the same executable, with the same
+// arguments and environment, passing it the listeners of servers, which it
+// serves on instead of listening again. Connections keep queuing on the
+// shared sockets while the new process starts and this one drains, so none
+// is refused; the caller then stops its servers as on shutdown.
+// Servers listening only once started, like the debug server, are skipped.
+// When the process started but an error is returned, the listeners of this
+// one may not stop accepting until the shutdown times out.
+func Handoff(servers ...*Server) (*os.Process, error) {
+	var addrs []string
+	var files []*os.File
+	defer func() {
+		for _, f := range files {
+			f.Close()
+		}
+	}()
+
+	var handedOff []net.Listener
+	for _, s := range servers {
+		for addr, ln := range s.listeners() {
+			lf, ok := ln.(listenerFile)
+			if !ok {
+				return nil, fmt.Errorf("listener of %s cannot be handed off", addr)
+			}
+			f, err := lf.File()
+			if err != nil {
+				return nil, fmt.Errorf("failed to hand off listener of %s: %w", addr, err)
+			}
+			addrs = append(addrs, addr)
+			files = append(files, f)
+			handedOff = append(handedOff, ln)
+		}
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		return nil, fmt.Errorf("failed to find executable: %w", err)
+	}
+	cmd := exec.Command(exe, os.Args[1:]...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	cmd.ExtraFiles = files
+	// The sockets of systemd are passed on with the others, under their address
+	for _, kv := range os.Environ() {
+		name, _, _ := strings.Cut(kv, "=")
+		if name != handoffEnv && !strings.HasPrefix(name, "LISTEN_") {
+			cmd.Env = append(cmd.Env, kv)
+		}
+	}
+	cmd.Env = append(cmd.Env, handoffEnv+"="+strings.Join(addrs, " "))
+
+	err = cmd.Start()
+	var errList []error
+	for _, ln := range handedOff {
+		errList = append(errList, restoreNonblock(ln))
+		// The new process serves on the Unix sockets, which must outlive this one
+		if ul, ok := ln.(*net.UnixListener); ok && err == nil {
+			ul.SetUnlinkOnClose(false)
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to start new process: %w", err)
+	}
+	if err := errors.Join(errList...); err != nil {
+		return cmd.Process, fmt.Errorf("failed to restore non-blocking listeners: %w", err)
+	}
+	return cmd.Process, nil
+}
@@ -0,0 +1,10 @@
+//go:build !unix
+
+package server
+
+import "net"
+
+// restoreNonblock does nothing, as sockets are not handed off on this platform
+func restoreNonblock(net.Listener) error {
+	return nil
+}
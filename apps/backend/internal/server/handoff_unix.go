@@ -0,0 +1,28 @@
+//go:build unix
+
+package server
+
+import (
+	"net"
+	"syscall"
+)
+
+// restoreNonblock puts the socket of ln back in non-blocking mode. Passing
+// a socket to a new process puts it in blocking mode, which it shares with
+// its duplicates, and a listener accepting in blocking mode cannot be closed.
+func restoreNonblock(ln net.Listener) error {
+	sc, ok := ln.(syscall.Conn)
+	if !ok {
+		return nil
+	}
+	rc, err := sc.SyscallConn()
+	if err != nil {
+		return err
+	}
+
+	var sockErr error
+	if err := rc.Control(func(fd uintptr) { sockErr = syscall.SetNonblock(int(fd), true) }); err != nil {
+		return err
+	}
+	return sockErr
+}
@@ -0,0 +1,100 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// listenFDsStart is the first file descriptor passed by systemd socket activation
+const listenFDsStart = 3
+
+// listen creates the listener of addr, which is either a TCP address such
+// as ":8080", a Unix socket path as "unix:///run/app.sock", or a socket
+// inherited from systemd socket activation as "systemd://", for the first
+// one, or "systemd://name", for the one of FileDescriptorName=name.
+// mode, when not zero, is the file mode of a Unix socket, which otherwise
+// follows the umask. reusePort sets SO_REUSEPORT on a TCP socket.
+// The listener passed by a process handing off its sockets is used
+// instead, whatever the form of addr.
+func listen(addr string, mode os.FileMode, reusePort bool) (net.Listener, error) {
+	if ln, ok, err := inherited(addr); ok {
+		return ln, err
+	}
+
+	switch {
+	case strings.HasPrefix(addr, "unix://"):
+		return listenUnix(strings.TrimPrefix(addr, "unix://"), mode)
+	case strings.HasPrefix(addr, "systemd://"):
+		return listenSystemd(strings.TrimPrefix(addr, "systemd://"))
+	default:
+		var lc net.ListenConfig
+		if reusePort {
+			lc.Control = reusePortControl
+		}
+		return lc.Listen(context.Background(), "tcp", addr)
+	}
+}
+
+// listenUnix listens on the Unix socket at path, replacing the socket left
+// by a previous run. The socket is removed when the listener is closed.
+func listenUnix(path string, mode os.FileMode) (net.Listener, error) {
+	if path == "" {
+		return nil, errors.New("unix socket path is empty")
+	}
+	if info, err := os.Stat(path); err == nil {
+		if info.Mode()&os.ModeSocket == 0 {
+			return nil, fmt.Errorf("%s exists and is not a socket", path)
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, fmt.Errorf("failed to remove stale socket: %w", err)
+		}
+	}
+
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if mode != 0 {
+		if err := os.Chmod(path, mode); err != nil {
+			ln.Close()
+			return nil, fmt.Errorf("failed to set socket mode: %w", err)
+		}
+	}
+	return ln, nil
+}
+
+// listenSystemd returns the listener of a socket passed by systemd, the one
+// named name or the first one when name is empty, as described in
+// sd_listen_fds(3)
+func listenSystemd(name string) (net.Listener, error) {
+	if pid, err := strconv.Atoi(os.Getenv("LISTEN_PID")); err != nil || pid != os.Getpid() {
+		return nil, errors.New("no sockets passed by systemd: LISTEN_PID is not the pid of the process")
+	}
+	count, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || count < 1 {
+		return nil, errors.New("no sockets passed by systemd: LISTEN_FDS is not set")
+	}
+
+	names := strings.Split(os.Getenv("LISTEN_FDNAMES"), ":")
+	for i := range count {
+		if name != "" && (i >= len(names) || names[i] != name) {
+			continue
+		}
+
+		fd := listenFDsStart + i
+		f := os.NewFile(uintptr(fd), "systemd:"+name)
+		ln, err := net.FileListener(f)
+		// FileListener duplicates the descriptor, the inherited one is closed
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to use socket %d passed by systemd: %w", fd, err)
+		}
+		return ln, nil
+	}
+	return nil, fmt.Errorf("no socket named %q passed by systemd", name)
+}
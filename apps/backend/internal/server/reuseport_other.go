@@ -0,0 +1,14 @@
+//go:build !(linux || darwin || dragonfly || freebsd || netbsd || openbsd)
+
+package server
+
+import (
+	"fmt"
+	"runtime"
+	"syscall"
+)
+
+// reusePortControl fails, as SO_REUSEPORT is not supported on this platform
+func reusePortControl(_, _ string, _ syscall.RawConn) error {
+	return fmt.Errorf("SO_REUSEPORT is not supported on %s", runtime.GOOS)
+}
@@ -1,29 +1,130 @@
 package server
 
 import (
+	"cmp"
 	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
+	"net"
 	"net/http"
+	"os"
+	"strconv"
 	"time"
 
 	"github.com/PrinceNarteh/go-boilerplate/internal/config"
+	"github.com/PrinceNarteh/go-boilerplate/internal/libs/async"
 	"github.com/rs/zerolog"
+	"golang.org/x/crypto/acme/autocert"
 )
 
 // Server represents the HTTP server
 type Server struct {
 	httpServer *http.Server
+	listener   net.Listener
 	logger     *zerolog.Logger
+
+	// tls is set when the server serves HTTPS, and redirectServer when
+	// HTTP requests are redirected to it
+	tls              *config.ServerTLSConfig
+	redirectServer   *http.Server
+	redirectListener net.Listener
 }
 
-// New creates a new HTTP server instance
-func New(cfg *config.Config, handler http.Handler, logger *zerolog.Logger) *Server {
+// New creates a new HTTP server instance, listening on cfg.Server.Listen
+// or, when empty, on cfg.Server.Port. The server must be started, or stopped
+// to release its listener.
+func New(cfg *config.Config, handler http.Handler, logger *zerolog.Logger) (*Server, error) {
+	addr := cmp.Or(cfg.Server.Listen, ":"+cfg.Server.Port)
+
+	var mode os.FileMode
+	if cfg.Server.SocketMode != "" {
+		m, err := strconv.ParseUint(cfg.Server.SocketMode, 8, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid socket mode %q: %w", cfg.Server.SocketMode, err)
+		}
+		mode = os.FileMode(m)
+	}
+	ln, err := listen(addr, mode, cfg.Server.ReusePort)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %w", addr, err)
+	}
+
 	srv := &http.Server{
-		Addr:         ":" + cfg.Server.Port,
-		Handler:      handler,
-		ReadTimeout:  time.Duration(cfg.Server.ReadTimeout) * time.Second,
-		WriteTimeout: time.Duration(cfg.Server.WriteTimeout) * time.Second,
-		IdleTimeout:  time.Duration(cfg.Server.IdleTimeout) * time.Second,
+		Addr:              addr,
+		Handler:           handler,
+		ReadTimeout:       time.Duration(cfg.Server.ReadTimeout) * time.Second,
+		ReadHeaderTimeout: time.Duration(cfg.Server.ReadHeaderTimeout) * time.Second,
+		WriteTimeout:      time.Duration(cfg.Server.WriteTimeout) * time.Second,
+		IdleTimeout:       time.Duration(cfg.Server.IdleTimeout) * time.Second,
+		MaxHeaderBytes:    cfg.Server.MaxHeaderBytes,
+	}
+	s := &Server{
+		httpServer: srv,
+		listener:   ln,
+		logger:     logger,
+	}
+
+	if tlsCfg := cfg.Server.TLS; tlsCfg.Enabled {
+		s.tls = &tlsCfg
+		srv.TLSConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+
+		redirect := redirectHandler(cfg.Server.Port)
+		if tlsCfg.Autocert {
+			manager := &autocert.Manager{
+				Prompt:     autocert.AcceptTOS,
+				HostPolicy: autocert.HostWhitelist(tlsCfg.Hosts...),
+				Cache:      autocert.DirCache(tlsCfg.CacheDir),
+				Email:      tlsCfg.Email,
+			}
+			srv.TLSConfig = manager.TLSConfig()
+			srv.TLSConfig.MinVersion = tls.VersionTLS12
+			redirect = manager.HTTPHandler(redirect)
+		}
+
+		if tlsCfg.RedirectPort != "" {
+			redirectAddr := ":" + tlsCfg.RedirectPort
+			s.redirectListener, err = listen(redirectAddr, 0, cfg.Server.ReusePort)
+			if err != nil {
+				ln.Close()
+				return nil, fmt.Errorf("failed to listen on %s: %w", redirectAddr, err)
+			}
+			s.redirectServer = &http.Server{
+				Addr:              redirectAddr,
+				Handler:           redirect,
+				ReadHeaderTimeout: 10 * time.Second,
+				IdleTimeout:       srv.IdleTimeout,
+			}
+		}
+	}
+
+	return s, nil
+}
+
+// redirectHandler redirects requests to the same URL over HTTPS on port
+func redirectHandler(port string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host, _, err := net.SplitHostPort(r.Host)
+		if err != nil {
+			host = r.Host
+		}
+		if port != "443" {
+			host = net.JoinHostPort(host, port)
+		}
+
+		target := "https://" + host + r.URL.RequestURI()
+		http.Redirect(w, r, target, http.StatusPermanentRedirect)
+	})
+}
+
+// NewDebug creates a server of the debug endpoints listening on port, to be
+// kept internal. It has no write timeout, so CPU profiles and traces longer
+// than the write timeout of the main server can be captured.
+func NewDebug(port string, handler http.Handler, logger *zerolog.Logger) *Server {
+	srv := &http.Server{
+		Addr:              ":" + port,
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
 	}
 
 	return &Server{
@@ -32,25 +133,111 @@ func New(cfg *config.Config, handler http.Handler, logger *zerolog.Logger) *Serv
 	}
 }
 
-// Start starts the HTTP server
+// NewAdmin creates the internal admin server listening on
+// cfg.Server.AdminListen, in the forms of config.ServerConfig.Listen. Like
+// the debug server, it has no write timeout, for long profiles.
+func NewAdmin(cfg *config.Config, handler http.Handler, logger *zerolog.Logger) (*Server, error) {
+	addr := cfg.Server.AdminListen
+	ln, err := listen(addr, 0, cfg.Server.ReusePort)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %w", addr, err)
+	}
+
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	return &Server{
+		httpServer: srv,
+		listener:   ln,
+		logger:     logger,
+	}, nil
+}
+
+// Addr returns the address the server listens on
+func (s *Server) Addr() string {
+	return s.httpServer.Addr
+}
+
+// Start starts the HTTP server, serving HTTPS and HTTP/2 when TLS is
+// enabled, along with the redirect of HTTP requests when configured
 func (s *Server) Start() error {
-	s.logger.Info().Msgf("Starting HTTP server on port %s", s.httpServer.Addr)
-	
-	if err := s.httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-		return fmt.Errorf("failed to start HTTP server: %w", err)
+	if s.tls == nil {
+		s.logger.Info().Msgf("Starting HTTP server on %s", s.httpServer.Addr)
+
+		// The debug server listens only once started
+		serve := s.httpServer.ListenAndServe
+		if s.listener != nil {
+			serve = func() error { return s.httpServer.Serve(s.listener) }
+		}
+		if err := serve(); err != nil && err != http.ErrServerClosed {
+			return fmt.Errorf("failed to start HTTP server: %w", err)
+		}
+		return nil
+	}
+
+	if s.redirectServer != nil {
+		s.logger.Info().Msgf("Redirecting HTTP requests on port %s to HTTPS", s.redirectServer.Addr)
+		async.Go(context.Background(), "server-redirect", func(context.Context) error {
+			if err := s.redirectServer.Serve(s.redirectListener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				return fmt.Errorf("failed to serve HTTP redirect: %w", err)
+			}
+			return nil
+		})
+	}
+
+	s.logger.Info().Bool("autocert", s.tls.Autocert).Msgf("Starting HTTPS server on %s", s.httpServer.Addr)
+
+	// With autocert, the certificates come from the TLS config rather than files
+	if err := s.httpServer.ServeTLS(s.listener, s.tls.CertFile, s.tls.KeyFile); err != nil && err != http.ErrServerClosed {
+		return fmt.Errorf("failed to start HTTPS server: %w", err)
 	}
-	
 	return nil
 }
 
+// Drain disables keep-alives and closes the idle connections, so that
+// clients open their next connections to another instance, or to the
+// process replacing this one, while the server keeps serving until stopped
+func (s *Server) Drain() {
+	s.httpServer.SetKeepAlivesEnabled(false)
+	if s.redirectServer != nil {
+		s.redirectServer.SetKeepAlivesEnabled(false)
+	}
+}
+
+// listeners returns the listeners of the server by address, for Handoff
+func (s *Server) listeners() map[string]net.Listener {
+	listeners := make(map[string]net.Listener)
+	if s.listener != nil {
+		listeners[s.httpServer.Addr] = s.listener
+	}
+	if s.redirectListener != nil {
+		listeners[s.redirectServer.Addr] = s.redirectListener
+	}
+	return listeners
+}
+
 // Stop gracefully stops the HTTP server
 func (s *Server) Stop(ctx context.Context) error {
 	s.logger.Info().Msg("Shutting down HTTP server...")
-	
+
 	if err := s.httpServer.Shutdown(ctx); err != nil {
 		return fmt.Errorf("failed to shutdown HTTP server: %w", err)
 	}
-	
+	// Release the listener of a server stopped before it started; it is
+	// already closed otherwise
+	if s.listener != nil {
+		_ = s.listener.Close()
+	}
+	if s.redirectServer != nil {
+		if err := s.redirectServer.Shutdown(ctx); err != nil {
+			return fmt.Errorf("failed to shutdown HTTP redirect server: %w", err)
+		}
+		_ = s.redirectListener.Close()
+	}
+
 	s.logger.Info().Msg("HTTP server stopped")
 	return nil
 }
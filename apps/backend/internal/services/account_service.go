@@ -0,0 +1,203 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/PrinceNarteh/go-boilerplate/internal/audit"
+	"github.com/PrinceNarteh/go-boilerplate/internal/auth"
+	"github.com/PrinceNarteh/go-boilerplate/internal/errs"
+	"github.com/PrinceNarteh/go-boilerplate/internal/libs/id"
+	"github.com/PrinceNarteh/go-boilerplate/internal/models"
+	"github.com/PrinceNarteh/go-boilerplate/internal/repositories"
+)
+
+// errIncorrectPassword is returned when the current password of a password
+// change does not match
+var errIncorrectPassword = errs.ErrValidation.WithDetails(map[string]string{
+	"current_password": "current_password is incorrect",
+})
+
+// AccountServiceOptions configures the optional dependencies of an AccountService
+type AccountServiceOptions struct {
+	// Tx makes a change and its security event atomic when set
+	Tx Transactor
+}
+
+// AccountService implements the self-serve account operations of users:
+// their password, their sessions and their security log. Every change is
+// recorded in the security log.
+type AccountService struct {
+	repo     repositories.UserRepository
+	sessions repositories.SessionRepository
+	log      audit.Log
+	tx       Transactor
+}
+
+// NewAccountService creates a new account service
+func NewAccountService(
+	repo repositories.UserRepository,
+	sessions repositories.SessionRepository,
+	log audit.Log,
+	opts AccountServiceOptions,
+) *AccountService {
+	tx := opts.Tx
+	if tx == nil {
+		tx = noTx{}
+	}
+
+	return &AccountService{
+		repo:     repo,
+		sessions: sessions,
+		log:      log,
+		tx:       tx,
+	}
+}
+
+// CheckPrincipal implements auth.PrincipalChecker, rejecting the tokens of
+// revoked or expired sessions. Tokens issued without a session are rejected
+// when they were issued before the last password change of their user, in
+// the second of the change or later being accepted.
+func (s *AccountService) CheckPrincipal(ctx context.Context, p *auth.Principal) error {
+	if p.SessionID != "" {
+		sessionID, err := id.Parse(p.SessionID)
+		if err != nil {
+			return fmt.Errorf("%w: invalid session", auth.ErrInvalidToken)
+		}
+		session, err := s.sessions.Get(ctx, sessionID)
+		if errors.Is(err, errs.ErrNotFound) {
+			return fmt.Errorf("%w: unknown session", auth.ErrInvalidToken)
+		}
+		if err != nil {
+			return err
+		}
+		if session.UserID != p.UserID || !session.Active(time.Now()) {
+			return fmt.Errorf("%w: session is no longer active", auth.ErrInvalidToken)
+		}
+		return nil
+	}
+
+	changedAt, err := s.repo.GetPasswordChangedAt(ctx, p.UserID)
+	if errors.Is(err, errs.ErrNotFound) {
+		return fmt.Errorf("%w: user %d no longer exists", auth.ErrInvalidToken, p.UserID)
+	}
+	if err != nil {
+		return err
+	}
+	// iat has a precision of a second
+	if changedAt != nil && p.IssuedAt.Before(changedAt.Truncate(time.Second)) {
+		return fmt.Errorf("%w: password changed since the token was issued", auth.ErrInvalidToken)
+	}
+	return nil
+}
+
+// ChangePassword replaces the password of the user of event, the security
+// event of the request built with audit.FromRequest, and signs out the
+// other sessions of the user, as well as every token issued without a
+// session, see CheckPrincipal. The current password must match, unless the
+// user has none yet. It returns a validation error when it does not.
+func (s *AccountService) ChangePassword(ctx context.Context, event *audit.Event, req models.ChangePasswordRequest) error {
+	hash, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return fmt.Errorf("failed to hash password: %w", err)
+	}
+
+	err = s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		current, err := s.repo.GetPasswordHash(ctx, event.UserID)
+		if err != nil {
+			return err
+		}
+		if current != "" && bcrypt.CompareHashAndPassword([]byte(current), []byte(req.CurrentPassword)) != nil {
+			return errIncorrectPassword
+		}
+
+		if err := s.repo.SetPasswordHash(ctx, event.UserID, string(hash)); err != nil {
+			return err
+		}
+		revoked, err := s.sessions.RevokeOthers(ctx, event.UserID, event.SessionID)
+		if err != nil {
+			return err
+		}
+
+		event.Type = audit.PasswordChanged
+		event.Details = map[string]string{"sessions_revoked": strconv.FormatInt(revoked, 10)}
+		return s.log.Record(ctx, event)
+	})
+	return userError(err)
+}
+
+// ListSessions returns the active sessions of a user, newest first, with
+// current, the session of the request, marked
+func (s *AccountService) ListSessions(ctx context.Context, userID int, current id.ID) ([]*models.Session, error) {
+	sessions, err := s.sessions.ListActive(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if sessions == nil {
+		sessions = []*models.Session{}
+	}
+	for _, session := range sessions {
+		session.Current = !current.IsZero() && session.ID == current
+	}
+	return sessions, nil
+}
+
+// RevokeSession signs out a session of the user of event, the security event
+// of the request built with audit.FromRequest. It returns a not found error
+// when the user has no such active session.
+func (s *AccountService) RevokeSession(ctx context.Context, event *audit.Event, sessionID id.ID) error {
+	err := s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		if err := s.sessions.Revoke(ctx, event.UserID, sessionID); err != nil {
+			return err
+		}
+
+		event.Type = audit.SessionRevoked
+		event.Details = map[string]string{"session_id": sessionID.String()}
+		return s.log.Record(ctx, event)
+	})
+	if errors.Is(err, errs.ErrNotFound) {
+		return errs.NewNotFound("Session")
+	}
+	return err
+}
+
+// RevokeOtherSessions signs out every session of the user of event, the
+// security event of the request built with audit.FromRequest, except the
+// session of the request, and returns how many it signed out
+func (s *AccountService) RevokeOtherSessions(ctx context.Context, event *audit.Event) (int64, error) {
+	var revoked int64
+	err := s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		var err error
+		if revoked, err = s.sessions.RevokeOthers(ctx, event.UserID, event.SessionID); err != nil {
+			return err
+		}
+
+		event.Type = audit.SessionsRevoked
+		event.Details = map[string]string{"sessions_revoked": strconv.FormatInt(revoked, 10)}
+		return s.log.Record(ctx, event)
+	})
+	return revoked, err
+}
+
+// SecurityEvents returns a page of the security log of a user, newest first.
+// It returns the cursor of the next page, which is empty on the last page.
+func (s *AccountService) SecurityEvents(
+	ctx context.Context,
+	userID int,
+	cursor string,
+	limit int,
+) ([]*audit.Event, string, error) {
+	events, next, err := s.log.ListByUser(ctx, userID, cursor, limit)
+	if err != nil {
+		return nil, "", err
+	}
+	if events == nil {
+		events = []*audit.Event{}
+	}
+	return events, next, nil
+}
@@ -0,0 +1,220 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/rs/zerolog"
+
+	"github.com/PrinceNarteh/go-boilerplate/internal/errs"
+	"github.com/PrinceNarteh/go-boilerplate/internal/libs/id"
+	"github.com/PrinceNarteh/go-boilerplate/internal/libs/upload"
+	"github.com/PrinceNarteh/go-boilerplate/internal/models"
+	"github.com/PrinceNarteh/go-boilerplate/internal/repositories"
+	"github.com/PrinceNarteh/go-boilerplate/internal/scanner"
+)
+
+// RescanFileJob is the job type that rescans a file whose scan failed
+const RescanFileJob = "file.rescan"
+
+// RescanFilePayload is the payload of a RescanFileJob
+type RescanFilePayload struct {
+	FileID int `json:"file_id"`
+}
+
+// FileStorage stores the content of uploaded files under a key
+type FileStorage interface {
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+}
+
+// JobEnqueuer enqueues background jobs, e.g. on a Redis-backed job queue
+type JobEnqueuer interface {
+	Enqueue(ctx context.Context, jobType string, payload any) error
+}
+
+// FileServiceOptions configures the optional dependencies of a FileService
+type FileServiceOptions struct {
+	// Scanner checks uploads for malware, scanner.Noop when nil
+	Scanner scanner.Scanner
+	// Jobs receives a RescanFileJob when a scan fails. Without it, files
+	// whose scan failed stay pending until RescanPending is run.
+	Jobs   JobEnqueuer
+	Logger *zerolog.Logger
+}
+
+// FileService stores uploaded files, scanning them for malware first
+type FileService struct {
+	repo    repositories.FileRepository
+	storage FileStorage
+	scanner scanner.Scanner
+	jobs    JobEnqueuer
+	logger  *zerolog.Logger
+}
+
+// NewFileService creates a new file service
+func NewFileService(repo repositories.FileRepository, storage FileStorage, opts FileServiceOptions) *FileService {
+	logger := opts.Logger
+	if logger == nil {
+		nop := zerolog.Nop()
+		logger = &nop
+	}
+	scan := opts.Scanner
+	if scan == nil {
+		scan = scanner.Noop{}
+	}
+
+	return &FileService{
+		repo:    repo,
+		storage: storage,
+		scanner: scan,
+		jobs:    opts.Jobs,
+		logger:  logger,
+	}
+}
+
+// Upload scans and stores a file uploaded by a user.
+// Infected files are stored as quarantined so that they can be inspected but
+// never served. When the scanner is unavailable, the file is stored as
+// pending and a rescan is enqueued.
+func (s *FileService) Upload(ctx context.Context, userID int, file *upload.File) (*models.File, error) {
+	status, threat, scanErr := s.scan(ctx, file)
+	if scanErr != nil {
+		s.logger.Warn().Err(scanErr).Str("filename", file.Filename).Msg("Failed to scan upload, deferring scan")
+	}
+
+	content, err := file.Open()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open upload: %w", err)
+	}
+	defer content.Close()
+
+	key := id.NewString()
+	if err := s.storage.Put(ctx, key, content, file.Size, file.ContentType); err != nil {
+		return nil, fmt.Errorf("failed to store upload: %w", err)
+	}
+
+	created, err := s.repo.Create(ctx, &models.File{
+		UserID:      userID,
+		Filename:    file.Filename,
+		ContentType: file.ContentType,
+		Size:        file.Size,
+		StorageKey:  key,
+		ScanStatus:  status,
+		ScanThreat:  threat,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if created.ScanStatus == models.ScanQuarantined {
+		s.logger.Warn().
+			Int("file_id", created.ID).
+			Int("user_id", userID).
+			Str("threat", threat).
+			Msg("Quarantined infected upload")
+	}
+	if scanErr != nil {
+		s.enqueueRescan(ctx, created.ID)
+	}
+
+	return created, nil
+}
+
+// Get retrieves a file that may be served. Files that are pending or
+// quarantined are reported as not found.
+func (s *FileService) Get(ctx context.Context, id int) (*models.File, error) {
+	file, err := s.repo.GetByID(ctx, id)
+	if errors.Is(err, errs.ErrNotFound) {
+		return nil, errs.NewNotFound("File")
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !file.Available() {
+		return nil, errs.NewNotFound("File")
+	}
+	return file, nil
+}
+
+// Rescan scans the stored content of a file again and records the result.
+// It handles RescanFileJob and returns an error, so that the job is retried,
+// when the scan fails again.
+func (s *FileService) Rescan(ctx context.Context, id int) (*models.File, error) {
+	file, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	content, err := s.storage.Get(ctx, file.StorageKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read stored file: %w", err)
+	}
+	defer content.Close()
+
+	result, err := s.scanner.Scan(ctx, content)
+	if err != nil {
+		return nil, fmt.Errorf("failed to rescan file %d: %w", id, err)
+	}
+
+	return s.repo.SetScanResult(ctx, id, scanStatus(result), result.Threat)
+}
+
+// RescanPending enqueues a rescan of up to limit files whose scan is still
+// pending, e.g. from a periodic job after a scanner outage
+func (s *FileService) RescanPending(ctx context.Context, limit int) (int, error) {
+	files, err := s.repo.ListPendingScan(ctx, limit)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, file := range files {
+		if s.jobs == nil {
+			if _, err := s.Rescan(ctx, file.ID); err != nil {
+				return 0, err
+			}
+			continue
+		}
+		if err := s.jobs.Enqueue(ctx, RescanFileJob, RescanFilePayload{FileID: file.ID}); err != nil {
+			return 0, fmt.Errorf("failed to enqueue file rescan: %w", err)
+		}
+	}
+
+	return len(files), nil
+}
+
+// scan scans an upload and returns its scan status
+func (s *FileService) scan(ctx context.Context, file *upload.File) (models.ScanStatus, string, error) {
+	content, err := file.Open()
+	if err != nil {
+		return models.ScanPending, "", err
+	}
+	defer content.Close()
+
+	result, err := s.scanner.Scan(ctx, content)
+	if err != nil {
+		return models.ScanPending, "", err
+	}
+	return scanStatus(result), result.Threat, nil
+}
+
+// enqueueRescan enqueues a rescan of a file. Failures are logged rather than
+// returned because the file is stored as pending and RescanPending picks it up.
+func (s *FileService) enqueueRescan(ctx context.Context, id int) {
+	if s.jobs == nil {
+		return
+	}
+	if err := s.jobs.Enqueue(ctx, RescanFileJob, RescanFilePayload{FileID: id}); err != nil {
+		s.logger.Error().Err(err).Int("file_id", id).Msg("Failed to enqueue file rescan")
+	}
+}
+
+// scanStatus maps a scan result to the stored scan status
+func scanStatus(result scanner.Result) models.ScanStatus {
+	if result.Infected() {
+		return models.ScanQuarantined
+	}
+	return models.ScanClean
+}
@@ -0,0 +1,235 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+
+	"github.com/rs/zerolog"
+
+	"github.com/PrinceNarteh/go-boilerplate/internal/errs"
+	"github.com/PrinceNarteh/go-boilerplate/internal/models"
+	"github.com/PrinceNarteh/go-boilerplate/internal/repositories"
+	"github.com/PrinceNarteh/go-boilerplate/internal/saga"
+)
+
+// defaultTenantSchemaPrefix prefixes the ID of a tenant to name its schema
+const defaultTenantSchemaPrefix = "tenant_"
+
+// TenantProvisionSaga is the name of the saga provisioning tenants, see
+// TenantService.ProvisionSaga
+const TenantProvisionSaga = "tenant.provision"
+
+// tenantIDPattern matches the IDs of tenants, which are part of schema names
+var tenantIDPattern = regexp.MustCompile(`^[a-z][a-z0-9_]{0,47}$`)
+
+// SchemaMigrator applies the migrations to a schema, such as database.MigrateSchema
+type SchemaMigrator func(ctx context.Context, schema string) error
+
+// TenantServiceOptions configures the optional settings of a TenantService
+type TenantServiceOptions struct {
+	// SchemaPrefix prefixes the ID of a tenant to name its schema, "tenant_" when empty
+	SchemaPrefix string
+	Logger       *zerolog.Logger
+}
+
+// TenantService provisions the tenants isolated in their own schema
+type TenantService struct {
+	repo    repositories.TenantRepository
+	migrate SchemaMigrator
+	prefix  string
+	logger  *zerolog.Logger
+}
+
+// NewTenantService creates a new tenant service. migrate applies the
+// migrations to the schemas of new tenants.
+func NewTenantService(
+	repo repositories.TenantRepository,
+	migrate SchemaMigrator,
+	opts TenantServiceOptions,
+) *TenantService {
+	logger := opts.Logger
+	if logger == nil {
+		nop := zerolog.Nop()
+		logger = &nop
+	}
+	prefix := opts.SchemaPrefix
+	if prefix == "" {
+		prefix = defaultTenantSchemaPrefix
+	}
+
+	return &TenantService{
+		repo:    repo,
+		migrate: migrate,
+		prefix:  prefix,
+		logger:  logger,
+	}
+}
+
+// Provision creates the schema of a new tenant, applies the migrations to it
+// and registers the tenant. The tenant is registered last, so requests only
+// reach it once its schema is ready. Provisioning a tenant again after a
+// failure resumes from the last applied migration.
+func (s *TenantService) Provision(ctx context.Context, id string) (*models.Tenant, error) {
+	if err := s.checkNew(ctx, id); err != nil {
+		return nil, err
+	}
+
+	schema := s.prefix + id
+	if err := s.repo.CreateSchema(ctx, schema); err != nil {
+		return nil, err
+	}
+	if err := s.migrate(ctx, schema); err != nil {
+		return nil, fmt.Errorf("failed to migrate schema %s: %w", schema, err)
+	}
+
+	tenant, err := s.repo.Create(ctx, &models.Tenant{ID: id, Schema: schema})
+	if err != nil {
+		return nil, tenantError(err)
+	}
+
+	s.logger.Info().Str("tenant", id).Str("schema", schema).Msg("Tenant provisioned")
+	return tenant, nil
+}
+
+// ProvisionSaga returns the saga provisioning a tenant in the background as
+// Provision does, for deployments whose migrations take long. It is started
+// with the ID of the tenant as its "tenant_id" data, and drops the schema of
+// the tenant when a later step fails.
+func (s *TenantService) ProvisionSaga() saga.Definition {
+	tenantID := func(state *saga.State) string {
+		id, _ := state.Data["tenant_id"].(string)
+		return id
+	}
+
+	return saga.Definition{
+		Name: TenantProvisionSaga,
+		Steps: []saga.Step{
+			{
+				Name: "create_schema",
+				Action: func(ctx context.Context, state *saga.State) error {
+					if err := s.checkNew(ctx, tenantID(state)); err != nil {
+						return err
+					}
+					return s.repo.CreateSchema(ctx, s.prefix+tenantID(state))
+				},
+				Compensate: func(ctx context.Context, state *saga.State) error {
+					return s.repo.DropSchema(ctx, s.prefix+tenantID(state))
+				},
+			},
+			{
+				Name: "migrate",
+				Action: func(ctx context.Context, state *saga.State) error {
+					return s.migrate(ctx, s.prefix+tenantID(state))
+				},
+			},
+			{
+				Name: "register",
+				Action: func(ctx context.Context, state *saga.State) error {
+					id, schema := tenantID(state), s.prefix+tenantID(state)
+					_, err := s.repo.Create(ctx, &models.Tenant{ID: id, Schema: schema})
+					if errors.Is(err, errs.ErrConflict) {
+						// Registered before a crash, when the tenant has this schema
+						if tenant, getErr := s.repo.Get(ctx, id); getErr == nil && tenant.Schema == schema {
+							return nil
+						}
+					}
+					if err != nil {
+						return err
+					}
+					s.logger.Info().Str("tenant", id).Str("schema", schema).Msg("Tenant provisioned")
+					return nil
+				},
+			},
+		},
+	}
+}
+
+// checkNew returns a validation error when id is not a valid tenant ID, and
+// a conflict error when the tenant exists
+func (s *TenantService) checkNew(ctx context.Context, id string) error {
+	if !tenantIDPattern.MatchString(id) {
+		return errs.NewValidation("Invalid tenant ID").WithDetails(map[string]string{
+			"id": "must start with a lowercase letter and contain only lowercase letters, digits and underscores",
+		})
+	}
+
+	if _, err := s.repo.Get(ctx, id); err == nil {
+		return tenantError(errs.ErrConflict)
+	} else if !errors.Is(err, errs.ErrNotFound) {
+		return err
+	}
+	return nil
+}
+
+// Deprovision unregisters a tenant and drops its schema with all its data.
+// The tenant is unregistered first, so requests stop reaching it before its
+// tables are dropped.
+func (s *TenantService) Deprovision(ctx context.Context, id string) error {
+	tenant, err := s.repo.Get(ctx, id)
+	if err != nil {
+		return tenantError(err)
+	}
+
+	if err := s.repo.Delete(ctx, id); err != nil {
+		return tenantError(err)
+	}
+	if err := s.repo.DropSchema(ctx, tenant.Schema); err != nil {
+		return err
+	}
+
+	s.logger.Warn().Str("tenant", id).Str("schema", tenant.Schema).Msg("Tenant deprovisioned")
+	return nil
+}
+
+// List returns every tenant
+func (s *TenantService) List(ctx context.Context) ([]*models.Tenant, error) {
+	tenants, err := s.repo.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if tenants == nil {
+		tenants = []*models.Tenant{}
+	}
+	return tenants, nil
+}
+
+// TenantSchema returns the schema of the tenant id, implementing
+// middlewares.TenantResolver
+func (s *TenantService) TenantSchema(ctx context.Context, id string) (string, error) {
+	tenant, err := s.repo.Get(ctx, id)
+	if err != nil {
+		return "", tenantError(err)
+	}
+	return tenant.Schema, nil
+}
+
+// MigrateAll applies the pending migrations to the schema of every tenant.
+// A tenant failing to migrate does not stop the others.
+func (s *TenantService) MigrateAll(ctx context.Context) error {
+	tenants, err := s.repo.List(ctx)
+	if err != nil {
+		return err
+	}
+
+	var errList []error
+	for _, tenant := range tenants {
+		if err := s.migrate(ctx, tenant.Schema); err != nil {
+			errList = append(errList, fmt.Errorf("tenant %s: %w", tenant.ID, err))
+		}
+	}
+	return errors.Join(errList...)
+}
+
+// tenantError maps repository errors to application errors
+func tenantError(err error) error {
+	switch {
+	case errors.Is(err, errs.ErrConflict):
+		return errs.ErrConflict.WithDetails(map[string]string{"id": "tenant already exists"})
+	case errors.Is(err, errs.ErrNotFound):
+		return errs.NewNotFound("Tenant")
+	default:
+		return err
+	}
+}
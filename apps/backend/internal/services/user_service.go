@@ -0,0 +1,350 @@
+// Package services contains the business logic of the application.
+//
+// Services sit between the HTTP handlers and the repositories: handlers decode
+// and validate requests, services enforce business rules and translate
+// storage errors into errs.AppError values, and repositories only run SQL.
+// Services are also where cross-cutting concerns such as caching and domain
+// events are plugged in.
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/PrinceNarteh/go-boilerplate/internal/auth"
+	"github.com/PrinceNarteh/go-boilerplate/internal/database"
+	"github.com/PrinceNarteh/go-boilerplate/internal/errs"
+	"github.com/PrinceNarteh/go-boilerplate/internal/models"
+	"github.com/PrinceNarteh/go-boilerplate/internal/notifications"
+	"github.com/PrinceNarteh/go-boilerplate/internal/repositories"
+	"github.com/PrinceNarteh/go-boilerplate/internal/telemetry"
+)
+
+// UserEventType identifies what happened to a user
+type UserEventType string
+
+// User event types
+const (
+	UserCreated  UserEventType = "user.created"
+	UserUpdated  UserEventType = "user.updated"
+	UserDeleted  UserEventType = "user.deleted"
+	UserRestored UserEventType = "user.restored"
+)
+
+// UserEvent is published after a user is changed
+type UserEvent struct {
+	Type       UserEventType `json:"type"`
+	User       *models.User  `json:"user"`
+	OccurredAt time.Time     `json:"occurred_at"`
+}
+
+// UserEventPublisher receives user events, e.g. to enqueue them for other services
+type UserEventPublisher interface {
+	PublishUserEvent(ctx context.Context, event UserEvent) error
+}
+
+// AccountUpdatedNotification is the notification of changes to the account of a user
+var AccountUpdatedNotification = notifications.Declare(notifications.Kind{
+	Name:        "account.updated",
+	Description: "Your account details were changed",
+	Defaults:    []notifications.Channel{notifications.ChannelEmail, notifications.ChannelInApp},
+})
+
+// UserEventsTopic is the outbox topic of user events, keyed by user ID
+const UserEventsTopic = "users"
+
+// EventOutbox records events in the transaction carried by its context.
+// It is implemented by outbox.Store.
+type EventOutbox interface {
+	Add(ctx context.Context, topic, key, eventType string, payload any) error
+}
+
+// UserCache caches users by ID in front of the repository
+type UserCache interface {
+	Get(ctx context.Context, id int) (*models.User, bool)
+	Set(ctx context.Context, user *models.User)
+	Delete(ctx context.Context, id int)
+}
+
+// Transactor runs a function within a transaction carried by its context.
+// It is implemented by database.TxManager.
+type Transactor interface {
+	WithinTx(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+// noTx runs functions without a transaction
+type noTx struct{}
+
+// WithinTx implements Transactor
+func (noTx) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(ctx)
+}
+
+// UserServiceOptions configures the optional dependencies of a UserService
+type UserServiceOptions struct {
+	// Cache is consulted before the repository when set
+	Cache UserCache
+	// Events receives an event after every successful change when set
+	Events UserEventPublisher
+	// Outbox records an event in the transaction of every change when set,
+	// for at-least-once delivery. It requires Tx.
+	Outbox EventOutbox
+	// Tx makes read-modify-write operations atomic when set
+	Tx     Transactor
+	Logger *zerolog.Logger
+}
+
+// UserService implements the business rules for users
+type UserService struct {
+	repo   repositories.UserRepository
+	cache  UserCache
+	events UserEventPublisher
+	outbox EventOutbox
+	tx     Transactor
+	logger *zerolog.Logger
+}
+
+// NewUserService creates a new user service
+func NewUserService(repo repositories.UserRepository, opts UserServiceOptions) *UserService {
+	logger := opts.Logger
+	if logger == nil {
+		nop := zerolog.Nop()
+		logger = &nop
+	}
+	tx := opts.Tx
+	if tx == nil {
+		tx = noTx{}
+	}
+
+	return &UserService{
+		repo:   repo,
+		cache:  opts.Cache,
+		events: opts.Events,
+		outbox: opts.Outbox,
+		tx:     tx,
+		logger: logger,
+	}
+}
+
+// Create creates a user. It returns errs.ErrConflict when the email is already in use.
+func (s *UserService) Create(ctx context.Context, req models.CreateUserRequest) (*models.User, error) {
+	var user *models.User
+	err := s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		var err error
+		if user, err = s.repo.Create(ctx, &models.User{Email: models.NormalizeEmail(req.Email)}); err != nil {
+			return err
+		}
+		return s.record(ctx, UserCreated, user)
+	})
+	if err != nil {
+		return nil, userError(err)
+	}
+
+	s.publish(ctx, UserCreated, user)
+	telemetry.RecordEvent("UserSignedUp", map[string]any{"user_id": user.ID})
+	return user, nil
+}
+
+// Get retrieves a user by ID. It returns a not found error when the user does not exist.
+func (s *UserService) Get(ctx context.Context, id int) (*models.User, error) {
+	if s.cache != nil {
+		if user, ok := s.cache.Get(ctx, id); ok {
+			return user, nil
+		}
+	}
+
+	user, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, userError(err)
+	}
+
+	if s.cache != nil {
+		s.cache.Set(ctx, user)
+	}
+	return user, nil
+}
+
+// GetAsOf retrieves a user as it was at asOf, for support and audit. The
+// history is read directly, as past versions never change, and includes
+// users deleted since.
+func (s *UserService) GetAsOf(ctx context.Context, id int, asOf time.Time) (*models.User, error) {
+	user, err := s.repo.GetByIDAsOf(ctx, id, asOf)
+	if err != nil {
+		return nil, userError(err)
+	}
+	return user, nil
+}
+
+// LoadPrincipal implements auth.PrincipalLoader, refreshing the principal of
+// a token from its user, which must still exist
+func (s *UserService) LoadPrincipal(ctx context.Context, p *auth.Principal) (*auth.Principal, error) {
+	user, err := s.Get(ctx, p.UserID)
+	var appErr *errs.AppError
+	if errors.As(err, &appErr) && appErr.Status == http.StatusNotFound {
+		return nil, fmt.Errorf("%w: user %d no longer exists", auth.ErrInvalidToken, p.UserID)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &auth.Principal{
+		UserID:    user.ID,
+		Email:     user.Email,
+		Roles:     p.Roles,
+		SessionID: p.SessionID,
+		TenantID:  p.TenantID,
+		IssuedAt:  p.IssuedAt,
+	}, nil
+}
+
+// Update applies the changes in req to a user. Fields left empty are kept,
+// and the preferences are merged with req.Preferences.
+// It returns errs.ErrConflict when the new email is already in use, or when
+// the user was modified since req.Version or since it was read.
+func (s *UserService) Update(ctx context.Context, id int, req models.UpdateUserRequest) (*models.User, error) {
+	var updated *models.User
+	err := s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		user, err := s.repo.GetByID(ctx, id)
+		if err != nil {
+			return err
+		}
+
+		if req.Version != 0 {
+			user.Version = req.Version
+		}
+		if req.Email != "" {
+			user.Email = models.NormalizeEmail(req.Email)
+		}
+		if len(req.Preferences) > 0 {
+			if err := user.Preferences.Merge(req.Preferences); err != nil {
+				return err
+			}
+		}
+
+		if updated, err = s.repo.Update(ctx, user); err != nil {
+			return err
+		}
+		return s.record(ctx, UserUpdated, updated)
+	})
+	if err != nil {
+		return nil, userError(err)
+	}
+
+	s.invalidate(ctx, id)
+	s.publish(ctx, UserUpdated, updated)
+	return updated, nil
+}
+
+// Delete soft-deletes a user, who can later be brought back with Restore.
+// It returns a not found error when the user does not exist.
+func (s *UserService) Delete(ctx context.Context, id int) error {
+	var user *models.User
+	err := s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		var err error
+		if user, err = s.repo.GetByID(ctx, id); err != nil {
+			return err
+		}
+		if err := s.repo.SoftDelete(ctx, id); err != nil {
+			return err
+		}
+		return s.record(ctx, UserDeleted, user)
+	})
+	if err != nil {
+		return userError(err)
+	}
+
+	s.invalidate(ctx, id)
+	s.publish(ctx, UserDeleted, user)
+	return nil
+}
+
+// Restore brings back a soft-deleted user. It returns a not found error when
+// there is no such deleted user, and errs.ErrConflict when their email has
+// been taken by another user since.
+func (s *UserService) Restore(ctx context.Context, id int) (*models.User, error) {
+	var user *models.User
+	err := s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		var err error
+		if user, err = s.repo.Restore(ctx, id); err != nil {
+			return err
+		}
+		return s.record(ctx, UserRestored, user)
+	})
+	if err != nil {
+		return nil, userError(err)
+	}
+
+	s.invalidate(ctx, id)
+	s.publish(ctx, UserRestored, user)
+	return user, nil
+}
+
+// List retrieves a page of users using keyset pagination.
+// It returns the cursor of the next page, which is empty on the last page.
+func (s *UserService) List(ctx context.Context, cursor string, limit int) ([]*models.User, string, error) {
+	users, next, err := s.repo.List(ctx, cursor, limit)
+	if err != nil {
+		return nil, "", userError(err)
+	}
+	if users == nil {
+		users = []*models.User{}
+	}
+	return users, next, nil
+}
+
+// invalidate removes a user from the cache
+func (s *UserService) invalidate(ctx context.Context, id int) {
+	if s.cache != nil {
+		s.cache.Delete(ctx, id)
+	}
+}
+
+// record adds a user event to the outbox, in the transaction of the change
+func (s *UserService) record(ctx context.Context, eventType UserEventType, user *models.User) error {
+	if s.outbox == nil {
+		return nil
+	}
+
+	event := UserEvent{Type: eventType, User: user, OccurredAt: time.Now()}
+	return s.outbox.Add(ctx, UserEventsTopic, strconv.Itoa(user.ID), string(eventType), event)
+}
+
+// publish sends a user event. Failures are logged rather than returned
+// because the change itself has already been committed.
+func (s *UserService) publish(ctx context.Context, eventType UserEventType, user *models.User) {
+	if s.events == nil {
+		return
+	}
+
+	event := UserEvent{Type: eventType, User: user, OccurredAt: time.Now()}
+	if err := s.events.PublishUserEvent(ctx, event); err != nil {
+		s.logger.Error().
+			Err(err).
+			Str("event", string(eventType)).
+			Int("user_id", user.ID).
+			Msg("Failed to publish user event")
+	}
+}
+
+// userError translates repository errors into application errors
+func userError(err error) error {
+	var appErr *errs.AppError
+	switch {
+	case database.IsUniqueViolation(err):
+		return errs.ErrConflict.WithDetails(map[string]string{"email": "email is already in use"})
+	case errors.Is(err, errs.ErrConflict):
+		return errs.ErrConflict.WithDetails(map[string]string{"version": "user was modified concurrently"})
+	case errors.Is(err, errs.ErrNotFound):
+		return errs.NewNotFound("User")
+	case errors.As(err, &appErr):
+		return appErr
+	default:
+		return err
+	}
+}
@@ -0,0 +1,138 @@
+package statuspage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// defaultIncidentKey is the Redis key of the current incident
+const defaultIncidentKey = "statuspage:incident"
+
+// Severity is the impact of an incident on the overall status
+type Severity string
+
+// Incident severities
+const (
+	SeverityMinor Severity = "minor"
+	SeverityMajor Severity = "major"
+)
+
+// Incident is an ongoing incident declared by an administrator
+type Incident struct {
+	Message   string    `json:"message"`
+	Severity  Severity  `json:"severity"`
+	StartedAt time.Time `json:"started_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// IncidentStore keeps the current incident
+type IncidentStore interface {
+	// Get returns the current incident, or nil when there is none
+	Get(ctx context.Context) (*Incident, error)
+	// Set declares or updates the current incident
+	Set(ctx context.Context, incident *Incident) error
+	// Clear resolves the current incident
+	Clear(ctx context.Context) error
+}
+
+// MemoryIncidents keeps the current incident in process memory, for
+// single-instance deployments and development
+type MemoryIncidents struct {
+	mu       sync.RWMutex
+	incident *Incident
+}
+
+// NewMemoryIncidents creates an in-memory incident store
+func NewMemoryIncidents() *MemoryIncidents {
+	return &MemoryIncidents{}
+}
+
+// Get implements IncidentStore
+func (m *MemoryIncidents) Get(_ context.Context) (*Incident, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if m.incident == nil {
+		return nil, nil
+	}
+	incident := *m.incident
+	return &incident, nil
+}
+
+// Set implements IncidentStore
+func (m *MemoryIncidents) Set(_ context.Context, incident *Incident) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stored := *incident
+	m.incident = &stored
+	return nil
+}
+
+// Clear implements IncidentStore
+func (m *MemoryIncidents) Clear(_ context.Context) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.incident = nil
+	return nil
+}
+
+// RedisIncidents keeps the current incident in Redis, shared by every
+// instance of the application
+type RedisIncidents struct {
+	client redis.Cmdable
+	key    string
+}
+
+// NewRedisIncidents creates a Redis incident store. key defaults to
+// "statuspage:incident" when empty.
+func NewRedisIncidents(client redis.Cmdable, key string) *RedisIncidents {
+	if key == "" {
+		key = defaultIncidentKey
+	}
+	return &RedisIncidents{client: client, key: key}
+}
+
+// Get implements IncidentStore
+func (r *RedisIncidents) Get(ctx context.Context) (*Incident, error) {
+	data, err := r.client.Get(ctx, r.key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get incident: %w", err)
+	}
+
+	var incident Incident
+	if err := json.Unmarshal(data, &incident); err != nil {
+		return nil, fmt.Errorf("failed to decode incident: %w", err)
+	}
+	return &incident, nil
+}
+
+// Set implements IncidentStore
+func (r *RedisIncidents) Set(ctx context.Context, incident *Incident) error {
+	data, err := json.Marshal(incident)
+	if err != nil {
+		return fmt.Errorf("failed to encode incident: %w", err)
+	}
+	if err := r.client.Set(ctx, r.key, data, 0).Err(); err != nil {
+		return fmt.Errorf("failed to set incident: %w", err)
+	}
+	return nil
+}
+
+// Clear implements IncidentStore
+func (r *RedisIncidents) Clear(ctx context.Context) error {
+	if err := r.client.Del(ctx, r.key).Err(); err != nil {
+		return fmt.Errorf("failed to clear incident: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,184 @@
+// Package statuspage summarizes the health of the application for its
+// users: the status of each component, from the last health check results,
+// their uptime over a recent window, from the health check history, and the
+// incident currently declared by administrators, if any.
+package statuspage
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/PrinceNarteh/go-boilerplate/internal/healthcheck"
+)
+
+// defaultUptimeWindow is the window of the uptime when none is configured
+const defaultUptimeWindow = 24 * time.Hour
+
+// Status is the status of a component or of the whole application
+type Status string
+
+// Statuses
+const (
+	StatusOperational Status = "operational"
+	StatusDegraded    Status = "degraded"
+	StatusOutage      Status = "outage"
+	// StatusUnknown is reported for components that have not been checked yet
+	StatusUnknown Status = "unknown"
+)
+
+// Component is the status of a dependency of the application
+type Component struct {
+	Name   string `json:"name"`
+	Status Status `json:"status"`
+	// UptimePercent is the share of healthy checks over the uptime window,
+	// nil when the component has no history yet or the uptime was skipped
+	UptimePercent *float64 `json:"uptime_percent"`
+}
+
+// Summary is the content of the status page
+type Summary struct {
+	Status       Status      `json:"status"`
+	Incident     *Incident   `json:"incident"`
+	Components   []Component `json:"components"`
+	UptimeWindow string      `json:"uptime_window"`
+	// UpdatedAt is the time of the last check, so the summary only changes
+	// when checks run or the incident changes
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Page builds the status page from the health checks and the incident store
+type Page struct {
+	health    *healthcheck.Service
+	incidents IncidentStore
+	window    time.Duration
+}
+
+// New creates a status page reporting uptime over window, 24 hours when zero
+func New(health *healthcheck.Service, incidents IncidentStore, window time.Duration) *Page {
+	if window <= 0 {
+		window = defaultUptimeWindow
+	}
+	return &Page{health: health, incidents: incidents, window: window}
+}
+
+// SummaryOptions configures the content of a summary
+type SummaryOptions struct {
+	// SkipUptime leaves out the uptime of the components, which reads the
+	// history of every check
+	SkipUptime bool
+}
+
+// Summary returns the current status of the application. Error messages
+// and details of the checks are left out, since the page is public.
+func (p *Page) Summary(ctx context.Context, opts SummaryOptions) (Summary, error) {
+	incident, err := p.incidents.Get(ctx)
+	if err != nil {
+		return Summary{}, err
+	}
+
+	report := p.health.Report()
+	summary := Summary{
+		Incident:     incident,
+		Components:   make([]Component, 0, len(report.Checks)),
+		UptimeWindow: p.window.String(),
+	}
+	if incident != nil && incident.UpdatedAt.After(summary.UpdatedAt) {
+		summary.UpdatedAt = incident.UpdatedAt
+	}
+
+	since := time.Now().Add(-p.window)
+	for name, result := range report.Checks {
+		component := Component{Name: name, Status: componentStatus(result)}
+		if !opts.SkipUptime {
+			samples, err := p.health.History(ctx, name, since)
+			if err != nil {
+				return Summary{}, fmt.Errorf("failed to get history of %s: %w", name, err)
+			}
+			if uptime := healthcheck.Uptime(samples); uptime >= 0 {
+				rounded := math.Round(uptime*100) / 100
+				component.UptimePercent = &rounded
+			}
+		}
+		summary.Components = append(summary.Components, component)
+
+		if result.CheckedAt.After(summary.UpdatedAt) {
+			summary.UpdatedAt = result.CheckedAt
+		}
+	}
+	sort.Slice(summary.Components, func(i, j int) bool {
+		return summary.Components[i].Name < summary.Components[j].Name
+	})
+
+	summary.Status = overallStatus(summary.Components, incident)
+	return summary, nil
+}
+
+// SetIncident declares an incident, or updates the current one, keeping its start
+func (p *Page) SetIncident(ctx context.Context, message string, severity Severity) (*Incident, error) {
+	current, err := p.incidents.Get(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	incident := &Incident{Message: message, Severity: severity, StartedAt: now, UpdatedAt: now}
+	if current != nil {
+		incident.StartedAt = current.StartedAt
+	}
+	if err := p.incidents.Set(ctx, incident); err != nil {
+		return nil, err
+	}
+	return incident, nil
+}
+
+// ClearIncident resolves the current incident
+func (p *Page) ClearIncident(ctx context.Context) error {
+	return p.incidents.Clear(ctx)
+}
+
+// componentStatus maps the result of a health check to the status of its
+// component, degraded while the check is flapping
+func componentStatus(result healthcheck.Result) Status {
+	if result.Flapping {
+		return StatusDegraded
+	}
+	switch result.Status {
+	case healthcheck.StatusHealthy:
+		return StatusOperational
+	case healthcheck.StatusUnhealthy:
+		return StatusOutage
+	default:
+		return StatusUnknown
+	}
+}
+
+// overallStatus is an outage when every checked component is down or a
+// major incident is declared, and degraded when some component is down or
+// degraded or a minor incident is declared
+func overallStatus(components []Component, incident *Incident) Status {
+	checked, down, degraded := 0, 0, 0
+	for _, c := range components {
+		switch c.Status {
+		case StatusOutage:
+			checked++
+			down++
+		case StatusDegraded:
+			checked++
+			degraded++
+		case StatusOperational:
+			checked++
+		}
+	}
+
+	switch {
+	case incident != nil && incident.Severity == SeverityMajor, checked > 0 && down == checked:
+		return StatusOutage
+	case incident != nil, down > 0, degraded > 0:
+		return StatusDegraded
+	default:
+		return StatusOperational
+	}
+}
@@ -0,0 +1,25 @@
+package tasks
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/PrinceNarteh/go-boilerplate/internal/matview"
+)
+
+// RefreshViewArgs are the arguments of the task returned by RefreshView
+type RefreshViewArgs struct {
+	View string `json:"view" validate:"required"`
+}
+
+// RefreshView returns a task refreshing one of the materialized views
+// registered with views outside of its schedule, e.g. after a bulk import
+func RefreshView(views *matview.Scheduler) Task {
+	return NewTask("refresh_materialized_view", "Refresh a materialized view now",
+		func(ctx context.Context, args *RefreshViewArgs) error {
+			if !views.Registered(args.View) {
+				return fmt.Errorf("materialized view %q is not registered", args.View)
+			}
+			return views.Refresh(ctx, args.View)
+		})
+}
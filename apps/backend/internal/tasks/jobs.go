@@ -0,0 +1,53 @@
+package tasks
+
+import (
+	"context"
+
+	"github.com/PrinceNarteh/go-boilerplate/internal/jobs"
+	"github.com/PrinceNarteh/go-boilerplate/internal/libs/id"
+)
+
+// ExecuteJob is the type of the jobs executing task runs
+const ExecuteJob = "task.execute"
+
+// ExecutePayload is the payload of an ExecuteJob
+type ExecutePayload struct {
+	RunID id.ID `json:"run_id"`
+}
+
+// JobDispatcher executes task runs on the workers of the job queue. A run
+// interrupted by a crash or a shutdown runs again once its job is claimed
+// again, instead of staying running. The workers must handle ExecuteJob
+// with Runner.JobHandler.
+type JobDispatcher struct {
+	queue *jobs.Queue
+}
+
+// NewJobDispatcher creates a dispatcher enqueuing task runs on queue
+func NewJobDispatcher(queue *jobs.Queue) *JobDispatcher {
+	return &JobDispatcher{queue: queue}
+}
+
+// Dispatch implements Dispatcher
+func (d *JobDispatcher) Dispatch(ctx context.Context, runID id.ID) error {
+	return d.queue.Enqueue(ctx, ExecuteJob, ExecutePayload{RunID: runID})
+}
+
+// JobHandler returns the handler of ExecuteJob, running the run of the
+// payload unless it finished. A failed run is recorded rather than retried,
+// but a run interrupted by shutdown is left running for its job to run it
+// again.
+func (r *Runner) JobHandler() jobs.Handler {
+	return jobs.Typed(func(ctx context.Context, p ExecutePayload) error {
+		run, ok, err := r.store.Resume(ctx, p.RunID)
+		if err != nil || !ok {
+			return err
+		}
+
+		runErr := r.execute(ctx, run)
+		if runErr != nil && ctx.Err() != nil {
+			return runErr
+		}
+		return r.finish(ctx, run, runErr)
+	})
+}
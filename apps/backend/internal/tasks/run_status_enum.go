@@ -0,0 +1,74 @@
+// Code generated by enumgen -type=RunStatus; DO NOT EDIT.
+
+package tasks
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"slices"
+)
+
+// runStatusValues are the values of RunStatus, in declaration order
+var runStatusValues = []RunStatus{StatusPending, StatusRunning, StatusSucceeded, StatusFailed}
+
+// RunStatusValues returns the values of RunStatus, in declaration order
+func RunStatusValues() []RunStatus {
+	return slices.Clone(runStatusValues)
+}
+
+// IsValid reports whether v is a declared value of RunStatus
+func (v RunStatus) IsValid() bool {
+	switch v {
+	case StatusPending, StatusRunning, StatusSucceeded, StatusFailed:
+		return true
+	}
+	return false
+}
+
+// ParseRunStatus returns the RunStatus of s, or an error when s is not a declared value
+func ParseRunStatus(s string) (RunStatus, error) {
+	v := RunStatus(s)
+	if !v.IsValid() {
+		return "", fmt.Errorf("invalid RunStatus %q", s)
+	}
+	return v, nil
+}
+
+// MarshalText implements encoding.TextMarshaler
+func (v RunStatus) MarshalText() ([]byte, error) {
+	return []byte(v), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler, rejecting undeclared values
+func (v *RunStatus) UnmarshalText(text []byte) error {
+	parsed, err := ParseRunStatus(string(text))
+	if err != nil {
+		return err
+	}
+	*v = parsed
+	return nil
+}
+
+// Value implements driver.Valuer, rejecting undeclared values
+func (v RunStatus) Value() (driver.Value, error) {
+	if !v.IsValid() {
+		return nil, fmt.Errorf("invalid RunStatus %q", string(v))
+	}
+	return string(v), nil
+}
+
+// Scan implements sql.Scanner, rejecting undeclared values. NULL scans into
+// the zero value, which IsValid reports as undeclared.
+func (v *RunStatus) Scan(src any) error {
+	switch src := src.(type) {
+	case nil:
+		*v = ""
+		return nil
+	case string:
+		return v.UnmarshalText([]byte(src))
+	case []byte:
+		return v.UnmarshalText(src)
+	default:
+		return fmt.Errorf("cannot scan %T into RunStatus", src)
+	}
+}
@@ -0,0 +1,156 @@
+package tasks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	pgx "github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/PrinceNarteh/go-boilerplate/internal/database"
+	"github.com/PrinceNarteh/go-boilerplate/internal/libs/id"
+)
+
+// runColumns are the columns read by scanRun
+const runColumns = `id, task, args, status, error, requested_by, created_at, started_at, finished_at`
+
+// PostgresStore persists task runs in the task_runs table
+type PostgresStore struct {
+	db *pgxpool.Pool
+}
+
+// NewPostgresStore creates a new PostgreSQL task run store
+func NewPostgresStore(db *pgxpool.Pool) *PostgresStore {
+	return &PostgresStore{db: db}
+}
+
+// Create inserts a new task run
+func (s *PostgresStore) Create(ctx context.Context, run *Run) error {
+	query := `
+		INSERT INTO task_runs (id, task, args, status, requested_by, created_at)
+		VALUES ($1, $2, $3, $4, $5, NOW())
+		RETURNING created_at`
+
+	var args []byte
+	if len(run.Args) > 0 {
+		args = run.Args
+	}
+
+	err := s.db.QueryRow(ctx, query, run.ID, run.Task, args, run.Status, run.RequestedBy).Scan(&run.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create task run: %w", database.TranslateError(err))
+	}
+
+	return nil
+}
+
+// Claim marks a pending task run as running
+func (s *PostgresStore) Claim(ctx context.Context, runID id.ID) (*Run, bool, error) {
+	query := `
+		UPDATE task_runs
+		SET status = 'running', started_at = NOW()
+		WHERE id = $1 AND status = 'pending'
+		RETURNING ` + runColumns
+
+	run, err := scanRun(s.db.QueryRow(ctx, query, runID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to claim task run: %w", database.TranslateError(err))
+	}
+
+	return run, true, nil
+}
+
+// Resume marks a pending or running task run as running
+func (s *PostgresStore) Resume(ctx context.Context, runID id.ID) (*Run, bool, error) {
+	query := `
+		UPDATE task_runs
+		SET status = 'running', started_at = NOW()
+		WHERE id = $1 AND status IN ('pending', 'running')
+		RETURNING ` + runColumns
+
+	run, err := scanRun(s.db.QueryRow(ctx, query, runID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to resume task run: %w", database.TranslateError(err))
+	}
+
+	return run, true, nil
+}
+
+// Finish records the outcome of a task run
+func (s *PostgresStore) Finish(ctx context.Context, runID id.ID, status RunStatus, message string) error {
+	query := `UPDATE task_runs SET status = $2, error = $3, finished_at = NOW() WHERE id = $1`
+
+	if _, err := s.db.Exec(ctx, query, runID, status, message); err != nil {
+		return fmt.Errorf("failed to finish task run: %w", database.TranslateError(err))
+	}
+
+	return nil
+}
+
+// Get returns a task run by ID.
+// It returns an error wrapping errs.ErrNotFound when there is none.
+func (s *PostgresStore) Get(ctx context.Context, runID id.ID) (*Run, error) {
+	query := `SELECT ` + runColumns + ` FROM task_runs WHERE id = $1`
+
+	run, err := scanRun(s.db.QueryRow(ctx, query, runID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get task run: %w", database.TranslateError(err))
+	}
+
+	return run, nil
+}
+
+// List returns the most recent task runs, newest first
+func (s *PostgresStore) List(ctx context.Context, limit int) ([]*Run, error) {
+	query := `SELECT ` + runColumns + ` FROM task_runs ORDER BY created_at DESC LIMIT $1`
+
+	rows, err := s.db.Query(ctx, query, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list task runs: %w", database.TranslateError(err))
+	}
+	defer rows.Close()
+
+	var runs []*Run
+	for rows.Next() {
+		run, err := scanRun(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan task run: %w", err)
+		}
+		runs = append(runs, run)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+
+	return runs, nil
+}
+
+// scanRun scans a row of runColumns
+func scanRun(row pgx.Row) (*Run, error) {
+	var run Run
+	var args []byte
+	err := row.Scan(
+		&run.ID,
+		&run.Task,
+		&args,
+		&run.Status,
+		&run.Error,
+		&run.RequestedBy,
+		&run.CreatedAt,
+		&run.StartedAt,
+		&run.FinishedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	run.Args = args
+	return &run, nil
+}
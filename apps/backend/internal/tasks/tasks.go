@@ -0,0 +1,307 @@
+// Package tasks runs registered operational tasks on demand, such as
+// refreshing a materialized view or invalidating a cache namespace, so that
+// one-off operations do not require shell access to production.
+//
+// Every run is persisted with its arguments, the user who requested it and
+// its outcome. Runs are executed asynchronously by a Dispatcher:
+// JobDispatcher runs tasks on the workers of the job queue, which run again
+// the runs interrupted by a crash, and GoroutineDispatcher runs them
+// in-process.
+package tasks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/PrinceNarteh/go-boilerplate/internal/errs"
+	"github.com/PrinceNarteh/go-boilerplate/internal/i18n"
+	"github.com/PrinceNarteh/go-boilerplate/internal/libs"
+	"github.com/PrinceNarteh/go-boilerplate/internal/libs/async"
+	"github.com/PrinceNarteh/go-boilerplate/internal/libs/id"
+)
+
+// ErrUnknownTask is returned when starting a task that is not registered
+var ErrUnknownTask = errors.New("tasks: unknown task")
+
+//go:generate go run github.com/PrinceNarteh/go-boilerplate/cmd/enumgen -type=RunStatus
+
+// RunStatus represents the lifecycle status of a task run
+type RunStatus string
+
+// Run statuses
+const (
+	StatusPending   RunStatus = "pending"
+	StatusRunning   RunStatus = "running"
+	StatusSucceeded RunStatus = "succeeded"
+	StatusFailed    RunStatus = "failed"
+)
+
+// StatusLabels translates run statuses
+var StatusLabels = i18n.RegisterEnum("task_run_status", RunStatusValues()...)
+
+// Task is an operational task that can be run on demand
+type Task struct {
+	Name        string
+	Description string
+	// Args returns a pointer to a new arguments value, into which the JSON
+	// arguments of a run are decoded and validated with libs.ValidateStruct.
+	// It is nil for tasks without arguments.
+	Args func() any
+	// Run performs the task with the decoded arguments
+	Run func(ctx context.Context, args any) error
+}
+
+// NewTask creates a task whose arguments are decoded into a value of type A
+func NewTask[A any](name, description string, run func(ctx context.Context, args *A) error) Task {
+	return Task{
+		Name:        name,
+		Description: description,
+		Args: func() any {
+			return new(A)
+		},
+		Run: func(ctx context.Context, args any) error {
+			return run(ctx, args.(*A))
+		},
+	}
+}
+
+// Run is a persisted run of a task
+type Run struct {
+	ID          id.ID           `json:"id"`
+	Task        string          `json:"task"`
+	Args        json.RawMessage `json:"args,omitempty"`
+	Status      RunStatus       `json:"status"`
+	StatusLabel string          `json:"status_label,omitempty"`
+	Error       string          `json:"error,omitempty"`
+	RequestedBy int             `json:"requested_by"`
+	CreatedAt   time.Time       `json:"created_at"`
+	StartedAt   *time.Time      `json:"started_at,omitempty"`
+	FinishedAt  *time.Time      `json:"finished_at,omitempty"`
+}
+
+// Localize implements i18n.Localizable
+func (r *Run) Localize(ctx context.Context) {
+	r.StatusLabel = StatusLabels.Label(ctx, r.Status)
+}
+
+// Store persists task runs
+type Store interface {
+	Create(ctx context.Context, run *Run) error
+	// Claim marks a pending run as running, reporting false when it is not pending
+	Claim(ctx context.Context, runID id.ID) (*Run, bool, error)
+	// Resume marks a pending or running run as running, reporting false
+	// when it finished. It is used by dispatchers guaranteeing a single
+	// executor per run, which run again the runs interrupted by a crash.
+	Resume(ctx context.Context, runID id.ID) (*Run, bool, error)
+	Finish(ctx context.Context, runID id.ID, status RunStatus, message string) error
+	Get(ctx context.Context, runID id.ID) (*Run, error)
+	List(ctx context.Context, limit int) ([]*Run, error)
+}
+
+// Dispatcher schedules the execution of a task run.
+// JobDispatcher runs tasks on the workers of the job queue;
+// GoroutineDispatcher runs them in-process.
+type Dispatcher interface {
+	Dispatch(ctx context.Context, runID id.ID) error
+}
+
+// Runner registers tasks and drives their runs
+type Runner struct {
+	store      Store
+	dispatcher Dispatcher
+	logger     *zerolog.Logger
+
+	mu    sync.RWMutex
+	tasks map[string]Task
+}
+
+// NewRunner creates a new task runner.
+// If dispatcher is nil, runs are executed in a background goroutine.
+func NewRunner(store Store, dispatcher Dispatcher, logger *zerolog.Logger) *Runner {
+	r := &Runner{
+		store:      store,
+		dispatcher: dispatcher,
+		logger:     logger,
+		tasks:      make(map[string]Task),
+	}
+	if r.dispatcher == nil {
+		r.dispatcher = &GoroutineDispatcher{runner: r}
+	}
+	return r
+}
+
+// Register adds a task to the runner
+func (r *Runner) Register(task Task) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.tasks[task.Name] = task
+}
+
+// Tasks returns the registered tasks sorted by name
+func (r *Runner) Tasks() []Task {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	tasks := make([]Task, 0, len(r.tasks))
+	for _, task := range r.tasks {
+		tasks = append(tasks, task)
+	}
+	slices.SortFunc(tasks, func(a, b Task) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	return tasks
+}
+
+// Start validates the arguments of a run of the named task, persists it and
+// dispatches it for execution. Invalid arguments are reported as a
+// validation *errs.AppError.
+func (r *Runner) Start(ctx context.Context, name string, args json.RawMessage, requestedBy int) (*Run, error) {
+	task, ok := r.task(name)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownTask, name)
+	}
+	if _, err := decodeArgs(task, args); err != nil {
+		return nil, err
+	}
+
+	run := &Run{
+		ID:          id.New(),
+		Task:        name,
+		Args:        args,
+		Status:      StatusPending,
+		RequestedBy: requestedBy,
+	}
+	if err := r.store.Create(ctx, run); err != nil {
+		return nil, err
+	}
+
+	if err := r.dispatcher.Dispatch(ctx, run.ID); err != nil {
+		return nil, fmt.Errorf("failed to dispatch task run: %w", err)
+	}
+
+	r.logger.Info().
+		Str("task", name).
+		Stringer("run_id", run.ID).
+		Int("requested_by", requestedBy).
+		Msg("Task run started")
+	return run, nil
+}
+
+// Execute runs a pending task run and records its outcome.
+// It is called by dispatchers and does nothing when the run is not pending,
+// e.g. when a job is delivered twice.
+func (r *Runner) Execute(ctx context.Context, runID id.ID) error {
+	run, ok, err := r.store.Claim(ctx, runID)
+	if err != nil || !ok {
+		return err
+	}
+
+	runErr := r.execute(ctx, run)
+	if err := r.finish(ctx, run, runErr); err != nil {
+		return err
+	}
+	return runErr
+}
+
+// finish records the outcome of a run
+func (r *Runner) finish(ctx context.Context, run *Run, runErr error) error {
+	status, message := StatusSucceeded, ""
+	if runErr != nil {
+		status, message = StatusFailed, runErr.Error()
+	}
+	if err := r.store.Finish(ctx, run.ID, status, message); err != nil {
+		return err
+	}
+
+	r.logger.Info().
+		Str("task", run.Task).
+		Stringer("run_id", run.ID).
+		Str("status", string(status)).
+		Str("error", message).
+		Msg("Task run finished")
+	return nil
+}
+
+// Get returns a task run by ID
+func (r *Runner) Get(ctx context.Context, runID id.ID) (*Run, error) {
+	return r.store.Get(ctx, runID)
+}
+
+// List returns the most recent task runs
+func (r *Runner) List(ctx context.Context, limit int) ([]*Run, error) {
+	return r.store.List(ctx, limit)
+}
+
+// execute decodes the arguments of a run and runs its task, recovering panics
+func (r *Runner) execute(ctx context.Context, run *Run) (err error) {
+	task, ok := r.task(run.Task)
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrUnknownTask, run.Task)
+	}
+
+	args, err := decodeArgs(task, run.Args)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("task panicked: %v", p)
+		}
+	}()
+	return task.Run(ctx, args)
+}
+
+// task returns the registered task with the given name
+func (r *Runner) task(name string) (Task, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	task, ok := r.tasks[name]
+	return task, ok
+}
+
+// decodeArgs decodes and validates the JSON arguments of a task
+func decodeArgs(task Task, raw json.RawMessage) (any, error) {
+	if task.Args == nil {
+		return nil, nil
+	}
+
+	args := task.Args()
+	if len(bytes.TrimSpace(raw)) > 0 {
+		decoder := json.NewDecoder(bytes.NewReader(raw))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(args); err != nil {
+			return nil, errs.NewValidation(fmt.Sprintf("Invalid task arguments: %v", err))
+		}
+	}
+
+	if fields := libs.ValidateStruct(args); fields != nil {
+		return nil, errs.ErrValidation.WithDetails(fields)
+	}
+	return args, nil
+}
+
+// GoroutineDispatcher executes task runs in background goroutines of the current process
+type GoroutineDispatcher struct {
+	runner *Runner
+}
+
+// Dispatch implements Dispatcher
+func (d *GoroutineDispatcher) Dispatch(ctx context.Context, runID id.ID) error {
+	async.Go(context.WithoutCancel(ctx), "tasks", func(ctx context.Context) error {
+		if err := d.runner.Execute(ctx, runID); err != nil {
+			d.runner.logger.Error().Err(err).Stringer("run_id", runID).Msg("task run failed")
+		}
+		return nil
+	})
+	return nil
+}
@@ -0,0 +1,275 @@
+package telemetry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/PrinceNarteh/go-boilerplate/internal/config"
+	"github.com/PrinceNarteh/go-boilerplate/internal/errs"
+	"github.com/PrinceNarteh/go-boilerplate/internal/libs/async"
+	"github.com/PrinceNarteh/go-boilerplate/internal/tracing"
+)
+
+// defaultErrorReporter is the reporter used by ReportError
+var defaultErrorReporter atomic.Pointer[ErrorReporter]
+
+// SetErrorReporter sets the reporter used by ReportError
+func SetErrorReporter(r *ErrorReporter) {
+	defaultErrorReporter.Store(r)
+}
+
+// ReportError reports err, which happened at location, such as a job type
+// or a topic, through the reporter set with SetErrorReporter. The New Relic
+// transaction of ctx is annotated with the code of the error. Without a
+// reporter, the error is noticed on the transaction of ctx directly.
+func ReportError(ctx context.Context, location string, err error) {
+	if err == nil {
+		return
+	}
+
+	txn, _ := tracing.FromContext(ctx)
+	r := defaultErrorReporter.Load()
+	if r == nil {
+		txn.NoticeError(err)
+		return
+	}
+
+	code := ErrorCode(err)
+	txn.AddAttribute("error.code", code)
+	r.Report(code, location, err)
+}
+
+// ErrorCode returns the code grouping err with the errors of the same kind:
+// the code of an errs.AppError, or the type of the innermost error, as
+// messages often hold identifiers
+func ErrorCode(err error) string {
+	var appErr *errs.AppError
+	if errors.As(err, &appErr) {
+		return appErr.Code
+	}
+	for {
+		inner := errors.Unwrap(err)
+		if inner == nil {
+			return fmt.Sprintf("%T", err)
+		}
+		err = inner
+	}
+}
+
+// ErrorReport is a batch of repeated errors sharing a code and a location
+type ErrorReport struct {
+	Code     string
+	Location string
+	// Message is the message of the first error of the batch
+	Message string
+	// Count is the number of errors in the batch
+	Count     int
+	FirstSeen time.Time
+	LastSeen  time.Time
+}
+
+// ErrorSink sends batches of errors to an error tracking backend
+type ErrorSink interface {
+	SendErrors(reports []ErrorReport) error
+}
+
+// NewRelicErrorSink sends errors as New Relic errors, noticed on a
+// background transaction named after their location
+type NewRelicErrorSink struct {
+	tracer tracing.Tracer
+}
+
+// NewNewRelicErrorSink creates a new New Relic error sink
+func NewNewRelicErrorSink(tracer tracing.Tracer) *NewRelicErrorSink {
+	return &NewRelicErrorSink{tracer: tracer}
+}
+
+// SendErrors implements ErrorSink
+func (s *NewRelicErrorSink) SendErrors(reports []ErrorReport) error {
+	for _, report := range reports {
+		txn := tracing.Start(s.tracer, "errors/"+report.Location)
+		txn.NoticeError(tracing.Error{
+			Message: report.Message,
+			Class:   report.Code,
+			Attributes: map[string]any{
+				"error.location":   report.Location,
+				"error.count":      report.Count,
+				"error.first_seen": report.FirstSeen.UTC().Format(time.RFC3339),
+				"error.last_seen":  report.LastSeen.UTC().Format(time.RFC3339),
+			},
+		})
+		txn.End()
+	}
+	return nil
+}
+
+// LogErrorSink writes errors as structured log entries, for when New Relic
+// is not configured
+type LogErrorSink struct {
+	logger *zerolog.Logger
+}
+
+// NewLogErrorSink creates a new log error sink
+func NewLogErrorSink(logger *zerolog.Logger) *LogErrorSink {
+	return &LogErrorSink{logger: logger}
+}
+
+// SendErrors implements ErrorSink
+func (s *LogErrorSink) SendErrors(reports []ErrorReport) error {
+	for _, report := range reports {
+		s.logger.Error().
+			Str("code", report.Code).
+			Str("location", report.Location).
+			Str("error", report.Message).
+			Int("count", report.Count).
+			Time("first_seen", report.FirstSeen).
+			Time("last_seen", report.LastSeen).
+			Msg("Errors reported")
+	}
+	return nil
+}
+
+// errorKey identifies repeated errors
+type errorKey struct {
+	code     string
+	location string
+}
+
+// ErrorReporter merges repeated errors and sends them to a sink in batches,
+// once per ErrorsConfig.Interval, so an error storm sends one report per
+// kind of error with its count rather than one per error. Beyond
+// ErrorsConfig.MaxPerInterval distinct errors per interval, new kinds of
+// errors are only counted as suppressed.
+type ErrorReporter struct {
+	sink   ErrorSink
+	cfg    config.ErrorsConfig
+	logger *zerolog.Logger
+
+	mu         sync.Mutex
+	pending    map[errorKey]*ErrorReport
+	order      []errorKey
+	suppressed int
+	stop       func()
+}
+
+// NewErrorReporter creates a new error reporter sending errors to sink.
+// Unset limits fall back to those of config.DefaultObservabilityConfig.
+func NewErrorReporter(sink ErrorSink, cfg config.ErrorsConfig, logger *zerolog.Logger) *ErrorReporter {
+	defaults := config.DefaultObservabilityConfig().Errors
+	if cfg.Interval <= 0 {
+		cfg.Interval = defaults.Interval
+	}
+	if cfg.MaxPerInterval <= 0 {
+		cfg.MaxPerInterval = defaults.MaxPerInterval
+	}
+
+	return &ErrorReporter{
+		sink:    sink,
+		cfg:     cfg,
+		logger:  logger,
+		pending: make(map[errorKey]*ErrorReport),
+	}
+}
+
+// Report adds an error of code at location to the current batch
+func (r *ErrorReporter) Report(code, location string, err error) {
+	now := time.Now()
+	key := errorKey{code: code, location: location}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if report, ok := r.pending[key]; ok {
+		report.Count++
+		report.LastSeen = now
+		return
+	}
+	if len(r.order) >= r.cfg.MaxPerInterval {
+		r.suppressed++
+		return
+	}
+	r.pending[key] = &ErrorReport{
+		Code:      code,
+		Location:  location,
+		Message:   err.Error(),
+		Count:     1,
+		FirstSeen: now,
+		LastSeen:  now,
+	}
+	r.order = append(r.order, key)
+}
+
+// Start sends batches in the background until Stop is called
+func (r *ErrorReporter) Start() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.stop != nil {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := async.Go(ctx, "telemetry.errors", func(ctx context.Context) error {
+		ticker := time.NewTicker(r.cfg.Interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				r.flush()
+				return nil
+			case <-ticker.C:
+				r.flush()
+			}
+		}
+	})
+
+	r.stop = func() {
+		cancel()
+		<-done
+	}
+}
+
+// Stop sends the current batch and stops the reporter
+func (r *ErrorReporter) Stop() {
+	r.mu.Lock()
+	stop := r.stop
+	r.stop = nil
+	r.mu.Unlock()
+
+	if stop != nil {
+		stop()
+	}
+}
+
+// flush sends the current batch and starts the next one
+func (r *ErrorReporter) flush() {
+	r.mu.Lock()
+	reports := make([]ErrorReport, 0, len(r.order))
+	for _, key := range r.order {
+		reports = append(reports, *r.pending[key])
+	}
+	suppressed := r.suppressed
+	clear(r.pending)
+	r.order = r.order[:0]
+	r.suppressed = 0
+	r.mu.Unlock()
+
+	if suppressed > 0 {
+		r.logger.Warn().
+			Int("suppressed", suppressed).
+			Int("limit", r.cfg.MaxPerInterval).
+			Msg("Errors were not reported because too many distinct errors occurred")
+	}
+	if len(reports) == 0 {
+		return
+	}
+	if err := r.sink.SendErrors(reports); err != nil {
+		r.logger.Warn().Err(err).Int("reports", len(reports)).Msg("Failed to send error reports")
+	}
+}
@@ -0,0 +1,200 @@
+package telemetry
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/PrinceNarteh/go-boilerplate/internal/config"
+	"github.com/PrinceNarteh/go-boilerplate/internal/libs/async"
+	"github.com/PrinceNarteh/go-boilerplate/internal/tracing"
+)
+
+// defaultRecorder is the recorder used by RecordEvent
+var defaultRecorder atomic.Pointer[EventRecorder]
+
+// SetEventRecorder sets the recorder used by RecordEvent.
+// Events are discarded until it is called.
+func SetEventRecorder(r *EventRecorder) {
+	defaultRecorder.Store(r)
+}
+
+// RecordEvent records a business event, such as "UserSignedUp", with its
+// attributes through the recorder set with SetEventRecorder. Attribute
+// values should be strings, numbers or booleans, and attrs must not be
+// modified afterwards. It never blocks: events are dropped when the buffer
+// is full.
+func RecordEvent(eventType string, attrs map[string]any) {
+	if r := defaultRecorder.Load(); r != nil {
+		r.Record(eventType, attrs)
+	}
+}
+
+// EventSink sends business events to an analytics backend
+type EventSink interface {
+	SendEvent(eventType string, attrs map[string]any) error
+}
+
+// NewRelicEventSink sends events as New Relic custom events
+type NewRelicEventSink struct {
+	tracer tracing.Tracer
+}
+
+// NewNewRelicEventSink creates a new New Relic custom event sink
+func NewNewRelicEventSink(tracer tracing.Tracer) *NewRelicEventSink {
+	return &NewRelicEventSink{tracer: tracer}
+}
+
+// SendEvent implements EventSink. Invalid events are reported by the agent's own logger.
+func (s *NewRelicEventSink) SendEvent(eventType string, attrs map[string]any) error {
+	s.tracer.RecordCustomEvent(eventType, attrs)
+	return nil
+}
+
+// LogEventSink writes events as structured log entries, for when New Relic
+// is not configured and events are collected from the logs instead
+type LogEventSink struct {
+	logger *zerolog.Logger
+}
+
+// NewLogEventSink creates a new log event sink
+func NewLogEventSink(logger *zerolog.Logger) *LogEventSink {
+	return &LogEventSink{logger: logger}
+}
+
+// SendEvent implements EventSink
+func (s *LogEventSink) SendEvent(eventType string, attrs map[string]any) error {
+	s.logger.Info().
+		Str("event_type", eventType).
+		Interface("attributes", attrs).
+		Msg("Application event")
+	return nil
+}
+
+// event is a buffered business event
+type event struct {
+	eventType string
+	attrs     map[string]any
+}
+
+// EventRecorder buffers business events and sends them to a sink in the
+// background, at most EventsConfig.Rate events per second, so that a burst
+// of events neither slows down requests nor exceeds the backend's limits
+type EventRecorder struct {
+	sink    EventSink
+	cfg     config.EventsConfig
+	logger  *zerolog.Logger
+	events  chan event
+	dropped atomic.Uint64
+
+	mu   sync.Mutex
+	stop func()
+}
+
+// NewEventRecorder creates a new event recorder sending events to sink.
+// Unset limits fall back to those of config.DefaultObservabilityConfig.
+func NewEventRecorder(sink EventSink, cfg config.EventsConfig, logger *zerolog.Logger) *EventRecorder {
+	defaults := config.DefaultObservabilityConfig().Events
+	if cfg.Buffer <= 0 {
+		cfg.Buffer = defaults.Buffer
+	}
+	if cfg.Rate <= 0 {
+		cfg.Rate = defaults.Rate
+	}
+
+	return &EventRecorder{
+		sink:   sink,
+		cfg:    cfg,
+		logger: logger,
+		events: make(chan event, cfg.Buffer),
+	}
+}
+
+// Record buffers an event, dropping it when the buffer is full
+func (r *EventRecorder) Record(eventType string, attrs map[string]any) {
+	select {
+	case r.events <- event{eventType: eventType, attrs: attrs}:
+	default:
+		r.dropped.Add(1)
+	}
+}
+
+// Dropped returns the number of events dropped because the buffer was full
+func (r *EventRecorder) Dropped() uint64 {
+	return r.dropped.Load()
+}
+
+// Start sends buffered events in the background until Stop is called
+func (r *EventRecorder) Start() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.stop != nil {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := async.Go(ctx, "telemetry.events", func(ctx context.Context) error {
+		ticker := time.NewTicker(time.Duration(float64(time.Second) / r.cfg.Rate))
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				r.flush()
+				return nil
+			case e := <-r.events:
+				r.send(e)
+			}
+
+			// Wait for the next slot to stay within the rate
+			select {
+			case <-ctx.Done():
+				r.flush()
+				return nil
+			case <-ticker.C:
+			}
+		}
+	})
+
+	r.stop = func() {
+		cancel()
+		<-done
+	}
+}
+
+// Stop sends the events still buffered and stops the recorder
+func (r *EventRecorder) Stop() {
+	r.mu.Lock()
+	stop := r.stop
+	r.stop = nil
+	r.mu.Unlock()
+
+	if stop != nil {
+		stop()
+	}
+	if dropped := r.Dropped(); dropped > 0 {
+		r.logger.Warn().Uint64("dropped", dropped).Msg("Application events were dropped because the buffer was full")
+	}
+}
+
+// flush sends every buffered event, ignoring the rate on shutdown
+func (r *EventRecorder) flush() {
+	for {
+		select {
+		case e := <-r.events:
+			r.send(e)
+		default:
+			return
+		}
+	}
+}
+
+// send sends a single event, logging failures
+func (r *EventRecorder) send(e event) {
+	if err := r.sink.SendEvent(e.eventType, e.attrs); err != nil {
+		r.logger.Warn().Err(err).Str("event_type", e.eventType).Msg("Failed to send application event")
+	}
+}
@@ -0,0 +1,434 @@
+// Package telemetry provides OpenTelemetry metrics and business events for
+// the application.
+//
+// Metrics are exported over OTLP/HTTP by a MeterProvider built from
+// configuration, and recorded through the instrument groups of Metrics:
+// HTTP server requests, database queries, cache lookups, background jobs and
+// materialized view refreshes.
+// Instrument names follow the OpenTelemetry semantic conventions where one
+// exists, so dashboards built for other OTel services work unchanged.
+//
+// Business events, such as a user signing up, are recorded with RecordEvent
+// and sent in the background to New Relic custom events or to the logs.
+package telemetry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/noop"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/resource"
+	semconv "go.opentelemetry.io/otel/semconv/v1.34.0"
+
+	"github.com/PrinceNarteh/go-boilerplate/internal/config"
+	"github.com/PrinceNarteh/go-boilerplate/internal/version"
+)
+
+// meterName is the instrumentation scope of the application's instruments
+const meterName = "github.com/PrinceNarteh/go-boilerplate"
+
+// NewMeterProvider creates a meter provider that periodically pushes metrics
+// over OTLP/HTTP. When cfg.DurationBuckets is set, a view applies them to
+// every histogram measured in seconds. The provider must be shut down to
+// flush the last metrics.
+func NewMeterProvider(ctx context.Context, cfg *config.ObservabilityConfig) (*sdkmetric.MeterProvider, error) {
+	opts := []otlpmetrichttp.Option{otlpmetrichttp.WithEndpoint(cfg.Metrics.Endpoint)}
+	if cfg.Metrics.Insecure {
+		opts = append(opts, otlpmetrichttp.WithInsecure())
+	}
+	if len(cfg.Metrics.Headers) > 0 {
+		opts = append(opts, otlpmetrichttp.WithHeaders(cfg.Metrics.Headers))
+	}
+
+	exporter, err := otlpmetrichttp.New(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OTLP metric exporter: %w", err)
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(
+		semconv.SchemaURL,
+		semconv.ServiceName(cfg.ServiceName),
+		semconv.ServiceVersion(version.Version),
+		semconv.DeploymentEnvironmentName(cfg.Environment),
+	))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create metric resource: %w", err)
+	}
+
+	providerOpts := []sdkmetric.Option{
+		sdkmetric.WithResource(res),
+		sdkmetric.WithReader(sdkmetric.NewPeriodicReader(exporter, sdkmetric.WithInterval(cfg.Metrics.Interval))),
+	}
+	if len(cfg.Metrics.DurationBuckets) > 0 {
+		providerOpts = append(providerOpts, sdkmetric.WithView(durationBucketsView(cfg.Metrics.DurationBuckets)))
+	}
+
+	return sdkmetric.NewMeterProvider(providerOpts...), nil
+}
+
+// durationBucketsView applies bucket boundaries to histograms measured in seconds
+func durationBucketsView(buckets []float64) sdkmetric.View {
+	return func(inst sdkmetric.Instrument) (sdkmetric.Stream, bool) {
+		if inst.Kind != sdkmetric.InstrumentKindHistogram || inst.Unit != "s" {
+			return sdkmetric.Stream{}, false
+		}
+		return sdkmetric.Stream{
+			Name:        inst.Name,
+			Description: inst.Description,
+			Unit:        inst.Unit,
+			Aggregation: sdkmetric.AggregationExplicitBucketHistogram{Boundaries: buckets},
+		}, true
+	}
+}
+
+// Metrics groups the application's instruments
+type Metrics struct {
+	HTTP  *HTTPMetrics
+	DB    *DBMetrics
+	Cache *CacheMetrics
+	Jobs  *JobMetrics
+	Views *ViewMetrics
+}
+
+// NewMetrics creates every instrument on meters from provider
+func NewMetrics(provider metric.MeterProvider) (*Metrics, error) {
+	meter := provider.Meter(meterName, metric.WithInstrumentationVersion(version.Version))
+
+	httpMetrics, err := newHTTPMetrics(meter)
+	if err != nil {
+		return nil, err
+	}
+	dbMetrics, err := newDBMetrics(meter)
+	if err != nil {
+		return nil, err
+	}
+	cacheMetrics, err := newCacheMetrics(meter)
+	if err != nil {
+		return nil, err
+	}
+	jobMetrics, err := newJobMetrics(meter)
+	if err != nil {
+		return nil, err
+	}
+	viewMetrics, err := newViewMetrics(meter)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Metrics{
+		HTTP:  httpMetrics,
+		DB:    dbMetrics,
+		Cache: cacheMetrics,
+		Jobs:  jobMetrics,
+		Views: viewMetrics,
+	}, nil
+}
+
+// NoopMetrics returns instruments that record nothing, for when metrics are disabled
+func NoopMetrics() *Metrics {
+	m, _ := NewMetrics(noop.NewMeterProvider())
+	return m
+}
+
+// HTTPMetrics records HTTP server requests and the requests rejected for
+// malformed or invalid input
+type HTTPMetrics struct {
+	duration           metric.Float64Histogram
+	active             metric.Int64UpDownCounter
+	bindingFailures    metric.Int64Counter
+	validationFailures metric.Int64Counter
+	canceled           metric.Int64Counter
+	deprecated         metric.Int64Counter
+}
+
+// newHTTPMetrics creates the HTTP server instruments
+func newHTTPMetrics(meter metric.Meter) (*HTTPMetrics, error) {
+	duration, err := meter.Float64Histogram("http.server.request.duration",
+		metric.WithUnit("s"),
+		metric.WithDescription("Duration of HTTP server requests."),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create http.server.request.duration: %w", err)
+	}
+
+	active, err := meter.Int64UpDownCounter("http.server.active_requests",
+		metric.WithUnit("{request}"),
+		metric.WithDescription("Number of active HTTP server requests."),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create http.server.active_requests: %w", err)
+	}
+
+	bindingFailures, err := meter.Int64Counter("http.server.request.binding_failures",
+		metric.WithUnit("{request}"),
+		metric.WithDescription("Number of requests whose input could not be decoded, by route, source and reason."),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create http.server.request.binding_failures: %w", err)
+	}
+
+	validationFailures, err := meter.Int64Counter("http.server.request.validation_failures",
+		metric.WithUnit("{failure}"),
+		metric.WithDescription("Number of request fields failing validation, by route, field and rule."),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create http.server.request.validation_failures: %w", err)
+	}
+
+	canceled, err := meter.Int64Counter("http.server.request.canceled",
+		metric.WithUnit("{request}"),
+		metric.WithDescription("Number of requests canceled by their client before the response, by route."),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create http.server.request.canceled: %w", err)
+	}
+
+	deprecated, err := meter.Int64Counter("http.server.request.deprecated",
+		metric.WithUnit("{request}"),
+		metric.WithDescription("Number of requests to deprecated route aliases, by alias and successor route."),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create http.server.request.deprecated: %w", err)
+	}
+
+	return &HTTPMetrics{
+		duration:           duration,
+		active:             active,
+		bindingFailures:    bindingFailures,
+		validationFailures: validationFailures,
+		canceled:           canceled,
+		deprecated:         deprecated,
+	}, nil
+}
+
+// Start records the start of a request and returns a function recording its end.
+// route must be the route pattern rather than the raw path to keep cardinality low.
+func (m *HTTPMetrics) Start(ctx context.Context, method string) func(route string, status int) {
+	start := time.Now()
+	methodAttr := semconv.HTTPRequestMethodKey.String(method)
+	m.active.Add(ctx, 1, metric.WithAttributes(methodAttr))
+
+	return func(route string, status int) {
+		m.active.Add(ctx, -1, metric.WithAttributes(methodAttr))
+		m.duration.Record(ctx, time.Since(start).Seconds(), metric.WithAttributes(
+			methodAttr,
+			semconv.HTTPRoute(route),
+			semconv.HTTPResponseStatusCode(status),
+		))
+	}
+}
+
+// RecordCanceled records a request canceled by its client
+func (m *HTTPMetrics) RecordCanceled(ctx context.Context, method, route string) {
+	m.canceled.Add(ctx, 1, metric.WithAttributes(
+		semconv.HTTPRequestMethodKey.String(method),
+		semconv.HTTPRoute(route),
+	))
+}
+
+// RecordDeprecatedRoute records a request to the deprecated route alias
+// route, rewritten to the route successor
+func (m *HTTPMetrics) RecordDeprecatedRoute(ctx context.Context, route, successor string) {
+	m.deprecated.Add(ctx, 1, metric.WithAttributes(
+		semconv.HTTPRoute(route),
+		attribute.String("http.route.successor", successor),
+	))
+}
+
+// RecordBindingFailure records a request whose input could not be decoded.
+// source is where the input was read from, such as "body" or "path", and
+// reason why it was rejected, such as "syntax". field is the offending
+// field when known.
+func (m *HTTPMetrics) RecordBindingFailure(ctx context.Context, route, source, reason, field string) {
+	attrs := []attribute.KeyValue{
+		semconv.HTTPRoute(route),
+		attribute.String("binding.source", source),
+		attribute.String("binding.reason", reason),
+	}
+	if field != "" {
+		attrs = append(attrs, attribute.String("binding.field", field))
+	}
+	m.bindingFailures.Add(ctx, 1, metric.WithAttributes(attrs...))
+}
+
+// RecordValidationFailure records a request field failing a validation rule, such as "required"
+func (m *HTTPMetrics) RecordValidationFailure(ctx context.Context, route, field, rule string) {
+	m.validationFailures.Add(ctx, 1, metric.WithAttributes(
+		semconv.HTTPRoute(route),
+		attribute.String("validation.field", field),
+		attribute.String("validation.rule", rule),
+	))
+}
+
+// DBMetrics records database queries
+type DBMetrics struct {
+	duration metric.Float64Histogram
+}
+
+// newDBMetrics creates the database instruments
+func newDBMetrics(meter metric.Meter) (*DBMetrics, error) {
+	duration, err := meter.Float64Histogram("db.client.operation.duration",
+		metric.WithUnit("s"),
+		metric.WithDescription("Duration of database client operations."),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create db.client.operation.duration: %w", err)
+	}
+
+	return &DBMetrics{duration: duration}, nil
+}
+
+// Record records a query of the given operation, such as SELECT, and its outcome
+func (m *DBMetrics) Record(ctx context.Context, operation string, d time.Duration, err error) {
+	attrs := []attribute.KeyValue{
+		semconv.DBSystemNamePostgreSQL,
+		semconv.DBOperationName(operation),
+	}
+	switch {
+	case errors.Is(err, context.Canceled):
+		// Queries aborted because the request was canceled did not fail
+		attrs = append(attrs, semconv.ErrorTypeKey.String("canceled"))
+	case err != nil:
+		attrs = append(attrs, semconv.ErrorTypeOther)
+	}
+	m.duration.Record(ctx, d.Seconds(), metric.WithAttributes(attrs...))
+}
+
+// CacheMetrics records cache lookups
+type CacheMetrics struct {
+	lookups metric.Int64Counter
+}
+
+// newCacheMetrics creates the cache instruments
+func newCacheMetrics(meter metric.Meter) (*CacheMetrics, error) {
+	lookups, err := meter.Int64Counter("cache.lookups",
+		metric.WithUnit("{lookup}"),
+		metric.WithDescription("Number of cache lookups, by cache and result."),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cache.lookups: %w", err)
+	}
+
+	return &CacheMetrics{lookups: lookups}, nil
+}
+
+// Hit records a cache hit in the named cache
+func (m *CacheMetrics) Hit(ctx context.Context, cache string) {
+	m.lookups.Add(ctx, 1, metric.WithAttributes(attribute.String("cache.name", cache), attribute.Bool("cache.hit", true)))
+}
+
+// Miss records a cache miss in the named cache
+func (m *CacheMetrics) Miss(ctx context.Context, cache string) {
+	m.lookups.Add(ctx, 1, metric.WithAttributes(attribute.String("cache.name", cache), attribute.Bool("cache.hit", false)))
+}
+
+// JobMetrics records background job executions and the jobs enqueued
+// while the queue was full
+type JobMetrics struct {
+	duration  metric.Float64Histogram
+	overflows metric.Int64Counter
+}
+
+// newJobMetrics creates the background job instruments
+func newJobMetrics(meter metric.Meter) (*JobMetrics, error) {
+	duration, err := meter.Float64Histogram("job.duration",
+		metric.WithUnit("s"),
+		metric.WithDescription("Duration of background job executions, by job type and outcome."),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create job.duration: %w", err)
+	}
+	overflows, err := meter.Int64Counter("job.enqueue.overflows",
+		metric.WithUnit("{job}"),
+		metric.WithDescription("Number of jobs enqueued while the queue was full, by job type and outcome."),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create job.enqueue.overflows: %w", err)
+	}
+
+	return &JobMetrics{duration: duration, overflows: overflows}, nil
+}
+
+// Record records the execution of a job of the given type and its outcome
+func (m *JobMetrics) Record(ctx context.Context, jobType string, d time.Duration, err error) {
+	outcome := "success"
+	if err != nil {
+		outcome = "failure"
+	}
+	m.duration.Record(ctx, d.Seconds(), metric.WithAttributes(
+		attribute.String("job.type", jobType),
+		attribute.String("job.outcome", outcome),
+	))
+}
+
+// RecordOverflow records a job of the given type enqueued while the queue
+// was full, and the outcome of the overflow policy: "blocked", "timed_out",
+// "dropped" or "spilled"
+func (m *JobMetrics) RecordOverflow(ctx context.Context, jobType, outcome string) {
+	m.overflows.Add(ctx, 1, metric.WithAttributes(
+		attribute.String("job.type", jobType),
+		attribute.String("job.overflow.outcome", outcome),
+	))
+}
+
+// ViewMetrics records materialized view refreshes and staleness
+type ViewMetrics struct {
+	duration  metric.Float64Histogram
+	staleness metric.Float64Gauge
+}
+
+// newViewMetrics creates the materialized view instruments
+func newViewMetrics(meter metric.Meter) (*ViewMetrics, error) {
+	duration, err := meter.Float64Histogram("db.materialized_view.refresh.duration",
+		metric.WithUnit("s"),
+		metric.WithDescription("Duration of materialized view refreshes, by view and outcome."),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create db.materialized_view.refresh.duration: %w", err)
+	}
+
+	staleness, err := meter.Float64Gauge("db.materialized_view.staleness",
+		metric.WithUnit("s"),
+		metric.WithDescription("Time since the last successful refresh of a materialized view."),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create db.materialized_view.staleness: %w", err)
+	}
+
+	return &ViewMetrics{duration: duration, staleness: staleness}, nil
+}
+
+// RecordRefresh records a refresh of the named view and its outcome
+func (m *ViewMetrics) RecordRefresh(ctx context.Context, view string, d time.Duration, err error) {
+	outcome := "success"
+	if err != nil {
+		outcome = "failure"
+	}
+	m.duration.Record(ctx, d.Seconds(), metric.WithAttributes(
+		attribute.String("db.materialized_view.name", view),
+		attribute.String("db.materialized_view.outcome", outcome),
+	))
+}
+
+// RecordStaleness records the time since the named view was last refreshed
+func (m *ViewMetrics) RecordStaleness(ctx context.Context, view string, staleness time.Duration) {
+	m.staleness.Record(ctx, staleness.Seconds(), metric.WithAttributes(
+		attribute.String("db.materialized_view.name", view),
+	))
+}
+
+// sqlOperation returns the first keyword of a SQL statement, such as SELECT
+func sqlOperation(sql string) string {
+	sql = strings.TrimSpace(sql)
+	if i := strings.IndexAny(sql, " \t\n("); i > 0 {
+		sql = sql[:i]
+	}
+	return strings.ToUpper(sql)
+}
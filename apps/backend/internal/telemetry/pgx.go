@@ -0,0 +1,42 @@
+package telemetry
+
+import (
+	"context"
+	"time"
+
+	pgx "github.com/jackc/pgx/v5"
+)
+
+// queryStartKey is the context key for the start of a traced query
+type queryStartKey struct{}
+
+// queryStart is the state carried from the start to the end of a query
+type queryStart struct {
+	operation string
+	start     time.Time
+}
+
+// QueryTracer is a pgx tracer recording every query in DBMetrics
+type QueryTracer struct {
+	metrics *DBMetrics
+}
+
+// NewQueryTracer creates a pgx tracer recording queries in metrics
+func NewQueryTracer(metrics *DBMetrics) *QueryTracer {
+	return &QueryTracer{metrics: metrics}
+}
+
+// TraceQueryStart implements pgx.QueryTracer
+func (t *QueryTracer) TraceQueryStart(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+	return context.WithValue(ctx, queryStartKey{}, queryStart{
+		operation: sqlOperation(data.SQL),
+		start:     time.Now(),
+	})
+}
+
+// TraceQueryEnd implements pgx.QueryTracer
+func (t *QueryTracer) TraceQueryEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryEndData) {
+	if qs, ok := ctx.Value(queryStartKey{}).(queryStart); ok {
+		t.metrics.Record(ctx, qs.operation, time.Since(qs.start), data.Err)
+	}
+}
@@ -0,0 +1,40 @@
+package telemetry
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+
+	"github.com/PrinceNarteh/go-boilerplate/internal/config"
+	"github.com/PrinceNarteh/go-boilerplate/internal/version"
+)
+
+// sentryFlushTimeout bounds the time spent sending the last events on shutdown
+const sentryFlushTimeout = 2 * time.Second
+
+// InitSentry initializes the Sentry client when a DSN is configured. Errors
+// are reported with middlewares.CaptureError, which does nothing without
+// it. The returned function sends the buffered events.
+func InitSentry(cfg *config.ObservabilityConfig) (func(), error) {
+	if cfg.Sentry.DSN == "" {
+		return func() {}, nil
+	}
+
+	err := sentry.Init(sentry.ClientOptions{
+		Dsn:              cfg.Sentry.DSN,
+		Environment:      cfg.Environment,
+		Release:          version.Version,
+		SampleRate:       cfg.Sentry.SampleRate,
+		Debug:            cfg.Sentry.Debug,
+		AttachStacktrace: true,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize Sentry: %w", err)
+	}
+	sentry.ConfigureScope(func(scope *sentry.Scope) {
+		scope.SetTag("service", cfg.ServiceName)
+	})
+
+	return func() { sentry.Flush(sentryFlushTimeout) }, nil
+}
@@ -0,0 +1,103 @@
+//go:build !nonewrelic
+
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/newrelic/go-agent/v3/newrelic"
+)
+
+// NewNewRelic starts the New Relic agent
+func NewNewRelic(opts NewRelicOptions) (Tracer, error) {
+	configOpts := []newrelic.ConfigOption{
+		newrelic.ConfigAppName(opts.AppName),
+		newrelic.ConfigLicense(opts.LicenseKey),
+		newrelic.ConfigAppLogForwardingEnabled(opts.AppLogForwarding),
+		newrelic.ConfigDistributedTracerEnabled(opts.DistributedTracing),
+	}
+
+	if opts.DebugLogging {
+		configOpts = append(configOpts, newrelic.ConfigDebugLogger(os.Stdout))
+	}
+
+	app, err := newrelic.NewApplication(configOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return newRelicTracer{app: app}, nil
+}
+
+// newRelicTracer implements Tracer with a New Relic application
+type newRelicTracer struct {
+	app *newrelic.Application
+}
+
+// StartTransaction implements Tracer
+func (t newRelicTracer) StartTransaction(name string) Transaction {
+	return newRelicTransaction{txn: t.app.StartTransaction(name)}
+}
+
+// RecordCustomEvent implements Tracer. Invalid events are reported by the agent's own logger.
+func (t newRelicTracer) RecordCustomEvent(eventType string, attrs map[string]any) {
+	t.app.RecordCustomEvent(eventType, attrs)
+}
+
+// Shutdown implements Tracer
+func (t newRelicTracer) Shutdown(timeout time.Duration) {
+	t.app.Shutdown(timeout)
+}
+
+// newRelicTransaction implements Transaction with a New Relic transaction
+type newRelicTransaction struct {
+	txn *newrelic.Transaction
+}
+
+// End implements Transaction
+func (t newRelicTransaction) End() { t.txn.End() }
+
+// AddAttribute implements Transaction
+func (t newRelicTransaction) AddAttribute(key string, value any) { t.txn.AddAttribute(key, value) }
+
+// NoticeError implements Transaction. The class and attributes of an
+// Error are recognized by the agent.
+func (t newRelicTransaction) NoticeError(err error) { t.txn.NoticeError(err) }
+
+// NewGoroutine implements Transaction
+func (t newRelicTransaction) NewGoroutine() Transaction {
+	return newRelicTransaction{txn: t.txn.NewGoroutine()}
+}
+
+// AcceptDistributedTraceHeaders implements Transaction
+func (t newRelicTransaction) AcceptDistributedTraceHeaders(transport Transport, headers http.Header) {
+	t.txn.AcceptDistributedTraceHeaders(newrelic.TransportType(transport), headers)
+}
+
+// InsertDistributedTraceHeaders implements Transaction
+func (t newRelicTransaction) InsertDistributedTraceHeaders(headers http.Header) {
+	t.txn.InsertDistributedTraceHeaders(headers)
+}
+
+// StartProducerSegment implements Transaction
+func (t newRelicTransaction) StartProducerSegment(library, destination string) Segment {
+	return &newrelic.MessageProducerSegment{
+		StartTime:       t.txn.StartSegmentNow(),
+		Library:         library,
+		DestinationType: newrelic.MessageTopic,
+		DestinationName: destination,
+	}
+}
+
+// TraceIDs implements Transaction
+func (t newRelicTransaction) TraceIDs() (string, string) {
+	metadata := t.txn.GetTraceMetadata()
+	return metadata.TraceID, metadata.SpanID
+}
+
+// newContext implements contextCarrier, for the pgx integration of New Relic
+func (t newRelicTransaction) newContext(ctx context.Context) context.Context {
+	return newrelic.NewContext(ctx, t.txn)
+}
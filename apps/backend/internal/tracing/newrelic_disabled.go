@@ -0,0 +1,11 @@
+//go:build nonewrelic
+
+package tracing
+
+import "errors"
+
+// NewNewRelic fails in builds with the nonewrelic tag, which leave the
+// New Relic agent out of the binary
+func NewNewRelic(NewRelicOptions) (Tracer, error) {
+	return nil, errors.New("built without New Relic support, rebuild without the nonewrelic tag")
+}
@@ -0,0 +1,137 @@
+// Package tracing records units of work, such as jobs, messages and gRPC
+// calls, as transactions of an APM agent, New Relic when it is configured.
+//
+// Instrumented packages only depend on the Tracer and Transaction
+// interfaces, so binaries built with the nonewrelic tag leave the agent
+// out. A nil Tracer is valid wherever one is accepted, and transactions
+// missing from a context are replaced by ones doing nothing.
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// Transport is the kind of transport distributed trace headers come from
+type Transport string
+
+// Transports of distributed trace headers
+const (
+	TransportHTTP  Transport = "HTTP"
+	TransportKafka Transport = "Kafka"
+	TransportQueue Transport = "Queue"
+	TransportOther Transport = "Other"
+)
+
+// NewRelicOptions configures the New Relic agent started by NewNewRelic
+type NewRelicOptions struct {
+	AppName            string
+	LicenseKey         string
+	AppLogForwarding   bool
+	DistributedTracing bool
+	// DebugLogging writes the debug logs of the agent to stdout
+	DebugLogging bool
+}
+
+// Tracer starts transactions and records custom events
+type Tracer interface {
+	// StartTransaction starts a transaction named name, ended by the caller
+	StartTransaction(name string) Transaction
+	// RecordCustomEvent records an event of eventType with its attributes
+	RecordCustomEvent(eventType string, attrs map[string]any)
+	// Shutdown sends the pending data, waiting up to timeout
+	Shutdown(timeout time.Duration)
+}
+
+// Transaction is a unit of work being traced
+type Transaction interface {
+	End()
+	AddAttribute(key string, value any)
+	// NoticeError records err on the transaction, see Error
+	NoticeError(err error)
+	// NewGoroutine returns the transaction to use in another goroutine
+	NewGoroutine() Transaction
+	// AcceptDistributedTraceHeaders continues the trace of the caller
+	AcceptDistributedTraceHeaders(transport Transport, headers http.Header)
+	// InsertDistributedTraceHeaders adds the headers continuing the trace to headers
+	InsertDistributedTraceHeaders(headers http.Header)
+	// StartProducerSegment times the publication of a message to the
+	// destination topic with library, until End is called on the segment
+	StartProducerSegment(library, destination string) Segment
+	// TraceIDs returns the IDs of the trace and of the current span
+	TraceIDs() (traceID, spanID string)
+}
+
+// Segment is a timed part of a transaction
+type Segment interface {
+	End()
+}
+
+// Error is an error noticed with a class and attributes of its own
+type Error struct {
+	Message    string
+	Class      string
+	Attributes map[string]any
+}
+
+// Error implements the error interface
+func (e Error) Error() string { return e.Message }
+
+// ErrorClass returns the class grouping the error
+func (e Error) ErrorClass() string { return e.Class }
+
+// ErrorAttributes returns the attributes of the error
+func (e Error) ErrorAttributes() map[string]any { return e.Attributes }
+
+// contextKey is the key of the transaction in a context
+type contextKey struct{}
+
+// contextCarrier is implemented by transactions also storing the
+// transaction of their agent, for its integrations reading it from contexts
+type contextCarrier interface {
+	newContext(ctx context.Context) context.Context
+}
+
+// NewContext returns a copy of ctx carrying txn
+func NewContext(ctx context.Context, txn Transaction) context.Context {
+	if c, ok := txn.(contextCarrier); ok {
+		ctx = c.newContext(ctx)
+	}
+	return context.WithValue(ctx, contextKey{}, txn)
+}
+
+// FromContext returns the transaction of ctx, and whether there is one.
+// Without one, it returns a transaction doing nothing.
+func FromContext(ctx context.Context) (Transaction, bool) {
+	txn, ok := ctx.Value(contextKey{}).(Transaction)
+	if !ok {
+		return nopTransaction{}, false
+	}
+	return txn, true
+}
+
+// Start starts a transaction of tracer, doing nothing when tracer is nil
+func Start(tracer Tracer, name string) Transaction {
+	if tracer == nil {
+		return nopTransaction{}
+	}
+	return tracer.StartTransaction(name)
+}
+
+// nopTransaction is a transaction doing nothing
+type nopTransaction struct{}
+
+func (nopTransaction) End()                                                 {}
+func (nopTransaction) AddAttribute(string, any)                             {}
+func (nopTransaction) NoticeError(error)                                    {}
+func (nopTransaction) NewGoroutine() Transaction                            { return nopTransaction{} }
+func (nopTransaction) AcceptDistributedTraceHeaders(Transport, http.Header) {}
+func (nopTransaction) InsertDistributedTraceHeaders(http.Header)            {}
+func (nopTransaction) StartProducerSegment(string, string) Segment          { return nopSegment{} }
+func (nopTransaction) TraceIDs() (string, string)                           { return "", "" }
+
+// nopSegment is a segment doing nothing
+type nopSegment struct{}
+
+func (nopSegment) End() {}
@@ -0,0 +1,175 @@
+// Package usagestats sends anonymous usage statistics about the boilerplate,
+// to help its maintainers learn which features are used.
+//
+// Reporting is strictly opt-in: nothing is sent unless usage_stats.enabled is
+// set to true. A report contains only the fields of Report, documented below.
+// It never contains request or database data, configuration values, hostnames,
+// IP addresses or credentials. The installation ID is random and regenerated
+// on every start, so reports cannot be linked across restarts.
+package usagestats
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"runtime"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+
+	"github.com/PrinceNarteh/go-boilerplate/internal/config"
+	"github.com/PrinceNarteh/go-boilerplate/internal/errs"
+	"github.com/PrinceNarteh/go-boilerplate/internal/libs/async"
+	"github.com/PrinceNarteh/go-boilerplate/internal/libs/retry"
+	"github.com/PrinceNarteh/go-boilerplate/internal/version"
+)
+
+const (
+	// SchemaVersion is the version of the Report schema, incremented on every change
+	SchemaVersion = 1
+
+	defaultInterval = 24 * time.Hour   // Default interval between reports
+	firstReport     = 10 * time.Minute // Delay before the first report, skipping short-lived processes
+	sendTimeout     = 10 * time.Second // Timeout for sending a report
+	sendAttempts    = 3                // Attempts at sending a report
+)
+
+// Report is the complete content of a usage report, sent as JSON
+type Report struct {
+	// SchemaVersion is the version of this schema
+	SchemaVersion int `json:"schema_version"`
+	// InstallationID is a random UUID generated when the process starts
+	InstallationID string `json:"installation_id"`
+	// Version is the version of the application build
+	Version string `json:"version"`
+	// GoVersion is the Go version the binary was built with
+	GoVersion string `json:"go_version"`
+	// OS and Arch are the platform the binary runs on
+	OS   string `json:"os"`
+	Arch string `json:"arch"`
+	// Features maps each optional subsystem to whether it is enabled,
+	// e.g. "database", "redis" or "rate_limit"
+	Features map[string]bool `json:"features"`
+	// UptimeSeconds is the number of whole seconds since the process started
+	UptimeSeconds int64 `json:"uptime_seconds"`
+}
+
+// Reporter periodically sends a Report to the configured endpoint
+type Reporter struct {
+	cfg            config.UsageStatsConfig
+	features       map[string]bool
+	installationID string
+	started        time.Time
+	client         *http.Client
+	logger         *zerolog.Logger
+	stop           func()
+}
+
+// NewReporter creates a reporter for the given enabled features
+func NewReporter(cfg config.UsageStatsConfig, features map[string]bool, logger *zerolog.Logger) *Reporter {
+	if cfg.Interval <= 0 {
+		cfg.Interval = defaultInterval
+	}
+	return &Reporter{
+		cfg:            cfg,
+		features:       features,
+		installationID: uuid.NewString(),
+		started:        time.Now(),
+		client:         &http.Client{Timeout: sendTimeout},
+		logger:         logger,
+	}
+}
+
+// Start sends reports in the background until Stop is called.
+// It does nothing unless usage statistics are enabled.
+func (r *Reporter) Start() {
+	if !r.cfg.Enabled || r.stop != nil {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := async.Go(ctx, "usagestats.reporter", func(ctx context.Context) error {
+		timer := time.NewTimer(firstReport)
+		defer timer.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-timer.C:
+			}
+
+			if err := r.Send(ctx); err != nil && ctx.Err() == nil {
+				r.logger.Debug().Err(err).Msg("Failed to send usage statistics")
+			}
+			timer.Reset(r.cfg.Interval)
+		}
+	})
+
+	r.stop = func() {
+		cancel()
+		<-done
+	}
+	r.logger.Info().Str("endpoint", r.cfg.Endpoint).Msg("Anonymous usage statistics enabled")
+}
+
+// Stop stops sending reports
+func (r *Reporter) Stop() {
+	if r.stop != nil {
+		r.stop()
+		r.stop = nil
+	}
+}
+
+// Report returns the report that would be sent now
+func (r *Reporter) Report() Report {
+	return Report{
+		SchemaVersion:  SchemaVersion,
+		InstallationID: r.installationID,
+		Version:        version.Version,
+		GoVersion:      runtime.Version(),
+		OS:             runtime.GOOS,
+		Arch:           runtime.GOARCH,
+		Features:       r.features,
+		UptimeSeconds:  int64(time.Since(r.started).Seconds()),
+	}
+}
+
+// Send sends a report now, retrying transient failures
+func (r *Reporter) Send(ctx context.Context) error {
+	body, err := json.Marshal(r.Report())
+	if err != nil {
+		return fmt.Errorf("failed to encode usage report: %w", err)
+	}
+
+	return retry.Do(ctx, retry.Policy{MaxAttempts: sendAttempts, MinBackoff: time.Second}, func(ctx context.Context) error {
+		return r.send(ctx, body)
+	})
+}
+
+// send posts an encoded report once
+func (r *Reporter) send(ctx context.Context, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.cfg.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create usage report request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send usage report: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		err := fmt.Errorf("usage report rejected with status %d", resp.StatusCode)
+		if errs.IsRetryableStatus(resp.StatusCode) {
+			return errs.Retryable(err)
+		}
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,40 @@
+// Package version exposes build information about the running binary.
+//
+// Version, Commit and BuildTime can be set at build time with
+//
+//	go build -ldflags "-X github.com/PrinceNarteh/go-boilerplate/internal/version.Version=1.2.3"
+//
+// When they are not set, Commit and BuildTime fall back to the VCS
+// information embedded by the Go toolchain.
+package version
+
+import (
+	"runtime/debug"
+)
+
+// Build information, overridable with -ldflags -X
+var (
+	Version   = "dev"
+	Commit    = ""
+	BuildTime = ""
+)
+
+func init() {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return
+	}
+
+	for _, setting := range info.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			if Commit == "" {
+				Commit = setting.Value
+			}
+		case "vcs.time":
+			if BuildTime == "" {
+				BuildTime = setting.Value
+			}
+		}
+	}
+}
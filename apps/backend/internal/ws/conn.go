@@ -0,0 +1,181 @@
+package ws
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/rs/zerolog"
+
+	"github.com/PrinceNarteh/go-boilerplate/internal/auth"
+)
+
+// OnMessage handles a message from a client. ctx carries the principal of
+// the connection and its logger, and is canceled when the connection
+// closes. Messages of a connection are handled one at a time.
+type OnMessage func(ctx context.Context, c *Conn, data []byte)
+
+// Conn is an open WebSocket connection of an authenticated user
+type Conn struct {
+	hub       *Hub
+	ws        *websocket.Conn
+	principal *auth.Principal
+	logger    *zerolog.Logger
+
+	send      chan []byte
+	done      chan struct{}
+	closeOnce sync.Once
+	closeCode int
+	closeText string
+}
+
+// Principal returns the user the connection was authenticated as
+func (c *Conn) Principal() *auth.Principal {
+	return c.principal
+}
+
+// Send sends msg, encoded as JSON, to the client
+func (c *Conn) Send(msg any) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("failed to encode message: %w", err)
+	}
+	return c.enqueue(data)
+}
+
+// Close closes the connection with the normal closure status
+func (c *Conn) Close() {
+	c.close(websocket.CloseNormalClosure, "")
+}
+
+// enqueue queues a message for the write pump without blocking, closing
+// the connection when its queue is full
+func (c *Conn) enqueue(data []byte) error {
+	select {
+	case <-c.done:
+		return ErrClosed
+	default:
+	}
+
+	select {
+	case c.send <- data:
+		return nil
+	default:
+		c.logger.Warn().Msg("WebSocket client too slow, closing connection")
+		c.close(websocket.ClosePolicyViolation, "too slow")
+		return ErrSlowClient
+	}
+}
+
+// close asks the write pump to send a close frame with code and close the
+// connection. Only the first call has an effect.
+func (c *Conn) close(code int, text string) {
+	c.closeOnce.Do(func() {
+		c.closeCode = code
+		c.closeText = text
+		close(c.done)
+	})
+}
+
+// run starts the pumps of the connection
+func (c *Conn) run(ctx context.Context, onMessage OnMessage) {
+	readDone := make(chan struct{})
+	c.hub.wg.Add(2)
+	go func() {
+		defer c.hub.wg.Done()
+		defer close(readDone)
+		c.readPump(ctx, onMessage)
+	}()
+	go func() {
+		defer c.hub.wg.Done()
+		c.writePump(readDone)
+	}()
+}
+
+// readPump reads messages until the connection fails or closes. Pongs
+// extend the read deadline, so a client that stops answering pings is
+// disconnected.
+func (c *Conn) readPump(ctx context.Context, onMessage OnMessage) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		<-c.done
+		cancel()
+	}()
+
+	pongTimeout := c.hub.cfg.PongTimeout
+	c.ws.SetReadLimit(c.hub.cfg.MaxMessageBytes)
+	_ = c.ws.SetReadDeadline(time.Now().Add(pongTimeout))
+	c.ws.SetPongHandler(func(string) error {
+		return c.ws.SetReadDeadline(time.Now().Add(pongTimeout))
+	})
+
+	for {
+		_, data, err := c.ws.ReadMessage()
+		if err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				c.logger.Debug().Err(err).Msg("WebSocket connection lost")
+			}
+			// The client answered or sent a close frame, or the connection is lost
+			c.close(websocket.CloseAbnormalClosure, "")
+			return
+		}
+		if onMessage != nil {
+			c.handle(ctx, onMessage, data)
+		}
+	}
+}
+
+// handle calls onMessage, recovering panics
+func (c *Conn) handle(ctx context.Context, onMessage OnMessage, data []byte) {
+	defer func() {
+		if p := recover(); p != nil {
+			c.logger.Error().Interface("panic", p).Msg("WebSocket message handler panicked")
+		}
+	}()
+	onMessage(ctx, c, data)
+}
+
+// writePump sends queued messages and pings until the connection closes,
+// then sends the close frame and waits briefly for the client to answer it
+func (c *Conn) writePump(readDone <-chan struct{}) {
+	pingInterval := c.hub.cfg.PongTimeout * 9 / 10
+	ticker := time.NewTicker(pingInterval)
+	defer func() {
+		ticker.Stop()
+		c.ws.Close()
+		c.hub.unregister(c)
+	}()
+
+	writeTimeout := c.hub.cfg.WriteTimeout
+	for {
+		select {
+		case data := <-c.send:
+			_ = c.ws.SetWriteDeadline(time.Now().Add(writeTimeout))
+			if err := c.ws.WriteMessage(websocket.TextMessage, data); err != nil {
+				c.close(websocket.CloseAbnormalClosure, "")
+				return
+			}
+		case <-ticker.C:
+			deadline := time.Now().Add(writeTimeout)
+			if err := c.ws.WriteControl(websocket.PingMessage, nil, deadline); err != nil {
+				c.close(websocket.CloseAbnormalClosure, "")
+				return
+			}
+		case <-c.done:
+			// Abnormal closure is never sent, the connection is closed already
+			if c.closeCode != websocket.CloseAbnormalClosure {
+				msg := websocket.FormatCloseMessage(c.closeCode, c.closeText)
+				_ = c.ws.WriteControl(websocket.CloseMessage, msg, time.Now().Add(writeTimeout))
+			}
+			select {
+			case <-readDone:
+			case <-time.After(closeGracePeriod):
+			}
+			return
+		}
+	}
+}
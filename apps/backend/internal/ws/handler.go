@@ -0,0 +1,108 @@
+package ws
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/rs/zerolog"
+
+	"github.com/PrinceNarteh/go-boilerplate/internal/auth"
+	"github.com/PrinceNarteh/go-boilerplate/internal/errs"
+	"github.com/PrinceNarteh/go-boilerplate/internal/middlewares"
+	"github.com/PrinceNarteh/go-boilerplate/internal/routers"
+)
+
+// accessTokenParam is the query parameter carrying the token of browsers,
+// which cannot set headers on WebSocket handshakes
+const accessTokenParam = "access_token"
+
+// Handler upgrades authenticated requests to WebSocket connections
+type Handler struct {
+	hub       *Hub
+	authn     *middlewares.Authenticator
+	upgrader  websocket.Upgrader
+	onMessage OnMessage
+}
+
+// NewHandler creates a handler registering connections on hub. The
+// handshake is authenticated with the bearer token of the Authorization
+// header, or of the access_token query parameter, whose principal is
+// resolved by authenticator as on the HTTP routes.
+// Browsers may only connect from allowedOrigins, as with CORS. onMessage
+// handles client messages and may be nil when clients only listen.
+func NewHandler(
+	hub *Hub,
+	authenticator *middlewares.Authenticator,
+	allowedOrigins []string,
+	onMessage OnMessage,
+) *Handler {
+	return &Handler{
+		hub:   hub,
+		authn: authenticator,
+		upgrader: websocket.Upgrader{
+			HandshakeTimeout: hub.cfg.WriteTimeout,
+			CheckOrigin: func(r *http.Request) bool {
+				origin := r.Header.Get("Origin")
+				// Clients other than browsers send no origin
+				return origin == "" || slices.Contains(allowedOrigins, "*") || slices.Contains(allowedOrigins, origin)
+			},
+		},
+		onMessage: onMessage,
+	}
+}
+
+// RegisterRoutes implements routers.Module
+func (h *Handler) RegisterRoutes(g *routers.RouteGroup) {
+	path := h.hub.cfg.Path
+	if path == "" {
+		path = "/ws"
+	}
+	g.GET(path, h.ServeHTTP)
+}
+
+// ServeHTTP authenticates and upgrades the request
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		token = r.URL.Query().Get(accessTokenParam)
+	}
+	if token == "" {
+		errs.WriteJSON(w, errs.ErrUnauthorized)
+		return
+	}
+	ctx, err := h.authn.Authenticate(r.Context(), token, r.Header.Get(h.authn.TenantHeader()))
+	if err != nil {
+		errs.WriteJSON(w, middlewares.AuthenticationError(r.Context(), err))
+		return
+	}
+	principal, _ := auth.FromContext(ctx)
+
+	// The upgrader writes the error response of failed handshakes
+	ws, err := h.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+
+	logger := zerolog.Ctx(ctx).With().Logger()
+	c := &Conn{
+		hub:       h.hub,
+		ws:        ws,
+		principal: principal,
+		logger:    &logger,
+		send:      make(chan []byte, h.hub.cfg.SendBuffer),
+		done:      make(chan struct{}),
+	}
+	if !h.hub.register(c) {
+		msg := websocket.FormatCloseMessage(websocket.CloseTryAgainLater, "server shutting down")
+		_ = ws.WriteControl(websocket.CloseMessage, msg, time.Now().Add(h.hub.cfg.WriteTimeout))
+		ws.Close()
+		return
+	}
+
+	// The connection outlives the request
+	c.run(logger.WithContext(context.WithoutCancel(ctx)), h.onMessage)
+}
@@ -0,0 +1,177 @@
+// Package ws serves WebSocket connections.
+//
+// A Handler authenticates the handshake with the bearer token of the
+// client, upgrades the request and registers the connection on a Hub. Each
+// connection runs a read pump, passing client messages to the OnMessage
+// callback and answering pings, and a write pump, sending queued messages
+// and pinging the client so dead connections are detected. The hub sends
+// messages to every connection or to those of a user, and closes them all
+// on shutdown.
+//
+// Messages are sent as JSON text frames, conventionally a Message naming
+// its type.
+package ws
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/rs/zerolog"
+
+	"github.com/PrinceNarteh/go-boilerplate/internal/config"
+)
+
+const (
+	defaultPongTimeout     = 60 * time.Second // Default time waited for a pong
+	defaultWriteTimeout    = 10 * time.Second // Default time allowed to write a message
+	defaultMaxMessageBytes = 64 << 10         // Default limit of client messages
+	defaultSendBuffer      = 64               // Default number of messages queued per connection
+	closeGracePeriod       = time.Second      // Time given to clients to answer a close frame
+)
+
+var (
+	// ErrClosed is returned when sending to a closed connection or hub
+	ErrClosed = errors.New("ws: connection closed")
+	// ErrSlowClient is returned when the queue of a connection is full. The
+	// connection is closed, and the client may reconnect and catch up.
+	ErrSlowClient = errors.New("ws: client too slow")
+)
+
+// Message is the conventional envelope of messages
+type Message struct {
+	Type string `json:"type"`
+	Data any    `json:"data,omitempty"`
+}
+
+// Hub keeps the open connections
+type Hub struct {
+	cfg    config.WebSocketConfig
+	logger *zerolog.Logger
+
+	mu      sync.RWMutex
+	conns   map[*Conn]struct{}
+	byUser  map[int]map[*Conn]struct{}
+	closing bool
+	wg      sync.WaitGroup
+}
+
+// NewHub creates a hub. Unset settings of cfg use the defaults.
+func NewHub(cfg config.WebSocketConfig, logger *zerolog.Logger) *Hub {
+	if cfg.PongTimeout <= 0 {
+		cfg.PongTimeout = defaultPongTimeout
+	}
+	if cfg.WriteTimeout <= 0 {
+		cfg.WriteTimeout = defaultWriteTimeout
+	}
+	if cfg.MaxMessageBytes <= 0 {
+		cfg.MaxMessageBytes = defaultMaxMessageBytes
+	}
+	if cfg.SendBuffer <= 0 {
+		cfg.SendBuffer = defaultSendBuffer
+	}
+
+	return &Hub{
+		cfg:    cfg,
+		logger: logger,
+		conns:  make(map[*Conn]struct{}),
+		byUser: make(map[int]map[*Conn]struct{}),
+	}
+}
+
+// Broadcast sends msg to every connection. Connections too slow to keep up
+// are closed.
+func (h *Hub) Broadcast(msg any) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("failed to encode message: %w", err)
+	}
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for c := range h.conns {
+		_ = c.enqueue(data)
+	}
+	return nil
+}
+
+// SendToUser sends msg to every connection of a user, such as their open
+// browser tabs. Sending to a user without connections does nothing.
+func (h *Hub) SendToUser(userID int, msg any) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("failed to encode message: %w", err)
+	}
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for c := range h.byUser[userID] {
+		_ = c.enqueue(data)
+	}
+	return nil
+}
+
+// Count returns the number of open connections
+func (h *Hub) Count() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.conns)
+}
+
+// Shutdown closes every connection with the going away status, so clients
+// reconnect to another instance, and waits for them to close until ctx is
+// done. New connections are refused afterwards.
+func (h *Hub) Shutdown(ctx context.Context) error {
+	h.mu.Lock()
+	h.closing = true
+	for c := range h.conns {
+		c.close(websocket.CloseGoingAway, "server shutting down")
+	}
+	h.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		h.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("failed to close WebSocket connections: %w", ctx.Err())
+	}
+}
+
+// register adds a connection, unless the hub is shutting down
+func (h *Hub) register(c *Conn) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closing {
+		return false
+	}
+
+	h.conns[c] = struct{}{}
+	userID := c.principal.UserID
+	if h.byUser[userID] == nil {
+		h.byUser[userID] = make(map[*Conn]struct{})
+	}
+	h.byUser[userID][c] = struct{}{}
+	return true
+}
+
+// unregister removes a closed connection
+func (h *Hub) unregister(c *Conn) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	delete(h.conns, c)
+	userID := c.principal.UserID
+	delete(h.byUser[userID], c)
+	if len(h.byUser[userID]) == 0 {
+		delete(h.byUser, userID)
+	}
+}